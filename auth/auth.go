@@ -2,6 +2,8 @@
 package auth
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"time"
 
@@ -16,37 +18,69 @@ var (
 	ErrExpiredToken = errors.New("token has expired")
 )
 
+// AccessTokenTTL is how long an access token is valid. Clients keep a session alive
+// past this by exchanging their refresh token for a new access token.
+const AccessTokenTTL = 15 * time.Minute
+
 // JWTClaims represents the JWT claims structure used for authentication tokens.
 // It includes the user ID and username along with standard JWT registered claims.
+// SessionID ties the token to a server-side device session so it can be revoked.
 type JWTClaims struct {
-	UserID   uuid.UUID `json:"user_id"`
-	Username string     `json:"username"`
+	UserID    uuid.UUID `json:"user_id"`
+	Username  string    `json:"username"`
+	SessionID uuid.UUID `json:"sid,omitempty"`
 	jwt.RegisteredClaims
 }
 
 // TokenManager handles JWT token generation and validation operations.
-// It manages tokens using a secret key for signing and verification.
+// Tokens are signed with the current secret key. Previous keys are kept for verification
+// only, so the secret can be rotated without logging everyone out.
 type TokenManager struct {
-	secretKey []byte
+	secretKey    []byte
+	keyID        string
+	previousKeys map[string][]byte // Key ID -> retired secret
 }
 
 // NewTokenManager creates a new TokenManager with the provided secret key.
 // The secret key is used to sign and verify JWT tokens.
 func NewTokenManager(secretKey string) *TokenManager {
 	return &TokenManager{
-		secretKey: []byte(secretKey),
+		secretKey:    []byte(secretKey),
+		keyID:        keyID(secretKey),
+		previousKeys: make(map[string][]byte),
 	}
 }
 
-// GenerateToken generates a JWT token for a user.
-// The token is valid for 24 hours and includes the user ID and username in the claims.
-// Returns the token string and any error that occurred during generation.
-func (tm *TokenManager) GenerateToken(userID uuid.UUID, username string) (string, error) {
-	expirationTime := time.Now().Add(24 * time.Hour) // Token valid for 24 hours
-	
+// AddPreviousKeys registers retired secret keys that are still accepted when validating
+// tokens. Tokens signed with them stay valid until they expire.
+func (tm *TokenManager) AddPreviousKeys(secretKeys ...string) {
+	for _, key := range secretKeys {
+		if key != "" {
+			tm.previousKeys[keyID(key)] = []byte(key)
+		}
+	}
+}
+
+// keyID derives the "kid" header value for a secret key without revealing it.
+func keyID(secretKey string) string {
+	sum := sha256.Sum256([]byte(secretKey))
+	return hex.EncodeToString(sum[:4])
+}
+
+// GenerateSessionToken generates a short-lived access token bound to a device session.
+// The token is valid for AccessTokenTTL.
+func (tm *TokenManager) GenerateSessionToken(userID uuid.UUID, username string, sessionID uuid.UUID) (string, error) {
+	return tm.generate(userID, username, sessionID, AccessTokenTTL)
+}
+
+// generate signs a token with the current key.
+func (tm *TokenManager) generate(userID uuid.UUID, username string, sessionID uuid.UUID, ttl time.Duration) (string, error) {
+	expirationTime := time.Now().Add(ttl)
+
 	claims := &JWTClaims{
-		UserID:   userID,
-		Username: username,
+		UserID:    userID,
+		Username:  username,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -56,6 +90,7 @@ func (tm *TokenManager) GenerateToken(userID uuid.UUID, username string) (string
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = tm.keyID
 	tokenString, err := token.SignedString(tm.secretKey)
 	if err != nil {
 		return "", err
@@ -74,7 +109,15 @@ func (tm *TokenManager) ValidateToken(tokenString string) (*JWTClaims, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, ErrInvalidToken
 		}
-		return tm.secretKey, nil
+		// Tokens without a key ID predate rotation support and were signed with the current key
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" || kid == tm.keyID {
+			return tm.secretKey, nil
+		}
+		if key, ok := tm.previousKeys[kid]; ok {
+			return key, nil
+		}
+		return nil, ErrInvalidToken
 	})
 
 	if err != nil {
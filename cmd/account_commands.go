@@ -0,0 +1,191 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"terminal-sh/services"
+	"terminal-sh/ui"
+
+	"github.com/google/uuid"
+)
+
+// handlePASSWD handles the passwd command
+func (h *CommandHandler) handlePASSWD(args []string) *CommandResult {
+	if h.user == nil {
+		return &CommandResult{Error: fmt.Errorf("not authenticated")}
+	}
+
+	// Admin: issue a reset token for another user
+	if len(args) > 0 && (args[0] == "--reset" || args[0] == "-r") {
+		if len(args) != 2 {
+			return &CommandResult{Error: fmt.Errorf("usage: passwd --reset <username>")}
+		}
+		token, expiresAt, err := h.userService.IssuePasswordResetToken(h.user, args[1])
+		if err != nil {
+			if errors.Is(err, services.ErrNotAdmin) {
+				return &CommandResult{Error: fmt.Errorf("passwd --reset: permission denied")}
+			}
+			return &CommandResult{Error: err}
+		}
+
+		var output strings.Builder
+		output.WriteString(ui.SuccessStyle.Render(fmt.Sprintf("Reset token issued for %s", args[1])) + "\n")
+		output.WriteString(fmt.Sprintf("  Token:   %s\n", ui.AccentBoldStyle.Render(token)))
+		output.WriteString(fmt.Sprintf("  Expires: %s\n", expiresAt.Format("2006-01-02 15:04 MST")))
+		output.WriteString(ui.InfoStyle.Render("The user enters this token as their password at login and chooses a new one.") + "\n")
+		return &CommandResult{Output: output.String()}
+	}
+
+	// Passwords are prompted for so they never show up in the command line or input history
+	if len(args) != 0 {
+		return &CommandResult{Error: fmt.Errorf("usage: passwd - Change your password (you'll be prompted)\n" +
+			"       passwd --reset <username> - Issue a reset token (admin)")}
+	}
+	return &CommandResult{PromptSecret: &SecretPromptRequest{
+		Prompts: []string{"Current password: ", "New password: ", "Retype new password: "},
+		Submit:  h.changePassword,
+	}}
+}
+
+// changePassword finishes passwd with the current password and the new one entered twice
+func (h *CommandHandler) changePassword(values []string) *CommandResult {
+	if len(values) != 3 {
+		return &CommandResult{Error: fmt.Errorf("passwd: password unchanged")}
+	}
+	if values[1] != values[2] {
+		return &CommandResult{Error: fmt.Errorf("passwd: passwords do not match")}
+	}
+	if err := h.userService.ChangePassword(h.user.ID, values[0], values[1], h.deviceSessionID); err != nil {
+		return &CommandResult{Error: fmt.Errorf("passwd: %w", err)}
+	}
+	return &CommandResult{Output: ui.SuccessStyle.Render("Password changed. Your other devices have been signed out.") + "\n"}
+}
+
+// handle2FA handles the 2fa command
+func (h *CommandHandler) handle2FA(args []string) *CommandResult {
+	if h.user == nil {
+		return &CommandResult{Error: fmt.Errorf("not authenticated")}
+	}
+
+	subcommand := "status"
+	if len(args) > 0 {
+		subcommand = strings.ToLower(args[0])
+	}
+
+	switch subcommand {
+	case "status":
+		if h.user.TOTPEnabled {
+			return &CommandResult{Output: ui.SuccessStyle.Render("Two-factor authentication is enabled") + "\n"}
+		}
+		return &CommandResult{Output: ui.InfoStyle.Render("Two-factor authentication is disabled. Run '2fa enable' to set it up.") + "\n"}
+
+	case "enable":
+		secret, url, err := h.userService.BeginTOTPEnrollment(h.user)
+		if err != nil {
+			return &CommandResult{Error: err}
+		}
+		var output strings.Builder
+		output.WriteString(ui.InfoStyle.Render("Add this account to your authenticator app:") + "\n")
+		output.WriteString(fmt.Sprintf("  Secret: %s\n", ui.AccentBoldStyle.Render(secret)))
+		output.WriteString(fmt.Sprintf("  URL:    %s\n", url))
+		output.WriteString(ui.InfoStyle.Render("Then run '2fa confirm <code>' with the current code to turn it on.") + "\n")
+		return &CommandResult{Output: output.String()}
+
+	case "confirm":
+		if len(args) != 2 {
+			return &CommandResult{Error: fmt.Errorf("usage: 2fa confirm <code>")}
+		}
+		if err := h.userService.ConfirmTOTP(h.user, args[1]); err != nil {
+			return &CommandResult{Error: err}
+		}
+		return &CommandResult{Output: ui.SuccessStyle.Render("Two-factor authentication enabled. You will be asked for a code at login.") + "\n"}
+
+	case "disable":
+		if len(args) != 2 {
+			return &CommandResult{Error: fmt.Errorf("usage: 2fa disable <code>")}
+		}
+		if err := h.userService.DisableTOTP(h.user, args[1]); err != nil {
+			return &CommandResult{Error: err}
+		}
+		return &CommandResult{Output: ui.SuccessStyle.Render("Two-factor authentication disabled") + "\n"}
+
+	default:
+		return &CommandResult{Error: fmt.Errorf("usage: 2fa [status|enable|confirm <code>|disable <code>]")}
+	}
+}
+
+// handleSESSIONS handles the sessions command
+func (h *CommandHandler) handleSESSIONS(args []string) *CommandResult {
+	if h.user == nil {
+		return &CommandResult{Error: fmt.Errorf("not authenticated")}
+	}
+
+	if len(args) == 0 {
+		return h.listSessions()
+	}
+
+	if strings.ToLower(args[0]) != "revoke" || len(args) != 2 {
+		return &CommandResult{Error: fmt.Errorf("usage: sessions [revoke <id>|revoke all]")}
+	}
+
+	if strings.ToLower(args[1]) == "all" {
+		count, err := h.userService.RevokeAllDeviceSessions(h.user.ID, h.deviceSessionID)
+		if err != nil {
+			return &CommandResult{Error: err}
+		}
+		return &CommandResult{Output: ui.SuccessStyle.Render(fmt.Sprintf("Signed out %d other device(s)", count)) + "\n"}
+	}
+
+	// Accept the short ID shown in the list
+	sessions, err := h.userService.ListDeviceSessions(h.user.ID)
+	if err != nil {
+		return &CommandResult{Error: fmt.Errorf("failed to list sessions: %w", err)}
+	}
+	var matches []uuid.UUID
+	for _, session := range sessions {
+		if strings.HasPrefix(session.ID.String(), strings.ToLower(args[1])) {
+			matches = append(matches, session.ID)
+		}
+	}
+	switch {
+	case len(matches) == 0:
+		return &CommandResult{Error: services.ErrSessionNotFound}
+	case len(matches) > 1:
+		return &CommandResult{Error: fmt.Errorf("session id '%s' is ambiguous", args[1])}
+	case matches[0] == h.deviceSessionID:
+		return &CommandResult{Error: fmt.Errorf("that is this device - use 'logout' instead")}
+	}
+
+	if err := h.userService.RevokeDeviceSession(h.user.ID, matches[0]); err != nil {
+		return &CommandResult{Error: err}
+	}
+	return &CommandResult{Output: ui.SuccessStyle.Render("Device signed out") + "\n"}
+}
+
+// listSessions prints the user's logged-in devices
+func (h *CommandHandler) listSessions() *CommandResult {
+	sessions, err := h.userService.ListDeviceSessions(h.user.ID)
+	if err != nil {
+		return &CommandResult{Error: fmt.Errorf("failed to list sessions: %w", err)}
+	}
+	if len(sessions) == 0 {
+		return &CommandResult{Output: ui.InfoStyle.Render("No saved browser sessions. SSH logins are not kept between connections.") + "\n"}
+	}
+
+	var output strings.Builder
+	output.WriteString(ui.AccentBoldStyle.Render("Logged-in devices:") + "\n")
+	for _, session := range sessions {
+		marker := ""
+		if session.ID == h.deviceSessionID {
+			marker = ui.SuccessStyle.Render(" (this device)")
+		}
+		output.WriteString(fmt.Sprintf("  %s  %-28s %-15s last used %s%s\n",
+			session.ID.String()[:8], session.Device, session.SourceIP,
+			session.LastUsedAt.Format(time.DateTime), marker))
+	}
+	output.WriteString(ui.InfoStyle.Render("Use 'sessions revoke <id>' or 'sessions revoke all' to sign devices out.") + "\n")
+	return &CommandResult{Output: output.String()}
+}
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"terminal-sh/models"
+	"terminal-sh/services"
+	"terminal-sh/ui"
+)
+
+// achievementBarWidth is the width of the progress bars in the achievements list
+const achievementBarWidth = 20
+
+// handleACHIEVEMENTS lists every achievement with the user's progress towards it. Secret
+// achievements stay hidden until unlocked.
+func (h *CommandHandler) handleACHIEVEMENTS(args []string) *CommandResult {
+	if h.user == nil {
+		return &CommandResult{Error: fmt.Errorf("not authenticated")}
+	}
+	if h.achievementService == nil {
+		return &CommandResult{Error: fmt.Errorf("achievement service not available")}
+	}
+	if len(args) > 0 {
+		return &CommandResult{Error: fmt.Errorf("usage: achievements")}
+	}
+
+	// Wallet thresholds can be crossed without any tracked action, so check everything first
+	h.achievementService.Evaluate(h.user.ID, "")
+
+	progress := h.achievementService.GetProgress(h.user.ID)
+	unlocked := 0
+	for _, p := range progress {
+		if p.Unlocked {
+			unlocked++
+		}
+	}
+
+	var output strings.Builder
+	output.WriteString(ui.FormatSectionHeader(fmt.Sprintf("Achievements (%d/%d unlocked)", unlocked, len(progress)), "🏆"))
+	for _, p := range progress {
+		def := p.Definition
+		icon := def.Icon
+		if icon == "" {
+			icon = "🏆"
+		}
+		switch {
+		case p.Unlocked:
+			output.WriteString(fmt.Sprintf("  %s %s %s\n", ui.SuccessStyle.Render("✓"), icon, ui.SuccessStyle.Render(def.Name)))
+			output.WriteString("      " + ui.DimStyle.Render(def.Description+" - unlocked "+p.UnlockedAt.Format("2006-01-02")) + "\n")
+		case def.Hidden:
+			output.WriteString(fmt.Sprintf("  %s %s\n", ui.DimStyle.Render("🔒"), ui.DimStyle.Render("??? Secret achievement")))
+		default:
+			output.WriteString(fmt.Sprintf("  %s %s %s\n", ui.DimStyle.Render("🔒"), icon, ui.ValueStyle.Render(def.Name)))
+			output.WriteString("      " + ui.DimStyle.Render(def.Description) + "\n")
+			if def.Criteria == nil {
+				output.WriteString("      " + ui.DimStyle.Render("Earned by completing missions") + "\n")
+			} else {
+				output.WriteString("      " + formatAchievementProgress(p) + "\n")
+			}
+		}
+	}
+
+	return &CommandResult{Output: output.String()}
+}
+
+// formatAchievementProgress renders a progress bar with the current and target values
+func formatAchievementProgress(p services.AchievementProgress) string {
+	filled := int(p.Fraction() * achievementBarWidth)
+	bar := strings.Repeat("█", filled) + strings.Repeat("░", achievementBarWidth-filled)
+	current, target := fmt.Sprintf("%.0f", p.Current), fmt.Sprintf("%.0f", p.Target)
+	switch {
+	case p.Definition.Criteria.Type == models.CriteriaActionSum && p.Definition.Criteria.Action == models.ActionDataExtract:
+		current, target = formatByteCount(p.Current), formatByteCount(p.Target)
+	case p.Definition.Criteria.Type == models.CriteriaStreak:
+		target += " days"
+	}
+	return ui.AccentStyle.Render("["+bar+"]") + " " + ui.ValueStyle.Render(current+"/"+target)
+}
+
+// formatByteCount formats a number of bytes as B, KB, MB or GB
+func formatByteCount(bytes float64) string {
+	units := []string{"B", "KB", "MB", "GB"}
+	unit := 0
+	for bytes >= 1024 && unit < len(units)-1 {
+		bytes /= 1024
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%.0f %s", bytes, units[unit])
+	}
+	return fmt.Sprintf("%.1f %s", bytes, units[unit])
+}
@@ -0,0 +1,63 @@
+// Command admin grants and revokes admin rights on player accounts. It talks to the database
+// directly, so only operators with access to the server's configuration can run it.
+//
+//	go run ./cmd/admin grant <username>
+//	go run ./cmd/admin revoke <username>
+//	go run ./cmd/admin list
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"terminal-sh/config"
+	"terminal-sh/database"
+	"terminal-sh/services"
+	"terminal-sh/ui"
+)
+
+const usage = "usage: admin grant <username> | admin revoke <username> | admin list"
+
+func main() {
+	if len(os.Args) < 2 {
+		log.Fatal(usage)
+	}
+	cfg := config.Load()
+
+	db, err := database.NewDB(cfg.DatabasePath, cfg.DatabaseURL)
+	if err != nil {
+		log.Fatalf(ui.ErrorStyle.Render("Failed to initialize database: %v"), err)
+	}
+	defer db.Close()
+	userService := services.NewUserService(db, cfg.JWTSecret)
+
+	switch command := os.Args[1]; command {
+	case "grant", "revoke":
+		if len(os.Args) != 3 {
+			log.Fatal(usage)
+		}
+		user, err := userService.SetAdmin(os.Args[2], command == "grant")
+		if err != nil {
+			log.Fatal(ui.ErrorStyle.Render(err.Error()))
+		}
+		if user.IsAdmin {
+			fmt.Println(ui.SuccessStyle.Render(fmt.Sprintf("✓ %s (%s) is now an admin", user.Username, user.ID)))
+		} else {
+			fmt.Println(ui.SuccessStyle.Render(fmt.Sprintf("✓ %s (%s) is no longer an admin", user.Username, user.ID)))
+		}
+	case "list":
+		admins, err := userService.ListAdmins()
+		if err != nil {
+			log.Fatalf(ui.ErrorStyle.Render("Failed to list admins: %v"), err)
+		}
+		if len(admins) == 0 {
+			fmt.Println("No admins.")
+		}
+		for _, admin := range admins {
+			fmt.Printf("%s\t%s\n", admin.Username, admin.ID)
+		}
+	default:
+		log.Fatal(usage)
+	}
+}
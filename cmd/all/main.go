@@ -12,11 +12,15 @@ import (
 	"terminal-sh/config"
 	"terminal-sh/database"
 	"terminal-sh/services"
+	"terminal-sh/terminal"
 	"terminal-sh/terminal/ssh"
 	"terminal-sh/terminal/websocket"
 	"terminal-sh/ui"
 )
 
+// drainTimeout bounds how long shutdown waits for sessions and in-flight operations.
+const drainTimeout = 10 * time.Second
+
 func main() {
 	cfg := config.Load()
 
@@ -78,6 +82,12 @@ func main() {
 	fmt.Println(ui.InfoStyle.Render(readyBox))
 	fmt.Println()
 
+	// Start the background jobs (sysadmin patrols, mining payouts, live events). Deferred after the
+	// database close, so they stop first
+	scheduler := services.NewWorldScheduler(db, chatService)
+	scheduler.Start()
+	defer scheduler.Stop()
+
 	// Set up signal handling for graceful shutdown
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
@@ -105,6 +115,16 @@ func main() {
 		fmt.Println(ui.InfoStyle.Render(fmt.Sprintf("Received signal: %v", sig)))
 		fmt.Println(ui.InfoStyle.Render("Shutting down gracefully..."))
 
+		// Notify SSH and web sessions, let in-flight operations finish and flush filesystems
+		drainCtx, cancelDrain := context.WithTimeout(context.Background(), drainTimeout)
+		persisted, err := terminal.Shutdown(drainCtx, "server restarting")
+		cancelDrain()
+		if err != nil {
+			log.Printf(ui.ErrorStyle.Render("Error draining sessions: %v"), err)
+		} else {
+			fmt.Println(ui.SuccessStyle.Render(fmt.Sprintf("✓ Sessions drained (%d operations saved for resumption)", persisted)))
+		}
+
 		// Give the servers a moment to finish current operations
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
@@ -116,9 +136,12 @@ func main() {
 			fmt.Println(ui.SuccessStyle.Render("✓ SSH server shut down gracefully"))
 		}
 
-		// Web server shutdown (HTTP server doesn't have explicit shutdown in current implementation)
-		// It will stop when the process exits
-		fmt.Println(ui.SuccessStyle.Render("✓ Web server shut down gracefully"))
+		// Shutdown web server
+		if err := websocket.ShutdownHTTPServer(ctx); err != nil {
+			log.Printf(ui.ErrorStyle.Render("Error shutting down web server: %v"), err)
+		} else {
+			fmt.Println(ui.SuccessStyle.Render("✓ Web server shut down gracefully"))
+		}
 
 	case err := <-sshErr:
 		log.Fatalf("SSH server error: %v", err)
@@ -0,0 +1,271 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"terminal-sh/models"
+	"terminal-sh/services"
+	"terminal-sh/ui"
+)
+
+// handleBOUNTY handles the bounty board, where players and the game post contracts
+func (h *CommandHandler) handleBOUNTY(args []string) *CommandResult {
+	if h.user == nil {
+		return &CommandResult{Error: fmt.Errorf("not authenticated")}
+	}
+	if h.bountyService == nil {
+		return &CommandResult{Error: fmt.Errorf("bounty service not available")}
+	}
+
+	usage := fmt.Errorf("usage: bounty - Show the bounty board\n" +
+		"       bounty post <root|wipe> <targetIP> <reward> [hours] - Post a bounty\n" +
+		"       bounty post creds <targetIP> <account> <reward> [hours] - Post a bounty for an account's password\n" +
+		"       bounty accept|abandon|claim <id> - Work a bounty as a hunter\n" +
+		"       bounty confirm|cancel <id> - Pay out a claim early, or withdraw a bounty nobody took\n" +
+		"       bounty dispute <id> <reason> - Dispute a claim on your bounty\n" +
+		"       bounty rep [user] - Show bounty reputation")
+	if len(args) == 0 {
+		return h.handleBountyBoard()
+	}
+
+	var bounty *models.Bounty
+	var err error
+	var output string
+	switch {
+	case args[0] == "post" && len(args) >= 4:
+		return h.postBounty(args[1:])
+	case args[0] == "accept" && len(args) == 2:
+		bounty, err = h.bountyService.Accept(h.user.ID, args[1])
+		if err == nil {
+			output = fmt.Sprintf("✅ Accepted %s. Run 'bounty claim %s' once it's done, before %s.",
+				formatBountyJob(bounty), shortID(bounty.ID), bounty.ExpiresAt.Format("2006-01-02 15:04"))
+		}
+	case args[0] == "abandon" && len(args) == 2:
+		bounty, err = h.bountyService.Abandon(h.user.ID, args[1])
+		if err == nil {
+			output = fmt.Sprintf("Abandoned %s. It's back on the board.", formatBountyJob(bounty))
+		}
+	case args[0] == "claim" && len(args) == 2:
+		bounty, err = h.bountyService.Claim(h.user.ID, args[1])
+		if err == nil && bounty.Status == models.BountyCompleted {
+			h.user.Wallet.Crypto += bounty.Reward
+			output = fmt.Sprintf("✅ Verified: %s. %.2f crypto paid.", formatBountyJob(bounty), bounty.Reward)
+		} else if err == nil {
+			output = fmt.Sprintf("✅ Verified: %s. %.2f crypto is paid out in %s unless %s disputes it.",
+				formatBountyJob(bounty), bounty.Reward, services.BountyDisputeWindow, h.usernameOf(*bounty.PosterID))
+		}
+	case args[0] == "confirm" && len(args) == 2:
+		bounty, err = h.bountyService.Confirm(h.user.ID, args[1])
+		if err == nil {
+			output = fmt.Sprintf("✅ Paid %.2f crypto to %s for %s.", bounty.Reward, h.usernameOf(*bounty.HunterID), formatBountyJob(bounty))
+		}
+	case args[0] == "cancel" && len(args) == 2:
+		bounty, err = h.bountyService.Cancel(h.user.ID, args[1])
+		if err == nil {
+			h.user.Wallet.Crypto += bounty.Reward
+			output = fmt.Sprintf("Cancelled %s. %.2f crypto refunded.", formatBountyJob(bounty), bounty.Reward)
+		}
+	case args[0] == "dispute" && len(args) >= 3:
+		bounty, err = h.bountyService.Dispute(h.user.ID, args[1], strings.Join(args[2:], " "))
+		if err == nil {
+			output = fmt.Sprintf("Disputed the claim on %s. The reward is held until an admin resolves it.", formatBountyJob(bounty))
+		}
+	case args[0] == "rep" && len(args) <= 2:
+		return h.handleBountyRep(args[1:])
+	case args[0] == "disputes" && len(args) == 1:
+		return h.handleBountyDisputes()
+	case args[0] == "resolve" && len(args) == 3:
+		return h.resolveBounty(args[1], args[2])
+	default:
+		return &CommandResult{Error: usage}
+	}
+	if err != nil {
+		if errors.Is(err, services.ErrBountyNotDone) {
+			err = fmt.Errorf("%w (root needs root access, creds a password for the account, wipe log_cleaner run after accepting)", err)
+		}
+		return &CommandResult{Error: fmt.Errorf("bounty: %w", err)}
+	}
+	return &CommandResult{Output: ui.SuccessStyle.Render(output) + "\n"}
+}
+
+// handleBountyBoard shows the bounties up for grabs and the user's own
+func (h *CommandHandler) handleBountyBoard() *CommandResult {
+	board, err := h.bountyService.GetBoard()
+	if err != nil {
+		return &CommandResult{Error: err}
+	}
+	mine, err := h.bountyService.GetUserBounties(h.user.ID)
+	if err != nil {
+		return &CommandResult{Error: err}
+	}
+
+	var output strings.Builder
+	output.WriteString(ui.FormatSectionHeader("Bounty Board:", "🎯"))
+	if len(board) == 0 {
+		output.WriteString(ui.DimStyle.Render("  No bounties posted.") + "\n")
+	}
+	for _, bounty := range board {
+		h.writeBounty(&output, &bounty)
+	}
+
+	// Bounties still on the board are listed above, so only claims waiting to pay out are left
+	header := false
+	for _, bounty := range mine {
+		if bounty.Status != models.BountyClaimed && bounty.Status != models.BountyDisputed {
+			continue
+		}
+		if !header {
+			output.WriteString("\n" + ui.FormatSectionHeader("Pending Claims:", "📌"))
+			header = true
+		}
+		h.writeBounty(&output, &bounty)
+	}
+	output.WriteString(ui.FormatUsage("Usage: bounty post <root|creds|wipe> ... | bounty accept <id> | bounty claim <id> | bounty rep"))
+	return &CommandResult{Output: output.String()}
+}
+
+// writeBounty writes one bounty of the board
+func (h *CommandHandler) writeBounty(output *strings.Builder, bounty *models.Bounty) {
+	output.WriteString(ui.ListStyle.Render(fmt.Sprintf("  [%s] ", shortID(bounty.ID))) + ui.AccentStyle.Render(formatBountyJob(bounty)) +
+		" " + ui.PriceStyle.Render(fmt.Sprintf("%.2f crypto", bounty.Reward)) + "\n")
+
+	poster := "posted by the syndicate"
+	if bounty.PosterID != nil {
+		rep := h.bountyService.GetReputation(*bounty.PosterID)
+		poster = fmt.Sprintf("posted by %s (rep %d)", h.usernameOf(*bounty.PosterID), rep.PosterScore())
+	}
+	status := "expires " + bounty.ExpiresAt.Format("2006-01-02 15:04")
+	switch bounty.Status {
+	case models.BountyAccepted:
+		status = "taken by " + h.usernameOf(*bounty.HunterID) + " · " + status
+	case models.BountyClaimed:
+		status = fmt.Sprintf("claimed by %s · pays out %s", h.usernameOf(*bounty.HunterID),
+			bounty.ClaimedAt.Add(services.BountyDisputeWindow).Format("2006-01-02 15:04"))
+	case models.BountyDisputed:
+		status = "disputed: " + bounty.Dispute
+	}
+	output.WriteString("      " + ui.DimStyle.Render(poster+" · "+status) + "\n")
+}
+
+// postBounty posts a bounty paid from the user's wallet
+func (h *CommandHandler) postBounty(args []string) *CommandResult {
+	kind := models.BountyKind(args[0])
+	target := args[1]
+	rest := args[2:]
+	account := ""
+	if kind == models.BountyCreds {
+		if len(rest) < 2 {
+			return &CommandResult{Error: fmt.Errorf("usage: bounty post creds <targetIP> <account> <reward> [hours]")}
+		}
+		account, rest = rest[0], rest[1:]
+	}
+	if len(rest) < 1 || len(rest) > 2 {
+		return &CommandResult{Error: fmt.Errorf("usage: bounty post <root|wipe> <targetIP> <reward> [hours]")}
+	}
+	reward, err := strconv.ParseFloat(rest[0], 64)
+	if err != nil {
+		return &CommandResult{Error: fmt.Errorf("bounty: invalid reward: %s", rest[0])}
+	}
+	ttl := services.DefaultBountyExpiry
+	if len(rest) == 2 {
+		hours, err := strconv.Atoi(rest[1])
+		if err != nil || hours <= 0 || hours > 168 {
+			return &CommandResult{Error: fmt.Errorf("bounty: expiry must be 1-168 hours")}
+		}
+		ttl = time.Duration(hours) * time.Hour
+	}
+
+	bounty, err := h.bountyService.Post(h.user.ID, kind, target, account, reward, ttl)
+	if err != nil {
+		return &CommandResult{Error: fmt.Errorf("bounty: %w", err)}
+	}
+	h.user.Wallet.Crypto -= reward
+
+	var output strings.Builder
+	output.WriteString(ui.SuccessStyle.Render(fmt.Sprintf("✅ Posted a bounty: %s for %.2f crypto", formatBountyJob(bounty), reward)) + "\n")
+	output.WriteString(ui.FormatKeyValuePair("Bounty ID", shortID(bounty.ID)) + "\n")
+	output.WriteString(ui.FormatKeyValuePair("Expires", bounty.ExpiresAt.Format("2006-01-02 15:04")) + "\n")
+	output.WriteString(ui.DimStyle.Render("The reward is held in escrow until a hunter's claim is paid, or the bounty is cancelled or expires.") + "\n")
+	return &CommandResult{Output: output.String()}
+}
+
+// handleBountyRep shows the bounty reputation of the user or another player
+func (h *CommandHandler) handleBountyRep(args []string) *CommandResult {
+	user := h.user
+	if len(args) == 1 {
+		other, err := h.userService.GetUserByUsername(args[0])
+		if err != nil {
+			return &CommandResult{Error: fmt.Errorf("bounty: %s: no such user", args[0])}
+		}
+		user = other
+	}
+	rep := h.bountyService.GetReputation(user.ID)
+
+	var output strings.Builder
+	output.WriteString(ui.FormatSectionHeader(fmt.Sprintf("Bounty Reputation of %s:", user.Username), "🏅"))
+	output.WriteString(ui.FormatKeyValuePair("  Hunter", fmt.Sprintf("%d (%d completed, %d failed)", rep.HunterScore(), rep.Completed, rep.Failed)) + "\n")
+	output.WriteString(ui.FormatKeyValuePair("  Poster", fmt.Sprintf("%d (%d paid out, %d disputes lost)", rep.PosterScore(), rep.Paid, rep.DisputesLost)) + "\n")
+	return &CommandResult{Output: output.String()}
+}
+
+// handleBountyDisputes lists the disputes waiting for an admin. Admin only.
+func (h *CommandHandler) handleBountyDisputes() *CommandResult {
+	if !h.userService.IsAdmin(h.user) {
+		return &CommandResult{Error: fmt.Errorf("bounty disputes: permission denied")}
+	}
+	disputes, err := h.bountyService.GetDisputes()
+	if err != nil {
+		return &CommandResult{Error: err}
+	}
+	var output strings.Builder
+	output.WriteString(ui.FormatSectionHeader("Disputed Bounties:", "⚖️"))
+	if len(disputes) == 0 {
+		output.WriteString(ui.DimStyle.Render("  No open disputes.") + "\n")
+	}
+	for _, bounty := range disputes {
+		h.writeBounty(&output, &bounty)
+		verified := "no longer verifies"
+		if h.bountyService.Verify(&bounty) {
+			verified = "still verifies"
+		}
+		output.WriteString("      " + ui.DimStyle.Render("hunter "+h.usernameOf(*bounty.HunterID)+" · claim "+verified) + "\n")
+	}
+	output.WriteString(ui.FormatUsage("Usage: bounty resolve <id> pay|refund"))
+	return &CommandResult{Output: output.String()}
+}
+
+// resolveBounty settles a dispute in favour of the hunter or the poster. Admin only.
+func (h *CommandHandler) resolveBounty(bountyID, outcome string) *CommandResult {
+	if !h.userService.IsAdmin(h.user) {
+		return &CommandResult{Error: fmt.Errorf("bounty resolve: permission denied")}
+	}
+	if outcome != "pay" && outcome != "refund" {
+		return &CommandResult{Error: fmt.Errorf("usage: bounty resolve <id> pay|refund")}
+	}
+	bounty, err := h.bountyService.Resolve(bountyID, outcome == "pay")
+	if err != nil {
+		return &CommandResult{Error: fmt.Errorf("bounty: %w", err)}
+	}
+	if outcome == "pay" {
+		return &CommandResult{Output: ui.SuccessStyle.Render(fmt.Sprintf("✅ Paid %.2f crypto to %s for %s",
+			bounty.Reward, h.usernameOf(*bounty.HunterID), formatBountyJob(bounty))) + "\n"}
+	}
+	return &CommandResult{Output: ui.SuccessStyle.Render(fmt.Sprintf("✅ Refunded %.2f crypto to %s for %s",
+		bounty.Reward, h.usernameOf(*bounty.PosterID), formatBountyJob(bounty))) + "\n"}
+}
+
+// formatBountyJob describes what a bounty asks for
+func formatBountyJob(bounty *models.Bounty) string {
+	switch bounty.Kind {
+	case models.BountyCreds:
+		return fmt.Sprintf("creds for %s on %s", bounty.Account, bounty.Target)
+	case models.BountyWipe:
+		return "wipe logs on " + bounty.Target
+	default:
+		return "root " + bounty.Target
+	}
+}
@@ -2,14 +2,17 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"math/rand"
 	"net"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"terminal-sh/database"
 	"terminal-sh/filesystem"
+	"terminal-sh/metrics"
 	"terminal-sh/models"
 	"terminal-sh/services"
 	"terminal-sh/ui"
@@ -31,6 +34,10 @@ type CommandResult struct {
 	StartASCIIAnimation *ASCIIAnimationRequest
 	// Progress operation (for long-running operations)
 	StartProgress *ProgressOperationRequest
+	// Background job to bring to the foreground (for fg)
+	Foreground *Job
+	// Secret input to read before the command can finish (for passwd)
+	PromptSecret *SecretPromptRequest
 }
 
 // ProgressOperationRequest contains parameters for starting a progress operation
@@ -39,6 +46,9 @@ type ProgressOperationRequest struct {
 	Message   string                                     // Message to display (e.g., "Downloading...")
 	Duration  float64                                    // Duration in seconds
 	Operation func() *CommandResult                      // The actual operation to run
+	Command     string // Command line that started it, as jobs lists it
+	Background  bool   // Run as a background job (the command line ended with &)
+	Interactive bool   // Takes over the shell when it finishes (connections), so it can't run in the background
 }
 
 // ASCIIAnimationRequest contains parameters for starting an ASCII animation
@@ -50,6 +60,13 @@ type ASCIIAnimationRequest struct {
 	SizeScale  int // Size as integer multiplier (1-10), 0 means use CharWidth/CharHeight directly
 }
 
+// SecretPromptRequest asks the shell to read values such as passwords one prompt at a time,
+// without echoing them or keeping them in the input history, then finish the command with them
+type SecretPromptRequest struct {
+	Prompts []string                            // Shown in place of the shell prompt, one per value
+	Submit  func(values []string) *CommandResult // Called with one value per prompt
+}
+
 // CommandHandler handles execution of terminal commands and manages game state.
 type CommandHandler struct {
 	db              *database.Database
@@ -77,12 +94,27 @@ type CommandHandler struct {
 	credentialService   *services.CredentialService
 	roleService         *services.RoleService
 	actionTracker       *services.ActionTracker
+	honeypotService     *services.HoneypotService
+	ledgerService       *services.LedgerService
+	tradeService        *services.TradeService
+	marketService       *services.MarketService
+	bountyService       *services.BountyService
+	sysAdminService     *services.SysAdminService
+	eventService        *services.EventService
+	notificationService *services.NotificationService
+	processes           *ProcessTable   // The session's jobs
+	mu                  sync.Mutex      // Held while a command or a job's operation runs: they share the handler's state
 	homeVFS             *filesystem.VFS // User's home filesystem (never changes; used for downloads)
 	currentServerPath   string     // Current server path if connected to a server
+	connectedAt         time.Time  // When the current server path was entered
 	currentServiceType  string     // Service type used for current connection (ssh, ftp, telnet, etc.)
 	currentAccessMethod string     // How we accessed current server (credentials, backdoor)
 	currentRole         *services.ConnectionRole // Current role/user on the connected server
 	sessionID           *uuid.UUID // Current session ID
+	deviceSessionID     uuid.UUID  // Device session the user logged in with (web only)
+	connectionChain     func() []ConnectedHost       // Machines from home to the current server, for scp
+	tunnels             []string                      // Server paths of the pivot tunnels opened this session
+	graph               *services.NetworkGraph        // Network topology, loaded at most once per command
 	onConnect           func(serverPath string) error // Callback for server connection
 	onDisconnect        func() error                  // Callback for server disconnection
 	// Deprecated: use onConnect instead
@@ -136,6 +168,18 @@ func NewCommandHandler(db *database.Database, vfs *filesystem.VFS, user *models.
 	actionTracker := services.NewActionTracker(db)
 	actionTracker.SetMissionService(missionService)
 	missionService.SetActionTracker(actionTracker)
+	if achievementService != nil {
+		actionTracker.SetAchievementService(achievementService)
+		achievementService.SetActionTracker(actionTracker)
+	}
+
+	// Initialize honeypot service to trap players who exploit honeypot servers
+	honeypotService := services.NewHoneypotService(db, serverLogService)
+
+	// Initialize event service so live events boost exploits and add their missions
+	eventService := services.NewEventService(db, chatService)
+	exploitationService.SetEventService(eventService)
+	missionService.SetEventService(eventService)
 
 	return &CommandHandler{
 		db:              db,
@@ -164,14 +208,41 @@ func NewCommandHandler(db *database.Database, vfs *filesystem.VFS, user *models.
 		credentialService: credentialService,
 		roleService: roleService,
 		actionTracker: actionTracker,
+		honeypotService: honeypotService,
+		ledgerService: services.NewLedgerService(db),
+		tradeService: services.NewTradeService(db),
+		marketService: services.NewMarketService(db),
+		bountyService: services.NewBountyService(db, roleService, credentialService, actionTracker),
+		sysAdminService: services.NewSysAdminService(db, miningService),
+		eventService:    eventService,
+		notificationService: services.NewNotificationService(db),
+		processes:       NewProcessTable(),
 	}
 }
 
+// RunOperation runs the operation of a job, or anything else that runs outside Execute, once
+// no command or other operation is running.
+func (h *CommandHandler) RunOperation(operation func() *CommandResult) *CommandResult {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return operation()
+}
+
+// Processes returns the session's process table.
+func (h *CommandHandler) Processes() *ProcessTable {
+	return h.processes
+}
+
 // SetSessionID sets the current session ID for this command handler.
 func (h *CommandHandler) SetSessionID(sessionID uuid.UUID) {
 	h.sessionID = &sessionID
 }
 
+// SetDeviceSessionID sets the device session the user logged in with, so 'sessions' can mark it.
+func (h *CommandHandler) SetDeviceSessionID(sessionID uuid.UUID) {
+	h.deviceSessionID = sessionID
+}
+
 // SetConnectionCallbacks sets callbacks for server connect and disconnect events.
 func (h *CommandHandler) SetConnectionCallbacks(onConnect func(serverPath string) error, onDisconnect func() error) {
 	h.onConnect = onConnect
@@ -204,6 +275,9 @@ func (h *CommandHandler) GetCurrentServerPath() string {
 
 // SetCurrentServerPath sets the current server path (used for restoring from session stack).
 func (h *CommandHandler) SetCurrentServerPath(path string) {
+	if path != h.currentServerPath {
+		h.connectedAt = time.Now()
+	}
 	h.currentServerPath = path
 }
 
@@ -287,6 +361,27 @@ func (h *CommandHandler) trackCredentialCrack(toolName, serverPath, serviceName
 	}
 }
 
+// trackBackdoorInstall records a backdoor being installed for mission validation
+func (h *CommandHandler) trackBackdoorInstall(toolName, serverPath string) {
+	if h.actionTracker != nil && h.user != nil {
+		h.actionTracker.TrackBackdoorInstall(h.user.ID, toolName, serverPath)
+	}
+}
+
+// trackPrivilegeEscalation records a privilege escalation for mission validation
+func (h *CommandHandler) trackPrivilegeEscalation(toolName, serverPath, fromRole, toRole string) {
+	if h.actionTracker != nil && h.user != nil {
+		h.actionTracker.TrackPrivilegeEscalation(h.user.ID, toolName, serverPath, fromRole, toRole)
+	}
+}
+
+// trackDataExtraction records bytes of data being taken from a server
+func (h *CommandHandler) trackDataExtraction(toolName, serverPath string, bytes int) {
+	if h.actionTracker != nil && h.user != nil {
+		h.actionTracker.TrackDataExtraction(h.user.ID, toolName, serverPath, int64(bytes))
+	}
+}
+
 func (h *CommandHandler) GetEffectiveSourceIP() string {
 	if h.currentServerPath == "" {
 		// On local machine - use user's IP
@@ -307,7 +402,20 @@ func (h *CommandHandler) SetVFS(vfs *filesystem.VFS) {
 	h.vfs = vfs
 }
 
+// SwitchContext moves the handler to another machine: its filesystem, server path and the
+// service it's connected with. It waits for any running command or job operation first.
+func (h *CommandHandler) SwitchContext(vfs *filesystem.VFS, serverPath, serviceType string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.SetVFS(vfs)
+	h.SetCurrentServerPath(serverPath)
+	h.SetCurrentServiceType(serviceType)
+}
+
 // CreateServerVFS creates a VFS for a server by loading its filesystem from the database.
+// The server's stored filesystem is an immutable base image; the current user's changes are
+// layered on top and saved to their own overlay. Servers in shared world mode instead write
+// changes back to the base, so every player sees them.
 // Returns the created VFS or an error if the server is not found.
 func (h *CommandHandler) CreateServerVFS(serverPath string) (*filesystem.VFS, error) {
 	// Get server by path
@@ -322,24 +430,59 @@ func (h *CommandHandler) CreateServerVFS(serverPath string) (*filesystem.VFS, er
 		// Fall back to standard VFS if merge fails
 		vfs = filesystem.NewVFS("root")
 	}
-	
-	// Set up save callback for server filesystem
 	vfs.SetServerID(serverPath)
+	addServerPseudoFiles(vfs, server)
+	addServiceFiles(vfs, server)
+	
+	if server.SharedWorld || h.user == nil {
+		vfs.SetSaveCallback(func(changes map[string]interface{}) error {
+			// Update server's filesystem in database
+			server.FileSystem = changes
+			return h.db.Model(&server).Select("file_system").Updates(&server).Error
+		})
+		return vfs, nil
+	}
+	
+	// Diff against the base as extracted by a VFS, so standard paths compare the same way
+	base := vfs.ExtractChanges()
+	overlay, err := h.serverService.GetServerOverlay(h.user.ID, server.ID)
+	if err != nil {
+		return nil, err
+	}
+	vfs.ApplyOverlay(overlay)
+	
+	userID := h.user.ID
 	vfs.SetSaveCallback(func(changes map[string]interface{}) error {
-		// Update server's filesystem in database
-		server.FileSystem = changes
-		return h.db.Model(&server).Update("file_system", changes).Error
+		return h.serverService.SaveServerOverlay(userID, server.ID, filesystem.DiffOverlay(base, changes))
 	})
 	
 	return vfs, nil
 }
 
+// GetRoleGroups returns the groups of a role on a server, for file permission checks.
+// Returns nil if the server or role is unknown.
+func (h *CommandHandler) GetRoleGroups(serverPath, username string) []string {
+	server, err := h.serverService.GetServerByPath(serverPath)
+	if err != nil {
+		return nil
+	}
+	if role := server.GetRoleByUsername(username); role != nil {
+		return role.GetGroups()
+	}
+	return nil
+}
+
 // SyncUserToolsToVFS syncs user's owned tools to /usr/bin in the VFS
 func (h *CommandHandler) SyncUserToolsToVFS() error {
 	if h.user == nil {
 		return nil // No user, nothing to sync
 	}
 	
+	return h.syncUserTools(h.vfs)
+}
+
+// syncUserTools adds the user's tools that are missing from vfs as commands.
+func (h *CommandHandler) syncUserTools(vfs *filesystem.VFS) error {
 	tools, err := h.toolService.GetUserTools(h.user.ID)
 	if err != nil {
 		return err
@@ -347,10 +490,10 @@ func (h *CommandHandler) SyncUserToolsToVFS() error {
 	
 	for _, tool := range tools {
 		// Add tool as command in /usr/bin if it doesn't exist
-		_, err := h.vfs.GetCommandDescription(tool.Name)
+		_, err := vfs.GetCommandDescription(tool.Name)
 		if err != nil {
 			// Command doesn't exist, add it
-			if err := h.vfs.AddUserCommand(tool.Name, tool.Function); err != nil {
+			if err := vfs.AddUserCommand(tool.Name, tool.Function); err != nil {
 				// Log error but continue with other tools
 				continue
 			}
@@ -360,16 +503,101 @@ func (h *CommandHandler) SyncUserToolsToVFS() error {
 	return nil
 }
 
+// errUnknownCommand is wrapped by the error returned for commands the shell doesn't recognize.
+var errUnknownCommand = errors.New("unknown command")
+
+// Execute parses and runs a shell command line, recording its latency in metrics.
 func (h *CommandHandler) Execute(command string) *CommandResult {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
 	// Parse command
 	parts := parseCommand(command)
 	if len(parts) == 0 {
 		return &CommandResult{Output: ""}
 	}
 
-	cmd := parts[0]
-	args := parts[1:]
+	// A session the server's admin killed ends before the next command runs
+	if kicked := h.checkKicked(); kicked != nil {
+		return kicked
+	}
 
+	// A trailing & runs the command as a background job
+	parts, background := splitBackground(parts)
+	if len(parts) == 0 {
+		return &CommandResult{Error: fmt.Errorf("syntax error near unexpected token `&'")}
+	}
+
+	start := time.Now()
+	h.graph = nil
+	var result *CommandResult
+	if h.ftpSession() {
+		result = h.dispatchFTP(parts[0], parts[1:])
+	} else {
+		result = h.dispatch(parts[0], parts[1:])
+	}
+	if result != nil && result.StartProgress != nil {
+		result.StartProgress.Command = strings.Join(parts, " ")
+		if background && result.StartProgress.Interactive {
+			return &CommandResult{Error: fmt.Errorf("%s: can't run in the background", parts[0])}
+		}
+		result.StartProgress.Background = background
+	}
+
+	// Collapse unrecognized input into a single label to keep metric cardinality bounded
+	label := parts[0]
+	if result != nil && errors.Is(result.Error, errUnknownCommand) {
+		label = "unknown"
+	}
+	metrics.ObserveCommand(label, time.Since(start))
+
+	// Announce achievements unlocked by the command, once its operation (if any) has run
+	if result != nil && result.StartProgress != nil && result.StartProgress.Operation != nil {
+		operation := result.StartProgress.Operation
+		result.StartProgress.Operation = func() *CommandResult {
+			return h.announceAchievements(operation())
+		}
+	} else {
+		h.announceAchievements(result)
+	}
+
+	return result
+}
+
+// checkKicked returns the result that drops the user off the current server if its admin
+// killed their session since they connected, or nil.
+func (h *CommandHandler) checkKicked() *CommandResult {
+	if h.currentServerPath == "" || h.user == nil || h.sysAdminService == nil {
+		return nil
+	}
+	parts := strings.Split(h.currentServerPath, ".localNetwork.")
+	kick := h.sysAdminService.KickedSince(h.user.ID, parts[len(parts)-1], h.connectedAt)
+	if kick == nil {
+		return nil
+	}
+	message := ui.ErrorStyle.Render("Connection closed by remote host.") + " " +
+		ui.DimStyle.Render(fmt.Sprintf("%s killed your session.", kick.Admin)) + "\n"
+	return &CommandResult{Output: "__KICKED__" + message}
+}
+
+// announceAchievements appends the achievements unlocked since the last announcement to a
+// successful result. A failed result leaves them for the next command.
+func (h *CommandHandler) announceAchievements(result *CommandResult) *CommandResult {
+	if result == nil || result.Error != nil || h.achievementService == nil || h.user == nil {
+		return result
+	}
+	for _, ach := range h.achievementService.TakeUnlocked(h.user.ID) {
+		icon := ach.Icon
+		if icon == "" {
+			icon = "🏆"
+		}
+		result.Output += "\n" + ui.SuccessStyle.Render(icon+" Achievement unlocked: "+ach.Name) + " " + ui.DimStyle.Render(ach.Description) + "\n"
+	}
+	return result
+}
+
+// dispatch routes a parsed command to its handler.
+func (h *CommandHandler) dispatch(cmd string, args []string) *CommandResult {
 	switch cmd {
 	case "pwd":
 		return h.handlePWD()
@@ -397,12 +625,20 @@ func (h *CommandHandler) Execute(command string) *CommandResult {
 		return h.handleREGISTER(args)
 	case "userinfo":
 		return h.handleUSERINFO()
+	case "achievements":
+		return h.handleACHIEVEMENTS(args)
 	case "info":
 		return h.handleINFO()
 	case "whoami":
 		return h.handleWHOAMI()
 	case "name":
 		return h.handleNAME(args)
+	case "passwd":
+		return h.handlePASSWD(args)
+	case "2fa":
+		return h.handle2FA(args)
+	case "sessions":
+		return h.handleSESSIONS(args)
 	case "ifconfig":
 		return h.handleIFCONFIG()
 	case "scan":
@@ -421,6 +657,24 @@ func (h *CommandHandler) Execute(command string) *CommandResult {
 		return h.handleEXIT()
 	case "get":
 		return h.handleGET(args)
+	case "scp":
+		return h.handleSCP(args)
+	case "upload":
+		return h.handleUPLOAD(args)
+	case "nmap":
+		return h.handleNMAP(args)
+	case "curl":
+		return h.handleCURL(args)
+	case "browse":
+		return h.handleBROWSE(args)
+	case "mysql":
+		return h.handleMYSQL(args)
+	case "traceroute":
+		return h.handleTRACEROUTE(args)
+	case "route":
+		return h.handleROUTE(args)
+	case "tunnel":
+		return h.handleTUNNEL(args)
 	case "download", "dl":
 		return h.handleDOWNLOAD(args)
 	case "tools":
@@ -447,8 +701,32 @@ func (h *CommandHandler) Execute(command string) *CommandResult {
 		return h.handleSTOPMINING(args)
 	case "miners":
 		return h.handleMINERS()
+	case "pool":
+		return h.handlePOOL(args)
+	case "ps":
+		return h.handlePS(args)
+	case "jobs":
+		return h.handleJOBS(args)
+	case "fg":
+		return h.handleFG(args)
+	case "kill":
+		return h.handleKILL(args)
 	case "wallet":
 		return h.handleWALLET()
+	case "transactions":
+		return h.handleTRANSACTIONS(args)
+	case "pay":
+		return h.handlePAY(args)
+	case "trade":
+		return h.handleTRADE(args)
+	case "bounty":
+		return h.handleBOUNTY(args)
+	case "event":
+		return h.handleEVENT(args)
+	case "notifications":
+		return h.handleNOTIFICATIONS(args)
+	case "market":
+		return h.handleMARKET(args)
 	case "password_cracker", "password_sniffer", "ssh_exploit", "user_enum", "lan_sniffer", "rootkit", "exploit_kit", "advanced_exploit_kit", "sql_injector", "xss_exploit", "packet_capture", "packet_decoder", "log_cleaner", "timestomper", "database_dumper", "phishing_kit", "audit_disable", "hash_cracker", "log_analyzer", "backup_destroyer":
 		return h.handleToolCommand(cmd, args)
 	case "touch":
@@ -463,8 +741,26 @@ func (h *CommandHandler) Execute(command string) *CommandResult {
 		return h.handleMV(args)
 	case "edit", "vi", "nano":
 		return h.handleEDIT(args)
+	case "chmod":
+		return h.handleCHMOD(args)
+	case "chown":
+		return h.handleCHOWN(args)
+	case "stat":
+		return h.handleSTAT(args)
+	case "ln":
+		return h.handleLN(args)
+	case "readlink":
+		return h.handleREADLINK(args)
+	case "tar":
+		return h.handleTAR(args)
+	case "unzip":
+		return h.handleUNZIP(args)
+	case "gunzip":
+		return h.handleGUNZIP(args)
+	case "decrypt":
+		return h.handleDECRYPT(args)
 	default:
-		return &CommandResult{Error: fmt.Errorf("unknown command: %s. Type 'help' for available commands", cmd)}
+		return &CommandResult{Error: fmt.Errorf("%w: %s. Type 'help' for available commands", errUnknownCommand, cmd)}
 	}
 }
 
@@ -495,6 +791,10 @@ func (h *CommandHandler) handleLS(args []string) *CommandResult {
 		}
 	}
 	
+	if err := h.vfs.CheckReadPermission(h.vfs.GetCurrentPath()); err != nil {
+		return &CommandResult{Error: fmt.Errorf("ls: cannot open directory %w", err)}
+	}
+	
 	nodes := h.vfs.ListDirWithOptions(showAll)
 	
 	if longFormat {
@@ -712,8 +1012,12 @@ func (h *CommandHandler) handleHELP() *CommandResult {
 	// User commands
 	output.WriteString(ui.SuccessStyle.Render(emojiUser + " User:") + "\n")
 	output.WriteString(formatListItem("userinfo            - Show user information", ""))
+	output.WriteString(formatListItem("achievements        - Show achievements and progress", ""))
 	output.WriteString(formatListItem("whoami              - Display current username", ""))
 	output.WriteString(formatListItem("name <newName>      - Change username", ""))
+	output.WriteString(formatListItem("passwd  - Change password", ""))
+	output.WriteString(formatListItem("2fa [enable|confirm|disable] - Manage two-factor authentication", ""))
+	output.WriteString(formatListItem("sessions [revoke <id>|all] - List or sign out logged-in devices", ""))
 	output.WriteString("\n")
 	
 	// Network commands
@@ -726,6 +1030,11 @@ func (h *CommandHandler) handleHELP() *CommandResult {
 	output.WriteString(formatListItem("ftp <targetIP>      - Connect via FTP (requires RCE)", ""))
 	output.WriteString(formatListItem("exit                - Disconnect from server", ""))
 	output.WriteString(formatListItem("server              - Show current server info", ""))
+	output.WriteString(formatListItem("ps [aux]            - List your processes, or every process on the machine", ""))
+	output.WriteString(formatListItem("<command> &         - Run a long command as a background job", ""))
+	output.WriteString(formatListItem("jobs [-l]           - List background jobs", ""))
+	output.WriteString(formatListItem("fg [%job]           - Bring a background job to the foreground", ""))
+	output.WriteString(formatListItem("kill <pid|%job>     - Kill a job, or a process on the server", ""))
 	output.WriteString("\n")
 	
 	// Tools/Game commands
@@ -737,6 +1046,15 @@ func (h *CommandHandler) handleHELP() *CommandResult {
 	output.WriteString(formatListItem("credentials          - List discovered credentials", ""))
 	output.WriteString(formatListItem("backdoors            - List installed backdoors", ""))
 	output.WriteString(formatListItem("wallet               - Show wallet balance", ""))
+	output.WriteString(formatListItem("transactions [count] - Show wallet transaction history", ""))
+	output.WriteString(formatListItem("pay <user> <amount>  - Send crypto to another player", ""))
+	output.WriteString(formatListItem("trade [offer|accept|cancel] - Trade tools, tokens and files through escrow", ""))
+	output.WriteString(formatListItem("market               - Trade on the exchange (connect to exchange first)", ""))
+	output.WriteString(formatListItem("bounty [post|accept|claim] - Post and hunt contracts on the bounty board", ""))
+	output.WriteString(formatListItem("event [top|loot] - Live events, their leaderboards and heist loot", ""))
+	output.WriteString(formatListItem("notifications [all|clear] - Read what happened while you were away", ""))
+	output.WriteString(formatListItem("miners               - Show your miners and the network's difficulty", ""))
+	output.WriteString(formatListItem("pool [create|join|leave] - Share mining payouts in a pool", ""))
 	output.WriteString("\n")
 	
 	// Learning
@@ -1018,6 +1336,10 @@ func (h *CommandHandler) handleUSERINFO() *CommandResult {
 		h.user.Resources.CPU, h.user.Resources.Bandwidth, h.user.Resources.RAM)) + "\n")
 	output.WriteString(ui.FormatKeyValuePair("Wallet:", fmt.Sprintf("Crypto=%.2f, Data=%.2f", 
 		h.user.Wallet.Crypto, h.user.Wallet.Data)) + "\n")
+	if h.user.TraceFlags > 0 {
+		output.WriteString(ui.FormatKeyValuePair("Trace flags:", ui.WarningStyle.Render(fmt.Sprintf("%d", h.user.TraceFlags))+
+			ui.DimStyle.Render(" (honeypots seize more from you)")) + "\n")
+	}
 	
 	// Show achievements if available
 	if h.achievementService != nil {
@@ -1031,7 +1353,11 @@ func (h *CommandHandler) handleUSERINFO() *CommandResult {
 				if achDef != nil && achDef.Icon != "" {
 					icon = achDef.Icon
 				}
-				output.WriteString(ui.FormatListBullet(fmt.Sprintf("%s %s", icon, ach.AchievementName)))
+				name := ach.AchievementName
+				if achDef != nil {
+					name = achDef.Name
+				}
+				output.WriteString(ui.FormatListBullet(fmt.Sprintf("%s %s", icon, name)))
 			}
 		}
 	}
@@ -1181,6 +1507,9 @@ func (h *CommandHandler) handleSCAN(args []string) *CommandResult {
 		if err != nil {
 			return &CommandResult{Error: err}
 		}
+		if err := h.checkRoute("scan", args[0]); err != nil {
+			return &CommandResult{Error: err}
+		}
 		
 		// Log the scan (scans are detected by the target server)
 		// Use effective source IP (the server we're on, or user's IP if local)
@@ -1213,10 +1542,7 @@ func (h *CommandHandler) handleSCAN(args []string) *CommandResult {
 		
 		// Tools: show if user has access (credentials/backdoor) or server needs no auth (e.g. home PC)
 		if len(server.Tools) > 0 {
-			serverPath := server.IP
-			if h.currentServerPath != "" {
-				serverPath = h.currentServerPath + ".localNetwork." + server.IP
-			}
+			serverPath := h.targetServerPath(server.IP)
 			hasAccess := false
 			if h.credentialService != nil {
 				hasAccess, _, _ = h.credentialService.CanAccessServer(h.user.ID, serverPath)
@@ -1278,10 +1604,7 @@ func (h *CommandHandler) handleSCAN(args []string) *CommandResult {
 		}
 		
 		// Calculate server path for access checking
-		scanServerPath := args[0]
-		if h.currentServerPath != "" {
-			scanServerPath = h.currentServerPath + ".localNetwork." + args[0]
-		}
+		scanServerPath := h.targetServerPath(args[0])
 
 		// Show discovered users
 		if h.credentialService != nil && h.user != nil {
@@ -1368,13 +1691,16 @@ func (h *CommandHandler) handleSERVER() *CommandResult {
 	
 	output.WriteString(ui.FormatSectionHeader("Server Information", "🖥️"))
 	
-	output.WriteString(ui.FormatKeyValuePair("Server:", formatIP(server.IP)) + "\n")
-	output.WriteString(ui.FormatKeyValuePair("Local IP:", formatIP(server.LocalIP)) + "\n")
-	output.WriteString(ui.FormatKeyValuePair("Security Level:", fmt.Sprintf("%d", server.SecurityLevel)) + "\n")
-	output.WriteString(ui.FormatKeyValuePair("Resources:", fmt.Sprintf("CPU=%d, Bandwidth=%.1f, RAM=%d",
+	output.WriteString(ui.FormatKeyValuePair("Server", formatIP(server.IP)) + "\n")
+	output.WriteString(ui.FormatKeyValuePair("Local IP", formatIP(server.LocalIP)) + "\n")
+	output.WriteString(ui.FormatKeyValuePair("Security Level", fmt.Sprintf("%d", server.SecurityLevel)) + "\n")
+	output.WriteString(ui.FormatKeyValuePair("Resources", fmt.Sprintf("CPU=%d, Bandwidth=%.1f, RAM=%d",
 		server.Resources.CPU, server.Resources.Bandwidth, server.Resources.RAM)) + "\n")
-	output.WriteString(ui.FormatKeyValuePair("Wallet:", fmt.Sprintf("Crypto=%.2f, Data=%.2f",
+	output.WriteString(ui.FormatKeyValuePair("Wallet", fmt.Sprintf("Crypto=%.2f, Data=%.2f",
 		server.Wallet.Crypto, server.Wallet.Data)) + "\n")
+	if admin := h.sysAdminService.GetAdmin(server.IP); admin != nil {
+		output.WriteString(ui.FormatKeyValuePair("Admin", fmt.Sprintf("%s (alert %.1f)", admin.Name, admin.Alert)) + "\n")
+	}
 
 	return &CommandResult{Output: output.String()}
 }
@@ -1425,11 +1751,9 @@ func (h *CommandHandler) handleConnect(args []string, requiredService string) *C
 	}
 
 	// Build the server path for access check
-	var serverPath string
-	if h.currentServerPath == "" {
-		serverPath = server.IP
-	} else {
-		serverPath = h.currentServerPath + ".localNetwork." + server.IP
+	hops, serverPath, err := h.routeTo(server.IP)
+	if err != nil {
+		return &CommandResult{Error: fmt.Errorf("%s: %s: %w", cmdName, targetIP, err)}
 	}
 
 	// Check access using credential service (credentials or backdoor)
@@ -1458,6 +1782,10 @@ func (h *CommandHandler) handleConnect(args []string, requiredService string) *C
 	} else if requiredService != "" {
 		// User requested a specific service - check if we have access
 		accessInfo := h.credentialService.GetAccessInfo(h.user.ID, serverPath, requiredService)
+		if ftp := services.FindService(server, "ftp"); !accessInfo.HasAccess && requiredService == "ftp" && ftp != nil && ftp.Anonymous {
+			// Anonymous FTP needs no credentials
+			accessInfo = services.AccessInfo{HasAccess: true, AccessMethod: anonymousUser, ServiceName: "ftp", Username: anonymousUser}
+		}
 		if !accessInfo.HasAccess {
 			// Check if the service exists on the server
 			serviceExists := false
@@ -1490,10 +1818,11 @@ func (h *CommandHandler) handleConnect(args []string, requiredService string) *C
 		}
 	}
 
-	// Calculate connection time based on user resources
+	// Calculate connection time based on user resources and the hops on the way
 	var duration float64 = 1.0 // default 1 second
 	if h.progressService != nil {
-		duration = h.progressService.CalculateOperationTime(services.OperationConnect, h.user.Resources)
+		duration = h.progressService.CalculateOperationTime(services.OperationConnect, h.user.Resources) +
+			h.progressService.CalculateRouteDelay(len(hops)-1)
 	}
 
 	// Return a progress operation that will run async
@@ -1510,7 +1839,9 @@ func (h *CommandHandler) handleConnect(args []string, requiredService string) *C
 
 	// Get the full role info for proper permissions
 	var roleInfo *services.ConnectionRole
-	if h.roleService != nil {
+	if accessMethod == anonymousUser {
+		roleInfo = anonymousFTPRole()
+	} else if h.roleService != nil {
 		roleInfo = h.roleService.GetConnectionRole(userID, serverPath, serviceType, server)
 	}
 	// Capture role info for async operation
@@ -1521,6 +1852,8 @@ func (h *CommandHandler) handleConnect(args []string, requiredService string) *C
 	switch accessMethod {
 	case "no_auth":
 		connectMsg = fmt.Sprintf("Connecting to %s via %s (no auth required)...", targetIP, serviceType)
+	case anonymousUser:
+		connectMsg = fmt.Sprintf("Connecting to %s via %s (anonymous login)...", targetIP, serviceType)
 	case "backdoor":
 		if roleInfo != nil && roleInfo.IsRoot {
 			connectMsg = fmt.Sprintf("Connecting to %s via %s (backdoor → root)...", targetIP, serviceType)
@@ -1538,14 +1871,16 @@ func (h *CommandHandler) handleConnect(args []string, requiredService string) *C
 
 	return &CommandResult{
 		StartProgress: &ProgressOperationRequest{
-			ID:       operationID,
-			Message:  connectMsg,
-			Duration: duration,
+			ID:          operationID,
+			Message:     connectMsg,
+			Duration:    duration,
+			Interactive: true,
 			Operation: func() *CommandResult {
-				// Log the connection with service type
+				// Log the connection with service type, and on every hop it passes through
 				if serverLogService != nil {
 					serverLogService.LogConnect(capturedServerIP, sourceIP, username, &userID, capturedServiceType, true)
 				}
+				handler.logRoute(hops, "Forwarded "+capturedServiceType+" connection")
 				
 				// Track server connection for mission objectives
 				if actionTracker != nil {
@@ -1590,20 +1925,17 @@ func (h *CommandHandler) handleEXIT() *CommandResult {
 
 	// Log disconnection with service type
 	if h.serverLogService != nil && h.user != nil {
-		// Extract current server IP from path
-		parts := strings.Split(h.currentServerPath, ".")
+		// Extract current server IP from path (IPs have dots of their own)
+		parts := strings.Split(h.currentServerPath, ".localNetwork.")
 		serverIP := parts[len(parts)-1]
 		
 		// The source IP for disconnect is the previous hop (where we came from)
 		// If path is "A.localNetwork.B", we're on B and came from A
 		// If path is just "A", we came from our local machine (user's IP)
 		var sourceIP string
-		if len(parts) >= 3 {
-			// We have at least one hop: extract the previous server IP
-			// Path format: "ip1.localNetwork.ip2.localNetwork.ip3"
-			// Parts would be: [ip1, localNetwork, ip2, localNetwork, ip3]
-			// Previous hop is parts[len(parts)-3]
-			sourceIP = parts[len(parts)-3]
+		if len(parts) >= 2 {
+			// We have at least one hop: the previous server IP
+			sourceIP = parts[len(parts)-2]
 		} else {
 			// Direct connection from user's machine
 			sourceIP = h.user.IP
@@ -1755,10 +2087,7 @@ func (h *CommandHandler) handleGET(args []string) *CommandResult {
 	alreadyConnected := h.currentServerPath != "" && strings.Contains(h.currentServerPath, targetIP)
 
 	if targetIP != "repo" && !alreadyConnected && h.credentialService != nil {
-		serverPath := targetIP
-		if h.currentServerPath != "" {
-			serverPath = h.currentServerPath + ".localNetwork." + targetIP
-		}
+		serverPath := h.targetServerPath(targetIP)
 		hasAccess, _, _ := h.credentialService.CanAccessServer(h.user.ID, serverPath)
 		if !hasAccess && h.exploitationService != nil {
 			hasAccess = h.exploitationService.CanAccessServer(h.user.ID, serverPath)
@@ -1797,6 +2126,7 @@ func (h *CommandHandler) handleGET(args []string) *CommandResult {
 	actionTracker := h.actionTracker
 	capturedToolName := toolName
 	capturedTargetIP := targetIP
+	vfs := h.vfs
 	
 	return &CommandResult{
 		StartProgress: &ProgressOperationRequest{
@@ -1819,8 +2149,8 @@ func (h *CommandHandler) handleGET(args []string) *CommandResult {
 					missionCompleted = missionService.TryAutoComplete(userID)
 				}
 
-				// Sync tools to VFS so the new tool appears in help
-				handler.SyncUserToolsToVFS()
+				// Sync tools to the VFS the download started on so the new tool appears in help
+				handler.syncUserTools(vfs)
 				
 				output := ui.SuccessStyle.Render("✅ Tool ") + ui.AccentBoldStyle.Render(capturedToolName) + ui.SuccessStyle.Render(" downloaded successfully from ") + formatIP(capturedTargetIP) + "\n"
 				return &CommandResult{Output: output, MissionCompleted: missionCompleted}
@@ -2064,12 +2394,21 @@ func (h *CommandHandler) handleCRYPTOMINER(args []string) *CommandResult {
 	}
 
 	serverIP := args[0]
+	notice := h.processMining()
 
 	if err := h.miningService.StartMining(h.user.ID, serverIP); err != nil {
-		return &CommandResult{Error: err}
+		return &CommandResult{Output: notice, Error: err}
 	}
 
-	output := fmt.Sprintf("Mining started on server %s\n", serverIP)
+	output := notice + fmt.Sprintf("Mining started on server %s\n", serverIP)
+	if miners, err := h.miningService.GetActiveMiners(h.user.ID); err == nil {
+		for _, miner := range miners {
+			if miner.ServerIP == serverIP {
+				output += ui.DimStyle.Render(fmt.Sprintf("Hashrate: %.2f MH/s, running as %s (pid %d)",
+					miner.Hashrate, services.MinerProcess, services.MinerPID(&miner))) + "\n"
+			}
+		}
+	}
 	return &CommandResult{Output: output}
 }
 
@@ -2083,12 +2422,17 @@ func (h *CommandHandler) handleSTOPMINING(args []string) *CommandResult {
 	}
 
 	serverIP := args[0]
+	notice := h.processMining()
 
-	if err := h.miningService.StopMining(h.user.ID, serverIP); err != nil {
-		return &CommandResult{Error: err}
+	lost, err := h.miningService.StopMining(h.user.ID, serverIP)
+	if err != nil {
+		return &CommandResult{Output: notice, Error: err}
 	}
 
-	output := ui.SuccessStyle.Render("✅ Mining stopped on server ") + formatIP(serverIP) + "\n"
+	output := notice + ui.SuccessStyle.Render("✅ Mining stopped on server ") + formatIP(serverIP) + "\n"
+	if lost >= 0.01 {
+		output += ui.DimStyle.Render(fmt.Sprintf("%.2f crypto short of a block was lost", lost)) + "\n"
+	}
 	return &CommandResult{Output: output}
 }
 
@@ -2097,24 +2441,34 @@ func (h *CommandHandler) handleMINERS() *CommandResult {
 		return &CommandResult{Error: fmt.Errorf("not authenticated")}
 	}
 
+	notice := h.processMining()
 	miners, err := h.miningService.GetActiveMiners(h.user.ID)
 	if err != nil {
-		return &CommandResult{Error: err}
+		return &CommandResult{Output: notice, Error: err}
 	}
 
+	var output strings.Builder
+	output.WriteString(notice)
+	output.WriteString(h.formatMiningStatus())
+
 	if len(miners) == 0 {
-		return &CommandResult{Output: ui.WarningStyle.Render("ℹ️  No active miners") + "\n"}
+		output.WriteString(ui.WarningStyle.Render("ℹ️  No active miners") + "\n")
+		return &CommandResult{Output: output.String()}
 	}
 
-	var output strings.Builder
 	output.WriteString(ui.WarningStyle.Render("⛏️  Active miners:") + "\n")
 	for _, miner := range miners {
 		duration := time.Since(miner.StartTime)
-		output.WriteString(ui.FormatListBullet(ui.AccentStyle.Render("• Server:") + " " + formatIP(miner.ServerIP) + " " + ui.ValueStyle.Render("(running for "+duration.Round(time.Second).String()+")")))
+		output.WriteString(ui.FormatListBullet(ui.AccentStyle.Render("• Server:") + " " + formatIP(miner.ServerIP) + " " + ui.ValueStyle.Render("(unpaid for "+duration.Round(time.Second).String()+")")))
 		output.WriteString("    " + ui.InfoStyle.Render("💻 Resources:") + " ")
 		output.WriteString(ui.InfoStyle.Render(fmt.Sprintf("CPU=%.1f", miner.ResourceUsage.CPU)) + ", ")
 		output.WriteString(ui.InfoStyle.Render(fmt.Sprintf("Bandwidth=%.1f", miner.ResourceUsage.Bandwidth)) + ", ")
 		output.WriteString(ui.InfoStyle.Render(fmt.Sprintf("RAM=%d", miner.ResourceUsage.RAM)) + "\n")
+		output.WriteString("    " + ui.InfoStyle.Render(fmt.Sprintf("⚡ %.2f MH/s", miner.Hashrate)))
+		if miner.Pending >= 0.01 {
+			output.WriteString(ui.DimStyle.Render(fmt.Sprintf(" · %.2f of %.0f crypto towards the next block", miner.Pending, services.SoloBlockReward)))
+		}
+		output.WriteString("\n")
 	}
 
 	return &CommandResult{Output: output.String()}
@@ -2232,10 +2586,14 @@ func (h *CommandHandler) handleDOWNLOAD(args []string) *CommandResult {
 	}
 	downloadsDir := "/home/" + username + "/Downloads"
 
-	// Calculate transfer time based on user resources (bandwidth, CPU, RAM)
+	// Calculate transfer time from the file size and the bandwidth of every machine back home
 	var duration float64 = 3.0
 	if h.progressService != nil && h.user != nil {
-		duration = h.progressService.CalculateOperationTime(services.OperationTransfer, h.user.Resources)
+		var bandwidths []float64
+		for _, host := range h.connectedHosts() {
+			bandwidths = append(bandwidths, h.hostBandwidth(host))
+		}
+		duration = h.progressService.CalculateTransferTime(len(content), bandwidths)
 	}
 
 	// Return progress operation - actual write happens after progress bar completes
@@ -2244,6 +2602,7 @@ func (h *CommandHandler) handleDOWNLOAD(args []string) *CommandResult {
 	capturedFileName := fileName
 	capturedDownloadsDir := downloadsDir
 	capturedServerIP := serverIP
+	capturedServerPath := h.currentServerPath
 	homeVFS := h.homeVFS
 
 	return &CommandResult{
@@ -2255,6 +2614,7 @@ func (h *CommandHandler) handleDOWNLOAD(args []string) *CommandResult {
 				if err := homeVFS.EnsureDirectoryAndCreateFile(capturedDownloadsDir, capturedFileName, capturedContent); err != nil {
 					return &CommandResult{Error: fmt.Errorf("download: %w", err)}
 				}
+				h.trackDataExtraction("download", capturedServerPath, len(capturedContent))
 				output := ui.SuccessStyle.Render("📥 Downloaded: ") + ui.ValueStyle.Render(capturedFileName) + ui.SuccessStyle.Render(" from ") + formatIP(capturedServerIP) + ui.SuccessStyle.Render(" → ~/Downloads/") + capturedFileName + "\n"
 				return &CommandResult{Output: output}
 			},
@@ -2487,12 +2847,14 @@ func parseCommand(input string) []string {
 	// Simple command parsing - split by spaces
 	parts := []string{}
 	current := ""
-	inQuotes := false
+	var quote rune // The quote character of the open quoted section, 0 if none
 
 	for _, char := range input {
-		if char == '"' || char == '\'' {
-			inQuotes = !inQuotes
-		} else if char == ' ' && !inQuotes {
+		if quote == 0 && (char == '"' || char == '\'') {
+			quote = char
+		} else if char == quote {
+			quote = 0
+		} else if char == ' ' && quote == 0 {
 			if current != "" {
 				parts = append(parts, current)
 				current = ""
@@ -0,0 +1,275 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"terminal-sh/models"
+	"terminal-sh/services"
+	"terminal-sh/ui"
+)
+
+// handleEVENT handles live events: world-wide incidents with their own missions and
+// leaderboards
+func (h *CommandHandler) handleEVENT(args []string) *CommandResult {
+	if h.user == nil {
+		return &CommandResult{Error: fmt.Errorf("not authenticated")}
+	}
+	if h.eventService == nil {
+		return &CommandResult{Error: fmt.Errorf("event service not available")}
+	}
+	h.eventService.ProcessEvents()
+
+	usage := fmt.Errorf("usage: event - Show running and upcoming events\n" +
+		"       event top [id] - Show an event's leaderboard\n" +
+		"       event loot - Take your cut of a heist vault (connected to the bank server)")
+	if len(args) == 0 {
+		return h.handleEventList()
+	}
+
+	switch {
+	case args[0] == "top" && len(args) <= 2:
+		return h.handleEventTop(args[1:])
+	case args[0] == "loot" && len(args) == 1:
+		return h.handleEventLoot()
+	case args[0] == "start" && len(args) >= 2:
+		return h.scheduleEvent(args[1], "0s", args[2:])
+	case args[0] == "schedule" && len(args) >= 3:
+		return h.scheduleEvent(args[1], args[2], args[3:])
+	case (args[0] == "end" || args[0] == "cancel") && len(args) == 2:
+		return h.stopEvent(args[0], args[1])
+	}
+	return &CommandResult{Error: usage}
+}
+
+// handleEventList shows the running events with their leaders, what's coming up, and how the
+// last events ended
+func (h *CommandHandler) handleEventList() *CommandResult {
+	active, err := h.eventService.Active()
+	if err != nil {
+		return &CommandResult{Error: err}
+	}
+	upcoming, err := h.eventService.Upcoming()
+	if err != nil {
+		return &CommandResult{Error: err}
+	}
+	recent, err := h.eventService.Recent(3)
+	if err != nil {
+		return &CommandResult{Error: err}
+	}
+
+	var output strings.Builder
+	output.WriteString(ui.FormatSectionHeader("Live Events:", "⚡"))
+	if len(active) == 0 {
+		output.WriteString(ui.DimStyle.Render("  Nothing happening right now.") + "\n")
+	}
+	for _, event := range active {
+		output.WriteString(ui.ListStyle.Render(fmt.Sprintf("  [%s] ", shortID(event.ID))) + ui.AccentBoldStyle.Render(services.EventTitle(&event)) +
+			" " + ui.WarningStyle.Render(fmt.Sprintf("%s left", time.Until(event.EndsAt).Round(time.Minute))) + "\n")
+		output.WriteString("    " + ui.ValueStyle.Render(services.EventSummary(&event)) + "\n")
+		output.WriteString(ui.FormatKeyValuePair("    Prize", h.formatPrize(&event)) + "\n")
+		for _, mission := range event.Missions {
+			output.WriteString(ui.FormatKeyValuePair("    Mission", fmt.Sprintf("%s (%s)", mission.Name, mission.ID)) + "\n")
+		}
+		leaders, _ := h.eventService.Leaderboard(event.ID, 3)
+		if len(leaders) > 0 {
+			var names []string
+			for i, score := range leaders {
+				names = append(names, fmt.Sprintf("%d. %s %s", i+1, score.Username, formatEventScore(&event, score.Score)))
+			}
+			output.WriteString(ui.FormatKeyValuePair("    Leaders", strings.Join(names, "  ")) + "\n")
+		}
+		output.WriteString("\n")
+	}
+
+	if len(upcoming) > 0 {
+		output.WriteString(ui.FormatSectionHeader("Coming Up:", "📅"))
+		for _, event := range upcoming {
+			output.WriteString(ui.ListStyle.Render(fmt.Sprintf("  [%s] ", shortID(event.ID))) + ui.AccentStyle.Render(services.EventTitle(&event)) +
+				" " + ui.DimStyle.Render(fmt.Sprintf("starts in %s, runs %s", time.Until(event.StartsAt).Round(time.Minute), event.EndsAt.Sub(event.StartsAt))) + "\n")
+		}
+		output.WriteString("\n")
+	}
+
+	if len(recent) > 0 {
+		output.WriteString(ui.FormatSectionHeader("Recently Ended:", "🏁"))
+		for _, event := range recent {
+			winner := "nobody scored"
+			if leaders, _ := h.eventService.Leaderboard(event.ID, 1); len(leaders) > 0 {
+				winner = fmt.Sprintf("won by %s with %s", leaders[0].Username, formatEventScore(&event, leaders[0].Score))
+			}
+			output.WriteString(ui.ListStyle.Render(fmt.Sprintf("  [%s] ", shortID(event.ID))) + ui.DimStyle.Render(fmt.Sprintf("%s · ended %s · %s",
+				services.EventTitle(&event), event.EndsAt.Format("2006-01-02 15:04"), winner)) + "\n")
+		}
+		output.WriteString("\n")
+	}
+
+	output.WriteString(ui.FormatUsage("Usage: event top [id] | event loot | mission start <missionID>"))
+	return &CommandResult{Output: output.String()}
+}
+
+// handleEventTop shows the leaderboard of an event: the given one, or the one running now,
+// or the last one to end
+func (h *CommandHandler) handleEventTop(args []string) *CommandResult {
+	var event *models.LiveEvent
+	if len(args) == 1 {
+		found, err := h.eventService.Find(args[0])
+		if err != nil {
+			return &CommandResult{Error: fmt.Errorf("event: %w", err)}
+		}
+		event = found
+	} else if active, _ := h.eventService.Active(); len(active) > 0 {
+		event = &active[0]
+	} else if recent, _ := h.eventService.Recent(1); len(recent) > 0 {
+		event = &recent[0]
+	} else {
+		return &CommandResult{Error: fmt.Errorf("event: no events yet")}
+	}
+
+	scores, err := h.eventService.Leaderboard(event.ID, 10)
+	if err != nil {
+		return &CommandResult{Error: err}
+	}
+
+	var output strings.Builder
+	output.WriteString(ui.FormatSectionHeader(fmt.Sprintf("%s Leaderboard:", services.EventTitle(event)), "🏆"))
+	if len(scores) == 0 {
+		output.WriteString(ui.DimStyle.Render("  Nobody has scored yet.") + "\n")
+	}
+	for i, score := range scores {
+		line := fmt.Sprintf("  %2d. %-16s %s", i+1, score.Username, formatEventScore(event, score.Score))
+		if score.Payout > 0 {
+			line += " " + ui.PriceStyle.Render(fmt.Sprintf("won %.2f crypto", score.Payout))
+		}
+		if score.UserID == h.user.ID {
+			output.WriteString(ui.AccentBoldStyle.Render(line) + "\n")
+			continue
+		}
+		output.WriteString(line + "\n")
+	}
+	output.WriteString("\n" + ui.FormatKeyValuePair("Prize", h.formatPrize(event)) + "\n")
+	return &CommandResult{Output: output.String()}
+}
+
+// handleEventLoot takes the user's cut of the vault of the heist server they're connected to
+func (h *CommandHandler) handleEventLoot() *CommandResult {
+	if h.currentServerPath == "" {
+		return &CommandResult{Error: fmt.Errorf("event loot: not connected to a server")}
+	}
+	parts := strings.Split(h.currentServerPath, ".localNetwork.")
+	cut, err := h.eventService.Loot(h.user.ID, h.user.Username, parts[len(parts)-1])
+	if err != nil {
+		return &CommandResult{Error: fmt.Errorf("event loot: %w", err)}
+	}
+	h.user.Wallet.Crypto += cut
+	return &CommandResult{Output: ui.SuccessStyle.Render(fmt.Sprintf("💰 Cleaned out %.2f crypto from the vault. Get out before the bank goes dark.", cut)) + "\n"}
+}
+
+// scheduleEvent starts an event after a delay, for an optional duration and, for a zero-day,
+// vulnerability type. Admin only.
+func (h *CommandHandler) scheduleEvent(kindArg, delayArg string, rest []string) *CommandResult {
+	if !h.userService.IsAdmin(h.user) {
+		return &CommandResult{Error: fmt.Errorf("event: permission denied")}
+	}
+	kind := models.EventKind(kindArg)
+	if !slices.Contains(services.EventKinds, kind) {
+		return &CommandResult{Error: fmt.Errorf("event: %w: %s (one of zeroday, heist, crackdown)", services.ErrUnknownEventKind, kindArg)}
+	}
+	delay, err := time.ParseDuration(delayArg)
+	if err != nil || delay < 0 {
+		return &CommandResult{Error: fmt.Errorf("event: invalid delay: %s (e.g. 30m, 2h)", delayArg)}
+	}
+	var duration time.Duration
+	vulnType := ""
+	for _, arg := range rest {
+		if d, err := time.ParseDuration(arg); err == nil && duration == 0 {
+			if d < time.Minute || d > 24*time.Hour {
+				return &CommandResult{Error: fmt.Errorf("event: duration must be between 1m and 24h")}
+			}
+			duration = d
+			continue
+		}
+		if kind != models.EventZeroDay || vulnType != "" {
+			return &CommandResult{Error: fmt.Errorf("usage: event start <zeroday|heist|crackdown> [duration] [vulnType]\n" +
+				"       event schedule <zeroday|heist|crackdown> <delay> [duration] [vulnType]")}
+		}
+		vulnType = arg
+	}
+
+	event, err := h.eventService.Schedule(kind, time.Now().Add(delay), duration, vulnType, &h.user.ID)
+	if err != nil {
+		return &CommandResult{Error: fmt.Errorf("event: %w", err)}
+	}
+	verb := "Started"
+	if event.Status == models.EventScheduled {
+		verb = "Scheduled"
+	}
+	var output strings.Builder
+	output.WriteString(ui.SuccessStyle.Render(fmt.Sprintf("✅ %s %s", verb, services.EventTitle(event))) + "\n")
+	output.WriteString(ui.FormatKeyValuePair("Event ID", shortID(event.ID)) + "\n")
+	output.WriteString(ui.FormatKeyValuePair("Runs", fmt.Sprintf("%s to %s", event.StartsAt.Format("2006-01-02 15:04"), event.EndsAt.Format("15:04"))) + "\n")
+	return &CommandResult{Output: output.String()}
+}
+
+// stopEvent ends a running event early or cancels one that hasn't started. Admin only.
+func (h *CommandHandler) stopEvent(action, eventID string) *CommandResult {
+	if !h.userService.IsAdmin(h.user) {
+		return &CommandResult{Error: fmt.Errorf("event %s: permission denied", action)}
+	}
+	var event *models.LiveEvent
+	var err error
+	if action == "end" {
+		event, err = h.eventService.End(eventID)
+	} else {
+		event, err = h.eventService.Cancel(eventID)
+	}
+	if errors.Is(err, services.ErrEventUnavailable) {
+		err = fmt.Errorf("%w (end stops a running event, cancel one that hasn't started)", err)
+	}
+	if err != nil {
+		return &CommandResult{Error: fmt.Errorf("event: %w", err)}
+	}
+	if action == "end" {
+		return &CommandResult{Output: ui.SuccessStyle.Render(fmt.Sprintf("✅ Ended %s and paid out its prizes", services.EventTitle(event))) + "\n"}
+	}
+	return &CommandResult{Output: ui.SuccessStyle.Render(fmt.Sprintf("✅ Cancelled %s", services.EventTitle(event))) + "\n"}
+}
+
+// formatPrize describes how an event's prize is split
+func (h *CommandHandler) formatPrize(event *models.LiveEvent) string {
+	shares := make([]string, len(services.PrizeShares))
+	for i, share := range services.PrizeShares {
+		shares[i] = fmt.Sprintf("%.0f", share*100)
+	}
+	return fmt.Sprintf("%.0f crypto, split %s among the top %d", event.Prize, strings.Join(shares, "/"), len(shares))
+}
+
+// formatEventScore formats a leaderboard score in the event's unit
+func formatEventScore(event *models.LiveEvent, score float64) string {
+	switch event.Kind {
+	case models.EventHeist:
+		return fmt.Sprintf("%.2f crypto", score)
+	case models.EventZeroDay:
+		return fmt.Sprintf("%.0f exploits", score)
+	}
+	return fmt.Sprintf("%.0f pts", score)
+}
+
+// withZeroDay adds the running zero-day to a service's vulnerabilities, for tools that check
+// them themselves instead of going through ExploitServer
+func (h *CommandHandler) withZeroDay(vulns []models.Vulnerability) []models.Vulnerability {
+	if zeroDay := h.exploitationService.ZeroDay(); zeroDay != nil {
+		return append(slices.Clone(vulns), *zeroDay)
+	}
+	return vulns
+}
+
+// scoreExploit scores an exploit made without ExploitServer on the running events' leaderboards
+func (h *CommandHandler) scoreExploit(server *models.Server, serviceName, vulnType string) {
+	if h.eventService != nil && h.user != nil {
+		h.eventService.RecordExploit(h.user.ID, h.user.Username, server, serviceName, []models.Exploit{{Type: vulnType}})
+	}
+}
@@ -0,0 +1,244 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"terminal-sh/filesystem"
+	"terminal-sh/ui"
+)
+
+// splitRecursiveFlag removes a leading -R from args.
+func splitRecursiveFlag(args []string) ([]string, bool) {
+	if len(args) > 0 && args[0] == "-R" {
+		return args[1:], true
+	}
+	return args, false
+}
+
+// handleCHMOD handles the chmod command
+func (h *CommandHandler) handleCHMOD(args []string) *CommandResult {
+	args, recursive := splitRecursiveFlag(args)
+	if len(args) != 2 {
+		return &CommandResult{Error: fmt.Errorf("usage: chmod [-R] <mode> <path>  (e.g. 640, u+x, go-w)")}
+	}
+
+	if err := h.vfs.Chmod(args[1], args[0], recursive); err != nil {
+		return &CommandResult{Error: fmt.Errorf("chmod: %w", err)}
+	}
+
+	node, err := h.vfs.Stat(args[1])
+	if err != nil {
+		return &CommandResult{Error: fmt.Errorf("chmod: %w", err)}
+	}
+	return &CommandResult{Output: ui.SuccessStyle.Render("Mode of ") + ui.ValueStyle.Render(args[1]) +
+		ui.SuccessStyle.Render(" is now ") + ui.AccentBoldStyle.Render(node.ModeString()) + "\n"}
+}
+
+// handleCHOWN handles the chown command
+func (h *CommandHandler) handleCHOWN(args []string) *CommandResult {
+	args, recursive := splitRecursiveFlag(args)
+	if len(args) != 2 {
+		return &CommandResult{Error: fmt.Errorf("usage: chown [-R] <owner>[:<group>] <path>")}
+	}
+
+	owner, group, _ := strings.Cut(args[0], ":")
+	if owner == "" && group == "" {
+		return &CommandResult{Error: fmt.Errorf("chown: invalid owner: '%s'", args[0])}
+	}
+
+	if err := h.vfs.Chown(args[1], owner, group, recursive); err != nil {
+		return &CommandResult{Error: fmt.Errorf("chown: %w", err)}
+	}
+
+	node, err := h.vfs.Stat(args[1])
+	if err != nil {
+		return &CommandResult{Error: fmt.Errorf("chown: %w", err)}
+	}
+	return &CommandResult{Output: ui.SuccessStyle.Render("Owner of ") + ui.ValueStyle.Render(args[1]) +
+		ui.SuccessStyle.Render(" is now ") + ui.AccentBoldStyle.Render(node.GetOwner()+":"+node.GetGroup()) + "\n"}
+}
+
+// handleSTAT handles the stat command
+func (h *CommandHandler) handleSTAT(args []string) *CommandResult {
+	if len(args) != 1 {
+		return &CommandResult{Error: fmt.Errorf("usage: stat <path>")}
+	}
+
+	node, err := h.vfs.Lstat(args[0])
+	if err != nil {
+		return &CommandResult{Error: fmt.Errorf("stat: %w", err)}
+	}
+
+	fileType := "regular file"
+	name := node.Path()
+	switch {
+	case node.IsDir:
+		fileType = "directory"
+	case node.IsSymlink():
+		fileType = "symbolic link"
+		name += " -> " + node.Symlink
+	case node.Size() == 0:
+		fileType = "regular empty file"
+	}
+
+	const timeFormat = "2006-01-02 15:04:05 -0700"
+	var output strings.Builder
+	output.WriteString("  " + ui.FormatKeyValuePair("File", name) + "\n")
+	output.WriteString("  " + ui.FormatKeyValuePair("Size", fmt.Sprintf("%-10d %s", node.Size(), fileType)) + "\n")
+	output.WriteString("  " + ui.FormatKeyValuePair("Links", fmt.Sprintf("%d", node.LinkCount())) + "\n")
+	output.WriteString("  " + ui.FormatKeyValuePair("Access", fmt.Sprintf("(%04o/%s)  Uid: %s  Gid: %s",
+		node.GetMode(), node.ModeString(), node.GetOwner(), node.GetGroup())) + "\n")
+	output.WriteString("  " + ui.FormatKeyValuePair("Access", node.GetAccessTime().In(time.Local).Format(timeFormat)) + "\n")
+	output.WriteString("  " + ui.FormatKeyValuePair("Modify", node.GetModTime().In(time.Local).Format(timeFormat)) + "\n")
+	return &CommandResult{Output: output.String()}
+}
+
+// handleLN handles the ln command
+func (h *CommandHandler) handleLN(args []string) *CommandResult {
+	symbolic := len(args) > 0 && args[0] == "-s"
+	if symbolic {
+		args = args[1:]
+	}
+	if len(args) != 2 {
+		return &CommandResult{Error: fmt.Errorf("usage: ln [-s] <target> <link_name>")}
+	}
+
+	var err error
+	if symbolic {
+		err = h.vfs.Symlink(args[0], args[1])
+	} else {
+		err = h.vfs.Link(args[0], args[1])
+	}
+	if err != nil {
+		return &CommandResult{Error: fmt.Errorf("ln: %w", err)}
+	}
+
+	arrow := " => "
+	if symbolic {
+		arrow = " -> "
+	}
+	output := ui.SuccessStyle.Render("Linked ") + ui.ValueStyle.Render(args[1]) +
+		ui.SuccessStyle.Render(arrow) + ui.AccentBoldStyle.Render(args[0]) + "\n"
+	if symbolic {
+		output += h.checkPathHijack(args[0], args[1])
+	}
+	return &CommandResult{Output: output}
+}
+
+// handleREADLINK handles the readlink command
+func (h *CommandHandler) handleREADLINK(args []string) *CommandResult {
+	if len(args) != 1 {
+		return &CommandResult{Error: fmt.Errorf("usage: readlink <path>")}
+	}
+
+	target, err := h.vfs.Readlink(args[0])
+	if err != nil {
+		return &CommandResult{Error: fmt.Errorf("readlink: %w", err)}
+	}
+	return &CommandResult{Output: target + "\n"}
+}
+
+// handleTAR handles the tar command. Only listing (-t) and extracting (-x) are supported.
+func (h *CommandHandler) handleTAR(args []string) *CommandResult {
+	const usage = "usage: tar -xf <archive> | tar -tf <archive>  (add v to list files while extracting)"
+	if len(args) != 2 {
+		return &CommandResult{Error: fmt.Errorf(usage)}
+	}
+	flags := strings.TrimPrefix(args[0], "-")
+	extract, list := strings.Contains(flags, "x"), strings.Contains(flags, "t")
+	if extract == list || !strings.Contains(flags, "f") {
+		return &CommandResult{Error: fmt.Errorf(usage)}
+	}
+
+	if list {
+		return h.listArchive("tar", args[1])
+	}
+	return h.extractArchive("tar", args[1], strings.Contains(flags, "v"))
+}
+
+// handleUNZIP handles the unzip command
+func (h *CommandHandler) handleUNZIP(args []string) *CommandResult {
+	if len(args) == 2 && args[0] == "-l" {
+		return h.listArchive("unzip", args[1])
+	}
+	if len(args) != 1 {
+		return &CommandResult{Error: fmt.Errorf("usage: unzip [-l] <archive.zip>")}
+	}
+	return h.extractArchive("unzip", args[0], true)
+}
+
+// listArchive lists the entries of an archive without extracting it
+func (h *CommandHandler) listArchive(cmdName, path string) *CommandResult {
+	content, err := h.vfs.ReadFileAtPath(path)
+	if err != nil {
+		return &CommandResult{Error: fmt.Errorf("%s: %w", cmdName, err)}
+	}
+	entries, err := filesystem.ReadArchive(content)
+	if err != nil {
+		return &CommandResult{Error: fmt.Errorf("%s: %s: %w", cmdName, path, err)}
+	}
+
+	var output strings.Builder
+	for _, entry := range entries {
+		if entry.IsDir {
+			output.WriteString(ui.ValueStyle.Render(strings.TrimSuffix(entry.Name, "/")+"/") + "\n")
+		} else {
+			output.WriteString(fmt.Sprintf("%s  %s\n", ui.ValueStyle.Render(entry.Name), ui.DimStyle.Render(fmt.Sprintf("(%d bytes)", len(entry.Content)))))
+		}
+	}
+	return &CommandResult{Output: output.String()}
+}
+
+// extractArchive expands an archive into the current directory
+func (h *CommandHandler) extractArchive(cmdName, path string, verbose bool) *CommandResult {
+	extracted, err := h.vfs.ExtractArchive(path)
+	if err != nil {
+		return &CommandResult{Error: fmt.Errorf("%s: %w", cmdName, err)}
+	}
+
+	var output strings.Builder
+	if verbose {
+		for _, name := range extracted {
+			output.WriteString("  " + ui.ValueStyle.Render(name) + "\n")
+		}
+	}
+	output.WriteString(ui.SuccessStyle.Render(fmt.Sprintf("📦 Extracted %d entries from ", len(extracted))) + ui.ValueStyle.Render(path) + "\n")
+	return &CommandResult{Output: output.String()}
+}
+
+// handleGUNZIP handles the gunzip command
+func (h *CommandHandler) handleGUNZIP(args []string) *CommandResult {
+	if len(args) != 1 {
+		return &CommandResult{Error: fmt.Errorf("usage: gunzip <file.gz>")}
+	}
+
+	name, err := h.vfs.DecompressFile(args[0])
+	if err != nil {
+		return &CommandResult{Error: fmt.Errorf("gunzip: %w", err)}
+	}
+	return &CommandResult{Output: ui.SuccessStyle.Render("Decompressed ") + ui.ValueStyle.Render(args[0]) +
+		ui.SuccessStyle.Render(" → ") + ui.ValueStyle.Render(name) + "\n"}
+}
+
+// handleDECRYPT handles the decrypt command
+func (h *CommandHandler) handleDECRYPT(args []string) *CommandResult {
+	if len(args) != 2 {
+		return &CommandResult{Error: fmt.Errorf("usage: decrypt <file> <key>")}
+	}
+
+	name, err := h.vfs.DecryptFile(args[0], args[1])
+	if errors.Is(err, filesystem.ErrWrongKey) {
+		content, _ := h.vfs.ReadFileAtPath(args[0])
+		if hint := filesystem.KeyHint(content); hint != "" {
+			return &CommandResult{Error: fmt.Errorf("decrypt: %w (hint: %s)", err, hint)}
+		}
+	}
+	if err != nil {
+		return &CommandResult{Error: fmt.Errorf("decrypt: %w", err)}
+	}
+	return &CommandResult{Output: ui.SuccessStyle.Render("🔓 Decrypted ") + ui.ValueStyle.Render(args[0]) +
+		ui.SuccessStyle.Render(" → ") + ui.ValueStyle.Render(name) + "\n"}
+}
@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"path/filepath"
+	"testing"
+
+	"terminal-sh/database"
+	"terminal-sh/filesystem"
+	"terminal-sh/services"
+)
+
+func newTestDatabase(t *testing.T) *database.Database {
+	t.Helper()
+
+	// The handler's services write their default seed files relative to the working directory
+	t.Chdir(t.TempDir())
+
+	db, err := database.NewDB(filepath.Join(t.TempDir(), "terminal-test.db"), "")
+	if err != nil {
+		t.Fatalf("failed to create test database: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Fatalf("failed to close test database: %v", err)
+		}
+	})
+	return db
+}
+
+// newTestHandler registers the player mallory and returns a command handler for them, at home in an empty filesystem.
+func newTestHandler(t *testing.T, db *database.Database) *CommandHandler {
+	t.Helper()
+
+	userService := services.NewUserService(db, "test-secret")
+	user, err := userService.Register("mallory", "password1")
+	if err != nil {
+		t.Fatalf("failed to register user: %v", err)
+	}
+	return NewCommandHandler(db, filesystem.NewVFS(user.Username), user, userService, services.NewChatService(db))
+}
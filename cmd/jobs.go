@@ -0,0 +1,318 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JobState is where a job is in its life.
+type JobState int
+
+const (
+	JobRunning   JobState = iota // Waiting out its duration; it can still be killed
+	JobFinishing                 // Its operation is executing and can no longer be killed
+	JobDone                      // Finished, waiting to be reported if it ran in the background
+	JobKilled                    // Killed before its operation ran, so it had no effect
+)
+
+// String returns the state as jobs shows it.
+func (s JobState) String() string {
+	switch s {
+	case JobDone:
+		return "Done"
+	case JobKilled:
+		return "Terminated"
+	}
+	return "Running"
+}
+
+var (
+	// ErrNoSuchJob is returned for a job spec or PID that isn't in the process table.
+	ErrNoSuchJob = errors.New("no such job")
+	// ErrJobFinishing is returned when killing a job whose operation is already executing.
+	ErrJobFinishing = errors.New("job is finishing and can't be interrupted")
+)
+
+// firstJobPID is the PID the first job of a session gets.
+const firstJobPID = 2048
+
+// Job is a long-running command in a session's process table. A job's operation only runs
+// once its duration has passed, so killing it before then leaves nothing half done.
+type Job struct {
+	ID         int // Job number, as in %1
+	PID        int
+	Command    string
+	Message    string
+	ServerPath string // Server it was started on, "" for the home machine
+	StartTime  time.Time
+	Duration   time.Duration
+
+	state       JobState
+	foreground  bool
+	interactive bool // Takes over the shell when it finishes, so it can't be sent to the background
+	result      *CommandResult
+	killed      chan struct{}
+}
+
+// Progress returns how far the job is through its duration, from 0 to 1.
+func (j *Job) Progress() float64 {
+	if j.Duration <= 0 {
+		return 1
+	}
+	return min(1, float64(time.Since(j.StartTime))/float64(j.Duration))
+}
+
+// State returns where the job is in its life.
+func (j *Job) State() JobState {
+	return j.state
+}
+
+// Result returns what a finished job's operation returned.
+func (j *Job) Result() *CommandResult {
+	return j.result
+}
+
+// Wait blocks until the job's duration has passed. Returns false if it was killed first.
+func (j *Job) Wait() bool {
+	timer := time.NewTimer(time.Until(j.StartTime.Add(j.Duration)))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-j.killed:
+		return false
+	}
+}
+
+// ProcessTable holds the jobs of one shell session, across every server it connects to.
+// It's safe for concurrent use: jobs finish in their own goroutines.
+type ProcessTable struct {
+	mu      sync.Mutex
+	jobs    []*Job
+	nextPID int
+}
+
+// NewProcessTable creates an empty process table.
+func NewProcessTable() *ProcessTable {
+	return &ProcessTable{nextPID: firstJobPID}
+}
+
+// Start adds a job for a progress operation. The caller runs it: Wait, then Begin, then the
+// operation, then Finish.
+func (t *ProcessTable) Start(req *ProgressOperationRequest, serverPath string, duration time.Duration, foreground bool) *Job {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Like bash, job numbers count up from the highest one still in the table
+	id := 1
+	for _, job := range t.jobs {
+		if job.ID >= id {
+			id = job.ID + 1
+		}
+	}
+	t.nextPID += 1 + len(t.jobs)%3
+	job := &Job{
+		ID:          id,
+		PID:         t.nextPID,
+		Command:     req.Command,
+		Message:     req.Message,
+		ServerPath:  serverPath,
+		StartTime:   time.Now(),
+		Duration:    duration,
+		foreground:  foreground,
+		interactive: req.Interactive,
+		killed:      make(chan struct{}),
+	}
+	t.jobs = append(t.jobs, job)
+	return job
+}
+
+// Begin marks a job's operation as executing. Returns false if the job was killed.
+func (t *ProcessTable) Begin(job *Job) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if job.state != JobRunning {
+		return false
+	}
+	job.state = JobFinishing
+	return true
+}
+
+// Finish records a job's result. A foreground job leaves the table and true is returned so
+// the shell shows the result; a background job stays until Reap reports it.
+func (t *ProcessTable) Finish(job *Job, result *CommandResult) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	job.state = JobDone
+	job.result = result
+	if job.foreground {
+		t.remove(job)
+	}
+	return job.foreground
+}
+
+// Kill stops a job before its operation runs.
+func (t *ProcessTable) Kill(job *Job) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch job.state {
+	case JobFinishing:
+		return ErrJobFinishing
+	case JobDone, JobKilled:
+		return ErrNoSuchJob
+	}
+	job.state = JobKilled
+	close(job.killed)
+	t.remove(job)
+	return nil
+}
+
+// Foreground brings a job to the foreground. Returns false if it has already finished.
+func (t *ProcessTable) Foreground(job *Job) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if job.state == JobDone || job.state == JobKilled {
+		return false
+	}
+	job.foreground = true
+	return true
+}
+
+// Background sends the foreground job to the background and returns it, or nil if there's
+// none or it's too late to detach.
+func (t *ProcessTable) Background() *Job {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, job := range t.jobs {
+		if job.foreground && job.state == JobRunning && !job.interactive {
+			job.foreground = false
+			return job
+		}
+	}
+	return nil
+}
+
+// ForegroundJob returns the job running in the foreground, or nil.
+func (t *ProcessTable) ForegroundJob() *Job {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, job := range t.jobs {
+		if job.foreground {
+			return job
+		}
+	}
+	return nil
+}
+
+// Jobs returns the background jobs, oldest first, with their states as of now.
+func (t *ProcessTable) Jobs() []Job {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var jobs []Job
+	for _, job := range t.jobs {
+		if !job.foreground {
+			jobs = append(jobs, *job)
+		}
+	}
+	return jobs
+}
+
+// Find returns the background job a spec names: %n or n for a job number, %% or %+ (or no
+// spec) for the most recent job.
+func (t *ProcessTable) Find(spec string) (*Job, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	spec = strings.TrimPrefix(spec, "%")
+	if spec == "" || spec == "%" || spec == "+" {
+		for i := len(t.jobs) - 1; i >= 0; i-- {
+			if !t.jobs[i].foreground && t.jobs[i].state != JobDone {
+				return t.jobs[i], nil
+			}
+		}
+		return nil, fmt.Errorf("current: %w", ErrNoSuchJob)
+	}
+	id, err := strconv.Atoi(spec)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", spec, ErrNoSuchJob)
+	}
+	for _, job := range t.jobs {
+		if job.ID == id && !job.foreground && job.state != JobDone {
+			return job, nil
+		}
+	}
+	return nil, fmt.Errorf("%%%d: %w", id, ErrNoSuchJob)
+}
+
+// NextPID returns the PID the next job will get, for ps to show itself under.
+func (t *ProcessTable) NextPID() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.nextPID + 1
+}
+
+// FindPID returns the job with a PID, or nil.
+func (t *ProcessTable) FindPID(pid int) *Job {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, job := range t.jobs {
+		if job.PID == pid && job.state != JobDone {
+			return job
+		}
+	}
+	return nil
+}
+
+// Reap removes the background jobs that finished since the last call and returns them, so
+// the shell can report them before its next prompt.
+func (t *ProcessTable) Reap() []Job {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var done []Job
+	for i := 0; i < len(t.jobs); {
+		if t.jobs[i].state != JobDone {
+			i++
+			continue
+		}
+		done = append(done, *t.jobs[i])
+		t.remove(t.jobs[i])
+	}
+	return done
+}
+
+// remove takes a job out of the table. The caller holds the lock.
+func (t *ProcessTable) remove(job *Job) {
+	for i, j := range t.jobs {
+		if j == job {
+			t.jobs = append(t.jobs[:i], t.jobs[i+1:]...)
+			return
+		}
+	}
+}
+
+// FormatJobLine formats a job the way bash reports it, e.g. "[1]  Done           nmap 10.0.0.1".
+func FormatJobLine(job Job) string {
+	command := job.Command
+	if job.state == JobRunning || job.state == JobFinishing {
+		command += " &"
+	}
+	return fmt.Sprintf("[%d]  %-14s %s", job.ID, job.state, command)
+}
+
+// splitBackground strips a trailing & from a command line, as in "nmap 10.0.0.1 &" or
+// "nmap 10.0.0.1&". Returns the remaining parts and whether there was one.
+func splitBackground(parts []string) ([]string, bool) {
+	last := parts[len(parts)-1]
+	if last == "&" {
+		return parts[:len(parts)-1], true
+	}
+	if strings.HasSuffix(last, "&") && !strings.HasSuffix(last, "&&") {
+		parts = append(parts[:len(parts)-1:len(parts)-1], strings.TrimSuffix(last, "&"))
+		return parts, true
+	}
+	return parts, false
+}
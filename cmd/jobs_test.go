@@ -0,0 +1,259 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"terminal-sh/services"
+)
+
+func TestSplitBackground(t *testing.T) {
+	tests := []struct {
+		line           []string
+		want           []string
+		wantBackground bool
+	}{
+		{[]string{"nmap", "10.0.0.1", "&"}, []string{"nmap", "10.0.0.1"}, true},
+		{[]string{"nmap", "x&"}, []string{"nmap", "x"}, true},
+		{[]string{"nmap", "x"}, []string{"nmap", "x"}, false},
+		{[]string{"nmap", "x&&"}, []string{"nmap", "x&&"}, false},
+		{[]string{"nmap", "x", "&&"}, []string{"nmap", "x", "&&"}, false},
+		{[]string{"&"}, []string{}, true},
+	}
+	for _, tt := range tests {
+		line := slices.Clone(tt.line)
+		got, background := splitBackground(line)
+		if !slices.Equal(got, tt.want) || background != tt.wantBackground {
+			t.Errorf("splitBackground(%q) = %q, %v; want %q, %v", tt.line, got, background, tt.want, tt.wantBackground)
+		}
+		if !slices.Equal(line, tt.line) {
+			t.Errorf("splitBackground(%q) changed its argument to %q", tt.line, line)
+		}
+	}
+}
+
+func TestJobLifecycle(t *testing.T) {
+	tests := []struct {
+		name       string
+		foreground bool
+		begin      bool // Begin before the kill
+		finish     bool // Finish before the kill
+		wantKill   error
+		wantState  JobState
+		wantListed bool // Still in jobs, waiting to be reaped
+	}{
+		{"killed while running", false, false, false, nil, JobKilled, false},
+		{"killed while finishing", false, true, false, ErrJobFinishing, JobFinishing, true},
+		{"killed after finishing in the background", false, true, true, ErrNoSuchJob, JobDone, true},
+		{"killed after finishing in the foreground", true, true, true, ErrNoSuchJob, JobDone, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := NewProcessTable()
+			job := table.Start(&ProgressOperationRequest{Command: "nmap 10.0.0.1"}, "", time.Hour, tt.foreground)
+			if tt.begin && !table.Begin(job) {
+				t.Fatal("expected a running job to begin")
+			}
+			if tt.finish {
+				if shown := table.Finish(job, &CommandResult{Output: "done"}); shown != tt.foreground {
+					t.Fatalf("expected Finish to return %v, got %v", tt.foreground, shown)
+				}
+			}
+
+			if err := table.Kill(job); !errors.Is(err, tt.wantKill) {
+				t.Fatalf("expected Kill to return %v, got %v", tt.wantKill, err)
+			}
+			if job.State() != tt.wantState {
+				t.Fatalf("expected the job to be %v, got %v", tt.wantState, job.State())
+			}
+			if listed := len(table.Jobs()) == 1; listed != tt.wantListed {
+				t.Fatalf("expected the job listed: %v, got %v", tt.wantListed, listed)
+			}
+			if tt.wantState == JobKilled && (table.Begin(job) || job.Wait()) {
+				t.Fatal("expected a killed job never to run")
+			}
+			if reaped := table.Reap(); (len(reaped) == 1) != (tt.wantState == JobDone && tt.wantListed) {
+				t.Fatalf("expected only a finished background job to be reaped, got %d", len(reaped))
+			}
+		})
+	}
+}
+
+func TestJobBeginRacesKill(t *testing.T) {
+	table := NewProcessTable()
+	for i := 0; i < 100; i++ {
+		job := table.Start(&ProgressOperationRequest{Command: "sleep"}, "", time.Hour, false)
+		var began bool
+		var killErr error
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			began = table.Begin(job)
+		}()
+		go func() {
+			defer wg.Done()
+			killErr = table.Kill(job)
+		}()
+		wg.Wait()
+
+		// Either the operation runs or the kill takes effect, never both
+		if began == (killErr == nil) {
+			t.Fatalf("expected exactly one of Begin and Kill to win, got began=%v kill=%v", began, killErr)
+		}
+		if began {
+			table.Finish(job, nil)
+		}
+	}
+	table.Reap()
+	if jobs := table.Jobs(); len(jobs) != 0 {
+		t.Fatalf("expected every job to be gone, got %d", len(jobs))
+	}
+}
+
+func TestBackgroundForegroundJob(t *testing.T) {
+	table := NewProcessTable()
+	if table.Background() != nil {
+		t.Fatal("expected nothing to background without a foreground job")
+	}
+
+	// Interactive jobs take over the shell and stay in the foreground
+	ssh := table.Start(&ProgressOperationRequest{Command: "ssh 10.0.0.1", Interactive: true}, "", time.Hour, true)
+	if table.Background() != nil {
+		t.Fatal("expected an interactive job to stay in the foreground")
+	}
+	table.Begin(ssh)
+	table.Finish(ssh, nil)
+
+	// Ctrl+Z: the running job goes to the background and becomes the current job
+	scan := table.Start(&ProgressOperationRequest{Command: "nmap 10.0.0.0/24"}, "", time.Hour, true)
+	if _, err := table.Find("%%"); !errors.Is(err, ErrNoSuchJob) {
+		t.Fatalf("expected no current job while it runs in the foreground, got %v", err)
+	}
+	if table.Background() != scan || table.ForegroundJob() != nil {
+		t.Fatal("expected Ctrl+Z to background the scan")
+	}
+	if table.Finish(scan, nil) {
+		t.Fatal("expected a backgrounded job's result to wait for Reap")
+	}
+	if reaped := table.Reap(); len(reaped) != 1 || FormatJobLine(reaped[0]) != "[1]  Done           nmap 10.0.0.0/24" {
+		t.Fatalf("expected the scan to be reported done, got %+v", reaped)
+	}
+
+	// Too late once its operation is executing
+	crack := table.Start(&ProgressOperationRequest{Command: "password_cracker 10.0.0.1"}, "", time.Hour, true)
+	table.Begin(crack)
+	if table.Background() != nil {
+		t.Fatal("expected a finishing job to stay in the foreground")
+	}
+}
+
+func TestFindJob(t *testing.T) {
+	table := NewProcessTable()
+	first := table.Start(&ProgressOperationRequest{Command: "nmap 10.0.0.1"}, "", time.Hour, false)
+	second := table.Start(&ProgressOperationRequest{Command: "nmap 10.0.0.2"}, "", time.Hour, false)
+	done := table.Start(&ProgressOperationRequest{Command: "nmap 10.0.0.3"}, "", time.Hour, false)
+	table.Start(&ProgressOperationRequest{Command: "nmap 10.0.0.4"}, "", time.Hour, true)
+	table.Begin(done)
+	table.Finish(done, nil)
+
+	tests := []struct {
+		spec string
+		want *Job
+	}{
+		{"%%", second},
+		{"%+", second},
+		{"", second},
+		{"%1", first},
+		{"2", second},
+		{"%3", nil}, // Finished
+		{"%4", nil}, // In the foreground
+		{"%9", nil},
+		{"%nmap", nil},
+	}
+	for _, tt := range tests {
+		job, err := table.Find(tt.spec)
+		if job != tt.want {
+			t.Errorf("Find(%q) = %+v, want %+v", tt.spec, job, tt.want)
+		}
+		if tt.want == nil && !errors.Is(err, ErrNoSuchJob) {
+			t.Errorf("Find(%q) returned %v, want ErrNoSuchJob", tt.spec, err)
+		}
+	}
+}
+
+// Run with -race: a background job's operation shares the handler, and its home filesystem,
+// with the commands run in the foreground meanwhile.
+func TestBackgroundJobRunsAlongsideCommands(t *testing.T) {
+	handler := newTestHandler(t, newTestDatabase(t))
+	homeVFS := handler.homeVFS
+
+	// What download's operation does when the transfer completes
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			handler.RunOperation(func() *CommandResult {
+				if err := homeVFS.EnsureDirectoryAndCreateFile("/home/mallory/Downloads", fmt.Sprintf("dump-%d.sql", i), "secrets"); err != nil {
+					return &CommandResult{Error: err}
+				}
+				return &CommandResult{}
+			})
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		handler.Execute(fmt.Sprintf("touch notes-%d", i))
+		handler.Execute("ls")
+	}
+	wg.Wait()
+
+	for _, path := range []string{"/home/mallory/Downloads/dump-19.sql", "/home/mallory/notes-19"} {
+		if _, err := homeVFS.ReadFileAtPath(path); err != nil {
+			t.Fatalf("expected %s: %v", path, err)
+		}
+	}
+}
+
+// A background job finishes on the machine it was started on, whatever the shell moved to since.
+func TestBackgroundScanWritesWhereItStarted(t *testing.T) {
+	db := newTestDatabase(t)
+	handler := newTestHandler(t, db)
+	serverService := services.NewServerService(db)
+	target, err := serverService.CreateServer("203.0.113.30", "10.30.0.1")
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	other, err := serverService.CreateServer("203.0.113.31", "10.31.0.1")
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	homeVFS := handler.homeVFS
+
+	result := handler.Execute("nmap -oN scan.txt " + target.IP + " &")
+	if result.Error != nil || result.StartProgress == nil || !result.StartProgress.Background {
+		t.Fatalf("expected nmap to start in the background, got %+v", result)
+	}
+
+	otherVFS, err := handler.CreateServerVFS(other.IP)
+	if err != nil {
+		t.Fatalf("failed to load server filesystem: %v", err)
+	}
+	otherVFS.SetRole("root", true, "/root")
+	handler.SwitchContext(otherVFS, other.IP, "ssh")
+
+	if result := handler.RunOperation(result.StartProgress.Operation); result.Error != nil {
+		t.Fatalf("expected the scan to finish, got %v", result.Error)
+	}
+	if content, err := homeVFS.ReadFileAtPath("/home/mallory/scan.txt"); err != nil || !strings.Contains(content, target.IP) {
+		t.Fatalf("expected the report in the home directory it was started from, got %q (%v)", content, err)
+	}
+	if _, err := otherVFS.ReadFileAtPath("/root/scan.txt"); err == nil {
+		t.Fatal("expected no report on the server the shell moved to")
+	}
+}
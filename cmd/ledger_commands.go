@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"terminal-sh/models"
+	"terminal-sh/services"
+	"terminal-sh/ui"
+
+	"github.com/google/uuid"
+)
+
+// defaultTransactionCount is how many ledger entries transactions shows without a count
+const defaultTransactionCount = 20
+
+// handleTRANSACTIONS lists the ledger entries of the user's wallet, newest first. Admins can
+// pass --verify to reconcile the whole ledger against every wallet.
+func (h *CommandHandler) handleTRANSACTIONS(args []string) *CommandResult {
+	if h.user == nil {
+		return &CommandResult{Error: fmt.Errorf("not authenticated")}
+	}
+	if len(args) == 1 && args[0] == "--verify" {
+		if !h.userService.IsAdmin(h.user) {
+			return &CommandResult{Error: fmt.Errorf("transactions --verify: permission denied")}
+		}
+		return h.verifyLedger()
+	}
+
+	count := defaultTransactionCount
+	if len(args) == 1 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n <= 0 {
+			return &CommandResult{Error: fmt.Errorf("usage: transactions [count]")}
+		}
+		count = n
+	} else if len(args) > 1 {
+		return &CommandResult{Error: fmt.Errorf("usage: transactions [count]")}
+	}
+
+	entries, err := h.ledgerService.History(services.UserAccount(h.user.ID), count)
+	if err != nil {
+		return &CommandResult{Error: fmt.Errorf("failed to load transactions: %w", err)}
+	}
+
+	var output strings.Builder
+	output.WriteString(ui.FormatSectionHeader("Transactions:", "🧾"))
+	if len(entries) == 0 {
+		output.WriteString(ui.DimStyle.Render("  No transactions yet.") + "\n")
+		return &CommandResult{Output: output.String()}
+	}
+
+	counterparties := h.ledgerService.Counterparties(entries)
+	for _, entry := range entries {
+		amount := fmt.Sprintf("%+.2f %s", entry.Amount, entry.Currency)
+		if entry.Amount < 0 {
+			amount = ui.ErrorStyle.Render(amount)
+		} else {
+			amount = ui.SuccessStyle.Render(amount)
+		}
+		output.WriteString(fmt.Sprintf("  %s  %s  %s\n",
+			ui.DimStyle.Render(entry.CreatedAt.Format("2006-01-02 15:04")),
+			amount,
+			ui.ValueStyle.Render(strings.ReplaceAll(entry.Reason, "_", " "))))
+
+		details := []string{"balance " + fmt.Sprintf("%.2f", entry.Balance)}
+		if counterparty, ok := counterparties[entry.ID]; ok {
+			direction := "from"
+			if entry.Amount < 0 {
+				direction = "to"
+			}
+			details = append(details, direction+" "+h.accountLabel(counterparty))
+		}
+		if entry.Reference != "" {
+			details = append(details, "ref "+entry.Reference)
+		}
+		output.WriteString("      " + ui.DimStyle.Render(strings.Join(details, " · ")) + "\n")
+	}
+
+	return &CommandResult{Output: output.String()}
+}
+
+// verifyLedger reconciles the ledger and reports unbalanced transactions and drifted wallets
+func (h *CommandHandler) verifyLedger() *CommandResult {
+	report, err := h.ledgerService.Reconcile()
+	if err != nil {
+		return &CommandResult{Error: err}
+	}
+
+	var output strings.Builder
+	output.WriteString(ui.FormatSectionHeader("Ledger Reconciliation:", "🧾"))
+	output.WriteString("  " + ui.FormatKeyValuePair("Entries", fmt.Sprintf("%d", report.Entries)) + "\n")
+	if report.OK() {
+		output.WriteString(ui.SuccessStyle.Render("  ✓ Ledger balances and every wallet matches it") + "\n")
+		return &CommandResult{Output: output.String()}
+	}
+	for _, txID := range report.Unbalanced {
+		output.WriteString(ui.ErrorStyle.Render("  ✗ Unbalanced transaction "+txID.String()) + "\n")
+	}
+	for _, drift := range report.Drift {
+		output.WriteString(ui.ErrorStyle.Render(fmt.Sprintf("  ✗ %s %s: ledger %.2f, wallet %.2f (drift %+.2f)",
+			h.accountLabel(drift.Account), drift.Currency, drift.Ledger, drift.Wallet, drift.Wallet-drift.Ledger)) + "\n")
+	}
+	for _, account := range report.Orphaned {
+		output.WriteString(ui.WarningStyle.Render("  ! Entries for missing wallet "+account) + "\n")
+	}
+	return &CommandResult{Output: output.String()}
+}
+
+// accountLabel names a ledger account for display: a username, server IP or system account
+func (h *CommandHandler) accountLabel(account string) string {
+	kind, rawID, _ := strings.Cut(account, ":")
+	id, err := uuid.Parse(rawID)
+	switch {
+	case kind == "system":
+		return rawID
+	case err != nil:
+		return account
+	case kind == "user":
+		if user, err := h.userService.GetUserByID(id); err == nil {
+			return user.Username
+		}
+	case kind == "server":
+		var server models.Server
+		if err := h.db.Select("ip").First(&server, "id = ?", id).Error; err == nil {
+			return server.IP
+		}
+	}
+	return account
+}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"terminal-sh/services"
+	"terminal-sh/ui"
+)
+
+// processMining pays out every miner and lets the hosts' admins look for them, and returns a
+// notice for any of the user's miners that were found and killed.
+func (h *CommandHandler) processMining() string {
+	kills, err := h.miningService.ProcessMiningRewards()
+	if err != nil {
+		return ""
+	}
+	var notice strings.Builder
+	for _, kill := range kills {
+		if kill.Miner.UserID != h.user.ID {
+			continue
+		}
+		notice.WriteString(ui.WarningStyle.Render("⚠️  The admin of ") + formatIP(kill.Miner.ServerIP) +
+			ui.WarningStyle.Render(fmt.Sprintf(" found your miner (pid %d) and killed it", services.MinerPID(&kill.Miner))))
+		if kill.Lost >= 0.01 {
+			notice.WriteString(ui.DimStyle.Render(fmt.Sprintf(", losing %.2f crypto short of a block", kill.Lost)))
+		}
+		notice.WriteString("\n")
+	}
+	return notice.String()
+}
+
+// formatMiningStatus shows the network's hashrate and difficulty and who the user mines for.
+func (h *CommandHandler) formatMiningStatus() string {
+	stats := h.miningService.GetNetworkStats()
+	var output strings.Builder
+	output.WriteString(ui.FormatKeyValuePair("Network", fmt.Sprintf("%.2f MH/s from %d miner(s), difficulty %.2f",
+		stats.Hashrate, stats.Miners, stats.Difficulty)) + "\n")
+	if pool := h.miningService.GetUserPool(h.user.ID); pool != nil {
+		output.WriteString(ui.FormatKeyValuePair("Pool", fmt.Sprintf("%s (%.0f%% fee)", pool.Name, pool.Fee*100)) + "\n")
+	} else {
+		output.WriteString(ui.FormatKeyValuePair("Pool", fmt.Sprintf("none, mining solo in %.0f crypto blocks", services.SoloBlockReward)) + "\n")
+	}
+	return output.String()
+}
+
+// handlePOOL handles the pool command: list mining pools, create one, join or leave.
+func (h *CommandHandler) handlePOOL(args []string) *CommandResult {
+	if h.user == nil {
+		return &CommandResult{Error: fmt.Errorf("not authenticated")}
+	}
+
+	notice := h.processMining()
+	if len(args) == 0 || args[0] == "list" {
+		return h.handlePoolList(notice)
+	}
+
+	switch args[0] {
+	case "create":
+		if len(args) < 2 || len(args) > 3 {
+			return &CommandResult{Output: notice, Error: fmt.Errorf("usage: pool create <name> [fee%%]")}
+		}
+		fee := 0.0
+		if len(args) == 3 {
+			percent, err := strconv.ParseFloat(strings.TrimSuffix(args[2], "%"), 64)
+			if err != nil {
+				return &CommandResult{Output: notice, Error: fmt.Errorf("invalid fee: %s", args[2])}
+			}
+			fee = percent / 100
+		}
+		pool, err := h.miningService.CreatePool(h.user.ID, args[1], fee)
+		if err != nil {
+			return &CommandResult{Output: notice, Error: err}
+		}
+		return &CommandResult{Output: notice + ui.SuccessStyle.Render(fmt.Sprintf("✅ Created mining pool %s with a %.0f%% fee. You mine for it now.", pool.Name, pool.Fee*100)) + "\n"}
+
+	case "join":
+		if len(args) != 2 {
+			return &CommandResult{Output: notice, Error: fmt.Errorf("usage: pool join <name>")}
+		}
+		pool, err := h.miningService.JoinPool(h.user.ID, args[1])
+		if err != nil {
+			return &CommandResult{Output: notice, Error: err}
+		}
+		return &CommandResult{Output: notice + ui.SuccessStyle.Render(fmt.Sprintf("✅ Joined mining pool %s. Its earnings are split by hashrate every period, less its %.0f%% fee.", pool.Name, pool.Fee*100)) + "\n"}
+
+	case "leave":
+		pool, err := h.miningService.LeavePool(h.user.ID)
+		if errors.Is(err, services.ErrNotInPool) {
+			return &CommandResult{Output: notice, Error: fmt.Errorf("you already mine solo")}
+		}
+		if err != nil {
+			return &CommandResult{Output: notice, Error: err}
+		}
+		return &CommandResult{Output: notice + ui.SuccessStyle.Render(fmt.Sprintf("✅ Left mining pool %s. You mine solo now.", pool.Name)) + "\n"}
+	}
+
+	return &CommandResult{Output: notice, Error: fmt.Errorf("usage: pool [list|create <name> [fee%%]|join <name>|leave]")}
+}
+
+// handlePoolList lists the mining pools, biggest first.
+func (h *CommandHandler) handlePoolList(notice string) *CommandResult {
+	pools, err := h.miningService.GetPools()
+	if err != nil {
+		return &CommandResult{Output: notice, Error: err}
+	}
+
+	var output strings.Builder
+	output.WriteString(notice)
+	output.WriteString(ui.FormatSectionHeader("Mining Pools:", "⛏️"))
+	output.WriteString(h.formatMiningStatus())
+	if len(pools) == 0 {
+		output.WriteString(ui.DimStyle.Render("  No pools yet.") + "\n")
+	}
+	for _, summary := range pools {
+		output.WriteString(fmt.Sprintf("  %s %s\n", ui.AccentStyle.Render(summary.Pool.Name),
+			ui.DimStyle.Render(fmt.Sprintf("by %s · %d member(s) · %.2f MH/s · %.0f%% fee",
+				summary.Owner, summary.Members, summary.Hashrate, summary.Pool.Fee*100))))
+	}
+	output.WriteString("\n" + ui.FormatUsage("Usage: pool create <name> [fee%] | pool join <name> | pool leave"))
+	return &CommandResult{Output: output.String()}
+}
@@ -0,0 +1,274 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"terminal-sh/services"
+	"terminal-sh/ui"
+)
+
+// networkGraph returns the network topology, loading it on first use in the current command.
+// Operations that run after the command returns must not call it: capture what they need first.
+func (h *CommandHandler) networkGraph() (*services.NetworkGraph, error) {
+	if h.networkService == nil {
+		return nil, errors.New("network unavailable")
+	}
+	if h.graph == nil {
+		graph, err := h.networkService.Graph()
+		if err != nil {
+			return nil, err
+		}
+		h.graph = graph
+	}
+	return h.graph, nil
+}
+
+// routeTo finds the route from the player's location to targetIP, using their pivot tunnels
+// where needed. Returns the hops (ending with the target) and the server path the target is
+// reached at. The error is services.ErrNoRoute if the target is on an internal subnet the player
+// can't get to.
+func (h *CommandHandler) routeTo(targetIP string) ([]services.Hop, string, error) {
+	if h.networkService == nil {
+		return nil, h.legacyServerPath(targetIP), nil
+	}
+	graph, err := h.networkGraph()
+	if err != nil {
+		return nil, "", err
+	}
+	hops, via, err := graph.Route(h.currentServerPath, h.tunnels, targetIP)
+	if err != nil {
+		return hops, "", err
+	}
+	if via == "" {
+		return hops, targetIP, nil
+	}
+	return hops, via + ".localNetwork." + targetIP, nil
+}
+
+// targetServerPath returns the server path of targetIP as seen from the player's location:
+// nested under the current server, or under the pivot tunnel that reaches it.
+func (h *CommandHandler) targetServerPath(targetIP string) string {
+	if _, path, err := h.routeTo(targetIP); err == nil {
+		return path
+	}
+	return h.legacyServerPath(targetIP)
+}
+
+// legacyServerPath nests targetIP under the current server, as paths were built before routing.
+func (h *CommandHandler) legacyServerPath(targetIP string) string {
+	if h.currentServerPath == "" {
+		return targetIP
+	}
+	return h.currentServerPath + ".localNetwork." + targetIP
+}
+
+// checkRoute returns an error if targetIP is a server the player has no route to.
+// Anything that isn't a known server (a file name, say) passes.
+func (h *CommandHandler) checkRoute(cmdName, targetIP string) error {
+	server, err := h.serverService.GetServerByIP(targetIP)
+	if err != nil {
+		return nil
+	}
+	if _, _, err := h.routeTo(server.IP); errors.Is(err, services.ErrNoRoute) {
+		return fmt.Errorf("%s: %s: %w", cmdName, targetIP, err)
+	}
+	return nil
+}
+
+// logRoute records traffic to the last hop in the ServerLog of every machine it passes through.
+func (h *CommandHandler) logRoute(hops []services.Hop, message string) {
+	if h.serverLogService == nil || h.user == nil || len(hops) < 2 {
+		return
+	}
+	sourceIP := h.user.IP
+	destIP := hops[len(hops)-1].IP
+	for _, hop := range hops[:len(hops)-1] {
+		h.serverLogService.LogRoute(hop.IP, sourceIP, destIP, &h.user.ID, message)
+	}
+}
+
+// handleTRACEROUTE handles the traceroute command
+func (h *CommandHandler) handleTRACEROUTE(args []string) *CommandResult {
+	if h.user == nil {
+		return &CommandResult{Error: fmt.Errorf("not authenticated")}
+	}
+	if len(args) != 1 {
+		return &CommandResult{Error: fmt.Errorf("usage: traceroute <targetIP>")}
+	}
+
+	server, err := h.serverService.GetServerByIP(args[0])
+	if err != nil {
+		return &CommandResult{Error: fmt.Errorf("traceroute: unknown host %s", args[0])}
+	}
+	hops, _, routeErr := h.routeTo(server.IP)
+	if routeErr != nil && !errors.Is(routeErr, services.ErrNoRoute) {
+		return &CommandResult{Error: fmt.Errorf("traceroute: %w", routeErr)}
+	}
+
+	var output strings.Builder
+	output.WriteString(fmt.Sprintf("traceroute to %s, %d hops max\n", formatIP(server.IP), 30))
+	total := 0
+	for i, hop := range hops {
+		total += hop.LatencyMs
+		output.WriteString(fmt.Sprintf("%2d  %s  %s\n", i+1, formatIP(hop.IP), ui.ValueStyle.Render(fmt.Sprintf("%d ms", total))))
+	}
+	if routeErr != nil {
+		output.WriteString(fmt.Sprintf("%2d  %s\n", len(hops)+1, ui.ErrorStyle.Render("* * *  !N (network unreachable)")))
+		output.WriteString(ui.DimStyle.Render("  The target is on an internal subnet - pivot through its gateway with 'tunnel add'") + "\n")
+	}
+
+	// Every hop that answered logs the probe
+	h.logRoute(hops, "Traceroute probe")
+	return &CommandResult{Output: output.String()}
+}
+
+// handleROUTE handles the route command: the routing table of the current machine
+func (h *CommandHandler) handleROUTE(args []string) *CommandResult {
+	if h.user == nil {
+		return &CommandResult{Error: fmt.Errorf("not authenticated")}
+	}
+	if len(args) > 1 || (len(args) == 1 && args[0] != "-n") {
+		return &CommandResult{Error: fmt.Errorf("usage: route [-n]")}
+	}
+
+	var routes []services.Route
+	if h.currentServerPath == "" {
+		routes = []services.Route{{Destination: "default", Gateway: homeRouter(h.user.LocalIP), Interface: "eth0"}}
+	} else {
+		server, err := h.serverService.GetServerByPath(h.currentServerPath)
+		if err != nil {
+			return &CommandResult{Error: fmt.Errorf("route: %w", err)}
+		}
+		graph, err := h.networkGraph()
+		if err != nil {
+			return &CommandResult{Error: fmt.Errorf("route: %w", err)}
+		}
+		routes = graph.Routes(server.IP)
+	}
+	if h.currentServerPath == "" {
+		// Pivot tunnels route the subnets behind their gateway
+		if graph, err := h.networkGraph(); err == nil {
+			for i, tunnel := range h.tunnels {
+				gateway := tunnelGateway(tunnel)
+				for _, subnet := range graph.Subnets(gateway) {
+					routes = append(routes, services.Route{Destination: subnet, Gateway: gateway, Interface: fmt.Sprintf("tun%d", i)})
+				}
+			}
+		}
+	}
+
+	var output strings.Builder
+	output.WriteString("Kernel IP routing table\n")
+	output.WriteString(ui.HeaderStyle.Render(fmt.Sprintf("%-18s %-16s %-6s %s", "Destination", "Gateway", "Flags", "Iface")) + "\n")
+	for _, route := range routes {
+		gateway, flags := route.Gateway, "U"
+		if gateway == "" {
+			gateway = "*"
+		} else {
+			flags = "UG"
+		}
+		output.WriteString(fmt.Sprintf("%-18s %-16s %-6s %s\n", route.Destination, gateway, flags, route.Interface))
+	}
+	return &CommandResult{Output: output.String()}
+}
+
+// handleTUNNEL handles the tunnel command: SOCKS-style pivots through compromised servers
+func (h *CommandHandler) handleTUNNEL(args []string) *CommandResult {
+	if h.user == nil {
+		return &CommandResult{Error: fmt.Errorf("not authenticated")}
+	}
+	const usage = "usage: tunnel add | tunnel list | tunnel del <gatewayIP>"
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+
+	switch args[0] {
+	case "add":
+		if len(args) != 1 {
+			return &CommandResult{Error: fmt.Errorf(usage)}
+		}
+		return h.addTunnel()
+	case "list", "ls":
+		return h.listTunnels()
+	case "del", "rm":
+		if len(args) != 2 {
+			return &CommandResult{Error: fmt.Errorf(usage)}
+		}
+		for i, tunnel := range h.tunnels {
+			if tunnelGateway(tunnel) == args[1] {
+				h.tunnels = append(h.tunnels[:i:i], h.tunnels[i+1:]...)
+				return &CommandResult{Output: ui.SuccessStyle.Render("Tunnel through ") + formatIP(args[1]) + ui.SuccessStyle.Render(" closed") + "\n"}
+			}
+		}
+		return &CommandResult{Error: fmt.Errorf("tunnel: no tunnel through %s", args[1])}
+	default:
+		return &CommandResult{Error: fmt.Errorf(usage)}
+	}
+}
+
+// addTunnel opens a pivot tunnel through the current server, so its internal subnet can be
+// reached from anywhere for the rest of the session.
+func (h *CommandHandler) addTunnel() *CommandResult {
+	if h.currentServerPath == "" {
+		return &CommandResult{Error: fmt.Errorf("tunnel: must be connected to the server to pivot through")}
+	}
+	for _, tunnel := range h.tunnels {
+		if tunnel == h.currentServerPath {
+			return &CommandResult{Error: fmt.Errorf("tunnel: already pivoting through %s", tunnelGateway(tunnel))}
+		}
+	}
+
+	gateway := tunnelGateway(h.currentServerPath)
+	var subnets []string
+	if graph, err := h.networkGraph(); err == nil {
+		subnets = graph.Subnets(gateway)
+	}
+	h.tunnels = append(h.tunnels, h.currentServerPath)
+
+	var output strings.Builder
+	output.WriteString(ui.SuccessStyle.Render("🔀 Tunnel opened through ") + formatIP(gateway) + "\n")
+	if len(subnets) == 0 {
+		output.WriteString(ui.WarningStyle.Render("  This host has no internal subnet to reach") + "\n")
+	} else {
+		output.WriteString(ui.FormatKeyValuePair("Routes:", strings.Join(subnets, ", ")) + "\n")
+	}
+	output.WriteString(ui.DimStyle.Render("  The tunnel stays up after you exit - reach the subnet from anywhere this session") + "\n")
+	return &CommandResult{Output: output.String()}
+}
+
+// listTunnels lists the open pivot tunnels
+func (h *CommandHandler) listTunnels() *CommandResult {
+	if len(h.tunnels) == 0 {
+		return &CommandResult{Output: "No tunnels open - connect to a server and run 'tunnel add'\n"}
+	}
+	graph, _ := h.networkGraph()
+
+	var output strings.Builder
+	output.WriteString(ui.FormatSectionHeader("Pivot tunnels:", "🔀"))
+	for i, tunnel := range h.tunnels {
+		gateway := tunnelGateway(tunnel)
+		line := fmt.Sprintf("tun%d via %s", i, formatIP(gateway))
+		if graph != nil {
+			if subnets := graph.Subnets(gateway); len(subnets) > 0 {
+				line += " → " + strings.Join(subnets, ", ")
+			}
+		}
+		output.WriteString(ui.FormatListBullet(line))
+	}
+	return &CommandResult{Output: output.String()}
+}
+
+// tunnelGateway returns the IP of the server a tunnel pivots through: the last one in its path.
+func tunnelGateway(serverPath string) string {
+	return hostName(ConnectedHost{ServerPath: serverPath})
+}
+
+// homeRouter returns the router of the player's home network: the .1 of their local /24.
+func homeRouter(localIP string) string {
+	if i := strings.LastIndex(localIP, "."); i >= 0 {
+		return localIP[:i] + ".1"
+	}
+	return "0.0.0.0"
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"terminal-sh/models"
+	"terminal-sh/services"
+	"terminal-sh/ui"
+)
+
+// notificationIcons mark each kind of notification in the list and the shell's status line
+var notificationIcons = map[models.NotificationKind]string{
+	models.NotificationMention:     "💬",
+	models.NotificationMining:      "⛏️",
+	models.NotificationAdmin:       "🛡️",
+	models.NotificationMission:     "📖",
+	models.NotificationAchievement: "🏆",
+	models.NotificationIntrusion:   "🚨",
+}
+
+// NotificationIcon returns the icon for a kind of notification
+func NotificationIcon(kind models.NotificationKind) string {
+	if icon, ok := notificationIcons[kind]; ok {
+		return icon
+	}
+	return "🔔"
+}
+
+// handleNOTIFICATIONS shows the user's notifications and marks them read, or clears them
+func (h *CommandHandler) handleNOTIFICATIONS(args []string) *CommandResult {
+	if h.user == nil {
+		return &CommandResult{Error: fmt.Errorf("not authenticated")}
+	}
+	if h.notificationService == nil {
+		return &CommandResult{Error: fmt.Errorf("notification service not available")}
+	}
+
+	switch {
+	case len(args) == 0:
+		return h.listNotifications(true)
+	case len(args) == 1 && args[0] == "all":
+		return h.listNotifications(false)
+	case len(args) == 1 && args[0] == "clear":
+		cleared, err := h.notificationService.Clear(h.user.ID)
+		if err != nil {
+			return &CommandResult{Error: fmt.Errorf("notifications: %w", err)}
+		}
+		return &CommandResult{Output: ui.SuccessStyle.Render(fmt.Sprintf("✅ Cleared %d notifications", cleared)) + "\n"}
+	}
+	return &CommandResult{Error: fmt.Errorf("usage: notifications - Read new notifications\n" +
+		"       notifications all - Show your latest notifications, read or not\n" +
+		"       notifications clear - Delete all your notifications")}
+}
+
+// listNotifications shows the user's unread notifications, or all their latest ones, newest
+// first, and marks them read
+func (h *CommandHandler) listNotifications(unreadOnly bool) *CommandResult {
+	notifications, err := h.notificationService.Recent(h.user.ID, unreadOnly, services.NotificationLimit)
+	if err != nil {
+		return &CommandResult{Error: fmt.Errorf("notifications: %w", err)}
+	}
+
+	var output strings.Builder
+	output.WriteString(ui.FormatSectionHeader("Notifications:", "🔔"))
+	if len(notifications) == 0 {
+		if unreadOnly {
+			output.WriteString(ui.DimStyle.Render("  No new notifications.") + "\n\n")
+		} else {
+			output.WriteString(ui.DimStyle.Render("  No notifications.") + "\n\n")
+		}
+		output.WriteString(ui.FormatUsage("Usage: notifications [all|clear]"))
+		return &CommandResult{Output: output.String()}
+	}
+
+	for _, n := range notifications {
+		line := fmt.Sprintf("%s %s", NotificationIcon(n.Kind), n.Message)
+		when := ui.DimStyle.Render(n.UpdatedAt.Format("2006-01-02 15:04"))
+		if n.Read {
+			output.WriteString("  " + when + "  " + ui.DimStyle.Render(line) + "\n")
+		} else {
+			output.WriteString("  " + when + "  " + ui.ValueStyle.Render(line) + "\n")
+		}
+	}
+	output.WriteString("\n" + ui.FormatUsage("Usage: notifications [all|clear]"))
+
+	if err := h.notificationService.MarkRead(h.user.ID, notifications); err != nil {
+		return &CommandResult{Error: fmt.Errorf("notifications: %w", err)}
+	}
+	return &CommandResult{Output: output.String()}
+}
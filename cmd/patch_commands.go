@@ -92,6 +92,23 @@ func (h *CommandHandler) handlePatchList() *CommandResult {
 		output.WriteString("\n")
 	}
 
+	// Upgrade tokens waiting to be applied
+	if tokens, err := h.shopService.GetUpgradeTokens(h.user.ID); err == nil && len(tokens) > 0 {
+		counts := map[string]int{}
+		var names []string
+		for _, token := range tokens {
+			if counts[token.Name] == 0 {
+				names = append(names, token.Name)
+			}
+			counts[token.Name]++
+		}
+		output.WriteString(ui.FormatSectionHeader("Upgrade Tokens:", "🎟️"))
+		for _, name := range names {
+			output.WriteString(ui.FormatListBullet(fmt.Sprintf("%s x%d", name, counts[name])))
+		}
+		output.WriteString("\n")
+	}
+
 	output.WriteString(ui.FormatUsage("Usage: patch <tool> - View upgrade options for a tool"))
 
 	return &CommandResult{Output: output.String()}
@@ -196,8 +213,14 @@ func (h *CommandHandler) handlePatchApply(toolName, upgradeTypeStr string) *Comm
 	}
 	cost := patch.CalculateUpgradeCost(upgradeType, currentCount)
 
-	// Apply upgrade
-	if err := h.upgradeService.ApplyUpgrade(h.user.ID, toolName, upgradeType); err != nil {
+	// Apply upgrade, with an upgrade token if the user holds one
+	token, err := h.shopService.UseUpgradeToken(h.user.ID, upgradeType)
+	if err == nil {
+		if err := h.upgradeService.ApplyFreeUpgrade(h.user.ID, toolName, upgradeType); err != nil {
+			h.shopService.ReturnUpgradeToken(token)
+			return &CommandResult{Error: err}
+		}
+	} else if err := h.upgradeService.ApplyUpgrade(h.user.ID, toolName, upgradeType); err != nil {
 		return &CommandResult{Error: err}
 	}
 
@@ -207,7 +230,11 @@ func (h *CommandHandler) handlePatchApply(toolName, upgradeTypeStr string) *Comm
 
 	var output strings.Builder
 	output.WriteString(ui.SuccessStyle.Render("✅ "+def.Name+" applied to "+toolName) + "\n")
-	output.WriteString(ui.FormatKeyValuePair("Cost:", fmt.Sprintf("%.0f crypto", cost)) + "\n")
+	if token != nil {
+		output.WriteString(ui.FormatKeyValuePair("Cost:", "1 "+token.Name) + "\n")
+	} else {
+		output.WriteString(ui.FormatKeyValuePair("Cost:", fmt.Sprintf("%.0f crypto", cost)) + "\n")
+	}
 	output.WriteString(ui.FormatKeyValuePair("Tool version:", fmt.Sprintf("%d", toolState.Version)) + "\n")
 
 	// Show new stats
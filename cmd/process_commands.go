@@ -0,0 +1,417 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+
+	"terminal-sh/models"
+	"terminal-sh/services"
+	"terminal-sh/ui"
+
+	"github.com/google/uuid"
+)
+
+// processKind is what a line in a process list is.
+type processKind int
+
+const (
+	systemProcess  processKind = iota // init, cron and the service daemons
+	sessionProcess                    // A player's login shell
+	jobProcess                        // One of the user's background jobs
+	minerProcess                      // A player's crypto miner
+)
+
+// hostProcess is a line in a process list.
+type hostProcess struct {
+	user    string
+	pid     int
+	cpu     float64 // Percent of the host's CPU
+	command string
+	kind    processKind
+	own     bool                // Started by the user
+	session *models.ServerLog   // The connection behind a session, from auth.log
+	miner   *models.ActiveMiner // The miner behind a miner process
+}
+
+// handlePS lists the user's processes on the machine they're on: their shell, their jobs and
+// their miners. With aux (or -e, -ef) it lists every process on the machine, including other
+// players' sessions and miners if the connected role can see them.
+func (h *CommandHandler) handlePS(args []string) *CommandResult {
+	if h.user == nil {
+		return &CommandResult{Error: fmt.Errorf("not authenticated")}
+	}
+
+	all := false
+	for _, arg := range args {
+		switch strings.TrimPrefix(arg, "-") {
+		case "aux", "ax", "e", "ef", "A":
+			all = true
+		default:
+			return &CommandResult{Error: fmt.Errorf("usage: ps [aux]")}
+		}
+	}
+
+	notice := h.processMining()
+	var processes []hostProcess
+	if h.currentServerPath == "" {
+		processes = h.homeProcesses()
+	} else {
+		server, err := h.serverService.GetServerByPath(h.currentServerPath)
+		if err != nil {
+			return &CommandResult{Output: notice, Error: err}
+		}
+		if processes, err = h.serverProcessList(server); err != nil {
+			return &CommandResult{Output: notice, Error: err}
+		}
+	}
+	processes = append(processes, hostProcess{user: h.loginName(), pid: h.processes.NextPID(),
+		command: strings.TrimSpace("ps " + strings.Join(args, " ")), kind: jobProcess, own: true})
+
+	var output strings.Builder
+	output.WriteString(notice)
+	if !all {
+		output.WriteString(fmt.Sprintf("%7s %-8s %s\n", "PID", "TTY", "CMD"))
+		for _, p := range processes {
+			if !p.own {
+				continue
+			}
+			tty := "pts/0"
+			if p.kind == minerProcess {
+				tty = "?"
+			}
+			output.WriteString(fmt.Sprintf("%7d %-8s %s\n", p.pid, tty, p.command))
+		}
+		return &CommandResult{Output: output.String()}
+	}
+
+	hidden := 0
+	output.WriteString(fmt.Sprintf("%-8s %6s %5s %s\n", "USER", "PID", "%CPU", "COMMAND"))
+	for _, p := range processes {
+		if !p.own && p.kind != systemProcess && !h.canSeeAllProcesses() {
+			hidden++
+			continue
+		}
+		line := fmt.Sprintf("%-8s %6d %5.1f %s", truncateUser(p.user), p.pid, p.cpu, p.command)
+		if p.kind == minerProcess {
+			line = ui.WarningStyle.Render(line)
+		}
+		output.WriteString(line + "\n")
+	}
+	if hidden > 0 {
+		output.WriteString(ui.DimStyle.Render(fmt.Sprintf("(%d process(es) of other users hidden from %s)", hidden, h.loginName())) + "\n")
+	}
+	return &CommandResult{Output: output.String()}
+}
+
+// handleJOBS lists the session's background jobs.
+func (h *CommandHandler) handleJOBS(args []string) *CommandResult {
+	showPIDs := len(args) == 1 && args[0] == "-l"
+	if len(args) > 0 && !showPIDs {
+		return &CommandResult{Error: fmt.Errorf("usage: jobs [-l]")}
+	}
+
+	var output strings.Builder
+	for _, job := range h.processes.Jobs() {
+		// Finished jobs are reported with their output before the next prompt
+		if job.State() == JobDone {
+			continue
+		}
+		pid := ""
+		if showPIDs {
+			pid = fmt.Sprintf("%d ", job.PID)
+		}
+		output.WriteString(fmt.Sprintf("[%d]  %s%-7s %3.0f%%  %s &", job.ID, pid, job.State(), job.Progress()*100, job.Command))
+		if job.ServerPath != h.currentServerPath {
+			output.WriteString(ui.DimStyle.Render("  (on " + jobHost(job) + ")"))
+		}
+		output.WriteString("\n")
+	}
+	return &CommandResult{Output: output.String()}
+}
+
+// handleFG brings a background job to the foreground.
+func (h *CommandHandler) handleFG(args []string) *CommandResult {
+	if len(args) > 1 {
+		return &CommandResult{Error: fmt.Errorf("usage: fg [%%job]")}
+	}
+	spec := ""
+	if len(args) == 1 {
+		spec = args[0]
+	}
+	job, err := h.processes.Find(spec)
+	if err != nil {
+		return &CommandResult{Error: fmt.Errorf("fg: %w", err)}
+	}
+	return &CommandResult{Foreground: job}
+}
+
+// handleKILL kills jobs and processes. The user can kill their own jobs anywhere, and their
+// own miners; on a server, root and admins can also kill other players' miners and sessions.
+func (h *CommandHandler) handleKILL(args []string) *CommandResult {
+	if h.user == nil {
+		return &CommandResult{Error: fmt.Errorf("not authenticated")}
+	}
+
+	// Signals make no difference: everything is killed outright
+	var targets []string
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			targets = append(targets, arg)
+		}
+	}
+	if len(targets) == 0 {
+		return &CommandResult{Error: fmt.Errorf("usage: kill [-9] <pid|%%job>...")}
+	}
+
+	notice := h.processMining()
+	var output strings.Builder
+	output.WriteString(notice)
+	var errs []error
+	for _, target := range targets {
+		message, err := h.killProcess(target)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		output.WriteString(message + "\n")
+	}
+	return &CommandResult{Output: output.String(), Error: errors.Join(errs...)}
+}
+
+// killProcess kills one job or process and describes what happened.
+func (h *CommandHandler) killProcess(target string) (string, error) {
+	if strings.HasPrefix(target, "%") {
+		job, err := h.processes.Find(target)
+		if err != nil {
+			return "", fmt.Errorf("kill: %w", err)
+		}
+		return h.killJob(job)
+	}
+
+	pid, err := strconv.Atoi(target)
+	if err != nil {
+		return "", fmt.Errorf("kill: %s: arguments must be process or job IDs", target)
+	}
+	if job := h.processes.FindPID(pid); job != nil && job.ServerPath == h.currentServerPath {
+		return h.killJob(job)
+	}
+	if h.currentServerPath == "" {
+		return "", fmt.Errorf("kill: (%d) - No such process", pid)
+	}
+
+	server, err := h.serverService.GetServerByPath(h.currentServerPath)
+	if err != nil {
+		return "", err
+	}
+	processes, err := h.serverProcessList(server)
+	if err != nil {
+		return "", err
+	}
+	for _, p := range processes {
+		if p.pid != pid {
+			continue
+		}
+		switch {
+		case p.kind == sessionProcess && p.own:
+			return "", fmt.Errorf("kill: (%d) - that's your own shell, use exit", pid)
+		case p.kind == minerProcess && p.own:
+			lost, err := h.miningService.StopMining(h.user.ID, server.IP)
+			if err != nil {
+				return "", fmt.Errorf("kill: %w", err)
+			}
+			return formatKilledMiner(p, "your", lost), nil
+		case p.kind == systemProcess || !h.canSeeAllProcesses():
+			return "", fmt.Errorf("kill: (%d) - Operation not permitted", pid)
+		case p.kind == minerProcess:
+			lost, err := h.miningService.KillMiner(p.miner)
+			if err != nil {
+				return "", fmt.Errorf("kill: %w", err)
+			}
+			h.serverLogService.LogSystem(server.IP, fmt.Sprintf("%s: killed process %s (pid %d)", h.loginName(), services.MinerProcess, pid))
+			h.notificationService.Notify(p.miner.UserID, models.NotificationAdmin, fmt.Sprintf("%s on %s killed your miner", h.loginName(), server.IP))
+			return formatKilledMiner(p, p.user+"'s", lost), nil
+		case p.kind == sessionProcess:
+			if err := h.sysAdminService.KickSession(p.session, h.loginName()); err != nil {
+				return "", fmt.Errorf("kill: %w", err)
+			}
+			h.serverLogService.LogSystem(server.IP, fmt.Sprintf("%s: killed the session of %s from %s", h.loginName(), p.session.Username, p.session.SourceIP))
+			h.notificationService.Notify(*p.session.UserID, models.NotificationAdmin, fmt.Sprintf("%s on %s killed your session", h.loginName(), server.IP))
+			return ui.SuccessStyle.Render(fmt.Sprintf("Killed %s's session (pid %d).", p.user, pid)) +
+				ui.DimStyle.Render(" They're dropped off the server on their next command."), nil
+		}
+	}
+	return "", fmt.Errorf("kill: (%d) - No such process", pid)
+}
+
+// killJob kills one of the session's jobs before its operation runs, so it has no effect.
+func (h *CommandHandler) killJob(job *Job) (string, error) {
+	if err := h.processes.Kill(job); err != nil {
+		return "", fmt.Errorf("kill: %%%d: %w", job.ID, err)
+	}
+	return FormatJobLine(*job), nil
+}
+
+// formatKilledMiner describes a miner that was killed, and what its owner lost short of a block.
+func formatKilledMiner(p hostProcess, owner string, lost float64) string {
+	message := ui.SuccessStyle.Render(fmt.Sprintf("Killed %s %s (pid %d).", owner, services.MinerProcess, p.pid))
+	if lost >= 0.01 {
+		message += ui.DimStyle.Render(fmt.Sprintf(" %.2f crypto short of a block was lost.", lost))
+	}
+	return message
+}
+
+// homeProcesses returns the processes on the user's home machine: their shell and the jobs
+// they started there.
+func (h *CommandHandler) homeProcesses() []hostProcess {
+	processes := []hostProcess{{user: h.loginName(), pid: 1, command: "bash", kind: sessionProcess, own: true}}
+	return append(processes, h.jobProcesses()...)
+}
+
+// serverProcessList returns every process on a server: its system processes, the user's
+// shell and jobs, other players' sessions, and everyone's miners.
+func (h *CommandHandler) serverProcessList(server *models.Server) ([]hostProcess, error) {
+	processes := serverProcesses(server)
+	processes = append(processes, hostProcess{user: h.loginName(), pid: sessionPID(h.user.ID, server.IP),
+		command: "-bash", kind: sessionProcess, own: true})
+	processes = append(processes, h.jobProcesses()...)
+
+	sessions, err := h.serverLogService.ActiveSessions(server.IP)
+	if err != nil {
+		return nil, err
+	}
+	tty := 1
+	for i := range sessions {
+		session := &sessions[i]
+		if *session.UserID == h.user.ID {
+			continue
+		}
+		processes = append(processes, hostProcess{user: session.Username, pid: sessionPID(*session.UserID, server.IP),
+			command: sessionCommand(session.ServiceType, session.Username, tty), kind: sessionProcess, session: session})
+		tty++
+	}
+
+	miners, err := h.miningService.GetServerMiners(server.IP)
+	if err != nil {
+		return nil, err
+	}
+	for i := range miners {
+		miner := &miners[i]
+		owner := "unknown"
+		if u, err := h.userService.GetUserByID(miner.UserID); err == nil {
+			owner = u.Username
+		}
+		cpu := 0.0
+		if server.Resources.CPU > 0 {
+			cpu = miner.ResourceUsage.CPU / float64(server.Resources.CPU) * 100
+		}
+		processes = append(processes, hostProcess{user: owner, pid: services.MinerPID(miner), cpu: cpu,
+			command: services.MinerProcess + " --donate-level 0 -o pool.local:3333", kind: minerProcess,
+			own: miner.UserID == h.user.ID, miner: miner})
+	}
+	return processes, nil
+}
+
+// jobProcesses returns the session's background jobs running on the current machine.
+func (h *CommandHandler) jobProcesses() []hostProcess {
+	var processes []hostProcess
+	for _, job := range h.processes.Jobs() {
+		if job.ServerPath == h.currentServerPath && job.State() != JobDone {
+			processes = append(processes, hostProcess{user: h.loginName(), pid: job.PID,
+				command: job.Command, kind: jobProcess, own: true})
+		}
+	}
+	return processes
+}
+
+// loginName returns who the user is logged in as on the current machine.
+func (h *CommandHandler) loginName() string {
+	if h.currentServerPath == "" {
+		return h.user.Username
+	}
+	if h.currentRole != nil {
+		return h.currentRole.Username
+	}
+	return "root"
+}
+
+// canSeeAllProcesses reports whether the connected role can see and kill other users'
+// processes. Servers mount /proc with hidepid, so only root and admins can.
+func (h *CommandHandler) canSeeAllProcesses() bool {
+	if h.currentRole == nil {
+		return true // Backdoor sessions are root
+	}
+	return h.currentRole.IsRoot || h.currentRole.RoleType == models.RoleTypeAdmin
+}
+
+// serverProcesses returns the system processes a server always runs: init, cron, and a daemon
+// for each of its services.
+func serverProcesses(server *models.Server) []hostProcess {
+	processes := []hostProcess{
+		{user: "root", pid: 1, command: "/sbin/init"},
+		{user: "root", pid: 412, command: "/usr/sbin/cron -f"},
+		{user: "syslog", pid: 433, cpu: 0.1, command: "/usr/sbin/rsyslogd -n"},
+	}
+	for i, service := range server.Services {
+		processes = append(processes, hostProcess{user: "root", pid: 500 + i*17, cpu: 0.1, command: serviceDaemon(service.Name)})
+	}
+	return processes
+}
+
+// serviceDaemon returns the command line of the daemon behind a service.
+func serviceDaemon(name string) string {
+	switch name {
+	case "ssh":
+		return "/usr/sbin/sshd -D"
+	case "ftp":
+		return "/usr/sbin/vsftpd"
+	case "telnet":
+		return "/usr/sbin/in.telnetd"
+	case "http", "https":
+		return "nginx: master process /usr/sbin/nginx"
+	case "smb":
+		return "/usr/sbin/smbd --foreground"
+	case "mysql":
+		return "/usr/sbin/mysqld"
+	}
+	return "/usr/sbin/" + name + "d"
+}
+
+// sessionCommand returns the command line a player's session shows up as, by the service
+// they connected with.
+func sessionCommand(serviceType, username string, tty int) string {
+	switch serviceType {
+	case "ftp":
+		return "vsftpd: " + username
+	case "telnet":
+		return fmt.Sprintf("login -- %s pts/%d", username, tty)
+	}
+	return fmt.Sprintf("sshd: %s@pts/%d", username, tty)
+}
+
+// sessionPID returns the process ID a player's login shell shows up under on a server.
+func sessionPID(userID uuid.UUID, serverIP string) int {
+	h := fnv.New32a()
+	h.Write(userID[:])
+	h.Write([]byte(serverIP))
+	return 1000 + int(h.Sum32()%30000)
+}
+
+// jobHost returns the machine a job runs on, for jobs to show.
+func jobHost(job Job) string {
+	if job.ServerPath == "" {
+		return "home"
+	}
+	parts := strings.Split(job.ServerPath, ".localNetwork.")
+	return parts[len(parts)-1]
+}
+
+// truncateUser shortens a user name to fit ps's USER column, like ps does.
+func truncateUser(name string) string {
+	if len(name) > 8 {
+		return name[:7] + "+"
+	}
+	return name
+}
@@ -0,0 +1,459 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"terminal-sh/filesystem"
+	"terminal-sh/models"
+	"terminal-sh/services"
+	"terminal-sh/ui"
+)
+
+// anonymousUser is the user name of anonymous FTP logins.
+const anonymousUser = "anonymous"
+
+// ConnectBanner returns what a client prints while connecting to a service on a server, before
+// the login message: the telnet preamble and the service's greeting.
+func (h *CommandHandler) ConnectBanner(serverPath, serviceType string) string {
+	server, err := h.serverService.GetServerByPath(serverPath)
+	if err != nil {
+		return ""
+	}
+	svc := services.FindService(server, serviceType)
+	if svc == nil {
+		return ""
+	}
+	banner := services.ServiceBanner(*svc)
+	switch serviceType {
+	case "telnet":
+		return fmt.Sprintf("Trying %s...\nConnected to %s.\nEscape character is '^]'.\n%s\n\n", server.IP, server.IP, banner)
+	case "ftp":
+		return fmt.Sprintf("Connected to %s.\n%s\n", server.IP, banner)
+	}
+	return ""
+}
+
+// anonymousFTPRole returns the role of an anonymous FTP login: a guest locked into FTPRoot.
+func anonymousFTPRole() *services.ConnectionRole {
+	return &services.ConnectionRole{
+		Username:     anonymousUser,
+		RoleType:     models.RoleTypeGuest,
+		HomeDir:      services.FTPRoot,
+		PromptChar:   "$",
+		AccessMethod: anonymousUser,
+	}
+}
+
+// addServiceFiles adds the files a server's services serve, if the server doesn't have its own:
+// a default site in the web root and a public directory for anonymous FTP.
+func addServiceFiles(vfs *filesystem.VFS, server *models.Server) {
+	if services.FindService(server, "http") != nil || services.FindService(server, "https") != nil {
+		if _, err := vfs.Stat(services.WebRoot); err != nil {
+			for name, content := range services.DefaultWebPages(server) {
+				vfs.EnsureDirectoryAndCreateFile(services.WebRoot, name, content)
+			}
+		}
+	}
+	if ftp := services.FindService(server, "ftp"); ftp != nil && ftp.Anonymous {
+		if _, err := vfs.Stat(services.FTPRoot); err != nil {
+			vfs.EnsureDirectoryAndCreateFile(services.FTPRoot+"/pub", "README", "Public file area. Uploads go in /incoming.\n")
+		}
+		// Anyone can drop files in incoming, but not list or remove other people's
+		vfs.AddSystemDir(services.FTPRoot+"/incoming", 01733)
+	}
+}
+
+// ftpSession reports whether the player is in an FTP session, which only has FTP's commands.
+// FTP reached through a backdoor is a shell spawned through the daemon, so it isn't limited.
+func (h *CommandHandler) ftpSession() bool {
+	if h.currentServerPath == "" || h.currentServiceType != "ftp" {
+		return false
+	}
+	return h.currentRole == nil || h.currentRole.AccessMethod != "backdoor"
+}
+
+// dispatchFTP routes a command in an FTP session.
+func (h *CommandHandler) dispatchFTP(cmd string, args []string) *CommandResult {
+	switch cmd {
+	case "ls", "dir":
+		for _, arg := range args {
+			if !strings.HasPrefix(arg, "-") && !h.ftpPathAllowed(arg) {
+				return &CommandResult{Error: fmt.Errorf("550 Permission denied")}
+			}
+		}
+		return h.handleLS(args)
+	case "cd":
+		if len(args) > 0 && !h.ftpPathAllowed(args[0]) {
+			return &CommandResult{Error: fmt.Errorf("550 Failed to change directory")}
+		}
+		return h.handleCD(args)
+	case "pwd":
+		return h.handlePWD()
+	case "get":
+		if len(args) != 1 {
+			return &CommandResult{Error: fmt.Errorf("usage: get <remote-file>")}
+		}
+		if !h.ftpPathAllowed(args[0]) {
+			return &CommandResult{Error: fmt.Errorf("550 Failed to open file")}
+		}
+		return h.handleDOWNLOAD(args)
+	case "put":
+		if len(args) < 1 || len(args) > 2 {
+			return &CommandResult{Error: fmt.Errorf("usage: put <local-file> [remote-file]")}
+		}
+		if len(args) == 2 && !h.ftpPathAllowed(args[1]) {
+			return &CommandResult{Error: fmt.Errorf("553 Could not create file")}
+		}
+		if len(args) == 1 && !h.ftpPathAllowed(".") {
+			return &CommandResult{Error: fmt.Errorf("553 Could not create file")}
+		}
+		return h.handleUPLOAD(args)
+	case "clear":
+		return h.handleCLEAR()
+	case "bye", "quit", "exit":
+		return h.handleEXIT()
+	case "help", "?":
+		return &CommandResult{Output: "Commands may be abbreviated.  Commands are:\n\n" + strings.Join(services.FTPCommands, "  ") + "\n"}
+	}
+	return &CommandResult{Error: fmt.Errorf("?Invalid command (%s). This is an FTP session - type 'help' for FTP commands", cmd)}
+}
+
+// ftpPathAllowed reports whether an FTP session may touch p. Anonymous users are locked into
+// the FTP root.
+func (h *CommandHandler) ftpPathAllowed(p string) bool {
+	if h.currentRole == nil || h.currentRole.AccessMethod != anonymousUser {
+		return true
+	}
+	if !strings.HasPrefix(p, "/") {
+		p = path.Join(h.vfs.GetCurrentPath(), p)
+	}
+	p = path.Clean(p)
+	return p == services.FTPRoot || strings.HasPrefix(p, services.FTPRoot+"/")
+}
+
+// fetchURL sends an HTTP request to the server in a URL, as seen from the player's location.
+// Returns the server, the request and its response.
+func (h *CommandHandler) fetchURL(cmdName, rawURL, method string, data url.Values) (*models.Server, services.WebRequest, services.WebResponse, error) {
+	host, pagePath, query, err := services.ParseURL(rawURL)
+	if err != nil {
+		return nil, services.WebRequest{}, services.WebResponse{}, fmt.Errorf("%s: %w", cmdName, err)
+	}
+	server, err := h.serverService.GetServerByIP(host)
+	if err != nil {
+		return nil, services.WebRequest{}, services.WebResponse{}, fmt.Errorf("%s: Could not resolve host: %s", cmdName, host)
+	}
+	hops, serverPath, err := h.routeTo(server.IP)
+	if err != nil {
+		return nil, services.WebRequest{}, services.WebResponse{}, fmt.Errorf("%s: Failed to connect to %s: %w", cmdName, host, err)
+	}
+	if services.FindService(server, "http") == nil && services.FindService(server, "https") == nil {
+		return nil, services.WebRequest{}, services.WebResponse{}, fmt.Errorf("%s: Failed to connect to %s port 80: Connection refused", cmdName, host)
+	}
+	serverVFS, err := h.CreateServerVFS(serverPath)
+	if err != nil {
+		return nil, services.WebRequest{}, services.WebResponse{}, fmt.Errorf("%s: %w", cmdName, err)
+	}
+
+	req := services.WebRequest{Method: method, Path: pagePath, Values: query}
+	if method == http.MethodPost {
+		req.Values = data
+	}
+	resp := services.ServeHTTP(server, req, serverVFS.ReadFileAtPath)
+	h.logRoute(hops, "Forwarded http request")
+	return server, req, resp, nil
+}
+
+// handleCURL handles the curl command: raw HTTP requests to a server's web service
+func (h *CommandHandler) handleCURL(args []string) *CommandResult {
+	if h.user == nil {
+		return &CommandResult{Error: fmt.Errorf("not authenticated")}
+	}
+	const usage = "usage: curl [-i] [-I] [-d <field=value&...>] <url>"
+
+	var rawURL, data string
+	var includeHeaders, headOnly, post bool
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-i":
+			includeHeaders = true
+		case "-I":
+			headOnly = true
+		case "-d", "--data":
+			if i+1 >= len(args) {
+				return &CommandResult{Error: fmt.Errorf(usage)}
+			}
+			i++
+			data, post = args[i], true
+		default:
+			if strings.HasPrefix(args[i], "-") || rawURL != "" {
+				return &CommandResult{Error: fmt.Errorf(usage)}
+			}
+			rawURL = args[i]
+		}
+	}
+	if rawURL == "" {
+		return &CommandResult{Error: fmt.Errorf(usage)}
+	}
+
+	method := http.MethodGet
+	values := url.Values{}
+	if post {
+		method = http.MethodPost
+		parsed, err := url.ParseQuery(data)
+		if err != nil {
+			return &CommandResult{Error: fmt.Errorf("curl: invalid form data: %w", err)}
+		}
+		values = parsed
+	}
+	_, _, resp, err := h.fetchURL("curl", rawURL, method, values)
+	if err != nil {
+		return &CommandResult{Error: err}
+	}
+
+	var output strings.Builder
+	if includeHeaders || headOnly {
+		output.WriteString(fmt.Sprintf("HTTP/1.1 %d %s\n", resp.Status, http.StatusText(resp.Status)))
+		output.WriteString("Server: " + resp.Server + "\n")
+		output.WriteString("Content-Type: text/html; charset=UTF-8\n")
+		output.WriteString(fmt.Sprintf("Content-Length: %d\n\n", len(resp.Body)))
+	}
+	if !headOnly {
+		output.WriteString(resp.Body)
+	}
+	return &CommandResult{Output: output.String()}
+}
+
+// handleBROWSE handles the browse command: renders a web page as text with its links and forms
+func (h *CommandHandler) handleBROWSE(args []string) *CommandResult {
+	if h.user == nil {
+		return &CommandResult{Error: fmt.Errorf("not authenticated")}
+	}
+	if len(args) != 1 {
+		return &CommandResult{Error: fmt.Errorf("usage: browse <url>")}
+	}
+	server, req, resp, err := h.fetchURL("browse", args[0], http.MethodGet, nil)
+	if err != nil {
+		return &CommandResult{Error: err}
+	}
+
+	var output strings.Builder
+	title := services.PageTitle(resp.Body)
+	if title == "" {
+		title = "http://" + server.IP + req.Path
+	}
+	output.WriteString(ui.FormatSectionHeader(title, "🌐"))
+	if resp.Status != http.StatusOK {
+		output.WriteString(ui.ErrorStyle.Render(fmt.Sprintf("%d %s", resp.Status, http.StatusText(resp.Status))) + "\n")
+	}
+	if text := services.PageText(resp.Body); text != "" {
+		output.WriteString(text + "\n")
+	}
+
+	if links := services.ParseLinks(resp.Body); len(links) > 0 {
+		output.WriteString("\n" + ui.LabelStyle.Render("Links:") + "\n")
+		for i, link := range links {
+			output.WriteString(fmt.Sprintf("  [%d] %s %s\n", i+1, link.Text, ui.DimStyle.Render(resolveLink(server.IP, req.Path, link.Href))))
+		}
+	}
+	if forms := services.ParseForms(resp.Body, req.Path); len(forms) > 0 {
+		output.WriteString("\n" + ui.LabelStyle.Render("Forms:") + "\n")
+		for _, form := range forms {
+			output.WriteString(fmt.Sprintf("  %s %s  fields: %s\n", ui.AccentStyle.Render(form.Method), resolveLink(server.IP, req.Path, form.Action),
+				ui.ValueStyle.Render(strings.Join(form.Fields, ", "))))
+		}
+		output.WriteString(ui.DimStyle.Render("  Submit with curl, e.g. curl -d 'field=value' <url>") + "\n")
+	}
+	return &CommandResult{Output: output.String()}
+}
+
+// resolveLink turns a link on a page into a full URL.
+func resolveLink(host, pagePath, href string) string {
+	if strings.Contains(href, "://") {
+		return href
+	}
+	if !strings.HasPrefix(href, "/") {
+		href = path.Join(path.Dir(pagePath), href)
+	}
+	return "http://" + host + href
+}
+
+// injectionTarget resolves the target of sql_injector or xss_exploit. A bare IP targets the
+// server's web service; a URL must point at a page with a form to inject into.
+// Returns the target's IP.
+func (h *CommandHandler) injectionTarget(toolName, target string) (string, error) {
+	if !strings.Contains(target, "/") {
+		return target, nil
+	}
+	server, req, resp, err := h.fetchURL(toolName, target, http.MethodGet, nil)
+	if err != nil {
+		return "", err
+	}
+	if resp.Status != http.StatusOK {
+		return "", fmt.Errorf("%s: %s: %d %s", toolName, target, resp.Status, http.StatusText(resp.Status))
+	}
+	if len(services.ParseForms(resp.Body, req.Path)) == 0 {
+		return "", fmt.Errorf("%s: no forms on %s - nothing to inject into (try 'browse' to find one)", toolName, target)
+	}
+	return server.IP, nil
+}
+
+// handleMYSQL handles the mysql command: read-only queries against a server's databases
+func (h *CommandHandler) handleMYSQL(args []string) *CommandResult {
+	if h.user == nil {
+		return &CommandResult{Error: fmt.Errorf("not authenticated")}
+	}
+	const usage = "usage: mysql [-h <host>] [-u <user>] [-p<password>] [database] -e \"<query>\""
+
+	host, query, database := "", "", ""
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "-h" || arg == "-u" || arg == "-e":
+			if i+1 >= len(args) {
+				return &CommandResult{Error: fmt.Errorf(usage)}
+			}
+			i++
+			if arg == "-h" {
+				host = args[i]
+			} else if arg == "-e" {
+				query = args[i]
+			}
+		case strings.HasPrefix(arg, "-p"):
+			// Passwords are checked against the player's stolen credentials, not typed ones
+		case strings.HasPrefix(arg, "-"):
+			return &CommandResult{Error: fmt.Errorf(usage)}
+		default:
+			database = arg
+		}
+	}
+	if query == "" {
+		return &CommandResult{Error: fmt.Errorf("mysql: interactive mode isn't supported - pass a query with -e\n%s", usage)}
+	}
+
+	server, serverPath, err := h.mysqlServer(host)
+	if err != nil {
+		return &CommandResult{Error: err}
+	}
+	serverVFS := h.vfs
+	if serverPath != h.currentServerPath {
+		if serverVFS, err = h.CreateServerVFS(serverPath); err != nil {
+			return &CommandResult{Error: fmt.Errorf("mysql: %w", err)}
+		}
+	}
+	databases := loadDatabases(serverVFS, server)
+
+	var output strings.Builder
+	for _, stmt := range strings.Split(query, ";") {
+		stmt = strings.TrimSpace(stmt)
+		if stmt == "" {
+			continue
+		}
+		upper := strings.ToUpper(stmt)
+		switch {
+		case upper == "SHOW DATABASES":
+			result := &services.SQLResult{Columns: []string{"Database"}}
+			for _, name := range services.SortedDatabaseNames(databases) {
+				result.Rows = append(result.Rows, []string{name})
+			}
+			output.WriteString(services.FormatSQLResult(result))
+			continue
+		case strings.HasPrefix(upper, "USE "):
+			database = strings.Trim(strings.TrimSpace(stmt[4:]), "`")
+			if databases[database] == nil {
+				return &CommandResult{Output: output.String(), Error: fmt.Errorf("ERROR 1049 (42000): Unknown database '%s'", database)}
+			}
+			output.WriteString("Database changed\n")
+			continue
+		}
+
+		db := databases[database]
+		if database == "" {
+			// Without a database selected, queries go to the only one there is
+			if len(databases) != 1 {
+				return &CommandResult{Output: output.String(), Error: fmt.Errorf("ERROR 1046 (3D000): No database selected")}
+			}
+			for _, only := range databases {
+				db = only
+			}
+		} else if db == nil {
+			return &CommandResult{Output: output.String(), Error: fmt.Errorf("ERROR 1049 (42000): Unknown database '%s'", database)}
+		}
+		result, err := db.Query(stmt)
+		if err != nil {
+			return &CommandResult{Output: output.String(), Error: err}
+		}
+		output.WriteString(services.FormatSQLResult(result))
+	}
+	return &CommandResult{Output: output.String()}
+}
+
+// mysqlServer finds the database server for mysql -h and checks the player may log in to it:
+// they're on the server, have credentials or a backdoor for its mysql service, or have
+// exploited its SQL injection. Without a host, the current server is used.
+func (h *CommandHandler) mysqlServer(host string) (*models.Server, string, error) {
+	if host == "" || host == "localhost" || host == "127.0.0.1" {
+		if h.currentServerPath == "" {
+			return nil, "", fmt.Errorf("ERROR 2002 (HY000): Can't connect to local MySQL server through socket '/var/run/mysqld/mysqld.sock'")
+		}
+		server, err := h.serverService.GetServerByPath(h.currentServerPath)
+		if err != nil {
+			return nil, "", fmt.Errorf("mysql: %w", err)
+		}
+		if services.FindService(server, "mysql") == nil {
+			return nil, "", fmt.Errorf("ERROR 2002 (HY000): Can't connect to local MySQL server through socket '/var/run/mysqld/mysqld.sock'")
+		}
+		return server, h.currentServerPath, nil
+	}
+
+	server, err := h.serverService.GetServerByIP(host)
+	if err != nil {
+		return nil, "", fmt.Errorf("ERROR 2005 (HY000): Unknown MySQL server host '%s'", host)
+	}
+	_, serverPath, err := h.routeTo(server.IP)
+	if errors.Is(err, services.ErrNoRoute) {
+		return nil, "", fmt.Errorf("ERROR 2003 (HY000): Can't connect to MySQL server on '%s' (%v)", host, err)
+	} else if err != nil {
+		return nil, "", fmt.Errorf("mysql: %w", err)
+	}
+	if services.FindService(server, "mysql") == nil {
+		return nil, "", fmt.Errorf("ERROR 2003 (HY000): Can't connect to MySQL server on '%s' (111)", host)
+	}
+	if hostName(ConnectedHost{ServerPath: h.currentServerPath}) == server.IP {
+		return server, h.currentServerPath, nil
+	}
+	allowed := h.credentialService != nil && h.credentialService.GetAccessInfo(h.user.ID, serverPath, "mysql").HasAccess
+	if !allowed && h.exploitationService != nil {
+		allowed = h.exploitationService.IsVulnerabilityExploited(h.user.ID, serverPath, "mysql", "sql_injection")
+	}
+	if !allowed {
+		return nil, "", fmt.Errorf("ERROR 1045 (28000): Access denied for user '%s'@'%s' (need mysql credentials, or exploit its SQL injection first)", h.user.Username, h.GetEffectiveSourceIP())
+	}
+	return server, serverPath, nil
+}
+
+// loadDatabases loads a server's databases from the dumps in its MySQL data directory. A server
+// without any gets its generated production database.
+func loadDatabases(vfs *filesystem.VFS, server *models.Server) map[string]*services.SQLDatabase {
+	databases := make(map[string]*services.SQLDatabase)
+	if node, err := vfs.Stat(services.MySQLDataDir); err == nil && node.IsDir {
+		for name, child := range node.Children {
+			if child.IsDir || !strings.HasSuffix(name, ".sql") {
+				continue
+			}
+			if content, err := vfs.ReadFileAtPath(filepath.Join(services.MySQLDataDir, name)); err == nil {
+				dbName := strings.TrimSuffix(name, ".sql")
+				databases[dbName] = services.ParseSQLDump(dbName, content)
+			}
+		}
+	}
+	if len(databases) == 0 {
+		databases["production"] = services.ParseSQLDump("production", services.GenerateDatabaseDump(server))
+	}
+	return databases
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"terminal-sh/models"
+	"terminal-sh/services"
+)
+
+func TestAnonymousFTPPut(t *testing.T) {
+	db := newTestDatabase(t)
+	handler := newTestHandler(t, db)
+	server, err := services.NewServerService(db).CreateServer("203.0.113.21", "10.21.0.1")
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	server.Services = []models.Service{{Name: "ftp", Port: 21, Anonymous: true}}
+	if err := db.Save(server).Error; err != nil {
+		t.Fatalf("failed to save server: %v", err)
+	}
+
+	homeVFS := handler.homeVFS
+	if err := homeVFS.EnsureDirectoryAndCreateFile("/home/mallory", "x.sh", "#!/bin/sh\n"); err != nil {
+		t.Fatalf("failed to create local file: %v", err)
+	}
+	role := anonymousFTPRole()
+	serverVFS, err := handler.CreateServerVFS(server.IP)
+	if err != nil {
+		t.Fatalf("failed to load server filesystem: %v", err)
+	}
+	serverVFS.SetRole(role.Username, false, role.HomeDir)
+	serverVFS.ChangeDir(services.FTPRoot)
+	handler.SwitchContext(serverVFS, server.IP, "ftp")
+	handler.SetCurrentRole(role)
+
+	result := handler.Execute("put /home/mallory/x.sh incoming")
+	if result.Error != nil || result.StartProgress == nil {
+		t.Fatalf("expected the upload to start, got %+v", result)
+	}
+	if result := result.StartProgress.Operation(); result.Error != nil {
+		t.Fatalf("expected anonymous put into incoming to work, got %v", result.Error)
+	}
+	if content, err := serverVFS.ReadFileAtPath(services.FTPRoot + "/incoming/x.sh"); err != nil || !strings.HasPrefix(content, "#!/bin/sh") {
+		t.Fatalf("expected the uploaded file in incoming, got %q (%v)", content, err)
+	}
+
+	// The rest of the tree stays read-only
+	result = handler.Execute("put /home/mallory/x.sh pub")
+	if result.Error == nil {
+		result = result.StartProgress.Operation()
+	}
+	if result.Error == nil {
+		t.Fatal("expected put into pub to be refused")
+	}
+}
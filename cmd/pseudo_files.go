@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"terminal-sh/filesystem"
+	"terminal-sh/models"
+)
+
+// addServerPseudoFiles adds the files a server generates when they're read, like the kernel does on
+// a real system: /etc/passwd and /etc/group from the server's roles, /etc/crontab from its local
+// vulnerabilities, and a few /proc entries from its resources.
+func addServerPseudoFiles(vfs *filesystem.VFS, server *models.Server) {
+	vfs.AddPseudoFile("/etc/passwd", func() string { return serverPasswd(server) })
+	vfs.AddPseudoFile("/etc/group", func() string { return serverGroups(server) })
+	vfs.AddPseudoFile("/etc/crontab", func() string { return serverCrontab(server) })
+	if vuln := findLocalVulnerability(server, "writable_path"); vuln != nil {
+		// Anyone can add commands in front of the ones root's cron job means to run
+		vfs.AddSystemDir(path.Dir(vuln.Target), 0777)
+	}
+	vfs.AddPseudoFile("/proc/version", func() string { return serverKernelVersion(server) })
+	vfs.AddPseudoFile("/proc/cpuinfo", func() string { return serverCPUInfo(server) })
+	vfs.AddPseudoFile("/proc/meminfo", func() string { return serverMemInfo(server) })
+	vfs.AddPseudoFile("/proc/uptime", func() string {
+		uptime := time.Since(server.CreatedAt).Seconds()
+		return fmt.Sprintf("%.2f %.2f\n", uptime, uptime*0.9)
+	})
+}
+
+// serverUsers returns the server's login roles in order, with root first.
+func serverUsers(server *models.Server) []models.Role {
+	users := []models.Role{{Role: "root", Type: models.RoleTypeRoot, HomeDir: "/root"}}
+	for _, role := range server.Roles {
+		if role.Role != "root" {
+			users = append(users, role)
+		}
+	}
+	return users
+}
+
+// serverPasswd formats the server's roles as /etc/passwd. Regular users get uids from 1000.
+func serverPasswd(server *models.Server) string {
+	var b strings.Builder
+	for i, role := range serverUsers(server) {
+		uid := 0
+		if i > 0 {
+			uid = 999 + i
+		}
+		home := role.HomeDir
+		if home == "" {
+			home = "/home/" + role.Role
+		}
+		shell := role.Shell
+		if shell == "" {
+			shell = "/bin/bash"
+		}
+		if role.Role == "root" {
+			fmt.Fprintf(&b, "root:x:0:0:root:%s:%s\n", home, shell)
+			b.WriteString("daemon:x:1:1:daemon:/usr/sbin:/usr/sbin/nologin\n")
+			b.WriteString("www-data:x:33:33:www-data:/var/www:/usr/sbin/nologin\n")
+			b.WriteString("sshd:x:110:65534::/run/sshd:/usr/sbin/nologin\n")
+			continue
+		}
+		fmt.Fprintf(&b, "%s:x:%d:%d:%s:%s:%s\n", role.Role, uid, uid, role.Role, home, shell)
+	}
+	return b.String()
+}
+
+// serverGroups formats the groups the server's roles belong to as /etc/group.
+func serverGroups(server *models.Server) string {
+	groups := []struct {
+		name string
+		gid  int
+	}{{"root", 0}, {"adm", 4}, {"sudo", 27}, {"shadow", 42}, {"users", 100}}
+
+	members := make(map[string][]string)
+	for _, role := range server.Roles {
+		for _, group := range role.GetGroups() {
+			members[group] = append(members[group], role.Role)
+		}
+	}
+
+	var b strings.Builder
+	for _, group := range groups {
+		fmt.Fprintf(&b, "%s:x:%d:%s\n", group.name, group.gid, strings.Join(members[group.name], ","))
+	}
+	// Every user has a private group with the same id
+	for i, role := range serverUsers(server) {
+		if i > 0 {
+			fmt.Fprintf(&b, "%s:x:%d:\n", role.Role, 999+i)
+		}
+	}
+	return b.String()
+}
+
+// serverCrontab returns /etc/crontab. Servers with a writable_path vulnerability run a cron job as
+// root that finds its command through a PATH starting with a world-writable directory.
+func serverCrontab(server *models.Server) string {
+	pathDirs := "/usr/local/sbin:/usr/local/bin:/sbin:/bin:/usr/sbin:/usr/bin"
+	var jobs strings.Builder
+	if vuln := findLocalVulnerability(server, "writable_path"); vuln != nil {
+		pathDirs = path.Dir(vuln.Target) + ":/usr/sbin:/usr/bin:/sbin:/bin"
+		fmt.Fprintf(&jobs, "*/5 *\t* * *\troot\t%s\n", path.Base(vuln.Target))
+	}
+
+	var b strings.Builder
+	b.WriteString("SHELL=/bin/sh\n")
+	fmt.Fprintf(&b, "PATH=%s\n\n", pathDirs)
+	b.WriteString("# m h dom mon dow user\tcommand\n")
+	b.WriteString("17 *\t* * *\troot\tcd / && run-parts --report /etc/cron.hourly\n")
+	b.WriteString("25 6\t* * *\troot\ttest -x /usr/sbin/anacron || ( cd / && run-parts --report /etc/cron.daily )\n")
+	b.WriteString(jobs.String())
+	return b.String()
+}
+
+// findLocalVulnerability returns the server's local vulnerability of the given type, or nil.
+func findLocalVulnerability(server *models.Server, vulnType string) *models.LocalVulnerability {
+	for i := range server.LocalVulnerabilities {
+		if server.LocalVulnerabilities[i].Type == vulnType {
+			return &server.LocalVulnerabilities[i]
+		}
+	}
+	return nil
+}
+
+// serverKernelVersion returns /proc/version. Servers with a kernel exploit run an old kernel.
+func serverKernelVersion(server *models.Server) string {
+	release := "5.15.0-91-generic"
+	for _, vuln := range server.LocalVulnerabilities {
+		if vuln.Type == "kernel_exploit" {
+			release = "4.15.0-20-generic"
+			break
+		}
+	}
+	return fmt.Sprintf("Linux version %s (buildd@lcy02-amd64-045) (gcc (Ubuntu 9.4.0-1ubuntu1~20.04.2) 9.4.0) #1-Ubuntu SMP\n", release)
+}
+
+// serverCPUInfo returns /proc/cpuinfo, with one core per 1000 units of CPU.
+func serverCPUInfo(server *models.Server) string {
+	cores := server.Resources.CPU / 1000
+	if cores < 1 {
+		cores = 1
+	}
+	mhz := server.Resources.CPU / cores
+
+	var b strings.Builder
+	for i := 0; i < cores; i++ {
+		fmt.Fprintf(&b, "processor\t: %d\n", i)
+		b.WriteString("vendor_id\t: GenuineIntel\n")
+		b.WriteString("model name\t: Intel(R) Xeon(R) CPU E5-2670 0 @ 2.60GHz\n")
+		fmt.Fprintf(&b, "cpu MHz\t\t: %d.000\n", mhz)
+		fmt.Fprintf(&b, "cpu cores\t: %d\n\n", cores)
+	}
+	return b.String()
+}
+
+// serverMemInfo returns /proc/meminfo from the server's RAM in MB.
+func serverMemInfo(server *models.Server) string {
+	total := server.Resources.RAM * 1024
+	free := total * 3 / 10
+	return fmt.Sprintf("MemTotal:       %8d kB\nMemFree:        %8d kB\nMemAvailable:   %8d kB\n",
+		total, free, total/2)
+}
@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"terminal-sh/filesystem"
+	"terminal-sh/models"
+	"terminal-sh/services"
+)
+
+func TestServerPseudoFiles(t *testing.T) {
+	server := &models.Server{
+		IP: "10.0.0.5",
+		Roles: []models.Role{
+			{Role: "root", Type: models.RoleTypeRoot, HomeDir: "/root"},
+			{Role: "alice", Type: models.RoleTypeUser, Groups: []string{"sudo"}},
+			{Role: "bob", Type: models.RoleTypeUser, HomeDir: "/srv/bob", Shell: "/bin/zsh"},
+		},
+		Resources:            models.ServerResources{CPU: 2000, RAM: 512},
+		LocalVulnerabilities: []models.LocalVulnerability{{Type: "kernel_exploit"}},
+		CreatedAt:            time.Now().Add(-time.Hour),
+	}
+	vfs := filesystem.NewVFS("root")
+	addServerPseudoFiles(vfs, server)
+
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"/etc/passwd", []string{
+			"root:x:0:0:root:/root:/bin/bash\n",
+			"alice:x:1000:1000:alice:/home/alice:/bin/bash\n",
+			"bob:x:1001:1001:bob:/srv/bob:/bin/zsh\n",
+		}},
+		{"/etc/group", []string{"sudo:x:27:alice\n", "alice:x:1000:\n"}},
+		{"/proc/version", []string{"4.15.0-20-generic"}},
+		{"/proc/cpuinfo", []string{"processor\t: 1\n", "cpu MHz\t\t: 1000.000\n"}},
+		{"/proc/meminfo", []string{"MemTotal:         524288 kB\n"}},
+		{"/proc/uptime", []string{"3600."}},
+	}
+	for _, tt := range tests {
+		content, err := vfs.ReadFileAtPath(tt.path)
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", tt.path, err)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(content, want) {
+				t.Errorf("expected %s to contain %q, got:\n%s", tt.path, want, content)
+			}
+		}
+	}
+
+	// Generated from the server each time they're read
+	server.Roles = append(server.Roles, models.Role{Role: "carol", Type: models.RoleTypeUser})
+	if content, _ := vfs.ReadFileAtPath("/etc/passwd"); !strings.Contains(content, "carol:x:1002:1002:") {
+		t.Fatalf("expected a new role in /etc/passwd, got:\n%s", content)
+	}
+
+	// Read-only and never persisted
+	if err := vfs.WriteFile("/etc/passwd", "root::0:0::/root:/bin/bash\n"); err == nil {
+		t.Fatal("expected writing /etc/passwd to fail")
+	}
+	changes := vfs.ExtractChanges()
+	if etc, ok := changes["etc"].(map[string]interface{}); ok && etc["passwd"] != nil {
+		t.Fatal("expected /etc/passwd not to be persisted")
+	}
+}
+
+func TestWritablePathHijack(t *testing.T) {
+	db := newTestDatabase(t)
+	handler := newTestHandler(t, db)
+	user := handler.user
+
+	serverService := services.NewServerService(db)
+	server, err := serverService.CreateServer("203.0.113.9", "10.9.0.1")
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	server.LocalVulnerabilities = []models.LocalVulnerability{{
+		Type:       "writable_path",
+		Target:     "/usr/local/bin/backup",
+		GrantsRoot: true,
+	}}
+	if err := db.Save(server).Error; err != nil {
+		t.Fatalf("failed to save server: %v", err)
+	}
+
+	serverVFS, err := handler.CreateServerVFS(server.IP)
+	if err != nil {
+		t.Fatalf("failed to load server filesystem: %v", err)
+	}
+	serverVFS.SetRole("user", false, "/home/user")
+	handler.SetVFS(serverVFS)
+	handler.SetCurrentServerPath(server.IP)
+	handler.SetCurrentRole(&services.ConnectionRole{Username: "user", RoleType: models.RoleTypeUser, PromptChar: "$"})
+
+	crontab, err := serverVFS.ReadFileAtPath("/etc/crontab")
+	if err != nil || !strings.Contains(crontab, "PATH=/usr/local/bin:") || !strings.Contains(crontab, "root\tbackup\n") {
+		t.Fatalf("expected root's cron job in /etc/crontab, got %q (%v)", crontab, err)
+	}
+
+	// Only the command the job runs is hijacked, and only with a shell
+	for _, args := range [][]string{
+		{"-s", "/bin/sh", "/usr/local/bin/cleanup"},
+		{"-s", "/etc/hostname", "/usr/local/bin/backup"},
+	} {
+		if result := handler.handleLN(args); result.Error != nil {
+			t.Fatalf("ln %v failed: %v", args, result.Error)
+		}
+		if handler.roleService.HasRootAccess(user.ID, server.IP) {
+			t.Fatalf("expected ln %v not to give root", args)
+		}
+		if err := serverVFS.RemoveFile(args[2]); err != nil {
+			t.Fatalf("rm %s failed: %v", args[2], err)
+		}
+	}
+
+	result := handler.handleLN([]string{"-s", "/bin/sh", "/usr/local/bin/backup"})
+	if result.Error != nil {
+		t.Fatalf("ln failed: %v", result.Error)
+	}
+	if !strings.Contains(result.Output, "PATH HIJACK SUCCESSFUL") || !handler.roleService.HasRootAccess(user.ID, server.IP) {
+		t.Fatalf("expected the hijack to give root, got:\n%s", result.Output)
+	}
+
+	// Without the vulnerability the directory is root's as usual
+	server.LocalVulnerabilities = nil
+	if err := db.Save(server).Error; err != nil {
+		t.Fatalf("failed to save server: %v", err)
+	}
+	plainVFS, err := handler.CreateServerVFS(server.IP)
+	if err != nil {
+		t.Fatalf("failed to load server filesystem: %v", err)
+	}
+	plainVFS.SetRole("user", false, "/home/user")
+	if err := plainVFS.Symlink("/bin/sh", "/usr/bin/backup"); err == nil {
+		t.Fatal("expected /usr/bin to be read-only for users")
+	}
+}
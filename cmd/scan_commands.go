@@ -0,0 +1,245 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"terminal-sh/filesystem"
+	"terminal-sh/services"
+	"terminal-sh/ui"
+)
+
+// scannerTool is the tool that fingerprints services for nmap -sV.
+const scannerTool = "port_scanner"
+
+// nmapOptions are the parsed options of an nmap command.
+type nmapOptions struct {
+	target   string
+	pingOnly bool // -sn: host discovery only
+	versions bool // -sV: service/version detection
+	timing   int  // -T0 to -T5
+	ports    services.PortSpec
+	outputs  map[string]string // Output format (N, G or J) -> file
+}
+
+// parseNmapArgs parses nmap's command line.
+func parseNmapArgs(args []string) (*nmapOptions, error) {
+	opts := &nmapOptions{timing: services.DefaultScanTiming, outputs: make(map[string]string)}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "-sn":
+			opts.pingOnly = true
+		case arg == "-sV":
+			opts.versions = true
+		case strings.HasPrefix(arg, "-T") && len(arg) == 3:
+			timing, err := strconv.Atoi(arg[2:])
+			if err != nil || timing < 0 || timing >= len(services.ScanTimings) {
+				return nil, fmt.Errorf("invalid timing template %s (use -T0 to -T5)", arg)
+			}
+			opts.timing = timing
+		case strings.HasPrefix(arg, "-p"):
+			spec := strings.TrimPrefix(arg, "-p")
+			if spec == "" {
+				if i+1 >= len(args) {
+					return nil, fmt.Errorf("-p needs a port list")
+				}
+				i++
+				spec = args[i]
+			}
+			ports, err := services.ParsePortSpec(spec)
+			if err != nil {
+				return nil, err
+			}
+			opts.ports = ports
+		case arg == "-oN" || arg == "-oG" || arg == "-oJ":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("%s needs a file name", arg)
+			}
+			i++
+			opts.outputs[arg[2:]] = args[i]
+		case strings.HasPrefix(arg, "-"):
+			return nil, fmt.Errorf("unknown option %s", arg)
+		default:
+			if opts.target != "" {
+				return nil, fmt.Errorf("only one target may be given")
+			}
+			opts.target = arg
+		}
+	}
+	if opts.target == "" {
+		return nil, fmt.Errorf("no target given")
+	}
+	if opts.pingOnly && (opts.versions || len(opts.ports) > 0) {
+		return nil, fmt.Errorf("-sn skips the port scan and can't be combined with -p or -sV")
+	}
+	return opts, nil
+}
+
+// handleNMAP handles the nmap command: host discovery, port scanning and service detection
+func (h *CommandHandler) handleNMAP(args []string) *CommandResult {
+	if h.user == nil {
+		return &CommandResult{Error: fmt.Errorf("not authenticated")}
+	}
+	opts, err := parseNmapArgs(args)
+	if err != nil {
+		return &CommandResult{Error: fmt.Errorf("nmap: %w\nusage: nmap [-sn] [-sV] [-T0-5] [-p <ports>] [-oN|-oG|-oJ <file>] <target|network/cidr>", err)}
+	}
+
+	// Service detection is only as good as the scanner's fingerprint database
+	versionLevel := 0
+	if opts.versions {
+		if !h.toolService.UserHasTool(h.user.ID, scannerTool) {
+			return &CommandResult{Error: fmt.Errorf("nmap: -sV requires the %s tool (download it from a tool repository)", scannerTool)}
+		}
+		tool, err := h.toolService.GetEffectiveTool(h.user.ID, scannerTool)
+		if err != nil {
+			return &CommandResult{Error: fmt.Errorf("nmap: %w", err)}
+		}
+		for _, exploit := range tool.Exploits {
+			if exploit.Type == "service_detection" && exploit.Level > versionLevel {
+				versionLevel = exploit.Level
+			}
+		}
+	}
+
+	graph, err := h.networkGraph()
+	if err != nil {
+		return &CommandResult{Error: fmt.Errorf("nmap: %w", err)}
+	}
+	targets, err := graph.SweepTargets(opts.target)
+	if err != nil {
+		return &CommandResult{Error: fmt.Errorf("nmap: %w", err)}
+	}
+
+	// Ping sweep: hosts on a subnet we have no route to never answer
+	timing := services.ScanTimings[opts.timing]
+	report := &services.ScanReport{
+		Command:   "nmap " + strings.Join(args, " "),
+		Target:    opts.target,
+		Timing:    timing.Name,
+		PingOnly:  opts.pingOnly,
+		Started:   time.Now(),
+		HostsSeen: len(targets),
+	}
+	routes := make(map[string][]services.Hop)
+	maxHops := 0
+	for _, ip := range targets {
+		hops, _, err := graph.Route(h.currentServerPath, h.tunnels, ip)
+		if errors.Is(err, services.ErrNoRoute) {
+			continue
+		} else if err != nil {
+			return &CommandResult{Error: fmt.Errorf("nmap: %w", err)}
+		}
+		server, err := h.serverService.GetServerByIP(ip)
+		if err != nil {
+			continue
+		}
+
+		host := services.HostResult{IP: ip}
+		if !opts.pingOnly {
+			host = services.ScanHost(server, opts.ports, versionLevel)
+		}
+		for _, hop := range hops {
+			host.LatencyMs += hop.LatencyMs
+		}
+		report.Hosts = append(report.Hosts, host)
+		routes[ip] = hops
+		if len(hops)-1 > maxHops {
+			maxHops = len(hops) - 1
+		}
+	}
+	report.HostsUp = len(report.Hosts)
+
+	ports := opts.ports.Count()
+	if opts.pingOnly {
+		ports = 0
+	}
+	duration := 3.0
+	if h.progressService != nil {
+		duration = h.progressService.CalculateScanTime(len(targets), report.HostsUp, ports, timing) +
+			h.progressService.CalculateRouteDelay(maxHops)
+	}
+	report.Elapsed = duration
+
+	// A background scan reports from, and writes to, the machine it was started on
+	sourceIP := h.GetEffectiveSourceIP()
+	vfs := h.vfs
+	outputs := make(map[string]string, len(opts.outputs))
+	for format, file := range opts.outputs {
+		outputs[format] = vfs.AbsPath(expandHome(vfs, file))
+	}
+
+	return &CommandResult{
+		StartProgress: &ProgressOperationRequest{
+			ID:       fmt.Sprintf("nmap-%s-%d", opts.target, time.Now().UnixNano()),
+			Message:  fmt.Sprintf("Scanning %s (%s)...", opts.target, timing.Name),
+			Duration: duration,
+			Operation: func() *CommandResult {
+				h.logScanDetection(report, routes, timing, sourceIP)
+
+				var output strings.Builder
+				output.WriteString(services.FormatScanNormal(report))
+				if report.HostsUp == 0 && len(targets) == 1 {
+					output.WriteString(ui.DimStyle.Render("  Host seems down - if it's on an internal subnet, pivot through its gateway with 'tunnel add'") + "\n")
+				}
+				for _, format := range []string{"N", "G", "J"} {
+					file, ok := outputs[format]
+					if !ok {
+						continue
+					}
+					written, err := writeScanReport(vfs, report, format, file)
+					if err != nil {
+						output.WriteString(ui.ErrorStyle.Render(fmt.Sprintf("nmap: %s: %v", opts.outputs[format], err)) + "\n")
+						continue
+					}
+					output.WriteString(ui.SuccessStyle.Render("📝 Output written to ") + ui.ValueStyle.Render(written) + "\n")
+				}
+				return &CommandResult{Output: output.String()}
+			},
+		},
+	}
+}
+
+// logScanDetection rolls, for every host that was scanned, whether it noticed the scan given
+// the timing template, and logs it from sourceIP on the host and the hops on the way if it did.
+// A ping sweep sends a fraction of the traffic and is half as likely to be noticed.
+func (h *CommandHandler) logScanDetection(report *services.ScanReport, routes map[string][]services.Hop, timing services.ScanTiming, sourceIP string) {
+	if h.serverLogService == nil {
+		return
+	}
+	chance := timing.DetectionChance
+	if report.PingOnly {
+		chance /= 2
+	}
+	for _, host := range report.Hosts {
+		if rand.Float64() >= chance {
+			continue
+		}
+		h.serverLogService.LogScan(host.IP, sourceIP, &h.user.ID)
+		h.logRoute(routes[host.IP], "Port scan")
+	}
+}
+
+// writeScanReport writes a scan report to the absolute path file on vfs in format N (normal),
+// G (grepable) or J (JSON). Returns the path written.
+func writeScanReport(vfs *filesystem.VFS, report *services.ScanReport, format, file string) (string, error) {
+	var content string
+	switch format {
+	case "G":
+		content = services.FormatScanGrepable(report)
+	case "J":
+		var err error
+		if content, err = services.FormatScanJSON(report); err != nil {
+			return "", err
+		}
+	default:
+		content = services.FormatScanNormal(report)
+	}
+	return vfs.ReceiveFile(file, filepath.Base(file), content)
+}
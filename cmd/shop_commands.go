@@ -2,13 +2,14 @@ package cmd
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
 	"terminal-sh/models"
 	"terminal-sh/patch"
+	"terminal-sh/services"
 	"terminal-sh/ui"
-
-	"github.com/google/uuid"
 )
 
 // handleSHOP handles shop-related commands
@@ -20,6 +21,9 @@ func (h *CommandHandler) handleSHOP(args []string) *CommandResult {
 	if len(args) == 0 {
 		return h.handleShopList()
 	}
+	if args[0] == "sale" {
+		return h.handleShopSale(args[1:])
+	}
 
 	shopID := args[0]
 	return h.handleShopBrowse(shopID)
@@ -88,27 +92,9 @@ func (h *CommandHandler) handleShopBrowse(shopID string) *CommandResult {
 		return &CommandResult{Error: fmt.Errorf("shop service not available")}
 	}
 
-	// Parse shop ID
-	shopUUID, err := uuid.Parse(shopID)
-	if err != nil {
-		return &CommandResult{Error: fmt.Errorf("invalid shop ID")}
-	}
-
-	shop, err := h.shopService.GetShopByServerIP(shopID)
+	shop, err := h.findShop(shopID)
 	if err != nil {
-		// Try by UUID - get all shops and find by UUID
-		shops, err := h.shopService.GetAllShops()
-		if err == nil {
-			for i := range shops {
-				if shops[i].ID == shopUUID {
-					shop = &shops[i]
-					break
-				}
-			}
-		}
-		if shop == nil {
-			return &CommandResult{Error: fmt.Errorf("shop not found")}
-		}
+		return &CommandResult{Error: err}
 	}
 
 	// Check if user can access this shop
@@ -119,45 +105,65 @@ func (h *CommandHandler) handleShopBrowse(shopID string) *CommandResult {
 		}
 	}
 
-	items, err := h.shopService.GetShopItems(shop.ID)
+	offers, err := h.shopService.GetShopOffers(shop, h.user.ID)
 	if err != nil {
 		return &CommandResult{Error: err}
 	}
 
+	now := time.Now()
 	var output strings.Builder
 	output.WriteString("╔═══════════════════════════════════════╗\n")
 	output.WriteString("║   " + ui.AccentBoldStyle.Render(shop.Name) + "\n")
 	output.WriteString("╚═══════════════════════════════════════╝\n\n")
 	output.WriteString(ui.ValueStyle.Render(shop.Description) + "\n\n")
 	output.WriteString(ui.FormatSectionHeader("Items for sale:", "🛍️"))
-	
-	if len(items) == 0 {
+	if rotates := services.NextRotation(shop, now); !rotates.IsZero() {
+		output.WriteString(ui.DimStyle.Render(fmt.Sprintf("  New stock rotates in %s", rotates.Sub(now).Round(time.Minute))) + "\n\n")
+	}
+
+	if len(offers) == 0 {
 		output.WriteString("  No items available.\n")
 	} else {
-		for i, item := range items {
+		for i, offer := range offers {
+			item := offer.Item
 			output.WriteString(ui.ListStyle.Render(fmt.Sprintf("  [%d] ", i+1)) + ui.AccentStyle.Render(item.Name) + "\n")
 			output.WriteString(ui.FormatKeyValuePair("      Type:", string(item.ItemType)) + "\n")
 			if item.Description != "" {
 				output.WriteString("      " + ui.ValueStyle.Render(item.Description) + "\n")
 			}
 			priceStr := ui.LabelStyle.Render("      Price: ")
-			if item.PriceCrypto > 0 {
-				priceStr += ui.PriceStyle.Render(fmt.Sprintf("%.2f crypto", item.PriceCrypto))
+			if offer.PriceCrypto > 0 {
+				priceStr += ui.PriceStyle.Render(fmt.Sprintf("%.2f crypto", offer.PriceCrypto))
 			}
-			if item.PriceData > 0 {
-				if item.PriceCrypto > 0 {
+			if offer.PriceData > 0 {
+				if offer.PriceCrypto > 0 {
 					priceStr += " + "
 				}
-				priceStr += ui.PriceStyle.Render(fmt.Sprintf("%.2f data", item.PriceData))
+				priceStr += ui.PriceStyle.Render(fmt.Sprintf("%.2f data", offer.PriceData))
+			}
+			if item.OnSale(now) {
+				priceStr += " " + ui.SuccessStyle.Render(fmt.Sprintf("SALE -%.0f%% for %s", item.SaleDiscount*100, item.SaleEndsAt.Sub(now).Round(time.Minute)))
+			} else if offer.PriceCrypto > item.PriceCrypto || offer.PriceData > item.PriceData {
+				priceStr += " " + ui.WarningStyle.Render(fmt.Sprintf("(%d bought recently)", offer.Demand))
 			}
 			output.WriteString(priceStr + "\n")
 			stockStr := ui.LabelStyle.Render("      Stock: ")
-			if item.Stock >= 0 {
-				stockStr += ui.ValueStyle.Render(fmt.Sprintf("%d", item.Stock))
-			} else {
+			switch {
+			case item.Stock < 0:
 				stockStr += ui.ValueStyle.Render("Unlimited")
+			case item.Stock == 0:
+				stockStr += ui.ErrorStyle.Render("Sold out")
+			default:
+				stockStr += ui.ValueStyle.Render(fmt.Sprintf("%d", item.Stock))
+			}
+			if item.MaxStock > 0 && shop.Economy.RestockMinutes > 0 {
+				stockStr += ui.DimStyle.Render(fmt.Sprintf(" (restocks to %d every %dm)", item.MaxStock, shop.Economy.RestockMinutes))
+			}
+			output.WriteString(stockStr + "\n")
+			if item.PurchaseLimit > 0 {
+				output.WriteString(ui.LabelStyle.Render("      Limit: ") + ui.ValueStyle.Render(fmt.Sprintf("%d/%d bought", offer.Bought, item.PurchaseLimit)) + "\n")
 			}
-			output.WriteString(stockStr + "\n\n")
+			output.WriteString("\n")
 		}
 	}
 
@@ -166,6 +172,69 @@ func (h *CommandHandler) handleShopBrowse(shopID string) *CommandResult {
 	return &CommandResult{Output: output.String()}
 }
 
+// handleShopSale puts a shop item on sale for a while. Admin only.
+func (h *CommandHandler) handleShopSale(args []string) *CommandResult {
+	if !h.userService.IsAdmin(h.user) {
+		return &CommandResult{Error: fmt.Errorf("shop sale: permission denied")}
+	}
+	if len(args) != 4 {
+		return &CommandResult{Error: fmt.Errorf("usage: shop sale <shopID> <itemNumber> <percentOff> <hours>")}
+	}
+	shop, err := h.findShop(args[0])
+	if err != nil {
+		return &CommandResult{Error: err}
+	}
+	offer, err := h.findShopOffer(shop, args[1])
+	if err != nil {
+		return &CommandResult{Error: err}
+	}
+	percent, err := strconv.ParseFloat(args[2], 64)
+	if err != nil {
+		return &CommandResult{Error: fmt.Errorf("invalid discount: %s", args[2])}
+	}
+	hours, err := strconv.ParseFloat(args[3], 64)
+	if err != nil {
+		return &CommandResult{Error: fmt.Errorf("invalid duration: %s", args[3])}
+	}
+
+	item, err := h.shopService.StartSale(offer.Item.ID, percent/100, time.Duration(hours*float64(time.Hour)))
+	if err != nil {
+		return &CommandResult{Error: err}
+	}
+	return &CommandResult{Output: ui.SuccessStyle.Render(fmt.Sprintf("✅ %s at %s is %.0f%% off until %s",
+		item.Name, shop.Name, percent, item.SaleEndsAt.Format("2006-01-02 15:04"))) + "\n"}
+}
+
+// findShop finds a shop by its server IP or an ID prefix as shown in the shop list
+func (h *CommandHandler) findShop(ref string) (*models.Shop, error) {
+	if shop, err := h.shopService.GetShopByServerIP(ref); err == nil {
+		return shop, nil
+	}
+	shops, err := h.shopService.GetAllShops()
+	if err != nil {
+		return nil, err
+	}
+	for i := range shops {
+		if len(ref) >= 4 && strings.HasPrefix(shops[i].ID.String(), ref) {
+			return &shops[i], nil
+		}
+	}
+	return nil, fmt.Errorf("shop not found")
+}
+
+// findShopOffer returns the item with the given number in a shop's current listing
+func (h *CommandHandler) findShopOffer(shop *models.Shop, itemNum string) (*services.ShopOffer, error) {
+	offers, err := h.shopService.GetShopOffers(shop, h.user.ID)
+	if err != nil {
+		return nil, err
+	}
+	var itemIndex int
+	if _, err := fmt.Sscanf(itemNum, "%d", &itemIndex); err != nil || itemIndex < 1 || itemIndex > len(offers) {
+		return nil, fmt.Errorf("invalid item number")
+	}
+	return &offers[itemIndex-1], nil
+}
+
 // handleBUY handles purchasing items from shops
 func (h *CommandHandler) handleBUY(args []string) *CommandResult {
 	if h.user == nil {
@@ -184,9 +253,9 @@ func (h *CommandHandler) handleBUY(args []string) *CommandResult {
 	itemNum := args[1]
 
 	// Get shop
-	shop, err := h.shopService.GetShopByServerIP(shopID)
+	shop, err := h.findShop(shopID)
 	if err != nil {
-		return &CommandResult{Error: fmt.Errorf("shop not found")}
+		return &CommandResult{Error: err}
 	}
 
 	// Check if user can access this shop
@@ -197,28 +266,23 @@ func (h *CommandHandler) handleBUY(args []string) *CommandResult {
 		}
 	}
 
-	// Get items
-	items, err := h.shopService.GetShopItems(shop.ID)
+	// Get the item from the shop's current listing
+	offer, err := h.findShopOffer(shop, itemNum)
 	if err != nil {
 		return &CommandResult{Error: err}
 	}
-
-	// Parse item number
-	var itemIndex int
-	if _, err := fmt.Sscanf(itemNum, "%d", &itemIndex); err != nil || itemIndex < 1 || itemIndex > len(items) {
-		return &CommandResult{Error: fmt.Errorf("invalid item number")}
-	}
-
-	item := items[itemIndex-1]
+	item := offer.Item
 
 	// Purchase item
-	if err := h.shopService.PurchaseItem(h.user.ID, shop.ID, item.ID); err != nil {
+	purchase, err := h.shopService.PurchaseItem(h.user.ID, shop.ID, item.ID)
+	if err != nil {
 		return &CommandResult{Error: err}
 	}
 
 	// Handle item based on type
 	var output strings.Builder
 	output.WriteString(ui.SuccessStyle.Render("✅ Successfully purchased ") + ui.AccentStyle.Render(item.Name) + ui.SuccessStyle.Render(fmt.Sprintf(" from %s", shop.Name)) + "\n")
+	output.WriteString(ui.FormatKeyValuePair("Paid", formatShopPrice(purchase.PriceCrypto, purchase.PriceData)) + "\n")
 
 	switch item.ItemType {
 	case models.ItemTypeTool:
@@ -235,25 +299,21 @@ func (h *CommandHandler) handleBUY(args []string) *CommandResult {
 	return &CommandResult{Output: output.String()}
 }
 
+// formatShopPrice formats a price in crypto and/or data
+func formatShopPrice(crypto, data float64) string {
+	switch {
+	case crypto > 0 && data > 0:
+		return fmt.Sprintf("%.2f crypto + %.2f data", crypto, data)
+	case data > 0:
+		return fmt.Sprintf("%.2f data", data)
+	default:
+		return fmt.Sprintf("%.2f crypto", crypto)
+	}
+}
+
 // getUpgradeTypeFromItemName extracts the upgrade type from an upgrade token item name
 func (h *CommandHandler) getUpgradeTypeFromItemName(itemName string) string {
-	nameLower := strings.ToLower(itemName)
-	if strings.Contains(nameLower, "exploit") {
-		return "exploit"
-	}
-	if strings.Contains(nameLower, "cpu") {
-		return "cpu"
-	}
-	if strings.Contains(nameLower, "ram") {
-		return "ram"
-	}
-	if strings.Contains(nameLower, "bandwidth") || strings.Contains(nameLower, "bw") {
-		return "bw"
-	}
-	if strings.Contains(nameLower, "full") || strings.Contains(nameLower, "tune") {
-		return "full"
-	}
-	return "exploit" // default
+	return services.UpgradeTypeForToken(itemName)
 }
 
 // handleBuyUpgradeToken handles purchasing upgrade tokens from shops
@@ -272,24 +332,17 @@ func (h *CommandHandler) handleBuyUpgradeToken(args []string) *CommandResult {
 	}
 
 	// Get shop
-	shop, err := h.shopService.GetShopByServerIP(shopID)
+	shop, err := h.findShop(shopID)
 	if err != nil {
-		return &CommandResult{Error: fmt.Errorf("shop not found")}
+		return &CommandResult{Error: err}
 	}
 
-	// Get items
-	items, err := h.shopService.GetShopItems(shop.ID)
+	// Get the item from the shop's current listing
+	offer, err := h.findShopOffer(shop, itemNum)
 	if err != nil {
 		return &CommandResult{Error: err}
 	}
-
-	// Parse item number
-	var itemIndex int
-	if _, err := fmt.Sscanf(itemNum, "%d", &itemIndex); err != nil || itemIndex < 1 || itemIndex > len(items) {
-		return &CommandResult{Error: fmt.Errorf("invalid item number")}
-	}
-
-	item := items[itemIndex-1]
+	item := offer.Item
 
 	// Verify it's an upgrade token
 	if item.ItemType != models.ItemTypeUpgradeToken {
@@ -297,16 +350,21 @@ func (h *CommandHandler) handleBuyUpgradeToken(args []string) *CommandResult {
 	}
 
 	// Purchase item
-	if err := h.shopService.PurchaseItem(h.user.ID, shop.ID, item.ID); err != nil {
+	if _, err := h.shopService.PurchaseItem(h.user.ID, shop.ID, item.ID); err != nil {
 		return &CommandResult{Error: err}
 	}
 
-	// Determine upgrade type from item name and apply it
+	// Determine upgrade type from item name and apply the token just bought
 	upgradeTypeStr := h.getUpgradeTypeFromItemName(item.Name)
 	upgradeType, _ := patch.ParseUpgradeType(upgradeTypeStr)
+	token, err := h.shopService.UseUpgradeToken(h.user.ID, upgradeType)
+	if err != nil {
+		return &CommandResult{Error: fmt.Errorf("failed to apply upgrade: %w", err)}
+	}
 
 	// Apply the upgrade for free (already paid for it)
 	if err := h.upgradeService.ApplyFreeUpgrade(h.user.ID, toolName, upgradeType); err != nil {
+		h.shopService.ReturnUpgradeToken(token)
 		return &CommandResult{Error: fmt.Errorf("failed to apply upgrade: %w", err)}
 	}
 
@@ -2,7 +2,10 @@ package cmd
 
 import (
 	"fmt"
+	"path"
+	"regexp"
 	"strings"
+	"terminal-sh/filesystem"
 	"terminal-sh/models"
 	"terminal-sh/services"
 	"terminal-sh/ui"
@@ -62,9 +65,23 @@ func (h *CommandHandler) createExploitProgressResult(toolName, targetIP string,
 	duration := h.getExploitDuration(toolName)
 	operationID := fmt.Sprintf("exploit-%s-%s-%d", toolName, targetIP, time.Now().UnixNano())
 
+	// Traffic to the target is slowed and logged by every hop it's routed through
+	hops, serverPath, err := h.routeTo(targetIP)
+	if err != nil {
+		serverPath = h.legacyServerPath(targetIP)
+	}
+	if h.progressService != nil && len(hops) > 1 {
+		duration += h.progressService.CalculateRouteDelay(len(hops) - 1)
+	}
+
 	// Wrap operation to check for mission auto-completion after each exploit
 	wrappedOp := func() *CommandResult {
+		h.logRoute(hops, "Forwarded "+toolName+" traffic")
+		started := time.Now()
 		result := operation()
+		if result != nil && result.Error == nil {
+			h.springHoneypot(targetIP, serverPath, started, result)
+		}
 		if result != nil && result.Error == nil && h.user != nil && h.missionService != nil {
 			if completion := h.missionService.TryAutoComplete(h.user.ID); completion != nil {
 				result.MissionCompleted = completion
@@ -83,12 +100,58 @@ func (h *CommandHandler) createExploitProgressResult(toolName, targetIP string,
 	}
 }
 
+// springHoneypot springs the trap if the operation that just ran against targetIP, reached at
+// serverPath, exploited a honeypot: the tracker is planted in the player's home and the damage
+// appended to the result.
+func (h *CommandHandler) springHoneypot(targetIP, serverPath string, since time.Time, result *CommandResult) {
+	if h.honeypotService == nil || h.user == nil {
+		return
+	}
+	server, err := h.serverService.GetServerByIP(targetIP)
+	if err != nil || !server.IsHoneypot() {
+		return
+	}
+	if !h.honeypotService.ExploitedSince(h.user.ID, serverPath, since) {
+		return
+	}
+	report, err := h.honeypotService.Spring(h.user.ID, server)
+	if err != nil {
+		return
+	}
+	h.user.Wallet.Crypto -= report.Confiscated
+	h.user.TraceFlags = report.TraceFlags
+	h.homeVFS.EnsureDirectoryAndCreateFile("/home/"+h.user.Username+"/.cache", report.TrackerName, report.TrackerContent)
+
+	var output strings.Builder
+	output.WriteString("\n" + ui.ErrorStyle.Render("🍯 "+targetIP+" was a honeypot!") + "\n")
+	output.WriteString(ui.FormatKeyValuePair("Your IP logged:", formatIP(report.LoggedIP)) + "\n")
+	if report.Confiscated > 0 {
+		output.WriteString(ui.FormatKeyValuePair("Crypto confiscated:", fmt.Sprintf("%.2f", report.Confiscated)) + "\n")
+	}
+	if report.Flagged {
+		output.WriteString(ui.FormatKeyValuePair("Trace flags:", fmt.Sprintf("%d", report.TraceFlags)) + "\n")
+	}
+	output.WriteString(ui.FormatKeyValuePair("Tracker planted:", "~/.cache/"+report.TrackerName) + "\n")
+	output.WriteString(ui.DimStyle.Render("💡 Run log_analyzer on unfamiliar servers before exploiting them") + "\n")
+	result.Output += output.String()
+}
+
 // passwordCrackableServices returns services that can be targeted by password cracking tools
 var passwordCrackableServices = []string{"ssh", "telnet", "ftp"}
 
 // findPasswordCrackableService finds a password-crackable service on a server
 // Returns the service and its name, or nil if none found
 func (h *CommandHandler) findPasswordCrackableService(server *models.Server) (*models.Service, string) {
+	// A password_cracking zero-day makes every one of them crackable
+	if zeroDay := h.exploitationService.ZeroDay(); zeroDay != nil && zeroDay.Type == "password_cracking" {
+		for _, serviceName := range passwordCrackableServices {
+			for i := range server.Services {
+				if server.Services[i].Name == serviceName {
+					return &server.Services[i], serviceName
+				}
+			}
+		}
+	}
 	for _, serviceName := range passwordCrackableServices {
 		for i := range server.Services {
 			if server.Services[i].Name == serviceName && server.Services[i].Vulnerable {
@@ -115,6 +178,17 @@ func (h *CommandHandler) handleToolCommand(toolName string, args []string) *Comm
 		return &CommandResult{Error: fmt.Errorf("tool %s not owned", toolName)}
 	}
 
+	// Targets on internal subnets need a route through their gateway
+	if len(args) > 0 {
+		target := args[0]
+		if host, _, _, err := services.ParseURL(target); err == nil && strings.Contains(target, "/") {
+			target = host
+		}
+		if err := h.checkRoute(toolName, target); err != nil {
+			return &CommandResult{Error: err}
+		}
+	}
+
 	switch toolName {
 	case "password_cracker":
 		return h.handlePasswordCracker(args)
@@ -156,6 +230,9 @@ func (h *CommandHandler) handleToolCommand(toolName string, args []string) *Comm
 		return h.handleLogAnalyzer(args)
 	case "backup_destroyer":
 		return h.handleBackupDestroyer(args)
+	case "port_scanner":
+		// Shorthand for a service/version scan
+		return h.handleNMAP(append([]string{"-sV"}, args...))
 	// Privilege escalation tools
 	case "privesc_scanner":
 		return h.handlePrivescScanner(args)
@@ -203,7 +280,7 @@ func (h *CommandHandler) handlePasswordCracker(args []string) *CommandResult {
 	// Check if tool can crack this service's password vulnerability
 	var canCrack bool
 	var vulnLevel int
-	for _, vuln := range targetService.Vulnerabilities {
+	for _, vuln := range h.withZeroDay(targetService.Vulnerabilities) {
 		if vuln.Type == "password_cracking" {
 			for _, exploit := range tool.Exploits {
 				if exploit.Type == "password_cracking" && exploit.Level >= vuln.Level {
@@ -219,10 +296,7 @@ func (h *CommandHandler) handlePasswordCracker(args []string) *CommandResult {
 	}
 
 	// Calculate server path
-	serverPath := targetIP
-	if h.currentServerPath != "" {
-		serverPath = h.currentServerPath + ".localNetwork." + targetIP
-	}
+	serverPath := h.targetServerPath(targetIP)
 
 	// Capture variables for the closure
 	credService := h.credentialService
@@ -281,7 +355,7 @@ func (h *CommandHandler) handlePasswordCracker(args []string) *CommandResult {
 		// Crack each user
 		for _, user := range usersToCrack {
 			// Generate password based on username and server
-			password := services.GeneratePassword(user.username, capturedServer.IP, user.role)
+			password := services.RolePassword(capturedServer, user.username, user.role)
 			
 			// Simulate cracking difficulty based on vulnerability level
 			// Higher level = harder to crack = chance of failure
@@ -324,6 +398,8 @@ func (h *CommandHandler) handlePasswordCracker(args []string) *CommandResult {
 			actionTracker.TrackToolUse(userID, "password_cracker", capturedServerPath, capturedServiceName)
 			actionTracker.TrackCredentialCrack(userID, "password_cracker", capturedServerPath, capturedServiceName)
 		}
+		h.scoreExploit(capturedServer, capturedServiceName, "password_cracking")
+		h.notificationService.NotifyIntrusion(capturedServer.IP, userID, username, capturedServiceName)
 
 		// Add experience based on cracked accounts and difficulty
 		xp := 5 * crackedCount * (1 + capturedVulnLevel/10)
@@ -373,10 +449,14 @@ func (h *CommandHandler) handleSSHExploit(args []string) *CommandResult {
 		return &CommandResult{Error: fmt.Errorf("tool not found")}
 	}
 
-	// Check if tool can exploit RCE vulnerability
+	// Check if tool can exploit RCE vulnerability. A patched service only falls to a zero-day.
+	vulns := sshService.Vulnerabilities
+	if !sshService.Vulnerable {
+		vulns = nil
+	}
 	var canExploit bool
 	var exploitType string
-	for _, vuln := range sshService.Vulnerabilities {
+	for _, vuln := range h.withZeroDay(vulns) {
 		if vuln.Type == "remote_code_execution" || vuln.Type == "buffer_overflow" {
 			for _, exploit := range tool.Exploits {
 				if exploit.Type == vuln.Type && exploit.Level >= vuln.Level {
@@ -387,15 +467,15 @@ func (h *CommandHandler) handleSSHExploit(args []string) *CommandResult {
 			}
 		}
 	}
+	if !canExploit && !sshService.Vulnerable {
+		return &CommandResult{Error: fmt.Errorf("ssh_exploit: SSH on this server has been patched")}
+	}
 	if !canExploit {
 		return &CommandResult{Error: fmt.Errorf("ssh_exploit cannot exploit SSH on this server (no suitable RCE vulnerability)")}
 	}
 
 	// Calculate server path
-	serverPath := targetIP
-	if h.currentServerPath != "" {
-		serverPath = h.currentServerPath + ".localNetwork." + targetIP
-	}
+	serverPath := h.targetServerPath(targetIP)
 
 	// Capture variables for the closure
 	credService := h.credentialService
@@ -421,6 +501,10 @@ func (h *CommandHandler) handleSSHExploit(args []string) *CommandResult {
 		if err != nil {
 			return &CommandResult{Error: fmt.Errorf("failed to install backdoor: %w", err)}
 		}
+		h.trackServerExploit("ssh_exploit", capturedServerPath, "ssh")
+		h.trackBackdoorInstall("ssh_exploit", capturedServerPath)
+		h.scoreExploit(capturedServer, "ssh", capturedExploitType)
+		h.notificationService.NotifyIntrusion(capturedServer.IP, userID, username, "ssh")
 
 		// Log the exploit
 		if serverLogService != nil {
@@ -459,10 +543,7 @@ func (h *CommandHandler) handleUserEnum(args []string) *CommandResult {
 	}
 
 	// Calculate server path
-	serverPath := targetIP
-	if h.currentServerPath != "" {
-		serverPath = h.currentServerPath + ".localNetwork." + targetIP
-	}
+	serverPath := h.targetServerPath(targetIP)
 
 	// Capture for async closure
 	userService := h.userService
@@ -592,10 +673,7 @@ func (h *CommandHandler) handleRootkit(args []string) *CommandResult {
 	}
 
 	// Check if server is already exploited
-	serverPath := targetIP
-	if h.currentServerPath != "" {
-		serverPath = h.currentServerPath + ".localNetwork." + targetIP
-	}
+	serverPath := h.targetServerPath(targetIP)
 
 	if !h.exploitationService.IsServerExploited(h.user.ID, serverPath) {
 		return &CommandResult{Error: fmt.Errorf("server must be exploited before installing rootkit")}
@@ -623,10 +701,7 @@ func (h *CommandHandler) handleExploitKit(args []string) *CommandResult {
 		return &CommandResult{Error: fmt.Errorf("server not found: %s", targetIP)}
 	}
 
-	serverPath := targetIP
-	if h.currentServerPath != "" {
-		serverPath = h.currentServerPath + ".localNetwork." + targetIP
-	}
+	serverPath := h.targetServerPath(targetIP)
 
 	// Capture variables for the closure
 	exploitService := h.exploitationService
@@ -636,11 +711,13 @@ func (h *CommandHandler) handleExploitKit(args []string) *CommandResult {
 	sourceIP := h.GetEffectiveSourceIP()
 
 	return h.createExploitProgressResult("exploit_kit", targetIP, func() *CommandResult {
-		// Try to exploit all vulnerable services
+		// Try to exploit all vulnerable services, or every service during a zero-day the kit can use
+		zeroDay := exploitService.ZeroDayFor(userID, "exploit_kit")
 		exploitedCount := 0
 		for _, service := range services {
-			if service.Vulnerable {
+			if service.Vulnerable || zeroDay {
 				if err := exploitService.ExploitServer(userID, serverPath, "exploit_kit", service.Name, sourceIP); err == nil {
+					h.trackServerExploit("exploit_kit", serverPath, service.Name)
 					exploitedCount++
 				}
 			}
@@ -717,10 +794,7 @@ func (h *CommandHandler) handleAdvancedExploitKit(args []string) *CommandResult
 		return &CommandResult{Error: fmt.Errorf("server not found: %s", targetIP)}
 	}
 
-	serverPath := targetIP
-	if h.currentServerPath != "" {
-		serverPath = h.currentServerPath + ".localNetwork." + targetIP
-	}
+	serverPath := h.targetServerPath(targetIP)
 
 	// Capture variables for the closure
 	exploitService := h.exploitationService
@@ -730,11 +804,13 @@ func (h *CommandHandler) handleAdvancedExploitKit(args []string) *CommandResult
 	sourceIP := h.GetEffectiveSourceIP()
 
 	return h.createExploitProgressResult("advanced_exploit_kit", targetIP, func() *CommandResult {
-		// Try to exploit all vulnerable services with advanced kit
+		// Try to exploit all vulnerable services, or every service during a zero-day the kit can use with advanced kit
+		zeroDay := exploitService.ZeroDayFor(userID, "advanced_exploit_kit")
 		exploitedCount := 0
 		for _, service := range services {
-			if service.Vulnerable {
+			if service.Vulnerable || zeroDay {
 				if err := exploitService.ExploitServer(userID, serverPath, "advanced_exploit_kit", service.Name, sourceIP); err == nil {
+					h.trackServerExploit("advanced_exploit_kit", serverPath, service.Name)
 					exploitedCount++
 				}
 			}
@@ -755,10 +831,13 @@ func (h *CommandHandler) handleAdvancedExploitKit(args []string) *CommandResult
 
 func (h *CommandHandler) handleSQLInjector(args []string) *CommandResult {
 	if len(args) != 1 {
-		return &CommandResult{Error: fmt.Errorf("usage: sql_injector <targetIP>")}
+		return &CommandResult{Error: fmt.Errorf("usage: sql_injector <targetIP|url>")}
 	}
 
-	targetIP := args[0]
+	targetIP, err := h.injectionTarget("sql_injector", args[0])
+	if err != nil {
+		return &CommandResult{Error: err}
+	}
 	
 	// Get server - validate before starting progress
 	server, err := h.serverService.GetServerByIP(targetIP)
@@ -779,10 +858,7 @@ func (h *CommandHandler) handleSQLInjector(args []string) *CommandResult {
 		return &CommandResult{Error: fmt.Errorf("HTTP service not found on server")}
 	}
 
-	serverPath := targetIP
-	if h.currentServerPath != "" {
-		serverPath = h.currentServerPath + ".localNetwork." + targetIP
-	}
+	serverPath := h.targetServerPath(targetIP)
 
 	// Capture variables for the closure
 	exploitService := h.exploitationService
@@ -794,6 +870,7 @@ func (h *CommandHandler) handleSQLInjector(args []string) *CommandResult {
 		if err := exploitService.ExploitServer(userID, serverPath, "sql_injector", "http", sourceIP); err != nil {
 			return &CommandResult{Error: err}
 		}
+		h.trackServerExploit("sql_injector", serverPath, "http")
 
 		// Add experience
 		userService.AddExperience(userID, 18)
@@ -806,10 +883,13 @@ func (h *CommandHandler) handleSQLInjector(args []string) *CommandResult {
 
 func (h *CommandHandler) handleXSSExploit(args []string) *CommandResult {
 	if len(args) != 1 {
-		return &CommandResult{Error: fmt.Errorf("usage: xss_exploit <targetIP>")}
+		return &CommandResult{Error: fmt.Errorf("usage: xss_exploit <targetIP|url>")}
 	}
 
-	targetIP := args[0]
+	targetIP, err := h.injectionTarget("xss_exploit", args[0])
+	if err != nil {
+		return &CommandResult{Error: err}
+	}
 	
 	// Get server - validate before starting progress
 	server, err := h.serverService.GetServerByIP(targetIP)
@@ -830,10 +910,7 @@ func (h *CommandHandler) handleXSSExploit(args []string) *CommandResult {
 		return &CommandResult{Error: fmt.Errorf("HTTP service not found on server")}
 	}
 
-	serverPath := targetIP
-	if h.currentServerPath != "" {
-		serverPath = h.currentServerPath + ".localNetwork." + targetIP
-	}
+	serverPath := h.targetServerPath(targetIP)
 
 	// Capture variables for the closure
 	exploitService := h.exploitationService
@@ -845,6 +922,7 @@ func (h *CommandHandler) handleXSSExploit(args []string) *CommandResult {
 		if err := exploitService.ExploitServer(userID, serverPath, "xss_exploit", "http", sourceIP); err != nil {
 			return &CommandResult{Error: err}
 		}
+		h.trackServerExploit("xss_exploit", serverPath, "http")
 
 		// Add experience
 		userService.AddExperience(userID, 12)
@@ -943,10 +1021,7 @@ func (h *CommandHandler) handleLogCleaner(args []string) *CommandResult {
 	}
 
 	// Check if server is exploited
-	serverPath := targetIP
-	if h.currentServerPath != "" {
-		serverPath = h.currentServerPath + ".localNetwork." + targetIP
-	}
+	serverPath := h.targetServerPath(targetIP)
 
 	if !h.exploitationService.IsServerExploited(h.user.ID, serverPath) {
 		return &CommandResult{Error: fmt.Errorf("server must be exploited before cleaning logs")}
@@ -974,8 +1049,8 @@ func (h *CommandHandler) handleLogCleaner(args []string) *CommandResult {
 }
 
 func (h *CommandHandler) handleTimestomper(args []string) *CommandResult {
-	if len(args) != 1 {
-		return &CommandResult{Error: fmt.Errorf("usage: timestomper <targetIP>")}
+	if len(args) != 1 && len(args) != 3 {
+		return &CommandResult{Error: fmt.Errorf("usage: timestomper <targetIP> [<path> <YYYY-MM-DD[THH:MM]>]")}
 	}
 
 	targetIP := args[0]
@@ -986,28 +1061,61 @@ func (h *CommandHandler) handleTimestomper(args []string) *CommandResult {
 	}
 
 	// Check if server is exploited
-	serverPath := targetIP
-	if h.currentServerPath != "" {
-		serverPath = h.currentServerPath + ".localNetwork." + targetIP
-	}
+	serverPath := h.targetServerPath(targetIP)
 
 	if !h.exploitationService.IsServerExploited(h.user.ID, serverPath) {
 		return &CommandResult{Error: fmt.Errorf("server must be exploited before modifying timestamps")}
 	}
 
+	// Optional explicit target: set one path to a chosen time instead of wiping all traces
+	var targetPath string
+	var stampTime time.Time
+	if len(args) == 3 {
+		targetPath = args[1]
+		var err error
+		if stampTime, err = parseStampTime(args[2]); err != nil {
+			return &CommandResult{Error: err}
+		}
+	}
+
+	// The exploit runs as root on the target
+	targetVFS, err := h.CreateServerVFS(serverPath)
+	if err != nil {
+		return &CommandResult{Error: err}
+	}
+	targetVFS.SetRole("root", true, "/root")
+	if targetPath != "" {
+		if _, err := targetVFS.Stat(targetPath); err != nil {
+			return &CommandResult{Error: fmt.Errorf("timestomper: %w", err)}
+		}
+	}
+
 	// Capture for async closure
 	userService := h.userService
 	userID := h.user.ID
 	actionTracker := h.actionTracker
 
 	return h.createExploitProgressResult("timestomper", targetIP, func() *CommandResult {
+		var output strings.Builder
+		if targetPath != "" {
+			if err := targetVFS.SetTimes(targetPath, stampTime, stampTime); err != nil {
+				return &CommandResult{Error: fmt.Errorf("timestomper: %w", err)}
+			}
+			output.WriteString(ui.SuccessStyle.Render("✅ Timestamps of ") + ui.ValueStyle.Render(targetPath) +
+				ui.SuccessStyle.Render(" on ") + formatIP(targetIP) + ui.SuccessStyle.Render(" set to "+stampTime.Format("2006-01-02 15:04")) + "\n")
+		} else {
+			count, err := targetVFS.ResetTimes("/")
+			if err != nil {
+				return &CommandResult{Error: fmt.Errorf("timestomper: %w", err)}
+			}
+			output.WriteString(ui.SuccessStyle.Render("✅ File timestamps modified on ") + formatIP(targetIP) +
+				ui.SuccessStyle.Render(fmt.Sprintf(". %d file(s) reset - tracks covered.", count)) + "\n")
+		}
+
 		// Track tool usage for mission validation
 		if actionTracker != nil {
 			actionTracker.TrackToolUse(userID, "timestomper", targetIP, "")
 		}
-
-		var output strings.Builder
-		output.WriteString(ui.SuccessStyle.Render("✅ File timestamps modified on ") + formatIP(targetIP) + ui.SuccessStyle.Render(". Tracks covered.") + "\n")
 		
 		// Add experience
 		userService.AddExperience(userID, 12)
@@ -1016,6 +1124,16 @@ func (h *CommandHandler) handleTimestomper(args []string) *CommandResult {
 	})
 }
 
+// parseStampTime parses a timestomper date, with or without a time of day.
+func parseStampTime(value string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02T15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date '%s': use YYYY-MM-DD or YYYY-MM-DDTHH:MM", value)
+}
+
 func (h *CommandHandler) handleDatabaseDumper(args []string) *CommandResult {
 	if len(args) != 1 {
 		return &CommandResult{Error: fmt.Errorf("usage: database_dumper <targetIP>")}
@@ -1042,10 +1160,7 @@ func (h *CommandHandler) handleDatabaseDumper(args []string) *CommandResult {
 		return &CommandResult{Error: fmt.Errorf("HTTP service not found on server")}
 	}
 
-	serverPath := targetIP
-	if h.currentServerPath != "" {
-		serverPath = h.currentServerPath + ".localNetwork." + targetIP
-	}
+	serverPath := h.targetServerPath(targetIP)
 
 	// Check if server is exploited - validate before starting progress
 	if !h.exploitationService.IsServerExploited(h.user.ID, serverPath) {
@@ -1055,13 +1170,27 @@ func (h *CommandHandler) handleDatabaseDumper(args []string) *CommandResult {
 	// Capture variables for the closure
 	userService := h.userService
 	userID := h.user.ID
+	homeVFS := h.homeVFS
+	downloadsDir := "/home/" + h.user.Username + "/Downloads"
 
 	return h.createExploitProgressResult("database_dumper", targetIP, func() *CommandResult {
+		dump := services.GenerateDatabaseDump(server)
+		compressed, err := filesystem.Compress(dump)
+		if err != nil {
+			return &CommandResult{Error: fmt.Errorf("database_dumper: %w", err)}
+		}
+		dumpName := targetIP + "_dump.sql.gz"
+		if err := homeVFS.EnsureDirectoryAndCreateFile(downloadsDir, dumpName, compressed); err != nil {
+			return &CommandResult{Error: fmt.Errorf("database_dumper: %w", err)}
+		}
+		h.trackDataExtraction("database_dumper", serverPath, len(dump))
+
 		var output strings.Builder
 		output.WriteString(ui.SuccessStyle.Render("✅ Database contents extracted from ") + formatIP(targetIP) + "\n")
-		output.WriteString(ui.FormatKeyValuePair("Tables dumped:", "12") + "\n")
-		output.WriteString(ui.FormatKeyValuePair("Records extracted:", "1,234") + "\n")
-		output.WriteString(ui.FormatKeyValuePair("Data size:", "45.2 MB") + "\n")
+		output.WriteString(ui.FormatKeyValuePair("Tables dumped:", "2") + "\n")
+		output.WriteString(ui.FormatKeyValuePair("Records extracted:", fmt.Sprintf("%d", strings.Count(dump, "INSERT INTO"))) + "\n")
+		output.WriteString(ui.FormatKeyValuePair("Saved to:", "~/Downloads/"+dumpName) + "\n")
+		output.WriteString(ui.InfoStyle.Render("💡 Run gunzip on the dump, then hash_cracker on the .sql file to recover the passwords") + "\n")
 		
 		// Add experience
 		userService.AddExperience(userID, 25)
@@ -1119,10 +1248,7 @@ func (h *CommandHandler) handleAuditDisable(args []string) *CommandResult {
 	}
 
 	// Check if server is exploited
-	serverPath := targetIP
-	if h.currentServerPath != "" {
-		serverPath = h.currentServerPath + ".localNetwork." + targetIP
-	}
+	serverPath := h.targetServerPath(targetIP)
 
 	if !h.exploitationService.IsServerExploited(h.user.ID, serverPath) {
 		return &CommandResult{Error: fmt.Errorf("server must be exploited before disabling audit")}
@@ -1151,10 +1277,15 @@ func (h *CommandHandler) handleAuditDisable(args []string) *CommandResult {
 
 func (h *CommandHandler) handleHashCracker(args []string) *CommandResult {
 	if len(args) != 1 {
-		return &CommandResult{Error: fmt.Errorf("usage: hash_cracker <targetIP>")}
+		return &CommandResult{Error: fmt.Errorf("usage: hash_cracker <targetIP|file>")}
 	}
 
 	targetIP := args[0]
+
+	// A readable file is cracked offline: encrypted files and dumps full of MD5 hashes
+	if content, err := h.vfs.ReadFileAtPath(targetIP); err == nil {
+		return h.crackFile(targetIP, content)
+	}
 	
 	// Check if server exists - validate before starting progress
 	if _, err := h.serverService.GetServerByIP(targetIP); err != nil {
@@ -1188,10 +1319,22 @@ func (h *CommandHandler) handleLogAnalyzer(args []string) *CommandResult {
 	targetIP := args[0]
 	
 	// Check if server exists
-	if _, err := h.serverService.GetServerByIP(targetIP); err != nil {
+	server, err := h.serverService.GetServerByIP(targetIP)
+	if err != nil {
 		return &CommandResult{Error: fmt.Errorf("server not found: %s", targetIP)}
 	}
 
+	// Honeypots give themselves away to an analyzer with a high enough detection level
+	detectionLevel := 0
+	if tool, err := h.toolService.GetEffectiveTool(h.user.ID, "log_analyzer"); err == nil {
+		for _, exploit := range tool.Exploits {
+			if exploit.Type == services.HoneypotDetection && exploit.Level > detectionLevel {
+				detectionLevel = exploit.Level
+			}
+		}
+	}
+	indicators := services.HoneypotIndicators(server, detectionLevel)
+
 	// Capture for async closure
 	userService := h.userService
 	userID := h.user.ID
@@ -1210,6 +1353,14 @@ func (h *CommandHandler) handleLogAnalyzer(args []string) *CommandResult {
 		output.WriteString(ui.FormatListBullet(ui.FormatKeyValuePair("Successful logins:", "12")))
 		output.WriteString(ui.FormatListBullet(ui.FormatKeyValuePair("Suspicious IPs:", "3")))
 		output.WriteString(ui.FormatListBullet(ui.FormatKeyValuePair("Admin access times:", "02:00-04:00")))
+		if len(indicators) > 0 {
+			output.WriteString(ui.WarningStyle.Render("🍯 Honeypot indicators:") + "\n")
+			for _, indicator := range indicators {
+				output.WriteString(ui.FormatListBullet(indicator))
+			}
+		} else {
+			output.WriteString(ui.FormatKeyValuePair("Honeypot indicators:", "none found") + "\n")
+		}
 		output.WriteString(ui.FormatKeyValuePair("Saved to:", "~/logs/"+targetIP+"-analysis.txt") + "\n")
 		
 		// Add experience
@@ -1232,10 +1383,7 @@ func (h *CommandHandler) handleBackupDestroyer(args []string) *CommandResult {
 	}
 
 	// Check if server is exploited
-	serverPath := targetIP
-	if h.currentServerPath != "" {
-		serverPath = h.currentServerPath + ".localNetwork." + targetIP
-	}
+	serverPath := h.targetServerPath(targetIP)
 
 	if !h.exploitationService.IsServerExploited(h.user.ID, serverPath) {
 		return &CommandResult{Error: fmt.Errorf("server must be exploited before destroying backups")}
@@ -1322,6 +1470,7 @@ func (h *CommandHandler) handlePrivescScanner(_ []string) *CommandResult {
 			}
 			
 			output.WriteString(ui.InfoStyle.Render("Use sudo_exploit, kernel_exploit, or suid_finder to exploit these vectors.") + "\n")
+			output.WriteString(ui.InfoStyle.Render("A writable PATH is hijacked by hand: link a shell in as the command root's cron job runs (see /etc/crontab).") + "\n")
 		}
 
 		// Add experience
@@ -1387,6 +1536,7 @@ func (h *CommandHandler) handleSudoExploit(_ []string) *CommandResult {
 					"sudo_misconfiguration", "sudo_exploit", true,
 				)
 			}
+			h.trackPrivilegeEscalation("sudo_exploit", serverPath, currentRole, "root")
 			output.WriteString(ui.SuccessStyle.Render("✅ PRIVILEGE ESCALATION SUCCESSFUL!") + "\n")
 			output.WriteString(ui.SuccessStyle.Render("Now running as: root") + "\n\n")
 			output.WriteString(ui.InfoStyle.Render("Reconnect to the server to use root privileges.") + "\n")
@@ -1458,6 +1608,7 @@ func (h *CommandHandler) handleKernelExploit(_ []string) *CommandResult {
 					"kernel_exploit", "kernel_exploit", true,
 				)
 			}
+			h.trackPrivilegeEscalation("kernel_exploit", serverPath, currentRole, "root")
 			output.WriteString(ui.SuccessStyle.Render("✅ KERNEL EXPLOIT SUCCESSFUL!") + "\n")
 			output.WriteString(ui.SuccessStyle.Render("uid=0(root) gid=0(root)") + "\n\n")
 			output.WriteString(ui.InfoStyle.Render("Reconnect to the server to use root privileges.") + "\n")
@@ -1534,6 +1685,7 @@ func (h *CommandHandler) handleSUIDFinder(_ []string) *CommandResult {
 					"suid_binary", "suid_finder", true,
 				)
 			}
+			h.trackPrivilegeEscalation("suid_finder", serverPath, currentRole, "root")
 			output.WriteString(ui.SuccessStyle.Render("✅ SUID EXPLOIT SUCCESSFUL!") + "\n")
 			output.WriteString(ui.SuccessStyle.Render("Spawned root shell via SUID binary") + "\n\n")
 			output.WriteString(ui.InfoStyle.Render("Reconnect to the server to use root privileges.") + "\n")
@@ -1546,6 +1698,48 @@ func (h *CommandHandler) handleSUIDFinder(_ []string) *CommandResult {
 	})
 }
 
+// pathHijackShells are the link targets that give a root shell when root's cron job runs them
+var pathHijackShells = map[string]bool{"sh": true, "bash": true, "dash": true, "zsh": true}
+
+// checkPathHijack checks whether a symbolic link just made on a server exploits its writable_path
+// vulnerability: a link to a shell, placed in the world-writable directory at the front of root's
+// cron PATH under the name of the command the job runs. The job then starts the shell as root.
+// Returns the output to add to ln's, or an empty string.
+func (h *CommandHandler) checkPathHijack(target, linkPath string) string {
+	if h.currentServerPath == "" || h.user == nil || h.IsCurrentRoleRoot() || !pathHijackShells[path.Base(target)] {
+		return ""
+	}
+	link, err := h.vfs.Lstat(linkPath)
+	if err != nil {
+		return ""
+	}
+
+	server, err := h.serverService.GetServerByPath(h.currentServerPath)
+	if err != nil {
+		return ""
+	}
+	vuln := findLocalVulnerability(server, "writable_path")
+	if vuln == nil || !vuln.GrantsRoot || link.Path() != vuln.Target {
+		return ""
+	}
+
+	currentRole := "user"
+	if h.currentRole != nil {
+		currentRole = h.currentRole.Username
+	}
+	var output strings.Builder
+	output.WriteString("\n" + ui.DimStyle.Render(fmt.Sprintf("Waiting for root's cron job to run %s...", path.Base(vuln.Target))) + "\n")
+	if h.roleService != nil {
+		h.roleService.RecordPrivilegeEscalation(h.user.ID, h.currentServerPath, currentRole, "root", "writable_path", "ln", true)
+	}
+	h.trackPrivilegeEscalation("ln", h.currentServerPath, currentRole, "root")
+	h.userService.AddExperience(h.user.ID, 50)
+	output.WriteString(ui.SuccessStyle.Render("✅ PATH HIJACK SUCCESSFUL!") + "\n")
+	output.WriteString(ui.SuccessStyle.Render(fmt.Sprintf("cron ran %s as root", target)) + "\n\n")
+	output.WriteString(ui.InfoStyle.Render("Reconnect to the server to use root privileges.") + "\n")
+	return output.String()
+}
+
 // formatPrivescType formats a privilege escalation type for display
 func formatPrivescType(vulnType string) string {
 	types := map[string]string{
@@ -1562,3 +1756,62 @@ func formatPrivescType(vulnType string) string {
 	}
 	return ui.InfoStyle.Render(vulnType)
 }
+
+// crackFile runs hash_cracker against a file: an encrypted file is decrypted if its key is in the
+// wordlist, otherwise every MD5 hash in the file is looked up.
+func (h *CommandHandler) crackFile(path, content string) *CommandResult {
+	userService := h.userService
+	userID := h.user.ID
+	vfs := h.vfs
+	absPath := vfs.AbsPath(path)
+
+	return h.createExploitProgressResult("hash_cracker", path, func() *CommandResult {
+		var output strings.Builder
+		output.WriteString(ui.HeaderStyle.Render("🔓 Cracking ") + ui.ValueStyle.Render(path) + "...\n")
+
+		if filesystem.IsEncrypted(content) {
+			for _, key := range services.WeakPasswords {
+				if _, err := filesystem.Decrypt(content, key); err == nil {
+					output.WriteString(ui.SuccessStyle.Render("Key found: ") + ui.SuccessStyleNoBold.Render(key) + "\n")
+					if name, err := vfs.DecryptFile(absPath, key); err == nil {
+						output.WriteString(ui.FormatKeyValuePair("Decrypted to:", name) + "\n")
+					}
+					userService.AddExperience(userID, 22)
+					return &CommandResult{Output: output.String()}
+				}
+			}
+			output.WriteString(ui.WarningStyle.Render("Key not in wordlist - look for it elsewhere on the server") + "\n")
+			if hint := filesystem.KeyHint(content); hint != "" {
+				output.WriteString(ui.FormatKeyValuePair("Key hint:", hint) + "\n")
+			}
+			return &CommandResult{Output: output.String()}
+		}
+
+		known := make(map[string]string, len(services.WeakPasswords))
+		for _, password := range services.WeakPasswords {
+			known[services.MD5Hex(password)] = password
+		}
+		cracked := 0
+		seen := make(map[string]bool)
+		output.WriteString(ui.FormatSectionHeader("Cracked hashes:", ""))
+		for _, hash := range md5Pattern.FindAllString(content, -1) {
+			password, ok := known[strings.ToLower(hash)]
+			if !ok || seen[hash] {
+				continue
+			}
+			seen[hash] = true
+			cracked++
+			output.WriteString(ui.FormatListBullet(ui.ValueStyle.Render(hash+":") + " " + ui.SuccessStyleNoBold.Render(password) + " " + ui.FormatKeyValuePair("(MD5)", "")))
+		}
+		if cracked == 0 {
+			output.WriteString(ui.WarningStyle.Render("No crackable hashes found") + "\n")
+			return &CommandResult{Output: output.String()}
+		}
+
+		userService.AddExperience(userID, 22)
+		return &CommandResult{Output: output.String()}
+	})
+}
+
+// md5Pattern matches hex MD5 hashes.
+var md5Pattern = regexp.MustCompile(`\b[0-9a-fA-F]{32}\b`)
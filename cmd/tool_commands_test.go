@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"terminal-sh/models"
+	"terminal-sh/services"
+)
+
+func TestSSHExploitNeedsAVulnerableService(t *testing.T) {
+	db := newTestDatabase(t)
+	handler := newTestHandler(t, db)
+	tool := &models.Tool{Name: "ssh_exploit", Function: "exploit", Exploits: []models.Exploit{{Type: "remote_code_execution", Level: 10}}}
+	if err := db.Create(tool).Error; err != nil {
+		t.Fatalf("failed to create tool: %v", err)
+	}
+	serverService := services.NewServerService(db)
+	if err := services.NewToolService(db, serverService).GrantToolToUser(handler.user.ID, tool.ID); err != nil {
+		t.Fatalf("failed to grant tool: %v", err)
+	}
+
+	server, err := serverService.CreateServer("203.0.113.22", "10.22.0.1")
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	server.Services = []models.Service{{
+		Name:            "ssh",
+		Port:            22,
+		Vulnerable:      false,
+		Vulnerabilities: []models.Vulnerability{{Type: "remote_code_execution", Level: 1}},
+	}}
+	if err := db.Save(server).Error; err != nil {
+		t.Fatalf("failed to save server: %v", err)
+	}
+
+	// The admin patched it: the vulnerability left on record doesn't count
+	result := handler.handleSSHExploit([]string{server.IP})
+	if result.Error == nil || !strings.Contains(result.Error.Error(), "patched") {
+		t.Fatalf("expected exploiting a patched service to fail, got %+v", result)
+	}
+
+	// A zero-day for the tool's exploit gets through anyway
+	if _, err := services.NewEventService(db, nil).Schedule(models.EventZeroDay, time.Now(), time.Hour, "remote_code_execution", nil); err != nil {
+		t.Fatalf("failed to start a zero-day: %v", err)
+	}
+	if result := handler.handleSSHExploit([]string{server.IP}); result.Error != nil || result.StartProgress == nil {
+		t.Fatalf("expected the zero-day to exploit the patched service, got %+v", result)
+	}
+}
@@ -0,0 +1,418 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"terminal-sh/models"
+	"terminal-sh/patch"
+	"terminal-sh/services"
+	"terminal-sh/ui"
+
+	"github.com/google/uuid"
+)
+
+// marketBookDepth is how many orders of each side the market shows
+const marketBookDepth = 10
+
+// handlePAY sends crypto to another player
+func (h *CommandHandler) handlePAY(args []string) *CommandResult {
+	if h.user == nil {
+		return &CommandResult{Error: fmt.Errorf("not authenticated")}
+	}
+	if len(args) != 2 {
+		return &CommandResult{Error: fmt.Errorf("usage: pay <user> <amount>")}
+	}
+	recipient, err := h.userService.GetUserByUsername(args[0])
+	if err != nil {
+		return &CommandResult{Error: fmt.Errorf("pay: %s: no such user", args[0])}
+	}
+	amount, err := strconv.ParseFloat(args[1], 64)
+	if err != nil || amount <= 0 {
+		return &CommandResult{Error: fmt.Errorf("pay: invalid amount: %s", args[1])}
+	}
+
+	if err := h.tradeService.Pay(h.user.ID, recipient.ID, amount); err != nil {
+		return &CommandResult{Error: fmt.Errorf("pay: %w", err)}
+	}
+	h.user.Wallet.Crypto -= amount
+	return &CommandResult{Output: ui.SuccessStyle.Render(fmt.Sprintf("✅ Sent %.2f crypto to %s", amount, recipient.Username)) + "\n"}
+}
+
+// handleTRADE handles escrowed trade offers between players
+func (h *CommandHandler) handleTRADE(args []string) *CommandResult {
+	if h.user == nil {
+		return &CommandResult{Error: fmt.Errorf("not authenticated")}
+	}
+	if h.tradeService == nil {
+		return &CommandResult{Error: fmt.Errorf("trade service not available")}
+	}
+	h.tradeService.ExpireTrades()
+
+	usage := fmt.Errorf("usage: trade - List open trades\n       trade offer <user> <item> <price> [hours] - Offer a tool, token:<type> or file\n       trade accept <id> - Pay for an offer made to you\n       trade cancel <id> - Withdraw your offer or decline one made to you")
+	if len(args) == 0 || (args[0] == "list" && len(args) == 1) {
+		return h.handleTradeList()
+	}
+
+	var result *CommandResult
+	switch {
+	case args[0] == "offer" && (len(args) == 4 || len(args) == 5):
+		buyer, err := h.userService.GetUserByUsername(args[1])
+		if err != nil {
+			return &CommandResult{Error: fmt.Errorf("trade: %s: no such user", args[1])}
+		}
+		result = h.openTrade(&buyer.ID, args[2], args[3], args[4:])
+	case args[0] == "accept" && len(args) == 2:
+		result = h.acceptTrade(args[1])
+	case (args[0] == "cancel" || args[0] == "decline") && len(args) == 2:
+		trade, err := h.tradeService.Cancel(h.user.ID, args[1])
+		if err != nil {
+			return &CommandResult{Error: fmt.Errorf("trade: %w", err)}
+		}
+		result = &CommandResult{Output: ui.SuccessStyle.Render(fmt.Sprintf("Trade %s %s: %s goes back to %s",
+			shortID(trade.ID), trade.Status, formatTradeItem(trade), h.usernameOf(trade.SellerID))) + "\n"}
+	default:
+		return &CommandResult{Error: usage}
+	}
+	if result.Error == nil {
+		result.Output += h.collectTradeDeliveries()
+	}
+	return result
+}
+
+// handleTradeList lists the user's open offers and listings, and the offers made to them
+func (h *CommandHandler) handleTradeList() *CommandResult {
+	trades, err := h.tradeService.GetTrades(h.user.ID)
+	if err != nil {
+		return &CommandResult{Error: err}
+	}
+
+	var output strings.Builder
+	output.WriteString(h.collectTradeDeliveries())
+	output.WriteString(ui.FormatSectionHeader("Open Trades:", "🤝"))
+	if len(trades) == 0 {
+		output.WriteString(ui.DimStyle.Render("  No open trades.") + "\n")
+	}
+	for _, trade := range trades {
+		var who string
+		switch {
+		case trade.SellerID != h.user.ID:
+			who = "from " + h.usernameOf(trade.SellerID)
+		case trade.Listed:
+			who = "listed on the market"
+		default:
+			who = "to " + h.usernameOf(*trade.BuyerID)
+		}
+		output.WriteString(ui.ListStyle.Render(fmt.Sprintf("  [%s] ", shortID(trade.ID))) + ui.AccentStyle.Render(formatTradeItem(&trade)) +
+			" " + ui.PriceStyle.Render(fmt.Sprintf("%.2f crypto", trade.PriceCrypto)) + "\n")
+		output.WriteString("      " + ui.DimStyle.Render(who+" · expires "+trade.ExpiresAt.Format("2006-01-02 15:04")) + "\n")
+	}
+	output.WriteString(ui.FormatUsage("Usage: trade offer <user> <item> <price> [hours] | trade accept <id> | trade cancel <id>"))
+	return &CommandResult{Output: output.String()}
+}
+
+// openTrade puts an item in escrow, offered to one player or listed on the market if buyerID is nil
+func (h *CommandHandler) openTrade(buyerID *uuid.UUID, itemSpec, priceArg string, rest []string) *CommandResult {
+	price, err := strconv.ParseFloat(priceArg, 64)
+	if err != nil || price < 0 {
+		return &CommandResult{Error: fmt.Errorf("trade: invalid price: %s", priceArg)}
+	}
+	ttl := services.DefaultTradeExpiry
+	if len(rest) > 0 {
+		hours, err := strconv.Atoi(rest[0])
+		if err != nil || hours <= 0 || hours > 168 {
+			return &CommandResult{Error: fmt.Errorf("trade: expiry must be 1-168 hours")}
+		}
+		ttl = time.Duration(hours) * time.Hour
+	}
+
+	item, lootPath, err := h.resolveTradeItem(itemSpec)
+	if err != nil {
+		return &CommandResult{Error: fmt.Errorf("trade: %w", err)}
+	}
+	trade, err := h.tradeService.Offer(h.user.ID, buyerID, item, price, ttl)
+	if err != nil {
+		return &CommandResult{Error: fmt.Errorf("trade: %w", err)}
+	}
+	if lootPath != "" {
+		if err := h.homeVFS.RemoveFile(lootPath); err != nil {
+			if abortErr := h.tradeService.Abort(trade); abortErr != nil {
+				return &CommandResult{Error: fmt.Errorf("trade: %s: %w (and the offer couldn't be withdrawn: %v)", itemSpec, err, abortErr)}
+			}
+			return &CommandResult{Error: fmt.Errorf("trade: %s: %w", itemSpec, err)}
+		}
+	}
+
+	to := "listed on the market"
+	if buyerID != nil {
+		to = "offered to " + h.usernameOf(*buyerID)
+	}
+	var output strings.Builder
+	output.WriteString(ui.SuccessStyle.Render(fmt.Sprintf("✅ %s %s for %.2f crypto", formatTradeItem(trade), to, price)) + "\n")
+	output.WriteString(ui.FormatKeyValuePair("Trade ID", shortID(trade.ID)) + "\n")
+	output.WriteString(ui.FormatKeyValuePair("Expires", trade.ExpiresAt.Format("2006-01-02 15:04")) + "\n")
+	output.WriteString(ui.DimStyle.Render("The item is held in escrow until the trade settles, is cancelled or expires.") + "\n")
+	return &CommandResult{Output: output.String()}
+}
+
+// acceptTrade pays for a trade and takes its item
+func (h *CommandHandler) acceptTrade(tradeID string) *CommandResult {
+	trade, err := h.tradeService.Accept(h.user.ID, tradeID)
+	if err != nil {
+		return &CommandResult{Error: fmt.Errorf("trade: %w", err)}
+	}
+	h.user.Wallet.Crypto -= trade.PriceCrypto
+
+	var output strings.Builder
+	output.WriteString(ui.SuccessStyle.Render(fmt.Sprintf("✅ Bought %s from %s for %.2f crypto",
+		formatTradeItem(trade), h.usernameOf(trade.SellerID), trade.PriceCrypto)) + "\n")
+	switch trade.ItemType {
+	case models.ItemTypeTool:
+		output.WriteString("Tool " + ui.AccentStyle.Render(trade.ItemName) + " has been added to your inventory, upgrades included.\n")
+	case models.ItemTypeUpgradeToken:
+		output.WriteString("Use 'patch <toolName> " + services.UpgradeTypeForToken(trade.ItemName) + "' to apply the token to a tool.\n")
+	}
+	return &CommandResult{Output: output.String()}
+}
+
+// resolveTradeItem works out what the player is trading: token:<type> for an upgrade token,
+// the name of a tool they own, or the path of a file in their home. For files it also returns
+// the path to remove once the file is in escrow.
+func (h *CommandHandler) resolveTradeItem(spec string) (services.TradeItem, string, error) {
+	if upgradeType, ok := strings.CutPrefix(spec, "token:"); ok {
+		parsed, valid := patch.ParseUpgradeType(upgradeType)
+		if !valid {
+			return services.TradeItem{}, "", fmt.Errorf("invalid upgrade type: %s", upgradeType)
+		}
+		return services.TradeItem{Type: models.ItemTypeUpgradeToken, Name: string(parsed)}, "", nil
+	}
+	if h.toolService.UserHasTool(h.user.ID, spec) {
+		return services.TradeItem{Type: models.ItemTypeTool, Name: spec}, "", nil
+	}
+
+	path := expandHome(h.homeVFS, spec)
+	node, err := h.homeVFS.Lstat(path)
+	if err != nil || node.IsDir || node.IsSymlink() {
+		return services.TradeItem{}, "", fmt.Errorf("%s: not a tool you own, token:<type> or a file in your home", spec)
+	}
+	content, err := h.homeVFS.ReadFileAtPath(path)
+	if err != nil {
+		return services.TradeItem{}, "", err
+	}
+	return services.TradeItem{Type: models.ItemTypeLoot, Name: filepath.Base(path), Content: content}, path, nil
+}
+
+// collectTradeDeliveries writes loot files bought by the user, or returned to them, into their
+// Downloads folder and describes what arrived
+func (h *CommandHandler) collectTradeDeliveries() string {
+	deliveries, err := h.tradeService.TakeDeliveries(h.user.ID)
+	if err != nil || len(deliveries) == 0 {
+		return ""
+	}
+	downloads := "/home/" + h.user.Username + "/Downloads"
+	var output strings.Builder
+	for _, trade := range deliveries {
+		name := trade.ItemName
+		if _, err := h.homeVFS.Lstat(downloads + "/" + name); err == nil {
+			name += "." + shortID(trade.ID)
+		}
+		if err := h.homeVFS.EnsureDirectoryAndCreateFile(downloads, name, trade.Content); err != nil {
+			// Left for the next delivery rather than lost
+			if h.tradeService.ReturnDelivery(&trade) == nil {
+				output.WriteString(ui.WarningStyle.Render(fmt.Sprintf("📦 %s couldn't be delivered (%v) - it will be retried", trade.ItemName, err)) + "\n")
+			}
+			continue
+		}
+		verb := "Received"
+		if trade.Status != models.TradeSettled {
+			verb = "Returned (" + string(trade.Status) + ")"
+		}
+		output.WriteString(ui.InfoStyle.Render(fmt.Sprintf("📦 %s: %s/%s", verb, downloads, name)) + "\n")
+	}
+	return output.String()
+}
+
+// handleMARKET handles the market's order book and item listings. Only available while
+// connected to the market's server.
+func (h *CommandHandler) handleMARKET(args []string) *CommandResult {
+	if h.user == nil {
+		return &CommandResult{Error: fmt.Errorf("not authenticated")}
+	}
+	if h.marketService == nil || h.tradeService == nil {
+		return &CommandResult{Error: fmt.Errorf("market service not available")}
+	}
+	shop, err := h.marketService.GetMarketShop()
+	if err != nil {
+		return &CommandResult{Error: fmt.Errorf("market: no market server is running")}
+	}
+	if h.currentServerPath == "" || h.GetEffectiveSourceIP() != shop.ServerIP {
+		return &CommandResult{Error: fmt.Errorf("market: connect to %s to trade", shop.ServerIP)}
+	}
+	h.tradeService.ExpireTrades()
+
+	usage := fmt.Errorf("usage: market - Show the order book and listings\n       market buy|sell <data> <price> - Place an order (price in crypto per data)\n       market orders - List your open orders\n       market cancel <id> - Cancel an order or listing\n       market list <item> <price> [hours] - List a tool, token:<type> or file for sale\n       market take <id> - Buy a listing")
+	if len(args) == 0 {
+		return h.handleMarketOverview(shop)
+	}
+
+	var result *CommandResult
+	switch {
+	case (args[0] == "buy" || args[0] == "sell") && len(args) == 3:
+		result = h.placeMarketOrder(models.OrderSide(args[0]), args[1], args[2])
+	case args[0] == "orders" && len(args) == 1:
+		result = h.handleMarketOrders()
+	case args[0] == "cancel" && len(args) == 2:
+		result = h.cancelMarketEntry(args[1])
+	case args[0] == "list" && (len(args) == 3 || len(args) == 4):
+		result = h.openTrade(nil, args[1], args[2], args[3:])
+	case args[0] == "take" && len(args) == 2:
+		result = h.acceptTrade(args[1])
+	default:
+		return &CommandResult{Error: usage}
+	}
+	if result.Error == nil {
+		result.Output += h.collectTradeDeliveries()
+	}
+	return result
+}
+
+// handleMarketOverview shows the order book and the items listed for sale
+func (h *CommandHandler) handleMarketOverview(shop *models.Shop) *CommandResult {
+	bids, asks, err := h.marketService.GetOrderBook(marketBookDepth)
+	if err != nil {
+		return &CommandResult{Error: err}
+	}
+	listings, err := h.tradeService.GetListings()
+	if err != nil {
+		return &CommandResult{Error: err}
+	}
+
+	var output strings.Builder
+	output.WriteString(h.collectTradeDeliveries())
+	output.WriteString(ui.FormatSectionHeader(shop.Name+" - Data/Crypto Order Book:", "📈"))
+	output.WriteString(ui.LabelStyle.Render(fmt.Sprintf("  %-10s %12s %12s", "", "Data", "Price")) + "\n")
+	for i := len(asks) - 1; i >= 0; i-- {
+		output.WriteString(ui.ErrorStyle.Render(fmt.Sprintf("  %-10s %12.2f %12.4f", "ask "+shortID(asks[i].ID)[:4], asks[i].Remaining, asks[i].Price)) + "\n")
+	}
+	if len(asks) == 0 && len(bids) == 0 {
+		output.WriteString(ui.DimStyle.Render("  No open orders.") + "\n")
+	} else {
+		output.WriteString(ui.DimStyle.Render("  "+strings.Repeat("─", 36)) + "\n")
+	}
+	for _, bid := range bids {
+		output.WriteString(ui.SuccessStyle.Render(fmt.Sprintf("  %-10s %12.2f %12.4f", "bid "+shortID(bid.ID)[:4], bid.Remaining, bid.Price)) + "\n")
+	}
+
+	output.WriteString("\n" + ui.FormatSectionHeader("Listings:", "🏷️"))
+	if len(listings) == 0 {
+		output.WriteString(ui.DimStyle.Render("  Nothing listed.") + "\n")
+	}
+	for _, listing := range listings {
+		output.WriteString(ui.ListStyle.Render(fmt.Sprintf("  [%s] ", shortID(listing.ID))) + ui.AccentStyle.Render(formatTradeItem(&listing)) +
+			" " + ui.PriceStyle.Render(fmt.Sprintf("%.2f crypto", listing.PriceCrypto)) + "\n")
+		output.WriteString("      " + ui.DimStyle.Render("by "+h.usernameOf(listing.SellerID)+" · expires "+listing.ExpiresAt.Format("2006-01-02 15:04")) + "\n")
+	}
+	output.WriteString(ui.FormatUsage("Usage: market buy|sell <data> <price> | market list <item> <price> [hours] | market take <id>"))
+	return &CommandResult{Output: output.String()}
+}
+
+// placeMarketOrder puts an order on the book and reports what filled
+func (h *CommandHandler) placeMarketOrder(side models.OrderSide, quantityArg, priceArg string) *CommandResult {
+	quantity, err := strconv.ParseFloat(quantityArg, 64)
+	if err != nil || quantity <= 0 {
+		return &CommandResult{Error: fmt.Errorf("market: invalid quantity: %s", quantityArg)}
+	}
+	price, err := strconv.ParseFloat(priceArg, 64)
+	if err != nil || price <= 0 {
+		return &CommandResult{Error: fmt.Errorf("market: invalid price: %s", priceArg)}
+	}
+
+	order, fills, err := h.marketService.PlaceOrder(h.user.ID, side, quantity, price)
+	if err != nil {
+		return &CommandResult{Error: fmt.Errorf("market: %w", err)}
+	}
+	if user, err := h.userService.GetUserByID(h.user.ID); err == nil {
+		h.user.Wallet = user.Wallet
+	}
+
+	var output strings.Builder
+	output.WriteString(ui.SuccessStyle.Render(fmt.Sprintf("✅ %s order %s: %.2f data at %.4f crypto", side, shortID(order.ID), quantity, price)) + "\n")
+	for _, fill := range fills {
+		counterparty := fill.SellerID
+		if side == models.OrderSell {
+			counterparty = fill.BuyerID
+		}
+		output.WriteString(ui.InfoStyle.Render(fmt.Sprintf("  Filled %.2f data at %.4f with %s", fill.Quantity, fill.Price, h.usernameOf(counterparty))) + "\n")
+	}
+	if order.Status == models.OrderFilled {
+		output.WriteString(ui.SuccessStyle.Render("  Order fully filled") + "\n")
+	} else {
+		output.WriteString(ui.DimStyle.Render(fmt.Sprintf("  %.2f data left on the book", order.Remaining)) + "\n")
+	}
+	return &CommandResult{Output: output.String()}
+}
+
+// handleMarketOrders lists the user's open orders and listings
+func (h *CommandHandler) handleMarketOrders() *CommandResult {
+	orders, err := h.marketService.GetUserOrders(h.user.ID)
+	if err != nil {
+		return &CommandResult{Error: err}
+	}
+	var output strings.Builder
+	output.WriteString(ui.FormatSectionHeader("Your Orders:", "📋"))
+	if len(orders) == 0 {
+		output.WriteString(ui.DimStyle.Render("  No open orders.") + "\n")
+	}
+	for _, order := range orders {
+		output.WriteString(ui.ListStyle.Render(fmt.Sprintf("  [%s] ", shortID(order.ID))) +
+			ui.ValueStyle.Render(fmt.Sprintf("%s %.2f/%.2f data at %.4f crypto", order.Side, order.Remaining, order.Quantity, order.Price)) + "\n")
+	}
+	return &CommandResult{Output: output.String()}
+}
+
+// cancelMarketEntry cancels one of the user's orders, or failing that one of their listings
+func (h *CommandHandler) cancelMarketEntry(id string) *CommandResult {
+	order, err := h.marketService.CancelOrder(h.user.ID, id)
+	if err == nil {
+		if user, err := h.userService.GetUserByID(h.user.ID); err == nil {
+			h.user.Wallet = user.Wallet
+		}
+		return &CommandResult{Output: ui.SuccessStyle.Render(fmt.Sprintf("Order %s cancelled, %.2f data unfilled refunded", shortID(order.ID), order.Remaining)) + "\n"}
+	}
+	if !errors.Is(err, services.ErrOrderNotFound) {
+		return &CommandResult{Error: fmt.Errorf("market: %w", err)}
+	}
+	trade, err := h.tradeService.Cancel(h.user.ID, id)
+	if err != nil {
+		return &CommandResult{Error: fmt.Errorf("market: %w", err)}
+	}
+	return &CommandResult{Output: ui.SuccessStyle.Render(fmt.Sprintf("Listing %s cancelled: %s goes back to you", shortID(trade.ID), formatTradeItem(trade))) + "\n"}
+}
+
+// formatTradeItem describes a traded item, e.g. "tool nmap"
+func formatTradeItem(trade *models.Trade) string {
+	switch trade.ItemType {
+	case models.ItemTypeUpgradeToken:
+		return "token " + trade.ItemName
+	case models.ItemTypeLoot:
+		return "file " + trade.ItemName
+	}
+	return string(trade.ItemType) + " " + trade.ItemName
+}
+
+// usernameOf returns the username of a user, or "unknown" if they no longer exist
+func (h *CommandHandler) usernameOf(userID uuid.UUID) string {
+	if user, err := h.userService.GetUserByID(userID); err == nil {
+		return user.Username
+	}
+	return "unknown"
+}
+
+// shortID returns the first 8 characters of an ID, as shown to players
+func shortID(id uuid.UUID) string {
+	return id.String()[:8]
+}
@@ -0,0 +1,194 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"terminal-sh/filesystem"
+	"terminal-sh/ui"
+)
+
+// homeHost is the host name scp uses for the player's own computer.
+const homeHost = "home"
+
+// ConnectedHost is a machine in the player's chain of connections: their home computer
+// (empty ServerPath) or a server they're logged in to.
+type ConnectedHost struct {
+	ServerPath string
+	VFS        *filesystem.VFS
+}
+
+// SetConnectionChain sets the function returning the player's chain of connections, from their
+// home computer to the current server. scp copies between any two machines in the chain.
+func (h *CommandHandler) SetConnectionChain(chain func() []ConnectedHost) {
+	h.connectionChain = chain
+}
+
+// connectedHosts returns the chain of connections. Without a chain set, the intermediate hops
+// are unknown and only the home computer and the current server are returned.
+func (h *CommandHandler) connectedHosts() []ConnectedHost {
+	if h.connectionChain != nil {
+		if hosts := h.connectionChain(); len(hosts) > 0 {
+			return hosts
+		}
+	}
+	hosts := []ConnectedHost{{VFS: h.homeVFS}}
+	if h.currentServerPath != "" {
+		hosts = append(hosts, ConnectedHost{ServerPath: h.currentServerPath, VFS: h.vfs})
+	}
+	return hosts
+}
+
+// hostName returns the name scp uses for a host: "home" or the server's IP.
+func hostName(host ConnectedHost) string {
+	if host.ServerPath == "" {
+		return homeHost
+	}
+	parts := strings.Split(host.ServerPath, ".localNetwork.")
+	return parts[len(parts)-1]
+}
+
+// parseTransferPath splits an scp argument like 10.0.0.5:/tmp/x into the index of its host in
+// hosts and the path. Without a host, the path is on the current machine (the last host).
+func parseTransferPath(arg string, hosts []ConnectedHost) (int, string, error) {
+	host, path, found := strings.Cut(arg, ":")
+	if !found {
+		return len(hosts) - 1, arg, nil
+	}
+	if host == "localhost" {
+		host = homeHost
+	}
+	for i, candidate := range hosts {
+		if hostName(candidate) == host {
+			if path == "" {
+				path = "."
+			}
+			return i, path, nil
+		}
+	}
+	return 0, "", fmt.Errorf("%s: not connected (scp only reaches machines in your connection chain)", host)
+}
+
+// handleSCP handles the scp command
+func (h *CommandHandler) handleSCP(args []string) *CommandResult {
+	if len(args) != 2 {
+		return &CommandResult{Error: fmt.Errorf("usage: scp [host:]<source> [host:]<dest>\n       host is 'home' or the IP of a server in your connection chain")}
+	}
+
+	hosts := h.connectedHosts()
+	src, srcPath, err := parseTransferPath(args[0], hosts)
+	if err != nil {
+		return &CommandResult{Error: fmt.Errorf("scp: %w", err)}
+	}
+	dst, dstPath, err := parseTransferPath(args[1], hosts)
+	if err != nil {
+		return &CommandResult{Error: fmt.Errorf("scp: %w", err)}
+	}
+	if src == dst {
+		return &CommandResult{Error: fmt.Errorf("scp: source and destination are on the same machine (use cp)")}
+	}
+	return h.startTransfer("scp", hosts, src, srcPath, dst, dstPath)
+}
+
+// handleUPLOAD handles the upload command: scp from the home computer to the current server
+func (h *CommandHandler) handleUPLOAD(args []string) *CommandResult {
+	if len(args) < 1 || len(args) > 2 {
+		return &CommandResult{Error: fmt.Errorf("usage: upload <local_path> [remote_path]\n       When connected to a server, copies a file from your home computer to it")}
+	}
+	if h.currentServerPath == "" {
+		return &CommandResult{Error: fmt.Errorf("upload: must be connected to a server first (use ssh, telnet, or connect)")}
+	}
+
+	dstPath := "."
+	if len(args) == 2 {
+		dstPath = args[1]
+	}
+	hosts := h.connectedHosts()
+	return h.startTransfer("upload", hosts, 0, args[0], len(hosts)-1, dstPath)
+}
+
+// startTransfer reads the source file and returns a progress operation that writes it to the
+// destination once the transfer time has passed. The time depends on the file size and the
+// bandwidth of every machine between the two.
+func (h *CommandHandler) startTransfer(cmdName string, hosts []ConnectedHost, src int, srcPath string, dst int, dstPath string) *CommandResult {
+	source, dest := hosts[src], hosts[dst]
+	// Resolved now: the destination's working directory may change before the transfer ends
+	srcPath, dstPath = expandHome(source.VFS, srcPath), dest.VFS.AbsPath(expandHome(dest.VFS, dstPath))
+	if node, err := source.VFS.Stat(srcPath); err == nil && node.IsDir {
+		return &CommandResult{Error: fmt.Errorf("%s: %s: is a directory", cmdName, srcPath)}
+	}
+	content, err := source.VFS.ReadFileAtPath(srcPath)
+	if err != nil {
+		return &CommandResult{Error: fmt.Errorf("%s: %w", cmdName, err)}
+	}
+
+	// Every machine on the route, source to destination
+	lo, hi := src, dst
+	if lo > hi {
+		lo, hi = hi, lo
+	}
+	var bandwidths []float64
+	for _, host := range hosts[lo : hi+1] {
+		bandwidths = append(bandwidths, h.hostBandwidth(host))
+	}
+
+	duration := 3.0
+	if h.progressService != nil {
+		duration = h.progressService.CalculateTransferTime(len(content), bandwidths)
+	}
+
+	fileName := filepath.Base(srcPath)
+	srcName, dstName := hostName(source), hostName(dest)
+	hops := hi - lo
+
+	return &CommandResult{
+		StartProgress: &ProgressOperationRequest{
+			ID:       fmt.Sprintf("%s-%s-%d", cmdName, fileName, time.Now().UnixNano()),
+			Message:  fmt.Sprintf("Transferring %s from %s to %s...", fileName, srcName, dstName),
+			Duration: duration,
+			Operation: func() *CommandResult {
+				written, err := dest.VFS.ReceiveFile(dstPath, fileName, content)
+				if err != nil {
+					return &CommandResult{Error: fmt.Errorf("%s: %w", cmdName, err)}
+				}
+				// Copying a file from a server back home takes its data
+				if dst == 0 && src > 0 {
+					h.trackDataExtraction(cmdName, source.ServerPath, len(content))
+				}
+				var output strings.Builder
+				output.WriteString(ui.SuccessStyle.Render("📤 Copied: ") + ui.ValueStyle.Render(fileName) +
+					ui.SuccessStyle.Render(" → ") + ui.ValueStyle.Render(dstName+":"+written) + "\n")
+				output.WriteString(ui.FormatKeyValuePair("Size:", fmt.Sprintf("%d bytes", len(content))) + "  " +
+					ui.FormatKeyValuePair("Hops:", fmt.Sprintf("%d", hops)) + "  " +
+					ui.FormatKeyValuePair("Time:", fmt.Sprintf("%.1fs", duration)) + "\n")
+				return &CommandResult{Output: output.String()}
+			},
+		},
+	}
+}
+
+// expandHome replaces a leading ~ in path with the home directory of the VFS's current role.
+func expandHome(vfs *filesystem.VFS, path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		return vfs.GetHomeDir() + strings.TrimPrefix(path, "~")
+	}
+	return path
+}
+
+// hostBandwidth returns a machine's bandwidth: the player's own for their home computer,
+// the server's for a server. Returns 0 if it's unknown.
+func (h *CommandHandler) hostBandwidth(host ConnectedHost) float64 {
+	if host.ServerPath == "" {
+		if h.user != nil {
+			return h.user.Resources.Bandwidth
+		}
+		return 0
+	}
+	server, err := h.serverService.GetServerByPath(host.ServerPath)
+	if err != nil {
+		return 0
+	}
+	return server.Resources.Bandwidth
+}
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"testing"
+
+	"terminal-sh/filesystem"
+	"terminal-sh/services"
+)
+
+// A machine on the route whose bandwidth is unknown still adds a link to the transfer.
+func TestTransferThroughUnknownHostCountsItsLink(t *testing.T) {
+	db := newTestDatabase(t)
+	handler := newTestHandler(t, db)
+	if err := handler.homeVFS.EnsureDirectoryAndCreateFile("/home/mallory", "loot.txt", "secrets"); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+	server, err := services.NewServerService(db).CreateServer("203.0.113.40", "10.40.0.1")
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	serverVFS := filesystem.NewVFS("root")
+	serverVFS.SetRole("root", true, "/root")
+
+	home := ConnectedHost{VFS: handler.homeVFS}
+	target := ConnectedHost{ServerPath: server.IP, VFS: serverVFS}
+	unknown := ConnectedHost{ServerPath: "198.51.100.99", VFS: filesystem.NewVFS("root")}
+
+	direct := handler.startTransfer("scp", []ConnectedHost{home, target}, 0, "/home/mallory/loot.txt", 1, "/root")
+	hopped := handler.startTransfer("scp", []ConnectedHost{home, unknown, target}, 0, "/home/mallory/loot.txt", 2, "/root")
+	if direct.Error != nil || hopped.Error != nil {
+		t.Fatalf("expected both transfers to start, got %v and %v", direct.Error, hopped.Error)
+	}
+	if hopped.StartProgress.Duration <= direct.StartProgress.Duration {
+		t.Fatalf("expected the extra hop to take longer: %.2f vs %.2f", hopped.StartProgress.Duration, direct.StartProgress.Duration)
+	}
+}
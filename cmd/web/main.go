@@ -1,19 +1,25 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"terminal-sh/config"
 	"terminal-sh/database"
 	"terminal-sh/services"
+	"terminal-sh/terminal"
 	"terminal-sh/terminal/websocket"
 	"terminal-sh/ui"
 )
 
+// drainTimeout bounds how long shutdown waits for sessions and in-flight operations.
+const drainTimeout = 10 * time.Second
+
 func main() {
 	cfg := config.Load()
 
@@ -75,6 +81,12 @@ func main() {
 	fmt.Println(ui.InfoStyle.Render(readyBox))
 	fmt.Println()
 
+	// Start the background jobs (sysadmin patrols, mining payouts, live events). Deferred after the
+	// database close, so they stop first
+	scheduler := services.NewWorldScheduler(db, chatService)
+	scheduler.Start()
+	defer scheduler.Stop()
+
 	// Set up signal handling for graceful shutdown
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
@@ -93,9 +105,27 @@ func main() {
 		fmt.Printf("\n\n")
 		fmt.Println(ui.InfoStyle.Render(fmt.Sprintf("Received signal: %v", sig)))
 		fmt.Println(ui.InfoStyle.Render("Shutting down gracefully..."))
-		// HTTP server shutdown would go here if needed
-		fmt.Println(ui.SuccessStyle.Render("✓ Server shut down gracefully"))
-		
+
+		// Notify sessions, let in-flight operations finish and flush filesystems
+		drainCtx, cancelDrain := context.WithTimeout(context.Background(), drainTimeout)
+		persisted, err := terminal.Shutdown(drainCtx, "server restarting")
+		cancelDrain()
+		if err != nil {
+			log.Printf(ui.ErrorStyle.Render("Error draining sessions: %v"), err)
+		} else {
+			fmt.Println(ui.SuccessStyle.Render(fmt.Sprintf("✓ Sessions drained (%d operations saved for resumption)", persisted)))
+		}
+
+		// Give the server a moment to finish current requests
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := websocket.ShutdownHTTPServer(ctx); err != nil {
+			log.Printf(ui.ErrorStyle.Render("Error shutting down web server: %v"), err)
+		} else {
+			fmt.Println(ui.SuccessStyle.Render("✓ Server shut down gracefully"))
+		}
+
 	case err := <-serverErr:
 		log.Fatalf("Server error: %v", err)
 	}
@@ -5,6 +5,7 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -19,6 +20,7 @@ type Config struct {
 	DatabasePath string // Path to SQLite database file (default: "data/terminal.db")
 	DatabaseURL  string // PostgreSQL connection URL (optional, takes precedence over DatabasePath)
 	JWTSecret    string // Secret key for JWT token signing
+	JWTPreviousSecrets []string // Retired JWT secrets still accepted for validation during key rotation
 }
 
 // Procedural generation configuration constants
@@ -49,6 +51,7 @@ func Load() *Config {
 	databasePath := getEnv("DATABASE_PATH", "data/terminal.db") // Default: data/terminal.db
 	databaseURL := getEnv("DATABASE_URL", "") // For PostgreSQL support
 	jwtSecret := getEnv("JWT_SECRET", "change-this-secret-key-in-production")
+	jwtPreviousSecrets := getEnvList("JWT_PREVIOUS_SECRETS") // Comma-separated retired secrets
 
 	return &Config{
 		Host:        host,
@@ -59,6 +62,7 @@ func Load() *Config {
 		DatabasePath: databasePath,
 		DatabaseURL:  databaseURL,
 		JWTSecret:    jwtSecret,
+		JWTPreviousSecrets: jwtPreviousSecrets,
 	}
 }
 
@@ -69,6 +73,16 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
 func getEnvInt(key string, defaultValue int) int {
 	if value := os.Getenv(key); value != "" {
 		if intValue, err := strconv.Atoi(value); err == nil {
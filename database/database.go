@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"terminal-sh/filesystem"
 	"terminal-sh/models"
 
 	"github.com/glebarez/sqlite"
@@ -60,6 +61,11 @@ func NewDB(dbPath, dbURL string) (*Database, error) {
 
 	db := &Database{DB: gormDB}
 
+	// Record query latency for the /metrics endpoint
+	if err := db.registerMetricsCallbacks(); err != nil {
+		return nil, err
+	}
+
 	// Run migrations
 	if err := db.Migrate(); err != nil {
 		return nil, fmt.Errorf("failed to run migrations: %w", err)
@@ -82,9 +88,15 @@ func (db *Database) Migrate() error {
 		&models.ShopItem{},
 		&models.UserPurchase{},
 		&models.Server{},
+		&models.ServerOverlay{},
 		&models.UserAchievement{},
+		&models.AchievementCounter{},
 		&models.ExploitedServer{},
 		&models.ActiveMiner{},
+		&models.MiningPool{},
+		&models.MiningPoolMember{},
+		&models.SysAdmin{},
+		&models.SessionKick{},
 		&models.Session{},
 		&models.ChatRoom{},
 		&models.ChatMessage{},
@@ -98,11 +110,71 @@ func (db *Database) Migrate() error {
 		&models.BackdoorAccess{},
 		&models.PrivilegeEscalation{},
 		&models.TrackedAction{},
+		&models.InterruptedOperation{},
+		&models.PasswordResetToken{},
+		&models.LoginThrottle{},
+		&models.DeviceSession{},
+		&models.LedgerEntry{},
+		&models.UpgradeToken{},
+		&models.Trade{},
+		&models.MarketOrder{},
+		&models.Bounty{},
+		&models.LiveEvent{},
+		&models.EventScore{},
+		&models.Notification{},
 	)
 	if err != nil {
 		return fmt.Errorf("failed to auto-migrate: %w", err)
 	}
 
+	if err := db.migrateFileSystems(); err != nil {
+		return fmt.Errorf("failed to migrate filesystems: %w", err)
+	}
+
+	return nil
+}
+
+// migrateFileSystems upgrades persisted user and server filesystems and players' server overlays
+// to the current filesystem.ChangesVersion format. Filesystems already at that version are left alone.
+func (db *Database) migrateFileSystems() error {
+	var users []models.User
+	if err := db.Select("id", "file_system").Where("file_system IS NOT NULL").Find(&users).Error; err != nil {
+		return err
+	}
+	for i := range users {
+		if fs, changed := filesystem.MigrateChanges(users[i].FileSystem); changed {
+			users[i].FileSystem = fs
+			if err := db.Model(&users[i]).Select("file_system").Updates(&users[i]).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	var servers []models.Server
+	if err := db.Select("id", "file_system").Where("file_system IS NOT NULL").Find(&servers).Error; err != nil {
+		return err
+	}
+	for i := range servers {
+		if fs, changed := filesystem.MigrateChanges(servers[i].FileSystem); changed {
+			servers[i].FileSystem = fs
+			if err := db.Model(&servers[i]).Select("file_system").Updates(&servers[i]).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	var overlays []models.ServerOverlay
+	if err := db.Select("id", "file_system", "version").Where("version < ?", filesystem.ChangesVersion).Find(&overlays).Error; err != nil {
+		return err
+	}
+	for i := range overlays {
+		overlays[i].FileSystem, _ = filesystem.MigrateOverlay(overlays[i].FileSystem, overlays[i].Version)
+		overlays[i].Version = filesystem.ChangesVersion
+		if err := db.Model(&overlays[i]).Select("file_system", "version").Updates(&overlays[i]).Error; err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -0,0 +1,58 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+
+	"terminal-sh/filesystem"
+	"terminal-sh/models"
+
+	"github.com/google/uuid"
+)
+
+func TestMigrateFileSystemsUpgradesServerOverlays(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "test.db"), "")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	// An overlay saved before overlays were versioned, diffed from nested version 1 maps
+	overlay := models.ServerOverlay{
+		UserID:   uuid.New(),
+		ServerID: uuid.New(),
+		FileSystem: map[string]interface{}{
+			"home": map[string]interface{}{
+				"home": map[string]interface{}{
+					"guest": map[string]interface{}{
+						"home": map[string]interface{}{
+							"guest": map[string]interface{}{
+								"todo.txt": map[string]interface{}{"content": "cover tracks"},
+							},
+						},
+					},
+				},
+			},
+		},
+		Version: 1,
+	}
+	if err := db.Create(&overlay).Error; err != nil {
+		t.Fatalf("failed to create overlay: %v", err)
+	}
+
+	if err := db.migrateFileSystems(); err != nil {
+		t.Fatalf("migrateFileSystems failed: %v", err)
+	}
+
+	var migrated models.ServerOverlay
+	if err := db.First(&migrated, "id = ?", overlay.ID).Error; err != nil {
+		t.Fatalf("failed to reload overlay: %v", err)
+	}
+	if migrated.Version != filesystem.ChangesVersion {
+		t.Fatalf("expected overlay at version %d, got %d", filesystem.ChangesVersion, migrated.Version)
+	}
+	content, err := filesystem.NewMapFileReader(migrated.FileSystem).ReadFile("/home/guest/todo.txt")
+	if err != nil || content != "cover tracks" {
+		t.Fatalf("expected the overlay unnested, got %q (%v)", content, err)
+	}
+}
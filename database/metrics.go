@@ -0,0 +1,65 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"terminal-sh/metrics"
+
+	"gorm.io/gorm"
+)
+
+const queryStartKey = "metrics:query_start"
+
+// registerMetricsCallbacks hooks GORM's callback chains to record query latency
+// for every create, query, update, delete, row and raw operation.
+func (db *Database) registerMetricsCallbacks() error {
+	before := func(tx *gorm.DB) {
+		tx.InstanceSet(queryStartKey, time.Now())
+	}
+	after := func(operation string) func(*gorm.DB) {
+		return func(tx *gorm.DB) {
+			if start, ok := tx.InstanceGet(queryStartKey); ok {
+				if t, ok := start.(time.Time); ok {
+					metrics.DBQueryDuration.WithLabelValues(operation).Observe(time.Since(t).Seconds())
+				}
+			}
+		}
+	}
+
+	cb := db.Callback()
+	hooks := []struct {
+		operation string
+		before    func(string, func(*gorm.DB)) error
+		after     func(string, func(*gorm.DB)) error
+	}{
+		{"create", cb.Create().Before("gorm:create").Register, cb.Create().After("gorm:create").Register},
+		{"query", cb.Query().Before("gorm:query").Register, cb.Query().After("gorm:query").Register},
+		{"update", cb.Update().Before("gorm:update").Register, cb.Update().After("gorm:update").Register},
+		{"delete", cb.Delete().Before("gorm:delete").Register, cb.Delete().After("gorm:delete").Register},
+		{"row", cb.Row().Before("gorm:row").Register, cb.Row().After("gorm:row").Register},
+		{"raw", cb.Raw().Before("gorm:raw").Register, cb.Raw().After("gorm:raw").Register},
+	}
+
+	for _, hook := range hooks {
+		if err := hook.before("metrics:before_"+hook.operation, before); err != nil {
+			return fmt.Errorf("failed to register %s metrics callback: %w", hook.operation, err)
+		}
+		if err := hook.after("metrics:after_"+hook.operation, after(hook.operation)); err != nil {
+			return fmt.Errorf("failed to register %s metrics callback: %w", hook.operation, err)
+		}
+	}
+
+	return nil
+}
+
+// Ping verifies the database connection is alive.
+// Used by the health and readiness endpoints.
+func (db *Database) Ping(ctx context.Context) error {
+	sqlDB, err := db.DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
@@ -0,0 +1,414 @@
+package filesystem
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// Binary loot (archives, compressed and encrypted files) is stored as ASCII armor - base64 between
+// BEGIN/END lines - because file content has to survive being persisted as JSON text.
+// The data inside is real: a tar.gz made here can be opened by the tar package and vice versa.
+
+const (
+	armorEncrypted = "ENCRYPTED FILE"
+	armorTar       = "TAR ARCHIVE"
+	armorZip       = "ZIP ARCHIVE"
+	armorGzip      = "GZIP DATA"
+)
+
+// ErrNotEncrypted is returned when decrypting a file that isn't encrypted.
+var ErrNotEncrypted = errors.New("not an encrypted file")
+
+// ErrWrongKey is returned when a file can't be decrypted with the given key.
+var ErrWrongKey = errors.New("bad decrypt: wrong key")
+
+// ErrNotArchive is returned when extracting a file that isn't a tar or zip archive.
+var ErrNotArchive = errors.New("not a tar or zip archive")
+
+// ErrNotCompressed is returned when decompressing a file that isn't gzip data.
+var ErrNotCompressed = errors.New("not in gzip format")
+
+// ArchiveEntry is a file or directory inside an archive.
+type ArchiveEntry struct {
+	Name    string // Path inside the archive, e.g. "backup/config.ini"
+	Content string
+	IsDir   bool
+}
+
+// armor wraps data in BEGIN/END lines with base64 content.
+func armor(kind string, headers []string, data []byte) string {
+	var b strings.Builder
+	b.WriteString("-----BEGIN " + kind + "-----\n")
+	for _, header := range headers {
+		b.WriteString(header + "\n")
+	}
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 64 {
+		b.WriteString(encoded[:64] + "\n")
+		encoded = encoded[64:]
+	}
+	b.WriteString(encoded + "\n")
+	b.WriteString("-----END " + kind + "-----\n")
+	return b.String()
+}
+
+// dearmor returns the data inside armor of the given kind. Header lines ("Key: value") are skipped.
+func dearmor(kind, content string) ([]byte, bool) {
+	begin, end := "-----BEGIN "+kind+"-----", "-----END "+kind+"-----"
+	start := strings.Index(content, begin)
+	stop := strings.Index(content, end)
+	if start < 0 || stop < start {
+		return nil, false
+	}
+
+	var encoded strings.Builder
+	for _, line := range strings.Split(content[start+len(begin):stop], "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.Contains(line, ": ") {
+			continue
+		}
+		encoded.WriteString(line)
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded.String())
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// --- Encryption ---
+
+// IsEncrypted reports whether content is an encrypted file.
+func IsEncrypted(content string) bool {
+	return strings.Contains(content, "-----BEGIN "+armorEncrypted+"-----")
+}
+
+// Encrypt encrypts plaintext with AES-256-GCM using a key derived from the passphrase.
+// hint is stored in the clear as a Key-Hint header (empty for none).
+func Encrypt(plaintext, key, hint string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	headers := []string{"Cipher: AES-256-GCM"}
+	if hint != "" {
+		headers = append(headers, "Key-Hint: "+hint)
+	}
+	return armor(armorEncrypted, headers, gcm.Seal(nonce, nonce, []byte(plaintext), nil)), nil
+}
+
+// Decrypt decrypts content produced by Encrypt.
+// Returns ErrNotEncrypted or ErrWrongKey if it can't.
+func Decrypt(content, key string) (string, error) {
+	data, ok := dearmor(armorEncrypted, content)
+	if !ok {
+		return "", ErrNotEncrypted
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", ErrWrongKey
+	}
+	plaintext, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", ErrWrongKey
+	}
+	return string(plaintext), nil
+}
+
+// KeyHint returns the key hint stored in an encrypted file, or "" if there is none.
+func KeyHint(content string) string {
+	for _, line := range strings.Split(content, "\n") {
+		if hint, ok := strings.CutPrefix(line, "Key-Hint: "); ok {
+			return hint
+		}
+	}
+	return ""
+}
+
+// newGCM returns an AES-256-GCM cipher keyed by the SHA-256 of the passphrase.
+func newGCM(key string) (cipher.AEAD, error) {
+	sum := sha256.Sum256([]byte(key))
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// --- Compression ---
+
+// Compress gzips content.
+func Compress(content string) (string, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write([]byte(content)); err != nil {
+		return "", err
+	}
+	if err := gz.Close(); err != nil {
+		return "", err
+	}
+	return armor(armorGzip, nil, buf.Bytes()), nil
+}
+
+// Decompress reverses Compress. Returns ErrNotCompressed for anything else.
+func Decompress(content string) (string, error) {
+	data, ok := dearmor(armorGzip, content)
+	if !ok {
+		return "", ErrNotCompressed
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return "", ErrNotCompressed
+	}
+	plain, err := io.ReadAll(gz)
+	if err != nil {
+		return "", fmt.Errorf("corrupt gzip data: %w", err)
+	}
+	return string(plain), nil
+}
+
+// --- Archives ---
+
+// CreateTar packs entries into a gzip-compressed tar archive.
+func CreateTar(entries []ArchiveEntry) (string, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, entry := range entries {
+		header := &tar.Header{Name: entry.Name, Mode: 0644, Size: int64(len(entry.Content)), ModTime: defaultModTime}
+		if entry.IsDir {
+			header = &tar.Header{Name: strings.TrimSuffix(entry.Name, "/") + "/", Mode: 0755, Typeflag: tar.TypeDir, ModTime: defaultModTime}
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return "", err
+		}
+		if !entry.IsDir {
+			if _, err := tw.Write([]byte(entry.Content)); err != nil {
+				return "", err
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return "", err
+	}
+	if err := gz.Close(); err != nil {
+		return "", err
+	}
+	return armor(armorTar, nil, buf.Bytes()), nil
+}
+
+// CreateZip packs entries into a zip archive.
+func CreateZip(entries []ArchiveEntry) (string, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, entry := range entries {
+		if entry.IsDir {
+			if _, err := zw.Create(strings.TrimSuffix(entry.Name, "/") + "/"); err != nil {
+				return "", err
+			}
+			continue
+		}
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: entry.Name, Method: zip.Deflate, Modified: defaultModTime})
+		if err != nil {
+			return "", err
+		}
+		if _, err := w.Write([]byte(entry.Content)); err != nil {
+			return "", err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return "", err
+	}
+	return armor(armorZip, nil, buf.Bytes()), nil
+}
+
+// ReadArchive returns the entries of a tar (optionally gzipped) or zip archive.
+// Returns ErrNotArchive for anything else.
+func ReadArchive(content string) ([]ArchiveEntry, error) {
+	if data, ok := dearmor(armorTar, content); ok {
+		return readTar(data)
+	}
+	if data, ok := dearmor(armorZip, content); ok {
+		return readZip(data)
+	}
+	return nil, ErrNotArchive
+}
+
+// readTar reads the entries of a tar archive, gunzipping it first if needed.
+func readTar(data []byte) ([]ArchiveEntry, error) {
+	var r io.Reader = bytes.NewReader(data)
+	if len(data) > 2 && data[0] == 0x1f && data[1] == 0x8b {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("corrupt archive: %w", err)
+		}
+		r = gz
+	}
+
+	var entries []ArchiveEntry
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("corrupt archive: %w", err)
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			entries = append(entries, ArchiveEntry{Name: header.Name, IsDir: true})
+		case tar.TypeReg:
+			content, err := io.ReadAll(tr)
+			if err != nil {
+				return nil, fmt.Errorf("corrupt archive: %w", err)
+			}
+			entries = append(entries, ArchiveEntry{Name: header.Name, Content: string(content)})
+		}
+	}
+}
+
+// readZip reads the entries of a zip archive.
+func readZip(data []byte) ([]ArchiveEntry, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("corrupt archive: %w", err)
+	}
+
+	var entries []ArchiveEntry
+	for _, file := range zr.File {
+		if file.FileInfo().IsDir() {
+			entries = append(entries, ArchiveEntry{Name: file.Name, IsDir: true})
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("corrupt archive: %w", err)
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("corrupt archive: %w", err)
+		}
+		entries = append(entries, ArchiveEntry{Name: file.Name, Content: string(content)})
+	}
+	return entries, nil
+}
+
+// --- VFS operations ---
+
+// ExtractArchive unpacks the tar or zip archive at path into the current directory, like tar -x
+// and unzip. Entries can't escape the current directory. Existing files are overwritten.
+// Returns the extracted paths relative to the current directory.
+// Triggers the save callback if set to persist the change.
+func (vfs *VFS) ExtractArchive(path string) ([]string, error) {
+	content, err := vfs.ReadFileAtPath(path)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := ReadArchive(content)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	cwd := vfs.GetCurrentPath()
+	var extracted []string
+	for _, entry := range entries {
+		// Cleaning against "/" drops any leading "../"
+		name := strings.TrimPrefix(filepath.Clean("/"+entry.Name), "/")
+		if name == "" {
+			continue
+		}
+		target := filepath.Join(cwd, name)
+		if entry.IsDir {
+			_, err = vfs.ensureDir(target)
+		} else {
+			err = vfs.putFile(filepath.Dir(target), filepath.Base(target), entry.Content)
+		}
+		if err != nil {
+			return extracted, err
+		}
+		extracted = append(extracted, name)
+	}
+
+	vfs.saveChanges()
+	return extracted, nil
+}
+
+// DecompressFile replaces a gzip-compressed file with its contents, like gunzip:
+// dump.sql.gz becomes dump.sql. Returns the name of the new file.
+// Triggers the save callback if set to persist the change.
+func (vfs *VFS) DecompressFile(path string) (string, error) {
+	content, err := vfs.ReadFileAtPath(path)
+	if err != nil {
+		return "", err
+	}
+	plain, err := Decompress(content)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", path, err)
+	}
+
+	abs := vfs.absPath(path)
+	name := strings.TrimSuffix(filepath.Base(abs), ".gz")
+	if name == filepath.Base(abs) {
+		name += ".out"
+	}
+	if err := vfs.putFile(filepath.Dir(abs), name, plain); err != nil {
+		return "", err
+	}
+	if node := vfs.findNode(abs); node != nil {
+		if err := vfs.canRemove(node); err == nil {
+			unlinkTree(node)
+			delete(node.Parent.Children, node.Name)
+		}
+	}
+
+	vfs.saveChanges()
+	return name, nil
+}
+
+// DecryptFile decrypts the file at path with key and writes the plaintext next to it, dropping an
+// .enc extension (secrets.txt.enc becomes secrets.txt). Returns the name of the new file.
+// Triggers the save callback if set to persist the change.
+func (vfs *VFS) DecryptFile(path, key string) (string, error) {
+	content, err := vfs.ReadFileAtPath(path)
+	if err != nil {
+		return "", err
+	}
+	plain, err := Decrypt(content, key)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", path, err)
+	}
+
+	abs := vfs.absPath(path)
+	name := strings.TrimSuffix(filepath.Base(abs), ".enc")
+	if name == filepath.Base(abs) {
+		name += ".dec"
+	}
+	if err := vfs.putFile(filepath.Dir(abs), name, plain); err != nil {
+		return "", err
+	}
+
+	vfs.saveChanges()
+	return name, nil
+}
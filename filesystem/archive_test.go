@@ -0,0 +1,111 @@
+package filesystem
+
+import (
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestArchivesRoundTrip(t *testing.T) {
+	entries := []ArchiveEntry{
+		{Name: "backup/", IsDir: true},
+		{Name: "backup/config.ini", Content: "[db]\npassword=hunter2\n"},
+		{Name: "backup/empty.txt"},
+	}
+	for name, create := range map[string]func([]ArchiveEntry) (string, error){"tar": CreateTar, "zip": CreateZip} {
+		content, err := create(entries)
+		if err != nil {
+			t.Fatalf("%s: failed to create archive: %v", name, err)
+		}
+		got, err := ReadArchive(content)
+		if err != nil || !reflect.DeepEqual(got, entries) {
+			t.Fatalf("%s: expected the entries back, got %+v (%v)", name, got, err)
+		}
+	}
+	if _, err := ReadArchive("just some text"); !errors.Is(err, ErrNotArchive) {
+		t.Fatalf("expected ErrNotArchive, got %v", err)
+	}
+}
+
+func TestCompressAndEncrypt(t *testing.T) {
+	compressed, err := Compress("INSERT INTO users VALUES (1, 'admin');")
+	if err != nil {
+		t.Fatalf("failed to compress: %v", err)
+	}
+	if plain, err := Decompress(compressed); err != nil || plain != "INSERT INTO users VALUES (1, 'admin');" {
+		t.Fatalf("expected the content back, got %q (%v)", plain, err)
+	}
+	if _, err := Decompress("plain text"); !errors.Is(err, ErrNotCompressed) {
+		t.Fatalf("expected ErrNotCompressed, got %v", err)
+	}
+
+	encrypted, err := Encrypt("the vault code is 0451", "swordfish", "a fish")
+	if err != nil {
+		t.Fatalf("failed to encrypt: %v", err)
+	}
+	if !IsEncrypted(encrypted) || KeyHint(encrypted) != "a fish" {
+		t.Fatalf("expected an encrypted file with its hint, got %q", encrypted)
+	}
+	if _, err := Decrypt(encrypted, "tuna"); !errors.Is(err, ErrWrongKey) {
+		t.Fatalf("expected ErrWrongKey, got %v", err)
+	}
+	if plain, err := Decrypt(encrypted, "swordfish"); err != nil || plain != "the vault code is 0451" {
+		t.Fatalf("expected the plaintext back, got %q (%v)", plain, err)
+	}
+	if _, err := Decrypt("the vault code is 0451", "swordfish"); !errors.Is(err, ErrNotEncrypted) {
+		t.Fatalf("expected ErrNotEncrypted, got %v", err)
+	}
+}
+
+func TestExtractArchiveStaysInCurrentDirectory(t *testing.T) {
+	vfs := NewVFS("alice")
+	archive, err := CreateTar([]ArchiveEntry{
+		{Name: "loot/passwords.txt", Content: "hunter2"},
+		{Name: "../../../etc/evil", Content: "pwned"},
+	})
+	if err != nil {
+		t.Fatalf("failed to create archive: %v", err)
+	}
+	if err := vfs.EnsureDirectoryAndCreateFile("/home/alice", "loot.tar.gz", archive); err != nil {
+		t.Fatalf("failed to create archive file: %v", err)
+	}
+
+	extracted, err := vfs.ExtractArchive("loot.tar.gz")
+	if err != nil || !slices.Equal(extracted, []string{"loot/passwords.txt", "etc/evil"}) {
+		t.Fatalf("expected both entries extracted under the current directory, got %v (%v)", extracted, err)
+	}
+	if content, err := vfs.ReadFileAtPath("/home/alice/etc/evil"); err != nil || content != "pwned" {
+		t.Fatalf("expected the escaping entry inside the current directory, got %q (%v)", content, err)
+	}
+	if _, err := vfs.ReadFileAtPath("/etc/evil"); err == nil {
+		t.Fatal("expected nothing extracted outside the current directory")
+	}
+}
+
+func TestDecompressAndDecryptFiles(t *testing.T) {
+	vfs := NewVFS("alice")
+	compressed, _ := Compress("dump")
+	encrypted, _ := Encrypt("secret", "swordfish", "")
+	vfs.EnsureDirectoryAndCreateFile("/home/alice", "dump.sql.gz", compressed)
+	vfs.EnsureDirectoryAndCreateFile("/home/alice", "secrets.txt.enc", encrypted)
+
+	// gunzip replaces the file; decrypting leaves the encrypted one
+	if name, err := vfs.DecompressFile("dump.sql.gz"); err != nil || name != "dump.sql" {
+		t.Fatalf("expected dump.sql, got %q (%v)", name, err)
+	}
+	if _, err := vfs.ReadFileAtPath("/home/alice/dump.sql.gz"); err == nil {
+		t.Fatal("expected the compressed file to be gone")
+	}
+	if name, err := vfs.DecryptFile("secrets.txt.enc", "swordfish"); err != nil || name != "secrets.txt" {
+		t.Fatalf("expected secrets.txt, got %q (%v)", name, err)
+	}
+	for path, want := range map[string]string{"/home/alice/dump.sql": "dump", "/home/alice/secrets.txt": "secret"} {
+		if content, err := vfs.ReadFileAtPath(path); err != nil || content != want {
+			t.Fatalf("expected %s to hold %q, got %q (%v)", path, want, content, err)
+		}
+	}
+	if _, err := vfs.ReadFileAtPath("/home/alice/secrets.txt.enc"); err != nil {
+		t.Fatalf("expected the encrypted file to stay, got %v", err)
+	}
+}
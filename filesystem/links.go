@@ -0,0 +1,372 @@
+package filesystem
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// maxSymlinkHops is how many symbolic links a single path lookup may follow, as on Linux.
+const maxSymlinkHops = 40
+
+// Errors returned while resolving paths
+var (
+	errNoSuchFile       = errors.New("no such file or directory")
+	errNotDirectory     = errors.New("not a directory")
+	errPermissionDenied = errors.New("Permission denied")
+	errSymlinkLoop      = errors.New("Too many levels of symbolic links")
+)
+
+// inode is shared by the hard links to a file. Each link is its own Node in the tree,
+// so content and metadata changes are copied to the others by syncLinks.
+type inode struct {
+	nodes []*Node
+}
+
+// IsSymlink reports whether the node is a symbolic link.
+func (n *Node) IsSymlink() bool {
+	return n.Symlink != ""
+}
+
+// LinkCount returns the number of hard links to the node. Directories count their own
+// "." entry, the entry in their parent and the ".." of each subdirectory.
+func (n *Node) LinkCount() int {
+	if n.IsDir {
+		count := 2
+		for _, child := range n.Children {
+			if child.IsDir {
+				count++
+			}
+		}
+		return count
+	}
+	if n.inode == nil {
+		return 1
+	}
+	return len(n.inode.nodes)
+}
+
+// readContent returns the file's content, generating it for pseudo-files.
+func (n *Node) readContent() string {
+	if n.generate != nil {
+		return n.generate()
+	}
+	return n.Content
+}
+
+// checkWritable returns an error if node is a pseudo-file, which can't be changed.
+func checkWritable(node *Node, path string) error {
+	if node.generate != nil {
+		return fmt.Errorf("%s: Read-only file system", path)
+	}
+	return nil
+}
+
+// lookup walks path from the root, following symbolic links in the directories on the way and,
+// if followLast is true, in the final component. search is called for every directory before
+// looking inside it; returning false stops the walk with errPermissionDenied.
+// If only the final component is missing, returns a nil node and the directory it would be in.
+func (vfs *VFS) lookup(path string, followLast bool, search func(dir *Node) bool) (node, dir *Node, err error) {
+	parts := splitPath(vfs.absPath(path))
+	current := vfs.Root
+	hops := 0
+	for len(parts) > 0 {
+		part := parts[0]
+		parts = parts[1:]
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			if current.Parent != nil {
+				current = current.Parent
+			}
+			continue
+		}
+
+		if !current.IsDir {
+			return nil, nil, errNotDirectory
+		}
+		if search != nil && !search(current) {
+			return nil, current, errPermissionDenied
+		}
+		child, exists := current.Children[part]
+		if !exists {
+			if len(parts) > 0 {
+				return nil, current, errNoSuchFile
+			}
+			return nil, current, nil
+		}
+
+		if child.IsSymlink() && (len(parts) > 0 || followLast) {
+			hops++
+			if hops > maxSymlinkHops {
+				return nil, nil, errSymlinkLoop
+			}
+			// Relative targets continue from the directory holding the link
+			if strings.HasPrefix(child.Symlink, "/") {
+				current = vfs.Root
+			}
+			parts = append(splitPath(child.Symlink), parts...)
+			continue
+		}
+		current = child
+	}
+	return current, current.Parent, nil
+}
+
+// resolve returns the node at path, following symbolic links (including the final
+// component if followLast is true).
+func (vfs *VFS) resolve(path string, followLast bool) (*Node, error) {
+	node, _, err := vfs.lookup(path, followLast, nil)
+	if err == nil && node == nil {
+		err = errNoSuchFile
+	}
+	return node, err
+}
+
+// Lstat returns the node at path like Stat, but returns a symbolic link itself rather than
+// the node it points to.
+func (vfs *VFS) Lstat(path string) (*Node, error) {
+	if errMsg := vfs.checkAccess(filepath.Dir(vfs.absPath(path)), 0); errMsg != "" {
+		return nil, fmt.Errorf("%s: %s", path, errMsg)
+	}
+	node, err := vfs.resolve(path, false)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return node, nil
+}
+
+// Readlink returns the target of the symbolic link at path.
+func (vfs *VFS) Readlink(path string) (string, error) {
+	node, err := vfs.Lstat(path)
+	if err != nil {
+		return "", err
+	}
+	if !node.IsSymlink() {
+		return "", fmt.Errorf("%s: not a symbolic link", path)
+	}
+	return node.Symlink, nil
+}
+
+// Symlink creates a symbolic link at linkPath pointing to target, like ln -s.
+// The target doesn't need to exist; relative targets are resolved from the link's directory.
+// If linkPath is an existing directory, the link is created inside it.
+// Triggers the save callback if set to persist the change.
+func (vfs *VFS) Symlink(target, linkPath string) error {
+	if target == "" {
+		return fmt.Errorf("invalid target: ''")
+	}
+	dir, name, err := vfs.linkDestination(linkPath, target)
+	if err != nil {
+		return err
+	}
+
+	link := vfs.newNode(name, false, dir)
+	link.Symlink = target
+	link.Mode = 0777
+	dir.Children[name] = link
+	touchDir(dir)
+
+	vfs.saveChanges()
+	return nil
+}
+
+// Link creates a hard link at linkPath to the file at source, like ln. Both names then refer to
+// the same file: writing through one changes the other, and it survives until both are removed.
+// Directories and pseudo-files can't be hard linked.
+// Triggers the save callback if set to persist the change.
+func (vfs *VFS) Link(source, linkPath string) error {
+	if errMsg := vfs.checkAccess(source, 0); errMsg != "" {
+		return fmt.Errorf("%s: %s", source, errMsg)
+	}
+	src, err := vfs.resolve(source, true)
+	if err != nil {
+		return fmt.Errorf("%s: %s", source, err)
+	}
+	if src.IsDir {
+		return fmt.Errorf("%s: hard link not allowed for directory", source)
+	}
+	if src.generate != nil {
+		return fmt.Errorf("%s: Invalid cross-device link", source)
+	}
+	dir, name, err := vfs.linkDestination(linkPath, source)
+	if err != nil {
+		return err
+	}
+
+	// Pin the metadata so both names report the same owner and mode wherever they are
+	src.pinMetadata()
+	if src.inode == nil {
+		src.inode = &inode{nodes: []*Node{src}}
+	}
+	link := &Node{Name: name, Parent: dir, inode: src.inode}
+	src.inode.nodes = append(src.inode.nodes, link)
+	src.syncLinks()
+	dir.Children[name] = link
+	touchDir(dir)
+
+	vfs.saveChanges()
+	return nil
+}
+
+// linkDestination returns the directory and name for a new link at linkPath, checking that the
+// name is free and the directory writable. If linkPath is an existing directory, the link goes
+// inside it under the base name of source.
+func (vfs *VFS) linkDestination(linkPath, source string) (*Node, string, error) {
+	absLink := vfs.absPath(linkPath)
+	if node, err := vfs.resolve(absLink, true); err == nil && node.IsDir {
+		absLink = filepath.Join(absLink, filepath.Base(source))
+	}
+
+	dir, err := vfs.resolve(filepath.Dir(absLink), true)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %s", linkPath, err)
+	}
+	if !dir.IsDir {
+		return nil, "", fmt.Errorf("%s: %s", linkPath, errNotDirectory)
+	}
+	name := filepath.Base(absLink)
+	if _, exists := dir.Children[name]; exists {
+		return nil, "", fmt.Errorf("%s: File exists", linkPath)
+	}
+	if errMsg := vfs.checkAccess(dir.Path(), permWrite|permExec); errMsg != "" {
+		return nil, "", fmt.Errorf("%s: %s", linkPath, errMsg)
+	}
+	return dir, name, nil
+}
+
+// syncLinks copies the node's content and metadata to its other hard links.
+func (n *Node) syncLinks() {
+	if n.inode == nil {
+		return
+	}
+	for _, other := range n.inode.nodes {
+		if other == n {
+			continue
+		}
+		other.Content = n.Content
+		other.Owner, other.Group, other.Mode, other.HasMode = n.Owner, n.Group, n.Mode, n.HasMode
+		other.ModTime, other.AccessTime = n.ModTime, n.AccessTime
+	}
+}
+
+// unlinkTree detaches node and everything below it from their hard links before they're removed.
+func unlinkTree(n *Node) {
+	if n.inode != nil {
+		nodes := n.inode.nodes
+		for i, other := range nodes {
+			if other == n {
+				n.inode.nodes = append(nodes[:i:i], nodes[i+1:]...)
+				break
+			}
+		}
+		n.inode = nil
+	}
+	for _, child := range n.Children {
+		unlinkTree(child)
+	}
+}
+
+// hardLinkKey identifies the node's hard link group in persisted maps: the smallest path among
+// its links. Returns "" if the node has no other links.
+func (n *Node) hardLinkKey() string {
+	if n.inode == nil || len(n.inode.nodes) < 2 {
+		return ""
+	}
+	key := ""
+	for _, link := range n.inode.nodes {
+		if path := link.Path(); key == "" || path < key {
+			key = path
+		}
+	}
+	return key
+}
+
+// setLinkFromMap reads the link fields of a persisted file entry. A symbolic link is stored with
+// its target as the content and "type": "symlink"; hard links share a "hardlink" key.
+func (n *Node) setLinkFromMap(data map[string]interface{}) {
+	n.Symlink = ""
+	if kind, _ := data["type"].(string); kind == "symlink" {
+		n.Symlink, n.Content = n.Content, ""
+	}
+	n.linkKey, _ = data["hardlink"].(string)
+}
+
+// relink joins the nodes read from a persisted map that share a hard link key.
+func (vfs *VFS) relink() {
+	groups := make(map[string][]*Node)
+	var walk func(n *Node)
+	walk = func(n *Node) {
+		if n.linkKey != "" {
+			groups[n.linkKey] = append(groups[n.linkKey], n)
+			n.linkKey = ""
+		}
+		for _, child := range n.Children {
+			walk(child)
+		}
+	}
+	walk(vfs.Root)
+
+	for _, nodes := range groups {
+		if len(nodes) < 2 {
+			continue
+		}
+		shared := &inode{nodes: nodes}
+		for _, n := range nodes {
+			n.inode = shared
+		}
+	}
+}
+
+// AddPseudoFile adds a read-only file whose content is produced by generate each time it's read,
+// like the files in /proc. Missing parent directories are created. Pseudo-files replace any file
+// already at path and are never persisted.
+func (vfs *VFS) AddPseudoFile(path string, generate func() string) {
+	path = filepath.Clean("/" + path)
+	dir := vfs.systemDir(filepath.Dir(path))
+
+	name := filepath.Base(path)
+	if existing, exists := dir.Children[name]; exists {
+		unlinkTree(existing)
+	}
+	dir.Children[name] = &Node{
+		Name:     name,
+		Parent:   dir,
+		Owner:    "root",
+		Group:    "root",
+		Mode:     0444,
+		HasMode:  true,
+		generate: generate,
+	}
+	vfs.standardPaths[path] = true
+	delete(vfs.standardDirs, path)
+}
+
+// AddSystemDir adds a root-owned directory with the given mode, as if it came with the system,
+// such as a world-writable directory on a misconfigured server. Missing parent directories are
+// created; an existing directory is given the mode.
+func (vfs *VFS) AddSystemDir(path string, mode uint32) {
+	dir := vfs.systemDir(filepath.Clean("/" + path))
+	dir.Owner, dir.Group, dir.Mode, dir.HasMode = "root", "root", mode, true
+}
+
+// systemDir returns the directory at path, creating it and any missing parents as part of the
+// standard filesystem.
+func (vfs *VFS) systemDir(path string) *Node {
+	dir := vfs.Root
+	dirPath := ""
+	for _, part := range splitPath(path) {
+		dirPath += "/" + part
+		child, exists := dir.Children[part]
+		if !exists || !child.IsDir {
+			child = &Node{Name: part, IsDir: true, Children: make(map[string]*Node), Parent: dir}
+			dir.Children[part] = child
+			vfs.standardPaths[dirPath] = true
+			vfs.standardDirs[dirPath] = true
+		}
+		dir = child
+	}
+	return dir
+}
@@ -0,0 +1,124 @@
+package filesystem
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestLinksSurviveReload(t *testing.T) {
+	vfs := NewVFS("alice")
+	if err := vfs.CreateFile("notes.txt"); err != nil {
+		t.Fatalf("touch failed: %v", err)
+	}
+	if err := vfs.WriteFile("notes.txt", "v1"); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if err := vfs.Link("notes.txt", "hard.txt"); err != nil {
+		t.Fatalf("ln failed: %v", err)
+	}
+	if err := vfs.Symlink("/home/alice/notes.txt", "soft.txt"); err != nil {
+		t.Fatalf("ln -s failed: %v", err)
+	}
+	if err := vfs.Symlink("loop", "loop"); err != nil {
+		t.Fatalf("ln -s failed: %v", err)
+	}
+
+	again, err := NewUserVFSFromMap("alice", vfs.ExtractChanges())
+	if err != nil {
+		t.Fatalf("failed to reload vfs: %v", err)
+	}
+
+	// Writing through the symlink changes every name of the file
+	if err := again.WriteFile("soft.txt", "v2"); err != nil {
+		t.Fatalf("write through symlink failed: %v", err)
+	}
+	if content, err := again.ReadFile("hard.txt"); err != nil || content != "v2" {
+		t.Fatalf("expected hard link to see the write, got %q (%v)", content, err)
+	}
+	if target, err := again.Readlink("soft.txt"); err != nil || target != "/home/alice/notes.txt" {
+		t.Fatalf("expected symlink target, got %q (%v)", target, err)
+	}
+	if node, err := again.Stat("notes.txt"); err != nil || node.LinkCount() != 2 {
+		t.Fatalf("expected 2 links, got %v (%v)", node, err)
+	}
+	if _, err := again.ReadFile("loop"); err == nil {
+		t.Fatal("expected reading a symlink loop to fail")
+	}
+
+	// Removing one name leaves the other intact
+	if err := again.DeleteNode("notes.txt", false); err != nil {
+		t.Fatalf("rm failed: %v", err)
+	}
+	if content, err := again.ReadFile("hard.txt"); err != nil || content != "v2" {
+		t.Fatalf("expected hard link to survive, got %q (%v)", content, err)
+	}
+	if _, err := again.ReadFile("soft.txt"); err == nil {
+		t.Fatal("expected dangling symlink read to fail")
+	}
+}
+
+func TestChangeDirThroughSymlink(t *testing.T) {
+	vfs := NewVFS("alice")
+	if err := vfs.CreateDirectory("projects"); err != nil {
+		t.Fatalf("mkdir failed: %v", err)
+	}
+	if err := vfs.ChangeDir("projects"); err != nil {
+		t.Fatalf("cd failed: %v", err)
+	}
+	if err := vfs.CreateDirectory("src"); err != nil {
+		t.Fatalf("mkdir failed: %v", err)
+	}
+	if err := vfs.Symlink("/home/alice/projects/src", "/home/alice/work"); err != nil {
+		t.Fatalf("ln -s failed: %v", err)
+	}
+
+	// cd lands in the directory the link points to; like the shell's cd, .. right after the link
+	// goes back to where the link is
+	if err := vfs.ChangeDir("/home/alice/work"); err != nil {
+		t.Fatalf("cd through symlink failed: %v", err)
+	}
+	if path := vfs.GetCurrentPath(); path != "/home/alice/projects/src" {
+		t.Fatalf("expected to be in the link's target, got %s", path)
+	}
+	if err := vfs.ChangeDir("/home/alice/work/.."); err != nil || vfs.GetCurrentPath() != "/home/alice" {
+		t.Fatalf("expected .. after a link to go back to the link's directory, got %s (%v)", vfs.GetCurrentPath(), err)
+	}
+
+	// A link to a file isn't a directory
+	if err := vfs.Symlink("/home/alice/README.txt", "/home/alice/readme"); err != nil {
+		t.Fatalf("ln -s failed: %v", err)
+	}
+	if err := vfs.ChangeDir("/home/alice/readme"); err == nil || !strings.Contains(err.Error(), "not a directory") {
+		t.Fatalf("expected cd into a file link to fail, got %v", err)
+	}
+}
+
+func TestSymlinkHopLimit(t *testing.T) {
+	vfs := NewVFS("alice")
+	if err := vfs.CreateDirectory("target"); err != nil {
+		t.Fatalf("mkdir failed: %v", err)
+	}
+
+	// link1 -> target, link2 -> link1, ... A lookup may follow 40 links, as on Linux.
+	const maxSymlinkHops = 40
+	previous := "target"
+	for i := 1; i <= maxSymlinkHops+1; i++ {
+		name := fmt.Sprintf("link%d", i)
+		if err := vfs.Symlink(previous, name); err != nil {
+			t.Fatalf("ln -s failed: %v", err)
+		}
+		previous = name
+	}
+
+	if err := vfs.ChangeDir(fmt.Sprintf("/home/alice/link%d", maxSymlinkHops)); err != nil {
+		t.Fatalf("expected %d links to resolve, got %v", maxSymlinkHops, err)
+	}
+	err := vfs.ChangeDir(fmt.Sprintf("/home/alice/link%d", maxSymlinkHops+1))
+	if err == nil || !strings.Contains(err.Error(), "Too many levels of symbolic links") {
+		t.Fatalf("expected a symlink loop error past %d links, got %v", maxSymlinkHops, err)
+	}
+	if _, err := vfs.ReadFileAtPath(fmt.Sprintf("/home/alice/link%d/x", maxSymlinkHops+1)); err == nil {
+		t.Fatal("expected reading past the hop limit to fail")
+	}
+}
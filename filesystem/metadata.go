@@ -0,0 +1,582 @@
+package filesystem
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Permission bits as used in Unix modes
+const (
+	permRead  uint32 = 4
+	permWrite uint32 = 2
+	permExec  uint32 = 1
+
+	modeSticky uint32 = 01000 // Only the owner may delete or rename entries (e.g. /tmp)
+	modePerm   uint32 = 07777
+)
+
+// metaKey holds a directory's own metadata in persisted filesystem maps.
+// No real entry can be called ".", so it can't clash with a child.
+const metaKey = "."
+
+// defaultModTime is the timestamp of files that came with a filesystem image and were never touched.
+var defaultModTime = time.Date(2026, time.January, 20, 9, 0, 0, 0, time.UTC)
+
+// pathMetadata is the ownership and mode of a well-known path.
+type pathMetadata struct {
+	owner string
+	group string
+	mode  uint32
+}
+
+// defaultPathMetadata lists paths whose defaults differ from root:root 0755/0644.
+var defaultPathMetadata = map[string]pathMetadata{
+	"/root":             {"root", "root", 0700},
+	"/tmp":              {"root", "root", 01777},
+	"/etc/shadow":       {"root", "shadow", 0640},
+	"/etc/sudoers":      {"root", "root", 0440},
+	"/var/log/auth.log": {"root", "adm", 0640},
+	"/var/log/syslog":   {"root", "adm", 0640},
+}
+
+// defaultMetadata returns the owner, group and mode a node at path has unless set explicitly.
+// Anything under /home/<name> belongs to <name>; programs in /bin and /usr/bin are executable.
+func defaultMetadata(path string, isDir bool) (owner, group string, mode uint32) {
+	if meta, ok := defaultPathMetadata[path]; ok {
+		return meta.owner, meta.group, meta.mode
+	}
+
+	owner, group, mode = "root", "root", 0644
+	if isDir {
+		mode = 0755
+	}
+	if rest, ok := strings.CutPrefix(path, "/home/"); ok && rest != "" {
+		name, _, _ := strings.Cut(rest, "/")
+		owner, group = name, name
+	}
+	if !isDir && (strings.HasPrefix(path, "/bin/") || strings.HasPrefix(path, "/usr/bin/")) {
+		mode = 0755
+	}
+	return owner, group, mode
+}
+
+// Path returns the absolute path of the node.
+func (n *Node) Path() string {
+	if n.Parent == nil {
+		return "/"
+	}
+	parts := []string{}
+	for node := n; node != nil && node.Parent != nil; node = node.Parent {
+		parts = append([]string{node.Name}, parts...)
+	}
+	return "/" + strings.Join(parts, "/")
+}
+
+// Size returns the file size in bytes. Directories report one 4K block like most Unix filesystems,
+// and symbolic links the length of their target.
+func (n *Node) Size() int {
+	if n.IsDir {
+		return 4096
+	}
+	if n.IsSymlink() {
+		return len(n.Symlink)
+	}
+	return len(n.readContent())
+}
+
+// GetOwner returns the owning user, defaulting based on the node's path if not set.
+func (n *Node) GetOwner() string {
+	if n.Owner != "" {
+		return n.Owner
+	}
+	owner, _, _ := defaultMetadata(n.Path(), n.IsDir)
+	return owner
+}
+
+// GetGroup returns the owning group, defaulting based on the node's path if not set.
+func (n *Node) GetGroup() string {
+	if n.Group != "" {
+		return n.Group
+	}
+	_, group, _ := defaultMetadata(n.Path(), n.IsDir)
+	return group
+}
+
+// GetMode returns the permission bits, defaulting based on the node's path if not set.
+// Symbolic links are always 0777; access is decided by what they point to.
+func (n *Node) GetMode() uint32 {
+	if n.IsSymlink() {
+		return 0777
+	}
+	if n.HasMode {
+		return n.Mode
+	}
+	_, _, mode := defaultMetadata(n.Path(), n.IsDir)
+	return mode
+}
+
+// GetModTime returns the last modification time, defaulting to the filesystem image time.
+func (n *Node) GetModTime() time.Time {
+	if n.ModTime.IsZero() {
+		return defaultModTime
+	}
+	return n.ModTime
+}
+
+// GetAccessTime returns the last access time, defaulting to the modification time.
+func (n *Node) GetAccessTime() time.Time {
+	if n.AccessTime.IsZero() {
+		return n.GetModTime()
+	}
+	return n.AccessTime
+}
+
+// ModeString formats the node's type and permissions like ls -l, e.g. "drwxr-xr-x".
+func (n *Node) ModeString() string {
+	mode := n.GetMode()
+	var b strings.Builder
+	if n.IsDir {
+		b.WriteByte('d')
+	} else if n.IsSymlink() {
+		b.WriteByte('l')
+	} else {
+		b.WriteByte('-')
+	}
+	const rwx = "rwxrwxrwx"
+	for i := 0; i < 9; i++ {
+		if mode&(1<<uint(8-i)) != 0 {
+			b.WriteByte(rwx[i])
+		} else {
+			b.WriteByte('-')
+		}
+	}
+	s := []byte(b.String())
+	if mode&modeSticky != 0 {
+		if s[9] == 'x' {
+			s[9] = 't'
+		} else {
+			s[9] = 'T'
+		}
+	}
+	return string(s)
+}
+
+// pinMetadata makes the node's current ownership and mode explicit, so they survive moving it
+// somewhere with different defaults.
+func (n *Node) pinMetadata() {
+	n.Owner, n.Group, n.Mode = n.GetOwner(), n.GetGroup(), n.GetMode()
+	n.HasMode = true
+}
+
+// metadataMap returns the node's explicitly set metadata in persisted form, or nil if there is none.
+func (n *Node) metadataMap() map[string]interface{} {
+	meta := make(map[string]interface{})
+	if n.Owner != "" {
+		meta["owner"] = n.Owner
+	}
+	if n.Group != "" {
+		meta["group"] = n.Group
+	}
+	if n.HasMode {
+		meta["mode"] = fmt.Sprintf("%04o", n.Mode)
+	}
+	if !n.ModTime.IsZero() {
+		meta["mtime"] = n.ModTime.Unix()
+	}
+	if !n.AccessTime.IsZero() {
+		meta["atime"] = n.AccessTime.Unix()
+	}
+	if len(meta) == 0 {
+		return nil
+	}
+	return meta
+}
+
+// setMetadataFromMap replaces the node's explicit metadata with the values in a persisted map.
+// Keys that aren't metadata (such as "content") are ignored.
+func (n *Node) setMetadataFromMap(data map[string]interface{}) {
+	n.Owner, _ = data["owner"].(string)
+	n.Group, _ = data["group"].(string)
+	n.Mode, n.HasMode = 0, false
+	if s, ok := data["mode"].(string); ok {
+		if mode, err := strconv.ParseUint(s, 8, 32); err == nil {
+			n.Mode, n.HasMode = uint32(mode)&modePerm, true
+		}
+	}
+	n.ModTime = unixField(data["mtime"])
+	n.AccessTime = unixField(data["atime"])
+}
+
+// unixField reads a Unix timestamp stored as a number (float64 after a JSON round trip).
+func unixField(value interface{}) time.Time {
+	switch v := value.(type) {
+	case int64:
+		return time.Unix(v, 0)
+	case float64:
+		return time.Unix(int64(v), 0)
+	case int:
+		return time.Unix(int64(v), 0)
+	}
+	return time.Time{}
+}
+
+// ParseMode applies a chmod mode to the current mode. Accepts octal ("755") or
+// symbolic ("u+x", "go-w", "a=r", "+x") notation, with several clauses separated by commas.
+func ParseMode(spec string, current uint32) (uint32, error) {
+	if octal, err := strconv.ParseUint(spec, 8, 32); err == nil {
+		if uint32(octal) > modePerm {
+			return 0, fmt.Errorf("invalid mode: %s", spec)
+		}
+		return uint32(octal), nil
+	}
+
+	mode := current
+	for _, clause := range strings.Split(spec, ",") {
+		i := strings.IndexAny(clause, "+-=")
+		if i < 0 || i == len(clause)-1 && clause[i] != '=' {
+			return 0, fmt.Errorf("invalid mode: %s", spec)
+		}
+
+		var who uint32
+		for _, c := range clause[:i] {
+			switch c {
+			case 'u':
+				who |= 04700
+			case 'g':
+				who |= 02070
+			case 'o':
+				who |= 01007
+			case 'a':
+				who |= 07777
+			default:
+				return 0, fmt.Errorf("invalid mode: %s", spec)
+			}
+		}
+		if who == 0 {
+			who = 07777
+		}
+
+		var bits uint32
+		for _, c := range clause[i+1:] {
+			switch c {
+			case 'r':
+				bits |= 0444
+			case 'w':
+				bits |= 0222
+			case 'x':
+				bits |= 0111
+			case 't':
+				bits |= modeSticky
+			default:
+				return 0, fmt.Errorf("invalid mode: %s", spec)
+			}
+		}
+		bits &= who
+
+		switch clause[i] {
+		case '+':
+			mode |= bits
+		case '-':
+			mode &^= bits
+		case '=':
+			mode = mode&^(who&0777) | bits
+		}
+	}
+	return mode, nil
+}
+
+// --- Permission checks ---
+
+// SetGroups sets the groups of the current role, used for group permission checks.
+// The role's own name always counts as its primary group.
+func (vfs *VFS) SetGroups(groups []string) {
+	vfs.groups = groups
+}
+
+// inGroup reports whether the current role belongs to group.
+func (vfs *VFS) inGroup(group string) bool {
+	if group == vfs.GetRole() {
+		return true
+	}
+	for _, g := range vfs.groups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
+
+// hasPermission checks the owner, group or other bits of node that apply to the current role.
+func (vfs *VFS) hasPermission(node *Node, perm uint32) bool {
+	if vfs.isRoot {
+		return true
+	}
+	mode := node.GetMode()
+	switch {
+	case node.GetOwner() == vfs.GetRole():
+		mode >>= 6
+	case vfs.inGroup(node.GetGroup()):
+		mode >>= 3
+	}
+	return mode&perm == perm
+}
+
+// checkAccess checks that the current role can reach path and has perm on it (0 only checks
+// the directories on the way). Symbolic links are followed, so access is decided by their target.
+// For a path that doesn't exist yet, writing requires write access to the deepest existing directory.
+// Returns an error message if access is denied, empty string if allowed.
+func (vfs *VFS) checkAccess(path string, perm uint32) string {
+	node, dir, err := vfs.lookup(path, true, func(dir *Node) bool {
+		return vfs.hasPermission(dir, permExec)
+	})
+	switch {
+	case errors.Is(err, errPermissionDenied), errors.Is(err, errSymlinkLoop):
+		return err.Error()
+	case node == nil:
+		if perm == permWrite && dir != nil && !vfs.hasPermission(dir, permWrite|permExec) {
+			return "Permission denied"
+		}
+		return ""
+	}
+
+	if perm != 0 && !vfs.hasPermission(node, perm) {
+		return "Permission denied"
+	}
+	return ""
+}
+
+// canRemove checks that the current role may delete or rename node from its directory.
+// Needs write access to the directory, and in a sticky directory (like /tmp) ownership of the node.
+func (vfs *VFS) canRemove(node *Node) error {
+	dir := node.Parent
+	if dir == nil {
+		return fmt.Errorf("%s: Permission denied", node.Name)
+	}
+	if errMsg := vfs.checkAccess(dir.Path(), permWrite|permExec); errMsg != "" {
+		return fmt.Errorf("%s: %s", node.Name, errMsg)
+	}
+	if !vfs.isRoot && dir.GetMode()&modeSticky != 0 &&
+		node.GetOwner() != vfs.GetRole() && dir.GetOwner() != vfs.GetRole() {
+		return fmt.Errorf("%s: Operation not permitted", node.Name)
+	}
+	return nil
+}
+
+// AbsPath resolves path against the current directory and cleans it.
+func (vfs *VFS) AbsPath(path string) string {
+	return vfs.absPath(path)
+}
+
+// absPath resolves path against the current directory.
+func (vfs *VFS) absPath(path string) string {
+	if !strings.HasPrefix(path, "/") {
+		currentPath := vfs.GetCurrentPath()
+		if currentPath == "/" {
+			path = "/" + path
+		} else {
+			path = currentPath + "/" + path
+		}
+	}
+	return filepath.Clean(path)
+}
+
+// newNode creates a file or directory owned by the current role, as if made by a program
+// running with umask 022.
+func (vfs *VFS) newNode(name string, isDir bool, parent *Node) *Node {
+	now := time.Now()
+	node := &Node{
+		Name:       name,
+		IsDir:      isDir,
+		Parent:     parent,
+		Owner:      vfs.GetRole(),
+		Group:      vfs.GetRole(),
+		Mode:       0644,
+		HasMode:    true,
+		ModTime:    now,
+		AccessTime: now,
+	}
+	if isDir {
+		node.Children = make(map[string]*Node)
+		node.Mode = 0755
+	}
+	return node
+}
+
+// touchDir records that a directory's entries changed.
+func touchDir(dir *Node) {
+	if dir != nil {
+		dir.ModTime = time.Now()
+	}
+}
+
+// --- Metadata operations ---
+
+// Stat returns the node at path. Only the directories leading to it need to be searchable.
+func (vfs *VFS) Stat(path string) (*Node, error) {
+	if errMsg := vfs.checkAccess(path, 0); errMsg != "" {
+		return nil, fmt.Errorf("%s: %s", path, errMsg)
+	}
+	node := vfs.findNode(vfs.absPath(path))
+	if node == nil {
+		return nil, fmt.Errorf("%s: no such file or directory", path)
+	}
+	return node, nil
+}
+
+// Chmod changes the permission bits of path. Only the owner or root may do this.
+// If recursive is true, directory contents are changed too.
+// Triggers the save callback if set to persist the change.
+func (vfs *VFS) Chmod(path string, spec string, recursive bool) error {
+	node, err := vfs.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !vfs.isRoot && node.GetOwner() != vfs.GetRole() {
+		return fmt.Errorf("changing permissions of '%s': Operation not permitted", path)
+	}
+	if err := checkWritable(node, path); err != nil {
+		return err
+	}
+
+	var apply func(n *Node) error
+	apply = func(n *Node) error {
+		if n.generate != nil || n.IsSymlink() {
+			return nil
+		}
+		mode, err := ParseMode(spec, n.GetMode())
+		if err != nil {
+			return err
+		}
+		n.pinMetadata()
+		n.Mode = mode
+		n.syncLinks()
+		if recursive && n.IsDir {
+			for _, child := range n.Children {
+				if vfs.isRoot || child.GetOwner() == vfs.GetRole() {
+					if err := apply(child); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		return nil
+	}
+	if err := apply(node); err != nil {
+		return err
+	}
+	vfs.saveChanges()
+	return nil
+}
+
+// Chown changes the owner and/or group of path (empty strings leave them unchanged).
+// Only root may change the owner; the owner may change the group to one they belong to.
+// If recursive is true, directory contents are changed too.
+// Triggers the save callback if set to persist the change.
+func (vfs *VFS) Chown(path, owner, group string, recursive bool) error {
+	node, err := vfs.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !vfs.isRoot {
+		if owner != "" && owner != node.GetOwner() {
+			return fmt.Errorf("changing ownership of '%s': Operation not permitted", path)
+		}
+		if node.GetOwner() != vfs.GetRole() || (group != "" && !vfs.inGroup(group)) {
+			return fmt.Errorf("changing group of '%s': Operation not permitted", path)
+		}
+	}
+	if err := checkWritable(node, path); err != nil {
+		return err
+	}
+
+	var apply func(n *Node)
+	apply = func(n *Node) {
+		if n.generate != nil {
+			return
+		}
+		n.pinMetadata()
+		if owner != "" {
+			n.Owner = owner
+		}
+		if group != "" {
+			n.Group = group
+		}
+		n.syncLinks()
+		if recursive && n.IsDir {
+			for _, child := range n.Children {
+				apply(child)
+			}
+		}
+	}
+	apply(node)
+	vfs.saveChanges()
+	return nil
+}
+
+// SetTimes sets the modification and access times of path (zero values leave them unchanged).
+// Requires write access unless the current role is root.
+// Triggers the save callback if set to persist the change.
+func (vfs *VFS) SetTimes(path string, modTime, accessTime time.Time) error {
+	if errMsg := vfs.checkAccess(path, permWrite); errMsg != "" {
+		return fmt.Errorf("%s: %s", path, errMsg)
+	}
+	node := vfs.findNode(vfs.absPath(path))
+	if node == nil {
+		return fmt.Errorf("%s: no such file or directory", path)
+	}
+	if err := checkWritable(node, path); err != nil {
+		return err
+	}
+	if !modTime.IsZero() {
+		node.ModTime = modTime
+	}
+	if !accessTime.IsZero() {
+		node.AccessTime = accessTime
+	}
+	node.syncLinks()
+	vfs.saveChanges()
+	return nil
+}
+
+// ResetTimes sets the timestamps of everything under path back to the filesystem image time,
+// hiding when files were created or changed. Returns the number of nodes changed.
+// Triggers the save callback if set to persist the change.
+func (vfs *VFS) ResetTimes(path string) (int, error) {
+	if !vfs.isRoot {
+		return 0, fmt.Errorf("%s: Operation not permitted", path)
+	}
+	node := vfs.findNode(vfs.absPath(path))
+	if node == nil {
+		return 0, fmt.Errorf("%s: no such file or directory", path)
+	}
+
+	count := 0
+	var reset func(n *Node)
+	reset = func(n *Node) {
+		if !n.ModTime.IsZero() || !n.AccessTime.IsZero() {
+			n.ModTime, n.AccessTime = time.Time{}, time.Time{}
+			n.syncLinks()
+			count++
+		}
+		for _, child := range n.Children {
+			reset(child)
+		}
+	}
+	reset(node)
+	if count > 0 {
+		vfs.saveChanges()
+	}
+	return count, nil
+}
+
+// saveChanges persists the filesystem through the save callback, if set.
+// Errors are ignored like in the other operations; the change is already made in memory.
+func (vfs *VFS) saveChanges() {
+	if vfs.onSaveCallback != nil {
+		_ = vfs.onSaveCallback(vfs.ExtractChanges())
+	}
+}
@@ -0,0 +1,110 @@
+package filesystem
+
+import "testing"
+
+func TestParseMode(t *testing.T) {
+	tests := []struct {
+		spec    string
+		current uint32
+		want    uint32
+		wantErr bool
+	}{
+		{"755", 0644, 0755, false},
+		{"0600", 0644, 0600, false},
+		{"1777", 0755, 01777, false},
+		{"17777", 0644, 0, true},
+		{"u+x", 0644, 0744, false},
+		{"go-w", 0666, 0644, false},
+		{"a=r", 0755, 0444, false},
+		{"+x", 0644, 0755, false},
+		{"u=rwx,go=", 0644, 0700, false},
+		{"o+t", 0777, 01777, false},
+		{"=", 0755, 0, false},
+		{"u+", 0644, 0, true},
+		{"z+x", 0644, 0, true},
+		{"u+q", 0644, 0, true},
+		{"rwx", 0644, 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseMode(tt.spec, tt.current)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseMode(%q, %04o) = %04o, %v; want %04o, error %v", tt.spec, tt.current, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestCheckAccess(t *testing.T) {
+	file := func(owner, group, mode string) map[string]interface{} {
+		return map[string]interface{}{"content": "x", "owner": owner, "group": group, "mode": mode}
+	}
+	vfs, err := NewVFSFromMap("root", map[string]interface{}{
+		"srv": map[string]interface{}{
+			"shared.txt":  file("alice", "staff", "0640"),
+			"private.txt": file("alice", "alice", "0600"),
+			"public.txt":  file("root", "root", "0644"),
+			"locked": map[string]interface{}{
+				".":          map[string]interface{}{"owner": "root", "group": "root", "mode": "0700"},
+				"secret.txt": file("root", "root", "0644"),
+			},
+			"dropbox": map[string]interface{}{
+				".": map[string]interface{}{"owner": "root", "group": "root", "mode": "1733"},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to build vfs: %v", err)
+	}
+
+	tests := []struct {
+		role   string
+		groups []string
+		path   string
+		perm   uint32
+		denied bool
+	}{
+		{"alice", nil, "/srv/private.txt", permRead | permWrite, false},
+		{"bob", nil, "/srv/private.txt", permRead, true},
+		{"bob", []string{"staff"}, "/srv/shared.txt", permRead, false},
+		{"bob", []string{"staff"}, "/srv/shared.txt", permWrite, true},
+		{"bob", nil, "/srv/shared.txt", permRead, true},
+		{"bob", nil, "/srv/public.txt", permRead, false},
+		{"bob", nil, "/srv/public.txt", permWrite, true},
+		{"bob", nil, "/srv/locked/secret.txt", 0, true}, // Can't get through the directory
+		{"bob", nil, "/srv/new.txt", permWrite, true},   // Creating needs write on the directory
+		{"bob", nil, "/srv/dropbox/new.txt", permWrite, false},
+		{"bob", nil, "/srv/dropbox", permRead, true},
+		{"root", nil, "/srv/locked/secret.txt", permRead | permWrite, false},
+	}
+	for _, tt := range tests {
+		vfs.SetRole(tt.role, tt.role == "root", "/")
+		vfs.SetGroups(tt.groups)
+		if denied := vfs.checkAccess(tt.path, tt.perm) != ""; denied != tt.denied {
+			t.Errorf("%s %v: checkAccess(%s, %o) denied = %v, want %v", tt.role, tt.groups, tt.path, tt.perm, denied, tt.denied)
+		}
+	}
+}
+
+func TestStickyDirectoryKeepsOthersFiles(t *testing.T) {
+	vfs, err := NewVFSFromMap("root", map[string]interface{}{"tmp": map[string]interface{}{}})
+	if err != nil {
+		t.Fatalf("failed to build vfs: %v", err)
+	}
+	vfs.SetRole("alice", false, "/home/alice")
+	if err := vfs.ChangeDir("/tmp"); err != nil {
+		t.Fatalf("cd failed: %v", err)
+	}
+	if err := vfs.CreateFile("alice.txt"); err != nil {
+		t.Fatalf("touch failed: %v", err)
+	}
+
+	vfs.SetRole("bob", false, "/tmp")
+	if err := vfs.DeleteNode("alice.txt", false); err == nil {
+		t.Fatal("expected bob not to remove alice's file from /tmp")
+	}
+	if err := vfs.CreateFile("bob.txt"); err != nil {
+		t.Fatalf("touch failed: %v", err)
+	}
+	if err := vfs.DeleteNode("bob.txt", false); err != nil {
+		t.Fatalf("expected bob to remove his own file, got %v", err)
+	}
+}
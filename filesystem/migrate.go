@@ -0,0 +1,166 @@
+package filesystem
+
+// ChangesVersion is the format version of maps produced by ExtractChanges, stored as
+// "version" in the root directory's "." entry. Maps without one are version 1.
+//
+// Version 1 maps written by ExtractChanges nested every directory's entries under the
+// directory's own path again, e.g. /home/alice/notes.txt was stored as
+// home -> home -> alice -> home -> alice -> notes.txt. Version 2 stores entries directly
+// under their directory and adds file metadata and whiteouts for deleted standard files.
+// Version 3 keeps the opaque markers of overlays in the directory's "." entry instead of among
+// its children, where they clashed with entries named "opaque"; other maps are unchanged.
+const ChangesVersion = 3
+
+const versionKey = "version"
+
+// changesVersion returns the format version of a persisted filesystem map.
+func changesVersion(fs map[string]interface{}) int {
+	meta, _ := fs[metaKey].(map[string]interface{})
+	switch v := meta[versionKey].(type) {
+	case int:
+		return v
+	case float64:
+		return int(v)
+	}
+	return 1
+}
+
+// setChangesVersion stamps fs with the current format version.
+func setChangesVersion(fs map[string]interface{}) {
+	meta, ok := fs[metaKey].(map[string]interface{})
+	if !ok {
+		meta = make(map[string]interface{})
+		fs[metaKey] = meta
+	}
+	meta[versionKey] = ChangesVersion
+}
+
+// MigrateChanges converts a persisted filesystem map to the current format.
+// Version 1 maps in the nested layout are unpacked; other version 1 maps (such as seeded server
+// filesystems, which were never written by ExtractChanges) are already laid out correctly.
+// Returns the map and whether it was changed.
+func MigrateChanges(fs map[string]interface{}) (map[string]interface{}, bool) {
+	if fs == nil || changesVersion(fs) >= ChangesVersion {
+		return fs, false
+	}
+
+	migrated := fs
+	if changesVersion(fs) < 2 {
+		if ok, nested := isNestedLayout(fs, nil); ok && nested {
+			migrated = unnest(fs, nil)
+		}
+	}
+	setChangesVersion(migrated)
+	return migrated, true
+}
+
+// MigrateOverlay converts a player's overlay stored in the given format version to the current one.
+// Overlays can't carry the version in their root "." entry (that entry is the root's metadata),
+// so callers store it next to the overlay. Returns the overlay and whether it was changed.
+func MigrateOverlay(overlay map[string]interface{}, version int) (map[string]interface{}, bool) {
+	if overlay == nil || version >= ChangesVersion {
+		return overlay, false
+	}
+	changed := false
+	if version < 2 {
+		if ok, nested := isNestedLayout(overlay, nil); ok && nested {
+			overlay, changed = unnest(overlay, nil), true
+		}
+	}
+	if moveOpaqueMarkers(overlay) {
+		changed = true
+	}
+	return overlay, changed
+}
+
+// moveOpaqueMarkers moves the version 2 opaque markers of an overlay, stored among a directory's
+// children as "opaque": true, into the directory's "." entry. Only markers are bools; an entry
+// named "opaque" is a map. Reports whether any marker was moved.
+func moveOpaqueMarkers(dir map[string]interface{}) bool {
+	moved := false
+	for name, value := range dir {
+		switch v := value.(type) {
+		case bool:
+			if name == opaqueKey {
+				delete(dir, name)
+				if v {
+					markOpaque(dir)
+				}
+				moved = true
+			}
+		case map[string]interface{}:
+			if name != metaKey && !isFileMap(v) && moveOpaqueMarkers(v) {
+				moved = true
+			}
+		}
+	}
+	return moved
+}
+
+// isNestedLayout reports whether the directory map for path looks like the version 1 nested layout,
+// and whether any nesting was actually found (an all-empty map fits both layouts).
+func isNestedLayout(dir map[string]interface{}, path []string) (ok bool, nested bool) {
+	level := dir
+	for _, part := range path {
+		if len(level) == 0 {
+			return true, nested
+		}
+		next, isDir := level[part].(map[string]interface{})
+		if len(level) != 1 || !isDir || isFileMap(next) {
+			return false, false
+		}
+		nested = true
+		level = next
+	}
+
+	for name, value := range level {
+		child, isDir := value.(map[string]interface{})
+		if !isDir || isFileMap(child) {
+			continue
+		}
+		childOK, childNested := isNestedLayout(child, append(path[:len(path):len(path)], name))
+		if !childOK {
+			return false, false
+		}
+		nested = nested || childNested
+	}
+	return true, nested
+}
+
+// unnest rebuilds a directory map from the version 1 nested layout.
+func unnest(dir map[string]interface{}, path []string) map[string]interface{} {
+	level := dir
+	for _, part := range path {
+		next, ok := level[part].(map[string]interface{})
+		if !ok {
+			return make(map[string]interface{})
+		}
+		level = next
+	}
+
+	result := make(map[string]interface{}, len(level))
+	for name, value := range level {
+		if child, isDir := value.(map[string]interface{}); isDir && !isFileMap(child) {
+			result[name] = unnest(child, append(path[:len(path):len(path)], name))
+		} else {
+			result[name] = value
+		}
+	}
+	return result
+}
+
+// isFileMap reports whether a map entry is a file rather than a directory.
+func isFileMap(entry map[string]interface{}) bool {
+	_, isFile := entry["content"].(string)
+	return isFile
+}
+
+// isWhiteout reports whether an entry marks a deleted file or directory.
+func isWhiteout(value interface{}) bool {
+	entry, ok := value.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	whiteout, _ := entry[whiteoutKey].(bool)
+	return whiteout
+}
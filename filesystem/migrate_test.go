@@ -0,0 +1,171 @@
+package filesystem
+
+import (
+	"reflect"
+	"testing"
+)
+
+// legacyUserChanges is what version 1 ExtractChanges wrote for a user with one file in their home.
+func legacyUserChanges() map[string]interface{} {
+	return map[string]interface{}{
+		"home": map[string]interface{}{
+			"home": map[string]interface{}{
+				"erin": map[string]interface{}{
+					"home": map[string]interface{}{
+						"erin": map[string]interface{}{
+							"notes.txt": map[string]interface{}{"content": "remember me"},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestMigrateLegacyUserChanges(t *testing.T) {
+	migrated, changed := MigrateChanges(legacyUserChanges())
+	if !changed || changesVersion(migrated) != ChangesVersion {
+		t.Fatalf("expected the map migrated to version %d, got version %d (changed %v)", ChangesVersion, changesVersion(migrated), changed)
+	}
+	content, err := NewMapFileReader(migrated).ReadFile("/home/erin/notes.txt")
+	if err != nil || content != "remember me" {
+		t.Fatalf("expected migrated file, got %q (%v)", content, err)
+	}
+	if again, changed := MigrateChanges(migrated); changed || !reflect.DeepEqual(again, migrated) {
+		t.Fatal("expected a current map to be left alone")
+	}
+
+	vfs, err := NewUserVFSFromMap("erin", legacyUserChanges())
+	if err != nil {
+		t.Fatalf("failed to build vfs: %v", err)
+	}
+	if content, err := vfs.ReadFileAtPath("/home/erin/notes.txt"); err != nil || content != "remember me" {
+		t.Fatalf("expected the vfs to migrate the map it's built from, got %q (%v)", content, err)
+	}
+	if _, err := vfs.ReadFileAtPath("/home/erin/README.txt"); err != nil {
+		t.Fatalf("expected README.txt for user vfs, got %v", err)
+	}
+}
+
+func TestMigrateSeededServerChanges(t *testing.T) {
+	// Seeded server filesystems are version 1 but were never nested
+	seeded := map[string]interface{}{
+		"etc": map[string]interface{}{
+			"passwd": map[string]interface{}{"content": "root:x:0:0"},
+		},
+		"home": map[string]interface{}{
+			"guest": map[string]interface{}{},
+		},
+	}
+	migrated, changed := MigrateChanges(seeded)
+	if !changed || changesVersion(migrated) != ChangesVersion {
+		t.Fatalf("expected the map stamped with version %d, got %d", ChangesVersion, changesVersion(migrated))
+	}
+	if content, err := NewMapFileReader(migrated).ReadFile("/etc/passwd"); err != nil || content != "root:x:0:0" {
+		t.Fatalf("expected the seeded file where it was, got %q (%v)", content, err)
+	}
+	if _, ok := migrated["home"].(map[string]interface{})["guest"]; !ok {
+		t.Fatal("expected the empty directory kept")
+	}
+}
+
+func TestMigrateLegacyOverlay(t *testing.T) {
+	// A version 1 overlay diffed from nested maps: the player rewrote the notes and added a file
+	legacy := map[string]interface{}{
+		"home": map[string]interface{}{
+			"home": map[string]interface{}{
+				"guest": map[string]interface{}{
+					"home": map[string]interface{}{
+						"guest": map[string]interface{}{
+							"notes.txt": map[string]interface{}{"content": "nothing to see"},
+							"todo.txt":  map[string]interface{}{"content": "cover tracks"},
+						},
+					},
+				},
+			},
+		},
+	}
+	migrated, changed := MigrateOverlay(legacy, 1)
+	if !changed {
+		t.Fatal("expected the nested overlay migrated")
+	}
+	if _, stamped := migrated[metaKey]; stamped {
+		t.Fatal("expected no version stamped into the overlay's root metadata")
+	}
+
+	vfs, _ := loadImage(t, guestImage())
+	vfs.ApplyOverlay(migrated)
+	for path, want := range map[string]string{
+		"/home/guest/notes.txt": "nothing to see",
+		"/home/guest/todo.txt":  "cover tracks",
+	} {
+		if content, err := vfs.ReadFileAtPath(path); err != nil || content != want {
+			t.Fatalf("expected %s to be %q, got %q (%v)", path, want, content, err)
+		}
+	}
+	if _, err := vfs.ReadFileAtPath("/home/home/guest/home/guest/notes.txt"); err == nil {
+		t.Fatal("expected no nested copy of the overlay")
+	}
+
+	if again, changed := MigrateOverlay(migrated, ChangesVersion); changed || !reflect.DeepEqual(again, migrated) {
+		t.Fatal("expected a current overlay to be left alone")
+	}
+}
+
+func TestIsNestedLayout(t *testing.T) {
+	file := map[string]interface{}{"content": "x"}
+	tests := []struct {
+		name       string
+		dir        map[string]interface{}
+		wantOK     bool
+		wantNested bool
+	}{
+		{"empty", map[string]interface{}{}, true, false},
+		{"files at the root", map[string]interface{}{"a.txt": file}, true, false},
+		{"empty directories", map[string]interface{}{"tmp": map[string]interface{}{}}, true, false},
+		{"nested", map[string]interface{}{
+			"tmp": map[string]interface{}{"tmp": map[string]interface{}{"a.txt": file}},
+		}, true, true},
+		{"nested twice", map[string]interface{}{
+			"var": map[string]interface{}{"var": map[string]interface{}{
+				"log": map[string]interface{}{"var": map[string]interface{}{"log": map[string]interface{}{"a.log": file}}},
+			}},
+		}, true, true},
+		{"flat", map[string]interface{}{
+			"etc": map[string]interface{}{"passwd": file},
+		}, false, false},
+		{"nesting that ends in a file", map[string]interface{}{
+			"tmp": map[string]interface{}{"tmp": file},
+		}, false, false},
+		{"nesting with a sibling", map[string]interface{}{
+			"tmp": map[string]interface{}{"tmp": map[string]interface{}{}, "a.txt": file},
+		}, false, false},
+	}
+	for _, tt := range tests {
+		ok, nested := isNestedLayout(tt.dir, nil)
+		if ok != tt.wantOK || nested != tt.wantNested {
+			t.Errorf("%s: isNestedLayout = %v, %v; want %v, %v", tt.name, ok, nested, tt.wantOK, tt.wantNested)
+		}
+	}
+}
+
+func TestUnnest(t *testing.T) {
+	file := map[string]interface{}{"content": "x"}
+	nested := map[string]interface{}{
+		"a.txt": file,
+		"var": map[string]interface{}{"var": map[string]interface{}{
+			"empty": map[string]interface{}{},
+			"log":   map[string]interface{}{"var": map[string]interface{}{"log": map[string]interface{}{"a.log": file}}},
+		}},
+	}
+	want := map[string]interface{}{
+		"a.txt": file,
+		"var": map[string]interface{}{
+			"empty": map[string]interface{}{},
+			"log":   map[string]interface{}{"a.log": file},
+		},
+	}
+	if got := unnest(nested, nil); !reflect.DeepEqual(got, want) {
+		t.Fatalf("unnest = %v, want %v", got, want)
+	}
+}
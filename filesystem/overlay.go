@@ -0,0 +1,165 @@
+package filesystem
+
+import "reflect"
+
+// Overlays store one player's changes to a server filesystem on top of the server's base image.
+// An overlay uses the same map format as ExtractChanges, including its whiteouts
+// ({"whiteout": true} hides the base entry of that name) and its opaque markers: a directory
+// whose "." entry holds "opaque": true, or a file entry holding it, replaces the base entry
+// instead of merging into it (used when a file was replaced by a directory or the other way round).
+//
+// A directory's "." entry (its metadata) is replaced as a whole; an empty one resets it to the defaults.
+
+const (
+	whiteoutKey = "whiteout"
+	opaqueKey   = "opaque"
+)
+
+// DiffOverlay returns the overlay that turns base into current.
+// Both maps should come from ExtractChanges so standard paths are treated the same way.
+// Returns an empty map if nothing changed.
+func DiffOverlay(base, current map[string]interface{}) map[string]interface{} {
+	overlay := make(map[string]interface{})
+
+	for name := range base {
+		if _, exists := current[name]; !exists {
+			if name == metaKey {
+				overlay[name] = map[string]interface{}{}
+				continue
+			}
+			overlay[name] = map[string]interface{}{whiteoutKey: true}
+		}
+	}
+
+	for name, value := range current {
+		baseValue, exists := base[name]
+		if !exists {
+			overlay[name] = value
+			continue
+		}
+		if name == metaKey || isWhiteout(value) || isWhiteout(baseValue) {
+			if !reflect.DeepEqual(value, baseValue) {
+				overlay[name] = value
+			}
+			continue
+		}
+
+		_, isFile := fileContent(value)
+		_, baseIsFile := fileContent(baseValue)
+		switch {
+		case isFile && baseIsFile:
+			// Compare the whole entry so metadata changes count too
+			if !reflect.DeepEqual(value, baseValue) {
+				overlay[name] = value
+			}
+		case isFile:
+			// Directory replaced by a file - the file entry replaces it on apply
+			overlay[name] = value
+		case baseIsFile:
+			dir := copyMap(value.(map[string]interface{}))
+			markOpaque(dir)
+			overlay[name] = dir
+		default:
+			diff := DiffOverlay(baseValue.(map[string]interface{}), value.(map[string]interface{}))
+			if len(diff) > 0 {
+				overlay[name] = diff
+			}
+		}
+	}
+
+	return overlay
+}
+
+// ApplyOverlay applies a player's overlay produced by DiffOverlay on top of the VFS.
+func (vfs *VFS) ApplyOverlay(overlay map[string]interface{}) {
+	if len(overlay) == 0 {
+		return
+	}
+	vfs.applyOverlayToNode(vfs.Root, overlay)
+	vfs.relink()
+}
+
+// applyOverlayToNode recursively applies overlay entries to the children of parent
+func (vfs *VFS) applyOverlayToNode(parent *Node, overlay map[string]interface{}) {
+	for name, value := range overlay {
+		existing := parent.Children[name]
+
+		if content, isFile := fileContent(value); isFile {
+			if existing == nil || existing.IsDir {
+				existing = &Node{Name: name, Parent: parent}
+				parent.Children[name] = existing
+			}
+			existing.Content = content
+			if entry, ok := value.(map[string]interface{}); ok {
+				existing.setMetadataFromMap(entry)
+				existing.setLinkFromMap(entry)
+			}
+			continue
+		}
+
+		entry, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if name == metaKey {
+			parent.setMetadataFromMap(entry)
+			continue
+		}
+		if isWhiteout(entry) {
+			delete(parent.Children, name)
+			continue
+		}
+
+		if existing == nil || !existing.IsDir || isOpaque(entry) {
+			existing = &Node{Name: name, IsDir: true, Children: make(map[string]*Node), Parent: parent}
+			parent.Children[name] = existing
+		}
+		vfs.applyOverlayToNode(existing, entry)
+	}
+}
+
+// markOpaque marks an entry as replacing whatever is at its path instead of merging into it.
+// A directory carries the marker in its "." entry, where it can't clash with a child's name.
+// The "." entry is copied first, so maps it's shared with are left alone.
+func markOpaque(entry map[string]interface{}) {
+	if isFileMap(entry) {
+		entry[opaqueKey] = true
+		return
+	}
+	meta, _ := entry[metaKey].(map[string]interface{})
+	meta = copyMap(meta)
+	meta[opaqueKey] = true
+	entry[metaKey] = meta
+}
+
+// isOpaque reports whether an entry was marked by markOpaque.
+func isOpaque(entry map[string]interface{}) bool {
+	if !isFileMap(entry) {
+		entry, _ = entry[metaKey].(map[string]interface{})
+	}
+	opaque, _ := entry[opaqueKey].(bool)
+	return opaque
+}
+
+// fileContent returns the content of a file entry in a filesystem map.
+// Returns false if the entry is a directory.
+func fileContent(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		// Legacy format
+		return v, true
+	case map[string]interface{}:
+		content, isFile := v["content"].(string)
+		return content, isFile
+	}
+	return "", false
+}
+
+// copyMap returns a shallow copy of m
+func copyMap(m map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		copied[k] = v
+	}
+	return copied
+}
@@ -0,0 +1,199 @@
+package filesystem
+
+import "testing"
+
+// guestImage is a server base image with one file in a guest's home.
+func guestImage() map[string]interface{} {
+	return map[string]interface{}{
+		"home": map[string]interface{}{
+			"guest": map[string]interface{}{
+				"notes.txt": map[string]interface{}{"content": "password: hunter2"},
+			},
+		},
+	}
+}
+
+// loadImage builds a root VFS on a base image, returning it with its changes before anything is done.
+func loadImage(t *testing.T, image map[string]interface{}) (*VFS, map[string]interface{}) {
+	t.Helper()
+	vfs, err := NewVFSFromMap("root", image)
+	if err != nil {
+		t.Fatalf("failed to build vfs: %v", err)
+	}
+	vfs.SetRole("root", true, "/root")
+	return vfs, vfs.ExtractChanges()
+}
+
+func TestOverlayKeepsBaseImageIntact(t *testing.T) {
+	// One player wipes the notes and leaves a file of their own
+	vfs, base := loadImage(t, guestImage())
+	if err := vfs.ChangeDir("/home/guest"); err != nil {
+		t.Fatalf("cd failed: %v", err)
+	}
+	if err := vfs.DeleteNode("notes.txt", false); err != nil {
+		t.Fatalf("rm failed: %v", err)
+	}
+	if err := vfs.CreateFile("pwned.txt"); err != nil {
+		t.Fatalf("touch failed: %v", err)
+	}
+	if err := vfs.WriteFile("pwned.txt", "was here"); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	overlay := DiffOverlay(base, vfs.ExtractChanges())
+	guest := overlay["home"].(map[string]interface{})["guest"].(map[string]interface{})
+	if !isWhiteout(guest["notes.txt"]) {
+		t.Fatalf("expected a whiteout for the deleted file, got %v", guest["notes.txt"])
+	}
+
+	// The player sees their changes on the next visit
+	again, _ := loadImage(t, guestImage())
+	again.ApplyOverlay(overlay)
+	if _, err := again.ReadFileAtPath("/home/guest/notes.txt"); err == nil {
+		t.Fatalf("expected deleted file to stay deleted for the player")
+	}
+	if content, err := again.ReadFileAtPath("/home/guest/pwned.txt"); err != nil || content != "was here" {
+		t.Fatalf("expected player's file, got %q (%v)", content, err)
+	}
+
+	// Everyone else still gets the untouched base image
+	fresh, _ := loadImage(t, guestImage())
+	if content, err := fresh.ReadFileAtPath("/home/guest/notes.txt"); err != nil || content != "password: hunter2" {
+		t.Fatalf("expected base file for other players, got %q (%v)", content, err)
+	}
+}
+
+func TestOverlayOfUnchangedImageIsEmpty(t *testing.T) {
+	vfs, base := loadImage(t, guestImage())
+	if err := vfs.ChangeDir("/home/guest"); err != nil {
+		t.Fatalf("cd failed: %v", err)
+	}
+	if overlay := DiffOverlay(base, vfs.ExtractChanges()); len(overlay) != 0 {
+		t.Fatalf("expected an empty overlay, got %v", overlay)
+	}
+}
+
+func TestOverlayReplacesFilesAndDirectories(t *testing.T) {
+	image := map[string]interface{}{
+		"srv": map[string]interface{}{
+			"backup":  map[string]interface{}{"content": "not a directory yet"},
+			"archive": map[string]interface{}{"old.tar": map[string]interface{}{"content": "tar"}},
+		},
+	}
+
+	// backup becomes a directory and archive a file
+	vfs, base := loadImage(t, image)
+	if err := vfs.ChangeDir("/srv"); err != nil {
+		t.Fatalf("cd failed: %v", err)
+	}
+	if err := vfs.DeleteNode("backup", false); err != nil {
+		t.Fatalf("rm failed: %v", err)
+	}
+	if err := vfs.DeleteNode("archive", true); err != nil {
+		t.Fatalf("rm -r failed: %v", err)
+	}
+	if err := vfs.EnsureDirectoryAndCreateFile("/srv/backup", "db.sql", "dump"); err != nil {
+		t.Fatalf("failed to create backup: %v", err)
+	}
+	if err := vfs.EnsureDirectoryAndCreateFile("/srv", "archive", "gone"); err != nil {
+		t.Fatalf("failed to create archive: %v", err)
+	}
+	overlay := DiffOverlay(base, vfs.ExtractChanges())
+	if backup, _ := overlay["srv"].(map[string]interface{})["backup"].(map[string]interface{}); !isOpaque(backup) {
+		t.Fatalf("expected the directory replacing a file to be opaque, got %v", backup)
+	}
+
+	again, _ := loadImage(t, image)
+	again.ApplyOverlay(overlay)
+	if content, err := again.ReadFileAtPath("/srv/backup/db.sql"); err != nil || content != "dump" {
+		t.Fatalf("expected the new directory's file, got %q (%v)", content, err)
+	}
+	if content, err := again.ReadFileAtPath("/srv/archive"); err != nil || content != "gone" {
+		t.Fatalf("expected the file replacing the directory, got %q (%v)", content, err)
+	}
+	if _, err := again.ReadFileAtPath("/srv/archive/old.tar"); err == nil {
+		t.Fatal("expected the replaced directory's contents to be gone")
+	}
+}
+
+func TestOverlayKeepsFileMetadata(t *testing.T) {
+	vfs, err := NewVFSFromMap("root", guestImage())
+	if err != nil {
+		t.Fatalf("failed to build vfs: %v", err)
+	}
+	baseChanges := vfs.ExtractChanges()
+	vfs.SetRole("guest", false, "/home/guest")
+	if err := vfs.Chmod("/home/guest/notes.txt", "600", false); err != nil {
+		t.Fatalf("chmod failed: %v", err)
+	}
+	overlay := DiffOverlay(baseChanges, vfs.ExtractChanges())
+
+	// Reload as another user on the same server
+	again, _ := NewVFSFromMap("root", guestImage())
+	again.ApplyOverlay(overlay)
+	again.SetRole("user", false, "/home/user")
+
+	node, err := again.Stat("/home/guest/notes.txt")
+	if err != nil {
+		t.Fatalf("stat failed: %v", err)
+	}
+	if node.ModeString() != "-rw-------" || node.GetOwner() != "guest" {
+		t.Fatalf("expected guest-owned 0600 file, got %s %s", node.ModeString(), node.GetOwner())
+	}
+	if _, err := again.ReadFileAtPath("/home/guest/notes.txt"); err == nil {
+		t.Fatalf("expected other users to be denied reading a 0600 file")
+	}
+}
+
+func TestOverlayKeepsEntriesNamedOpaque(t *testing.T) {
+	image := map[string]interface{}{
+		"srv": map[string]interface{}{
+			"backup": map[string]interface{}{"content": "old"},
+			"data": map[string]interface{}{
+				"opaque": map[string]interface{}{"content": "base"},
+				"keep":   map[string]interface{}{"content": "from the image"},
+			},
+		},
+	}
+	vfs, base := loadImage(t, image)
+
+	// A directory replacing a file holds a file named opaque; a merged one has its own
+	if err := vfs.RemoveFile("/srv/backup"); err != nil {
+		t.Fatalf("failed to remove backup: %v", err)
+	}
+	if err := vfs.EnsureDirectoryAndCreateFile("/srv/backup", "opaque", "new"); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+	if err := vfs.EnsureDirectoryAndCreateFile("/srv/data", "opaque", "changed"); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	overlay := DiffOverlay(base, vfs.ExtractChanges())
+
+	again, _ := loadImage(t, image)
+	again.ApplyOverlay(overlay)
+	for path, want := range map[string]string{
+		"/srv/backup/opaque": "new",
+		"/srv/data/opaque":   "changed",
+		"/srv/data/keep":     "from the image",
+	} {
+		if content, err := again.ReadFileAtPath(path); err != nil || content != want {
+			t.Fatalf("expected %s to be %q, got %q (%v)", path, want, content, err)
+		}
+	}
+}
+
+func TestMigrateOverlayMovesOpaqueMarkers(t *testing.T) {
+	// Version 2 kept the marker among the directory's children
+	overlay := map[string]interface{}{
+		"srv": map[string]interface{}{
+			"backup": map[string]interface{}{
+				opaqueKey: true,
+				"db.sql":  map[string]interface{}{"content": "dump"},
+			},
+		},
+	}
+	migrated, changed := MigrateOverlay(overlay, 2)
+	backup := migrated["srv"].(map[string]interface{})["backup"].(map[string]interface{})
+	if !changed || !isOpaque(backup) || backup[opaqueKey] != nil {
+		t.Fatalf("expected the marker moved into the directory's metadata, got %v", backup)
+	}
+}
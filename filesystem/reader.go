@@ -54,6 +54,9 @@ func (r *MapFileReader) ReadFile(path string) (string, error) {
 				if content, ok := file["content"].(string); ok {
 					return content, nil
 				}
+				if isWhiteout(file) {
+					return "", ErrFileNotFound
+				}
 				// It's a directory, not a file
 				return "", ErrNotAFile
 			}
@@ -86,9 +89,12 @@ func (r *MapFileReader) ListDir(path string) ([]string, error) {
 		}
 	}
 
-	// Collect entries
+	// Collect entries, skipping directory metadata and deleted entries
 	entries := make([]string, 0, len(currentLevel))
-	for name := range currentLevel {
+	for name, value := range currentLevel {
+		if name == metaKey || isWhiteout(value) {
+			continue
+		}
 		entries = append(entries, name)
 	}
 
@@ -3,9 +3,11 @@
 package filesystem
 
 import (
+	"errors"
 	"fmt"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 // Node represents a file or directory in the virtual filesystem.
@@ -15,6 +17,19 @@ type Node struct {
 	Content  string            // File content (empty for directories)
 	Children map[string]*Node  // Child nodes (for directories only)
 	Parent   *Node             // Parent node (nil for root)
+
+	// Unix metadata. Zero values mean the default for the node's path (see defaultMetadata).
+	Owner      string    // Owning user
+	Group      string    // Owning group
+	Mode       uint32    // Permission bits, e.g. 0644
+	HasMode    bool      // Mode is set explicitly; a mode of 0 (chmod 000) is a real mode
+	ModTime    time.Time // Last content change
+	AccessTime time.Time // Last read
+
+	Symlink  string        // Target path if this is a symbolic link
+	inode    *inode        // Shared with the node's hard links (nil if it has none)
+	linkKey  string        // Hard link group read from a persisted map, resolved by relink
+	generate func() string // Produces the content of a pseudo-file (like /proc/uptime) at read time
 }
 
 // VFS represents a virtual filesystem with standard Unix-like directory structure.
@@ -24,6 +39,7 @@ type VFS struct {
 	Current        *Node
 	username       string // Store username for home directory
 	standardPaths  map[string]bool // Tracks paths that are part of standard filesystem
+	standardDirs   map[string]bool // Standard paths that are directories
 	isServerVFS   bool   // True if this is a server VFS (for server filesystem persistence)
 	serverID       string // Server ID or path for server VFS (for persistence)
 	userID         string // User ID for user VFS (for persistence)
@@ -33,6 +49,7 @@ type VFS struct {
 	currentRole    string // Current role/username on the server
 	isRoot         bool   // Whether current role has root privileges
 	homeDir        string // Home directory for current role
+	groups         []string // Groups of the current role
 }
 
 // NewVFS creates a new VFS with standard filesystem structure.
@@ -120,6 +137,7 @@ Type 'cat README.txt' anytime to see this message again.
 		Current:       userDir,
 		username:      username,
 		standardPaths: make(map[string]bool),
+		standardDirs:  make(map[string]bool),
 		isServerVFS:   false,
 	}
 	
@@ -151,6 +169,19 @@ func NewVFSFromMap(username string, fs map[string]interface{}) (*VFS, error) {
 	return vfs, nil
 }
 
+// NewUserVFSFromMap creates a user's VFS, including the README.txt, and merges in their
+// persisted filesystem changes.
+// Returns a VFS instance and any error that occurred during merging.
+func NewUserVFSFromMap(username string, fs map[string]interface{}) (*VFS, error) {
+	vfs := newVFSWithOptions(username, true)
+
+	if err := vfs.MergeFromMap(fs); err != nil {
+		return nil, fmt.Errorf("failed to merge filesystem: %w", err)
+	}
+
+	return vfs, nil
+}
+
 // GetCurrentPath returns the absolute path of the current directory.
 func (vfs *VFS) GetCurrentPath() string {
 	if vfs.Current == vfs.Root {
@@ -169,7 +200,7 @@ func (vfs *VFS) GetCurrentPath() string {
 
 // ChangeDir changes the current directory to the specified path.
 // Supports absolute paths, relative paths, "~" for home directory, "." for current, and ".." for parent.
-// Returns an error if the path doesn't exist or is not a directory.
+// Returns an error if the path doesn't exist, is not a directory, or permission denied.
 func (vfs *VFS) ChangeDir(path string) error {
 	// Strip trailing slashes (except for root "/")
 	if len(path) > 1 {
@@ -181,15 +212,13 @@ func (vfs *VFS) ChangeDir(path string) error {
 		homePath := "/home/" + vfs.username
 		target := vfs.findNode(homePath)
 		if target != nil && target.IsDir {
-			vfs.Current = target
-			return nil
+			return vfs.enterDir(target, path)
 		}
 		// Fallback: try to find any user directory under /home
 		if home, exists := vfs.Root.Children["home"]; exists {
 			// Find the user directory (should be the only child of home, or find by username)
 			if userDir, exists := home.Children[vfs.username]; exists && userDir.IsDir {
-				vfs.Current = userDir
-				return nil
+				return vfs.enterDir(userDir, path)
 			}
 		}
 		return fmt.Errorf("cd: home directory not found")
@@ -211,24 +240,27 @@ func (vfs *VFS) ChangeDir(path string) error {
 		return nil
 	}
 
-	// Handle absolute paths
-	if strings.HasPrefix(path, "/") {
-		target := vfs.findNode(path)
-		if target != nil && target.IsDir {
-			vfs.Current = target
-			return nil
-		}
+	// Absolute or relative path, following symbolic links
+	target, err := vfs.resolve(path, true)
+	if errors.Is(err, errSymlinkLoop) {
+		return fmt.Errorf("cd: %s: %s", path, err)
+	}
+	if err != nil {
 		return fmt.Errorf("cd: no such file or directory: %s", path)
 	}
-
-	// Handle relative paths
-	target := vfs.Current.Children[path]
-	if target != nil && target.IsDir {
-		vfs.Current = target
-		return nil
+	if !target.IsDir {
+		return fmt.Errorf("cd: not a directory: %s", path)
 	}
+	return vfs.enterDir(target, path)
+}
 
-	return fmt.Errorf("cd: no such file or directory: %s", path)
+// enterDir makes target the current directory if the current role may search it.
+func (vfs *VFS) enterDir(target *Node, path string) error {
+	if errMsg := vfs.checkAccess(target.Path(), permExec); errMsg != "" {
+		return fmt.Errorf("cd: %s: %s", path, errMsg)
+	}
+	vfs.Current = target
+	return nil
 }
 
 // ListDir returns a list of nodes in the current directory, excluding hidden files.
@@ -254,7 +286,8 @@ func (vfs *VFS) ListDirWithOptions(showAll bool) []*Node {
 	return nodes
 }
 
-// ReadFile reads the content of a file in the current directory.
+// ReadFile reads the content of a file, usually a name in the current directory.
+// Symbolic links are followed and pseudo-files are generated.
 // Returns the file content and an error if the file doesn't exist, is a directory, or permission denied.
 func (vfs *VFS) ReadFile(name string) (string, error) {
 	// Check read permission
@@ -262,28 +295,18 @@ func (vfs *VFS) ReadFile(name string) (string, error) {
 		return "", fmt.Errorf("cat: %s", err)
 	}
 	
-	if file, exists := vfs.Current.Children[name]; exists && !file.IsDir {
-		return file.Content, nil
+	if file, err := vfs.resolve(name, true); err == nil && !file.IsDir {
+		file.AccessTime = time.Now()
+		file.syncLinks()
+		return file.readContent(), nil
 	}
 	return "", fmt.Errorf("cat: %s: no such file or directory", name)
 }
 
+// findNode returns the node at path, following symbolic links, or nil if there is none.
 func (vfs *VFS) findNode(path string) *Node {
-	path = filepath.Clean(path)
-	parts := strings.Split(strings.Trim(path, "/"), "/")
-
-	current := vfs.Root
-	for _, part := range parts {
-		if part == "" {
-			continue
-		}
-		if child, exists := current.Children[part]; exists {
-			current = child
-		} else {
-			return nil
-		}
-	}
-	return current
+	node, _ := vfs.resolve(path, true)
+	return node
 }
 
 // ReadFileAtPath reads a file by absolute or relative path.
@@ -311,64 +334,110 @@ func (vfs *VFS) ReadFileAtPath(path string) (string, error) {
 	if node.IsDir {
 		return "", fmt.Errorf("is a directory: %s", path)
 	}
-	return node.Content, nil
+	node.AccessTime = time.Now()
+	node.syncLinks()
+	return node.readContent(), nil
 }
 
 // EnsureDirectoryAndCreateFile ensures the directory exists, then creates a file with the given content.
 // The path is like /home/user/Downloads. The filename is the leaf name.
 // Overwrites if the file already exists.
 func (vfs *VFS) EnsureDirectoryAndCreateFile(dirPath, fileName, content string) error {
-	dirPath = filepath.Clean(dirPath)
-	parts := strings.Split(strings.Trim(dirPath, "/"), "/")
+	if err := vfs.putFile(dirPath, fileName, content); err != nil {
+		return err
+	}
+	vfs.saveChanges()
+	return nil
+}
 
-	current := vfs.Root
-	for _, part := range parts {
-		if part == "" {
-			continue
-		}
-		if child, exists := current.Children[part]; exists {
-			if !child.IsDir {
-				return fmt.Errorf("not a directory: %s", part)
-			}
-			current = child
-		} else {
-			newDir := &Node{
-				Name:     part,
-				IsDir:    true,
-				Children: make(map[string]*Node),
-				Parent:   current,
-			}
-			current.Children[part] = newDir
-			current = newDir
-		}
+// ReceiveFile writes a file copied from another machine to dest, like the receiving end of scp:
+// if dest is a directory, the file goes inside it as name. The parent directory must exist.
+// Returns the absolute path of the written file.
+// Triggers the save callback if set to persist the change.
+func (vfs *VFS) ReceiveFile(dest, name, content string) (string, error) {
+	abs := vfs.absPath(dest)
+	if node, err := vfs.resolve(abs, true); err == nil && node.IsDir {
+		abs = filepath.Join(abs, name)
+	}
+	if dir, err := vfs.resolve(filepath.Dir(abs), true); err != nil || !dir.IsDir {
+		return "", fmt.Errorf("%s: No such file or directory", dest)
 	}
 
-	// Check write permission for the full path
-	fullPath := dirPath
-	if !strings.HasSuffix(dirPath, "/") {
-		fullPath += "/"
+	if err := vfs.putFile(filepath.Dir(abs), filepath.Base(abs), content); err != nil {
+		return "", err
 	}
-	fullPath += fileName
+	vfs.saveChanges()
+	return abs, nil
+}
+
+// putFile creates or overwrites fileName in dirPath, creating missing directories on the way.
+// Writing to an existing symbolic link writes the file it points to. Doesn't trigger the save callback.
+func (vfs *VFS) putFile(dirPath, fileName, content string) error {
+	dirPath = filepath.Clean(dirPath)
+
+	// Check write permission for the full path before creating anything
+	fullPath := filepath.Join(dirPath, fileName)
 	if errMsg := vfs.CanAccessPath(fullPath, true); errMsg != "" {
 		return fmt.Errorf("%s: %s", fullPath, errMsg)
 	}
 
-	// Create or overwrite the file
-	file := &Node{
-		Name:    fileName,
-		IsDir:   false,
-		Content: content,
-		Parent:  current,
+	current, err := vfs.ensureDir(dirPath)
+	if err != nil {
+		return err
 	}
-	current.Children[fileName] = file
 
-	if vfs.onSaveCallback != nil {
-		changes := vfs.ExtractChanges()
-		_ = vfs.onSaveCallback(changes)
+	// Create or overwrite the file, writing through a symbolic link
+	existing := current.Children[fileName]
+	if existing != nil && existing.IsSymlink() {
+		existing = vfs.findNode(existing.Path())
+	}
+	if existing != nil && !existing.IsDir {
+		if err := checkWritable(existing, fullPath); err != nil {
+			return err
+		}
+		existing.Content = content
+		existing.ModTime = time.Now()
+		existing.syncLinks()
+	} else {
+		file := vfs.newNode(fileName, false, current)
+		file.Content = content
+		current.Children[fileName] = file
+		touchDir(current)
 	}
 	return nil
 }
 
+// ensureDir returns the directory at dirPath, creating it and any missing parents.
+// Symbolic links on the way are followed. Doesn't trigger the save callback.
+func (vfs *VFS) ensureDir(dirPath string) (*Node, error) {
+	if errMsg := vfs.CanAccessPath(dirPath, true); errMsg != "" {
+		if node := vfs.findNode(dirPath); node == nil || !node.IsDir {
+			return nil, fmt.Errorf("%s: %s", dirPath, errMsg)
+		}
+	}
+
+	current := vfs.Root
+	for _, part := range splitPath(filepath.Clean(dirPath)) {
+		if child, exists := current.Children[part]; exists {
+			if child.IsSymlink() {
+				if target := vfs.findNode(child.Path()); target != nil {
+					child = target
+				}
+			}
+			if !child.IsDir {
+				return nil, fmt.Errorf("not a directory: %s", part)
+			}
+			current = child
+		} else {
+			newDir := vfs.newNode(part, true, current)
+			current.Children[part] = newDir
+			touchDir(current)
+			current = newDir
+		}
+	}
+	return current, nil
+}
+
 // CreateFile creates a new empty file in the current directory.
 // Returns an error if a file or directory with the same name already exists or permission denied.
 // Triggers the save callback if set to persist the change.
@@ -382,13 +451,9 @@ func (vfs *VFS) CreateFile(name string) error {
 		return fmt.Errorf("file already exists: %s", name)
 	}
 	
-	file := &Node{
-		Name:    name,
-		IsDir:   false,
-		Content: "",
-		Parent:  vfs.Current,
-	}
+	file := vfs.newNode(name, false, vfs.Current)
 	vfs.Current.Children[name] = file
+	touchDir(vfs.Current)
 	
 	// Trigger save callback if set
 	if vfs.onSaveCallback != nil {
@@ -414,13 +479,9 @@ func (vfs *VFS) CreateDirectory(name string) error {
 		return fmt.Errorf("directory already exists: %s", name)
 	}
 	
-	dir := &Node{
-		Name:     name,
-		IsDir:    true,
-		Children: make(map[string]*Node),
-		Parent:   vfs.Current,
-	}
+	dir := vfs.newNode(name, true, vfs.Current)
 	vfs.Current.Children[name] = dir
+	touchDir(vfs.Current)
 	
 	// Trigger save callback if set
 	if vfs.onSaveCallback != nil {
@@ -435,7 +496,8 @@ func (vfs *VFS) CreateDirectory(name string) error {
 
 // DeleteNode deletes a file or directory in the current directory.
 // If recursive is true, deletes directories even if they contain files.
-// Returns an error if trying to delete a standard filesystem node or if the directory is not empty (when recursive is false).
+// Returns an error if trying to delete a standard filesystem node, if the directory is not empty (when recursive is false),
+// or if the current role lacks write permission on the directory.
 // Triggers the save callback if set to persist the change.
 func (vfs *VFS) DeleteNode(name string, recursive bool) error {
 	node, exists := vfs.Current.Children[name]
@@ -448,8 +510,8 @@ func (vfs *VFS) DeleteNode(name string, recursive bool) error {
 	if strings.HasSuffix(fullPath, "/") {
 		fullPath = fullPath[:len(fullPath)-1]
 	}
-	if vfs.isStandardPath(fullPath) {
-		return fmt.Errorf("cannot delete standard filesystem node: %s", name)
+	if node.IsDir && vfs.isStandardPath(fullPath) {
+		return fmt.Errorf("cannot delete standard directory: %s", name)
 	}
 	
 	// Check if directory contains standard paths
@@ -463,7 +525,16 @@ func (vfs *VFS) DeleteNode(name string, recursive bool) error {
 		return fmt.Errorf("cannot remove directory '%s': directory not empty", name)
 	}
 	
+	if err := checkWritable(node, name); err != nil {
+		return err
+	}
+	if err := vfs.canRemove(node); err != nil {
+		return err
+	}
+	
+	unlinkTree(node)
 	delete(vfs.Current.Children, name)
+	touchDir(vfs.Current)
 	
 	// Trigger save callback if set
 	if vfs.onSaveCallback != nil {
@@ -477,45 +548,61 @@ func (vfs *VFS) DeleteNode(name string, recursive bool) error {
 	return nil
 }
 
+// RemoveFile deletes the file at an absolute or relative path. Unlike DeleteNode it works
+// outside the current directory, and only on files.
+// Triggers the save callback if set to persist the change.
+func (vfs *VFS) RemoveFile(path string) error {
+	node, err := vfs.Lstat(path)
+	if err != nil {
+		return fmt.Errorf("no such file or directory: %s", path)
+	}
+	if node.IsDir {
+		return fmt.Errorf("is a directory: %s", path)
+	}
+	if err := checkWritable(node, path); err != nil {
+		return err
+	}
+	if err := vfs.canRemove(node); err != nil {
+		return err
+	}
+
+	unlinkTree(node)
+	delete(node.Parent.Children, node.Name)
+	touchDir(node.Parent)
+	vfs.saveChanges()
+	return nil
+}
+
 // CopyNode copies a file or directory from src to dest in the current directory.
-// The destination must not already exist. Returns an error if source doesn't exist or destination exists.
+// The destination must not already exist. Returns an error if source doesn't exist, destination exists,
+// or permission denied. The copy belongs to the current role and keeps the source's permission bits.
 // Triggers the save callback if set to persist the change.
 func (vfs *VFS) CopyNode(src, dest string) error {
 	srcNode := vfs.Current.Children[src]
 	if srcNode == nil {
 		return fmt.Errorf("source not found: %s", src)
 	}
+	if srcNode.IsSymlink() {
+		// Copy what the link points to
+		srcNode = vfs.findNode(srcNode.Path())
+		if srcNode == nil {
+			return fmt.Errorf("source not found: %s", src)
+		}
+	}
 	
 	if _, exists := vfs.Current.Children[dest]; exists {
 		return fmt.Errorf("destination already exists: %s", dest)
 	}
 	
-	// Create a copy
-	newNode := &Node{
-		Name:     dest,
-		IsDir:    srcNode.IsDir,
-		Content:  srcNode.Content,
-		Parent:   vfs.Current,
+	if err := vfs.CheckReadPermission(src); err != nil {
+		return err
 	}
-	
-	if srcNode.IsDir {
-		newNode.Children = make(map[string]*Node)
-		// Recursively copy children (simplified)
-		for name, child := range srcNode.Children {
-			childCopy := &Node{
-				Name:     name,
-				IsDir:    child.IsDir,
-				Content:  child.Content,
-				Parent:   newNode,
-			}
-			if child.IsDir {
-				childCopy.Children = make(map[string]*Node)
-			}
-			newNode.Children[name] = childCopy
-		}
+	if err := vfs.CheckWritePermission(dest); err != nil {
+		return err
 	}
 	
-	vfs.Current.Children[dest] = newNode
+	vfs.Current.Children[dest] = vfs.copyTree(srcNode, dest, vfs.Current)
+	touchDir(vfs.Current)
 	
 	// Trigger save callback if set
 	if vfs.onSaveCallback != nil {
@@ -528,8 +615,21 @@ func (vfs *VFS) CopyNode(src, dest string) error {
 	return nil
 }
 
+// copyTree copies node and everything below it into parent under the given name.
+func (vfs *VFS) copyTree(node *Node, name string, parent *Node) *Node {
+	copied := vfs.newNode(name, node.IsDir, parent)
+	copied.Content = node.readContent()
+	copied.Symlink = node.Symlink
+	copied.Mode, copied.HasMode = node.GetMode(), true
+	for childName, child := range node.Children {
+		copied.Children[childName] = vfs.copyTree(child, childName, copied)
+	}
+	return copied
+}
+
 // MoveNode moves or renames a file or directory from src to dest in the current directory.
-// Cannot move standard filesystem nodes. Returns an error if source doesn't exist or destination exists.
+// Cannot move standard filesystem nodes. Returns an error if source doesn't exist, destination exists,
+// or permission denied. The node keeps its owner, mode and timestamps.
 // Triggers the save callback if set to persist the change.
 func (vfs *VFS) MoveNode(src, dest string) error {
 	srcNode := vfs.Current.Children[src]
@@ -542,18 +642,27 @@ func (vfs *VFS) MoveNode(src, dest string) error {
 	if strings.HasSuffix(srcPath, "/") {
 		srcPath = srcPath[:len(srcPath)-1]
 	}
-	if vfs.isStandardPath(srcPath) {
-		return fmt.Errorf("cannot move standard filesystem node: %s", src)
+	if srcNode.IsDir && vfs.isStandardPath(srcPath) {
+		return fmt.Errorf("cannot move standard directory: %s", src)
 	}
 	
 	if _, exists := vfs.Current.Children[dest]; exists {
 		return fmt.Errorf("destination already exists: %s", dest)
 	}
 	
+	if err := checkWritable(srcNode, src); err != nil {
+		return err
+	}
+	if err := vfs.canRemove(srcNode); err != nil {
+		return err
+	}
+	
 	// Rename/move
+	srcNode.pinMetadata()
 	srcNode.Name = dest
 	vfs.Current.Children[dest] = srcNode
 	delete(vfs.Current.Children, src)
+	touchDir(vfs.Current)
 	
 	// Trigger save callback if set
 	if vfs.onSaveCallback != nil {
@@ -566,7 +675,8 @@ func (vfs *VFS) MoveNode(src, dest string) error {
 	return nil
 }
 
-// WriteFile writes content to a file in the current directory.
+// WriteFile writes content to a file, usually a name in the current directory.
+// Symbolic links are followed, so writing to a link changes the file it points to.
 // Returns an error if the file doesn't exist, is a directory, or permission denied.
 // Triggers the save callback if set to persist the change.
 func (vfs *VFS) WriteFile(name, content string) error {
@@ -575,16 +685,21 @@ func (vfs *VFS) WriteFile(name, content string) error {
 		return err
 	}
 	
-	file, exists := vfs.Current.Children[name]
-	if !exists {
+	file, err := vfs.resolve(name, true)
+	if err != nil {
 		return fmt.Errorf("file not found: %s", name)
 	}
 	
 	if file.IsDir {
 		return fmt.Errorf("cannot write to directory: %s", name)
 	}
+	if err := checkWritable(file, name); err != nil {
+		return err
+	}
 	
 	file.Content = content
+	file.ModTime = time.Now()
+	file.syncLinks()
 	
 	// Trigger save callback if set
 	if vfs.onSaveCallback != nil {
@@ -622,7 +737,20 @@ func (vfs *VFS) RenameHomeDirectory(newUsername string) error {
 		return fmt.Errorf("current user directory /home/%s not found", vfs.username)
 	}
 
-	// Rename the directory
+	// Rename the directory, handing over anything explicitly owned by the old name
+	var chown func(n *Node)
+	chown = func(n *Node) {
+		if n.Owner == vfs.username {
+			n.Owner = newUsername
+		}
+		if n.Group == vfs.username {
+			n.Group = newUsername
+		}
+		for _, child := range n.Children {
+			chown(child)
+		}
+	}
+	chown(oldUserDir)
 	oldUserDir.Name = newUsername
 	home.Children[newUsername] = oldUserDir
 	delete(home.Children, vfs.username)
@@ -688,11 +816,23 @@ func (vfs *VFS) InitializeSystemCommands() {
 		"rm":              "Delete file or directory",
 		"cp":              "Copy files/folders",
 		"mv":              "Move or rename files/folders",
+		"chmod":           "Change file permissions",
+		"chown":           "Change file owner and group",
+		"stat":            "Show file owner, permissions and timestamps",
+		"ln":              "Create hard or symbolic (-s) links",
+		"readlink":        "Show where a symbolic link points",
+		"tar":             "List (-t) or extract (-x) a tar archive",
+		"unzip":           "Extract a zip archive",
+		"gunzip":          "Decompress a .gz file",
+		"decrypt":         "Decrypt a file with its key",
 		"edit":            "Edit a file",
 		"clear":           "Clear the screen",
 		"help":            "Show available commands",
 		"whoami":          "Display current username",
 		"name":            "Change username",
+		"passwd":          "Change password",
+		"2fa":             "Manage two-factor authentication",
+		"sessions":        "List or sign out logged-in devices",
 		"ifconfig":        "Show network interfaces",
 		"scan":            "Scan internet or IP",
 		"ssh":             "Connect to a server",
@@ -700,13 +840,35 @@ func (vfs *VFS) InitializeSystemCommands() {
 		"server":          "Show current server info",
 		"get":             "Download tool from server",
 		"download":        "Download file to ~/Downloads",
+		"upload":          "Upload a file from your home computer to the server",
+		"scp":             "Copy a file between machines in your connection chain",
+		"nmap":            "Discover hosts and scan open ports (-sn, -sV, -T0-5, -p, -oN/-oG/-oJ)",
+		"curl":            "Send an HTTP request to a server's web service",
+		"browse":          "View a web page with its links and forms",
+		"mysql":           "Query a server's databases (mysql -h <host> -e \"<query>\")",
+		"traceroute":      "Show the hops on the route to a server",
+		"route":           "Show the routing table of the current machine",
+		"tunnel":          "Open a pivot tunnel through the current server (add, list, del)",
 		"tools":           "List owned tools",
 		"exploited":       "List exploited servers",
 		"wallet":          "Show wallet balance",
+		"transactions":    "Show wallet transaction history",
+		"pay":             "Send crypto to another player",
+		"trade":           "Trade tools, tokens and files with other players",
+		"market":          "Trade on the exchange's order book and listings",
+		"bounty":          "Post and hunt contracts on the bounty board",
+		"event":           "Live events, their leaderboards and heist loot",
+		"notifications":   "Read what happened while you were away",
 		"crypto_miner":    "Start mining",
 		"stop_mining":     "Stop mining",
 		"miners":          "List active miners",
+		"pool":            "Create, join or leave a mining pool",
+		"ps":              "List processes",
+		"jobs":            "List background jobs",
+		"fg":              "Bring a background job to the foreground",
+		"kill":            "Kill a job or process",
 		"userinfo":        "Show user information",
+		"achievements":    "Show achievements and progress",
 		"info":            "Display browser/client info",
 	}
 	
@@ -804,6 +966,9 @@ func (vfs *VFS) markStandardPaths() {
 func (vfs *VFS) markNodePaths(node *Node, path string) {
 	if path != "/" {
 		vfs.standardPaths[path] = true
+		if node.IsDir {
+			vfs.standardDirs[path] = true
+		}
 	}
 	
 	for name, child := range node.Children {
@@ -850,15 +1015,48 @@ func (vfs *VFS) checkDirectoryForStandardPaths(node *Node, basePath string) erro
 
 // ExtractChanges extracts only non-standard files/directories from the VFS.
 // Returns a map structure with only user-created/modified content, suitable for persistence.
-// Standard filesystem nodes (like /bin, /usr/bin) are excluded from the result.
+// Untouched standard filesystem nodes (like /bin, /usr/bin) are excluded from the result;
+// standard files that were deleted or moved away are recorded as whiteouts.
 func (vfs *VFS) ExtractChanges() map[string]interface{} {
 	changes := make(map[string]interface{})
 	vfs.extractChangesFromNode(vfs.Root, "/", changes)
+	vfs.extractWhiteouts(changes)
+	setChangesVersion(changes)
 	return changes
 }
 
+// extractWhiteouts adds a whiteout for every standard path that no longer exists.
+// Only the topmost missing path gets one; its children are gone with it.
+func (vfs *VFS) extractWhiteouts(changes map[string]interface{}) {
+	for path := range vfs.standardPaths {
+		if vfs.findNode(path) != nil {
+			continue
+		}
+		parentPath := filepath.Dir(path)
+		if parent := vfs.findNode(parentPath); parent == nil || !parent.IsDir {
+			continue
+		}
+
+		dir := changes
+		for _, part := range splitPath(parentPath) {
+			next, ok := dir[part].(map[string]interface{})
+			if !ok {
+				next = make(map[string]interface{})
+				dir[part] = next
+			}
+			dir = next
+		}
+		dir[filepath.Base(path)] = map[string]interface{}{whiteoutKey: true}
+	}
+}
+
 // extractChangesFromNode recursively extracts non-standard nodes
 func (vfs *VFS) extractChangesFromNode(node *Node, path string, changes map[string]interface{}) {
+	// Directory metadata that was changed from the defaults
+	if meta := node.metadataMap(); meta != nil {
+		changes[metaKey] = meta
+	}
+	
 	for name, child := range node.Children {
 		childPath := path
 		if childPath == "/" {
@@ -867,28 +1065,25 @@ func (vfs *VFS) extractChangesFromNode(node *Node, path string, changes map[stri
 			childPath = path + "/" + name
 		}
 		
+		// Pseudo-files are generated, not stored
+		if child.generate != nil {
+			continue
+		}
+		
 		// Normalize path for comparison
 		normalizedPath := filepath.Clean(childPath)
 		
 		// Check if this is a standard path
 		isStandard := vfs.isStandardPath(normalizedPath)
+		// A standard path that was turned from a file into a directory or back replaces the
+		// seeded entry instead of merging into it
+		replaced := isStandard && vfs.standardDirs[normalizedPath] != child.IsDir
 		
-		// Get relative path from root for building structure
-		relPath := strings.TrimPrefix(normalizedPath, "/")
-		parts := strings.Split(relPath, "/")
-		
-		// Navigate to the parent directory in changes map
+		// changes is already the map for this node's directory
 		current := changes
-		for i := 0; i < len(parts)-1; i++ {
-			part := parts[i]
-			if _, exists := current[part]; !exists {
-				current[part] = make(map[string]interface{})
-			}
-			current = current[part].(map[string]interface{})
-		}
 		
 		// Now handle the current node
-		if !isStandard {
+		if !isStandard || replaced {
 			// Non-standard node - add it to changes
 			// Use the child's name directly (not derived from path parts)
 			if child.IsDir {
@@ -901,9 +1096,10 @@ func (vfs *VFS) extractChangesFromNode(node *Node, path string, changes map[stri
 				vfs.extractChangesFromNode(child, childPath, dirMap)
 			} else {
 				// Add file with content
-				current[name] = map[string]interface{}{
-					"content": child.Content,
-				}
+				current[name] = fileEntry(child)
+			}
+			if replaced {
+				markOpaque(current[name].(map[string]interface{}))
 			}
 		} else if child.IsDir {
 			// Standard directory - recurse to find non-standard children
@@ -914,19 +1110,46 @@ func (vfs *VFS) extractChangesFromNode(node *Node, path string, changes map[stri
 			}
 			dirMap := current[name].(map[string]interface{})
 			vfs.extractChangesFromNode(child, childPath, dirMap)
+		} else if child.metadataMap() != nil {
+			// Standard file whose owner, mode or timestamps changed
+			current[name] = fileEntry(child)
 		}
-		// If it's a standard file, we skip it entirely
+		// Otherwise it's an untouched standard file, so we skip it entirely
+	}
+}
+
+// fileEntry returns the persisted form of a file: its content plus any explicit metadata and links.
+func fileEntry(file *Node) map[string]interface{} {
+	entry := map[string]interface{}{
+		"content": file.Content,
+	}
+	if file.IsSymlink() {
+		entry["content"] = file.Symlink
+		entry["type"] = "symlink"
+	}
+	if key := file.hardLinkKey(); key != "" {
+		entry["hardlink"] = key
+	}
+	for key, value := range file.metadataMap() {
+		entry[key] = value
 	}
+	return entry
 }
 
 // MergeFromMap merges a map structure into the VFS, overlaying on top of standard filesystem.
 // This is used to restore persisted filesystem changes from the database.
+// Maps in an older format are migrated first (see MigrateChanges).
 // Returns an error if the merge operation fails due to conflicts.
 func (vfs *VFS) MergeFromMap(fs map[string]interface{}) error {
 	if fs == nil || len(fs) == 0 {
 		return nil
 	}
-	return vfs.mergeIntoNode(vfs.Root, "/", fs)
+	fs, _ = MigrateChanges(fs)
+	if err := vfs.mergeIntoNode(vfs.Root, "/", fs); err != nil {
+		return err
+	}
+	vfs.relink()
+	return nil
 }
 
 // mergeIntoNode recursively merges map structure into VFS nodes
@@ -941,15 +1164,32 @@ func (vfs *VFS) mergeIntoNode(parent *Node, parentPath string, data map[string]i
 		
 		switch v := value.(type) {
 		case map[string]interface{}:
+			if key == metaKey {
+				// Metadata of the directory itself
+				parent.setMetadataFromMap(v)
+				continue
+			}
+			if isWhiteout(v) {
+				// Deleted or moved away since the filesystem was created
+				delete(parent.Children, key)
+				continue
+			}
+			
 			// Check if it's a file with content or a directory
 			if content, isFile := v["content"].(string); isFile {
 				// It's a file
-				// Check if node exists
-				if existing, exists := parent.Children[key]; exists {
-					if existing.IsDir {
+				// Check if node exists; a file marked opaque replaces a directory
+				existing, exists := parent.Children[key]
+				if exists && existing.IsDir {
+					if !isOpaque(v) {
 						return fmt.Errorf("cannot merge file into existing directory: %s", childPath)
 					}
+					exists = false
+				}
+				if exists {
 					existing.Content = content
+					existing.setMetadataFromMap(v)
+					existing.setLinkFromMap(v)
 				} else {
 					// Create new file
 					file := &Node{
@@ -958,12 +1198,15 @@ func (vfs *VFS) mergeIntoNode(parent *Node, parentPath string, data map[string]i
 						Content: content,
 						Parent:  parent,
 					}
+					file.setMetadataFromMap(v)
+					file.setLinkFromMap(v)
 					parent.Children[key] = file
 				}
 			} else {
 				// It's a directory
+				// A directory marked opaque replaces what was there instead of merging into it
 				var dirNode *Node
-				if existing, exists := parent.Children[key]; exists {
+				if existing, exists := parent.Children[key]; exists && !isOpaque(v) {
 					if !existing.IsDir {
 						return fmt.Errorf("cannot merge directory into existing file: %s", childPath)
 					}
@@ -1012,6 +1255,16 @@ func (vfs *VFS) SetSaveCallback(callback func(map[string]interface{}) error) {
 	vfs.onSaveCallback = callback
 }
 
+// Flush persists the current filesystem changes through the save callback.
+// Used on shutdown to make sure the latest state is written even if an earlier save failed.
+// Returns nil if no save callback is set.
+func (vfs *VFS) Flush() error {
+	if vfs.onSaveCallback == nil {
+		return nil
+	}
+	return vfs.onSaveCallback(vfs.ExtractChanges())
+}
+
 // SetServerID sets the server ID/path for server VFS persistence.
 // Marks this VFS as a server filesystem and stores the server identifier.
 func (vfs *VFS) SetServerID(serverID string) {
@@ -1067,57 +1320,14 @@ func (vfs *VFS) GetHomeDir() string {
 	return "/home/" + vfs.GetRole()
 }
 
-// CanAccessPath checks if the current role can access a path.
+// CanAccessPath checks if the current role can access a path, using the owner, group and mode of
+// the path and of the directories leading to it.
 // Returns an error message if access is denied, empty string if allowed.
 func (vfs *VFS) CanAccessPath(path string, isWrite bool) string {
-	// Root can access everything
-	if vfs.isRoot {
-		return ""
-	}
-
-	// Normalize path
-	if !strings.HasPrefix(path, "/") {
-		currentPath := vfs.GetCurrentPath()
-		if currentPath == "/" {
-			path = "/" + path
-		} else {
-			path = currentPath + "/" + path
-		}
-	}
-	path = filepath.Clean(path)
-
-	// Restricted paths that only root can access
-	rootOnlyPaths := []string{
-		"/root",
-		"/etc/shadow",
-		"/etc/sudoers",
-	}
-
-	for _, restricted := range rootOnlyPaths {
-		if path == restricted || strings.HasPrefix(path, restricted+"/") {
-			return "Permission denied"
-		}
-	}
-
-	// System directories - require root for write access
 	if isWrite {
-		systemDirs := []string{"/etc", "/usr", "/bin", "/sbin", "/var", "/lib"}
-		for _, sysDir := range systemDirs {
-			if path == sysDir || strings.HasPrefix(path, sysDir+"/") {
-				return "Permission denied: requires root privileges"
-			}
-		}
+		return vfs.checkAccess(path, permWrite)
 	}
-
-	// Users can only write to their own home directory
-	if isWrite {
-		userHome := vfs.GetHomeDir()
-		if !strings.HasPrefix(path, userHome) && !strings.HasPrefix(path, "/tmp") {
-			return "Permission denied: can only write to home directory or /tmp"
-		}
-	}
-
-	return ""
+	return vfs.checkAccess(path, permRead)
 }
 
 // CheckReadPermission checks read permission for a path.
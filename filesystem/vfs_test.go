@@ -0,0 +1,117 @@
+package filesystem
+
+import "testing"
+
+func TestSetRoleLeavesHomeOwnership(t *testing.T) {
+	vfs, err := NewVFSFromMap("root", map[string]interface{}{
+		"srv": map[string]interface{}{"ftp": map[string]interface{}{"pub": map[string]interface{}{}}},
+	})
+	if err != nil {
+		t.Fatalf("failed to load filesystem: %v", err)
+	}
+
+	// An anonymous FTP login is homed in the shared tree without taking it over
+	vfs.SetRole("anonymous", false, "/srv/ftp")
+	node, err := vfs.Stat("/srv/ftp")
+	if err != nil {
+		t.Fatalf("failed to stat /srv/ftp: %v", err)
+	}
+	if node.GetOwner() != "root" || node.GetGroup() != "root" {
+		t.Fatalf("expected /srv/ftp to stay root:root, got %s:%s", node.GetOwner(), node.GetGroup())
+	}
+	srv, _ := vfs.ExtractChanges()["srv"].(map[string]interface{})
+	if ftp, _ := srv["ftp"].(map[string]interface{}); ftp != nil && ftp["."] != nil {
+		t.Fatalf("expected no metadata to be persisted for /srv/ftp, got %v", ftp["."])
+	}
+}
+
+func TestDeletedSeedFilesStayDeleted(t *testing.T) {
+	vfs := NewVFS("alice")
+	if err := vfs.MoveNode("README.txt", "old-readme.txt"); err != nil {
+		t.Fatalf("mv failed: %v", err)
+	}
+
+	again, err := NewUserVFSFromMap("alice", vfs.ExtractChanges())
+	if err != nil {
+		t.Fatalf("failed to reload vfs: %v", err)
+	}
+	if _, err := again.ReadFileAtPath("/home/alice/README.txt"); err == nil {
+		t.Fatal("expected moved README.txt to stay gone after reload")
+	}
+	if _, err := again.ReadFileAtPath("/home/alice/old-readme.txt"); err != nil {
+		t.Fatalf("expected README.txt at its new name, got %v", err)
+	}
+
+	// Standard directories are still protected
+	if err := again.ChangeDir("/"); err != nil {
+		t.Fatalf("cd failed: %v", err)
+	}
+	again.SetRole("root", true, "/root")
+	if err := again.DeleteNode("bin", true); err == nil {
+		t.Fatal("expected deleting /bin to fail")
+	}
+}
+
+func TestSeedFileReplacedByDirectory(t *testing.T) {
+	tests := []struct {
+		name    string
+		replace func(vfs *VFS) error
+	}{
+		{"rm and mkdir", func(vfs *VFS) error {
+			if err := vfs.DeleteNode("README.txt", false); err != nil {
+				return err
+			}
+			return vfs.CreateDirectory("README.txt")
+		}},
+		{"mv", func(vfs *VFS) error {
+			if err := vfs.DeleteNode("README.txt", false); err != nil {
+				return err
+			}
+			if err := vfs.CreateDirectory("notes"); err != nil {
+				return err
+			}
+			return vfs.MoveNode("notes", "README.txt")
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vfs := NewVFS("alice")
+			if err := tt.replace(vfs); err != nil {
+				t.Fatalf("failed to replace README.txt: %v", err)
+			}
+			if err := vfs.EnsureDirectoryAndCreateFile("/home/alice/README.txt", "todo.txt", "keep me"); err != nil {
+				t.Fatalf("failed to create file: %v", err)
+			}
+			if err := vfs.CreateFile("diary.txt"); err != nil {
+				t.Fatalf("failed to create file: %v", err)
+			}
+
+			again, err := NewUserVFSFromMap("alice", vfs.ExtractChanges())
+			if err != nil {
+				t.Fatalf("failed to reload vfs: %v", err)
+			}
+			if content, err := again.ReadFileAtPath("/home/alice/README.txt/todo.txt"); err != nil || content != "keep me" {
+				t.Fatalf("expected the file in the replacing directory, got %q (%v)", content, err)
+			}
+			if _, err := again.ReadFileAtPath("/home/alice/diary.txt"); err != nil {
+				t.Fatalf("expected the other saved files, got %v", err)
+			}
+		})
+	}
+}
+
+func TestReceiveFileIntoDirectory(t *testing.T) {
+	vfs := NewVFS("alice")
+
+	path, err := vfs.ReceiveFile(".", "payload.sh", "#!/bin/sh\n")
+	if err != nil || path != "/home/alice/payload.sh" {
+		t.Fatalf("expected file in current directory, got %q (%v)", path, err)
+	}
+	path, err = vfs.ReceiveFile("/home/alice/renamed.sh", "payload.sh", "#!/bin/sh\n")
+	if err != nil || path != "/home/alice/renamed.sh" {
+		t.Fatalf("expected file at the given name, got %q (%v)", path, err)
+	}
+	if _, err := vfs.ReceiveFile("/nowhere/x.sh", "payload.sh", ""); err == nil {
+		t.Fatal("expected missing parent directory to fail")
+	}
+}
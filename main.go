@@ -12,9 +12,13 @@ import (
 	"terminal-sh/config"
 	"terminal-sh/database"
 	"terminal-sh/services"
+	"terminal-sh/terminal"
 	"terminal-sh/terminal/ssh"
 )
 
+// drainTimeout bounds how long shutdown waits for sessions and in-flight operations.
+const drainTimeout = 10 * time.Second
+
 func main() {
 	cfg := config.Load()
 
@@ -57,6 +61,17 @@ func main() {
 		fmt.Println("✓")
 	}
 
+	// Check that wallets still match the ledger
+	fmt.Print("Reconciling ledger... ")
+	if report, err := services.NewLedgerService(db).Reconcile(); err != nil {
+		log.Printf("\n✗ Failed to reconcile ledger: %v", err)
+	} else if !report.OK() {
+		log.Printf("\n✗ Ledger drift: %d unbalanced transactions, %d wallets off, %d orphaned accounts",
+			len(report.Unbalanced), len(report.Drift), len(report.Orphaned))
+	} else {
+		fmt.Println("✓")
+	}
+
 	// Initialize chat service
 	fmt.Print("Initializing chat service... ")
 	chatService := services.NewChatService(db)
@@ -72,6 +87,12 @@ func main() {
 	fmt.Printf("╚═══════════════════════════════════════╝\n")
 	fmt.Println()
 
+	// Start the background jobs (sysadmin patrols, mining payouts, live events). Deferred after the
+	// database close, so they stop first
+	scheduler := services.NewWorldScheduler(db, chatService)
+	scheduler.Start()
+	defer scheduler.Stop()
+
 	// Set up signal handling for graceful shutdown
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
@@ -90,6 +111,16 @@ func main() {
 		fmt.Printf("\n\nReceived signal: %v\n", sig)
 		fmt.Println("Shutting down gracefully...")
 		
+		// Notify sessions, let in-flight operations finish and flush filesystems
+		drainCtx, cancelDrain := context.WithTimeout(context.Background(), drainTimeout)
+		persisted, err := terminal.Shutdown(drainCtx, "server restarting")
+		cancelDrain()
+		if err != nil {
+			log.Printf("Error draining sessions: %v", err)
+		} else {
+			fmt.Printf("✓ Sessions drained (%d operations saved for resumption)\n", persisted)
+		}
+		
 		// Give the server a moment to finish current operations
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
@@ -0,0 +1,169 @@
+// Package metrics provides Prometheus instrumentation for the terminal.sh server.
+// Collectors are package-level so services can record events without extra wiring;
+// gauges that need database access are registered by the HTTP server at startup.
+package metrics
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "terminalsh"
+
+// Session transports used as label values for ActiveSessions.
+const (
+	TransportSSH       = "ssh"
+	TransportWebSocket = "websocket"
+)
+
+var registry = prometheus.NewRegistry()
+
+var (
+	// ActiveSessions tracks currently connected clients by transport (ssh, websocket).
+	ActiveSessions = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "active_sessions",
+		Help:      "Number of currently connected client sessions by transport.",
+	}, []string{"transport"})
+
+	// Logins counts login attempts by result (success, failure).
+	Logins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "logins_total",
+		Help:      "Total login attempts by result.",
+	}, []string{"result"})
+
+	// LoginFailures counts failed login attempts by reason (see the Reason constants).
+	LoginFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "login_failures_total",
+		Help:      "Total failed login attempts by reason.",
+	}, []string{"reason"})
+
+	// Registrations counts newly created user accounts.
+	Registrations = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "registrations_total",
+		Help:      "Total user accounts registered.",
+	})
+
+	// Commands counts shell commands executed by command name.
+	Commands = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "commands_total",
+		Help:      "Total shell commands executed by command name.",
+	}, []string{"command"})
+
+	// CommandDuration observes how long shell commands take to execute.
+	CommandDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "command_duration_seconds",
+		Help:      "Shell command execution latency by command name.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"command"})
+
+	// Exploits counts exploit attempts by result (success, failure).
+	Exploits = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "exploits_total",
+		Help:      "Total exploit attempts by result.",
+	}, []string{"result"})
+
+	// ChatMessages counts chat messages sent by players.
+	ChatMessages = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "chat_messages_total",
+		Help:      "Total chat messages sent.",
+	})
+
+	// DBQueryDuration observes database query latency by operation (query, create, update, delete, row, raw).
+	DBQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Database query latency by operation.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"operation"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		ActiveSessions,
+		Logins,
+		LoginFailures,
+		Registrations,
+		Commands,
+		CommandDuration,
+		Exploits,
+		ChatMessages,
+		DBQueryDuration,
+	)
+}
+
+// Result label values shared by Logins and Exploits.
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+)
+
+// ResultLabel converts a boolean outcome into a result label value.
+func ResultLabel(ok bool) string {
+	if ok {
+		return ResultSuccess
+	}
+	return ResultFailure
+}
+
+// Reason label values for LoginFailures.
+const (
+	ReasonUnknownUser = "unknown_user" // No account with that username
+	ReasonBadPassword = "bad_password"
+	ReasonBadTOTP     = "bad_totp"
+	ReasonLockedOut   = "locked_out" // The username is locked after repeated failures
+	ReasonThrottled   = "throttled"  // The source IP is locked after repeated failures
+)
+
+// ObserveLoginFailure records a failed login attempt and why it failed.
+func ObserveLoginFailure(reason string) {
+	Logins.WithLabelValues(ResultFailure).Inc()
+	LoginFailures.WithLabelValues(reason).Inc()
+}
+
+// ObserveCommand records a command execution and its latency.
+func ObserveCommand(command string, duration time.Duration) {
+	Commands.WithLabelValues(command).Inc()
+	CommandDuration.WithLabelValues(command).Observe(duration.Seconds())
+}
+
+var gaugeFuncsOnce sync.Once
+
+// RegisterGameGauges registers gauges whose values are read on each scrape.
+// activeMiners and proceduralServers are called from the scrape goroutine and must be safe for concurrent use.
+// Only the first call has any effect, so multiple servers in one process can call it safely.
+func RegisterGameGauges(activeMiners, proceduralServers func() float64) {
+	gaugeFuncsOnce.Do(func() {
+		registry.MustRegister(
+			prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "active_miners",
+				Help:      "Number of crypto miners currently running across all players.",
+			}, activeMiners),
+			prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "procedural_servers",
+				Help:      "Number of procedurally generated servers in the world.",
+			}, proceduralServers),
+		)
+	})
+}
+
+// Handler returns an HTTP handler that serves all registered metrics in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{Registry: registry})
+}
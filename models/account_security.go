@@ -0,0 +1,69 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// PasswordResetToken is a one-time token issued by an admin so a user can log in and set a new password.
+// Only a SHA-256 hash of the token is stored.
+type PasswordResetToken struct {
+	ID        uuid.UUID  `gorm:"type:text;primary_key" json:"id"`
+	UserID    uuid.UUID  `gorm:"type:text;not null;index" json:"user_id"`
+	TokenHash string     `gorm:"uniqueIndex;not null" json:"-"`
+	IssuedBy  uuid.UUID  `gorm:"type:text;not null" json:"issued_by"` // Admin user who issued the token
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// BeforeCreate is a GORM hook that generates a UUID for the reset token if one doesn't exist.
+func (t *PasswordResetToken) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
+	return nil
+}
+
+// LoginThrottle tracks consecutive failed logins for a username or source IP.
+// Key is "user:<username>" or "ip:<address>".
+type LoginThrottle struct {
+	ID            uuid.UUID  `gorm:"type:text;primary_key" json:"id"`
+	Key           string     `gorm:"column:throttle_key;uniqueIndex;not null" json:"key"`
+	Failures      int        `gorm:"not null;default:0" json:"failures"`
+	LastFailureAt time.Time  `gorm:"not null" json:"last_failure_at"`
+	LockedUntil   *time.Time `json:"locked_until,omitempty"`
+}
+
+// BeforeCreate is a GORM hook that generates a UUID for the login throttle if one doesn't exist.
+func (t *LoginThrottle) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
+	return nil
+}
+
+// DeviceSession is a logged-in device holding a refresh token.
+// Only a SHA-256 hash of the refresh token is stored; it is replaced each time the token is used.
+type DeviceSession struct {
+	ID                uuid.UUID  `gorm:"type:text;primary_key" json:"id"`
+	UserID            uuid.UUID  `gorm:"type:text;not null;index" json:"user_id"`
+	RefreshTokenHash  string     `gorm:"uniqueIndex;not null" json:"-"`
+	PreviousTokenHash string     `gorm:"index" json:"-"`         // The refresh token the current one replaced; presenting it again revokes the session
+	Device            string     `gorm:"not null" json:"device"` // Client description, e.g. browser and OS
+	SourceIP          string     `gorm:"" json:"source_ip"`      // Address the session was last used from
+	ExpiresAt         time.Time  `gorm:"not null" json:"expires_at"`
+	LastUsedAt        time.Time  `gorm:"not null" json:"last_used_at"`
+	RevokedAt         *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+}
+
+// BeforeCreate is a GORM hook that generates a UUID for the device session if one doesn't exist.
+func (d *DeviceSession) BeforeCreate(tx *gorm.DB) error {
+	if d.ID == uuid.Nil {
+		d.ID = uuid.New()
+	}
+	return nil
+}
@@ -23,3 +23,12 @@ func (u *UserAchievement) BeforeCreate(tx *gorm.DB) error {
 	return nil
 }
 
+
+// AchievementCounter is a user's running value for one kind of achievement criteria, moved as
+// their actions are tracked so criteria don't have to be counted from every action each time.
+type AchievementCounter struct {
+	UserID    uuid.UUID `gorm:"type:text;primary_key" json:"user_id"`
+	Criteria  string    `gorm:"primary_key" json:"criteria"` // Type, action and tool, e.g. "action_count:tool_use:nmap"
+	Value     float64   `gorm:"not null;default:0" json:"value"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -1,11 +1,33 @@
 package models
 
+// Achievement criteria types
+const (
+	CriteriaActionCount     = "action_count"     // Number of tracked actions of a type
+	CriteriaActionSum       = "action_sum"       // Total Amount of tracked actions of a type
+	CriteriaDistinctServers = "distinct_servers" // Number of different servers an action was done on
+	CriteriaToolUsage       = "tool_usage"       // Number of times a tool was used
+	CriteriaWallet          = "wallet"           // Wallet balance of a currency
+	CriteriaStreak          = "streak"           // Consecutive days with a tracked action
+)
+
+// AchievementCriteria is the rule that unlocks an achievement automatically once its
+// Target is reached.
+type AchievementCriteria struct {
+	Type     string     `json:"type"`               // One of the Criteria* types
+	Action   ActionType `json:"action,omitempty"`   // Action type counted, for action and streak criteria (any action if empty)
+	Tool     string     `json:"tool,omitempty"`     // Tool for tool_usage; narrows the other action criteria
+	Currency string     `json:"currency,omitempty"` // crypto or data, for wallet criteria
+	Target   float64    `json:"target"`
+}
+
 // AchievementDefinition represents an achievement definition
 type AchievementDefinition struct {
-	ID          string `json:"id"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	Icon        string `json:"icon,omitempty"` // Emoji or icon identifier
+	ID          string               `json:"id"`
+	Name        string               `json:"name"`
+	Description string               `json:"description"`
+	Icon        string               `json:"icon,omitempty"`     // Emoji or icon identifier
+	Hidden      bool                 `json:"hidden,omitempty"`   // Secret: name and description are shown only once unlocked
+	Criteria    *AchievementCriteria `json:"criteria,omitempty"` // Unlock rule; without one the achievement is only granted by missions
 }
 
 // AchievementData represents the complete achievement data structure
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// BountyKind is what a bounty's hunter has to do to earn the reward.
+type BountyKind string
+
+const (
+	BountyRoot  BountyKind = "root"  // Get root on the target server
+	BountyCreds BountyKind = "creds" // Bring back the password for an account on the target
+	BountyWipe  BountyKind = "wipe"  // Clean the logs on the target after accepting
+)
+
+// BountyStatus is where a bounty is in its lifecycle.
+type BountyStatus string
+
+const (
+	BountyOpen      BountyStatus = "open"      // Posted, waiting for a hunter
+	BountyAccepted  BountyStatus = "accepted"  // A hunter is working on it
+	BountyClaimed   BountyStatus = "claimed"   // Verified done, paid out once the dispute window passes
+	BountyDisputed  BountyStatus = "disputed"  // The poster disputed the claim, waiting for an admin
+	BountyCompleted BountyStatus = "completed" // The hunter was paid
+	BountyRefunded  BountyStatus = "refunded"  // A dispute went to the poster, who got the reward back
+	BountyExpired   BountyStatus = "expired"   // Nobody completed it in time, the reward went back
+	BountyCancelled BountyStatus = "cancelled" // Withdrawn by the poster before anyone accepted it
+)
+
+// Bounty is a contract on the bounty board. Its reward is held in escrow from the moment it's
+// posted until it's paid to the hunter or returned to the poster.
+type Bounty struct {
+	ID          uuid.UUID    `gorm:"type:text;primary_key" json:"id"`
+	PosterID    *uuid.UUID   `gorm:"type:text;index" json:"poster_id,omitempty"` // Nil for bounties posted by the game
+	HunterID    *uuid.UUID   `gorm:"type:text;index" json:"hunter_id,omitempty"`
+	Kind        BountyKind   `gorm:"not null" json:"kind"`
+	Target      string       `gorm:"not null" json:"target"` // Server IP
+	Account     string       `json:"account,omitempty"`      // Username wanted by a creds bounty
+	Reward      float64      `gorm:"not null" json:"reward"` // Crypto held in escrow
+	Status      BountyStatus `gorm:"not null;index" json:"status"`
+	Disputed    bool         `gorm:"default:false" json:"disputed"` // Set once disputed, whatever the outcome
+	Dispute     string       `json:"dispute,omitempty"`             // The poster's reason for disputing
+	ExpiresAt   time.Time    `gorm:"not null" json:"expires_at"`
+	AcceptedAt  *time.Time   `json:"accepted_at,omitempty"`
+	ClaimedAt   *time.Time   `json:"claimed_at,omitempty"`
+	CompletedAt *time.Time   `json:"completed_at,omitempty"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
+
+// BeforeCreate is a GORM hook that generates a UUID for the bounty if one doesn't exist.
+func (b *Bounty) BeforeCreate(tx *gorm.DB) error {
+	if b.ID == uuid.Nil {
+		b.ID = uuid.New()
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// EventKind is what a live event does to the world while it runs.
+type EventKind string
+
+const (
+	EventZeroDay   EventKind = "zeroday"   // One vulnerability type is exploitable on every service of every server
+	EventHeist     EventKind = "heist"     // A bank server with a vault full of crypto comes online
+	EventCrackdown EventKind = "crackdown" // Every server's security level goes up
+)
+
+// EventStatus is where a live event is in its lifecycle.
+type EventStatus string
+
+const (
+	EventScheduled EventStatus = "scheduled" // Waiting for its start time
+	EventActive    EventStatus = "active"    // Running: its effects are on and it's scoring
+	EventEnded     EventStatus = "ended"     // Over, effects undone and prizes paid
+	EventCancelled EventStatus = "cancelled" // Called off by an operator before it started
+)
+
+// LiveEvent is a time-limited change to the whole world, scheduled by an operator or
+// triggered at random. Players score on its leaderboard while it runs, and the top scorers
+// split its prize when it ends.
+type LiveEvent struct {
+	ID        uuid.UUID   `gorm:"type:text;primary_key" json:"id"`
+	Kind      EventKind   `gorm:"not null" json:"kind"`
+	Status    EventStatus `gorm:"not null;index" json:"status"`
+	VulnType  string      `json:"vuln_type,omitempty"`                       // Zero-day: the vulnerability type anyone can exploit
+	ServerIP  string      `json:"server_ip,omitempty"`                       // Heist: the bank server, once it's online
+	Boost     int         `json:"boost,omitempty"`                           // Crackdown: security levels added to every server, capped per server (see Server.SecurityBoost)
+	Boosted   bool        `json:"boosted,omitempty"`                         // Crackdown: the boost was applied, and comes off when it ends
+	Prize     float64     `gorm:"not null" json:"prize"`                     // Crypto split among the top of the leaderboard
+	Missions  []Mission   `gorm:"type:text;serializer:json" json:"missions"` // Missions only available while the event runs
+	StartsAt  time.Time   `gorm:"not null;index" json:"starts_at"`
+	EndsAt    time.Time   `gorm:"not null" json:"ends_at"`
+	StartedAt *time.Time  `json:"started_at,omitempty"`
+	CreatedBy *uuid.UUID  `gorm:"type:text" json:"created_by,omitempty"` // Nil for random events
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+// BeforeCreate is a GORM hook that generates a UUID for the event if one doesn't exist.
+func (e *LiveEvent) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	return nil
+}
+
+// EventScore is a player's line on a live event's leaderboard.
+type EventScore struct {
+	EventID   uuid.UUID `gorm:"type:text;primary_key" json:"event_id"`
+	UserID    uuid.UUID `gorm:"type:text;primary_key" json:"user_id"`
+	Username  string    `gorm:"not null" json:"username"`
+	Score     float64   `gorm:"not null;default:0" json:"score"`
+	Targets   []string  `gorm:"type:text;serializer:json" json:"targets"` // Server/service pairs already scored, so re-exploiting doesn't count twice
+	Payout    float64   `gorm:"default:0" json:"payout"`                  // Prize paid when the event ended
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// InterruptedOperation records a progress operation that was still running when the server shut down.
+// The shell resumes it on the user's next login.
+type InterruptedOperation struct {
+	ID         uuid.UUID `gorm:"type:text;primary_key" json:"id"`
+	UserID     uuid.UUID `gorm:"type:text;not null;index" json:"user_id"`
+	Command    string    `gorm:"not null" json:"command"`             // Command line that started the operation
+	ServerPath string    `gorm:"" json:"server_path"`                 // Server the user was connected to, empty if on user's local system
+	Message    string    `gorm:"" json:"message"`                     // Progress message shown while running
+	Remaining  float64   `gorm:"not null;default:0" json:"remaining"` // Seconds left when the operation was interrupted
+	CreatedAt  time.Time `gorm:"not null" json:"created_at"`
+}
+
+// BeforeCreate is a GORM hook that generates a UUID for the interrupted operation if one doesn't exist.
+func (o *InterruptedOperation) BeforeCreate(tx *gorm.DB) error {
+	if o.ID == uuid.Nil {
+		o.ID = uuid.New()
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrLedgerImmutable is returned when something tries to change or remove a ledger entry.
+var ErrLedgerImmutable = errors.New("ledger entries are immutable")
+
+// Currency is a wallet currency.
+type Currency string
+
+const (
+	CurrencyCrypto Currency = "crypto"
+	CurrencyData   Currency = "data"
+)
+
+// LedgerEntry is one leg of a ledger transaction: a credit (positive Amount) or debit (negative)
+// to an account. The legs of a transaction sum to zero, so every unit of currency comes from
+// somewhere. Accounts are "user:<id>", "server:<id>" or "system:<name>" for the game itself
+// (mining, missions, shops and the like).
+type LedgerEntry struct {
+	ID            uuid.UUID `gorm:"type:text;primary_key" json:"id"`
+	TransactionID uuid.UUID `gorm:"type:text;not null;index" json:"transaction_id"`
+	Account       string    `gorm:"not null;index" json:"account"`
+	Currency      Currency  `gorm:"not null" json:"currency"`
+	Amount        float64   `gorm:"not null" json:"amount"`
+	Balance       float64   `json:"balance"`                // Account balance after the entry (user and server accounts)
+	Reason        string    `gorm:"not null" json:"reason"` // e.g. mining_reward, purchase, mission_reward
+	Reference     string    `json:"reference,omitempty"`    // What the transaction was for, e.g. a mission or item ID
+	CreatedAt     time.Time `gorm:"index" json:"created_at"`
+}
+
+// BeforeCreate is a GORM hook that generates a UUID for the entry if one doesn't exist.
+func (e *LedgerEntry) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	return nil
+}
+
+// BeforeUpdate is a GORM hook that keeps entries from being changed once written.
+func (e *LedgerEntry) BeforeUpdate(tx *gorm.DB) error {
+	return ErrLedgerImmutable
+}
+
+// BeforeDelete is a GORM hook that keeps entries from being removed once written.
+func (e *LedgerEntry) BeforeDelete(tx *gorm.DB) error {
+	return ErrLedgerImmutable
+}
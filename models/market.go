@@ -0,0 +1,87 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// TradeStatus represents the state of a trade.
+type TradeStatus string
+
+const (
+	TradeOpen      TradeStatus = "open"      // Item in escrow, waiting for a buyer
+	TradeSettled   TradeStatus = "settled"   // Paid for and handed over
+	TradeCancelled TradeStatus = "cancelled" // Withdrawn by the seller
+	TradeDeclined  TradeStatus = "declined"  // Turned down by the player it was offered to
+	TradeExpired   TradeStatus = "expired"   // Nobody took it in time
+)
+
+// Trade is an item held in escrow for sale: an offer to one player, or a listing on the
+// market anyone can take. The item leaves the seller when the trade opens and goes to the
+// buyer when it settles, or back to the seller if it doesn't.
+type Trade struct {
+	ID          uuid.UUID   `gorm:"type:text;primary_key" json:"id"`
+	SellerID    uuid.UUID   `gorm:"type:text;not null;index" json:"seller_id"`
+	BuyerID     *uuid.UUID  `gorm:"type:text;index" json:"buyer_id,omitempty"` // Who the offer is for; set on listings once bought
+	Listed      bool        `gorm:"default:false;index" json:"listed"`         // On the market rather than offered to one player
+	ItemType    ItemType    `gorm:"not null" json:"item_type"`
+	ItemName    string      `gorm:"not null" json:"item_name"`
+	ItemRef     string      `json:"item_ref,omitempty"` // Tool state or upgrade token in escrow
+	Content     string      `gorm:"type:text" json:"-"` // Loot file content in escrow
+	PriceCrypto float64     `gorm:"default:0" json:"price_crypto"`
+	Status      TradeStatus `gorm:"not null;default:open;index" json:"status"`
+	Delivered   bool        `gorm:"default:false" json:"delivered"` // Whether the item has reached whoever ends up with it
+	ExpiresAt   time.Time   `gorm:"index" json:"expires_at"`
+	SettledAt   *time.Time  `json:"settled_at,omitempty"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+}
+
+// BeforeCreate is a GORM hook that generates a UUID for the trade if one doesn't exist.
+func (t *Trade) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
+	return nil
+}
+
+// OrderSide is which side of the order book an order is on.
+type OrderSide string
+
+const (
+	OrderBuy  OrderSide = "buy"  // Buying data with crypto
+	OrderSell OrderSide = "sell" // Selling data for crypto
+)
+
+// OrderStatus represents the state of a market order.
+type OrderStatus string
+
+const (
+	OrderOpen      OrderStatus = "open"
+	OrderFilled    OrderStatus = "filled"
+	OrderCancelled OrderStatus = "cancelled"
+)
+
+// MarketOrder is an order on the market's data/crypto order book. Price is crypto per unit of
+// data. The currency an order could spend is held in escrow until it fills or is cancelled.
+type MarketOrder struct {
+	ID        uuid.UUID   `gorm:"type:text;primary_key" json:"id"`
+	UserID    uuid.UUID   `gorm:"type:text;not null;index" json:"user_id"`
+	Side      OrderSide   `gorm:"not null;index" json:"side"`
+	Quantity  float64     `gorm:"not null" json:"quantity"`
+	Remaining float64     `gorm:"not null" json:"remaining"`
+	Price     float64     `gorm:"not null" json:"price"`
+	Status    OrderStatus `gorm:"not null;default:open;index" json:"status"`
+	CreatedAt time.Time   `gorm:"index" json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+// BeforeCreate is a GORM hook that generates a UUID for the market order if one doesn't exist.
+func (o *MarketOrder) BeforeCreate(tx *gorm.DB) error {
+	if o.ID == uuid.Nil {
+		o.ID = uuid.New()
+	}
+	return nil
+}
@@ -9,18 +9,20 @@ import (
 
 // MiningResourceUsage represents computational resource usage for cryptocurrency mining.
 type MiningResourceUsage struct {
-	CPU      float64 `json:"cpu"`
+	CPU       float64 `json:"cpu"`
 	Bandwidth float64 `json:"bandwidth"`
-	RAM      int     `json:"ram"`
+	RAM       int     `json:"ram"`
 }
 
 // ActiveMiner represents an active cryptocurrency mining session on a server.
 type ActiveMiner struct {
-	ID            uuid.UUID          `gorm:"type:text;primary_key" json:"id"`
-	UserID        uuid.UUID          `gorm:"type:text;not null;index" json:"user_id"`
-	ServerIP      string             `gorm:"not null;index" json:"server_ip"`
-	StartTime     time.Time          `gorm:"not null" json:"start_time"`
+	ID            uuid.UUID           `gorm:"type:text;primary_key" json:"id"`
+	UserID        uuid.UUID           `gorm:"type:text;not null;index" json:"user_id"`
+	ServerIP      string              `gorm:"not null;index" json:"server_ip"`
+	StartTime     time.Time           `gorm:"not null" json:"start_time"` // Start of the period that hasn't been paid yet
 	ResourceUsage MiningResourceUsage `gorm:"type:text;serializer:json" json:"resource_usage"`
+	Hashrate      float64             `gorm:"default:0" json:"hashrate"` // MH/s, from the host's CPU and the miner's efficiency
+	Pending       float64             `gorm:"default:0" json:"pending"`  // Solo earnings short of a full block, lost if the miner stops
 }
 
 // BeforeCreate is a GORM hook that generates a UUID for the active miner if one doesn't exist.
@@ -31,3 +33,27 @@ func (a *ActiveMiner) BeforeCreate(tx *gorm.DB) error {
 	return nil
 }
 
+// MiningPool is a group of players who share mining payouts. Members are paid for their
+// hashrate every period instead of waiting to find whole blocks, less the pool's fee.
+type MiningPool struct {
+	ID        uuid.UUID `gorm:"type:text;primary_key" json:"id"`
+	Name      string    `gorm:"uniqueIndex;not null" json:"name"`
+	OwnerID   uuid.UUID `gorm:"type:text;not null;index" json:"owner_id"`
+	Fee       float64   `gorm:"not null" json:"fee"` // Fraction of each payout that goes to the owner
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// BeforeCreate is a GORM hook that generates a UUID for the pool if one doesn't exist.
+func (p *MiningPool) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == uuid.Nil {
+		p.ID = uuid.New()
+	}
+	return nil
+}
+
+// MiningPoolMember records which pool a player mines for. A player is in at most one pool.
+type MiningPoolMember struct {
+	UserID   uuid.UUID `gorm:"type:text;primary_key" json:"user_id"`
+	PoolID   uuid.UUID `gorm:"type:text;not null;index" json:"pool_id"`
+	JoinedAt time.Time `json:"joined_at"`
+}
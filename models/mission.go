@@ -16,6 +16,7 @@ type MissionObjective struct {
 	TargetType   string `json:"target_server_type,omitempty"`  // Server type to target (any server of this type)
 	TargetServer string `json:"target_server,omitempty"`       // Specific server IP to target (takes precedence over TargetType)
 	Hint         string `json:"hint,omitempty"`                // Tutorial-like hint explaining how to complete this objective
+	Since        *time.Time `json:"since,omitempty"`          // Only actions from this time on count (event missions)
 }
 
 // ToolUpgradeReward represents a free upgrade granted as a mission reward.
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// NotificationKind is what a notification is about.
+type NotificationKind string
+
+const (
+	NotificationMention     NotificationKind = "mention"     // Someone @mentioned the user in chat, or invited them to a room
+	NotificationMining      NotificationKind = "mining"      // Mining paid out; unread payouts add up in one notification
+	NotificationAdmin       NotificationKind = "admin"       // A sysadmin removed the user's backdoor, killed their miner or session
+	NotificationMission     NotificationKind = "mission"     // A mission was unlocked
+	NotificationAchievement NotificationKind = "achievement" // An achievement was earned
+	NotificationIntrusion   NotificationKind = "intrusion"   // Someone broke into a server where the user has a foothold
+)
+
+// Notification is something that happened to a player while they were busy or offline. It's
+// kept until they clear it, and shown in the shell's status line until they've read it.
+type Notification struct {
+	ID        uuid.UUID        `gorm:"type:text;primary_key" json:"id"`
+	UserID    uuid.UUID        `gorm:"type:text;not null;index" json:"user_id"`
+	Kind      NotificationKind `gorm:"not null" json:"kind"`
+	Message   string           `gorm:"not null" json:"message"`
+	Amount    float64          `gorm:"default:0" json:"amount,omitempty"` // Mining: crypto paid out while unread
+	Read      bool             `gorm:"not null;default:false;index" json:"read"`
+	CreatedAt time.Time        `json:"created_at"`
+	UpdatedAt time.Time        `json:"updated_at"` // When it last changed, such as another payout added up
+}
+
+// BeforeCreate is a GORM hook that generates a UUID for the notification if one doesn't exist.
+func (n *Notification) BeforeCreate(tx *gorm.DB) error {
+	if n.ID == uuid.Nil {
+		n.ID = uuid.New()
+	}
+	return nil
+}
@@ -20,6 +20,14 @@ type ServerWallet struct {
 	Data   float64 `json:"data"`
 }
 
+// HoneypotConfig marks a server as a honeypot: a trap that looks vulnerable but traces
+// and fines whoever exploits it.
+type HoneypotConfig struct {
+	Level          int     `json:"level"`           // honeypot_detection level log_analyzer needs to spot it
+	ConfiscateRate float64 `json:"confiscate_rate"` // Fraction of the attacker's crypto seized
+	TraceBack      bool    `json:"trace_back"`      // Whether the attacker is flagged for trace-back
+}
+
 // Vulnerability represents a service vulnerability type and level.
 type Vulnerability struct {
 	Type  string `json:"type"`
@@ -36,6 +44,9 @@ type Service struct {
 	Vulnerabilities   []Vulnerability `json:"vulnerabilities"`
 	GrantsShellAccess bool            `json:"grants_shell_access"` // Whether exploiting this service grants shell access
 	RequiresAuth      *bool           `json:"requires_auth,omitempty"` // If false, no credentials needed (e.g., your own PC)
+	Banner            string          `json:"banner,omitempty"`        // Greeting shown on connect; generated from the service version if empty
+	Anonymous         bool            `json:"anonymous,omitempty"`     // FTP only: allows anonymous login without credentials
+	PatchedAt         *time.Time      `json:"patched_at,omitempty"`    // Set while the server's admin has patched the service's vulnerabilities
 }
 
 // DefaultShellAccessServices returns service names that grant shell access by default.
@@ -93,6 +104,7 @@ type Role struct {
 	CanSudo     bool     `json:"can_sudo"`      // Whether this role can use sudo
 	SudoNoPass  bool     `json:"sudo_no_pass"`  // Whether sudo requires password
 	Groups      []string `json:"groups"`        // Groups this role belongs to
+	Rotations   int      `json:"rotations,omitempty"` // How many times the server's admin has changed the password
 }
 
 // GetRoleType returns the role type, defaulting based on role name if not set.
@@ -135,6 +147,23 @@ func (r *Role) GetHomeDir() string {
 	return "/home/" + r.Role
 }
 
+// GetGroups returns the groups this role belongs to, defaulting based on role type if not set.
+func (r *Role) GetGroups() []string {
+	if len(r.Groups) > 0 {
+		return r.Groups
+	}
+	switch r.GetRoleType() {
+	case RoleTypeRoot:
+		return []string{"root"}
+	case RoleTypeAdmin:
+		return []string{"users", "sudo", "adm"}
+	case RoleTypeGuest:
+		return nil
+	default:
+		return []string{"users"}
+	}
+}
+
 // GetPromptChar returns the shell prompt character (# for root, $ for others).
 func (r *Role) GetPromptChar() string {
 	if r.IsRoot() {
@@ -149,6 +178,7 @@ type Server struct {
 	IP                   string                 `gorm:"uniqueIndex;not null" json:"ip"`
 	LocalIP              string                 `gorm:"not null" json:"local_ip"`
 	SecurityLevel        int                    `gorm:"default:100" json:"security_level"`
+	SecurityBoost        int                    `gorm:"default:0" json:"security_boost,omitempty"` // Security levels a running crackdown added, taken off when it ends
 	Resources            ServerResources        `gorm:"type:text;serializer:json" json:"resources"`
 	Wallet               ServerWallet           `gorm:"type:text;serializer:json" json:"wallet"`
 	Tools                []string               `gorm:"type:text;serializer:json" json:"tools"`
@@ -158,6 +188,8 @@ type Server struct {
 	LocalVulnerabilities []LocalVulnerability   `gorm:"type:text;serializer:json" json:"local_vulnerabilities"` // Privilege escalation vulns
 	FileSystem           map[string]interface{} `gorm:"type:text;serializer:json" json:"file_system"`
 	LocalNetwork         map[string]interface{} `gorm:"type:text;serializer:json" json:"local_network"`
+	SharedWorld          bool                   `gorm:"default:false" json:"shared_world"` // Filesystem changes are seen by every player instead of per-player overlays
+	Honeypot             *HoneypotConfig        `gorm:"type:text;serializer:json" json:"honeypot,omitempty"` // Set if the server is a trap
 	CreatedAt            time.Time              `json:"created_at"`
 	UpdatedAt            time.Time              `json:"updated_at"`
 }
@@ -186,6 +218,11 @@ func (s *Server) HasLocalVulnerabilities() bool {
 	return len(s.LocalVulnerabilities) > 0
 }
 
+// IsHoneypot returns true if the server is a honeypot.
+func (s *Server) IsHoneypot() bool {
+	return s.Honeypot != nil
+}
+
 // BeforeCreate is a GORM hook that generates a UUID for the server if one doesn't exist.
 func (s *Server) BeforeCreate(tx *gorm.DB) error {
 	if s.ID == uuid.Nil {
@@ -194,3 +231,23 @@ func (s *Server) BeforeCreate(tx *gorm.DB) error {
 	return nil
 }
 
+
+// ServerOverlay is one player's copy-on-write layer over a server's base filesystem.
+// FileSystem holds only the player's changes, with whiteout entries for deleted files.
+// Version is the filesystem.ChangesVersion format FileSystem was written in.
+type ServerOverlay struct {
+	ID         uuid.UUID              `gorm:"type:text;primary_key" json:"id"`
+	UserID     uuid.UUID              `gorm:"type:text;not null;uniqueIndex:idx_server_overlay_user_server" json:"user_id"`
+	ServerID   uuid.UUID              `gorm:"type:text;not null;uniqueIndex:idx_server_overlay_user_server" json:"server_id"`
+	FileSystem map[string]interface{} `gorm:"type:text;serializer:json" json:"file_system"`
+	Version    int                    `gorm:"not null;default:1" json:"version"`
+	UpdatedAt  time.Time              `json:"updated_at"`
+}
+
+// BeforeCreate is a GORM hook that generates a UUID for the overlay if one doesn't exist.
+func (o *ServerOverlay) BeforeCreate(tx *gorm.DB) error {
+	if o.ID == uuid.Nil {
+		o.ID = uuid.New()
+	}
+	return nil
+}
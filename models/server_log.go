@@ -24,6 +24,7 @@ const (
 	LogTypeScan          LogType = "scan"
 	LogTypeAuth          LogType = "auth"
 	LogTypeSystem        LogType = "system"
+	LogTypeRoute         LogType = "route" // Traffic forwarded through the server to another host
 )
 
 // ServerLog represents a log entry for a server.
@@ -115,6 +116,8 @@ func (l *ServerLog) FormatForSystemLog() string {
 		return timestamp + " net[" + l.ID.String()[:8] + "]: Port scan detected from " + l.SourceIP
 	case LogTypeSystem:
 		return timestamp + " system: " + l.Message
+	case LogTypeRoute:
+		return timestamp + " net[" + l.ID.String()[:8] + "]: " + l.Message + " from " + l.SourceIP + " to " + l.Details
 	default:
 		return timestamp + " " + string(l.LogType) + ": " + l.Message
 	}
@@ -15,24 +15,37 @@ const (
 	ShopTypeTools     ShopType = "tools"     // Purchasable tools
 	ShopTypeResources ShopType = "resources" // CPU/RAM/Bandwidth upgrades
 	ShopTypeMixed     ShopType = "mixed"     // Combination of above
+	ShopTypeMarket    ShopType = "market"    // Player-to-player exchange
 )
 
 // Shop represents a shop on a server where users can purchase items.
 type Shop struct {
-	ID              uuid.UUID `gorm:"type:text;primary_key" json:"id"`
-	ServerIP        string    `gorm:"uniqueIndex;not null" json:"server_ip"`
-	ShopType        ShopType  `gorm:"not null" json:"shop_type"`
-	Name            string    `gorm:"not null" json:"name"`
-	Description     string    `json:"description"`
-	RequiredMission string    `json:"required_mission,omitempty"` // Mission ID that must be completed to access this shop
-	RequiredLevel   int       `json:"required_level,omitempty"`   // Minimum player level to access this shop
-	CreatedAt       time.Time `json:"created_at"`
-	UpdatedAt       time.Time `json:"updated_at"`
+	ID              uuid.UUID   `gorm:"type:text;primary_key" json:"id"`
+	ServerIP        string      `gorm:"uniqueIndex;not null" json:"server_ip"`
+	ShopType        ShopType    `gorm:"not null" json:"shop_type"`
+	Name            string      `gorm:"not null" json:"name"`
+	Description     string      `json:"description"`
+	RequiredMission string      `json:"required_mission,omitempty"`               // Mission ID that must be completed to access this shop
+	RequiredLevel   int         `json:"required_level,omitempty"`                 // Minimum player level to access this shop
+	Economy         ShopEconomy `gorm:"type:text;serializer:json" json:"economy"` // How stock and prices change over time
+	CreatedAt       time.Time   `json:"created_at"`
+	UpdatedAt       time.Time   `json:"updated_at"`
 
 	// Relationships
 	Items []ShopItem `gorm:"foreignKey:ShopID" json:"items,omitempty"`
 }
 
+// ShopEconomy configures how a shop's stock and prices change over time. The zero value keeps
+// a shop static: fixed prices, no restocking and its whole inventory on offer.
+type ShopEconomy struct {
+	RestockMinutes      int     `json:"restock_minutes,omitempty"`       // How often items below their max stock restock
+	DemandWindowMinutes int     `json:"demand_window_minutes,omitempty"` // How far back purchases count towards demand
+	PriceStep           float64 `json:"price_step,omitempty"`            // Price increase per recent purchase, e.g. 0.05 for +5%
+	MaxMarkup           float64 `json:"max_markup,omitempty"`            // Cap on the demand increase, e.g. 1 to at most double; 0 is no cap
+	RotationSize        int     `json:"rotation_size,omitempty"`         // Items on offer at a time; 0 offers everything
+	RotationMinutes     int     `json:"rotation_minutes,omitempty"`      // How often the items on offer change
+}
+
 // BeforeCreate is a GORM hook that generates a UUID for the shop if one doesn't exist.
 func (s *Shop) BeforeCreate(tx *gorm.DB) error {
 	if s.ID == uuid.Nil {
@@ -48,25 +61,37 @@ const (
 	ItemTypeTool         ItemType = "tool"          // Hacking tool
 	ItemTypeUpgradeToken ItemType = "upgrade_token" // Tool upgrade token (exploit, cpu, ram, bandwidth)
 	ItemTypeResource     ItemType = "resource"      // Resource upgrade (CPU/RAM/Bandwidth for user)
+	ItemTypeLoot         ItemType = "loot"          // File taken from a server, traded between players
+	ItemTypeData         ItemType = "data"          // Data currency bought on the market
 )
 
 // ShopItem represents an item for sale in a shop.
 type ShopItem struct {
-	ID          uuid.UUID `gorm:"type:text;primary_key" json:"id"`
-	ShopID      uuid.UUID `gorm:"not null" json:"shop_id"`
-	ItemType    ItemType  `gorm:"not null" json:"item_type"`
-	Name        string    `gorm:"not null" json:"name"`
-	Description string    `json:"description"`
-	PriceCrypto float64   `gorm:"default:0" json:"price_crypto"`
-	PriceData   float64   `gorm:"default:0" json:"price_data"`
-	Stock       int       `gorm:"default:-1" json:"stock"` // -1 means unlimited
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID            uuid.UUID  `gorm:"type:text;primary_key" json:"id"`
+	ShopID        uuid.UUID  `gorm:"not null" json:"shop_id"`
+	ItemType      ItemType   `gorm:"not null" json:"item_type"`
+	Name          string     `gorm:"not null" json:"name"`
+	Description   string     `json:"description"`
+	PriceCrypto   float64    `gorm:"default:0" json:"price_crypto"`
+	PriceData     float64    `gorm:"default:0" json:"price_data"`
+	Stock         int        `gorm:"default:-1" json:"stock"`                   // -1 means unlimited
+	MaxStock      int        `gorm:"default:0" json:"max_stock,omitempty"`      // Stock refills to this each restock; 0 never restocks
+	RestockedAt   time.Time  `json:"restocked_at"`                              // When the restock timer last started
+	PurchaseLimit int        `gorm:"default:0" json:"purchase_limit,omitempty"` // Purchases allowed per player; 0 is unlimited
+	SaleDiscount  float64    `gorm:"default:0" json:"sale_discount,omitempty"`  // Fraction off while on sale, e.g. 0.25
+	SaleEndsAt    *time.Time `json:"sale_ends_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
 
 	// Relationships
 	Shop Shop `gorm:"foreignKey:ShopID" json:"shop,omitempty"`
 }
 
+// OnSale reports whether the item is discounted at the given time.
+func (si *ShopItem) OnSale(now time.Time) bool {
+	return si.SaleDiscount > 0 && si.SaleEndsAt != nil && now.Before(*si.SaleEndsAt)
+}
+
 // BeforeCreate is a GORM hook that generates a UUID for the shop item if one doesn't exist.
 func (si *ShopItem) BeforeCreate(tx *gorm.DB) error {
 	if si.ID == uuid.Nil {
@@ -100,3 +125,21 @@ func (up *UserPurchase) BeforeCreate(tx *gorm.DB) error {
 	return nil
 }
 
+
+// UpgradeToken is an upgrade token a user holds until they apply it with patch or trade it away.
+type UpgradeToken struct {
+	ID          uuid.UUID  `gorm:"type:text;primary_key" json:"id"`
+	UserID      uuid.UUID  `gorm:"type:text;not null;index" json:"user_id"`
+	Name        string     `gorm:"not null" json:"name"`
+	UpgradeType string     `gorm:"not null" json:"upgrade_type"`            // exploit, cpu, ram, bandwidth or full
+	EscrowID    *uuid.UUID `gorm:"type:text;index" json:"escrow_id,omitempty"` // Trade holding the token, if any
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// BeforeCreate is a GORM hook that generates a UUID for the upgrade token if one doesn't exist.
+func (t *UpgradeToken) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SysAdmin is the simulated administrator looking after a server. It patrols on the
+// background scheduler, and how much it does scales with the server's security level.
+type SysAdmin struct {
+	ServerIP     string    `gorm:"primary_key" json:"server_ip"`
+	Name         string    `gorm:"not null" json:"name"`
+	Alert        float64   `gorm:"default:0" json:"alert"` // Raised by suspicious auth.log entries, calms down over time
+	LastPatrolAt time.Time `gorm:"not null" json:"last_patrol_at"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// SessionKick records a sysadmin killing a player's session on a server. The player is
+// dropped from the server on their next command.
+type SessionKick struct {
+	ID        uuid.UUID `gorm:"type:text;primary_key" json:"id"`
+	UserID    uuid.UUID `gorm:"type:text;not null;index" json:"user_id"`
+	ServerIP  string    `gorm:"not null;index" json:"server_ip"`
+	Admin     string    `json:"admin"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
+// BeforeCreate is a GORM hook that generates a UUID for the kick if one doesn't exist.
+func (k *SessionKick) BeforeCreate(tx *gorm.DB) error {
+	if k.ID == uuid.Nil {
+		k.ID = uuid.New()
+	}
+	return nil
+}
@@ -33,6 +33,7 @@ type TrackedAction struct {
 	TargetServer string     `json:"target_server,omitempty"` // Server IP or path
 	ServiceName  string     `json:"service_name,omitempty"`
 	Details      string     `gorm:"type:text" json:"details,omitempty"` // JSON for additional context
+	Amount       int64      `gorm:"default:0" json:"amount,omitempty"`  // Size of the action, e.g. bytes of data extracted
 	MissionID    string     `json:"mission_id,omitempty"`               // Active mission at time of action
 	CreatedAt    time.Time  `gorm:"index" json:"created_at"`
 }
@@ -35,6 +35,10 @@ type User struct {
 	Resources       Resources  `gorm:"type:text;serializer:json" json:"resources"`
 	Wallet          Wallet     `gorm:"type:text;serializer:json" json:"wallet"`
 	FileSystem      map[string]interface{} `gorm:"type:text;serializer:json" json:"file_system"` // User's filesystem changes
+	TOTPSecret      string     `gorm:"default:''" json:"-"`                   // Base32 TOTP secret (set during enrollment)
+	TOTPEnabled     bool       `gorm:"default:false" json:"totp_enabled"`     // Whether a TOTP code is required at login
+	TraceFlags      int        `gorm:"default:0" json:"trace_flags"`          // Times a honeypot has flagged the user for trace-back
+	IsAdmin         bool       `gorm:"default:false" json:"is_admin"`         // Granted to the account by an operator (cmd/admin), never by username
 	CreatedAt       time.Time  `json:"created_at"`
 	UpdatedAt       time.Time  `json:"updated_at"`
 	
@@ -4,11 +4,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
+	"sync"
 	"terminal-sh/database"
 	"terminal-sh/models"
 	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 // AchievementService handles achievement-related operations
@@ -16,6 +19,13 @@ type AchievementService struct {
 	db          *database.Database
 	achievements []models.AchievementDefinition
 	dataPath    string
+	actionTracker *ActionTracker
+	notifications *NotificationService
+
+	mu       sync.Mutex
+	unlocked map[uuid.UUID][]models.AchievementDefinition // Unlocked by Evaluate and not yet announced
+	have     map[uuid.UUID]map[string]time.Time           // When each user unlocked their achievements, by ID, once loaded
+	streaks  map[uuid.UUID]map[string]time.Time           // Day each user's unmet streak achievements were last checked, by ID
 }
 
 // NewAchievementService creates a new AchievementService and loads achievements from JSON
@@ -23,6 +33,10 @@ func NewAchievementService(db *database.Database, dataPath string) (*Achievement
 	service := &AchievementService{
 		db:       db,
 		dataPath: dataPath,
+		notifications: NewNotificationService(db),
+		unlocked: make(map[uuid.UUID][]models.AchievementDefinition),
+		have:     make(map[uuid.UUID]map[string]time.Time),
+		streaks:  make(map[uuid.UUID]map[string]time.Time),
 	}
 	
 	if err := service.LoadAchievements(); err != nil {
@@ -62,12 +76,19 @@ func (s *AchievementService) LoadAchievements() error {
 
 // UnlockAchievement unlocks an achievement for a user
 func (s *AchievementService) UnlockAchievement(userID uuid.UUID, achievementName string) error {
+	_, err := s.unlock(userID, achievementName)
+	return err
+}
+
+// unlock unlocks an achievement for a user, reporting whether they didn't have it yet
+func (s *AchievementService) unlock(userID uuid.UUID, achievementName string) (bool, error) {
 	// Check if achievement already unlocked
 	var existing models.UserAchievement
 	err := s.db.Where("user_id = ? AND achievement_name = ?", userID, achievementName).First(&existing).Error
 	if err == nil {
 		// Already unlocked
-		return nil
+		s.remember(userID, existing)
+		return false, nil
 	}
 	
 	// Create new achievement
@@ -78,10 +99,11 @@ func (s *AchievementService) UnlockAchievement(userID uuid.UUID, achievementName
 	}
 	
 	if err := s.db.Create(achievement).Error; err != nil {
-		return fmt.Errorf("failed to unlock achievement: %w", err)
+		return false, fmt.Errorf("failed to unlock achievement: %w", err)
 	}
 	
-	return nil
+	s.remember(userID, *achievement)
+	return true, nil
 }
 
 // GetUserAchievements retrieves all achievements for a user
@@ -98,16 +120,340 @@ func (s *AchievementService) GetAllAchievements() []models.AchievementDefinition
 	return s.achievements
 }
 
-// GetAchievementByName returns an achievement definition by name
+// GetAchievementByName returns an achievement definition by name or ID
+// (missions and criteria unlock achievements by ID)
 func (s *AchievementService) GetAchievementByName(name string) *models.AchievementDefinition {
 	for _, ach := range s.achievements {
-		if ach.Name == name {
+		if ach.Name == name || ach.ID == name {
 			return &ach
 		}
 	}
 	return nil
 }
 
+// SetActionTracker sets the action tracker whose records achievement criteria are counted from
+func (s *AchievementService) SetActionTracker(actionTracker *ActionTracker) {
+	s.actionTracker = actionTracker
+}
+
+// AchievementProgress is how far a user is towards an achievement
+type AchievementProgress struct {
+	Definition models.AchievementDefinition
+	Current    float64
+	Target     float64
+	Unlocked   bool
+	UnlockedAt time.Time
+}
+
+// Fraction returns the progress as a fraction from 0 to 1
+func (p AchievementProgress) Fraction() float64 {
+	if p.Unlocked || p.Target <= 0 {
+		return 1
+	}
+	if p.Current >= p.Target {
+		return 1
+	}
+	return p.Current / p.Target
+}
+
+// counterKey returns the key of the counter kept for a criteria, or false for criteria that
+// aren't counted from actions (wallet balances and streaks).
+func counterKey(criteria *models.AchievementCriteria) (string, bool) {
+	switch criteria.Type {
+	case models.CriteriaActionCount, models.CriteriaActionSum, models.CriteriaDistinctServers, models.CriteriaToolUsage:
+		return fmt.Sprintf("%s:%s:%s", criteria.Type, criteria.Action, criteria.Tool), true
+	}
+	return "", false
+}
+
+// counts reports whether an action counts towards a criteria
+func counts(criteria *models.AchievementCriteria, action *models.TrackedAction) bool {
+	if criteria.Type == models.CriteriaToolUsage {
+		return action.ActionType == models.ActionToolUse && action.ToolName == criteria.Tool
+	}
+	if criteria.Type == models.CriteriaDistinctServers && action.TargetServer == "" {
+		return false
+	}
+	return (criteria.Action == "" || criteria.Action == action.ActionType) &&
+		(criteria.Tool == "" || criteria.Tool == action.ToolName)
+}
+
+// criteriaActions returns the user's actions that count towards a criteria, within tx
+func criteriaActions(tx *gorm.DB, userID uuid.UUID, criteria *models.AchievementCriteria) *gorm.DB {
+	query := tx.Model(&models.TrackedAction{}).Where("user_id = ?", userID)
+	if criteria.Type == models.CriteriaToolUsage {
+		return query.Where("action_type = ? AND tool_name = ?", models.ActionToolUse, criteria.Tool)
+	}
+	if criteria.Action != "" {
+		query = query.Where("action_type = ?", criteria.Action)
+	}
+	if criteria.Tool != "" {
+		query = query.Where("tool_name = ?", criteria.Tool)
+	}
+	if criteria.Type == models.CriteriaDistinctServers {
+		query = query.Where("target_server <> ''")
+	}
+	return query
+}
+
+// countActions works out a counted criteria's value from the user's actions, within tx
+func countActions(tx *gorm.DB, userID uuid.UUID, criteria *models.AchievementCriteria) float64 {
+	query := criteriaActions(tx, userID, criteria)
+	switch criteria.Type {
+	case models.CriteriaActionSum:
+		var total int64
+		query.Select("COALESCE(SUM(amount), 0)").Scan(&total)
+		return float64(total)
+	case models.CriteriaDistinctServers:
+		query = query.Distinct("target_server")
+	}
+	var count int64
+	query.Count(&count)
+	return float64(count)
+}
+
+// count moves the user's counters for every criteria a tracked action counts towards, within tx.
+// It runs in the transaction that records the action, so no action is counted twice or missed.
+// A counter starts from the actions recorded so far the first time it is moved.
+func (s *AchievementService) count(tx *gorm.DB, action *models.TrackedAction) error {
+	s.mu.Lock()
+	have := s.have[action.UserID] // Only what's loaded: tx may hold the database
+	s.mu.Unlock()
+	moved := make(map[string]bool)
+	for _, ach := range s.achievements {
+		if ach.Criteria == nil || !counts(ach.Criteria, action) {
+			continue
+		}
+		key, ok := counterKey(ach.Criteria)
+		if _, unlocked := have[ach.ID]; !ok || unlocked || moved[key] {
+			continue
+		}
+		moved[key] = true
+
+		delta := 1.0
+		switch ach.Criteria.Type {
+		case models.CriteriaActionSum:
+			delta = float64(action.Amount)
+		case models.CriteriaDistinctServers:
+			var count int64
+			criteriaActions(tx, action.UserID, ach.Criteria).Where("target_server = ?", action.TargetServer).Count(&count)
+			if count > 1 {
+				delta = 0 // Been there already
+			}
+		}
+
+		result := tx.Model(&models.AchievementCounter{}).Where("user_id = ? AND criteria = ?", action.UserID, key).
+			Updates(map[string]interface{}{"value": gorm.Expr("value + ?", delta), "updated_at": time.Now()})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			counter := &models.AchievementCounter{UserID: action.UserID, Criteria: key, Value: countActions(tx, action.UserID, ach.Criteria)}
+			if err := tx.Create(counter).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// criteriaValues reads a user's values for achievement criteria, loading their counters and
+// wallet at most once.
+type criteriaValues struct {
+	s        *AchievementService
+	userID   uuid.UUID
+	counters map[string]float64
+	wallet   *models.Wallet
+}
+
+// get returns the user's current value for a criteria
+func (v *criteriaValues) get(criteria *models.AchievementCriteria) float64 {
+	if key, ok := counterKey(criteria); ok {
+		if v.counters == nil {
+			var counters []models.AchievementCounter
+			v.s.db.Where("user_id = ?", v.userID).Find(&counters)
+			v.counters = make(map[string]float64, len(counters))
+			for _, counter := range counters {
+				v.counters[counter.Criteria] = counter.Value
+			}
+		}
+		if value, ok := v.counters[key]; ok {
+			return value
+		}
+		// Not counted yet: nothing has moved it since the user's actions were first tracked
+		return countActions(v.s.db.DB, v.userID, criteria)
+	}
+
+	switch criteria.Type {
+	case models.CriteriaWallet:
+		if v.wallet == nil {
+			var user models.User
+			if err := v.s.db.Select("wallet").First(&user, "id = ?", v.userID).Error; err != nil {
+				return 0
+			}
+			v.wallet = &user.Wallet
+		}
+		if criteria.Currency == "data" {
+			return v.wallet.Data
+		}
+		return v.wallet.Crypto
+	case models.CriteriaStreak:
+		if v.s.actionTracker == nil {
+			return 0
+		}
+		return float64(v.s.actionTracker.GetActionStreak(v.userID, criteria.Action, criteria.Tool, int(criteria.Target)))
+	}
+	return 0
+}
+
+// watches reports whether an action of a type can move a criteria forward. Wallet criteria are
+// checked on every action, as the balance changes outside of tracked actions.
+func watches(criteria *models.AchievementCriteria, actionType models.ActionType) bool {
+	switch criteria.Type {
+	case models.CriteriaWallet:
+		return true
+	case models.CriteriaToolUsage:
+		return actionType == models.ActionToolUse
+	}
+	return criteria.Action == "" || criteria.Action == actionType
+}
+
+// Evaluate unlocks every achievement whose criteria the user now meets, checking only those an
+// action of actionType can affect (all of them if empty). Returns the newly unlocked achievements,
+// which are also kept for TakeUnlocked.
+func (s *AchievementService) Evaluate(userID uuid.UUID, actionType models.ActionType) []models.AchievementDefinition {
+	have := s.unlockedAt(userID)
+	values := &criteriaValues{s: s, userID: userID}
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	var newlyUnlocked []models.AchievementDefinition
+	for _, ach := range s.achievements {
+		if ach.Criteria == nil || (actionType != "" && !watches(ach.Criteria, actionType)) {
+			continue
+		}
+		if _, ok := have[ach.ID]; ok {
+			continue
+		}
+		// A streak only grows with the first action of the day
+		streak := ach.Criteria.Type == models.CriteriaStreak
+		if streak && actionType != "" && s.streakChecked(userID, ach.ID).Equal(today) {
+			continue
+		}
+		if values.get(ach.Criteria) < ach.Criteria.Target {
+			if streak {
+				s.setStreakChecked(userID, ach.ID, today)
+			}
+			continue
+		}
+		if unlocked, err := s.unlock(userID, ach.ID); err == nil && unlocked {
+			newlyUnlocked = append(newlyUnlocked, ach)
+			s.notifications.Notify(userID, models.NotificationAchievement, fmt.Sprintf("Achievement earned: %s", ach.Name))
+		}
+	}
+
+	if len(newlyUnlocked) > 0 {
+		s.mu.Lock()
+		s.unlocked[userID] = append(s.unlocked[userID], newlyUnlocked...)
+		s.mu.Unlock()
+	}
+	return newlyUnlocked
+}
+
+// TakeUnlocked returns the achievements Evaluate has unlocked for a user since the last call
+func (s *AchievementService) TakeUnlocked(userID uuid.UUID) []models.AchievementDefinition {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	unlocked := s.unlocked[userID]
+	delete(s.unlocked, userID)
+	return unlocked
+}
+
+// unlockedAt returns when the user unlocked each of their achievements, by ID. They are loaded
+// once and kept up to date as achievements are unlocked.
+func (s *AchievementService) unlockedAt(userID uuid.UUID) map[string]time.Time {
+	s.mu.Lock()
+	have, ok := s.have[userID]
+	s.mu.Unlock()
+	if ok {
+		return have
+	}
+
+	achievements, _ := s.GetUserAchievements(userID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	have = make(map[string]time.Time, len(achievements))
+	for _, ach := range achievements {
+		have[s.achievementID(ach.AchievementName)] = ach.UnlockedAt
+	}
+	for id, at := range s.have[userID] {
+		have[id] = at // Unlocked while they were loading
+	}
+	s.have[userID] = have
+	return have
+}
+
+// remember adds an unlocked achievement to the user's loaded achievements
+func (s *AchievementService) remember(userID uuid.UUID, achievement models.UserAchievement) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	have, ok := s.have[userID]
+	if !ok {
+		return // Loaded with it when first needed
+	}
+	updated := make(map[string]time.Time, len(have)+1)
+	for id, at := range have {
+		updated[id] = at
+	}
+	updated[s.achievementID(achievement.AchievementName)] = achievement.UnlockedAt
+	s.have[userID] = updated
+}
+
+// achievementID returns the ID of an achievement stored by name or ID
+func (s *AchievementService) achievementID(name string) string {
+	if def := s.GetAchievementByName(name); def != nil {
+		return def.ID
+	}
+	return name
+}
+
+// streakChecked returns the day a user's unmet streak achievement was last checked
+func (s *AchievementService) streakChecked(userID uuid.UUID, achievementID string) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.streaks[userID][achievementID]
+}
+
+// setStreakChecked records that a user's streak achievement was checked, and not met, on a day
+func (s *AchievementService) setStreakChecked(userID uuid.UUID, achievementID string, day time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.streaks[userID] == nil {
+		s.streaks[userID] = make(map[string]time.Time)
+	}
+	s.streaks[userID][achievementID] = day
+}
+
+// GetProgress returns the user's progress towards every achievement, in definition order
+func (s *AchievementService) GetProgress(userID uuid.UUID) []AchievementProgress {
+	have := s.unlockedAt(userID)
+	values := &criteriaValues{s: s, userID: userID}
+	progress := make([]AchievementProgress, 0, len(s.achievements))
+	for _, ach := range s.achievements {
+		p := AchievementProgress{Definition: ach, Target: 1}
+		p.UnlockedAt, p.Unlocked = have[ach.ID]
+		if ach.Criteria != nil {
+			p.Target = ach.Criteria.Target
+			if !p.Unlocked {
+				p.Current = values.get(ach.Criteria)
+			}
+		}
+		if p.Unlocked {
+			p.Current = p.Target
+		}
+		progress = append(progress, p)
+	}
+	return progress
+}
+
 // createDefaultAchievements creates a default achievements file
 func (s *AchievementService) createDefaultAchievements(path string) error {
 	defaultAchievements := models.AchievementData{
@@ -151,7 +497,7 @@ func (s *AchievementService) createDefaultAchievements(path string) error {
 	}
 	
 	// Ensure directory exists
-	if err := os.MkdirAll("data/seed", 0755); err != nil {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
 		return err
 	}
 	
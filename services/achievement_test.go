@@ -0,0 +1,128 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"terminal-sh/models"
+)
+
+func TestCriteriaUnlockAchievementsAsActionsAreTracked(t *testing.T) {
+	db := newTestDatabase(t)
+	dataPath := filepath.Join(t.TempDir(), "achievements.json")
+	if err := os.WriteFile(dataPath, []byte(`{"achievements": [
+		{"id": "phisher", "name": "Phisher", "criteria": {"type": "distinct_servers", "action": "tool_use", "tool": "phishing_kit", "target": 2}},
+		{"id": "hoarder", "name": "Hoarder", "hidden": true, "criteria": {"type": "action_sum", "action": "data_extract", "target": 1000}},
+		{"id": "regular", "name": "Regular", "criteria": {"type": "streak", "target": 1}},
+		{"id": "story", "name": "Story"}
+	]}`), 0644); err != nil {
+		t.Fatalf("failed to write achievements: %v", err)
+	}
+	achievementService, err := NewAchievementService(db, dataPath)
+	if err != nil {
+		t.Fatalf("failed to load achievements: %v", err)
+	}
+	tracker := NewActionTracker(db)
+	tracker.SetAchievementService(achievementService)
+	achievementService.SetActionTracker(tracker)
+
+	user, err := NewUserService(db, "test-secret").Register("alice", "correct-horse")
+	if err != nil {
+		t.Fatalf("failed to register user: %v", err)
+	}
+
+	tracker.TrackToolUse(user.ID, "phishing_kit", "10.0.0.1", "")
+	tracker.TrackToolUse(user.ID, "phishing_kit", "10.0.0.1", "")
+	if unlocked := achievementService.TakeUnlocked(user.ID); len(unlocked) != 1 || unlocked[0].ID != "regular" {
+		t.Fatalf("expected only the streak to unlock on the same server twice, got %+v", unlocked)
+	}
+
+	tracker.TrackToolUse(user.ID, "phishing_kit", "10.0.0.2", "")
+	tracker.TrackDataExtraction(user.ID, "download", "10.0.0.2", 600)
+	if unlocked := achievementService.TakeUnlocked(user.ID); len(unlocked) != 1 || unlocked[0].ID != "phisher" {
+		t.Fatalf("expected phisher after a second server, got %+v", unlocked)
+	}
+
+	var hoarder AchievementProgress
+	for _, p := range achievementService.GetProgress(user.ID) {
+		if p.Definition.ID == "hoarder" {
+			hoarder = p
+		}
+	}
+	if hoarder.Unlocked || hoarder.Current != 600 || hoarder.Fraction() != 0.6 {
+		t.Fatalf("expected hoarder at 600/1000, got %+v", hoarder)
+	}
+
+	tracker.TrackDataExtraction(user.ID, "scp", "10.0.0.2", 400)
+	if unlocked := achievementService.TakeUnlocked(user.ID); len(unlocked) != 1 || unlocked[0].ID != "hoarder" {
+		t.Fatalf("expected hoarder once 1000 bytes were taken, got %+v", unlocked)
+	}
+	if achievementService.Evaluate(user.ID, ""); len(achievementService.TakeUnlocked(user.ID)) != 0 {
+		t.Fatal("expected achievements without criteria to be left to missions")
+	}
+
+	var count int64
+	db.Model(&models.UserAchievement{}).Where("user_id = ?", user.ID).Count(&count)
+	if count != 3 {
+		t.Fatalf("expected 3 unlocked achievements, got %d", count)
+	}
+}
+
+func TestAchievementCountersAreSharedBetweenSessions(t *testing.T) {
+	db := newTestDatabase(t)
+	dataPath := filepath.Join(t.TempDir(), "achievements.json")
+	if err := os.WriteFile(dataPath, []byte(`{"achievements": [
+		{"id": "scanner", "name": "Scanner", "criteria": {"type": "tool_usage", "tool": "nmap", "target": 4}},
+		{"id": "tourist", "name": "Tourist", "criteria": {"type": "distinct_servers", "action": "server_connect", "target": 3}}
+	]}`), 0644); err != nil {
+		t.Fatalf("failed to write achievements: %v", err)
+	}
+	user, err := NewUserService(db, "test-secret").Register("alice", "correct-horse")
+	if err != nil {
+		t.Fatalf("failed to register user: %v", err)
+	}
+
+	// Actions tracked before any counter existed still count
+	NewActionTracker(db).TrackToolUse(user.ID, "nmap", "10.0.0.1", "")
+
+	// Each session has its own services
+	session := func() (*AchievementService, *ActionTracker) {
+		achievementService, err := NewAchievementService(db, dataPath)
+		if err != nil {
+			t.Fatalf("failed to load achievements: %v", err)
+		}
+		tracker := NewActionTracker(db)
+		tracker.SetAchievementService(achievementService)
+		achievementService.SetActionTracker(tracker)
+		return achievementService, tracker
+	}
+	firstAchievements, first := session()
+	secondAchievements, second := session()
+
+	first.TrackToolUse(user.ID, "nmap", "10.0.0.1", "")
+	second.TrackToolUse(user.ID, "nmap", "10.0.0.2", "")
+	first.TrackServerConnect(user.ID, "10.0.0.1", "ssh")
+	second.TrackServerConnect(user.ID, "10.0.0.1", "ssh")
+	second.TrackServerConnect(user.ID, "10.0.0.2", "ssh")
+
+	var counters []models.AchievementCounter
+	db.Where("user_id = ?", user.ID).Order("criteria ASC").Find(&counters)
+	if len(counters) != 2 || counters[0].Value != 2 || counters[1].Value != 3 {
+		t.Fatalf("expected 2 servers and 3 nmap runs counted, got %+v", counters)
+	}
+
+	// The session that reaches the target announces it, and only that one
+	second.TrackToolUse(user.ID, "nmap", "10.0.0.3", "")
+	if unlocked := secondAchievements.TakeUnlocked(user.ID); len(unlocked) != 1 || unlocked[0].ID != "scanner" {
+		t.Fatalf("expected scanner on the fourth nmap run, got %+v", unlocked)
+	}
+	first.TrackToolUse(user.ID, "nmap", "10.0.0.3", "")
+	if unlocked := firstAchievements.TakeUnlocked(user.ID); len(unlocked) != 0 {
+		t.Fatalf("expected scanner not to be announced again, got %+v", unlocked)
+	}
+	first.TrackServerConnect(user.ID, "10.0.0.3", "ssh")
+	if unlocked := firstAchievements.TakeUnlocked(user.ID); len(unlocked) != 1 || unlocked[0].ID != "tourist" {
+		t.Fatalf("expected tourist on the third server, got %+v", unlocked)
+	}
+}
@@ -10,12 +10,14 @@ import (
 	"terminal-sh/models"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 // ActionTracker provides centralized tracking of player actions
 type ActionTracker struct {
-	db             *database.Database
-	missionService *MissionService
+	db                 *database.Database
+	missionService     *MissionService
+	achievementService *AchievementService
 }
 
 // NewActionTracker creates a new ActionTracker service
@@ -30,6 +32,32 @@ func (t *ActionTracker) SetMissionService(missionService *MissionService) {
 	t.missionService = missionService
 }
 
+// SetAchievementService sets the achievement service, which is checked for unlocks after every action
+func (t *ActionTracker) SetAchievementService(achievementService *AchievementService) {
+	t.achievementService = achievementService
+}
+
+// record saves an action, moving the achievement counters it counts towards, and unlocks any
+// achievement it completes
+func (t *ActionTracker) record(action *models.TrackedAction) error {
+	err := t.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(action).Error; err != nil {
+			return err
+		}
+		if t.achievementService != nil {
+			return t.achievementService.count(tx, action)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if t.achievementService != nil {
+		t.achievementService.Evaluate(action.UserID, action.ActionType)
+	}
+	return nil
+}
+
 // TrackToolUse records a tool being used on a target
 func (t *ActionTracker) TrackToolUse(userID uuid.UUID, toolName, targetServer, serviceName string) error {
 	missionID := t.getActiveMissionID(userID)
@@ -44,7 +72,7 @@ func (t *ActionTracker) TrackToolUse(userID uuid.UUID, toolName, targetServer, s
 		CreatedAt:    time.Now(),
 	}
 
-	return t.db.Create(action).Error
+	return t.record(action)
 }
 
 // TrackServerExploit records a successful server exploit
@@ -61,7 +89,7 @@ func (t *ActionTracker) TrackServerExploit(userID uuid.UUID, toolName, serverPat
 		CreatedAt:    time.Now(),
 	}
 
-	return t.db.Create(action).Error
+	return t.record(action)
 }
 
 // TrackPrivilegeEscalation records a privilege escalation
@@ -78,7 +106,7 @@ func (t *ActionTracker) TrackPrivilegeEscalation(userID uuid.UUID, toolName, ser
 		CreatedAt:    time.Now(),
 	}
 
-	return t.db.Create(action).Error
+	return t.record(action)
 }
 
 // TrackCredentialCrack records a credential being cracked
@@ -95,11 +123,11 @@ func (t *ActionTracker) TrackCredentialCrack(userID uuid.UUID, toolName, serverP
 		CreatedAt:    time.Now(),
 	}
 
-	return t.db.Create(action).Error
+	return t.record(action)
 }
 
-// TrackDataExtraction records data being extracted
-func (t *ActionTracker) TrackDataExtraction(userID uuid.UUID, toolName, serverPath string) error {
+// TrackDataExtraction records bytes of data being extracted
+func (t *ActionTracker) TrackDataExtraction(userID uuid.UUID, toolName, serverPath string, bytes int64) error {
 	missionID := t.getActiveMissionID(userID)
 
 	action := &models.TrackedAction{
@@ -107,11 +135,12 @@ func (t *ActionTracker) TrackDataExtraction(userID uuid.UUID, toolName, serverPa
 		ActionType:   models.ActionDataExtract,
 		ToolName:     toolName,
 		TargetServer: serverPath,
+		Amount:       bytes,
 		MissionID:    missionID,
 		CreatedAt:    time.Now(),
 	}
 
-	return t.db.Create(action).Error
+	return t.record(action)
 }
 
 // TrackBackdoorInstall records a backdoor being installed
@@ -127,7 +156,7 @@ func (t *ActionTracker) TrackBackdoorInstall(userID uuid.UUID, toolName, serverP
 		CreatedAt:    time.Now(),
 	}
 
-	return t.db.Create(action).Error
+	return t.record(action)
 }
 
 // TrackServerConnect records connecting to a server
@@ -143,7 +172,7 @@ func (t *ActionTracker) TrackServerConnect(userID uuid.UUID, serverPath, service
 		CreatedAt:    time.Now(),
 	}
 
-	return t.db.Create(action).Error
+	return t.record(action)
 }
 
 // TrackToolDownload records downloading a tool from a server
@@ -159,7 +188,7 @@ func (t *ActionTracker) TrackToolDownload(userID uuid.UUID, toolName, serverPath
 		CreatedAt:    time.Now(),
 	}
 
-	return t.db.Create(action).Error
+	return t.record(action)
 }
 
 // getActiveMissionID returns the ID of the user's active (in_progress) mission
@@ -251,10 +280,24 @@ func (t *ActionTracker) HasCompletedObjective(userID uuid.UUID, missionID string
 		}
 	}
 
+	if objective.Since != nil {
+		query = query.Where("created_at >= ?", *objective.Since)
+	}
+
 	query.Count(&count)
 	return count > 0
 }
 
+// HasToolUseSince checks if a user has used a tool on a server, by IP or path, since a time
+func (t *ActionTracker) HasToolUseSince(userID uuid.UUID, toolName, targetServer string, since time.Time) bool {
+	var count int64
+	t.db.Model(&models.TrackedAction{}).
+		Where("user_id = ? AND action_type = ? AND tool_name = ? AND created_at >= ?", userID, models.ActionToolUse, toolName, since).
+		Where("target_server = ? OR target_server LIKE ?", targetServer, "%."+targetServer).
+		Count(&count)
+	return count > 0
+}
+
 // GetActionsForMission returns all actions recorded for a specific mission
 func (t *ActionTracker) GetActionsForMission(userID uuid.UUID, missionID string) ([]models.TrackedAction, error) {
 	var actions []models.TrackedAction
@@ -289,6 +332,65 @@ func (t *ActionTracker) GetExploitedServerCount(userID uuid.UUID) int64 {
 	return count
 }
 
+// actionQuery returns the user's actions of a type (any type if empty), narrowed to a tool if given
+func (t *ActionTracker) actionQuery(userID uuid.UUID, actionType models.ActionType, toolName string) *gorm.DB {
+	query := t.db.Model(&models.TrackedAction{}).Where("user_id = ?", userID)
+	if actionType != "" {
+		query = query.Where("action_type = ?", actionType)
+	}
+	if toolName != "" {
+		query = query.Where("tool_name = ?", toolName)
+	}
+	return query
+}
+
+// GetActionCount returns how many actions of a type the user has done
+func (t *ActionTracker) GetActionCount(userID uuid.UUID, actionType models.ActionType, toolName string) int64 {
+	var count int64
+	t.actionQuery(userID, actionType, toolName).Count(&count)
+	return count
+}
+
+// GetActionAmount returns the total Amount of the user's actions of a type
+func (t *ActionTracker) GetActionAmount(userID uuid.UUID, actionType models.ActionType, toolName string) int64 {
+	var total int64
+	t.actionQuery(userID, actionType, toolName).Select("COALESCE(SUM(amount), 0)").Scan(&total)
+	return total
+}
+
+// GetDistinctServerCount returns how many different servers the user has done an action on
+func (t *ActionTracker) GetDistinctServerCount(userID uuid.UUID, actionType models.ActionType, toolName string) int64 {
+	var count int64
+	t.actionQuery(userID, actionType, toolName).Where("target_server <> ''").
+		Distinct("target_server").Count(&count)
+	return count
+}
+
+// GetActionStreak returns the number of consecutive days, up to maxDays, on which the user has
+// done an action of a type. A streak is still alive if the last action was yesterday.
+func (t *ActionTracker) GetActionStreak(userID uuid.UUID, actionType models.ActionType, toolName string, maxDays int) int {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	var times []time.Time
+	t.actionQuery(userID, actionType, toolName).
+		Where("created_at >= ?", today.AddDate(0, 0, -maxDays)).
+		Pluck("created_at", &times)
+
+	active := make(map[time.Time]bool)
+	for _, at := range times {
+		active[at.UTC().Truncate(24*time.Hour)] = true
+	}
+	day := today
+	if !active[day] {
+		day = day.AddDate(0, 0, -1)
+	}
+	streak := 0
+	for active[day] && streak < maxDays {
+		streak++
+		day = day.AddDate(0, 0, -1)
+	}
+	return streak
+}
+
 // CleanupOldActions removes actions older than the specified duration
 func (t *ActionTracker) CleanupOldActions(olderThan time.Duration) error {
 	cutoff := time.Now().Add(-olderThan)
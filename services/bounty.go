@@ -0,0 +1,492 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"terminal-sh/database"
+	"terminal-sh/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrBountyNotFound is returned when a bounty doesn't exist.
+	ErrBountyNotFound = errors.New("bounty not found")
+	// ErrBountyUnavailable is returned when a bounty isn't in a state that allows the action.
+	ErrBountyUnavailable = errors.New("bounty is not available for that")
+	// ErrBountyNotDone is returned when a hunter claims a bounty whose job isn't verifiably done.
+	ErrBountyNotDone = errors.New("the job isn't done yet")
+	// ErrBountyRewardTooLow is returned when a bounty is posted with less than MinBountyReward.
+	ErrBountyRewardTooLow = errors.New("reward too low")
+)
+
+const (
+	// MinBountyReward is the smallest reward a player can post.
+	MinBountyReward = 10
+	// DefaultBountyExpiry is how long a bounty stays on the board when no expiry is given.
+	DefaultBountyExpiry = 48 * time.Hour
+	// BountyDisputeWindow is how long a poster has to dispute a claim before the hunter is paid.
+	BountyDisputeWindow = time.Hour
+	// BountyCheckInterval is how often the background scheduler closes bounties that are due and
+	// tops the board up.
+	BountyCheckInterval = time.Minute
+	// systemBountyCount is how many of the game's own bounties are kept on the board.
+	systemBountyCount = 3
+)
+
+// bountyEscrow is the account holding the rewards of bounties that haven't paid out.
+var bountyEscrow = SystemAccount("bounties")
+
+// bountyWipeTool is the tool whose use after accepting completes a wipe bounty.
+const bountyWipeTool = "log_cleaner"
+
+// Reputation is how a player has done on the bounty board, as a hunter and as a poster.
+type Reputation struct {
+	Completed    int64 // Bounties completed as a hunter
+	Failed       int64 // Accepted bounties left to expire, or claims overturned in a dispute
+	Paid         int64 // Posted bounties that paid out
+	DisputesLost int64 // Disputes raised as a poster that went to the hunter
+}
+
+// HunterScore rates a player as a hunter: completions count for, failures against.
+func (r Reputation) HunterScore() int64 {
+	return r.Completed*10 - r.Failed*15
+}
+
+// PosterScore rates a player as a poster: bounties that paid count for, lost disputes against.
+func (r Reputation) PosterScore() int64 {
+	return r.Paid*5 - r.DisputesLost*10
+}
+
+// BountyService runs the bounty board: contracts posted by players or the game, whose rewards
+// sit in escrow until a hunter's claim is verified against their access records and actions.
+type BountyService struct {
+	db                *database.Database
+	ledger            *LedgerService
+	roleService       *RoleService
+	credentialService *CredentialService
+	actionTracker     *ActionTracker
+}
+
+// NewBountyService creates a new BountyService with the services claims are verified against.
+func NewBountyService(db *database.Database, roleService *RoleService, credentialService *CredentialService, actionTracker *ActionTracker) *BountyService {
+	return &BountyService{
+		db:                db,
+		ledger:            NewLedgerService(db),
+		roleService:       roleService,
+		credentialService: credentialService,
+		actionTracker:     actionTracker,
+	}
+}
+
+// Post puts a player's bounty on the board, moving the reward from their wallet into escrow.
+func (s *BountyService) Post(posterID uuid.UUID, kind models.BountyKind, target, account string, reward float64, ttl time.Duration) (*models.Bounty, error) {
+	if reward < MinBountyReward || math.IsInf(reward, 0) || math.IsNaN(reward) {
+		return nil, fmt.Errorf("%w: rewards start at %d crypto", ErrBountyRewardTooLow, MinBountyReward)
+	}
+	bounty, err := s.newBounty(kind, target, account, reward, ttl)
+	if err != nil {
+		return nil, err
+	}
+	bounty.PosterID = &posterID
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if _, err := s.ledger.TransferTx(tx, UserAccount(posterID), bountyEscrow, models.CurrencyCrypto, reward, "bounty_escrow", bounty.ID.String()); err != nil {
+			return err
+		}
+		return tx.Create(bounty).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return bounty, nil
+}
+
+// PostSystemBounty puts a bounty from the game itself on the board.
+func (s *BountyService) PostSystemBounty(kind models.BountyKind, target, account string, reward float64, ttl time.Duration) (*models.Bounty, error) {
+	bounty, err := s.newBounty(kind, target, account, reward, ttl)
+	if err != nil {
+		return nil, err
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if _, err := s.ledger.TransferTx(tx, SystemAccount("contracts"), bountyEscrow, models.CurrencyCrypto, reward, "bounty_escrow", bounty.ID.String()); err != nil {
+			return err
+		}
+		return tx.Create(bounty).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return bounty, nil
+}
+
+// newBounty validates a bounty's job and builds it.
+func (s *BountyService) newBounty(kind models.BountyKind, target, account string, reward float64, ttl time.Duration) (*models.Bounty, error) {
+	var server models.Server
+	if err := s.db.Where("ip = ?", target).First(&server).Error; err != nil {
+		return nil, fmt.Errorf("server not found: %s", target)
+	}
+	switch kind {
+	case models.BountyRoot, models.BountyWipe:
+		account = ""
+	case models.BountyCreds:
+		if account == "" {
+			return nil, fmt.Errorf("a creds bounty needs an account name")
+		}
+	default:
+		return nil, fmt.Errorf("unknown bounty kind: %s (root, creds or wipe)", kind)
+	}
+	if ttl <= 0 {
+		ttl = DefaultBountyExpiry
+	}
+	return &models.Bounty{
+		ID:        uuid.New(),
+		Kind:      kind,
+		Target:    target,
+		Account:   account,
+		Reward:    reward,
+		Status:    models.BountyOpen,
+		ExpiresAt: time.Now().Add(ttl),
+	}, nil
+}
+
+// Accept takes an open bounty for a hunter, so nobody else can claim it while they work on it.
+func (s *BountyService) Accept(hunterID uuid.UUID, bountyID string) (*models.Bounty, error) {
+	return s.update(bountyID, func(tx *gorm.DB, bounty *models.Bounty) error {
+		if bounty.Status != models.BountyOpen || time.Now().After(bounty.ExpiresAt) {
+			return ErrBountyUnavailable
+		}
+		if bounty.PosterID != nil && *bounty.PosterID == hunterID {
+			return fmt.Errorf("you can't take your own bounty")
+		}
+		now := time.Now()
+		bounty.HunterID = &hunterID
+		bounty.AcceptedAt = &now
+		bounty.Status = models.BountyAccepted
+		return tx.Model(bounty).Select("hunter_id", "accepted_at", "status").Updates(bounty).Error
+	})
+}
+
+// Abandon puts a bounty the hunter accepted back on the board.
+func (s *BountyService) Abandon(hunterID uuid.UUID, bountyID string) (*models.Bounty, error) {
+	return s.update(bountyID, func(tx *gorm.DB, bounty *models.Bounty) error {
+		if bounty.Status != models.BountyAccepted || !isUser(bounty.HunterID, hunterID) {
+			return ErrBountyUnavailable
+		}
+		bounty.Status = models.BountyOpen
+		bounty.HunterID = nil
+		bounty.AcceptedAt = nil
+		return tx.Model(bounty).Updates(map[string]interface{}{"status": bounty.Status, "hunter_id": nil, "accepted_at": nil}).Error
+	})
+}
+
+// Claim verifies that the hunter has done a bounty's job. A game bounty pays out at once; a
+// player's pays out after the dispute window unless the poster disputes it or confirms it first.
+func (s *BountyService) Claim(hunterID uuid.UUID, bountyID string) (*models.Bounty, error) {
+	return s.update(bountyID, func(tx *gorm.DB, bounty *models.Bounty) error {
+		if bounty.Status != models.BountyAccepted || !isUser(bounty.HunterID, hunterID) {
+			return ErrBountyUnavailable
+		}
+		if !s.Verify(bounty) {
+			return ErrBountyNotDone
+		}
+		if bounty.PosterID == nil {
+			return s.payOut(tx, bounty)
+		}
+		now := time.Now()
+		bounty.ClaimedAt = &now
+		bounty.Status = models.BountyClaimed
+		return tx.Model(bounty).Select("claimed_at", "status").Updates(bounty).Error
+	})
+}
+
+// Confirm lets a poster accept a claim and pay the hunter without waiting out the dispute window.
+func (s *BountyService) Confirm(posterID uuid.UUID, bountyID string) (*models.Bounty, error) {
+	return s.update(bountyID, func(tx *gorm.DB, bounty *models.Bounty) error {
+		if bounty.Status != models.BountyClaimed || !isUser(bounty.PosterID, posterID) {
+			return ErrBountyUnavailable
+		}
+		return s.payOut(tx, bounty)
+	})
+}
+
+// Dispute holds a claimed bounty's reward until an admin resolves it.
+func (s *BountyService) Dispute(posterID uuid.UUID, bountyID, reason string) (*models.Bounty, error) {
+	return s.update(bountyID, func(tx *gorm.DB, bounty *models.Bounty) error {
+		if bounty.Status != models.BountyClaimed || !isUser(bounty.PosterID, posterID) {
+			return ErrBountyUnavailable
+		}
+		bounty.Status = models.BountyDisputed
+		bounty.Disputed = true
+		bounty.Dispute = reason
+		return tx.Model(bounty).Select("status", "disputed", "dispute").Updates(bounty).Error
+	})
+}
+
+// Resolve settles a disputed bounty, paying the hunter or refunding the poster.
+func (s *BountyService) Resolve(bountyID string, payHunter bool) (*models.Bounty, error) {
+	return s.update(bountyID, func(tx *gorm.DB, bounty *models.Bounty) error {
+		if bounty.Status != models.BountyDisputed {
+			return ErrBountyUnavailable
+		}
+		if payHunter {
+			return s.payOut(tx, bounty)
+		}
+		return s.refund(tx, bounty, models.BountyRefunded)
+	})
+}
+
+// Cancel withdraws a poster's bounty that nobody has accepted and refunds the reward.
+func (s *BountyService) Cancel(posterID uuid.UUID, bountyID string) (*models.Bounty, error) {
+	return s.update(bountyID, func(tx *gorm.DB, bounty *models.Bounty) error {
+		if bounty.Status != models.BountyOpen || !isUser(bounty.PosterID, posterID) {
+			return ErrBountyUnavailable
+		}
+		return s.refund(tx, bounty, models.BountyCancelled)
+	})
+}
+
+// ProcessBounties expires bounties nobody finished in time and pays out claims whose dispute
+// window has passed. Returns how many bounties it closed.
+func (s *BountyService) ProcessBounties() (int, error) {
+	closed := 0
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		var bounties []models.Bounty
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("(status IN ? AND expires_at < ?) OR (status = ? AND claimed_at < ?)",
+				[]models.BountyStatus{models.BountyOpen, models.BountyAccepted}, now,
+				models.BountyClaimed, now.Add(-BountyDisputeWindow)).
+			Find(&bounties).Error; err != nil {
+			return err
+		}
+		for i := range bounties {
+			var err error
+			if bounties[i].Status == models.BountyClaimed {
+				err = s.payOut(tx, &bounties[i])
+			} else {
+				err = s.refund(tx, &bounties[i], models.BountyExpired)
+			}
+			if err != nil {
+				return err
+			}
+			closed++
+		}
+		return nil
+	})
+	return closed, err
+}
+
+// RefreshSystemBounties tops the board up with the game's own bounties on random servers.
+func (s *BountyService) RefreshSystemBounties() error {
+	var open int64
+	if err := s.db.Model(&models.Bounty{}).Where("poster_id IS NULL AND status IN ?",
+		[]models.BountyStatus{models.BountyOpen, models.BountyAccepted}).Count(&open).Error; err != nil {
+		return err
+	}
+	if open >= systemBountyCount {
+		return nil
+	}
+
+	// Shops, honeypots and shared servers aren't fair game
+	var candidates, servers []models.Server
+	if err := s.db.Where("shared_world = ? AND ip NOT IN (?)", false,
+		s.db.Model(&models.Shop{}).Select("server_ip")).Find(&candidates).Error; err != nil {
+		return err
+	}
+	for _, server := range candidates {
+		if server.Honeypot == nil {
+			servers = append(servers, server)
+		}
+	}
+	for i := int(open); i < systemBountyCount && len(servers) > 0; i++ {
+		server := servers[rand.Intn(len(servers))]
+		kind := []models.BountyKind{models.BountyRoot, models.BountyCreds, models.BountyWipe}[rand.Intn(3)]
+		account := ""
+		if kind == models.BountyCreds {
+			var accounts []string
+			for _, role := range server.Roles {
+				if role.GetRoleType() != models.RoleTypeRoot {
+					accounts = append(accounts, role.Role)
+				}
+			}
+			if len(accounts) == 0 {
+				kind = models.BountyRoot
+			} else {
+				account = accounts[rand.Intn(len(accounts))]
+			}
+		}
+		reward := float64(50 + server.SecurityLevel*2)
+		if _, err := s.PostSystemBounty(kind, server.IP, account, reward, 24*time.Hour); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Verify checks whether a bounty's hunter has done its job: root on the target, a credential
+// for the wanted account, or the target's logs cleaned since they accepted it.
+func (s *BountyService) Verify(bounty *models.Bounty) bool {
+	if bounty.HunterID == nil {
+		return false
+	}
+	hunterID := *bounty.HunterID
+	switch bounty.Kind {
+	case models.BountyRoot:
+		for _, path := range s.serverPaths(hunterID, bounty.Target) {
+			if s.roleService.HasRootAccess(hunterID, path) {
+				return true
+			}
+		}
+	case models.BountyCreds:
+		return s.findCredential(hunterID, bounty) != nil
+	case models.BountyWipe:
+		return bounty.AcceptedAt != nil && s.actionTracker.HasToolUseSince(hunterID, bountyWipeTool, bounty.Target, *bounty.AcceptedAt)
+	}
+	return false
+}
+
+// GetBoard returns the bounties still on the board, newest first.
+func (s *BountyService) GetBoard() ([]models.Bounty, error) {
+	var bounties []models.Bounty
+	err := s.db.Where("status IN ?", []models.BountyStatus{models.BountyOpen, models.BountyAccepted}).
+		Order("created_at DESC").Find(&bounties).Error
+	return bounties, err
+}
+
+// GetUserBounties returns the bounties a user posted or hunted that are still in play.
+func (s *BountyService) GetUserBounties(userID uuid.UUID) ([]models.Bounty, error) {
+	var bounties []models.Bounty
+	err := s.db.Where("(poster_id = ? OR hunter_id = ?) AND status IN ?", userID, userID,
+		[]models.BountyStatus{models.BountyOpen, models.BountyAccepted, models.BountyClaimed, models.BountyDisputed}).
+		Order("created_at DESC").Find(&bounties).Error
+	return bounties, err
+}
+
+// GetDisputes returns the bounties waiting for an admin to resolve a dispute.
+func (s *BountyService) GetDisputes() ([]models.Bounty, error) {
+	var bounties []models.Bounty
+	err := s.db.Where("status = ?", models.BountyDisputed).Order("claimed_at").Find(&bounties).Error
+	return bounties, err
+}
+
+// GetReputation works out a player's bounty board reputation from their bounties.
+func (s *BountyService) GetReputation(userID uuid.UUID) Reputation {
+	var rep Reputation
+	s.db.Model(&models.Bounty{}).Where("hunter_id = ? AND status = ?", userID, models.BountyCompleted).Count(&rep.Completed)
+	s.db.Model(&models.Bounty{}).Where("hunter_id = ? AND status IN ?", userID,
+		[]models.BountyStatus{models.BountyExpired, models.BountyRefunded}).Count(&rep.Failed)
+	s.db.Model(&models.Bounty{}).Where("poster_id = ? AND status = ?", userID, models.BountyCompleted).Count(&rep.Paid)
+	s.db.Model(&models.Bounty{}).Where("poster_id = ? AND status = ? AND disputed = ?", userID, models.BountyCompleted, true).Count(&rep.DisputesLost)
+	return rep
+}
+
+// update locks a bounty by ID prefix and applies a change to it in a transaction.
+func (s *BountyService) update(bountyID string, change func(tx *gorm.DB, bounty *models.Bounty) error) (*models.Bounty, error) {
+	var bounty models.Bounty
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if len(bountyID) < minIDPrefix {
+			return ErrBountyNotFound
+		}
+		var bounties []models.Bounty
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where(`id LIKE ? ESCAPE '\'`, idPrefixPattern(bountyID)).Limit(2).Find(&bounties).Error; err != nil {
+			return err
+		}
+		switch len(bounties) {
+		case 0:
+			return ErrBountyNotFound
+		case 1:
+		default:
+			return ErrAmbiguousID
+		}
+		bounty = bounties[0]
+		return change(tx, &bounty)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &bounty, nil
+}
+
+// payOut pays a bounty's reward from escrow to its hunter. The poster of a creds bounty gets
+// a copy of the credential the hunter found.
+func (s *BountyService) payOut(tx *gorm.DB, bounty *models.Bounty) error {
+	if _, err := s.ledger.TransferTx(tx, bountyEscrow, UserAccount(*bounty.HunterID), models.CurrencyCrypto, bounty.Reward, "bounty_reward", bounty.ID.String()); err != nil {
+		return err
+	}
+	if bounty.Kind == models.BountyCreds && bounty.PosterID != nil {
+		if cred := s.findCredential(*bounty.HunterID, bounty); cred != nil {
+			handed := *cred
+			handed.ID = uuid.New()
+			handed.UserID = *bounty.PosterID
+			handed.ToolUsed = "bounty"
+			if err := tx.Where("user_id = ? AND server_path = ? AND service_name = ? AND username = ?",
+				handed.UserID, handed.ServerPath, handed.ServiceName, handed.Username).
+				FirstOrCreate(&handed).Error; err != nil {
+				return fmt.Errorf("failed to hand over credential: %w", err)
+			}
+		}
+	}
+	now := time.Now()
+	bounty.Status = models.BountyCompleted
+	bounty.CompletedAt = &now
+	return tx.Model(bounty).Select("status", "completed_at").Updates(bounty).Error
+}
+
+// refund returns a bounty's reward from escrow to whoever posted it.
+func (s *BountyService) refund(tx *gorm.DB, bounty *models.Bounty, status models.BountyStatus) error {
+	poster := SystemAccount("contracts")
+	if bounty.PosterID != nil {
+		poster = UserAccount(*bounty.PosterID)
+	}
+	if _, err := s.ledger.TransferTx(tx, bountyEscrow, poster, models.CurrencyCrypto, bounty.Reward, "bounty_refund", bounty.ID.String()); err != nil {
+		return err
+	}
+	bounty.Status = status
+	return tx.Model(bounty).Select("status").Updates(bounty).Error
+}
+
+// findCredential returns the hunter's credential for a creds bounty's account, if they have one.
+func (s *BountyService) findCredential(hunterID uuid.UUID, bounty *models.Bounty) *models.DiscoveredCredential {
+	for _, path := range s.serverPaths(hunterID, bounty.Target) {
+		creds, err := s.credentialService.GetCredentials(hunterID, path)
+		if err != nil {
+			continue
+		}
+		for i := range creds {
+			if creds[i].Username == bounty.Account {
+				return &creds[i]
+			}
+		}
+	}
+	return nil
+}
+
+// serverPaths returns the paths a user has reached a server by: its IP, and any path through
+// other servers' local networks that their access records end in it.
+func (s *BountyService) serverPaths(userID uuid.UUID, ip string) []string {
+	paths := []string{ip}
+	seen := map[string]bool{ip: true}
+	for _, model := range []interface{}{&models.DiscoveredCredential{}, &models.BackdoorAccess{}, &models.PrivilegeEscalation{}} {
+		var found []string
+		s.db.Model(model).Where("user_id = ? AND server_path LIKE ?", userID, "%."+ip).Distinct().Pluck("server_path", &found)
+		for _, path := range found {
+			if !seen[path] {
+				seen[path] = true
+				paths = append(paths, path)
+			}
+		}
+	}
+	return paths
+}
+
+// isUser reports whether an optional user ID is set to the given user.
+func isUser(id *uuid.UUID, userID uuid.UUID) bool {
+	return id != nil && *id == userID
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"terminal-sh/models"
+)
+
+func newTestBountyService(t *testing.T) (*BountyService, *models.User, *models.User) {
+	t.Helper()
+	db := newTestDatabase(t)
+	if err := db.Create(&models.Server{IP: "10.4.2.7", LocalIP: "192.168.1.7"}).Error; err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	users := NewUserService(db, "test-secret")
+	poster, _ := users.Register("alice", "correct-horse")
+	hunter, _ := users.Register("bob", "battery-staple")
+	for _, u := range []*models.User{poster, hunter} {
+		u.Wallet.Crypto = 100
+		db.Model(u).Select("wallet").Updates(u)
+	}
+	roles := NewRoleService(db)
+	return NewBountyService(db, roles, NewCredentialService(db), NewActionTracker(db)), poster, hunter
+}
+
+func TestCredsBountyPaysHunterAndHandsOverCredential(t *testing.T) {
+	bounties, poster, hunter := newTestBountyService(t)
+	db := bounties.db
+
+	bounty, err := bounties.Post(poster.ID, models.BountyCreds, "10.4.2.7", "admin", 60, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to post: %v", err)
+	}
+	id := bounty.ID.String()[:8]
+	if _, err := bounties.Accept(hunter.ID, id); err != nil {
+		t.Fatalf("failed to accept: %v", err)
+	}
+	if _, err := bounties.Claim(hunter.ID, id); !errors.Is(err, ErrBountyNotDone) {
+		t.Fatalf("expected the claim to fail without the credential, got %v", err)
+	}
+
+	// The hunter cracked the account from inside another server's local network
+	if err := bounties.credentialService.SaveCredential(hunter.ID, "1.1.1.1.localNetwork.10.4.2.7", "ssh", "admin", "hunter2", "admin", models.CredentialTypeCracked, "password_cracker"); err != nil {
+		t.Fatalf("failed to save credential: %v", err)
+	}
+	claimed, err := bounties.Claim(hunter.ID, id)
+	if err != nil || claimed.Status != models.BountyClaimed {
+		t.Fatalf("expected the claim to verify, got %+v (%v)", claimed, err)
+	}
+	if _, err := bounties.Dispute(poster.ID, id, "wrong password"); err != nil {
+		t.Fatalf("failed to dispute: %v", err)
+	}
+	if _, err := bounties.Resolve(id, true); err != nil {
+		t.Fatalf("failed to resolve: %v", err)
+	}
+
+	var paid, hunted models.User
+	db.First(&paid, "id = ?", poster.ID)
+	db.First(&hunted, "id = ?", hunter.ID)
+	if paid.Wallet.Crypto != 40 || hunted.Wallet.Crypto != 160 {
+		t.Fatalf("expected 60 crypto to go from poster to hunter, got poster %.2f hunter %.2f", paid.Wallet.Crypto, hunted.Wallet.Crypto)
+	}
+	if !bounties.credentialService.HasCredentialsForService(poster.ID, "1.1.1.1.localNetwork.10.4.2.7", "ssh") {
+		t.Fatal("expected the poster to receive the credential")
+	}
+	if rep := bounties.GetReputation(hunter.ID); rep.HunterScore() != 10 {
+		t.Fatalf("expected hunter score 10, got %d", rep.HunterScore())
+	}
+	if rep := bounties.GetReputation(poster.ID); rep.Paid != 1 || rep.DisputesLost != 1 || rep.PosterScore() != -5 {
+		t.Fatalf("expected the lost dispute to count against the poster, got %+v", rep)
+	}
+}
+
+func TestWipeBountyNeedsLogCleanerAfterAcceptingAndExpires(t *testing.T) {
+	bounties, poster, hunter := newTestBountyService(t)
+
+	bounty, err := bounties.Post(poster.ID, models.BountyWipe, "10.4.2.7", "", 30, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to post: %v", err)
+	}
+	id := bounty.ID.String()
+	bounties.actionTracker.TrackToolUse(hunter.ID, "log_cleaner", "10.4.2.7", "")
+	accepted, err := bounties.Accept(hunter.ID, id)
+	if err != nil {
+		t.Fatalf("failed to accept: %v", err)
+	}
+	if bounties.Verify(accepted) {
+		t.Fatal("expected a wipe from before accepting not to count")
+	}
+	bounties.db.Model(&models.TrackedAction{}).Where("user_id = ?", hunter.ID).Update("created_at", accepted.AcceptedAt.Add(time.Second))
+	if !bounties.Verify(accepted) {
+		t.Fatal("expected a wipe after accepting to count")
+	}
+
+	bounties.db.Model(&models.Bounty{}).Where("id = ?", id).Update("expires_at", time.Now().Add(-time.Minute))
+	if closed, err := bounties.ProcessBounties(); err != nil || closed != 1 {
+		t.Fatalf("expected the bounty to expire, got %d (%v)", closed, err)
+	}
+	var refunded models.User
+	bounties.db.First(&refunded, "id = ?", poster.ID)
+	if refunded.Wallet.Crypto != 100 {
+		t.Fatalf("expected the reward refunded, poster has %.2f", refunded.Wallet.Crypto)
+	}
+	if rep := bounties.GetReputation(hunter.ID); rep.Failed != 1 {
+		t.Fatalf("expected the expired bounty to count against the hunter, got %+v", rep)
+	}
+	if report, _ := NewLedgerService(bounties.db).Reconcile(); !report.OK() {
+		t.Fatalf("expected the ledger to reconcile, got %+v", report)
+	}
+}
@@ -2,11 +2,13 @@ package services
 
 import (
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
 	"terminal-sh/auth"
 	"terminal-sh/database"
+	"terminal-sh/metrics"
 	"terminal-sh/models"
 
 	"github.com/google/uuid"
@@ -20,6 +22,8 @@ type ChatService struct {
 	activeSessions map[uuid.UUID]chan models.ChatMessage
 	sessionUsers   map[uuid.UUID]uuid.UUID          // sessionID -> userID
 	roomMembers    map[uuid.UUID]map[uuid.UUID]bool // roomID -> userID -> bool
+	announcer      func(text string)                // Also delivers announcements outside chat (shell notifications)
+	notifications  *NotificationService             // Tells players about mentions and invites outside chat
 	mu             sync.RWMutex
 }
 
@@ -31,6 +35,7 @@ func NewChatService(db *database.Database) *ChatService {
 		activeSessions: make(map[uuid.UUID]chan models.ChatMessage),
 		sessionUsers:   make(map[uuid.UUID]uuid.UUID),
 		roomMembers:    make(map[uuid.UUID]map[uuid.UUID]bool),
+		notifications:  NewNotificationService(db),
 	}
 
 	// Load rooms from database into memory
@@ -289,6 +294,8 @@ func (s *ChatService) InviteUser(roomID, inviterID, inviteeID uuid.UUID, inviter
 	}
 
 	s.roomMembers[roomID][inviteeID] = true
+	s.notifications.Notify(inviteeID, models.NotificationMention,
+		fmt.Sprintf("%s invited you to %s. Use /join %s in chat.", inviterUsername, room.Name, room.Name))
 
 	// Send invitation notification to invitee's active sessions
 	inviteMsg := models.ChatMessage{
@@ -349,6 +356,7 @@ func (s *ChatService) SendMessage(roomID, userID uuid.UUID, username, content st
 	if err := s.db.Create(message).Error; err != nil {
 		return fmt.Errorf("failed to send message: %w", err)
 	}
+	metrics.ChatMessages.Inc()
 
 	// Trim old messages (keep last 100)
 	s.trimMessages(roomID)
@@ -356,6 +364,80 @@ func (s *ChatService) SendMessage(roomID, userID uuid.UUID, username, content st
 	// Broadcast to all active sessions in the room
 	s.broadcastMessage(*message, roomID)
 
+	s.notifyMentions(*message)
+
+	return nil
+}
+
+// notifyMentions notifies each player a message @mentions, other than its sender, if they can
+// read the room it was sent to.
+func (s *ChatService) notifyMentions(message models.ChatMessage) {
+	mentioned := make(map[string]bool)
+	for _, word := range strings.Fields(message.Content) {
+		name, ok := strings.CutPrefix(word, "@")
+		name = strings.TrimRight(name, ".,:;!?")
+		if ok && name != "" && name != message.Username {
+			mentioned[name] = true
+		}
+	}
+	if len(mentioned) == 0 {
+		return
+	}
+
+	preview := []rune(message.Content)
+	if len(preview) > 60 {
+		preview = append(preview[:57], []rune("...")...)
+	}
+	for name := range mentioned {
+		user, err := s.GetUserByUsername(name)
+		if err != nil {
+			continue
+		}
+		s.mu.RLock()
+		room, ok := s.rooms[message.RoomID]
+		canRead := ok && (room.Type == "public" || s.roomMembers[message.RoomID][user.ID])
+		s.mu.RUnlock()
+		if !canRead {
+			continue // Don't leak messages from rooms they can't join
+		}
+		s.notifications.Notify(user.ID, models.NotificationMention,
+			fmt.Sprintf("%s mentioned you in %s: %s", message.Username, room.Name, string(preview)))
+	}
+}
+
+// SetAnnouncer sets where announcements go besides #public, such as every connected shell.
+func (s *ChatService) SetAnnouncer(announce func(text string)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.announcer = announce
+}
+
+// Announce posts a server-wide message from "system" to #public, where it stays in the
+// history, and passes it to the announcer so players outside chat see it too.
+func (s *ChatService) Announce(content string) error {
+	s.mu.RLock()
+	announce := s.announcer
+	s.mu.RUnlock()
+	if announce != nil {
+		announce(content)
+	}
+
+	room, err := s.GetRoomByName("#public")
+	if err != nil {
+		return err
+	}
+	message := &models.ChatMessage{
+		RoomID:    room.ID,
+		UserID:    uuid.Nil,
+		Username:  "system",
+		Content:   content,
+		CreatedAt: time.Now(),
+	}
+	if err := s.db.Create(message).Error; err != nil {
+		return fmt.Errorf("failed to post announcement: %w", err)
+	}
+	s.trimMessages(room.ID)
+	s.broadcastMessage(*message, room.ID)
 	return nil
 }
 
@@ -2,6 +2,7 @@ package services
 
 import (
 	"fmt"
+	"strings"
 	"terminal-sh/database"
 	"terminal-sh/models"
 
@@ -345,3 +346,18 @@ func GeneratePassword(username, serverIP string, role string) string {
 	// Default password pattern
 	return fmt.Sprintf("%s_%s_2026", username, serverIP[len(serverIP)-2:])
 }
+
+// RolePassword returns the current password of an account on a server. It starts as the
+// GeneratePassword default and changes each time the server's admin rotates it.
+func RolePassword(server *models.Server, username, role string) string {
+	password := GeneratePassword(username, server.IP, role)
+	r := server.GetRoleByUsername(username)
+	if r == nil || r.Rotations == 0 || username == "" {
+		return password
+	}
+
+	// Admins rotate to the season's password, like everyone else
+	seasons := []string{"Winter", "Spring", "Summer", "Autumn"}
+	name := strings.ToUpper(username[:1]) + username[1:]
+	return fmt.Sprintf("%s%s%d!", name, seasons[(len(password)+r.Rotations)%len(seasons)], 2026+r.Rotations/len(seasons))
+}
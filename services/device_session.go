@@ -0,0 +1,254 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"terminal-sh/auth"
+	"terminal-sh/models"
+
+	"github.com/google/uuid"
+)
+
+// refreshTokenTTL is how long a device stays logged in without being used.
+// Every refresh extends it, up to sessionMaxAge after the login.
+const refreshTokenTTL = 30 * 24 * time.Hour
+
+// sessionMaxAge is how long a device stays logged in however often it's used.
+// After that the user has to log in again.
+const sessionMaxAge = 90 * 24 * time.Hour
+
+// ErrSessionNotFound is returned when a device session doesn't exist, belongs to another user,
+// or has already been revoked.
+var ErrSessionNotFound = errors.New("session not found")
+
+// SessionTokens are the credentials handed to a client after login or resume.
+type SessionTokens struct {
+	SessionID    uuid.UUID
+	AccessToken  string
+	RefreshToken string
+	ExpiresAt    time.Time // Access token expiry
+}
+
+// SetPreviousJWTSecrets sets retired JWT secrets that are still accepted when validating tokens.
+func (s *UserService) SetPreviousJWTSecrets(secrets []string) {
+	s.tokenManager.AddPreviousKeys(secrets...)
+}
+
+// IssueDeviceSession creates a device session for a freshly authenticated user and
+// returns an access token and refresh token for it.
+func (s *UserService) IssueDeviceSession(user *models.User, device, sourceIP string) (*SessionTokens, error) {
+	refreshToken, err := newRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	session := &models.DeviceSession{
+		UserID:           user.ID,
+		RefreshTokenHash: hashToken(refreshToken),
+		Device:           device,
+		SourceIP:         sourceIP,
+		ExpiresAt:        now.Add(refreshTokenTTL),
+		LastUsedAt:       now,
+	}
+	if err := s.db.Create(session).Error; err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	tokens, err := s.sessionAccessToken(user, session.ID)
+	if err != nil {
+		return nil, err
+	}
+	tokens.RefreshToken = refreshToken
+	return tokens, nil
+}
+
+// ResumeSession logs a returning client back in. The refresh token is exchanged for a new
+// access token and a new refresh token, and the old refresh token stops working. An old
+// refresh token presented again revokes the session (see revokeReplayedSession).
+func (s *UserService) ResumeSession(refreshToken, sourceIP string) (*models.User, *SessionTokens, error) {
+	if refreshToken == "" {
+		return nil, nil, auth.ErrInvalidToken
+	}
+
+	var session models.DeviceSession
+	err := s.db.Where("refresh_token_hash = ? AND revoked_at IS NULL AND expires_at > ?", hashToken(refreshToken), time.Now()).
+		First(&session).Error
+	if err != nil {
+		s.revokeReplayedSession(hashToken(refreshToken))
+		return nil, nil, auth.ErrInvalidToken
+	}
+
+	user, err := s.GetUserByID(session.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	newToken, err := newRefreshToken()
+	if err != nil {
+		return nil, nil, err
+	}
+	// Rotate only if nobody else used the token first, so a copied token works at most once
+	now := time.Now()
+	updates := map[string]interface{}{
+		"refresh_token_hash":  hashToken(newToken),
+		"previous_token_hash": session.RefreshTokenHash,
+		"last_used_at":        now,
+		"expires_at":          sessionExpiry(session.CreatedAt, now),
+	}
+	if sourceIP != "" {
+		updates["source_ip"] = sourceIP
+	}
+	result := s.db.Model(&models.DeviceSession{}).
+		Where("id = ? AND refresh_token_hash = ?", session.ID, session.RefreshTokenHash).
+		Updates(updates)
+	if result.Error != nil {
+		return nil, nil, fmt.Errorf("failed to rotate refresh token: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		// Someone rotated it first: the token was used twice
+		s.revokeReplayedSession(session.RefreshTokenHash)
+		return nil, nil, auth.ErrInvalidToken
+	}
+
+	tokens, err := s.sessionAccessToken(user, session.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	tokens.RefreshToken = newToken
+	return user, tokens, nil
+}
+
+// AuthenticateRefreshToken returns the active device session a refresh token belongs to.
+// Unlike ResumeSession, the token isn't used up.
+func (s *UserService) AuthenticateRefreshToken(refreshToken string) (*models.DeviceSession, error) {
+	if refreshToken == "" {
+		return nil, auth.ErrInvalidToken
+	}
+	var session models.DeviceSession
+	err := s.db.Where("refresh_token_hash = ? AND revoked_at IS NULL AND expires_at > ?", hashToken(refreshToken), time.Now()).
+		First(&session).Error
+	if err != nil {
+		s.revokeReplayedSession(hashToken(refreshToken))
+		return nil, auth.ErrInvalidToken
+	}
+	return &session, nil
+}
+
+// revokeReplayedSession revokes the active device session whose previous refresh token has
+// the given hash. A rotated-out token only comes back if it was copied, and the session can't
+// tell whether the thief or the user holds the current one, so it ends for both.
+func (s *UserService) revokeReplayedSession(tokenHash string) {
+	var session models.DeviceSession
+	if err := s.db.Where("previous_token_hash = ? AND revoked_at IS NULL", tokenHash).First(&session).Error; err != nil {
+		return
+	}
+	s.RevokeDeviceSession(session.UserID, session.ID)
+}
+
+// ListDeviceSessions returns the user's active device sessions, most recently used first.
+func (s *UserService) ListDeviceSessions(userID uuid.UUID) ([]models.DeviceSession, error) {
+	var sessions []models.DeviceSession
+	err := s.db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_used_at DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// SetSessionRevokedCallback sets a function called with the IDs of revoked device sessions,
+// so transports can disconnect devices that are still connected.
+func (s *UserService) SetSessionRevokedCallback(callback func(sessionIDs []uuid.UUID)) {
+	s.onSessionRevoked = callback
+}
+
+// RevokeDeviceSession logs out one of the user's devices.
+// Its refresh token and any access tokens issued for it stop working immediately.
+func (s *UserService) RevokeDeviceSession(userID, sessionID uuid.UUID) error {
+	result := s.db.Model(&models.DeviceSession{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("failed to revoke session: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrSessionNotFound
+	}
+	s.sessionsRevoked([]uuid.UUID{sessionID})
+	return nil
+}
+
+// RevokeAllDeviceSessions logs out every device of the user except keep (uuid.Nil keeps none).
+// Returns the number of sessions revoked.
+func (s *UserService) RevokeAllDeviceSessions(userID, keep uuid.UUID) (int64, error) {
+	var ids []uuid.UUID
+	if err := s.db.Model(&models.DeviceSession{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, keep).
+		Pluck("id", &ids).Error; err != nil {
+		return 0, fmt.Errorf("failed to find sessions: %w", err)
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	result := s.db.Model(&models.DeviceSession{}).
+		Where("id IN ? AND revoked_at IS NULL", ids).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to revoke sessions: %w", result.Error)
+	}
+	s.sessionsRevoked(ids)
+	return result.RowsAffected, nil
+}
+
+// sessionsRevoked notifies the revocation callback, if any.
+func (s *UserService) sessionsRevoked(sessionIDs []uuid.UUID) {
+	if s.onSessionRevoked != nil {
+		s.onSessionRevoked(sessionIDs)
+	}
+}
+
+// activeSession returns the device session if it hasn't been revoked or expired.
+func (s *UserService) activeSession(sessionID uuid.UUID) (*models.DeviceSession, error) {
+	var session models.DeviceSession
+	err := s.db.Where("id = ? AND revoked_at IS NULL AND expires_at > ?", sessionID, time.Now()).First(&session).Error
+	if err != nil {
+		return nil, auth.ErrInvalidToken
+	}
+	return &session, nil
+}
+
+// sessionExpiry returns when a session created at createdAt and used at now expires:
+// refreshTokenTTL after its last use, but no later than sessionMaxAge after it was created.
+func sessionExpiry(createdAt, now time.Time) time.Time {
+	expiry := now.Add(refreshTokenTTL)
+	if limit := createdAt.Add(sessionMaxAge); expiry.After(limit) {
+		return limit
+	}
+	return expiry
+}
+
+// sessionAccessToken issues an access token bound to a device session.
+func (s *UserService) sessionAccessToken(user *models.User, sessionID uuid.UUID) (*SessionTokens, error) {
+	accessToken, err := s.tokenManager.GenerateSessionToken(user.ID, user.Username, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+	return &SessionTokens{
+		SessionID:   sessionID,
+		AccessToken: accessToken,
+		ExpiresAt:   time.Now().Add(auth.AccessTokenTTL),
+	}, nil
+}
+
+// newRefreshToken returns a random opaque refresh token.
+func newRefreshToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+	return hex.EncodeToString(raw), nil
+}
@@ -0,0 +1,196 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"terminal-sh/auth"
+	"terminal-sh/models"
+
+	"github.com/google/uuid"
+)
+
+func TestResumeSessionRotatesRefreshToken(t *testing.T) {
+	db := newTestDatabase(t)
+	userService := NewUserService(db, "test-secret")
+
+	user, err := userService.Register("carol", "password1")
+	if err != nil {
+		t.Fatalf("failed to register user: %v", err)
+	}
+	tokens, err := userService.IssueDeviceSession(user, "Firefox on Linux", "127.0.0.1")
+	if err != nil {
+		t.Fatalf("failed to issue session: %v", err)
+	}
+
+	_, resumed, err := userService.ResumeSession(tokens.RefreshToken, "127.0.0.1")
+	if err != nil {
+		t.Fatalf("expected refresh token to resume session, got %v", err)
+	}
+	if resumed.RefreshToken == "" || resumed.RefreshToken == tokens.RefreshToken {
+		t.Fatal("expected a new refresh token after resume")
+	}
+	if _, _, err := userService.ResumeSession(tokens.RefreshToken, "127.0.0.1"); err == nil {
+		t.Fatal("expected old refresh token to be rejected after rotation")
+	}
+}
+
+func TestReplayedRefreshTokenRevokesSession(t *testing.T) {
+	db := newTestDatabase(t)
+	userService := NewUserService(db, "test-secret")
+	var revoked []uuid.UUID
+	userService.SetSessionRevokedCallback(func(ids []uuid.UUID) { revoked = append(revoked, ids...) })
+
+	user, err := userService.Register("erin", "password1")
+	if err != nil {
+		t.Fatalf("failed to register user: %v", err)
+	}
+	stolen, err := userService.IssueDeviceSession(user, "Firefox on Linux", "127.0.0.1")
+	if err != nil {
+		t.Fatalf("failed to issue session: %v", err)
+	}
+
+	// The user resumes first, then the copied token turns up
+	_, current, err := userService.ResumeSession(stolen.RefreshToken, "127.0.0.1")
+	if err != nil {
+		t.Fatalf("expected refresh token to resume session, got %v", err)
+	}
+	if _, _, err := userService.ResumeSession(stolen.RefreshToken, "203.0.113.7"); err == nil {
+		t.Fatal("expected the rotated-out token to be rejected")
+	}
+	if len(revoked) != 1 || revoked[0] != stolen.SessionID {
+		t.Fatalf("expected the replay to revoke the session, got %v", revoked)
+	}
+	if _, _, err := userService.ResumeSession(current.RefreshToken, "127.0.0.1"); err == nil {
+		t.Fatal("expected the current refresh token to stop working too")
+	}
+	if _, err := userService.ValidateToken(current.AccessToken); err == nil {
+		t.Fatal("expected the session's access token to stop working")
+	}
+}
+
+func TestRevokedSessionInvalidatesTokens(t *testing.T) {
+	db := newTestDatabase(t)
+	userService := NewUserService(db, "new-secret")
+	userService.SetPreviousJWTSecrets([]string{"old-secret"})
+
+	var revoked []uuid.UUID
+	userService.SetSessionRevokedCallback(func(ids []uuid.UUID) { revoked = append(revoked, ids...) })
+
+	user, err := userService.Register("dave", "password1")
+	if err != nil {
+		t.Fatalf("failed to register user: %v", err)
+	}
+
+	// Tokens signed with a retired secret are still accepted
+	oldService := NewUserService(db, "old-secret")
+	tokens, err := oldService.IssueDeviceSession(user, "Chrome on macOS", "")
+	if err != nil {
+		t.Fatalf("failed to issue session: %v", err)
+	}
+	if _, err := userService.ValidateToken(tokens.AccessToken); err != nil {
+		t.Fatalf("expected token signed with previous secret to validate, got %v", err)
+	}
+
+	if err := userService.SetPassword(user.ID, "password2"); err != nil {
+		t.Fatalf("failed to set password: %v", err)
+	}
+	if len(revoked) != 1 || revoked[0] != tokens.SessionID {
+		t.Fatalf("expected password change to revoke the session, got %v", revoked)
+	}
+	if _, err := userService.ValidateToken(tokens.AccessToken); err == nil {
+		t.Fatal("expected access token of revoked session to be rejected")
+	}
+	if _, _, err := userService.ResumeSession(tokens.RefreshToken, ""); err == nil {
+		t.Fatal("expected revoked session not to resume")
+	}
+}
+
+func TestLoginTokenIsBoundToDeviceSession(t *testing.T) {
+	db := newTestDatabase(t)
+	userService := NewUserService(db, "test-secret")
+
+	user, err := userService.Register("erin", "password1")
+	if err != nil {
+		t.Fatalf("failed to register user: %v", err)
+	}
+	if _, tokens, err := userService.Login("erin", "password1", "", ""); err != nil || tokens != nil {
+		t.Fatalf("expected a login without a device to issue no tokens, got %+v (%v)", tokens, err)
+	}
+
+	_, tokens, err := userService.Login("erin", "password1", "127.0.0.1", "Firefox on Linux")
+	if err != nil || tokens == nil || tokens.RefreshToken == "" {
+		t.Fatalf("expected a device login to start a session, got %+v (%v)", tokens, err)
+	}
+	if time.Until(tokens.ExpiresAt) > auth.AccessTokenTTL {
+		t.Fatalf("expected a short-lived access token, expires at %v", tokens.ExpiresAt)
+	}
+	if _, err := userService.ValidateToken(tokens.AccessToken); err != nil {
+		t.Fatalf("expected the login's access token to validate, got %v", err)
+	}
+
+	if err := userService.RevokeDeviceSession(user.ID, tokens.SessionID); err != nil {
+		t.Fatalf("failed to revoke session: %v", err)
+	}
+	if _, err := userService.ValidateToken(tokens.AccessToken); err == nil {
+		t.Fatal("expected the login's access token to stop working with its device session")
+	}
+}
+
+func TestResumeSessionNeedsRefreshTokenWithinMaxAge(t *testing.T) {
+	db := newTestDatabase(t)
+	userService := NewUserService(db, "test-secret")
+
+	user, err := userService.Register("frank", "password1")
+	if err != nil {
+		t.Fatalf("failed to register user: %v", err)
+	}
+	tokens, err := userService.IssueDeviceSession(user, "Safari on iOS", "")
+	if err != nil {
+		t.Fatalf("failed to issue session: %v", err)
+	}
+	if _, _, err := userService.ResumeSession("", ""); err == nil {
+		t.Fatal("expected resume without a refresh token to fail")
+	}
+
+	// Kept in use, but logged in longer ago than the session may last
+	created := time.Now().Add(-sessionMaxAge + time.Hour)
+	if err := db.Model(&models.DeviceSession{}).Where("id = ?", tokens.SessionID).Update("created_at", created).Error; err != nil {
+		t.Fatalf("failed to age session: %v", err)
+	}
+	if _, _, err := userService.ResumeSession(tokens.RefreshToken, ""); err != nil {
+		t.Fatalf("expected the session to resume before its max age, got %v", err)
+	}
+	var session models.DeviceSession
+	if err := db.First(&session, "id = ?", tokens.SessionID).Error; err != nil {
+		t.Fatalf("failed to load session: %v", err)
+	}
+	if session.ExpiresAt.After(created.Add(sessionMaxAge)) {
+		t.Fatalf("expected the session to expire by its max age, expires at %v", session.ExpiresAt)
+	}
+}
+
+func TestAuthenticateRefreshTokenKeepsToken(t *testing.T) {
+	db := newTestDatabase(t)
+	userService := NewUserService(db, "test-secret")
+
+	user, err := userService.Register("grace", "password1")
+	if err != nil {
+		t.Fatalf("failed to register user: %v", err)
+	}
+	tokens, err := userService.IssueDeviceSession(user, "Edge on Windows", "")
+	if err != nil {
+		t.Fatalf("failed to issue session: %v", err)
+	}
+
+	session, err := userService.AuthenticateRefreshToken(tokens.RefreshToken)
+	if err != nil || session.ID != tokens.SessionID {
+		t.Fatalf("expected the refresh token to identify its session, got %+v (%v)", session, err)
+	}
+	if _, _, err := userService.ResumeSession(tokens.RefreshToken, ""); err != nil {
+		t.Fatalf("expected the refresh token to still resume, got %v", err)
+	}
+	if _, err := userService.AuthenticateRefreshToken(tokens.RefreshToken); err == nil {
+		t.Fatal("expected a rotated refresh token to be rejected")
+	}
+}
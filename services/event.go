@@ -0,0 +1,694 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"slices"
+	"strings"
+	"time"
+
+	"terminal-sh/database"
+	"terminal-sh/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrEventNotFound is returned when a live event doesn't exist.
+	ErrEventNotFound = errors.New("event not found")
+	// ErrEventUnavailable is returned when an event isn't in a state that allows the action.
+	ErrEventUnavailable = errors.New("event is not available for that")
+	// ErrEventOverlap is returned when an event would run at the same time as another.
+	ErrEventOverlap = errors.New("another event is running or scheduled at that time")
+	// ErrUnknownEventKind is returned for an event kind that doesn't exist.
+	ErrUnknownEventKind = errors.New("unknown event kind")
+	// ErrNoHeist is returned when looting a server that isn't the bank of a running heist.
+	ErrNoHeist = errors.New("there's no heist on this server")
+	// ErrAlreadyLooted is returned when a player loots a heist vault a second time.
+	ErrAlreadyLooted = errors.New("you've already taken your cut of this vault")
+	// ErrVaultEmpty is returned when there's nothing left in a heist vault.
+	ErrVaultEmpty = errors.New("the vault is empty")
+)
+
+const (
+	// EventCheckInterval is how often the background scheduler starts and ends events and rolls
+	// for a random one.
+	EventCheckInterval = time.Minute
+	// RandomEventRate is how many random events start an hour, when none is running or due.
+	RandomEventRate = 0.1
+	// HeistVault is the crypto a heist's bank server holds when it comes online.
+	HeistVault = 5000
+	// HeistCut is the share of what's left in the vault each player takes when they loot it.
+	HeistCut = 0.2
+	// CrackdownBoost is how much a crackdown raises every server's security level, up to
+	// MaxSecurityLevel.
+	CrackdownBoost = 25
+	// MaxSecurityLevel is the highest security level a server can have.
+	MaxSecurityLevel = 100
+	// EventMissionPrefix starts the ID of every event mission.
+	EventMissionPrefix = "event-"
+	// heistLevel is the difficulty the bank server is generated at.
+	heistLevel = 4
+	// vaultTarget marks a heist score as coming from the vault, which each player loots once.
+	vaultTarget = "vault"
+)
+
+// eventPrizes is where event prizes and heist vaults come from, and where whatever is left
+// in a vault goes back to.
+var eventPrizes = SystemAccount("events")
+
+// PrizeShares is how an event's prize is split among the top of its leaderboard.
+var PrizeShares = []float64{0.5, 0.3, 0.2}
+
+// ZeroDayTypes are the vulnerability types a zero-day can turn up in.
+var ZeroDayTypes = []string{"password_cracking", "remote_code_execution", "sql_injection", "xss"}
+
+// EventKinds are the kinds of live event, in the order they're listed to operators.
+var EventKinds = []models.EventKind{models.EventZeroDay, models.EventHeist, models.EventCrackdown}
+
+// eventDefaults is how long each kind of event runs and what it pays out.
+var eventDefaults = map[models.EventKind]struct {
+	duration time.Duration
+	prize    float64
+}{
+	models.EventZeroDay:   {time.Hour, 1000},
+	models.EventHeist:     {30 * time.Minute, 1500},
+	models.EventCrackdown: {2 * time.Hour, 1500},
+}
+
+// EventService runs live events: world-wide incidents that operators schedule or that start at
+// random, change the world while they run, offer their own missions, and pay their prize to
+// the top of their leaderboard when they end. Starts and ends are announced in #public and to
+// every connected shell.
+type EventService struct {
+	db              *database.Database
+	ledger          *LedgerService
+	serverService   *ServerService
+	serverGenerator *ServerGenerator
+	chatService     *ChatService   // Optional, announcements are skipped without it
+	chance          func() float64 // Rolls for random events; replaced in tests
+}
+
+// NewEventService creates a new EventService. chatService may be nil.
+func NewEventService(db *database.Database, chatService *ChatService) *EventService {
+	serverService := NewServerService(db)
+	return &EventService{
+		db:              db,
+		ledger:          NewLedgerService(db),
+		serverService:   serverService,
+		serverGenerator: NewServerGenerator(db, serverService),
+		chatService:     chatService,
+		chance:          rand.Float64,
+	}
+}
+
+// Schedule puts an event on the calendar to start at startsAt and run for duration, or the
+// kind's default if duration is zero. vulnType picks a zero-day's vulnerability type, random if
+// empty. createdBy is the operator who scheduled it, nil for random events. An event that's due
+// starts straight away.
+func (s *EventService) Schedule(kind models.EventKind, startsAt time.Time, duration time.Duration, vulnType string, createdBy *uuid.UUID) (*models.LiveEvent, error) {
+	defaults, ok := eventDefaults[kind]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownEventKind, kind)
+	}
+	if duration <= 0 {
+		duration = defaults.duration
+	}
+	event := &models.LiveEvent{
+		Kind:      kind,
+		Status:    models.EventScheduled,
+		Prize:     defaults.prize,
+		StartsAt:  startsAt,
+		EndsAt:    startsAt.Add(duration),
+		CreatedBy: createdBy,
+	}
+	switch kind {
+	case models.EventZeroDay:
+		if vulnType == "" {
+			vulnType = ZeroDayTypes[rand.Intn(len(ZeroDayTypes))]
+		} else if !slices.Contains(ZeroDayTypes, vulnType) {
+			return nil, fmt.Errorf("no zero-day for %s (one of %s)", vulnType, strings.Join(ZeroDayTypes, ", "))
+		}
+		event.VulnType = vulnType
+	case models.EventCrackdown:
+		event.Boost = CrackdownBoost
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var overlapping int64
+		if err := tx.Model(&models.LiveEvent{}).
+			Where("status IN ? AND starts_at < ? AND ends_at > ?",
+				[]models.EventStatus{models.EventScheduled, models.EventActive}, event.EndsAt, event.StartsAt).
+			Count(&overlapping).Error; err != nil {
+			return err
+		}
+		if overlapping > 0 {
+			return ErrEventOverlap
+		}
+		return tx.Create(event).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if wait := time.Until(startsAt); wait > 0 {
+		s.announce(fmt.Sprintf("📅 %s starts in %s. %s", EventTitle(event), wait.Round(time.Minute), EventSummary(event)))
+		return event, nil
+	}
+	if err := s.ProcessEvents(); err != nil {
+		return nil, err
+	}
+	return event, s.db.First(event, "id = ?", event.ID).Error
+}
+
+// ProcessEvents starts the events that are due and ends the ones whose time is up. An event
+// whose whole window passed before it could start, say while the server was down, is
+// cancelled.
+func (s *EventService) ProcessEvents() error {
+	now := time.Now()
+	var due []models.LiveEvent
+	if err := s.db.Where("(status = ? AND starts_at <= ?) OR (status = ? AND ends_at <= ?)",
+		models.EventScheduled, now, models.EventActive, now).
+		Order("starts_at").Find(&due).Error; err != nil {
+		return err
+	}
+
+	var errs []error
+	for i := range due {
+		event := &due[i]
+		if event.Status == models.EventScheduled && !event.EndsAt.After(now) {
+			if _, err := s.claim(s.db.DB, event, models.EventScheduled, models.EventCancelled); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		if event.Status == models.EventScheduled {
+			if err := s.start(event); err != nil {
+				errs = append(errs, fmt.Errorf("failed to start %s event: %w", event.Kind, err))
+			}
+			continue
+		}
+		if err := s.end(event); err != nil {
+			errs = append(errs, fmt.Errorf("failed to end %s event: %w", event.Kind, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// TriggerRandomEvent rolls for a random event to start now. Called every EventCheckInterval,
+// it starts RandomEventRate events an hour, never while another is running or due.
+func (s *EventService) TriggerRandomEvent() (*models.LiveEvent, error) {
+	if s.chance() >= RandomEventRate*EventCheckInterval.Hours() {
+		return nil, nil
+	}
+	event, err := s.Schedule(EventKinds[rand.Intn(len(EventKinds))], time.Now(), 0, "", nil)
+	if errors.Is(err, ErrEventOverlap) {
+		return nil, nil
+	}
+	return event, err
+}
+
+// End ends a running event now rather than at its end time.
+func (s *EventService) End(eventID string) (*models.LiveEvent, error) {
+	event, err := s.Find(eventID)
+	if err != nil {
+		return nil, err
+	}
+	if event.Status != models.EventActive {
+		return nil, ErrEventUnavailable
+	}
+	event.EndsAt = time.Now()
+	if err := s.db.Model(event).Update("ends_at", event.EndsAt).Error; err != nil {
+		return nil, err
+	}
+	return event, s.end(event)
+}
+
+// Cancel calls off an event that hasn't started yet.
+func (s *EventService) Cancel(eventID string) (*models.LiveEvent, error) {
+	event, err := s.Find(eventID)
+	if err != nil {
+		return nil, err
+	}
+	claimed, err := s.claim(s.db.DB, event, models.EventScheduled, models.EventCancelled)
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		return nil, ErrEventUnavailable
+	}
+	s.announce(fmt.Sprintf("📅 %s has been called off.", EventTitle(event)))
+	return event, nil
+}
+
+// Find returns an event by ID prefix, as shown to players.
+func (s *EventService) Find(eventID string) (*models.LiveEvent, error) {
+	if len(eventID) < minIDPrefix {
+		return nil, ErrEventNotFound
+	}
+	var events []models.LiveEvent
+	if err := s.db.Where(`id LIKE ? ESCAPE '\'`, idPrefixPattern(eventID)).Limit(2).Find(&events).Error; err != nil {
+		return nil, err
+	}
+	switch len(events) {
+	case 0:
+		return nil, ErrEventNotFound
+	case 1:
+		return &events[0], nil
+	default:
+		return nil, ErrAmbiguousID
+	}
+}
+
+// Active returns the events running now.
+func (s *EventService) Active() ([]models.LiveEvent, error) {
+	var events []models.LiveEvent
+	err := s.db.Where("status = ? AND ends_at > ?", models.EventActive, time.Now()).
+		Order("starts_at").Find(&events).Error
+	return events, err
+}
+
+// Upcoming returns the events scheduled to start, soonest first.
+func (s *EventService) Upcoming() ([]models.LiveEvent, error) {
+	var events []models.LiveEvent
+	err := s.db.Where("status = ?", models.EventScheduled).Order("starts_at").Find(&events).Error
+	return events, err
+}
+
+// Recent returns the events that ended most recently, latest first.
+func (s *EventService) Recent(limit int) ([]models.LiveEvent, error) {
+	var events []models.LiveEvent
+	err := s.db.Where("status = ?", models.EventEnded).Order("ends_at DESC").Limit(limit).Find(&events).Error
+	return events, err
+}
+
+// Leaderboard returns the top scorers of an event, best first. Ties go to whoever got there
+// first.
+func (s *EventService) Leaderboard(eventID uuid.UUID, limit int) ([]models.EventScore, error) {
+	var scores []models.EventScore
+	err := s.db.Where("event_id = ? AND score > 0", eventID).
+		Order("score DESC, updated_at ASC").Limit(limit).Find(&scores).Error
+	return scores, err
+}
+
+// ActiveZeroDay returns the vulnerability type of the zero-day running now, or "" if there's
+// none.
+func (s *EventService) ActiveZeroDay() string {
+	var event models.LiveEvent
+	if err := s.db.Where("kind = ? AND status = ? AND ends_at > ?", models.EventZeroDay, models.EventActive, time.Now()).
+		First(&event).Error; err != nil {
+		return ""
+	}
+	return event.VulnType
+}
+
+// RecordExploit scores a successful exploit on the leaderboards of the events running now.
+// During a zero-day an exploit through its vulnerability scores 1; during a crackdown any
+// exploit scores the server's security level. Each service of a server scores once per event.
+func (s *EventService) RecordExploit(userID uuid.UUID, username string, server *models.Server, serviceName string, exploits []models.Exploit) {
+	events, err := s.Active()
+	if err != nil {
+		return
+	}
+	for i := range events {
+		var points float64
+		switch events[i].Kind {
+		case models.EventZeroDay:
+			for _, exploit := range exploits {
+				if exploit.Type == events[i].VulnType {
+					points = 1
+				}
+			}
+		case models.EventCrackdown:
+			points = float64(server.SecurityLevel)
+		}
+		if points == 0 {
+			continue
+		}
+		if err := s.addScore(s.db.DB, &events[i], userID, username, server.IP+"/"+serviceName, points); err != nil {
+			log.Printf("events: failed to score exploit for %s: %v", username, err)
+		}
+	}
+}
+
+// Loot takes a player's cut of a heist vault: HeistCut of what's left in it, once per player.
+// The crypto taken is the player's score. Returns the amount taken.
+func (s *EventService) Loot(userID uuid.UUID, username, serverIP string) (float64, error) {
+	var event models.LiveEvent
+	if err := s.db.Where("kind = ? AND status = ? AND server_ip = ? AND ends_at > ?",
+		models.EventHeist, models.EventActive, serverIP, time.Now()).First(&event).Error; err != nil {
+		return 0, ErrNoHeist
+	}
+
+	var cut float64
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var server models.Server
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&server, "ip = ?", serverIP).Error; err != nil {
+			return ErrNoHeist
+		}
+		var score models.EventScore
+		if err := tx.Where("event_id = ? AND user_id = ?", event.ID, userID).First(&score).Error; err == nil &&
+			slices.Contains(score.Targets, vaultTarget) {
+			return ErrAlreadyLooted
+		}
+		cut = math.Floor(server.Wallet.Crypto*HeistCut*100) / 100
+		if cut <= 0 {
+			return ErrVaultEmpty
+		}
+		if _, err := s.ledger.TransferTx(tx, ServerAccount(server.ID), UserAccount(userID), models.CurrencyCrypto,
+			cut, "heist", event.ID.String()); err != nil {
+			return err
+		}
+		return s.addScore(tx, &event, userID, username, vaultTarget, cut)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return cut, nil
+}
+
+// GetMission returns an event mission by ID, or nil if there's no such event mission.
+func (s *EventService) GetMission(missionID string) *models.Mission {
+	event := s.missionEvent(missionID)
+	if event == nil {
+		return nil
+	}
+	for i := range event.Missions {
+		if event.Missions[i].ID == missionID {
+			return &event.Missions[i]
+		}
+	}
+	return nil
+}
+
+// MissionExpired reports whether a mission belongs to an event that's no longer running.
+func (s *EventService) MissionExpired(missionID string) bool {
+	event := s.missionEvent(missionID)
+	return event != nil && (event.Status != models.EventActive || !event.EndsAt.After(time.Now()))
+}
+
+// ActiveMissions returns the missions of the events running now.
+func (s *EventService) ActiveMissions() []models.Mission {
+	events, err := s.Active()
+	if err != nil {
+		return nil
+	}
+	var missions []models.Mission
+	for _, event := range events {
+		missions = append(missions, event.Missions...)
+	}
+	return missions
+}
+
+// missionEvent returns the event an event mission belongs to, or nil if the mission isn't one.
+// Event mission IDs start with the event's short ID, as in "event-1a2b3c4d-1".
+func (s *EventService) missionEvent(missionID string) *models.LiveEvent {
+	rest, ok := strings.CutPrefix(missionID, EventMissionPrefix)
+	if !ok {
+		return nil
+	}
+	eventID, _, _ := strings.Cut(rest, "-")
+	event, err := s.Find(eventID)
+	if err != nil {
+		return nil
+	}
+	return event
+}
+
+// start turns an event's effects on and announces it. The event is claimed and its effects
+// applied together, so it never runs without them or gets them twice.
+func (s *EventService) start(event *models.LiveEvent) error {
+	// The bank is generated first: the generator doesn't write through a transaction
+	var heistServer *models.Server
+	if event.Kind == models.EventHeist {
+		server, err := s.serverGenerator.GenerateHeistServer(heistLevel)
+		if err != nil {
+			return fmt.Errorf("failed to bring the bank online: %w", err)
+		}
+		heistServer = server
+	}
+
+	claimed := false
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if claimed, err = s.claim(tx, event, models.EventScheduled, models.EventActive); err != nil || !claimed {
+			return err
+		}
+
+		now := time.Now()
+		event.StartedAt = &now
+		switch event.Kind {
+		case models.EventHeist:
+			if _, err := s.ledger.TransferTx(tx, eventPrizes, ServerAccount(heistServer.ID), models.CurrencyCrypto,
+				HeistVault, "heist_vault", event.ID.String()); err != nil {
+				return fmt.Errorf("failed to fill the vault: %w", err)
+			}
+			event.ServerIP = heistServer.IP
+		case models.EventCrackdown:
+			// Each server records what it actually got so the end takes off no more than that
+			applied := gorm.Expr("CASE WHEN security_level >= ? THEN 0 WHEN security_level + ? > ? THEN ? - security_level ELSE ? END",
+				MaxSecurityLevel, event.Boost, MaxSecurityLevel, MaxSecurityLevel, event.Boost)
+			if err := tx.Model(&models.Server{}).Where("security_level > 0").Updates(map[string]interface{}{
+				"security_boost": applied,
+				"security_level": gorm.Expr("security_level + ?", applied),
+			}).Error; err != nil {
+				return fmt.Errorf("failed to raise security levels: %w", err)
+			}
+			event.Boosted = true
+		}
+		event.Missions = eventMissions(event)
+		return tx.Model(event).Select("started_at", "server_ip", "boosted", "missions").Updates(event).Error
+	})
+	if err != nil || !claimed {
+		if err != nil {
+			event.Status = models.EventScheduled
+		}
+		if heistServer != nil {
+			s.db.Delete(heistServer)
+		}
+		return err
+	}
+
+	s.announce(fmt.Sprintf("⚡ %s has started! %s It ends in %s, and the top %d split %.0f crypto. See 'event'.",
+		EventTitle(event), EventSummary(event), time.Until(event.EndsAt).Round(time.Minute), len(PrizeShares), event.Prize))
+	return nil
+}
+
+// end turns an event's effects off, pays its prizes and announces the winners. Event missions
+// still in progress are dropped. The event is claimed and its effects undone together, so an
+// event whose effects couldn't be undone stays active and is ended again on the next check.
+func (s *EventService) end(event *models.LiveEvent) error {
+	claimed := false
+	var winners []models.EventScore
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if claimed, err = s.claim(tx, event, models.EventActive, models.EventEnded); err != nil || !claimed {
+			return err
+		}
+
+		switch event.Kind {
+		case models.EventHeist:
+			if err := s.closeVault(tx, event); err != nil {
+				return fmt.Errorf("failed to close the vault: %w", err)
+			}
+		case models.EventCrackdown:
+			// Servers generated or recycled during the crackdown never got the boost
+			if event.Boosted {
+				if err := tx.Model(&models.Server{}).Where("security_boost > 0").Updates(map[string]interface{}{
+					"security_level": gorm.Expr("CASE WHEN security_level > security_boost THEN security_level - security_boost ELSE 1 END"),
+					"security_boost": 0,
+				}).Error; err != nil {
+					return fmt.Errorf("failed to restore security levels: %w", err)
+				}
+			}
+		}
+
+		var missionIDs []string
+		for _, mission := range event.Missions {
+			missionIDs = append(missionIDs, mission.ID)
+		}
+		if len(missionIDs) > 0 {
+			if err := tx.Where("mission_id IN ? AND status = ?", missionIDs, "in_progress").
+				Delete(&models.UserMission{}).Error; err != nil {
+				return err
+			}
+		}
+
+		winners, err = s.payPrizes(tx, event)
+		return err
+	})
+	if err != nil {
+		event.Status = models.EventActive
+		return err
+	}
+	if !claimed {
+		return nil
+	}
+
+	if len(winners) == 0 {
+		s.announce(fmt.Sprintf("🏁 %s is over. Nobody made the leaderboard.", EventTitle(event)))
+	} else {
+		var names []string
+		for i, winner := range winners {
+			names = append(names, fmt.Sprintf("%d. %s (%.0f crypto)", i+1, winner.Username, winner.Payout))
+		}
+		s.announce(fmt.Sprintf("🏁 %s is over. Winners: %s", EventTitle(event), strings.Join(names, ", ")))
+	}
+	return nil
+}
+
+// closeVault takes a heist's bank server offline, returning what nobody looted to the game.
+func (s *EventService) closeVault(tx *gorm.DB, event *models.LiveEvent) error {
+	var server models.Server
+	if err := tx.First(&server, "ip = ?", event.ServerIP).Error; err != nil {
+		return nil // Never came online, or already gone
+	}
+	if server.Wallet.Crypto > 0 {
+		if _, err := s.ledger.TransferTx(tx, ServerAccount(server.ID), eventPrizes, models.CurrencyCrypto,
+			server.Wallet.Crypto, "heist_vault", event.ID.String()); err != nil {
+			return err
+		}
+	}
+	return tx.Delete(&server).Error
+}
+
+// payPrizes splits an event's prize among the top of its leaderboard by PrizeShares.
+// Returns the winners with their payouts.
+func (s *EventService) payPrizes(tx *gorm.DB, event *models.LiveEvent) ([]models.EventScore, error) {
+	var winners []models.EventScore
+	if err := tx.Where("event_id = ? AND score > 0", event.ID).
+		Order("score DESC, updated_at ASC").Limit(len(PrizeShares)).Find(&winners).Error; err != nil {
+		return nil, err
+	}
+	for i := range winners {
+		payout := math.Floor(event.Prize*PrizeShares[i]*100) / 100
+		if payout <= 0 {
+			continue
+		}
+		if _, err := s.ledger.TransferTx(tx, eventPrizes, UserAccount(winners[i].UserID), models.CurrencyCrypto,
+			payout, "event_prize", event.ID.String()); err != nil {
+			return nil, err
+		}
+		winners[i].Payout = payout
+		if err := tx.Model(&models.EventScore{}).Where("event_id = ? AND user_id = ?", event.ID, winners[i].UserID).
+			Update("payout", payout).Error; err != nil {
+			return nil, err
+		}
+	}
+	return winners, nil
+}
+
+// addScore adds points to a player's score on an event. A target that already scored for the
+// player doesn't score again.
+func (s *EventService) addScore(tx *gorm.DB, event *models.LiveEvent, userID uuid.UUID, username, target string, points float64) error {
+	score := models.EventScore{EventID: event.ID, UserID: userID, Username: username}
+	if err := tx.Where("event_id = ? AND user_id = ?", event.ID, userID).First(&score).Error; err != nil &&
+		!errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if slices.Contains(score.Targets, target) {
+		return nil
+	}
+	score.Score += points
+	score.Targets = append(score.Targets, target)
+	return tx.Save(&score).Error
+}
+
+// claim moves an event from one status to another. Only one caller gets to make the move, so
+// an event's effects are applied once even when several sessions process events at the same
+// time. Returns whether this caller made it.
+func (s *EventService) claim(tx *gorm.DB, event *models.LiveEvent, from, to models.EventStatus) (bool, error) {
+	result := tx.Model(&models.LiveEvent{}).Where("id = ? AND status = ?", event.ID, from).Update("status", to)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+	event.Status = to
+	return true, nil
+}
+
+// announce tells every player about an event.
+func (s *EventService) announce(text string) {
+	if s.chatService == nil {
+		return
+	}
+	if err := s.chatService.Announce(text); err != nil {
+		log.Printf("events: failed to announce: %v", err)
+	}
+}
+
+// EventTitle returns an event's name as players see it.
+func EventTitle(event *models.LiveEvent) string {
+	switch event.Kind {
+	case models.EventZeroDay:
+		return fmt.Sprintf("Zero-Day (%s)", event.VulnType)
+	case models.EventHeist:
+		return "Bank Heist"
+	case models.EventCrackdown:
+		return "Global Crackdown"
+	}
+	return string(event.Kind)
+}
+
+// EventSummary explains in a sentence or two what an event does and how it scores.
+func EventSummary(event *models.LiveEvent) string {
+	switch event.Kind {
+	case models.EventZeroDay:
+		return fmt.Sprintf("Any tool with a %s exploit breaks into every service on every server, patched or not. Each service exploited through it scores 1.", event.VulnType)
+	case models.EventHeist:
+		bank := "A bank server comes online"
+		if event.ServerIP != "" {
+			bank = "A bank server is online at " + event.ServerIP
+		}
+		return fmt.Sprintf("%s with %d crypto in its vault. Get in and run 'event loot' to take %.0f%% of what's left; what you take scores.", bank, HeistVault, HeistCut*100)
+	case models.EventCrackdown:
+		return fmt.Sprintf("Every server's security level is up by %d and admins are on edge. Each service exploited scores its server's security level.", event.Boost)
+	}
+	return ""
+}
+
+// eventMissions returns the missions an event offers while it runs. Only what players do after
+// the event starts counts towards them.
+func eventMissions(event *models.LiveEvent) []models.Mission {
+	since := event.StartedAt
+	mission := models.Mission{
+		ID:            EventMissionPrefix + event.ID.String()[:8] + "-1",
+		ArcID:         "events",
+		ArcName:       "Live Events",
+		MissionNumber: 1,
+	}
+	switch event.Kind {
+	case models.EventZeroDay:
+		mission.Name = "Zero-Day Rush"
+		mission.Description = fmt.Sprintf("A %s zero-day is loose. Use it before the vendors ship a fix.", event.VulnType)
+		mission.Objectives = []models.MissionObjective{
+			{ID: 1, Type: "exploit_server", Description: "Exploit a server while the zero-day is open", Since: since,
+				Hint: fmt.Sprintf("Any tool with a %s exploit works on every service until the window closes", event.VulnType)},
+		}
+		mission.Rewards = models.MissionRewards{Experience: 150, Crypto: 100}
+	case models.EventHeist:
+		mission.Name = "Bank Job"
+		mission.Description = "A bank's server is online with its vault exposed. Get in before it goes dark."
+		mission.Objectives = []models.MissionObjective{
+			{ID: 1, Type: "exploit_server", Description: "Break into the bank server", TargetServer: event.ServerIP, Since: since,
+				Hint: "Scan " + event.ServerIP + " for a service your tools can exploit"},
+			{ID: 2, Type: "connect_server", Description: "Get a shell on the bank server", TargetServer: event.ServerIP, Since: since,
+				Hint: "Connect to " + event.ServerIP + ", then run 'event loot' to take your cut of the vault"},
+		}
+		mission.Rewards = models.MissionRewards{Experience: 200, Crypto: 150}
+	case models.EventCrackdown:
+		mission.Name = "Under the Radar"
+		mission.Description = "Every admin on the net is on alert. Prove you can still get in, and stay in."
+		mission.Objectives = []models.MissionObjective{
+			{ID: 1, Type: "exploit_server", Description: "Exploit a server during the crackdown", Since: since},
+			{ID: 2, Type: "install_backdoor", Description: "Leave a backdoor behind during the crackdown", Since: since,
+				Hint: "Admins remove backdoors faster during a crackdown, so pick a server with a low security level"},
+		}
+		mission.Rewards = models.MissionRewards{Experience: 250, Crypto: 200}
+	}
+	return []models.Mission{mission}
+}
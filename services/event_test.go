@@ -0,0 +1,160 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"terminal-sh/models"
+)
+
+func TestHeistLootAndPrizesGoThroughTheLedger(t *testing.T) {
+	db := newTestDatabase(t)
+	users := NewUserService(db, "test-secret")
+	alice, _ := users.Register("alice", "correct-horse")
+	bob, _ := users.Register("bob", "battery-staple")
+	events := NewEventService(db, nil)
+
+	heist, err := events.Schedule(models.EventHeist, time.Now(), 0, "", nil)
+	if err != nil || heist.Status != models.EventActive || heist.ServerIP == "" {
+		t.Fatalf("expected the heist to start with a bank online, got %+v (%v)", heist, err)
+	}
+	if _, err := events.Schedule(models.EventZeroDay, time.Now(), 0, "sql_injection", nil); !errors.Is(err, ErrEventOverlap) {
+		t.Fatalf("expected overlapping events to be refused, got %v", err)
+	}
+
+	// Each player takes a fifth of what's left, once
+	if cut, err := events.Loot(alice.ID, "alice", heist.ServerIP); err != nil || cut != 1000 {
+		t.Fatalf("expected alice to take 1000, got %.2f (%v)", cut, err)
+	}
+	if _, err := events.Loot(alice.ID, "alice", heist.ServerIP); !errors.Is(err, ErrAlreadyLooted) {
+		t.Fatalf("expected a second loot to fail, got %v", err)
+	}
+	if cut, err := events.Loot(bob.ID, "bob", heist.ServerIP); err != nil || cut != 800 {
+		t.Fatalf("expected bob to take 800, got %.2f (%v)", cut, err)
+	}
+
+	if _, err := events.End(heist.ID.String()[:8]); err != nil {
+		t.Fatalf("failed to end the heist: %v", err)
+	}
+	if _, err := NewServerService(db).GetServerByIP(heist.ServerIP); err == nil {
+		t.Fatal("expected the bank to go offline")
+	}
+	var paidAlice, paidBob models.User
+	db.First(&paidAlice, "id = ?", alice.ID)
+	db.First(&paidBob, "id = ?", bob.ID)
+	if paidAlice.Wallet.Crypto != alice.Wallet.Crypto+1000+750 || paidBob.Wallet.Crypto != bob.Wallet.Crypto+800+450 {
+		t.Fatalf("expected loot plus 50/30 of the prize, got alice %.2f bob %.2f", paidAlice.Wallet.Crypto, paidBob.Wallet.Crypto)
+	}
+	report, err := NewLedgerService(db).Reconcile()
+	if err != nil || !report.OK() {
+		t.Fatalf("expected the ledger to reconcile, got %+v (%v)", report, err)
+	}
+
+	// The vault was funded by the event, not opened from nothing
+	var funded []models.LedgerEntry
+	db.Where("reason = ? AND reference = ? AND account LIKE ? AND amount > 0", "heist_vault", heist.ID.String(), "server:%").Find(&funded)
+	if len(funded) != 1 || funded[0].Amount != HeistVault {
+		t.Fatalf("expected the vault funded with %d crypto by the event, got %+v", HeistVault, funded)
+	}
+	var openings int64
+	db.Model(&models.LedgerEntry{}).Where("reason = ? AND account LIKE ?", "opening_balance", "server:%").Count(&openings)
+	if openings != 0 {
+		t.Fatalf("expected no opening balance for the bank, got %d", openings)
+	}
+}
+
+func TestCrackdownRaisesSecurityAndRestoresIt(t *testing.T) {
+	db := newTestDatabase(t)
+	users := NewUserService(db, "test-secret")
+	alice, _ := users.Register("alice", "correct-horse")
+	server := &models.Server{IP: "10.4.2.7", LocalIP: "192.168.1.7", SecurityLevel: 40}
+	db.Create(server)
+	hardened := &models.Server{IP: "10.4.2.9", LocalIP: "192.168.1.9", SecurityLevel: MaxSecurityLevel - 10}
+	db.Create(hardened)
+	events := NewEventService(db, nil)
+
+	crackdown, err := events.Schedule(models.EventCrackdown, time.Now(), 0, "", nil)
+	if err != nil {
+		t.Fatalf("failed to start the crackdown: %v", err)
+	}
+	db.First(server, "ip = ?", "10.4.2.7")
+	if server.SecurityLevel != 40+CrackdownBoost {
+		t.Fatalf("expected security level %d, got %d", 40+CrackdownBoost, server.SecurityLevel)
+	}
+	db.First(hardened, "ip = ?", "10.4.2.9")
+	if hardened.SecurityLevel != MaxSecurityLevel || hardened.SecurityBoost != 10 {
+		t.Fatalf("expected security level capped at %d with a boost of 10, got %d with %d", MaxSecurityLevel, hardened.SecurityLevel, hardened.SecurityBoost)
+	}
+
+	// The same service only scores once
+	events.RecordExploit(alice.ID, "alice", server, "ssh", nil)
+	events.RecordExploit(alice.ID, "alice", server, "ssh", nil)
+	if scores, _ := events.Leaderboard(crackdown.ID, 10); len(scores) != 1 || scores[0].Score != float64(40+CrackdownBoost) {
+		t.Fatalf("expected one score of %d, got %+v", 40+CrackdownBoost, scores)
+	}
+	if len(crackdown.Missions) == 0 || events.GetMission(crackdown.Missions[0].ID) == nil {
+		t.Fatal("expected the crackdown to bring a mission")
+	}
+
+	if _, err := events.End(crackdown.ID.String()); err != nil {
+		t.Fatalf("failed to end the crackdown: %v", err)
+	}
+	db.First(server, "ip = ?", "10.4.2.7")
+	if server.SecurityLevel != 40 {
+		t.Fatalf("expected security level back at 40, got %d", server.SecurityLevel)
+	}
+	db.First(hardened, "ip = ?", "10.4.2.9")
+	if hardened.SecurityLevel != MaxSecurityLevel-10 || hardened.SecurityBoost != 0 {
+		t.Fatalf("expected security level back at %d, got %d with a boost of %d", MaxSecurityLevel-10, hardened.SecurityLevel, hardened.SecurityBoost)
+	}
+	if !events.MissionExpired(crackdown.Missions[0].ID) {
+		t.Fatal("expected the crackdown's mission to expire with it")
+	}
+
+	// A crackdown whose boost was never applied doesn't take it off
+	started := time.Now()
+	unboosted := &models.LiveEvent{Kind: models.EventCrackdown, Status: models.EventActive, Boost: CrackdownBoost,
+		StartsAt: started, StartedAt: &started, EndsAt: time.Now().Add(time.Hour)}
+	db.Create(unboosted)
+	if _, err := events.End(unboosted.ID.String()); err != nil {
+		t.Fatalf("failed to end the crackdown: %v", err)
+	}
+	db.First(server, "ip = ?", "10.4.2.7")
+	if server.SecurityLevel != 40 {
+		t.Fatalf("expected security level to stay at 40, got %d", server.SecurityLevel)
+	}
+}
+
+func TestFailedEndLeavesTheEventRunning(t *testing.T) {
+	db := newTestDatabase(t)
+	server := &models.Server{IP: "10.4.2.8", LocalIP: "192.168.1.8", SecurityLevel: 40}
+	db.Create(server)
+	events := NewEventService(db, nil)
+	crackdown, err := events.Schedule(models.EventCrackdown, time.Now(), 0, "", nil)
+	if err != nil {
+		t.Fatalf("failed to start the crackdown: %v", err)
+	}
+
+	// Paying the prizes fails: nothing it undid before that sticks
+	db.Migrator().DropTable(&models.EventScore{})
+	if _, err := events.End(crackdown.ID.String()); err == nil {
+		t.Fatal("expected ending the crackdown to fail")
+	}
+	var stored models.LiveEvent
+	db.First(&stored, "id = ?", crackdown.ID)
+	db.First(server, "ip = ?", "10.4.2.8")
+	if stored.Status != models.EventActive || server.SecurityLevel != 40+CrackdownBoost {
+		t.Fatalf("expected the crackdown still on, got %s at security level %d", stored.Status, server.SecurityLevel)
+	}
+
+	// The next try ends it
+	db.AutoMigrate(&models.EventScore{})
+	if _, err := events.End(crackdown.ID.String()); err != nil {
+		t.Fatalf("failed to end the crackdown: %v", err)
+	}
+	db.First(server, "ip = ?", "10.4.2.8")
+	if server.SecurityLevel != 40 {
+		t.Fatalf("expected security level back at 40, got %d", server.SecurityLevel)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"terminal-sh/database"
+	"terminal-sh/metrics"
 	"terminal-sh/models"
 
 	"github.com/google/uuid"
@@ -15,6 +16,8 @@ type ExploitationService struct {
 	toolService      *ToolService
 	serverService    *ServerService
 	serverLogService *ServerLogService
+	notifications    *NotificationService
+	eventService     *EventService // Optional, for zero-days and event scoring
 }
 
 // NewExploitationService creates a new ExploitationService with the provided dependencies.
@@ -24,9 +27,16 @@ func NewExploitationService(db *database.Database, toolService *ToolService, ser
 		toolService:      toolService,
 		serverService:    serverService,
 		serverLogService: NewServerLogService(db),
+		notifications:    NewNotificationService(db),
 	}
 }
 
+// SetEventService sets the event service, so zero-days open services up and exploits score
+// on event leaderboards.
+func (s *ExploitationService) SetEventService(eventService *EventService) {
+	s.eventService = eventService
+}
+
 // ExploitServer attempts to exploit a server using a tool on a specific service.
 // Returns an error if the user doesn't own the tool, the service is not vulnerable, or exploitation fails.
 // sourceIP is the IP address to log as the source (the hop we're connecting from).
@@ -66,12 +76,13 @@ func (s *ExploitationService) ExploitServer(userID uuid.UUID, serverPath, toolNa
 		return fmt.Errorf("service %s not found on server", serviceName)
 	}
 
+	// A zero-day makes its vulnerability type exploitable on every service, patched or not
+	zeroDay := s.ZeroDay()
+
 	// Check if service is vulnerable
-	if !targetService.Vulnerable {
+	if !targetService.Vulnerable && zeroDay == nil {
 		// Log failed exploit attempt
-		if s.serverLogService != nil {
-			s.serverLogService.LogExploitAttempt(server.IP, sourceIP, username, &userID, toolName, serviceName, false)
-		}
+		s.recordAttempt(server.IP, sourceIP, username, userID, toolName, serviceName, false)
 		return fmt.Errorf("service %s is not vulnerable", serviceName)
 	}
 
@@ -79,14 +90,28 @@ func (s *ExploitationService) ExploitServer(userID uuid.UUID, serverPath, toolNa
 	canExploit := false
 	exploitedVulns := []models.Exploit{}
 
-	for _, vuln := range targetService.Vulnerabilities {
+	if targetService.Vulnerable {
+		for _, vuln := range targetService.Vulnerabilities {
+			if zeroDay != nil && vuln.Type == zeroDay.Type {
+				continue // Exploited through the zero-day below, whatever its level
+			}
+			for _, toolExploit := range tool.Exploits {
+				if toolExploit.Type == vuln.Type && toolExploit.Level >= vuln.Level {
+					canExploit = true
+					exploitedVulns = append(exploitedVulns, models.Exploit{
+						Type:  vuln.Type,
+						Level: vuln.Level,
+					})
+					break
+				}
+			}
+		}
+	}
+	if zeroDay != nil {
 		for _, toolExploit := range tool.Exploits {
-			if toolExploit.Type == vuln.Type && toolExploit.Level >= vuln.Level {
+			if toolExploit.Type == zeroDay.Type {
 				canExploit = true
-				exploitedVulns = append(exploitedVulns, models.Exploit{
-					Type:  vuln.Type,
-					Level: vuln.Level,
-				})
+				exploitedVulns = append(exploitedVulns, models.Exploit{Type: zeroDay.Type, Level: zeroDay.Level})
 				break
 			}
 		}
@@ -94,9 +119,7 @@ func (s *ExploitationService) ExploitServer(userID uuid.UUID, serverPath, toolNa
 
 	if !canExploit {
 		// Log failed exploit attempt
-		if s.serverLogService != nil {
-			s.serverLogService.LogExploitAttempt(server.IP, sourceIP, username, &userID, toolName, serviceName, false)
-		}
+		s.recordAttempt(server.IP, sourceIP, username, userID, toolName, serviceName, false)
 		return fmt.Errorf("tool %s cannot exploit service %s vulnerabilities", toolName, serviceName)
 	}
 
@@ -110,9 +133,8 @@ func (s *ExploitationService) ExploitServer(userID uuid.UUID, serverPath, toolNa
 			return fmt.Errorf("failed to update exploitation: %w", err)
 		}
 		// Log successful re-exploitation
-		if s.serverLogService != nil {
-			s.serverLogService.LogExploitAttempt(server.IP, sourceIP, username, &userID, toolName, serviceName, true)
-		}
+		s.recordAttempt(server.IP, sourceIP, username, userID, toolName, serviceName, true)
+		s.recordEventScore(userID, username, server, serviceName, exploitedVulns)
 		return nil
 	}
 
@@ -129,13 +151,61 @@ func (s *ExploitationService) ExploitServer(userID uuid.UUID, serverPath, toolNa
 	}
 
 	// Log successful exploit
-	if s.serverLogService != nil {
-		s.serverLogService.LogExploitAttempt(server.IP, sourceIP, username, &userID, toolName, serviceName, true)
-	}
+	s.recordAttempt(server.IP, sourceIP, username, userID, toolName, serviceName, true)
+	s.recordEventScore(userID, username, server, serviceName, exploitedVulns)
+
+	return nil
+}
 
+// ZeroDay returns the vulnerability a running zero-day adds to every service, or nil if there's
+// none. Its level is 0, so any tool with an exploit of its type can use it.
+func (s *ExploitationService) ZeroDay() *models.Vulnerability {
+	if s.eventService == nil {
+		return nil
+	}
+	if vulnType := s.eventService.ActiveZeroDay(); vulnType != "" {
+		return &models.Vulnerability{Type: vulnType}
+	}
 	return nil
 }
 
+// ZeroDayFor reports whether a zero-day is running that the user's copy of a tool can exploit.
+func (s *ExploitationService) ZeroDayFor(userID uuid.UUID, toolName string) bool {
+	zeroDay := s.ZeroDay()
+	if zeroDay == nil {
+		return false
+	}
+	tool, err := s.toolService.GetEffectiveTool(userID, toolName)
+	if err != nil {
+		return false
+	}
+	for _, exploit := range tool.Exploits {
+		if exploit.Type == zeroDay.Type {
+			return true
+		}
+	}
+	return false
+}
+
+// recordEventScore scores a successful exploit on the leaderboards of the running events.
+func (s *ExploitationService) recordEventScore(userID uuid.UUID, username string, server *models.Server, serviceName string, exploits []models.Exploit) {
+	if s.eventService != nil {
+		s.eventService.RecordExploit(userID, username, server, serviceName, exploits)
+	}
+}
+
+// recordAttempt logs an exploit attempt to the target's server log and counts it in metrics.
+// A successful one warns the other players with a foothold on the server.
+func (s *ExploitationService) recordAttempt(serverIP, sourceIP, username string, userID uuid.UUID, toolName, serviceName string, success bool) {
+	metrics.Exploits.WithLabelValues(metrics.ResultLabel(success)).Inc()
+	if s.serverLogService != nil {
+		s.serverLogService.LogExploitAttempt(serverIP, sourceIP, username, &userID, toolName, serviceName, success)
+	}
+	if success {
+		s.notifications.NotifyIntrusion(serverIP, userID, username, serviceName)
+	}
+}
+
 // GetExploitedServers retrieves all servers exploited by a user
 func (s *ExploitationService) GetExploitedServers(userID uuid.UUID) ([]models.ExploitedServer, error) {
 	var exploited []models.ExploitedServer
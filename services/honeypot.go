@@ -0,0 +1,168 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"terminal-sh/database"
+	"terminal-sh/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// HoneypotDetection is the exploit type of log_analyzer that reveals honeypot indicators.
+const HoneypotDetection = "honeypot_detection"
+
+// honeypotChance is the chance a generated server is a honeypot.
+const honeypotChance = 0.08
+
+// traceFlagPenalty is how much each trace flag an attacker already has adds to the share of
+// their crypto a honeypot seizes, up to maxConfiscateRate.
+const (
+	traceFlagPenalty  = 0.1
+	maxConfiscateRate = 0.6
+)
+
+// HoneypotService springs the trap on players who exploit a honeypot server.
+type HoneypotService struct {
+	db               *database.Database
+	serverLogService *ServerLogService
+	ledger           *LedgerService
+}
+
+// NewHoneypotService creates a new HoneypotService.
+func NewHoneypotService(db *database.Database, serverLogService *ServerLogService) *HoneypotService {
+	return &HoneypotService{db: db, serverLogService: serverLogService, ledger: NewLedgerService(db)}
+}
+
+// HoneypotReport is what a honeypot did to the player who exploited it.
+type HoneypotReport struct {
+	LoggedIP       string  // The attacker's real IP, as written to the honeypot's logs
+	Confiscated    float64 // Crypto seized from the attacker's wallet
+	Flagged        bool    // Whether the attacker was flagged for trace-back
+	TraceFlags     int     // The attacker's trace flags after this one
+	TrackerName    string  // Name of the tracking file planted on the attacker's machine
+	TrackerContent string
+}
+
+// Spring traps the player who just exploited a honeypot. Their real IP is logged on the
+// honeypot no matter how many hops they came through, part of their crypto is seized into
+// the honeypot's wallet and they may be flagged for trace-back. Players already flagged are
+// known to the trap: every earlier flag makes it seize more. The caller plants the returned
+// tracking file on the player's machine.
+func (s *HoneypotService) Spring(userID uuid.UUID, server *models.Server) (*HoneypotReport, error) {
+	if !server.IsHoneypot() {
+		return nil, fmt.Errorf("%s is not a honeypot", server.IP)
+	}
+	config := server.Honeypot
+
+	var report HoneypotReport
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.First(&user, "id = ?", userID).Error; err != nil {
+			return err
+		}
+		report.LoggedIP = user.IP
+
+		rate := min(config.ConfiscateRate+traceFlagPenalty*float64(user.TraceFlags), maxConfiscateRate)
+		report.Confiscated = math.Floor(user.Wallet.Crypto*rate*100) / 100
+		if report.Confiscated > 0 {
+			if _, err := s.ledger.TransferTx(tx, UserAccount(userID), ServerAccount(server.ID), models.CurrencyCrypto,
+				report.Confiscated, "honeypot_confiscation", server.IP); err != nil {
+				return err
+			}
+		}
+		if config.TraceBack {
+			user.TraceFlags++
+			report.Flagged = true
+		}
+		report.TraceFlags = user.TraceFlags
+		return tx.Model(&user).Select("trace_flags").Updates(&user).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to spring honeypot: %w", err)
+	}
+
+	if s.serverLogService != nil {
+		s.serverLogService.LogSystem(server.IP, fmt.Sprintf("honeypot: intrusion traced to origin %s", report.LoggedIP))
+	}
+
+	report.TrackerName = fmt.Sprintf(".sync-%s", server.ID.String()[:8])
+	report.TrackerContent = fmt.Sprintf("beacon=%s\norigin=%s\nplanted=%s\ninterval=300\n",
+		server.IP, report.LoggedIP, time.Now().UTC().Format(time.RFC3339))
+	return &report, nil
+}
+
+// HoneypotIndicators returns the signs that give a honeypot away, if detectionLevel is
+// high enough to spot them. A real server, or a honeypot beyond the detector, shows none.
+func HoneypotIndicators(server *models.Server, detectionLevel int) []string {
+	if !server.IsHoneypot() || detectionLevel < server.Honeypot.Level {
+		return nil
+	}
+	indicators := []string{"No interactive logins in the logs: only scans and exploit attempts"}
+	vulnerable := 0
+	for _, svc := range server.Services {
+		if svc.Vulnerable {
+			vulnerable++
+		}
+		if svc.Name == "ssh" {
+			indicators = append(indicators, "SSH host key matches a stock honeypot image")
+		}
+	}
+	if vulnerable > 1 && vulnerable == len(server.Services) {
+		indicators = append(indicators, fmt.Sprintf("Every service (%d) is unpatched", vulnerable))
+	}
+	if server.Wallet.Crypto > 0 {
+		indicators = append(indicators, fmt.Sprintf("Wallet of %.0f crypto is far above what security level %d guards", server.Wallet.Crypto, server.SecurityLevel))
+	}
+	return indicators
+}
+
+// maybeHoneypot turns some generated servers into honeypots: their services become easy to
+// exploit and their wallet worth the risk, and they're harder to spot at higher levels.
+// Returns true if the server became a honeypot.
+func (g *ServerGenerator) maybeHoneypot(server *models.Server, level int) bool {
+	server.Honeypot = nil
+	if g.rng.Float64() >= honeypotChance {
+		return false
+	}
+	for i := range server.Services {
+		server.Services[i].Vulnerable = true
+		server.Services[i].Level = max(1, server.Services[i].Level/2)
+		for j := range server.Services[i].Vulnerabilities {
+			server.Services[i].Vulnerabilities[j].Level = max(1, server.Services[i].Vulnerabilities[j].Level/2)
+		}
+	}
+	server.SecurityLevel = max(1, server.SecurityLevel/2)
+	server.Wallet.Crypto *= 3
+	server.Honeypot = &models.HoneypotConfig{
+		Level:          10 + getTierForLevel(level)*5,
+		ConfiscateRate: 0.1 + g.rng.Float64()*0.15,
+		TraceBack:      g.rng.Float32() < 0.5,
+	}
+	return true
+}
+
+// ExploitedSince reports whether the user has gained a foothold on a server since a given
+// time: exploited a service, cracked a credential or planted a backdoor.
+func (s *HoneypotService) ExploitedSince(userID uuid.UUID, serverPath string, since time.Time) bool {
+	var count int64
+	s.db.Model(&models.ExploitedServer{}).
+		Where("user_id = ? AND server_path = ? AND updated_at >= ?", userID, serverPath, since).
+		Count(&count)
+	if count > 0 {
+		return true
+	}
+	s.db.Model(&models.DiscoveredCredential{}).
+		Where("user_id = ? AND server_path = ? AND updated_at >= ?", userID, serverPath, since).
+		Count(&count)
+	if count > 0 {
+		return true
+	}
+	s.db.Model(&models.BackdoorAccess{}).
+		Where("user_id = ? AND server_path = ? AND created_at >= ?", userID, serverPath, since).
+		Count(&count)
+	return count > 0
+}
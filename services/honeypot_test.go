@@ -0,0 +1,83 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"terminal-sh/models"
+)
+
+func TestHoneypotTracesAndFinesAttacker(t *testing.T) {
+	db := newTestDatabase(t)
+	userService := NewUserService(db, "test-secret")
+	user, err := userService.Register("alice", "correct-horse")
+	if err != nil {
+		t.Fatalf("failed to register user: %v", err)
+	}
+	user.Wallet.Crypto = 100
+	if err := db.Model(user).Select("wallet").Updates(user).Error; err != nil {
+		t.Fatalf("failed to fund user: %v", err)
+	}
+
+	server := &models.Server{
+		IP:       "bait",
+		LocalIP:  "10.0.0.13",
+		Wallet:   models.ServerWallet{Crypto: 500},
+		Services: []models.Service{{Name: "ssh", Vulnerable: true}},
+		Honeypot: &models.HoneypotConfig{Level: 12, ConfiscateRate: 0.25, TraceBack: true},
+	}
+	if err := db.Create(server).Error; err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+
+	report, err := NewHoneypotService(db, NewServerLogService(db)).Spring(user.ID, server)
+	if err != nil {
+		t.Fatalf("failed to spring honeypot: %v", err)
+	}
+	if report.LoggedIP != user.IP || report.Confiscated != 25 || report.TraceFlags != 1 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if !strings.Contains(report.TrackerContent, "origin="+user.IP) {
+		t.Fatalf("expected the tracker to carry the attacker's IP, got %q", report.TrackerContent)
+	}
+
+	var stored models.User
+	db.First(&stored, "id = ?", user.ID)
+	var honeypot models.Server
+	db.First(&honeypot, "id = ?", server.ID)
+	if stored.Wallet.Crypto != 75 || stored.TraceFlags != 1 || honeypot.Wallet.Crypto != 525 {
+		t.Fatalf("expected 25 crypto moved to the honeypot and a trace flag, got user %+v/%d, honeypot %+v", stored.Wallet, stored.TraceFlags, honeypot.Wallet)
+	}
+
+	logs, _ := NewServerLogService(db).GetSystemLogs("bait", 10)
+	if len(logs) != 1 || !strings.Contains(logs[0].Message, user.IP) {
+		t.Fatalf("expected the attacker's real IP in the honeypot's log, got %+v", logs)
+	}
+
+	// Walking into a trap again while flagged costs more: 35% of what's left
+	report, err = NewHoneypotService(db, nil).Spring(user.ID, server)
+	if err != nil {
+		t.Fatalf("failed to spring honeypot: %v", err)
+	}
+	if report.Confiscated != 26.25 || report.TraceFlags != 2 {
+		t.Fatalf("expected 26.25 crypto seized from a flagged attacker, got %+v", report)
+	}
+}
+
+func TestHoneypotIndicatorsNeedDetectionLevel(t *testing.T) {
+	server := &models.Server{
+		IP:       "bait",
+		Services: []models.Service{{Name: "ssh", Vulnerable: true}},
+		Honeypot: &models.HoneypotConfig{Level: 20},
+	}
+	if indicators := HoneypotIndicators(server, 15); len(indicators) != 0 {
+		t.Fatalf("expected a low-level analyzer to miss the honeypot, got %v", indicators)
+	}
+	if indicators := HoneypotIndicators(server, 20); len(indicators) == 0 {
+		t.Fatal("expected indicators at the honeypot's level")
+	}
+	server.Honeypot = nil
+	if indicators := HoneypotIndicators(server, 100); len(indicators) != 0 {
+		t.Fatalf("expected no indicators on a real server, got %v", indicators)
+	}
+}
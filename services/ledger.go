@@ -0,0 +1,314 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"terminal-sh/database"
+	"terminal-sh/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrInsufficientFunds is returned when a transfer would overdraw a wallet.
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	// ErrInvalidAmount is returned for transfers of zero, negative or non-finite amounts.
+	ErrInvalidAmount = errors.New("amount must be positive")
+)
+
+// ledgerEpsilon is how far a wallet may stray from its ledger before it counts as drift,
+// to absorb float rounding over many small entries.
+const ledgerEpsilon = 1e-6
+
+// openingAccount funds the opening balance of wallets that existed before the ledger.
+const openingAccount = "system:opening"
+
+// UserAccount returns the ledger account of a user's wallet.
+func UserAccount(userID uuid.UUID) string {
+	return "user:" + userID.String()
+}
+
+// ServerAccount returns the ledger account of a server's wallet.
+func ServerAccount(serverID uuid.UUID) string {
+	return "server:" + serverID.String()
+}
+
+// SystemAccount returns a game-side account, such as "mining" or "shop". System accounts
+// have no wallet: they are where currency enters and leaves the economy.
+func SystemAccount(name string) string {
+	return "system:" + name
+}
+
+// LedgerService moves currency between wallets as double-entry transactions. Every credit
+// has a matching debit, entries are never changed once written, and the wallet balances are
+// updated in the same database transaction as the entries.
+type LedgerService struct {
+	db *database.Database
+}
+
+// NewLedgerService creates a new LedgerService.
+func NewLedgerService(db *database.Database) *LedgerService {
+	return &LedgerService{db: db}
+}
+
+// Transfer moves an amount of a currency from one account to another in its own database
+// transaction. Returns the ledger transaction ID.
+func (s *LedgerService) Transfer(from, to string, currency models.Currency, amount float64, reason, reference string) (uuid.UUID, error) {
+	var txID uuid.UUID
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		txID, err = s.TransferTx(tx, from, to, currency, amount, reason, reference)
+		return err
+	})
+	return txID, err
+}
+
+// TransferTx is Transfer inside the caller's database transaction, so the wallet changes
+// commit or roll back together with whatever else the caller writes.
+func (s *LedgerService) TransferTx(tx *gorm.DB, from, to string, currency models.Currency, amount float64, reason, reference string) (uuid.UUID, error) {
+	if amount <= 0 || math.IsNaN(amount) || math.IsInf(amount, 0) {
+		return uuid.Nil, ErrInvalidAmount
+	}
+	if from == to {
+		return uuid.Nil, fmt.Errorf("cannot transfer from %s to itself", from)
+	}
+	if currency != models.CurrencyCrypto && currency != models.CurrencyData {
+		return uuid.Nil, fmt.Errorf("unknown currency: %s", currency)
+	}
+
+	fromBalance, err := s.openAccount(tx, from, currency)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if fromBalance != nil && *fromBalance+ledgerEpsilon < amount {
+		return uuid.Nil, fmt.Errorf("%w: need %.2f %s, have %.2f", ErrInsufficientFunds, amount, currency, *fromBalance)
+	}
+	toBalance, err := s.openAccount(tx, to, currency)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	txID := uuid.New()
+	debit := models.LedgerEntry{TransactionID: txID, Account: from, Currency: currency, Amount: -amount, Reason: reason, Reference: reference}
+	credit := models.LedgerEntry{TransactionID: txID, Account: to, Currency: currency, Amount: amount, Reason: reason, Reference: reference}
+	if fromBalance != nil {
+		debit.Balance = *fromBalance - amount
+		if err := s.setBalance(tx, from, currency, debit.Balance); err != nil {
+			return uuid.Nil, err
+		}
+	}
+	if toBalance != nil {
+		credit.Balance = *toBalance + amount
+		if err := s.setBalance(tx, to, currency, credit.Balance); err != nil {
+			return uuid.Nil, err
+		}
+	}
+	if err := tx.Create(&[]models.LedgerEntry{debit, credit}).Error; err != nil {
+		return uuid.Nil, fmt.Errorf("failed to write ledger entries: %w", err)
+	}
+	return txID, nil
+}
+
+// openAccount locks the wallet behind an account and returns its balance, or nil for system
+// accounts. A wallet touched by the ledger for the first time gets an opening entry for its
+// current balance, so the ledger always sums to what the wallet holds.
+func (s *LedgerService) openAccount(tx *gorm.DB, account string, currency models.Currency) (*float64, error) {
+	if strings.HasPrefix(account, "system:") {
+		return nil, nil
+	}
+	balance, err := s.balance(tx.Clauses(clause.Locking{Strength: "UPDATE"}), account, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries int64
+	if err := tx.Model(&models.LedgerEntry{}).Where("account = ? AND currency = ?", account, currency).Count(&entries).Error; err != nil {
+		return nil, err
+	}
+	if entries == 0 && balance != 0 {
+		txID := uuid.New()
+		opening := []models.LedgerEntry{
+			{TransactionID: txID, Account: openingAccount, Currency: currency, Amount: -balance, Reason: "opening_balance"},
+			{TransactionID: txID, Account: account, Currency: currency, Amount: balance, Balance: balance, Reason: "opening_balance"},
+		}
+		if err := tx.Create(&opening).Error; err != nil {
+			return nil, fmt.Errorf("failed to open ledger account: %w", err)
+		}
+	}
+	return &balance, nil
+}
+
+// balance reads the wallet balance behind a user or server account.
+func (s *LedgerService) balance(tx *gorm.DB, account string, currency models.Currency) (float64, error) {
+	kind, id, err := parseAccount(account)
+	if err != nil {
+		return 0, err
+	}
+	switch kind {
+	case "user":
+		var user models.User
+		if err := tx.Select("id", "wallet").First(&user, "id = ?", id).Error; err != nil {
+			return 0, fmt.Errorf("wallet %s not found: %w", account, err)
+		}
+		if currency == models.CurrencyData {
+			return user.Wallet.Data, nil
+		}
+		return user.Wallet.Crypto, nil
+	default:
+		var server models.Server
+		if err := tx.Select("id", "wallet").First(&server, "id = ?", id).Error; err != nil {
+			return 0, fmt.Errorf("wallet %s not found: %w", account, err)
+		}
+		if currency == models.CurrencyData {
+			return server.Wallet.Data, nil
+		}
+		return server.Wallet.Crypto, nil
+	}
+}
+
+// setBalance writes one currency of the wallet behind a user or server account.
+func (s *LedgerService) setBalance(tx *gorm.DB, account string, currency models.Currency, amount float64) error {
+	kind, id, err := parseAccount(account)
+	if err != nil {
+		return err
+	}
+	switch kind {
+	case "user":
+		var user models.User
+		if err := tx.Select("id", "wallet").First(&user, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if currency == models.CurrencyData {
+			user.Wallet.Data = amount
+		} else {
+			user.Wallet.Crypto = amount
+		}
+		return tx.Model(&user).Select("wallet").Updates(&user).Error
+	default:
+		var server models.Server
+		if err := tx.Select("id", "wallet").First(&server, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if currency == models.CurrencyData {
+			server.Wallet.Data = amount
+		} else {
+			server.Wallet.Crypto = amount
+		}
+		return tx.Model(&server).Select("wallet").Updates(&server).Error
+	}
+}
+
+// parseAccount splits a user or server account into its kind and ID.
+func parseAccount(account string) (string, uuid.UUID, error) {
+	kind, rawID, ok := strings.Cut(account, ":")
+	if !ok || (kind != "user" && kind != "server") {
+		return "", uuid.Nil, fmt.Errorf("invalid ledger account: %s", account)
+	}
+	id, err := uuid.Parse(rawID)
+	if err != nil {
+		return "", uuid.Nil, fmt.Errorf("invalid ledger account: %s", account)
+	}
+	return kind, id, nil
+}
+
+// History returns the most recent entries of an account, newest first.
+func (s *LedgerService) History(account string, limit int) ([]models.LedgerEntry, error) {
+	var entries []models.LedgerEntry
+	err := s.db.Where("account = ?", account).Order("created_at DESC").Limit(limit).Find(&entries).Error
+	return entries, err
+}
+
+// Counterparties returns, for each entry, the account on the other side of its transaction.
+func (s *LedgerService) Counterparties(entries []models.LedgerEntry) map[uuid.UUID]string {
+	counterparties := make(map[uuid.UUID]string)
+	if len(entries) == 0 {
+		return counterparties
+	}
+	txIDs := make([]uuid.UUID, len(entries))
+	for i, entry := range entries {
+		txIDs[i] = entry.TransactionID
+	}
+	var legs []models.LedgerEntry
+	s.db.Where("transaction_id IN ?", txIDs).Find(&legs)
+	for _, entry := range entries {
+		for _, leg := range legs {
+			if leg.TransactionID == entry.TransactionID && leg.Account != entry.Account {
+				counterparties[entry.ID] = leg.Account
+			}
+		}
+	}
+	return counterparties
+}
+
+// LedgerDrift is a wallet whose balance no longer matches the sum of its ledger entries,
+// meaning it was changed outside the ledger.
+type LedgerDrift struct {
+	Account  string
+	Currency models.Currency
+	Ledger   float64
+	Wallet   float64
+}
+
+// ReconcileReport is the result of checking the ledger against itself and the wallets.
+type ReconcileReport struct {
+	Entries    int64
+	Unbalanced []uuid.UUID // Transactions whose entries don't sum to zero
+	Drift      []LedgerDrift
+	Orphaned   []string // Accounts with a balance in the ledger but no wallet behind them
+}
+
+// OK reports whether the ledger balances and every wallet matches it.
+func (r *ReconcileReport) OK() bool {
+	return len(r.Unbalanced) == 0 && len(r.Drift) == 0 && len(r.Orphaned) == 0
+}
+
+// Reconcile checks that every transaction sums to zero and that every wallet the ledger
+// knows about holds what its entries add up to.
+func (s *LedgerService) Reconcile() (*ReconcileReport, error) {
+	report := &ReconcileReport{}
+	if err := s.db.Model(&models.LedgerEntry{}).Count(&report.Entries).Error; err != nil {
+		return nil, fmt.Errorf("failed to count ledger entries: %w", err)
+	}
+
+	var unbalanced []struct {
+		TransactionID uuid.UUID
+	}
+	if err := s.db.Model(&models.LedgerEntry{}).Select("transaction_id").
+		Group("transaction_id").Having("ABS(SUM(amount)) > ?", ledgerEpsilon).
+		Scan(&unbalanced).Error; err != nil {
+		return nil, fmt.Errorf("failed to check transactions: %w", err)
+	}
+	for _, row := range unbalanced {
+		report.Unbalanced = append(report.Unbalanced, row.TransactionID)
+	}
+
+	var totals []struct {
+		Account  string
+		Currency models.Currency
+		Total    float64
+	}
+	if err := s.db.Model(&models.LedgerEntry{}).Select("account, currency, SUM(amount) AS total").
+		Where("account NOT LIKE ?", "system:%").Group("account, currency").
+		Scan(&totals).Error; err != nil {
+		return nil, fmt.Errorf("failed to sum accounts: %w", err)
+	}
+	for _, row := range totals {
+		balance, err := s.balance(s.db.DB, row.Account, row.Currency)
+		if err != nil {
+			// A wallet that was emptied through the ledger before it went away was closed properly
+			if math.Abs(row.Total) > ledgerEpsilon {
+				report.Orphaned = append(report.Orphaned, row.Account)
+			}
+			continue
+		}
+		if math.Abs(balance-row.Total) > ledgerEpsilon {
+			report.Drift = append(report.Drift, LedgerDrift{Account: row.Account, Currency: row.Currency, Ledger: row.Total, Wallet: balance})
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"terminal-sh/models"
+)
+
+func TestLedgerTransfersBalanceAndReconcile(t *testing.T) {
+	db := newTestDatabase(t)
+	user, err := NewUserService(db, "test-secret").Register("alice", "correct-horse")
+	if err != nil {
+		t.Fatalf("failed to register user: %v", err)
+	}
+	user.Wallet.Crypto = 100
+	if err := db.Model(user).Select("wallet").Updates(user).Error; err != nil {
+		t.Fatalf("failed to fund user: %v", err)
+	}
+
+	ledger := NewLedgerService(db)
+	account := UserAccount(user.ID)
+	if _, err := ledger.Transfer(SystemAccount("mining"), account, models.CurrencyCrypto, 25, "mining_reward", "10.0.0.1"); err != nil {
+		t.Fatalf("failed to credit: %v", err)
+	}
+	if _, err := ledger.Transfer(account, SystemAccount("shop"), models.CurrencyCrypto, 200, "purchase", "item"); !errors.Is(err, ErrInsufficientFunds) {
+		t.Fatalf("expected insufficient funds, got %v", err)
+	}
+	if _, err := ledger.Transfer(account, SystemAccount("shop"), models.CurrencyCrypto, 45, "purchase", "item"); err != nil {
+		t.Fatalf("failed to debit: %v", err)
+	}
+
+	history, _ := ledger.History(account, 10)
+	if len(history) != 3 || history[0].Balance != 80 || history[2].Reason != "opening_balance" {
+		t.Fatalf("expected opening balance, credit and debit ending at 80, got %+v", history)
+	}
+	var stored models.User
+	db.First(&stored, "id = ?", user.ID)
+	if stored.Wallet.Crypto != 80 {
+		t.Fatalf("expected wallet at 80, got %.2f", stored.Wallet.Crypto)
+	}
+	if err := db.Delete(&history[0]).Error; !errors.Is(err, models.ErrLedgerImmutable) {
+		t.Fatalf("expected entries to be immutable, got %v", err)
+	}
+
+	report, err := ledger.Reconcile()
+	if err != nil || !report.OK() {
+		t.Fatalf("expected a clean ledger, got %+v (%v)", report, err)
+	}
+
+	// A wallet changed behind the ledger's back shows up as drift
+	stored.Wallet.Crypto = 1000
+	db.Model(&stored).Select("wallet").Updates(&stored)
+	report, _ = ledger.Reconcile()
+	if len(report.Drift) != 1 || report.Drift[0].Ledger != 80 || report.Drift[0].Wallet != 1000 {
+		t.Fatalf("expected drift of the tampered wallet, got %+v", report)
+	}
+}
@@ -0,0 +1,148 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"terminal-sh/metrics"
+	"terminal-sh/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Login throttling policy.
+// A username or source IP is locked once it reaches its failure limit; each further failure
+// doubles the lockout up to lockoutMax. Failures older than failureWindow are forgotten.
+const (
+	maxUsernameFailures = 5
+	maxSourceIPFailures = 10
+	lockoutBase         = 30 * time.Second
+	lockoutMax          = 15 * time.Minute
+	failureWindow       = time.Hour
+)
+
+// LockoutError is returned when a username or source IP is temporarily locked after repeated failures.
+type LockoutError struct {
+	Until  time.Time
+	Source bool // Only the source IP is locked, not the username
+}
+
+// Error implements the error interface.
+func (e *LockoutError) Error() string {
+	wait := time.Until(e.Until).Round(time.Second)
+	if wait < time.Second {
+		wait = time.Second
+	}
+	return fmt.Sprintf("too many failed attempts, try again in %s", wait)
+}
+
+// throttleKeys returns the throttle keys for a login attempt.
+// The source IP key is omitted when the address is unknown.
+func throttleKeys(username, sourceIP string) []string {
+	keys := []string{"user:" + strings.ToLower(username)}
+	if sourceIP != "" {
+		keys = append(keys, "ip:"+sourceIP)
+	}
+	return keys
+}
+
+// failureLimit returns how many failures a throttle key allows before locking.
+func failureLimit(key string) int {
+	if strings.HasPrefix(key, "ip:") {
+		return maxSourceIPFailures
+	}
+	return maxUsernameFailures
+}
+
+// lockoutDuration returns the backoff for a key that has failed the given number of times.
+func lockoutDuration(failures, limit int) time.Duration {
+	if failures < limit {
+		return 0
+	}
+	d := time.Duration(float64(lockoutBase) * math.Pow(2, float64(failures-limit)))
+	if d > lockoutMax || d <= 0 {
+		d = lockoutMax
+	}
+	return d
+}
+
+// checkLockout returns a LockoutError if any of the keys is currently locked.
+func (s *UserService) checkLockout(keys []string) error {
+	var throttles []models.LoginThrottle
+	if err := s.db.Where("throttle_key IN ?", keys).Find(&throttles).Error; err != nil {
+		return fmt.Errorf("failed to check login throttle: %w", err)
+	}
+
+	var until time.Time
+	source := true
+	for _, t := range throttles {
+		if t.LockedUntil == nil || !t.LockedUntil.After(time.Now()) {
+			continue
+		}
+		if t.LockedUntil.After(until) {
+			until = *t.LockedUntil
+		}
+		if !strings.HasPrefix(t.Key, "ip:") {
+			source = false
+		}
+	}
+	if !until.IsZero() {
+		return &LockoutError{Until: until, Source: source}
+	}
+	return nil
+}
+
+// observeLockout records a login attempt refused by checkLockout.
+func observeLockout(err error) {
+	var lockout *LockoutError
+	if !errors.As(err, &lockout) {
+		return
+	}
+	if lockout.Source {
+		metrics.ObserveLoginFailure(metrics.ReasonThrottled)
+	} else {
+		metrics.ObserveLoginFailure(metrics.ReasonLockedOut)
+	}
+}
+
+// recordLoginFailure counts a failed attempt against every key and locks keys that reach their limit.
+func (s *UserService) recordLoginFailure(keys []string) {
+	now := time.Now()
+	for _, key := range keys {
+		_ = s.db.Transaction(func(tx *gorm.DB) error {
+			var t models.LoginThrottle
+			err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("throttle_key = ?", key).First(&t).Error
+			if err != nil && err != gorm.ErrRecordNotFound {
+				return err
+			}
+			if err == gorm.ErrRecordNotFound {
+				t = models.LoginThrottle{Key: key}
+			}
+
+			// Forget old failures
+			if now.Sub(t.LastFailureAt) > failureWindow {
+				t.Failures = 0
+				t.LockedUntil = nil
+			}
+
+			t.Failures++
+			t.LastFailureAt = now
+			if d := lockoutDuration(t.Failures, failureLimit(key)); d > 0 {
+				until := now.Add(d)
+				t.LockedUntil = &until
+			}
+
+			return tx.Save(&t).Error
+		})
+	}
+}
+
+// clearLoginFailures resets the username's failure count after a successful login.
+// Source IP failures are left to expire so one valid account can't unlock an IP guessing others.
+func (s *UserService) clearLoginFailures(username string) {
+	s.db.Where("throttle_key = ?", "user:"+strings.ToLower(username)).Delete(&models.LoginThrottle{})
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"terminal-sh/metrics"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestLoginLocksUsernameAfterRepeatedFailures(t *testing.T) {
+	db := newTestDatabase(t)
+	userService := NewUserService(db, "test-secret")
+
+	if _, err := userService.Register("alice", "correct-horse"); err != nil {
+		t.Fatalf("failed to register user: %v", err)
+	}
+
+	for i := 0; i < maxUsernameFailures; i++ {
+		if _, _, err := userService.Login("alice", "wrong", "", ""); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("attempt %d: expected invalid credentials, got %v", i+1, err)
+		}
+	}
+
+	var lockout *LockoutError
+	if _, _, err := userService.Login("alice", "correct-horse", "", ""); !errors.As(err, &lockout) {
+		t.Fatalf("expected lockout after %d failures, got %v", maxUsernameFailures, err)
+	}
+}
+
+func TestLoginWithResetTokenRequiresNewPassword(t *testing.T) {
+	db := newTestDatabase(t)
+	userService := NewUserService(db, "test-secret")
+
+	admin, err := userService.Register("admin", "admin-pass")
+	if err != nil {
+		t.Fatalf("failed to register admin: %v", err)
+	}
+	if _, err := userService.SetAdmin("admin", true); err != nil {
+		t.Fatalf("failed to grant admin: %v", err)
+	}
+	if _, err := userService.Register("bob", "forgotten"); err != nil {
+		t.Fatalf("failed to register user: %v", err)
+	}
+
+	token, _, err := userService.IssuePasswordResetToken(admin, "bob")
+	if err != nil {
+		t.Fatalf("failed to issue reset token: %v", err)
+	}
+
+	bob, _, err := userService.Login("bob", token, "", "")
+	if !errors.Is(err, ErrPasswordResetRequired) {
+		t.Fatalf("expected reset required, got %v", err)
+	}
+
+	// The token isn't used up by a new password that's rejected
+	if _, _, err := userService.ResetPassword(bob.ID, token, "short", "", ""); err == nil {
+		t.Fatal("expected short password to be rejected")
+	}
+
+	// Two-factor is still required once the new password is set
+	if err := db.Model(bob).Update("totp_enabled", true).Error; err != nil {
+		t.Fatalf("failed to enable TOTP: %v", err)
+	}
+	if _, _, err := userService.ResetPassword(bob.ID, token, "new-password", "", ""); !errors.Is(err, ErrTOTPRequired) {
+		t.Fatalf("expected TOTP required after reset, got %v", err)
+	}
+
+	if _, _, err := userService.Login("bob", token, "", ""); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected reused token to be rejected, got %v", err)
+	}
+	if _, _, err := userService.ResetPassword(bob.ID, token, "other-password", "", ""); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected reused token to be rejected, got %v", err)
+	}
+	if _, _, err := userService.Login("bob", "new-password", "", ""); !errors.Is(err, ErrTOTPRequired) {
+		t.Fatalf("expected new password to be accepted, got %v", err)
+	}
+}
+
+func TestEveryLoginFailureIsCounted(t *testing.T) {
+	db := newTestDatabase(t)
+	userService := NewUserService(db, "test-secret")
+	if _, err := userService.Register("alice", "correct-horse"); err != nil {
+		t.Fatalf("failed to register user: %v", err)
+	}
+	failures := func(reason string) float64 {
+		return testutil.ToFloat64(metrics.LoginFailures.WithLabelValues(reason))
+	}
+	before := map[string]float64{}
+	for _, reason := range []string{metrics.ReasonUnknownUser, metrics.ReasonBadPassword, metrics.ReasonLockedOut, metrics.ReasonThrottled} {
+		before[reason] = failures(reason)
+	}
+	total := testutil.ToFloat64(metrics.Logins.WithLabelValues(metrics.ResultFailure))
+
+	// Guessing usernames from one address gets the address throttled
+	for i := 0; i < maxSourceIPFailures; i++ {
+		userService.Login(fmt.Sprintf("nobody%d", i), "guess", "198.51.100.7", "")
+	}
+	userService.Login("alice", "correct-horse", "198.51.100.7", "")
+
+	// Guessing alice's password from anywhere locks her username
+	for i := 0; i < maxUsernameFailures; i++ {
+		userService.Login("alice", "wrong", "", "")
+	}
+	userService.Login("alice", "correct-horse", "", "")
+
+	want := map[string]float64{
+		metrics.ReasonUnknownUser: maxSourceIPFailures,
+		metrics.ReasonThrottled:   1,
+		metrics.ReasonBadPassword: maxUsernameFailures,
+		metrics.ReasonLockedOut:   1,
+	}
+	for reason, n := range want {
+		if got := failures(reason) - before[reason]; got != n {
+			t.Errorf("expected %.0f %s failures, got %.0f", n, reason, got)
+		}
+	}
+	if got := testutil.ToFloat64(metrics.Logins.WithLabelValues(metrics.ResultFailure)) - total; got != maxSourceIPFailures+maxUsernameFailures+2 {
+		t.Errorf("expected every failure in logins_total, got %.0f", got)
+	}
+}
+
+func TestAdminRightsBelongToTheAccount(t *testing.T) {
+	db := newTestDatabase(t)
+	userService := NewUserService(db, "test-secret")
+	admin, _ := userService.Register("admin", "admin-pass")
+	mallory, _ := userService.Register("mallory", "password1")
+	if _, err := userService.SetAdmin("admin", true); err != nil {
+		t.Fatalf("failed to grant admin: %v", err)
+	}
+
+	// Renaming keeps the rights, and taking the old name doesn't hand them over
+	if err := userService.UpdateUsername(admin.ID, "operator"); err != nil {
+		t.Fatalf("failed to rename admin: %v", err)
+	}
+	if err := userService.UpdateUsername(mallory.ID, "admin"); err != nil {
+		t.Fatalf("failed to rename user: %v", err)
+	}
+	if !userService.IsAdmin(admin) {
+		t.Fatal("expected the renamed admin to keep admin rights")
+	}
+	if userService.IsAdmin(mallory) {
+		t.Fatal("expected the admin's old name not to make its new owner an admin")
+	}
+	if _, _, err := userService.IssuePasswordResetToken(mallory, "operator"); !errors.Is(err, ErrNotAdmin) {
+		t.Fatalf("expected reset tokens to need admin rights, got %v", err)
+	}
+
+	// A revoke applies to the session that's already logged in
+	if _, err := userService.SetAdmin("operator", false); err != nil {
+		t.Fatalf("failed to revoke admin: %v", err)
+	}
+	if userService.IsAdmin(admin) {
+		t.Fatal("expected revoked admin rights to take effect straight away")
+	}
+}
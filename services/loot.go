@@ -0,0 +1,169 @@
+package services
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+	"math/rand"
+	"strings"
+
+	"terminal-sh/filesystem"
+	"terminal-sh/models"
+)
+
+// WeakPasswords is the wordlist hash_cracker tries. Loot protected by one of these can be cracked;
+// anything else needs the key to be found on the server.
+var WeakPasswords = []string{
+	"password", "123456", "password123", "qwerty", "letmein", "admin123", "welcome1",
+	"monkey", "dragon", "iloveyou", "sunshine", "trustno1", "backup2024", "summer2025",
+	"changeme", "hunter2", "P@ssw0rd", "master", "shadow", "football",
+}
+
+// MD5Hex returns the hex MD5 of s, the hash format used in generated database dumps.
+func MD5Hex(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// generateLoot adds archives and encrypted files to a generated filesystem. Higher levels get
+// more layers: tarballs from tier 2, zipped web backups with password hashes from tier 4,
+// encrypted secrets from tier 5 (with a crackable key below tier 7, a key hidden elsewhere above)
+// and an encrypted archive in /root from tier 8.
+func (g *ServerGenerator) generateLoot(fs map[string]interface{}, level int, roles []models.Role) {
+	tier := getTierForLevel(level)
+	home := lootDir(fs, "home")
+	user, admin := lootOwners(roles)
+
+	if tier >= 2 && user != "" {
+		archive, err := filesystem.CreateTar([]filesystem.ArchiveEntry{
+			{Name: "backup", IsDir: true},
+			{Name: "backup/notes.txt", Content: "Moved my old notes here before the migration.\nVPN: vpn." + lootDomain(g.rng) + "\n"},
+			{Name: "backup/.bash_history", Content: "ssh " + admin + "@localhost\nmysql -u root -p\n"},
+		})
+		if err == nil {
+			lootDir(home, user)["backup.tar.gz"] = lootFile(archive)
+		}
+	}
+
+	if tier >= 4 {
+		var htpasswd strings.Builder
+		for _, name := range []string{admin, "webmaster", "deploy"} {
+			if name != "" {
+				fmt.Fprintf(&htpasswd, "%s:%s\n", name, MD5Hex(WeakPasswords[g.rng.Intn(len(WeakPasswords))]))
+			}
+		}
+		archive, err := filesystem.CreateZip([]filesystem.ArchiveEntry{
+			{Name: "www/config.php", Content: fmt.Sprintf("<?php\n$db_user = 'web';\n$db_pass = '%s';\n", WeakPasswords[g.rng.Intn(len(WeakPasswords))])},
+			{Name: "www/.htpasswd", Content: htpasswd.String()},
+		})
+		if err == nil {
+			lootDir(lootDir(fs, "var"), "backups")["site.zip"] = lootFile(archive)
+		}
+	}
+
+	if tier >= 5 && admin != "" {
+		key, hint := WeakPasswords[g.rng.Intn(len(WeakPasswords))], "the usual"
+		if tier >= 7 {
+			// Too strong to crack - it's left lying around in the shell history instead
+			key, hint = fmt.Sprintf("vault-%06d", g.rng.Intn(1000000)), "check the history"
+			dir := lootDir(home, admin)
+			dir[".bash_history"] = lootFile("openssl enc -aes-256-gcm -in secrets.txt -out secrets.txt.enc -k " + key + "\nrm secrets.txt\n")
+		}
+		secret := fmt.Sprintf("=== SECRETS ===\nroot password: %s\nbackup server: %d.%d.%d.%d\n",
+			WeakPasswords[g.rng.Intn(len(WeakPasswords))], 10, g.rng.Intn(255), g.rng.Intn(255), g.rng.Intn(254)+1)
+		if encrypted, err := filesystem.Encrypt(secret, key, hint); err == nil {
+			lootDir(home, admin)["secrets.txt.enc"] = lootFile(encrypted)
+		}
+	}
+
+	if tier >= 8 {
+		key := fmt.Sprintf("%x", g.rng.Int63())
+		archive, err := filesystem.CreateTar([]filesystem.ArchiveEntry{
+			{Name: "vault/wallet.txt", Content: fmt.Sprintf("Cold wallet seed: %s\n", strings.Join(lootWords(g.rng, 12), " "))},
+			{Name: "vault/contacts.txt", Content: "fence: irc.darkmarket\n"},
+		})
+		if err != nil {
+			return
+		}
+		encrypted, err := filesystem.Encrypt(archive, key, "")
+		if err != nil {
+			return
+		}
+		root := lootDir(fs, "root")
+		root["vault.tar.gz.enc"] = lootFile(encrypted)
+		root[".vault_key"] = lootFile(key + "\n")
+	}
+}
+
+// GenerateDatabaseDump returns a SQL dump of a server's database, as stolen by database_dumper.
+// Password hashes in it are MD5s of weak passwords, so hash_cracker can recover them.
+func GenerateDatabaseDump(server *models.Server) string {
+	// Seeded by the server so dumping it twice gives the same data
+	rng := rand.New(rand.NewSource(int64(crc32.ChecksumIEEE([]byte(server.IP)))))
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "-- MySQL dump 10.13  Host: %s    Database: production\n", server.IP)
+	b.WriteString("CREATE TABLE users (id INT PRIMARY KEY, username VARCHAR(64), password_md5 CHAR(32));\n")
+	id := 1
+	for _, role := range server.Roles {
+		fmt.Fprintf(&b, "INSERT INTO users VALUES (%d,'%s','%s');\n", id, role.Role, MD5Hex(WeakPasswords[rng.Intn(len(WeakPasswords))]))
+		id++
+	}
+	b.WriteString("CREATE TABLE customers (id INT PRIMARY KEY, email VARCHAR(128), card_last4 CHAR(4));\n")
+	for i := 0; i < 5; i++ {
+		fmt.Fprintf(&b, "INSERT INTO customers VALUES (%d,'%s@%s','%04d');\n", i+1, lootWords(rng, 1)[0], lootDomain(rng), rng.Intn(10000))
+	}
+	return b.String()
+}
+
+// lootOwners picks the roles whose home directories hold loot: a regular user and an admin
+// (falling back to any non-root role).
+func lootOwners(roles []models.Role) (user, admin string) {
+	for _, role := range roles {
+		switch {
+		case role.Role == "root":
+			// root's loot goes in /root
+		case role.GetRoleType() == models.RoleTypeAdmin && admin == "":
+			admin = role.Role
+		case user == "":
+			user = role.Role
+		}
+	}
+	if admin == "" {
+		admin = user
+	}
+	return user, admin
+}
+
+// lootDir returns the directory map called name in parent, creating it if needed.
+func lootDir(parent map[string]interface{}, name string) map[string]interface{} {
+	dir, ok := parent[name].(map[string]interface{})
+	if !ok {
+		dir = map[string]interface{}{}
+		parent[name] = dir
+	}
+	return dir
+}
+
+// lootFile returns a file entry for a generated filesystem.
+func lootFile(content string) map[string]interface{} {
+	return map[string]interface{}{"content": content}
+}
+
+var lootWordList = []string{"amber", "falcon", "copper", "river", "lantern", "orbit", "cedar", "pixel",
+	"harbor", "velvet", "summit", "ember", "quartz", "willow", "signal", "meadow"}
+
+// lootWords returns n random words.
+func lootWords(rng *rand.Rand, n int) []string {
+	words := make([]string, n)
+	for i := range words {
+		words[i] = lootWordList[rng.Intn(len(lootWordList))]
+	}
+	return words
+}
+
+// lootDomain returns a random company domain.
+func lootDomain(rng *rand.Rand) string {
+	return lootWords(rng, 1)[0] + "-corp.net"
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"terminal-sh/filesystem"
+	"terminal-sh/models"
+)
+
+func TestGeneratedLootCanBeOpened(t *testing.T) {
+	g := &ServerGenerator{rng: rand.New(rand.NewSource(1))}
+	fs := g.generateFileSystem(8, g.generateRolesForLevel(8))
+	reader := filesystem.NewMapFileReader(fs)
+
+	// The admin's secrets need the key left in their shell history
+	history, err := reader.ReadFile("/home/admin/.bash_history")
+	if err != nil {
+		t.Fatalf("expected admin history, got %v", err)
+	}
+	key := history[strings.Index(history, "-k ")+3 : strings.Index(history, "\nrm")]
+	secrets, err := reader.ReadFile("/home/admin/secrets.txt.enc")
+	if err != nil {
+		t.Fatalf("expected encrypted secrets, got %v", err)
+	}
+	if _, err := filesystem.Decrypt(secrets, "password"); err != filesystem.ErrWrongKey {
+		t.Fatalf("expected wrong key error, got %v", err)
+	}
+	if plain, err := filesystem.Decrypt(secrets, key); err != nil || !strings.Contains(plain, "root password") {
+		t.Fatalf("expected secrets to decrypt, got %q (%v)", plain, err)
+	}
+
+	// The vault is an encrypted tarball whose key sits next to it
+	vault, _ := reader.ReadFile("/root/vault.tar.gz.enc")
+	vaultKey, _ := reader.ReadFile("/root/.vault_key")
+	archive, err := filesystem.Decrypt(vault, strings.TrimSpace(vaultKey))
+	if err != nil {
+		t.Fatalf("failed to decrypt vault: %v", err)
+	}
+	entries, err := filesystem.ReadArchive(archive)
+	if err != nil || len(entries) == 0 {
+		t.Fatalf("expected vault entries, got %v (%v)", entries, err)
+	}
+
+	// Hashes in the zipped web backup come from the wordlist
+	site, _ := reader.ReadFile("/var/backups/site.zip")
+	entries, err = filesystem.ReadArchive(site)
+	if err != nil {
+		t.Fatalf("failed to read site backup: %v", err)
+	}
+	known := make(map[string]bool)
+	for _, password := range WeakPasswords {
+		known[MD5Hex(password)] = true
+	}
+	for _, entry := range entries {
+		if entry.Name != "www/.htpasswd" {
+			continue
+		}
+		for _, line := range strings.Fields(entry.Content) {
+			if !known[line[strings.Index(line, ":")+1:]] {
+				t.Fatalf("expected crackable hash, got %q", line)
+			}
+		}
+	}
+}
+
+func TestExtractArchiveStaysInCurrentDirectory(t *testing.T) {
+	archive, err := filesystem.CreateTar([]filesystem.ArchiveEntry{
+		{Name: "../../etc/evil", Content: "x"},
+		{Name: "docs/readme.txt", Content: "hello"},
+	})
+	if err != nil {
+		t.Fatalf("failed to create tar: %v", err)
+	}
+	compressed, err := filesystem.Compress(archive)
+	if err != nil {
+		t.Fatalf("failed to compress: %v", err)
+	}
+
+	vfs := filesystem.NewVFS("alice")
+	if err := vfs.EnsureDirectoryAndCreateFile("/home/alice", "backup.tar.gz.gz", compressed); err != nil {
+		t.Fatalf("failed to create archive file: %v", err)
+	}
+	name, err := vfs.DecompressFile("backup.tar.gz.gz")
+	if err != nil || name != "backup.tar.gz" {
+		t.Fatalf("gunzip failed: %q (%v)", name, err)
+	}
+	if _, err := vfs.ExtractArchive(name); err != nil {
+		t.Fatalf("extract failed: %v", err)
+	}
+	if content, err := vfs.ReadFile("docs/readme.txt"); err != nil || content != "hello" {
+		t.Fatalf("expected extracted file, got %q (%v)", content, err)
+	}
+	if _, err := vfs.ReadFile("etc/evil"); err != nil {
+		t.Fatalf("expected ../ entry to land in the current directory, got %v", err)
+	}
+}
+
+func TestDatabaseDumpHashesAreCrackable(t *testing.T) {
+	server := &models.Server{IP: "10.0.0.5", Roles: []models.Role{{Role: "admin"}, {Role: "user"}}}
+	dump := GenerateDatabaseDump(server)
+	if dump != GenerateDatabaseDump(server) {
+		t.Fatal("expected the same dump for the same server")
+	}
+
+	known := make(map[string]bool)
+	for _, password := range WeakPasswords {
+		known[MD5Hex(password)] = true
+	}
+	cracked := 0
+	for _, line := range strings.Split(dump, "\n") {
+		if !strings.HasPrefix(line, "INSERT INTO users") {
+			continue
+		}
+		hash := line[strings.LastIndex(line, ",'")+2 : strings.LastIndex(line, "'")]
+		if known[hash] {
+			cracked++
+		}
+	}
+	if cracked != len(server.Roles) {
+		t.Fatalf("expected %d crackable hashes, got %d", len(server.Roles), cracked)
+	}
+}
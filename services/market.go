@@ -0,0 +1,219 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"terminal-sh/database"
+	"terminal-sh/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrOrderNotFound is returned when a market order doesn't exist or isn't the user's.
+var ErrOrderNotFound = errors.New("order not found")
+
+// marketEscrow is the account holding the currency that open orders could spend.
+var marketEscrow = SystemAccount("market")
+
+// MarketFill is one match between a new order and one resting on the book.
+type MarketFill struct {
+	Quantity float64 // Data traded
+	Price    float64 // Crypto per unit of data: the resting order's price
+	BuyerID  uuid.UUID
+	SellerID uuid.UUID
+}
+
+// MarketService runs the market's order book, where players trade data for crypto. Orders
+// match on price, then time, and each fill settles in the same database transaction as the
+// order that triggered it.
+type MarketService struct {
+	db     *database.Database
+	ledger *LedgerService
+}
+
+// NewMarketService creates a new MarketService.
+func NewMarketService(db *database.Database) *MarketService {
+	return &MarketService{db: db, ledger: NewLedgerService(db)}
+}
+
+// GetMarketShop returns the shop of the market, whose server players connect to to trade.
+func (s *MarketService) GetMarketShop() (*models.Shop, error) {
+	var shop models.Shop
+	if err := s.db.Where("shop_type = ?", models.ShopTypeMarket).First(&shop).Error; err != nil {
+		return nil, err
+	}
+	return &shop, nil
+}
+
+// PlaceOrder puts an order on the book: a buy order escrows quantity*price crypto, a sell
+// order escrows quantity data. It then fills against the best-priced resting orders on the
+// other side, oldest first, at their prices. Whatever doesn't fill stays on the book.
+func (s *MarketService) PlaceOrder(userID uuid.UUID, side models.OrderSide, quantity, price float64) (*models.MarketOrder, []MarketFill, error) {
+	if quantity <= 0 || price <= 0 || math.IsInf(quantity*price, 0) || math.IsNaN(quantity*price) {
+		return nil, nil, ErrInvalidAmount
+	}
+	if side != models.OrderBuy && side != models.OrderSell {
+		return nil, nil, fmt.Errorf("unknown order side: %s", side)
+	}
+
+	order := &models.MarketOrder{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Side:      side,
+		Quantity:  quantity,
+		Remaining: quantity,
+		Price:     price,
+		Status:    models.OrderOpen,
+	}
+	var fills []MarketFill
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if side == models.OrderBuy {
+			_, err = s.ledger.TransferTx(tx, UserAccount(userID), marketEscrow, models.CurrencyCrypto, quantity*price, "order_escrow", order.ID.String())
+		} else {
+			_, err = s.ledger.TransferTx(tx, UserAccount(userID), marketEscrow, models.CurrencyData, quantity, "order_escrow", order.ID.String())
+		}
+		if err != nil {
+			return err
+		}
+		if err := tx.Create(order).Error; err != nil {
+			return err
+		}
+		fills, err = s.match(tx, order)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return order, fills, nil
+}
+
+// match fills an incoming order against the resting orders it crosses.
+func (s *MarketService) match(tx *gorm.DB, order *models.MarketOrder) ([]MarketFill, error) {
+	query := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("status = ? AND user_id <> ?", models.OrderOpen, order.UserID)
+	if order.Side == models.OrderBuy {
+		query = query.Where("side = ? AND price <= ?", models.OrderSell, order.Price).Order("price ASC, created_at ASC")
+	} else {
+		query = query.Where("side = ? AND price >= ?", models.OrderBuy, order.Price).Order("price DESC, created_at ASC")
+	}
+	var resting []models.MarketOrder
+	if err := query.Find(&resting).Error; err != nil {
+		return nil, err
+	}
+
+	shopID := marketShopID(tx)
+	var fills []MarketFill
+	for i := range resting {
+		if order.Remaining <= ledgerEpsilon {
+			break
+		}
+		other := &resting[i]
+		buy, sell := order, other
+		if order.Side == models.OrderSell {
+			buy, sell = other, order
+		}
+		fill := MarketFill{Quantity: math.Min(order.Remaining, other.Remaining), Price: other.Price, BuyerID: buy.UserID, SellerID: sell.UserID}
+		reference := fmt.Sprintf("%s/%s", buy.ID, sell.ID)
+
+		if _, err := s.ledger.TransferTx(tx, marketEscrow, UserAccount(buy.UserID), models.CurrencyData, fill.Quantity, "market_fill", reference); err != nil {
+			return nil, err
+		}
+		if _, err := s.ledger.TransferTx(tx, marketEscrow, UserAccount(sell.UserID), models.CurrencyCrypto, fill.Quantity*fill.Price, "market_fill", reference); err != nil {
+			return nil, err
+		}
+		// A buyer who bid above the fill price gets the difference back from escrow
+		if refund := fill.Quantity * (buy.Price - fill.Price); refund > ledgerEpsilon {
+			if _, err := s.ledger.TransferTx(tx, marketEscrow, UserAccount(buy.UserID), models.CurrencyCrypto, refund, "order_refund", buy.ID.String()); err != nil {
+				return nil, err
+			}
+		}
+
+		for _, o := range []*models.MarketOrder{order, other} {
+			o.Remaining -= fill.Quantity
+			if o.Remaining <= ledgerEpsilon {
+				o.Remaining = 0
+				o.Status = models.OrderFilled
+			}
+			if err := tx.Model(o).Select("remaining", "status").Updates(o).Error; err != nil {
+				return nil, err
+			}
+		}
+
+		purchase := &models.UserPurchase{
+			UserID:      buy.UserID,
+			ShopID:      shopID,
+			ItemID:      sell.ID,
+			ItemName:    fmt.Sprintf("%.2f data", fill.Quantity),
+			ItemType:    models.ItemTypeData,
+			PriceCrypto: fill.Quantity * fill.Price,
+		}
+		if err := tx.Create(purchase).Error; err != nil {
+			return nil, fmt.Errorf("failed to record purchase: %w", err)
+		}
+		fills = append(fills, fill)
+	}
+	return fills, nil
+}
+
+// CancelOrder takes a user's open order off the book and refunds what it still had in escrow.
+func (s *MarketService) CancelOrder(userID uuid.UUID, orderID string) (*models.MarketOrder, error) {
+	var order models.MarketOrder
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var orders []models.MarketOrder
+		if len(orderID) < minIDPrefix {
+			return ErrOrderNotFound
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where(`id LIKE ? ESCAPE '\' AND user_id = ? AND status = ?`, idPrefixPattern(orderID), userID, models.OrderOpen).
+			Limit(2).Find(&orders).Error; err != nil {
+			return err
+		}
+		switch len(orders) {
+		case 0:
+			return ErrOrderNotFound
+		case 1:
+		default:
+			return ErrAmbiguousID
+		}
+		order = orders[0]
+
+		var err error
+		if order.Side == models.OrderBuy {
+			_, err = s.ledger.TransferTx(tx, marketEscrow, UserAccount(userID), models.CurrencyCrypto, order.Remaining*order.Price, "order_refund", order.ID.String())
+		} else {
+			_, err = s.ledger.TransferTx(tx, marketEscrow, UserAccount(userID), models.CurrencyData, order.Remaining, "order_refund", order.ID.String())
+		}
+		if err != nil {
+			return err
+		}
+		order.Status = models.OrderCancelled
+		return tx.Model(&order).Select("status").Updates(&order).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// GetOrderBook returns the open orders on each side, best price first.
+func (s *MarketService) GetOrderBook(depth int) (bids, asks []models.MarketOrder, err error) {
+	if err = s.db.Where("status = ? AND side = ?", models.OrderOpen, models.OrderBuy).
+		Order("price DESC, created_at ASC").Limit(depth).Find(&bids).Error; err != nil {
+		return nil, nil, err
+	}
+	err = s.db.Where("status = ? AND side = ?", models.OrderOpen, models.OrderSell).
+		Order("price ASC, created_at ASC").Limit(depth).Find(&asks).Error
+	return bids, asks, err
+}
+
+// GetUserOrders returns a user's open orders, oldest first.
+func (s *MarketService) GetUserOrders(userID uuid.UUID) ([]models.MarketOrder, error) {
+	var orders []models.MarketOrder
+	err := s.db.Where("user_id = ? AND status = ?", userID, models.OrderOpen).Order("created_at").Find(&orders).Error
+	return orders, err
+}
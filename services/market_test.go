@@ -0,0 +1,51 @@
+package services
+
+import (
+	"math"
+	"testing"
+
+	"terminal-sh/models"
+)
+
+func TestMarketOrdersMatchAndSettle(t *testing.T) {
+	db := newTestDatabase(t)
+	userService := NewUserService(db, "test-secret")
+	seller, _ := userService.Register("alice", "correct-horse")
+	buyer, _ := userService.Register("bob", "battery-staple")
+	seller.Wallet = models.Wallet{Data: 1000}
+	buyer.Wallet = models.Wallet{Crypto: 500}
+	db.Model(seller).Select("wallet").Updates(seller)
+	db.Model(buyer).Select("wallet").Updates(buyer)
+
+	market := NewMarketService(db)
+	if _, fills, err := market.PlaceOrder(seller.ID, models.OrderSell, 400, 0.5); err != nil || len(fills) != 0 {
+		t.Fatalf("expected the sell order to rest on the book, got %v fills (%v)", fills, err)
+	}
+	// Bidding above the ask fills at the ask, the rest stays on the book
+	order, fills, err := market.PlaceOrder(buyer.ID, models.OrderBuy, 600, 0.6)
+	if err != nil {
+		t.Fatalf("failed to place buy order: %v", err)
+	}
+	if len(fills) != 1 || fills[0].Quantity != 400 || fills[0].Price != 0.5 || order.Remaining != 200 {
+		t.Fatalf("expected 400 data filled at 0.5, got %+v, %.2f left", fills, order.Remaining)
+	}
+
+	var s, b models.User
+	db.First(&s, "id = ?", seller.ID)
+	db.First(&b, "id = ?", buyer.ID)
+	// Buyer escrowed 360 and got 40 back from the fill at 0.5; 120 stays in escrow
+	if s.Wallet.Crypto != 200 || s.Wallet.Data != 600 || b.Wallet.Data != 400 || math.Abs(b.Wallet.Crypto-180) > 1e-9 {
+		t.Fatalf("unexpected wallets: seller %+v, buyer %+v", s.Wallet, b.Wallet)
+	}
+
+	if _, err := market.CancelOrder(buyer.ID, order.ID.String()[:8]); err != nil {
+		t.Fatalf("failed to cancel: %v", err)
+	}
+	db.First(&b, "id = ?", buyer.ID)
+	if math.Abs(b.Wallet.Crypto-500+200) > 1e-9 {
+		t.Fatalf("expected the unfilled escrow refunded, buyer has %.2f", b.Wallet.Crypto)
+	}
+	if report, _ := NewLedgerService(db).Reconcile(); !report.OK() {
+		t.Fatalf("expected the ledger to reconcile, got %+v", report)
+	}
+}
@@ -1,20 +1,64 @@
 package services
 
 import (
+	"errors"
 	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
 	"time"
 
 	"terminal-sh/database"
 	"terminal-sh/models"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
+const (
+	// MiningRate is the crypto an hour one MH/s earns while the network is under its target hashrate.
+	MiningRate = 0.5
+	// TargetNetworkHashrate is the total hashrate above which the difficulty starts to rise, so the
+	// crypto mined across the whole network each hour stays the same however many miners join.
+	TargetNetworkHashrate = 100.0
+	// SoloBlockReward is the size of a block. Solo miners are only paid in whole blocks; what they
+	// earn towards the next one is lost if the miner stops or is killed.
+	SoloBlockReward = 5.0
+	// MaxMinerEfficiency caps how much patching crypto_miner can raise its hashrate.
+	MaxMinerEfficiency = 2.0
+	// MinerDetectionRate is how often an hour the admin of a level 10 server notices an unpatched miner.
+	MinerDetectionRate = 0.02
+	// MinerProcess is the name a miner runs under in its host's process list.
+	MinerProcess = "xmrig"
+	// MiningPayoutInterval is how often the background scheduler pays the miners.
+	MiningPayoutInterval = 5 * time.Minute
+)
+
+// errMinerSettled is returned when a miner was paid or stopped while it was being settled.
+var errMinerSettled = errors.New("miner already settled")
+
+// NetworkStats is a snapshot of mining across the whole network.
+type NetworkStats struct {
+	Miners     int64
+	Hashrate   float64 // Total MH/s
+	Difficulty float64 // Divides every miner's earnings; 1 until the network passes its target hashrate
+}
+
+// MinerKill is a miner that the admin of its host found and killed.
+type MinerKill struct {
+	Miner models.ActiveMiner
+	Lost  float64 // Solo earnings short of a block that went with it
+}
+
 // MiningService handles cryptocurrency mining operations on exploited servers.
 type MiningService struct {
 	db            *database.Database
 	toolService   *ToolService
 	serverService *ServerService
+	ledger        *LedgerService
+	serverLogs    *ServerLogService
+	notifications *NotificationService
+	chance        func() float64 // Rolls for the admins' checks; replaced in tests
 }
 
 // NewMiningService creates a new MiningService with the provided dependencies.
@@ -23,6 +67,10 @@ func NewMiningService(db *database.Database, toolService *ToolService, serverSer
 		db:            db,
 		toolService:   toolService,
 		serverService: serverService,
+		ledger:        NewLedgerService(db),
+		serverLogs:    NewServerLogService(db),
+		notifications: NewNotificationService(db),
+		chance:        rand.Float64,
 	}
 }
 
@@ -41,7 +89,8 @@ func (s *MiningService) StartMining(userID uuid.UUID, serverIP string) error {
 	}
 
 	// Check if server exists
-	if _, err := s.serverService.GetServerByIP(serverIP); err != nil {
+	server, err := s.serverService.GetServerByIP(serverIP)
+	if err != nil {
 		return fmt.Errorf("server not found: %w", err)
 	}
 
@@ -53,13 +102,15 @@ func (s *MiningService) StartMining(userID uuid.UUID, serverIP string) error {
 
 	// Check server resources
 	resourceUsage := models.MiningResourceUsage{
-		CPU:      tool.Resources.CPU,
+		CPU:       tool.Resources.CPU,
 		Bandwidth: tool.Resources.Bandwidth,
-		RAM:      tool.Resources.RAM,
+		RAM:       tool.Resources.RAM,
 	}
 
-	// TODO: Check server's used resources vs available
-	// For now, we'll assume resources are available
+	// Every player's miners on a server share its CPU
+	if used := s.usedCPU(serverIP); used+resourceUsage.CPU > float64(server.Resources.CPU) {
+		return fmt.Errorf("not enough free CPU on %s (%.0f of %d in use)", serverIP, used, server.Resources.CPU)
+	}
 
 	// Create mining session
 	miner := &models.ActiveMiner{
@@ -67,6 +118,7 @@ func (s *MiningService) StartMining(userID uuid.UUID, serverIP string) error {
 		ServerIP:      serverIP,
 		StartTime:     time.Now(),
 		ResourceUsage: resourceUsage,
+		Hashrate:      s.hashrate(server, tool),
 	}
 
 	if err := s.db.Create(miner).Error; err != nil {
@@ -76,16 +128,30 @@ func (s *MiningService) StartMining(userID uuid.UUID, serverIP string) error {
 	return nil
 }
 
-// StopMining stops a mining operation
-func (s *MiningService) StopMining(userID uuid.UUID, serverIP string) error {
-	result := s.db.Where("user_id = ? AND server_ip = ?", userID, serverIP).Delete(&models.ActiveMiner{})
-	if result.Error != nil {
-		return fmt.Errorf("failed to stop mining: %w", result.Error)
+// StopMining stops a mining operation. Earnings up to now are settled first; solo earnings
+// short of a full block are lost. Returns the amount lost.
+func (s *MiningService) StopMining(userID uuid.UUID, serverIP string) (float64, error) {
+	var miner models.ActiveMiner
+	if err := s.db.Where("user_id = ? AND server_ip = ?", userID, serverIP).First(&miner).Error; err != nil {
+		return 0, fmt.Errorf("no active miner found on server %s", serverIP)
 	}
-	if result.RowsAffected == 0 {
-		return fmt.Errorf("no active miner found on server %s", serverIP)
+
+	stats := s.GetNetworkStats()
+	var lost float64
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.settle(tx, &miner, stats.Difficulty, true); err != nil {
+			return err
+		}
+		lost = miner.Pending
+		return nil
+	})
+	if errors.Is(err, errMinerSettled) {
+		return 0, fmt.Errorf("no active miner found on server %s", serverIP)
 	}
-	return nil
+	if err != nil {
+		return 0, fmt.Errorf("failed to stop mining: %w", err)
+	}
+	return lost, nil
 }
 
 // GetActiveMiners retrieves all active miners for a user
@@ -97,46 +163,293 @@ func (s *MiningService) GetActiveMiners(userID uuid.UUID) ([]models.ActiveMiner,
 	return miners, nil
 }
 
-// CalculateMiningReward calculates the reward for a mining session
-func (s *MiningService) CalculateMiningReward(miner *models.ActiveMiner) float64 {
-	duration := time.Since(miner.StartTime)
-	hours := duration.Hours()
-	
-	// Reward formula: base rate * hours * resource usage multiplier
-	baseRate := 1.0 // crypto per hour
-	multiplier := 1.0 + (miner.ResourceUsage.CPU / 100.0) // CPU affects reward
-	
-	return baseRate * hours * multiplier
+// GetServerMiners returns every player's miners running on a server, oldest first.
+func (s *MiningService) GetServerMiners(serverIP string) ([]models.ActiveMiner, error) {
+	var miners []models.ActiveMiner
+	if err := s.db.Where("server_ip = ?", serverIP).Order("start_time ASC").Find(&miners).Error; err != nil {
+		return nil, err
+	}
+	return miners, nil
+}
+
+// CountActiveMiners returns the number of miners running across all players.
+func (s *MiningService) CountActiveMiners() int64 {
+	var count int64
+	s.db.Model(&models.ActiveMiner{}).Count(&count)
+	return count
+}
+
+// GetNetworkStats returns the network's total hashrate and current difficulty.
+func (s *MiningService) GetNetworkStats() NetworkStats {
+	var stats NetworkStats
+	s.db.Model(&models.ActiveMiner{}).Count(&stats.Miners)
+	s.db.Model(&models.ActiveMiner{}).Select("COALESCE(SUM(hashrate), 0)").Scan(&stats.Hashrate)
+	stats.Difficulty = math.Max(1, stats.Hashrate/TargetNetworkHashrate)
+	return stats
+}
+
+// CalculateMiningReward calculates what a miner has earned since it was last paid at the given difficulty.
+func (s *MiningService) CalculateMiningReward(miner *models.ActiveMiner, difficulty float64) float64 {
+	hours := time.Since(miner.StartTime).Hours()
+	if hours <= 0 {
+		return 0
+	}
+	return MiningRate * miner.Hashrate * hours / math.Max(1, difficulty)
 }
 
-// ProcessMiningRewards processes rewards for all active miners (called periodically)
-func (s *MiningService) ProcessMiningRewards() error {
+// ProcessMiningRewards pays every active miner for the time since it was last paid, and gives
+// each host's admin the chance to notice its miners. It runs on the world scheduler and before
+// mining commands, so what they show is up to date. Returns the miners that were killed.
+func (s *MiningService) ProcessMiningRewards() ([]MinerKill, error) {
 	var miners []models.ActiveMiner
 	if err := s.db.Find(&miners).Error; err != nil {
-		return err
+		return nil, err
+	}
+
+	stats := s.GetNetworkStats()
+	detected := make(map[uuid.UUID]bool)
+	for _, miner := range miners {
+		detected[miner.ID] = s.detected(&miner)
+	}
+
+	// Pay the reward and reset the start time for the next period together, so a period is
+	// never paid twice. A pool's members are paid together, since they share its earnings.
+	settled := make(map[uuid.UUID]models.ActiveMiner)
+	pools := make(map[uuid.UUID]*models.MiningPool)
+	for _, miner := range miners {
+		if pool := s.GetUserPool(miner.UserID); pool != nil {
+			pools[pool.ID] = pool
+			continue
+		}
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			return s.settle(tx, &miner, stats.Difficulty, detected[miner.ID])
+		})
+		if err == nil {
+			settled[miner.ID] = miner
+		}
+	}
+	for _, pool := range pools {
+		var paid []models.ActiveMiner
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			paid, err = s.settlePool(tx, pool, stats.Difficulty, detected)
+			return err
+		})
+		if err != nil {
+			continue
+		}
+		for _, miner := range paid {
+			settled[miner.ID] = miner
+		}
 	}
 
+	var kills []MinerKill
 	for _, miner := range miners {
-		reward := s.CalculateMiningReward(&miner)
-		
-		// Update user's wallet
-		var user models.User
-		if err := s.db.First(&user, "id = ?", miner.UserID).Error; err != nil {
+		miner, ok := settled[miner.ID]
+		if !ok || !detected[miner.ID] {
 			continue
 		}
 
-		user.Wallet.Crypto += reward
-		if err := s.db.Save(&user).Error; err != nil {
+		kills = append(kills, MinerKill{Miner: miner, Lost: miner.Pending})
+		s.serverLogs.LogSystem(miner.ServerIP, fmt.Sprintf("%s: killed suspicious process %s (pid %d, %.0f CPU)",
+			sysAdminName(miner.ServerIP), MinerProcess, MinerPID(&miner), miner.ResourceUsage.CPU))
+		s.notifications.Notify(miner.UserID, models.NotificationAdmin,
+			fmt.Sprintf("%s noticed your miner on %s and killed it", sysAdminName(miner.ServerIP), miner.ServerIP))
+	}
+
+	return kills, nil
+}
+
+// KillServerMiners kills every miner on a server, settling what each has earned first. The
+// caller logs the kills.
+func (s *MiningService) KillServerMiners(serverIP string) ([]MinerKill, error) {
+	miners, err := s.GetServerMiners(serverIP)
+	if err != nil || len(miners) == 0 {
+		return nil, err
+	}
+
+	stats := s.GetNetworkStats()
+	var kills []MinerKill
+	for _, miner := range miners {
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			return s.settle(tx, &miner, stats.Difficulty, true)
+		})
+		if err != nil {
 			continue
 		}
+		kills = append(kills, MinerKill{Miner: miner, Lost: miner.Pending})
+	}
+	return kills, nil
+}
+
+// KillMiner kills one miner, settling what it has earned first. Returns what its owner lost
+// short of a block.
+func (s *MiningService) KillMiner(miner *models.ActiveMiner) (float64, error) {
+	stats := s.GetNetworkStats()
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		return s.settle(tx, miner, stats.Difficulty, true)
+	})
+	if errors.Is(err, errMinerSettled) {
+		return 0, fmt.Errorf("miner on %s already stopped", miner.ServerIP)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to kill miner: %w", err)
+	}
+	return miner.Pending, nil
+}
+
+// DetectionRate returns how often an hour the admin of a server notices a miner, from the
+// server's security level and how much CPU the miner uses.
+func DetectionRate(server *models.Server, miner *models.ActiveMiner) float64 {
+	return MinerDetectionRate * float64(server.SecurityLevel) / 10 * miner.ResourceUsage.CPU / 50
+}
+
+// MinerPID returns the process ID a miner shows up under on its host.
+func MinerPID(miner *models.ActiveMiner) int {
+	h := fnv.New32a()
+	h.Write(miner.ID[:])
+	return 1000 + int(h.Sum32()%30000)
+}
+
+// settle pays a miner for the time since it was last paid and starts its next period, or
+// removes it, within tx. Solo miners bank their earnings until they make a block; a pool
+// member's miner is paid along with the rest of its pool. Returns errMinerSettled if another
+// settlement got to the miner first.
+func (s *MiningService) settle(tx *gorm.DB, miner *models.ActiveMiner, difficulty float64, remove bool) error {
+	if pool := s.userPool(tx, miner.UserID); pool != nil {
+		var count int64
+		if err := tx.Model(&models.ActiveMiner{}).Where("id = ?", miner.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return errMinerSettled
+		}
+		_, err := s.settlePool(tx, pool, difficulty, map[uuid.UUID]bool{miner.ID: remove})
+		return err
+	}
 
-		// Reset start time for next period
-		miner.StartTime = time.Now()
-		if err := s.db.Save(&miner).Error; err != nil {
+	miner.Pending += s.CalculateMiningReward(miner, difficulty)
+	paid := math.Floor(miner.Pending/SoloBlockReward) * SoloBlockReward
+	miner.Pending -= paid
+	if err := s.startPeriod(tx, miner, remove); err != nil {
+		return err
+	}
+	if paid <= 0 {
+		return nil
+	}
+	if _, err := s.ledger.TransferTx(tx, SystemAccount("mining"), UserAccount(miner.UserID),
+		models.CurrencyCrypto, paid, "mining_reward", miner.ServerIP); err != nil {
+		return err
+	}
+	return s.notifications.NotifyPayoutTx(tx, miner.UserID, paid)
+}
+
+// settlePool pays every miner of a pool's members for the time since it was last paid and
+// starts its next period within tx, removing the miners in remove. What they earned is pooled
+// and split by each member's share of the pool's hashrate, after the owner takes the pool's
+// fee. Returns the miners it settled.
+func (s *MiningService) settlePool(tx *gorm.DB, pool *models.MiningPool, difficulty float64, remove map[uuid.UUID]bool) ([]models.ActiveMiner, error) {
+	var miners []models.ActiveMiner
+	members := tx.Model(&models.MiningPoolMember{}).Select("user_id").Where("pool_id = ?", pool.ID)
+	if err := tx.Where("user_id IN (?)", members).Order("start_time ASC").Find(&miners).Error; err != nil {
+		return nil, err
+	}
+
+	var earned, hashrate float64
+	shares := make(map[uuid.UUID]float64) // Each member's hashrate
+	var order []uuid.UUID
+	for i := range miners {
+		earned += s.CalculateMiningReward(&miners[i], difficulty)
+		hashrate += miners[i].Hashrate
+		if _, ok := shares[miners[i].UserID]; !ok {
+			order = append(order, miners[i].UserID)
+		}
+		shares[miners[i].UserID] += miners[i].Hashrate
+		if err := s.startPeriod(tx, &miners[i], remove[miners[i].ID]); err != nil {
+			return nil, err
+		}
+	}
+	if earned <= 0 || hashrate <= 0 {
+		return miners, nil
+	}
+
+	fee := roundPrice(earned * pool.Fee)
+	if fee > 0 {
+		if _, err := s.ledger.TransferTx(tx, SystemAccount("mining"), UserAccount(pool.OwnerID),
+			models.CurrencyCrypto, fee, "mining_pool_fee", pool.Name); err != nil {
+			return nil, err
+		}
+		if err := s.notifications.NotifyPayoutTx(tx, pool.OwnerID, fee); err != nil {
+			return nil, err
+		}
+	}
+	for _, userID := range order {
+		paid := (earned - fee) * shares[userID] / hashrate
+		if paid <= 0 {
 			continue
 		}
+		if _, err := s.ledger.TransferTx(tx, SystemAccount("mining"), UserAccount(userID),
+			models.CurrencyCrypto, paid, "mining_reward", pool.Name); err != nil {
+			return nil, err
+		}
+		if err := s.notifications.NotifyPayoutTx(tx, userID, paid); err != nil {
+			return nil, err
+		}
 	}
+	return miners, nil
+}
+
+// startPeriod starts a miner's next period within tx, or removes it. Returns errMinerSettled
+// if its current period was already settled.
+func (s *MiningService) startPeriod(tx *gorm.DB, miner *models.ActiveMiner, remove bool) error {
+	period := tx.Model(&models.ActiveMiner{}).Where("id = ? AND start_time = ?", miner.ID, miner.StartTime)
+	miner.StartTime = time.Now()
 
+	var result *gorm.DB
+	if remove {
+		result = period.Delete(&models.ActiveMiner{})
+	} else {
+		result = period.Updates(map[string]interface{}{"start_time": miner.StartTime, "pending": miner.Pending})
+	}
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errMinerSettled
+	}
 	return nil
 }
 
+// detected rolls whether the admin of a miner's host noticed it since it was last paid.
+func (s *MiningService) detected(miner *models.ActiveMiner) bool {
+	server, err := s.serverService.GetServerByIP(miner.ServerIP)
+	if err != nil {
+		return false
+	}
+	hours := time.Since(miner.StartTime).Hours()
+	if hours <= 0 {
+		return false
+	}
+	return s.chance() < 1-math.Exp(-DetectionRate(server, miner)*hours)
+}
+
+// hashrate returns the MH/s a miner makes on a server: one per 1000 CPU, times the miner's
+// efficiency. Each CPU patch makes crypto_miner leaner, and up to MaxMinerEfficiency faster.
+func (s *MiningService) hashrate(server *models.Server, tool *models.Tool) float64 {
+	efficiency := 1.0
+	if base, err := s.toolService.GetToolByName(tool.Name); err == nil && tool.Resources.CPU > 0 {
+		efficiency = math.Min(MaxMinerEfficiency, math.Max(1, base.Resources.CPU/tool.Resources.CPU))
+	}
+	return math.Round(float64(server.Resources.CPU)/1000*efficiency*100) / 100
+}
+
+// usedCPU returns the CPU taken by every miner on a server.
+func (s *MiningService) usedCPU(serverIP string) float64 {
+	var miners []models.ActiveMiner
+	s.db.Where("server_ip = ?", serverIP).Find(&miners)
+	var used float64
+	for _, m := range miners {
+		used += m.ResourceUsage.CPU
+	}
+	return used
+}
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"terminal-sh/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrPoolNotFound is returned when a mining pool doesn't exist.
+	ErrPoolNotFound = errors.New("mining pool not found")
+	// ErrPoolNameTaken is returned when creating a pool with a name already in use.
+	ErrPoolNameTaken = errors.New("a pool with that name already exists")
+	// ErrInvalidPoolFee is returned for a pool fee outside 0 to MaxPoolFee.
+	ErrInvalidPoolFee = errors.New("invalid pool fee")
+	// ErrNotInPool is returned when leaving a pool while not in one.
+	ErrNotInPool = errors.New("not in a mining pool")
+)
+
+// MaxPoolFee is the largest share of its members' payouts a pool can keep.
+const MaxPoolFee = 0.1
+
+// PoolSummary is a mining pool with its members' combined mining.
+type PoolSummary struct {
+	Pool     models.MiningPool
+	Owner    string
+	Members  int64
+	Hashrate float64
+}
+
+// CreatePool creates a mining pool and moves its owner into it.
+func (s *MiningService) CreatePool(ownerID uuid.UUID, name string, fee float64) (*models.MiningPool, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 24 || strings.ContainsAny(name, " \t") {
+		return nil, fmt.Errorf("pool names are 1 to 24 characters with no spaces")
+	}
+	if fee < 0 || fee > MaxPoolFee {
+		return nil, fmt.Errorf("%w: fees go from 0 to %.0f%%", ErrInvalidPoolFee, MaxPoolFee*100)
+	}
+
+	pool := &models.MiningPool{Name: name, OwnerID: ownerID, Fee: fee}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		tx.Model(&models.MiningPool{}).Where("LOWER(name) = ?", strings.ToLower(name)).Count(&count)
+		if count > 0 {
+			return ErrPoolNameTaken
+		}
+		if err := tx.Create(pool).Error; err != nil {
+			return err
+		}
+		return s.join(tx, ownerID, pool.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pool, nil
+}
+
+// JoinPool moves a player into a pool, leaving the one they were in.
+func (s *MiningService) JoinPool(userID uuid.UUID, name string) (*models.MiningPool, error) {
+	pool, err := s.findPool(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		return s.join(tx, userID, pool.ID)
+	}); err != nil {
+		return nil, err
+	}
+	return pool, nil
+}
+
+// LeavePool takes a player out of their pool, so they go back to mining solo.
+func (s *MiningService) LeavePool(userID uuid.UUID) (*models.MiningPool, error) {
+	pool := s.GetUserPool(userID)
+	if pool == nil {
+		return nil, ErrNotInPool
+	}
+	if err := s.db.Delete(&models.MiningPoolMember{}, "user_id = ?", userID).Error; err != nil {
+		return nil, err
+	}
+	return pool, nil
+}
+
+// GetUserPool returns the pool a player mines for, or nil if they mine solo.
+func (s *MiningService) GetUserPool(userID uuid.UUID) *models.MiningPool {
+	return s.userPool(s.db.DB, userID)
+}
+
+// GetPools returns every pool with its members and their combined hashrate, biggest first.
+func (s *MiningService) GetPools() ([]PoolSummary, error) {
+	var pools []models.MiningPool
+	if err := s.db.Order("created_at ASC").Find(&pools).Error; err != nil {
+		return nil, err
+	}
+
+	summaries := make([]PoolSummary, 0, len(pools))
+	for _, pool := range pools {
+		summary := PoolSummary{Pool: pool}
+		var owner models.User
+		if err := s.db.Select("username").First(&owner, "id = ?", pool.OwnerID).Error; err == nil {
+			summary.Owner = owner.Username
+		}
+		members := s.db.Model(&models.MiningPoolMember{}).Select("user_id").Where("pool_id = ?", pool.ID)
+		s.db.Model(&models.MiningPoolMember{}).Where("pool_id = ?", pool.ID).Count(&summary.Members)
+		s.db.Model(&models.ActiveMiner{}).Where("user_id IN (?)", members).
+			Select("COALESCE(SUM(hashrate), 0)").Scan(&summary.Hashrate)
+		summaries = append(summaries, summary)
+	}
+
+	sort.SliceStable(summaries, func(i, j int) bool { return summaries[i].Hashrate > summaries[j].Hashrate })
+	return summaries, nil
+}
+
+// join makes a player a member of a pool within tx, replacing any membership they had.
+func (s *MiningService) join(tx *gorm.DB, userID, poolID uuid.UUID) error {
+	if err := tx.Delete(&models.MiningPoolMember{}, "user_id = ?", userID).Error; err != nil {
+		return err
+	}
+	return tx.Create(&models.MiningPoolMember{UserID: userID, PoolID: poolID, JoinedAt: time.Now()}).Error
+}
+
+// userPool returns the pool a player mines for within tx, or nil.
+func (s *MiningService) userPool(tx *gorm.DB, userID uuid.UUID) *models.MiningPool {
+	var member models.MiningPoolMember
+	if err := tx.Where("user_id = ?", userID).First(&member).Error; err != nil {
+		return nil
+	}
+	var pool models.MiningPool
+	if err := tx.Where("id = ?", member.PoolID).First(&pool).Error; err != nil {
+		return nil
+	}
+	return &pool
+}
+
+// findPool looks a pool up by name, ignoring case.
+func (s *MiningService) findPool(name string) (*models.MiningPool, error) {
+	var pool models.MiningPool
+	if err := s.db.Where("LOWER(name) = ?", strings.ToLower(name)).First(&pool).Error; err != nil {
+		return nil, ErrPoolNotFound
+	}
+	return &pool, nil
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"terminal-sh/models"
+
+	"github.com/google/uuid"
+)
+
+func TestPoolMinersArePaidEachPeriodAndSoloMinersInBlocks(t *testing.T) {
+	db := newTestDatabase(t)
+	servers := NewServerService(db)
+	tools := NewToolService(db, servers)
+	if err := db.Create(&models.Tool{Name: "crypto_miner", Function: "Mine cryptocurrency", Resources: models.ToolResources{CPU: 50, Bandwidth: 1, RAM: 16}}).Error; err != nil {
+		t.Fatalf("failed to create crypto_miner: %v", err)
+	}
+	if err := db.Create(&models.Server{IP: "10.0.0.1", LocalIP: "192.168.0.1", Resources: models.ServerResources{CPU: 4000}}).Error; err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	mining := NewMiningService(db, tools, servers)
+	mining.chance = func() float64 { return 1 } // No admin ever notices
+
+	users := NewUserService(db, "test-secret")
+	alice, _ := users.Register("alice", "correct-horse")
+	bob, _ := users.Register("bob", "battery-staple")
+	miner, _ := tools.GetToolByName("crypto_miner")
+	for _, u := range []*models.User{alice, bob} {
+		u.Wallet.Crypto = 0
+		db.Model(u).Select("wallet").Updates(u)
+		if err := tools.GrantToolToUser(u.ID, miner.ID); err != nil {
+			t.Fatalf("failed to grant crypto_miner: %v", err)
+		}
+		if err := mining.StartMining(u.ID, "10.0.0.1"); err != nil {
+			t.Fatalf("failed to start mining: %v", err)
+		}
+	}
+	if _, err := mining.CreatePool(alice.ID, "deepcore", 0.1); err != nil {
+		t.Fatalf("failed to create pool: %v", err)
+	}
+	if _, err := mining.JoinPool(bob.ID, "DeepCore"); err != nil {
+		t.Fatalf("failed to join pool: %v", err)
+	}
+	if _, err := mining.LeavePool(alice.ID); err != nil {
+		t.Fatalf("failed to leave pool: %v", err)
+	}
+
+	// Each miner makes 4 MH/s on a 4000 CPU host, 2 crypto an hour at difficulty 1
+	stats := mining.GetNetworkStats()
+	if stats.Hashrate != 8 || stats.Difficulty != 1 {
+		t.Fatalf("expected 8 MH/s at difficulty 1, got %+v", stats)
+	}
+	db.Model(&models.ActiveMiner{}).Where("1 = 1").Update("start_time", time.Now().Add(-90*time.Minute))
+	if kills, err := mining.ProcessMiningRewards(); err != nil || len(kills) != 0 {
+		t.Fatalf("expected no kills, got %v (%v)", kills, err)
+	}
+
+	// Bob's 3 crypto goes through the pool, which keeps 10% for alice; alice mines solo and
+	// hasn't made a 5 crypto block yet
+	var a, b models.User
+	db.First(&a, "id = ?", alice.ID)
+	db.First(&b, "id = ?", bob.ID)
+	if math.Abs(a.Wallet.Crypto-0.3) > 0.01 || math.Abs(b.Wallet.Crypto-2.7) > 0.01 {
+		t.Fatalf("expected alice 0.30 and bob 2.70, got %.2f and %.2f", a.Wallet.Crypto, b.Wallet.Crypto)
+	}
+	lost, err := mining.StopMining(alice.ID, "10.0.0.1")
+	if err != nil || math.Abs(lost-3) > 0.01 {
+		t.Fatalf("expected alice to lose her 3 crypto short of a block, got %.2f (%v)", lost, err)
+	}
+	if report, _ := NewLedgerService(db).Reconcile(); !report.OK() {
+		t.Fatalf("expected the ledger to reconcile, got %+v", report)
+	}
+}
+
+func TestAdminKillsMinerItNotices(t *testing.T) {
+	db := newTestDatabase(t)
+	servers := NewServerService(db)
+	tools := NewToolService(db, servers)
+	if err := db.Create(&models.Tool{Name: "crypto_miner", Function: "Mine cryptocurrency", Resources: models.ToolResources{CPU: 50, Bandwidth: 1, RAM: 16}}).Error; err != nil {
+		t.Fatalf("failed to create crypto_miner: %v", err)
+	}
+	server := &models.Server{IP: "10.0.0.9", LocalIP: "192.168.0.9", SecurityLevel: 50, Resources: models.ServerResources{CPU: 1000}}
+	if err := db.Create(server).Error; err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	mining := NewMiningService(db, tools, servers)
+	mining.chance = func() float64 { return 0 } // Every admin notices
+
+	users := NewUserService(db, "test-secret")
+	alice, _ := users.Register("alice", "correct-horse")
+	miner, _ := tools.GetToolByName("crypto_miner")
+	tools.GrantToolToUser(alice.ID, miner.ID)
+	if err := mining.StartMining(alice.ID, server.IP); err != nil {
+		t.Fatalf("failed to start mining: %v", err)
+	}
+	db.Model(&models.ActiveMiner{}).Where("1 = 1").Update("start_time", time.Now().Add(-time.Hour))
+
+	kills, err := mining.ProcessMiningRewards()
+	if err != nil || len(kills) != 1 || math.Abs(kills[0].Lost-0.5) > 0.01 {
+		t.Fatalf("expected the miner killed with 0.50 unpaid, got %+v (%v)", kills, err)
+	}
+	if mining.CountActiveMiners() != 0 {
+		t.Fatal("expected the miner to be gone")
+	}
+	logs, _ := NewServerLogService(db).GetSystemLogs(server.IP, 10)
+	if len(logs) != 1 {
+		t.Fatalf("expected the kill in the system log, got %d entries", len(logs))
+	}
+}
+
+func TestPoolSplitsItsEarningsByHashrate(t *testing.T) {
+	db := newTestDatabase(t)
+	servers := NewServerService(db)
+	tools := NewToolService(db, servers)
+	if err := db.Create(&models.Tool{Name: "crypto_miner", Function: "Mine cryptocurrency", Resources: models.ToolResources{CPU: 50, Bandwidth: 1, RAM: 16}}).Error; err != nil {
+		t.Fatalf("failed to create crypto_miner: %v", err)
+	}
+	db.Create(&models.Server{IP: "10.0.0.1", LocalIP: "192.168.0.1", Resources: models.ServerResources{CPU: 4000}})
+	db.Create(&models.Server{IP: "10.0.0.2", LocalIP: "192.168.0.2", Resources: models.ServerResources{CPU: 2000}})
+	mining := NewMiningService(db, tools, servers)
+	mining.chance = func() float64 { return 1 } // No admin ever notices
+
+	users := NewUserService(db, "test-secret")
+	alice, _ := users.Register("alice", "correct-horse")
+	bob, _ := users.Register("bob", "battery-staple")
+	carol, _ := users.Register("carol", "tr0ub4dor")
+	miner, _ := tools.GetToolByName("crypto_miner")
+	for _, u := range []*models.User{alice, bob, carol} {
+		u.Wallet.Crypto = 0
+		db.Model(u).Select("wallet").Updates(u)
+		tools.GrantToolToUser(u.ID, miner.ID)
+	}
+	if _, err := mining.CreatePool(carol.ID, "deepcore", 0.1); err != nil {
+		t.Fatalf("failed to create pool: %v", err)
+	}
+	for _, u := range []*models.User{alice, bob} {
+		if _, err := mining.JoinPool(u.ID, "deepcore"); err != nil {
+			t.Fatalf("failed to join pool: %v", err)
+		}
+	}
+
+	// Alice mines 4 MH/s for 90 minutes and bob 2 MH/s for 30: 3.5 crypto between them
+	mining.StartMining(alice.ID, "10.0.0.1")
+	mining.StartMining(bob.ID, "10.0.0.2")
+	db.Model(&models.ActiveMiner{}).Where("user_id = ?", alice.ID).Update("start_time", time.Now().Add(-90*time.Minute))
+	db.Model(&models.ActiveMiner{}).Where("user_id = ?", bob.ID).Update("start_time", time.Now().Add(-30*time.Minute))
+	if _, err := mining.ProcessMiningRewards(); err != nil {
+		t.Fatalf("failed to pay the miners: %v", err)
+	}
+
+	// Carol takes 10%, and alice gets two thirds of the rest for two thirds of the hashrate
+	want := map[uuid.UUID]float64{alice.ID: 2.10, bob.ID: 1.05, carol.ID: 0.35}
+	for id, crypto := range want {
+		var u models.User
+		db.First(&u, "id = ?", id)
+		if math.Abs(u.Wallet.Crypto-crypto) > 0.01 {
+			t.Fatalf("expected %s to have %.2f, got %.2f", u.Username, crypto, u.Wallet.Crypto)
+		}
+	}
+	if report, _ := NewLedgerService(db).Reconcile(); !report.OK() {
+		t.Fatalf("expected the ledger to reconcile, got %+v", report)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 	"terminal-sh/database"
@@ -20,6 +21,8 @@ type MissionService struct {
 	rewardService     *RewardService
 	missionGenerator  *MissionGenerator  // Optional mission generator
 	actionTracker     *ActionTracker     // Optional action tracker for objective validation
+	eventService      *EventService      // Optional live events, which bring their own missions
+	notifications     *NotificationService
 }
 
 // NewMissionService creates a new MissionService and loads missions from JSON
@@ -28,6 +31,7 @@ func NewMissionService(db *database.Database, dataPath string, rewardService *Re
 		db:            db,
 		dataPath:      dataPath,
 		rewardService: rewardService,
+		notifications: NewNotificationService(db),
 	}
 	
 	if err := service.LoadMissions(); err != nil {
@@ -79,6 +83,13 @@ func (s *MissionService) GetMissionByID(id string) (*models.Mission, error) {
 		}
 	}
 	
+	// Check the missions of live events
+	if s.eventService != nil {
+		if mission := s.eventService.GetMission(id); mission != nil {
+			return mission, nil
+		}
+	}
+	
 	// Check procedurally generated missions
 	var generatedMission models.GeneratedMission
 	if err := s.db.Where("mission_id = ?", id).First(&generatedMission).Error; err == nil {
@@ -125,6 +136,9 @@ func (s *MissionService) StartMission(userID uuid.UUID, missionID string) error
 	if err != nil {
 		return err
 	}
+	if s.eventService != nil && s.eventService.MissionExpired(missionID) {
+		return fmt.Errorf("the event this mission belongs to is over")
+	}
 	
 	// Check prerequisites
 	userMissions, err := s.GetUserMissions(userID)
@@ -299,7 +313,21 @@ func (s *MissionService) completeMissionInternal(userID uuid.UUID, missionID str
 	userMission.Status = "completed"
 	userMission.Progress = 100
 	userMission.CompletedAt = &now
-	return s.db.Save(userMission).Error
+	if err := s.db.Save(userMission).Error; err != nil {
+		return err
+	}
+	s.notifyUnlocks(userID, mission)
+	return nil
+}
+
+// notifyUnlocks tells the user about the missions completing a mission unlocked.
+func (s *MissionService) notifyUnlocks(userID uuid.UUID, mission *models.Mission) {
+	for _, id := range mission.Unlocks {
+		if unlocked, err := s.GetMissionByID(id); err == nil {
+			s.notifications.Notify(userID, models.NotificationMission,
+				fmt.Sprintf("New mission unlocked: %s (%s)", unlocked.Name, unlocked.ID))
+		}
+	}
 }
 
 // StopMission abandons an in-progress mission (mission board only).
@@ -371,6 +399,7 @@ func (s *MissionService) CompleteMission(userID uuid.UUID, missionID string) err
 	if err := s.db.Save(userMission).Error; err != nil {
 		return fmt.Errorf("failed to complete mission: %w", err)
 	}
+	s.notifyUnlocks(userID, mission)
 	
 	return nil
 }
@@ -577,6 +606,11 @@ func (s *MissionService) SetActionTracker(tracker *ActionTracker) {
 	s.actionTracker = tracker
 }
 
+// SetEventService sets the live event service, whose running events add missions
+func (s *MissionService) SetEventService(eventService *EventService) {
+	s.eventService = eventService
+}
+
 // GetAvailableMissions returns missions available to a user (prerequisites met, level met)
 func (s *MissionService) GetAvailableMissions(userID uuid.UUID, userLevel int) []models.Mission {
 	userMissions, _ := s.GetUserMissions(userID)
@@ -642,6 +676,15 @@ func (s *MissionService) GetAvailableMissions(userID uuid.UUID, userLevel int) [
 		}
 	}
 	
+	// Live events add their missions while they run
+	if s.eventService != nil {
+		for _, mission := range s.eventService.ActiveMissions() {
+			if !completedMissionIDs[mission.ID] {
+				available = append(available, mission)
+			}
+		}
+	}
+	
 	return available
 }
 
@@ -1093,7 +1136,7 @@ func (s *MissionService) createDefaultMissions(path string) error {
 	}
 	
 	// Ensure directory exists
-	if err := os.MkdirAll("data/seed", 0755); err != nil {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
 		return err
 	}
 	
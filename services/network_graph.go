@@ -0,0 +1,195 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"net"
+	"sort"
+
+	"terminal-sh/models"
+)
+
+// ErrNoRoute is returned when a server can't be reached from the player's location.
+var ErrNoRoute = errors.New("No route to host")
+
+// NetworkGraph is the routing topology of every server. Servers that aren't in any other server's
+// LocalNetwork are on the internet and reachable from anywhere; the rest sit on an internal subnet
+// behind their gateway, the server whose LocalNetwork holds them, and can only be reached from that
+// gateway or through a pivot tunnel it hosts.
+type NetworkGraph struct {
+	servers map[string]*models.Server
+	gateway map[string]string // Internal server IP -> gateway server IP
+}
+
+// Route is one entry in a machine's routing table.
+type Route struct {
+	Destination string // Network in CIDR form, "default" for the default route
+	Gateway     string // Next hop, empty for directly connected networks
+	Interface   string // eth0 faces the gateway, eth1 the internal subnet, tun0 pivot tunnels
+}
+
+// Hop is one machine on the route to a server.
+type Hop struct {
+	IP        string
+	LatencyMs int
+}
+
+// NewNetworkGraph builds the topology from a list of servers.
+func NewNetworkGraph(servers []models.Server) *NetworkGraph {
+	g := &NetworkGraph{
+		servers: make(map[string]*models.Server, len(servers)),
+		gateway: make(map[string]string),
+	}
+	for i := range servers {
+		server := &servers[i]
+		g.servers[server.IP] = server
+		for ip := range server.LocalNetwork {
+			g.gateway[ip] = server.IP
+		}
+	}
+	return g
+}
+
+// Graph loads the network topology of every server. Only the columns routing needs are loaded.
+func (n *NetworkService) Graph() (*NetworkGraph, error) {
+	var servers []models.Server
+	if err := n.serverService.db.Select("ip", "local_ip", "local_network", "services").Find(&servers).Error; err != nil {
+		return nil, fmt.Errorf("failed to load network: %w", err)
+	}
+	return NewNetworkGraph(servers), nil
+}
+
+// Gateway returns the gateway of an internal server, or "" for a server on the internet.
+func (g *NetworkGraph) Gateway(ip string) string {
+	return g.gateway[ip]
+}
+
+// IsInternal reports whether a server sits on an internal subnet.
+func (g *NetworkGraph) IsInternal(ip string) bool {
+	return g.gateway[ip] != ""
+}
+
+// Subnets returns the internal networks a server is the gateway for, in CIDR form.
+// Hosts that don't have an IPv4 address get a /32 of their own name.
+func (g *NetworkGraph) Subnets(ip string) []string {
+	server, ok := g.servers[ip]
+	if !ok {
+		return nil
+	}
+	seen := make(map[string]bool)
+	var subnets []string
+	for host := range server.LocalNetwork {
+		subnet := subnetOf(host)
+		if !seen[subnet] {
+			seen[subnet] = true
+			subnets = append(subnets, subnet)
+		}
+	}
+	sort.Strings(subnets)
+	return subnets
+}
+
+// Routes returns a server's routing table: a default route towards the internet (through its
+// gateway for an internal server) and a directly connected route for each internal subnet.
+func (g *NetworkGraph) Routes(ip string) []Route {
+	routes := []Route{{Destination: "default", Gateway: g.gateway[ip], Interface: "eth0"}}
+	if routes[0].Gateway == "" {
+		// Servers with a host name instead of an address are routed from their local IP
+		address := ip
+		if server, ok := g.servers[ip]; ok && net.ParseIP(ip) == nil {
+			address = server.LocalIP
+		}
+		routes[0].Gateway = upstreamGateway(address)
+	}
+	for _, subnet := range g.Subnets(ip) {
+		routes = append(routes, Route{Destination: subnet, Interface: "eth1"})
+	}
+	return routes
+}
+
+// Route returns the hops from the player's location to target, ending with target itself.
+// fromPath is the server path the player is on ("" for their home computer) and tunnels holds
+// the server paths of their pivot tunnels. Along with the hops, returns the server path the
+// connection enters target's network from - fromPath, or the tunnel used.
+// An internal server is only reachable from its gateway or through a tunnel on it; if it can't
+// be reached, returns the hops up to where the packets are dropped and ErrNoRoute.
+func (g *NetworkGraph) Route(fromPath string, tunnels []string, target string) ([]Hop, string, error) {
+	if _, ok := g.servers[target]; !ok {
+		return nil, "", fmt.Errorf("%s: unknown host", target)
+	}
+	from := pathHosts(fromPath)
+
+	gateway := g.gateway[target]
+	if gateway == "" || (len(from) > 0 && from[len(from)-1] == gateway) {
+		return g.hops(append(from, target)), fromPath, nil
+	}
+	for i := len(tunnels) - 1; i >= 0; i-- {
+		via := pathHosts(tunnels[i])
+		if len(via) > 0 && via[len(via)-1] == gateway {
+			return g.hops(append(via, target)), tunnels[i], nil
+		}
+	}
+
+	// Packets get as far as the edge of the internal network and are dropped there
+	edge := target
+	for g.gateway[edge] != "" {
+		edge = g.gateway[edge]
+	}
+	if len(from) > 0 && from[len(from)-1] == edge {
+		return g.hops(from), fromPath, ErrNoRoute
+	}
+	return g.hops(append(from, edge)), fromPath, ErrNoRoute
+}
+
+// hops returns the hops for a list of server IPs with their latencies.
+func (g *NetworkGraph) hops(ips []string) []Hop {
+	hops := make([]Hop, len(ips))
+	for i, ip := range ips {
+		hops[i] = Hop{IP: ip, LatencyMs: g.latency(ip)}
+	}
+	return hops
+}
+
+// latency returns a server's fixed per-hop latency in milliseconds: a few ms on an internal
+// subnet, more across the internet.
+func (g *NetworkGraph) latency(ip string) int {
+	spread := int(crc32.ChecksumIEEE([]byte(ip)) % 30)
+	if g.IsInternal(ip) {
+		return 1 + spread%3
+	}
+	return 10 + spread
+}
+
+// pathHosts returns the server IPs in a server path like "1.2.3.4.localNetwork.10.0.0.5".
+func pathHosts(path string) []string {
+	if path == "" {
+		return nil
+	}
+	var hosts []string
+	for _, part := range parseServerPath(path) {
+		if part != "localNetwork" {
+			hosts = append(hosts, part)
+		}
+	}
+	return hosts
+}
+
+// subnetOf returns the /24 network of an IPv4 address, or a /32 of anything else.
+func subnetOf(host string) string {
+	ip := net.ParseIP(host).To4()
+	if ip == nil {
+		return host + "/32"
+	}
+	return fmt.Sprintf("%d.%d.%d.0/24", ip[0], ip[1], ip[2])
+}
+
+// upstreamGateway returns the router a server on the internet sends its traffic to: the .1 of
+// its /24.
+func upstreamGateway(host string) string {
+	ip := net.ParseIP(host).To4()
+	if ip == nil {
+		return "0.0.0.0"
+	}
+	return fmt.Sprintf("%d.%d.%d.1", ip[0], ip[1], ip[2])
+}
@@ -0,0 +1,71 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"terminal-sh/models"
+)
+
+func testNetwork() *NetworkGraph {
+	return NewNetworkGraph([]models.Server{
+		{IP: "203.0.113.42", LocalNetwork: map[string]interface{}{"10.10.10.50": "10.10.10.50"}},
+		{IP: "10.10.10.50", LocalNetwork: map[string]interface{}{"172.20.0.9": "172.20.0.9"}},
+		{IP: "172.20.0.9"},
+		{IP: "198.51.100.77"},
+	})
+}
+
+func TestInternalServersNeedAPivot(t *testing.T) {
+	g := testNetwork()
+
+	// Public servers are reachable from anywhere
+	hops, via, err := g.Route("", nil, "198.51.100.77")
+	if err != nil || len(hops) != 1 || via != "" {
+		t.Fatalf("expected a direct route, got %v via %q (%v)", hops, via, err)
+	}
+
+	// An internal server can't be reached from home...
+	hops, _, err = g.Route("", nil, "10.10.10.50")
+	if !errors.Is(err, ErrNoRoute) {
+		t.Fatalf("expected no route, got %v", err)
+	}
+	if len(hops) != 1 || hops[0].IP != "203.0.113.42" {
+		t.Fatalf("expected packets to stop at the gateway, got %v", hops)
+	}
+
+	// ...but can from its gateway
+	hops, via, err = g.Route("203.0.113.42", nil, "10.10.10.50")
+	if err != nil || len(hops) != 2 || via != "203.0.113.42" {
+		t.Fatalf("expected a route from the gateway, got %v via %q (%v)", hops, via, err)
+	}
+
+	// A tunnel on the gateway reaches its subnet from home, but not the subnets further in
+	tunnels := []string{"203.0.113.42"}
+	if _, via, err = g.Route("", tunnels, "10.10.10.50"); err != nil || via != "203.0.113.42" {
+		t.Fatalf("expected a route through the tunnel, got via %q (%v)", via, err)
+	}
+	if _, _, err = g.Route("", tunnels, "172.20.0.9"); !errors.Is(err, ErrNoRoute) {
+		t.Fatalf("expected the deeper subnet to need its own pivot, got %v", err)
+	}
+	tunnels = append(tunnels, "203.0.113.42.localNetwork.10.10.10.50")
+	hops, via, err = g.Route("", tunnels, "172.20.0.9")
+	if err != nil || len(hops) != 3 || via != tunnels[1] {
+		t.Fatalf("expected a route through both pivots, got %v via %q (%v)", hops, via, err)
+	}
+}
+
+func TestRoutingTable(t *testing.T) {
+	g := testNetwork()
+
+	routes := g.Routes("10.10.10.50")
+	if len(routes) != 2 {
+		t.Fatalf("expected default and subnet routes, got %v", routes)
+	}
+	if routes[0].Destination != "default" || routes[0].Gateway != "203.0.113.42" {
+		t.Fatalf("expected default route via the gateway, got %v", routes[0])
+	}
+	if routes[1].Destination != "172.20.0.0/24" || routes[1].Gateway != "" {
+		t.Fatalf("expected directly connected subnet, got %v", routes[1])
+	}
+}
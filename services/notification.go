@@ -0,0 +1,153 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"terminal-sh/database"
+	"terminal-sh/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// NotificationLimit is how many notifications are kept per player. Older ones are dropped.
+const NotificationLimit = 100
+
+// NotificationService queues notifications for players: things that happen to them between
+// their commands or while they're offline. Notifications are kept in the database, so any
+// service can send them and every shell the player has open picks them up.
+type NotificationService struct {
+	db *database.Database
+}
+
+// NewNotificationService creates a new NotificationService.
+func NewNotificationService(db *database.Database) *NotificationService {
+	return &NotificationService{db: db}
+}
+
+// Notify queues a notification for a player. A notification just like one they haven't read
+// yet moves that one up instead of queuing another. Failures are logged: a notification is
+// never worth failing what it's about.
+func (s *NotificationService) Notify(userID uuid.UUID, kind models.NotificationKind, message string) {
+	if err := s.NotifyTx(s.db.DB, userID, kind, message); err != nil {
+		log.Printf("notifications: failed to notify %s: %v", userID, err)
+	}
+}
+
+// NotifyTx queues a notification within tx, for callers that are already in a transaction.
+func (s *NotificationService) NotifyTx(tx *gorm.DB, userID uuid.UUID, kind models.NotificationKind, message string) error {
+	result := tx.Model(&models.Notification{}).
+		Where("user_id = ? AND kind = ? AND message = ? AND read = ?", userID, kind, message, false).
+		Update("updated_at", time.Now())
+	if result.Error != nil || result.RowsAffected > 0 {
+		return result.Error
+	}
+	if err := tx.Create(&models.Notification{UserID: userID, Kind: kind, Message: message}).Error; err != nil {
+		return err
+	}
+	return s.trim(tx, userID)
+}
+
+// NotifyPayoutTx tells a player within tx that mining paid them. Payouts add up in one
+// notification until the player reads it, so a long night of mining is one line.
+func (s *NotificationService) NotifyPayoutTx(tx *gorm.DB, userID uuid.UUID, amount float64) error {
+	var pending models.Notification
+	err := tx.Where("user_id = ? AND kind = ? AND read = ?", userID, models.NotificationMining, false).First(&pending).Error
+	if err != nil {
+		pending = models.Notification{UserID: userID, Kind: models.NotificationMining}
+	}
+	pending.Amount += amount
+	pending.Message = fmt.Sprintf("Mining paid out %.2f crypto", pending.Amount)
+	if pending.ID == uuid.Nil {
+		if err := tx.Create(&pending).Error; err != nil {
+			return err
+		}
+		return s.trim(tx, userID)
+	}
+	return tx.Model(&pending).Select("amount", "message").Updates(&pending).Error
+}
+
+// NotifyIntrusion warns the players with a backdoor or a miner on a server that someone else
+// just broke into it.
+func (s *NotificationService) NotifyIntrusion(serverIP string, intruderID uuid.UUID, intruder, serviceName string) {
+	var backdoored, mining []uuid.UUID
+	s.db.Model(&models.BackdoorAccess{}).
+		Where("(server_path = ? OR server_path LIKE ?) AND user_id <> ?", serverIP, "%."+serverIP, intruderID).
+		Distinct().Pluck("user_id", &backdoored)
+	s.db.Model(&models.ActiveMiner{}).Where("server_ip = ? AND user_id <> ?", serverIP, intruderID).
+		Distinct().Pluck("user_id", &mining)
+
+	notified := make(map[uuid.UUID]bool)
+	for _, userID := range backdoored {
+		notified[userID] = true
+		s.Notify(userID, models.NotificationIntrusion,
+			fmt.Sprintf("Intrusion on %s, where you have a backdoor: %s got in through %s", serverIP, intruder, serviceName))
+	}
+	for _, userID := range mining {
+		if !notified[userID] {
+			s.Notify(userID, models.NotificationIntrusion,
+				fmt.Sprintf("Intrusion on %s, where you're mining: %s got in through %s", serverIP, intruder, serviceName))
+		}
+	}
+}
+
+// Unread returns how many notifications a player hasn't read, and the latest of them.
+func (s *NotificationService) Unread(userID uuid.UUID) (int64, *models.Notification, error) {
+	var count int64
+	if err := s.db.Model(&models.Notification{}).Where("user_id = ? AND read = ?", userID, false).Count(&count).Error; err != nil {
+		return 0, nil, err
+	}
+	if count == 0 {
+		return 0, nil, nil
+	}
+	var latest models.Notification
+	if err := s.db.Where("user_id = ? AND read = ?", userID, false).Order("updated_at DESC").First(&latest).Error; err != nil {
+		return 0, nil, err
+	}
+	return count, &latest, nil
+}
+
+// Recent returns a player's latest notifications, newest first: only the unread ones, or
+// all of them up to limit.
+func (s *NotificationService) Recent(userID uuid.UUID, unreadOnly bool, limit int) ([]models.Notification, error) {
+	query := s.db.Where("user_id = ?", userID)
+	if unreadOnly {
+		query = query.Where("read = ?", false)
+	}
+	var notifications []models.Notification
+	err := query.Order("updated_at DESC").Limit(limit).Find(&notifications).Error
+	return notifications, err
+}
+
+// MarkRead marks the given notifications of a player read, so any that arrived since they were
+// shown stay unread.
+func (s *NotificationService) MarkRead(userID uuid.UUID, notifications []models.Notification) error {
+	var ids []uuid.UUID
+	for _, n := range notifications {
+		if !n.Read {
+			ids = append(ids, n.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	return s.db.Model(&models.Notification{}).Where("user_id = ? AND id IN ?", userID, ids).Update("read", true).Error
+}
+
+// Clear deletes all of a player's notifications. Returns how many there were.
+func (s *NotificationService) Clear(userID uuid.UUID) (int64, error) {
+	result := s.db.Where("user_id = ?", userID).Delete(&models.Notification{})
+	return result.RowsAffected, result.Error
+}
+
+// trim keeps only a player's latest NotificationLimit notifications.
+func (s *NotificationService) trim(tx *gorm.DB, userID uuid.UUID) error {
+	var oldest []models.Notification
+	if err := tx.Where("user_id = ?", userID).Order("updated_at DESC").Offset(NotificationLimit - 1).Limit(1).
+		Find(&oldest).Error; err != nil || len(oldest) == 0 {
+		return err
+	}
+	return tx.Where("user_id = ? AND updated_at < ?", userID, oldest[0].UpdatedAt).Delete(&models.Notification{}).Error
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"testing"
+
+	"terminal-sh/models"
+)
+
+func TestMentionsNotifyOnlyPlayersWhoCanReadTheRoom(t *testing.T) {
+	db := newTestDatabase(t)
+	users := NewUserService(db, "test-secret")
+	alice, _ := users.Register("alice", "correct-horse")
+	bob, _ := users.Register("bob", "battery-staple")
+	chat := NewChatService(db)
+	if err := chat.InitializeDefaultRoom(); err != nil {
+		t.Fatalf("failed to create #public: %v", err)
+	}
+	public, _ := chat.GetRoomByName("#public")
+	if err := chat.JoinRoom(public.ID, alice.ID, ""); err != nil {
+		t.Fatalf("failed to join #public: %v", err)
+	}
+	private, err := chat.CreateRoom("ops", "private", "", alice.ID)
+	if err != nil {
+		t.Fatalf("failed to create room: %v", err)
+	}
+
+	// Bob hasn't opened chat yet but can read #public; nobody outside ops can read it
+	chat.SendMessage(public.ID, alice.ID, "alice", "@bob, @nobody: the bank is open")
+	chat.SendMessage(public.ID, alice.ID, "alice", "@bob, @nobody: the bank is open")
+	chat.SendMessage(private.ID, alice.ID, "alice", "don't tell @bob")
+
+	notifications := NewNotificationService(db)
+	unread, latest, err := notifications.Unread(bob.ID)
+	if err != nil || unread != 1 || latest.Kind != models.NotificationMention {
+		t.Fatalf("expected one mention for bob, got %d %+v (%v)", unread, latest, err)
+	}
+	if unread, _, _ := notifications.Unread(alice.ID); unread != 0 {
+		t.Fatalf("expected no notifications for alice, got %d", unread)
+	}
+
+	recent, _ := notifications.Recent(bob.ID, true, NotificationLimit)
+	if err := notifications.MarkRead(bob.ID, recent); err != nil {
+		t.Fatalf("failed to mark read: %v", err)
+	}
+	if unread, _, _ := notifications.Unread(bob.ID); unread != 0 {
+		t.Fatalf("expected bob's mention to be read, got %d unread", unread)
+	}
+}
+
+func TestPayoutsAddUpAndIntrusionsWarnPlayersWithAFoothold(t *testing.T) {
+	db := newTestDatabase(t)
+	users := NewUserService(db, "test-secret")
+	alice, _ := users.Register("alice", "correct-horse")
+	bob, _ := users.Register("bob", "battery-staple")
+	notifications := NewNotificationService(db)
+
+	notifications.NotifyPayoutTx(db.DB, bob.ID, 2.5)
+	notifications.NotifyPayoutTx(db.DB, bob.ID, 1.25)
+	recent, _ := notifications.Recent(bob.ID, true, NotificationLimit)
+	if len(recent) != 1 || recent[0].Amount != 3.75 || recent[0].Message != "Mining paid out 3.75 crypto" {
+		t.Fatalf("expected the payouts to add up in one notification, got %+v", recent)
+	}
+	notifications.MarkRead(bob.ID, recent)
+
+	// Bob's backdoor was installed from inside another server's local network
+	db.Create(&models.BackdoorAccess{UserID: bob.ID, ServerPath: "1.1.1.1.localNetwork.10.0.0.1", ServiceName: "ssh", ExploitType: "remote_code_execution"})
+	db.Create(&models.BackdoorAccess{UserID: alice.ID, ServerPath: "10.0.0.1", ServiceName: "http", ExploitType: "sql_injection"})
+	notifications.NotifyIntrusion("10.0.0.1", alice.ID, "alice", "http")
+
+	unread, latest, _ := notifications.Unread(bob.ID)
+	if unread != 1 || latest.Kind != models.NotificationIntrusion {
+		t.Fatalf("expected bob to be warned of the intrusion, got %d %+v", unread, latest)
+	}
+	if unread, _, _ := notifications.Unread(alice.ID); unread != 0 {
+		t.Fatalf("expected the intruder not to be warned, got %d", unread)
+	}
+}
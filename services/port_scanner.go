@@ -0,0 +1,278 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"terminal-sh/models"
+)
+
+// ScanTiming is an nmap-style timing template. Slower templates spread their probes out and
+// are less likely to be noticed by the target.
+type ScanTiming struct {
+	Name            string
+	SpeedFactor     float64 // Multiplies the scan time
+	DetectionChance float64 // Chance the target logs the scan, 0 to 1
+}
+
+// ScanTimings are the timing templates -T0 to -T5.
+var ScanTimings = []ScanTiming{
+	{Name: "paranoid", SpeedFactor: 10.0, DetectionChance: 0.0},
+	{Name: "sneaky", SpeedFactor: 4.0, DetectionChance: 0.1},
+	{Name: "polite", SpeedFactor: 2.0, DetectionChance: 0.3},
+	{Name: "normal", SpeedFactor: 1.0, DetectionChance: 0.6},
+	{Name: "aggressive", SpeedFactor: 0.6, DetectionChance: 0.85},
+	{Name: "insane", SpeedFactor: 0.35, DetectionChance: 1.0},
+}
+
+// DefaultScanTiming is the timing template used without -T: normal.
+const DefaultScanTiming = 3
+
+// defaultScanPorts is the number of ports probed without -p, like nmap's top 1000.
+const defaultScanPorts = 1000
+
+// PortSpec is the set of ports to scan, parsed from a list like "22,80,8000-8100".
+// An empty PortSpec means the default ports.
+type PortSpec []portRange
+
+type portRange struct{ lo, hi int }
+
+// ParsePortSpec parses a comma-separated list of ports and port ranges.
+func ParsePortSpec(spec string) (PortSpec, error) {
+	var ports PortSpec
+	for _, part := range strings.Split(spec, ",") {
+		loStr, hiStr, isRange := strings.Cut(part, "-")
+		lo, err := strconv.Atoi(loStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port: %s", part)
+		}
+		hi := lo
+		if isRange {
+			if hi, err = strconv.Atoi(hiStr); err != nil {
+				return nil, fmt.Errorf("invalid port range: %s", part)
+			}
+		}
+		if lo < 1 || hi > 65535 || lo > hi {
+			return nil, fmt.Errorf("invalid port range: %s", part)
+		}
+		ports = append(ports, portRange{lo, hi})
+	}
+	return ports, nil
+}
+
+// Contains reports whether port is in the spec. The default ports include every service port.
+func (p PortSpec) Contains(port int) bool {
+	if len(p) == 0 {
+		return true
+	}
+	for _, r := range p {
+		if port >= r.lo && port <= r.hi {
+			return true
+		}
+	}
+	return false
+}
+
+// Count returns the number of ports probed.
+func (p PortSpec) Count() int {
+	if len(p) == 0 {
+		return defaultScanPorts
+	}
+	count := 0
+	for _, r := range p {
+		count += r.hi - r.lo + 1
+	}
+	return count
+}
+
+// PortResult is an open port found by a scan.
+type PortResult struct {
+	Port            int                    `json:"port"`
+	State           string                 `json:"state"`
+	Service         string                 `json:"service"`
+	Version         string                 `json:"version,omitempty"`
+	Vulnerabilities []models.Vulnerability `json:"vulnerabilities,omitempty"`
+}
+
+// HostResult is a host found up by a scan, with its open ports unless it was a ping sweep.
+type HostResult struct {
+	IP        string       `json:"ip"`
+	LatencyMs int          `json:"latency_ms"`
+	Ports     []PortResult `json:"ports,omitempty"`
+	Hidden    int          `json:"-"` // Vulnerabilities too advanced for the scanner to fingerprint
+}
+
+// ScanReport is the result of a scan, ready to be written out in any of the output formats.
+type ScanReport struct {
+	Command   string       `json:"command"`
+	Target    string       `json:"target"`
+	Timing    string       `json:"timing"`
+	PingOnly  bool         `json:"ping_only"`
+	Started   time.Time    `json:"started"`
+	Elapsed   float64      `json:"elapsed"`
+	HostsUp   int          `json:"hosts_up"`
+	HostsSeen int          `json:"hosts_scanned"`
+	Hosts     []HostResult `json:"hosts"`
+}
+
+// SweepTargets returns the known servers in target, which is an IP, a host name or a CIDR
+// network like 10.0.0.0/24, sorted by address.
+func (g *NetworkGraph) SweepTargets(target string) ([]string, error) {
+	if !strings.Contains(target, "/") {
+		if _, ok := g.servers[target]; !ok {
+			return nil, fmt.Errorf("%s: unknown host", target)
+		}
+		return []string{target}, nil
+	}
+	_, network, err := net.ParseCIDR(target)
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid network", target)
+	}
+	var ips []string
+	for ip := range g.servers {
+		if parsed := net.ParseIP(ip); parsed != nil && network.Contains(parsed) {
+			ips = append(ips, ip)
+		}
+	}
+	sort.Slice(ips, func(i, j int) bool {
+		return compareIPs(ips[i], ips[j]) < 0
+	})
+	return ips, nil
+}
+
+// ScanHost probes a server's open ports in ports. With a versionLevel above 0 (service/version
+// detection), open ports are fingerprinted and vulnerabilities up to that level revealed; the
+// rest are counted in Hidden.
+func ScanHost(server *models.Server, ports PortSpec, versionLevel int) HostResult {
+	result := HostResult{IP: server.IP}
+	services := append([]models.Service(nil), server.Services...)
+	sort.SliceStable(services, func(i, j int) bool { return services[i].Port < services[j].Port })
+
+	for _, svc := range services {
+		if svc.Port == 0 || !ports.Contains(svc.Port) {
+			continue
+		}
+		port := PortResult{Port: svc.Port, State: "open", Service: svc.Name}
+		if versionLevel > 0 {
+			port.Version = ServiceVersion(svc)
+			for _, vuln := range svc.Vulnerabilities {
+				if vuln.Level <= versionLevel {
+					port.Vulnerabilities = append(port.Vulnerabilities, vuln)
+				} else {
+					result.Hidden++
+				}
+			}
+		}
+		result.Ports = append(result.Ports, port)
+	}
+	return result
+}
+
+// serviceProducts holds the software a service reports: the outdated release on vulnerable
+// servers, then the patched one.
+var serviceProducts = map[string][2]string{
+	"ssh":    {"OpenSSH 7.2p2 Ubuntu 4ubuntu2.1", "OpenSSH 9.6p1 Ubuntu 3ubuntu13"},
+	"ftp":    {"vsftpd 2.3.4", "vsftpd 3.0.5"},
+	"telnet": {"BusyBox telnetd 1.19", "Linux telnetd 0.17"},
+	"http":   {"Apache httpd 2.4.49", "nginx 1.24.0"},
+	"https":  {"Apache httpd 2.4.49 (SSL)", "nginx 1.24.0 (SSL)"},
+	"mysql":  {"MySQL 5.5.62", "MySQL 8.0.36"},
+	"smtp":   {"Postfix smtpd 2.11", "Postfix smtpd 3.8"},
+	"rdp":    {"Microsoft Terminal Services 6.1", "Microsoft Terminal Services 10.0"},
+	"vnc":    {"RealVNC 4.1.1", "TigerVNC 1.13"},
+}
+
+// ServiceVersion returns the product and version a service identifies itself as.
+func ServiceVersion(svc models.Service) string {
+	products, ok := serviceProducts[svc.Name]
+	if !ok {
+		return svc.Name
+	}
+	if svc.Vulnerable && len(svc.Vulnerabilities) > 0 {
+		return products[0]
+	}
+	return products[1]
+}
+
+// FormatScanNormal formats a report like nmap's normal output (-oN).
+func FormatScanNormal(report *ScanReport) string {
+	var out strings.Builder
+	out.WriteString(fmt.Sprintf("# %s\n", report.Command))
+	out.WriteString(fmt.Sprintf("Starting scan at %s (timing: %s)\n", report.Started.Format("2006-01-02 15:04"), report.Timing))
+	for _, host := range report.Hosts {
+		out.WriteString(fmt.Sprintf("Scan report for %s\n", host.IP))
+		out.WriteString(fmt.Sprintf("Host is up (%.3fs latency).\n", float64(host.LatencyMs)/1000))
+		if report.PingOnly {
+			continue
+		}
+		if len(host.Ports) == 0 {
+			out.WriteString("All scanned ports are closed\n\n")
+			continue
+		}
+		out.WriteString(fmt.Sprintf("%-10s %-6s %-10s %s\n", "PORT", "STATE", "SERVICE", "VERSION"))
+		for _, port := range host.Ports {
+			out.WriteString(strings.TrimRight(fmt.Sprintf("%-10s %-6s %-10s %s", fmt.Sprintf("%d/tcp", port.Port), port.State, port.Service, port.Version), " ") + "\n")
+			for _, vuln := range port.Vulnerabilities {
+				out.WriteString(fmt.Sprintf("| %s: level %d\n", vuln.Type, vuln.Level))
+			}
+		}
+		if host.Hidden > 0 {
+			out.WriteString(fmt.Sprintf("%d vulnerabilities could not be fingerprinted (upgrade your scanner)\n", host.Hidden))
+		}
+		out.WriteString("\n")
+	}
+	out.WriteString(fmt.Sprintf("Scan done: %d IP addresses (%d hosts up) scanned in %.2f seconds\n", report.HostsSeen, report.HostsUp, report.Elapsed))
+	return out.String()
+}
+
+// FormatScanGrepable formats a report like nmap's grepable output (-oG): one line per host.
+func FormatScanGrepable(report *ScanReport) string {
+	var out strings.Builder
+	out.WriteString(fmt.Sprintf("# %s\n", report.Command))
+	for _, host := range report.Hosts {
+		if report.PingOnly {
+			out.WriteString(fmt.Sprintf("Host: %s ()\tStatus: Up\n", host.IP))
+			continue
+		}
+		ports := make([]string, len(host.Ports))
+		for i, port := range host.Ports {
+			ports[i] = fmt.Sprintf("%d/%s/tcp//%s//%s/", port.Port, port.State, port.Service, port.Version)
+		}
+		out.WriteString(fmt.Sprintf("Host: %s ()\tPorts: %s\n", host.IP, strings.Join(ports, ", ")))
+	}
+	out.WriteString(fmt.Sprintf("# Scan done: %d IP addresses (%d hosts up) scanned in %.2f seconds\n", report.HostsSeen, report.HostsUp, report.Elapsed))
+	return out.String()
+}
+
+// FormatScanJSON formats a report as indented JSON (-oJ).
+func FormatScanJSON(report *ScanReport) (string, error) {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode scan report: %w", err)
+	}
+	return string(data) + "\n", nil
+}
+
+// compareIPs orders IPv4 addresses numerically and anything else after them by name.
+func compareIPs(a, b string) int {
+	ipA, ipB := net.ParseIP(a).To4(), net.ParseIP(b).To4()
+	switch {
+	case ipA != nil && ipB != nil:
+		for i := range ipA {
+			if ipA[i] != ipB[i] {
+				return int(ipA[i]) - int(ipB[i])
+			}
+		}
+		return 0
+	case ipA != nil:
+		return -1
+	case ipB != nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
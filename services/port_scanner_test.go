@@ -0,0 +1,60 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"terminal-sh/models"
+)
+
+func TestPingSweepFindsHostsInNetwork(t *testing.T) {
+	g := NewNetworkGraph([]models.Server{
+		{IP: "10.0.0.20"}, {IP: "10.0.0.3"}, {IP: "10.0.1.5"}, {IP: "test"},
+	})
+
+	ips, err := g.SweepTargets("10.0.0.0/24")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(ips, " ") != "10.0.0.3 10.0.0.20" {
+		t.Fatalf("expected the hosts in 10.0.0.0/24 in address order, got %v", ips)
+	}
+	if _, err := g.SweepTargets("10.0.0.0/33"); err == nil {
+		t.Fatal("expected an invalid network to be rejected")
+	}
+	if _, err := g.SweepTargets("10.9.9.9"); err == nil {
+		t.Fatal("expected an unknown host to be rejected")
+	}
+}
+
+func TestServiceDetectionNeedsScannerLevel(t *testing.T) {
+	server := &models.Server{IP: "203.0.113.42", Services: []models.Service{
+		{Name: "http", Port: 80, Vulnerable: true, Vulnerabilities: []models.Vulnerability{{Type: "xss", Level: 15}, {Type: "sql_injection", Level: 30}}},
+		{Name: "ssh", Port: 22},
+	}}
+
+	// A plain port scan shows open ports and nothing else
+	host := ScanHost(server, nil, 0)
+	if len(host.Ports) != 2 || host.Ports[0].Port != 22 || host.Ports[1].Version != "" || len(host.Ports[1].Vulnerabilities) != 0 {
+		t.Fatalf("expected bare open ports in port order, got %+v", host.Ports)
+	}
+
+	// -p limits the ports probed
+	ports, err := ParsePortSpec("70-90,443")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if host := ScanHost(server, ports, 0); len(host.Ports) != 1 || host.Ports[0].Port != 80 {
+		t.Fatalf("expected only port 80, got %+v", host.Ports)
+	}
+
+	// Version detection reveals the vulnerabilities the scanner is good enough for
+	host = ScanHost(server, nil, 20)
+	http := host.Ports[1]
+	if http.Version != "Apache httpd 2.4.49" || len(http.Vulnerabilities) != 1 || http.Vulnerabilities[0].Type != "xss" || host.Hidden != 1 {
+		t.Fatalf("expected the level 15 vulnerability only, got %+v (hidden %d)", http, host.Hidden)
+	}
+	if !strings.Contains(FormatScanGrepable(&ScanReport{Hosts: []HostResult{host}}), "80/open/tcp//http//Apache httpd 2.4.49/") {
+		t.Fatal("expected the grepable output to list the fingerprinted port")
+	}
+}
@@ -84,6 +84,65 @@ func (s *ProgressService) CalculateToolOperationTime(operationType OperationType
 	return baseTime / combinedMultiplier
 }
 
+// Transfer timing constants. Bandwidth is in the same units as Resources.Bandwidth (a new player
+// has 300); each unit moves transferBytesPerBandwidth bytes per second.
+const (
+	transferSetupTime         = 1.0  // Seconds to negotiate the connection
+	transferHopLatency        = 0.5  // Seconds added for every link between machines
+	transferBytesPerBandwidth = 10.0 // Bytes per second per unit of bandwidth
+	transferMaxTime           = 60.0 // Longest a single transfer may take
+)
+
+// CalculateTransferTime calculates the time to copy sizeBytes between machines.
+// bandwidths lists the bandwidth of every machine on the route in order, from source to
+// destination, including the hops in between; 0 marks a machine whose bandwidth is unknown.
+// The slowest known machine limits the transfer rate and every link adds latency.
+// Returns the transfer time in seconds.
+func (s *ProgressService) CalculateTransferTime(sizeBytes int, bandwidths []float64) float64 {
+	bottleneck := math.Inf(1)
+	for _, bandwidth := range bandwidths {
+		if bandwidth > 0 {
+			bottleneck = math.Min(bottleneck, math.Max(bandwidth, 1))
+		}
+	}
+	if math.IsInf(bottleneck, 1) {
+		bottleneck = 300 // Base user bandwidth
+	}
+
+	links := len(bandwidths) - 1
+	if links < 1 {
+		links = 1
+	}
+
+	seconds := transferSetupTime + float64(links)*transferHopLatency + float64(sizeBytes)/(bottleneck*transferBytesPerBandwidth)
+	return math.Min(seconds, transferMaxTime)
+}
+
+// CalculateRouteDelay calculates the extra time an operation takes when it's routed through
+// hops intermediate machines on its way to the target.
+// Returns the delay in seconds.
+func (s *ProgressService) CalculateRouteDelay(hops int) float64 {
+	if hops < 0 {
+		hops = 0
+	}
+	return float64(hops) * transferHopLatency
+}
+
+// Scan timing constants, at the normal timing template.
+const (
+	scanHostTime = 0.5   // Seconds to ping a host
+	scanPortTime = 0.002 // Seconds to probe one port on a host that's up
+	scanMaxTime  = 120.0 // Longest a single scan may take
+)
+
+// CalculateScanTime calculates the time a scan takes: pinging hosts addresses, then probing
+// ports ports on each of the hostsUp that answered, slowed down or sped up by the timing template.
+// Returns the scan time in seconds.
+func (s *ProgressService) CalculateScanTime(hosts, hostsUp, ports int, timing ScanTiming) float64 {
+	seconds := (float64(hosts)*scanHostTime + float64(hostsUp*ports)*scanPortTime) * timing.SpeedFactor
+	return math.Min(math.Max(seconds, 1.0), scanMaxTime)
+}
+
 // GetResourceMultiplier calculates the speed multiplier based on user resources
 func (s *ProgressService) GetResourceMultiplier(userResources models.Resources) float64 {
 	// Normalize resources (assuming base values: CPU=200, Bandwidth=300, RAM=24)
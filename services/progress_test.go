@@ -0,0 +1,25 @@
+package services
+
+import "testing"
+
+func TestTransferTimeDependsOnSizeAndRoute(t *testing.T) {
+	s := NewProgressService()
+
+	direct := s.CalculateTransferTime(3000, []float64{300, 5000})
+	if bigger := s.CalculateTransferTime(30000, []float64{300, 5000}); bigger <= direct {
+		t.Fatalf("expected a bigger file to take longer: %.2f vs %.2f", bigger, direct)
+	}
+	if hopped := s.CalculateTransferTime(3000, []float64{300, 5000, 5000, 5000}); hopped <= direct {
+		t.Fatalf("expected hops to add latency: %.2f vs %.2f", hopped, direct)
+	}
+	if slow := s.CalculateTransferTime(3000, []float64{300, 5000, 50}); slow <= direct {
+		t.Fatalf("expected a slow hop to limit the rate: %.2f vs %.2f", slow, direct)
+	}
+	// A machine of unknown bandwidth still adds its link, without limiting the rate
+	if unknown := s.CalculateTransferTime(3000, []float64{300, 0, 5000}); unknown != direct+transferHopLatency {
+		t.Fatalf("expected an unknown hop to add one link's latency: %.2f vs %.2f", unknown, direct)
+	}
+	if capped := s.CalculateTransferTime(1<<30, []float64{1}); capped != transferMaxTime {
+		t.Fatalf("expected transfer time to be capped, got %.2f", capped)
+	}
+}
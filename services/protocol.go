@@ -0,0 +1,289 @@
+package services
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"terminal-sh/models"
+)
+
+// WebRoot is the directory a server's HTTP service serves pages from.
+const WebRoot = "/var/www/html"
+
+// FTPRoot is the directory anonymous FTP users are locked into.
+const FTPRoot = "/srv/ftp"
+
+// FTPCommands are the commands available in an FTP session.
+var FTPCommands = []string{"ls", "dir", "cd", "pwd", "get", "put", "help", "clear", "bye", "quit", "exit"}
+
+// FindService returns the first service called name on a server, or nil.
+func FindService(server *models.Server, name string) *models.Service {
+	for i := range server.Services {
+		if server.Services[i].Name == name {
+			return &server.Services[i]
+		}
+	}
+	return nil
+}
+
+// HasVulnerability reports whether a service has a vulnerability of the given type.
+func HasVulnerability(svc *models.Service, vulnType string) bool {
+	if svc == nil || !svc.Vulnerable {
+		return false
+	}
+	for _, vuln := range svc.Vulnerabilities {
+		if vuln.Type == vulnType {
+			return true
+		}
+	}
+	return false
+}
+
+// ServiceBanner returns the greeting a service sends when a client connects: the service's
+// own Banner if it has one, otherwise one built from its version in the style of its protocol.
+func ServiceBanner(svc models.Service) string {
+	version := ServiceVersion(svc)
+	switch svc.Name {
+	case "ftp":
+		if svc.Banner != "" {
+			return "220 " + svc.Banner
+		}
+		return "220 (" + version + ")"
+	case "ssh":
+		if svc.Banner != "" {
+			return svc.Banner
+		}
+		return "SSH-2.0-" + strings.ReplaceAll(version, " ", "_")
+	case "mysql":
+		if svc.Banner != "" {
+			return svc.Banner
+		}
+		return "Server version: " + strings.TrimPrefix(version, "MySQL ") + " MySQL Community Server (GPL)"
+	}
+	if svc.Banner != "" {
+		return svc.Banner
+	}
+	return version
+}
+
+// WebRequest is an HTTP request to a server. Values holds the query string or form data.
+type WebRequest struct {
+	Method string
+	Path   string
+	Values url.Values
+}
+
+// WebResponse is a server's answer to a WebRequest.
+type WebResponse struct {
+	Status int
+	Server string
+	Body   string
+}
+
+// WebForm is an HTML form on a page: an injection point for sql_injector and xss_exploit.
+type WebForm struct {
+	Action string
+	Method string
+	Fields []string
+}
+
+// WebLink is a link on a page.
+type WebLink struct {
+	Href string
+	Text string
+}
+
+// ParseURL splits a URL like http://10.0.0.5/login.php?user=x into its host, path and query.
+// The scheme, port and path are optional.
+func ParseURL(raw string) (host, pagePath string, query url.Values, err error) {
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Hostname() == "" {
+		return "", "", nil, fmt.Errorf("%s: malformed URL", raw)
+	}
+	pagePath = parsed.Path
+	if pagePath == "" {
+		pagePath = "/"
+	}
+	return parsed.Hostname(), pagePath, parsed.Query(), nil
+}
+
+// DefaultWebPages returns the pages of a server with an HTTP service but no site of its own:
+// a home page linking a login form and a search form. Keyed by path under WebRoot.
+func DefaultWebPages(server *models.Server) map[string]string {
+	title := server.IP
+	if svc := FindService(server, "http"); svc != nil && svc.Description != "" {
+		title = svc.Description
+	}
+	title = html.EscapeString(title)
+	return map[string]string{
+		"index.html": "<html><head><title>" + title + "</title></head>\n<body>\n<h1>" + title + "</h1>\n" +
+			"<p>Welcome. Staff can sign in to the portal below.</p>\n" +
+			"<a href=\"/login.php\">Staff login</a>\n<a href=\"/search.php\">Search</a>\n</body></html>\n",
+		"login.php": "<html><head><title>Login</title></head>\n<body>\n<h1>Staff login</h1>\n" +
+			"<form action=\"/login.php\" method=\"post\">\n<input type=\"text\" name=\"username\">\n" +
+			"<input type=\"password\" name=\"password\">\n<input type=\"submit\" value=\"Sign in\">\n</form>\n</body></html>\n",
+		"search.php": "<html><head><title>Search</title></head>\n<body>\n<h1>Search</h1>\n" +
+			"<form action=\"/search.php\" method=\"get\">\n<input type=\"text\" name=\"q\">\n" +
+			"<input type=\"submit\" value=\"Search\">\n</form>\n</body></html>\n",
+	}
+}
+
+var (
+	formPattern  = regexp.MustCompile(`(?is)<form([^>]*)>(.*?)</form>`)
+	inputPattern = regexp.MustCompile(`(?is)<(?:input|textarea|select)([^>]*)>`)
+	linkPattern  = regexp.MustCompile(`(?is)<a([^>]*)>(.*?)</a>`)
+	titlePattern = regexp.MustCompile(`(?is)<title>(.*?)</title>`)
+	tagPattern   = regexp.MustCompile(`(?s)<[^>]*>`)
+	blankLines   = regexp.MustCompile(`\n{3,}`)
+	phpPattern   = regexp.MustCompile(`(?s)<\?php.*?(\?>\n?|$)`)
+
+	// Payloads that break out of a SQL string or comment out the rest of the query
+	sqlInjectionPattern = regexp.MustCompile(`(?i)('|\bor\b\s+\S+\s*=\s*\S+|\bunion\b\s+(all\s+)?select|--|#)`)
+	xssPattern          = regexp.MustCompile(`(?i)<\s*script|on\w+\s*=|javascript:`)
+)
+
+// htmlAttr returns the value of an attribute in a tag's attribute string.
+func htmlAttr(attrs, name string) string {
+	pattern := regexp.MustCompile(`(?i)\b` + name + `\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s>]+))`)
+	match := pattern.FindStringSubmatch(attrs)
+	if match == nil {
+		return ""
+	}
+	return match[1] + match[2] + match[3]
+}
+
+// ParseForms returns the forms on a page. Forms without an action submit to pagePath.
+func ParseForms(page, pagePath string) []WebForm {
+	var forms []WebForm
+	for _, match := range formPattern.FindAllStringSubmatch(page, -1) {
+		form := WebForm{Action: htmlAttr(match[1], "action"), Method: strings.ToUpper(htmlAttr(match[1], "method"))}
+		if form.Action == "" {
+			form.Action = pagePath
+		}
+		if form.Method == "" {
+			form.Method = "GET"
+		}
+		for _, input := range inputPattern.FindAllStringSubmatch(match[2], -1) {
+			if name := htmlAttr(input[1], "name"); name != "" {
+				form.Fields = append(form.Fields, name)
+			}
+		}
+		forms = append(forms, form)
+	}
+	return forms
+}
+
+// ParseLinks returns the links on a page.
+func ParseLinks(page string) []WebLink {
+	var links []WebLink
+	for _, match := range linkPattern.FindAllStringSubmatch(page, -1) {
+		links = append(links, WebLink{Href: htmlAttr(match[1], "href"), Text: strings.TrimSpace(tagPattern.ReplaceAllString(match[2], ""))})
+	}
+	return links
+}
+
+// PageTitle returns the title of a page, or "" if it has none.
+func PageTitle(page string) string {
+	if match := titlePattern.FindStringSubmatch(page); match != nil {
+		return html.UnescapeString(strings.TrimSpace(match[1]))
+	}
+	return ""
+}
+
+// PageText returns the readable text of a page, with the tags stripped.
+func PageText(page string) string {
+	body := titlePattern.ReplaceAllString(page, "")
+	body = formPattern.ReplaceAllString(body, "")
+	lines := strings.Split(html.UnescapeString(tagPattern.ReplaceAllString(body, "")), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	return strings.TrimSpace(blankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+}
+
+// ServeHTTP answers a request to a server's HTTP service, which the caller has checked it runs.
+// readFile reads a file from the server's filesystem. Pages are served from WebRoot with their
+// PHP code run (stripped, as the visitor never sees it). A request with values to a form's
+// action runs the form handler, which is injectable when the service has the matching
+// vulnerability: a SQL error leaks for sql_injection, input is echoed unescaped for xss.
+func ServeHTTP(server *models.Server, req WebRequest, readFile func(string) (string, error)) WebResponse {
+	svc := FindService(server, "http")
+	if svc == nil {
+		svc = FindService(server, "https")
+	}
+	resp := WebResponse{Status: 200}
+	if svc != nil {
+		resp.Server = ServiceVersion(*svc)
+	}
+
+	pagePath := path.Clean("/" + req.Path)
+	page, err := readFile(path.Join(WebRoot, pagePath))
+	if err != nil {
+		page, err = readFile(path.Join(WebRoot, pagePath, "index.html"))
+	}
+	page = phpPattern.ReplaceAllString(page, "")
+
+	// The form handling the request is on the page itself, or on the home page for actions
+	// that aren't files of their own
+	var form *WebForm
+	if len(req.Values) > 0 {
+		pages := []string{page}
+		if index, err := readFile(path.Join(WebRoot, "index.html")); err == nil {
+			pages = append(pages, index)
+		}
+		for _, candidatePage := range pages {
+			for _, candidate := range ParseForms(candidatePage, pagePath) {
+				if form == nil && path.Clean("/"+candidate.Action) == pagePath {
+					form = &candidate
+				}
+			}
+		}
+	}
+	if err != nil && form == nil {
+		resp.Status = 404
+		resp.Body = "<html><head><title>404 Not Found</title></head>\n<body>\n<h1>Not Found</h1>\n" +
+			"<p>The requested URL " + html.EscapeString(pagePath) + " was not found on this server.</p>\n</body></html>\n"
+		return resp
+	}
+	if form == nil {
+		resp.Body = page
+		return resp
+	}
+
+	var submitted []string
+	for _, field := range form.Fields {
+		if value := req.Values.Get(field); value != "" {
+			submitted = append(submitted, value)
+		}
+	}
+	for _, value := range submitted {
+		if sqlInjectionPattern.MatchString(value) && HasVulnerability(svc, "sql_injection") {
+			resp.Status = 500
+			resp.Body = "<html><body>\n<b>Warning</b>: mysqli_query(): You have an error in your SQL syntax; check the manual that " +
+				"corresponds to your MySQL server version for the right syntax to use near '" + html.EscapeString(value) + "' at line 1\n</body></html>\n"
+			return resp
+		}
+	}
+
+	var result strings.Builder
+	result.WriteString("<html><body>\n")
+	if form.Method == "POST" {
+		result.WriteString("<p>Invalid username or password.</p>\n")
+	} else {
+		query := strings.Join(submitted, " ")
+		if !(xssPattern.MatchString(query) && HasVulnerability(svc, "xss")) {
+			query = html.EscapeString(query)
+		}
+		result.WriteString("<p>No results for " + query + "</p>\n")
+	}
+	result.WriteString("</body></html>\n")
+	resp.Body = result.String()
+	return resp
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"testing"
+
+	"terminal-sh/models"
+)
+
+func TestServiceBannersFollowProtocol(t *testing.T) {
+	ftp := models.Service{Name: "ftp", Vulnerable: true, Vulnerabilities: []models.Vulnerability{{Type: "remote_code_execution", Level: 5}}}
+	if banner := ServiceBanner(ftp); banner != "220 (vsftpd 2.3.4)" {
+		t.Fatalf("expected an FTP greeting with the vulnerable version, got %q", banner)
+	}
+	telnet := models.Service{Name: "telnet", Banner: "SunOS 5.8"}
+	if banner := ServiceBanner(telnet); banner != "SunOS 5.8" {
+		t.Fatalf("expected the configured banner, got %q", banner)
+	}
+}
+
+func TestWebFormsAreInjectableWhenVulnerable(t *testing.T) {
+	server := &models.Server{IP: "203.0.113.42", Services: []models.Service{
+		{Name: "http", Port: 80, Vulnerable: true, Vulnerabilities: []models.Vulnerability{{Type: "xss", Level: 10}}},
+	}}
+	pages := DefaultWebPages(server)
+	readFile := func(p string) (string, error) {
+		if page, ok := pages[strings.TrimPrefix(p, WebRoot+"/")]; ok {
+			return page, nil
+		}
+		return "", fmt.Errorf("%s: not found", path.Base(p))
+	}
+
+	home := ServeHTTP(server, WebRequest{Method: "GET", Path: "/"}, readFile)
+	if home.Status != 200 || len(ParseLinks(home.Body)) != 2 {
+		t.Fatalf("expected the home page with two links, got %d %q", home.Status, home.Body)
+	}
+	if forms := ParseForms(pages["login.php"], "/login.php"); len(forms) != 1 || forms[0].Method != "POST" || strings.Join(forms[0].Fields, ",") != "username,password" {
+		t.Fatalf("expected the login form, got %+v", forms)
+	}
+
+	// Reflected input isn't escaped on a server vulnerable to xss
+	search := ServeHTTP(server, WebRequest{Method: "GET", Path: "/search.php", Values: url.Values{"q": {"<script>alert(1)</script>"}}}, readFile)
+	if !strings.Contains(search.Body, "<script>alert(1)</script>") {
+		t.Fatalf("expected the script to be reflected, got %q", search.Body)
+	}
+
+	// ...but SQL errors don't leak without sql_injection
+	login := ServeHTTP(server, WebRequest{Method: "POST", Path: "/login.php", Values: url.Values{"username": {"admin' OR '1'='1"}}}, readFile)
+	if login.Status != 200 || strings.Contains(login.Body, "SQL syntax") {
+		t.Fatalf("expected a plain login failure, got %d %q", login.Status, login.Body)
+	}
+	server.Services[0].Vulnerabilities = append(server.Services[0].Vulnerabilities, models.Vulnerability{Type: "sql_injection", Level: 10})
+	login = ServeHTTP(server, WebRequest{Method: "POST", Path: "/login.php", Values: url.Values{"username": {"admin' OR '1'='1"}}}, readFile)
+	if login.Status != 500 || !strings.Contains(login.Body, "SQL syntax") {
+		t.Fatalf("expected a leaked SQL error, got %d %q", login.Status, login.Body)
+	}
+
+	if missing := ServeHTTP(server, WebRequest{Method: "GET", Path: "/admin"}, readFile); missing.Status != 404 {
+		t.Fatalf("expected 404, got %d", missing.Status)
+	}
+}
+
+func TestQueryDatabaseDump(t *testing.T) {
+	db := ParseSQLDump("shop", "-- dump\n"+
+		"CREATE TABLE users (id INT PRIMARY KEY, username VARCHAR(64), note TEXT);\n"+
+		"INSERT INTO users VALUES (1,'admin','it''s me'),(2,'guest','a, b');\n"+
+		"INSERT INTO orders VALUES (7, 'pending');\n")
+
+	result, err := db.Query("SELECT username FROM users WHERE id = 2")
+	if err != nil || len(result.Rows) != 1 || result.Rows[0][0] != "guest" {
+		t.Fatalf("expected guest, got %+v (%v)", result, err)
+	}
+	result, err = db.Query("SELECT * FROM users WHERE username=admin")
+	if err != nil || len(result.Rows) != 1 || result.Rows[0][2] != "it's me" {
+		t.Fatalf("expected the admin row with its escaped quote, got %+v (%v)", result, err)
+	}
+	result, err = db.Query("SELECT col2 FROM orders")
+	if err != nil || result.Rows[0][0] != "pending" {
+		t.Fatalf("expected generated column names for a table without CREATE, got %+v (%v)", result, err)
+	}
+	if _, err := db.Query("DROP TABLE users"); err == nil {
+		t.Fatal("expected writes to be refused")
+	}
+	if out := FormatSQLResult(&SQLResult{Columns: []string{"id"}, Rows: [][]string{{"1"}}}); !strings.HasSuffix(out, "1 row in set\n") {
+		t.Fatalf("unexpected result format %q", out)
+	}
+}
@@ -16,6 +16,7 @@ type RewardService struct {
 	toolService        *ToolService
 	upgradeService     *UpgradeService
 	achievementService *AchievementService
+	ledger             *LedgerService
 }
 
 // NewRewardService creates a new RewardService
@@ -26,6 +27,7 @@ func NewRewardService(db *database.Database, userService *UserService, toolServi
 		toolService:        toolService,
 		upgradeService:     upgradeService,
 		achievementService: achievementService,
+		ledger:             NewLedgerService(db),
 	}
 }
 
@@ -40,12 +42,8 @@ func (s *RewardService) GrantRewards(userID uuid.UUID, rewards models.MissionRew
 
 	// Grant cryptocurrency
 	if rewards.Crypto > 0 {
-		user, err := s.userService.GetUserByID(userID)
-		if err != nil {
-			return fmt.Errorf("failed to get user: %w", err)
-		}
-		user.Wallet.Crypto += rewards.Crypto
-		if err := s.db.Save(user).Error; err != nil {
+		if _, err := s.ledger.Transfer(SystemAccount("missions"), UserAccount(userID), models.CurrencyCrypto,
+			rewards.Crypto, "mission_reward", ""); err != nil {
 			return fmt.Errorf("failed to grant crypto: %w", err)
 		}
 	}
@@ -127,73 +127,8 @@ func (s *RoleService) GetAllPrivilegeEscalations(userID uuid.UUID) ([]models.Pri
 
 // --- Permission Checking ---
 
-// FilePermission represents permissions for a file/directory.
-type FilePermission struct {
-	Path       string
-	Owner      string   // Username who owns this
-	Group      string   // Group that owns this
-	Mode       string   // e.g., "rwxr-xr-x"
-	RequiresRoot bool   // Whether only root can access
-}
-
-// DefaultFilePermissions returns default file permissions for common paths.
-func DefaultFilePermissions() map[string]FilePermission {
-	return map[string]FilePermission{
-		"/etc/passwd":       {Path: "/etc/passwd", Owner: "root", Group: "root", Mode: "rw-r--r--", RequiresRoot: false},
-		"/etc/shadow":       {Path: "/etc/shadow", Owner: "root", Group: "shadow", Mode: "rw-r-----", RequiresRoot: true},
-		"/etc/sudoers":      {Path: "/etc/sudoers", Owner: "root", Group: "root", Mode: "r--r-----", RequiresRoot: true},
-		"/root":             {Path: "/root", Owner: "root", Group: "root", Mode: "rwx------", RequiresRoot: true},
-		"/var/log/auth.log": {Path: "/var/log/auth.log", Owner: "root", Group: "adm", Mode: "rw-r-----", RequiresRoot: false},
-		"/var/log/syslog":   {Path: "/var/log/syslog", Owner: "root", Group: "adm", Mode: "rw-r-----", RequiresRoot: false},
-	}
-}
-
-// CanAccessPath checks if a role can access a given path.
-func CanAccessPath(path string, role *models.Role, isWrite bool) bool {
-	if role == nil {
-		return false
-	}
-
-	// Root can access everything
-	if role.IsRoot() {
-		return true
-	}
-
-	// Check default permissions
-	perms := DefaultFilePermissions()
-	
-	// Check exact path match
-	if perm, ok := perms[path]; ok {
-		if perm.RequiresRoot {
-			return false
-		}
-		// Owner check
-		if perm.Owner == role.Role {
-			return true
-		}
-	}
-
-	// Check if path is under a restricted directory
-	restrictedPaths := []string{"/root", "/etc/shadow", "/etc/sudoers"}
-	for _, restricted := range restrictedPaths {
-		if strings.HasPrefix(path, restricted) {
-			return false
-		}
-	}
-
-	// Check if trying to write to system directories
-	if isWrite {
-		systemDirs := []string{"/etc", "/usr", "/bin", "/sbin", "/var"}
-		for _, sysDir := range systemDirs {
-			if strings.HasPrefix(path, sysDir) {
-				return role.CanWriteSystem()
-			}
-		}
-	}
-
-	// Default: allow access
-	return true
-}
+// File permissions are enforced by the VFS itself from each node's owner, group and mode
+// (see filesystem.VFS.CanAccessPath); the connected role's groups come from Role.GetGroups.
 
 // CanRunCommand checks if a role can run a specific command.
 func CanRunCommand(command string, role *models.Role) (bool, string) {
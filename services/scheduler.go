@@ -0,0 +1,98 @@
+package services
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"terminal-sh/database"
+)
+
+// scheduledJob is a job the scheduler runs at a fixed interval.
+type scheduledJob struct {
+	name     string
+	interval time.Duration
+	run      func() error
+}
+
+// Scheduler runs jobs in the background at fixed intervals until it's stopped. A job that
+// fails is logged and runs again at its next interval.
+type Scheduler struct {
+	jobs []scheduledJob
+	stop chan struct{}
+	once sync.Once
+	wg   sync.WaitGroup
+}
+
+// NewScheduler creates a scheduler with no jobs.
+func NewScheduler() *Scheduler {
+	return &Scheduler{stop: make(chan struct{})}
+}
+
+// NewWorldScheduler creates a scheduler with the jobs that change the world between players'
+// commands: the sysadmins' patrols, mining payouts, the bounty board and live events, which
+// are announced through chatService.
+func NewWorldScheduler(db *database.Database, chatService *ChatService) *Scheduler {
+	serverService := NewServerService(db)
+	miningService := NewMiningService(db, NewToolService(db, serverService), serverService)
+	sysAdminService := NewSysAdminService(db, miningService)
+	eventService := NewEventService(db, chatService)
+	bountyService := NewBountyService(db, NewRoleService(db), NewCredentialService(db), NewActionTracker(db))
+
+	scheduler := NewScheduler()
+	scheduler.Every("sysadmin patrol", SysAdminPatrolInterval, func() error {
+		_, err := sysAdminService.Patrol()
+		return err
+	})
+	scheduler.Every("mining payouts", MiningPayoutInterval, func() error {
+		_, err := miningService.ProcessMiningRewards()
+		return err
+	})
+	scheduler.Every("bounties", BountyCheckInterval, func() error {
+		if _, err := bountyService.ProcessBounties(); err != nil {
+			return err
+		}
+		return bountyService.RefreshSystemBounties()
+	})
+	scheduler.Every("live events", EventCheckInterval, func() error {
+		if err := eventService.ProcessEvents(); err != nil {
+			return err
+		}
+		_, err := eventService.TriggerRandomEvent()
+		return err
+	})
+	return scheduler
+}
+
+// Every adds a job that runs once every interval. Jobs must be added before Start.
+func (s *Scheduler) Every(name string, interval time.Duration, run func() error) {
+	s.jobs = append(s.jobs, scheduledJob{name: name, interval: interval, run: run})
+}
+
+// Start runs each job in its own goroutine, first after one interval.
+func (s *Scheduler) Start() {
+	for _, job := range s.jobs {
+		s.wg.Add(1)
+		go func(job scheduledJob) {
+			defer s.wg.Done()
+			ticker := time.NewTicker(job.interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-s.stop:
+					return
+				case <-ticker.C:
+					if err := job.run(); err != nil {
+						log.Printf("scheduler: %s failed: %v", job.name, err)
+					}
+				}
+			}
+		}(job)
+	}
+}
+
+// Stop stops the scheduler and waits for any job that's running to finish.
+func (s *Scheduler) Stop() {
+	s.once.Do(func() { close(s.stop) })
+	s.wg.Wait()
+}
@@ -139,8 +139,13 @@ func seedServerWithLocalNetwork(db *database.Database, server *models.Server) er
 			}
 		} else if err != nil {
 			return fmt.Errorf("failed to check server %s: %w", server.IP, err)
+		} else if existing.SharedWorld != server.SharedWorld {
+			// Keep the filesystem mode in sync with servers.json
+			if err := db.Model(&existing).Update("shared_world", server.SharedWorld).Error; err != nil {
+				return fmt.Errorf("failed to update server %s: %w", server.IP, err)
+			}
 		}
-		// If server exists, skip it
+		// Otherwise, if server exists, skip it
 	return nil
 }
 
@@ -303,21 +308,24 @@ func seedShopsFromJSON(db *database.Database) error {
 
 	var shopData struct {
 		Shops []struct {
-			ServerIP        string        `json:"server_ip"`
-			Server          models.Server `json:"server"`
-			ShopType        string        `json:"shop_type"`
-			ShopName        string        `json:"shop_name"`
-			ShopDescription string        `json:"shop_description"`
-			RequiredMission string        `json:"required_mission,omitempty"`
-			RequiredLevel   int           `json:"required_level,omitempty"`
+			ServerIP        string             `json:"server_ip"`
+			Server          models.Server      `json:"server"`
+			ShopType        string             `json:"shop_type"`
+			ShopName        string             `json:"shop_name"`
+			ShopDescription string             `json:"shop_description"`
+			RequiredMission string             `json:"required_mission,omitempty"`
+			RequiredLevel   int                `json:"required_level,omitempty"`
+			Economy         models.ShopEconomy `json:"economy"`
 			Items           []struct {
-				ItemType    string  `json:"item_type"`
-				ItemID      string  `json:"item_id"`
-				Name        string  `json:"name"`
-				Description string  `json:"description"`
-				CryptoPrice float64 `json:"crypto_price"`
-				DataPrice   float64 `json:"data_price"`
-				Stock       int     `json:"stock"`
+				ItemType      string  `json:"item_type"`
+				ItemID        string  `json:"item_id"`
+				Name          string  `json:"name"`
+				Description   string  `json:"description"`
+				CryptoPrice   float64 `json:"crypto_price"`
+				DataPrice     float64 `json:"data_price"`
+				Stock         int     `json:"stock"`
+				MaxStock      int     `json:"max_stock,omitempty"`
+				PurchaseLimit int     `json:"purchase_limit,omitempty"`
 			} `json:"items"`
 		} `json:"shops"`
 	}
@@ -353,6 +361,8 @@ func seedShopsFromJSON(db *database.Database) error {
 			shopType = models.ShopTypeTools
 		case "resources":
 			shopType = models.ShopTypeResources
+		case "market":
+			shopType = models.ShopTypeMarket
 		default:
 			shopType = models.ShopTypeTools
 		}
@@ -369,6 +379,9 @@ func seedShopsFromJSON(db *database.Database) error {
 		if err != nil {
 			return fmt.Errorf("failed to create shop: %w", err)
 		}
+		if err := shopService.SetShopEconomy(shop.ID, shopDef.Economy); err != nil {
+			return err
+		}
 
 		// Add shop items
 		for _, item := range shopDef.Items {
@@ -384,7 +397,7 @@ func seedShopsFromJSON(db *database.Database) error {
 				itemType = models.ItemTypeTool
 			}
 
-			_, err = shopService.AddShopItem(shop.ID, itemType, item.Name, item.Description, item.CryptoPrice, item.DataPrice, item.Stock)
+			_, err = shopService.AddShopItemWithLimits(shop.ID, itemType, item.Name, item.Description, item.CryptoPrice, item.DataPrice, item.Stock, item.MaxStock, item.PurchaseLimit)
 			if err != nil {
 				return fmt.Errorf("failed to add shop item %s: %w", item.Name, err)
 			}
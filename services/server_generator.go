@@ -91,6 +91,13 @@ func (g *ServerGenerator) CheckAndGenerateServers(userID uuid.UUID) error {
 			return fmt.Errorf("failed to generate server: %w", err)
 		}
 
+		// Some of the servers that keep the world stocked are traps; mission servers never are
+		if g.maybeHoneypot(server, user.Level) {
+			if err := g.db.Save(server).Error; err != nil {
+				return fmt.Errorf("failed to save honeypot: %w", err)
+			}
+		}
+
 		// Track as procedural server
 		proceduralServer := &models.ProceduralServer{
 			ServerID:     server.ID,
@@ -157,6 +164,21 @@ func (g *ServerGenerator) GenerateServerForMission(mission *models.Mission) (*mo
 	return server, nil
 }
 
+// GenerateHeistServer creates the bank server of a heist event: an ordinary server of the
+// given level with an empty crypto wallet, for the event to fund the vault through the ledger.
+// It isn't tracked as a procedural server, since the event takes it down again.
+func (g *ServerGenerator) GenerateHeistServer(level int) (*models.Server, error) {
+	server, err := g.generateServerWithDifficulty(level, "")
+	if err != nil {
+		return nil, err
+	}
+	server.Wallet.Crypto = 0
+	if err := g.db.Model(server).Select("wallet").Updates(server).Error; err != nil {
+		return nil, fmt.Errorf("failed to empty vault: %w", err)
+	}
+	return server, nil
+}
+
 // GenerateServerChain creates a chain of connected servers
 func (g *ServerGenerator) GenerateServerChain(count int, parentIP string) ([]models.Server, error) {
 	var servers []models.Server
@@ -253,7 +275,7 @@ func (g *ServerGenerator) generateServerWithDifficulty(level int, serverType str
 	server.Services = g.generateServicesForDifficulty(level, serverType)
 	server.Roles = g.generateRolesForLevel(level)
 	server.LocalVulnerabilities = g.generateLocalVulnerabilities(level)
-	server.FileSystem = g.generateFileSystem(server.Roles)
+	server.FileSystem = g.generateFileSystem(level, server.Roles)
 
 	// Update resources based on level (higher level = better resources)
 	server.Resources.CPU = 1000 + (level * 100) + g.rng.Intn(500)
@@ -297,7 +319,7 @@ func (g *ServerGenerator) generateLocalServerForParent(parentIP string, parentLe
 	server.Services = g.generateServicesForDifficulty(localLevel, "")
 	server.Roles = g.generateRolesForLevel(localLevel)
 	server.LocalVulnerabilities = g.generateLocalVulnerabilities(localLevel)
-	server.FileSystem = g.generateFileSystem(server.Roles)
+	server.FileSystem = g.generateFileSystem(localLevel, server.Roles)
 
 	if err := g.serverService.db.Save(server).Error; err != nil {
 		return nil, fmt.Errorf("failed to update local server: %w", err)
@@ -533,6 +555,13 @@ func (g *ServerGenerator) generateLocalVulnerabilities(level int) []models.Local
 			Target:      "/opt/backup/backup_tool",
 			GrantsRoot:  true,
 		},
+		{
+			Type:        "writable_path",
+			Level:       baseVulnLevel + 2 + g.rng.Intn(5),
+			Description: "Root's cron job runs a command from a world-writable directory in its PATH",
+			Target:      "/usr/local/bin/backup",
+			GrantsRoot:  true,
+		},
 		{
 			Type:        "kernel_exploit",
 			Level:       baseVulnLevel + 8 + g.rng.Intn(8),
@@ -565,7 +594,9 @@ func (g *ServerGenerator) generateLocalVulnerabilities(level int) []models.Local
 	return result
 }
 
-func (g *ServerGenerator) generateFileSystem(roles []models.Role) map[string]interface{} {
+// generateFileSystem builds the filesystem of a generated server: home directories for its roles,
+// the usual system files, and loot that gets harder to get at with level (see generateLoot).
+func (g *ServerGenerator) generateFileSystem(level int, roles []models.Role) map[string]interface{} {
 	homeDirs := map[string]interface{}{}
 	for _, role := range roles {
 		if role.Role == "root" {
@@ -579,7 +610,7 @@ func (g *ServerGenerator) generateFileSystem(roles []models.Role) map[string]int
 		}
 	}
 
-	return map[string]interface{}{
+	fs := map[string]interface{}{
 		"etc": map[string]interface{}{
 			"motd":  map[string]interface{}{"content": "Authorized access only."},
 			"passwd": map[string]interface{}{"content": "root:x:0:0:root:/root:/bin/bash\nuser:x:1000:1000:User:/home/user:/bin/bash\nadmin:x:1001:1001:Admin:/home/admin:/bin/bash"},
@@ -591,6 +622,8 @@ func (g *ServerGenerator) generateFileSystem(roles []models.Role) map[string]int
 		},
 		"home": homeDirs,
 	}
+	g.generateLoot(fs, level, roles)
+	return fs
 }
 
 // Helper functions
@@ -751,12 +784,13 @@ func (g *ServerGenerator) RecycleServer(server *models.Server, playerLevel int)
 	}
 	
 	server.SecurityLevel = minSecurity + g.rng.Intn(maxSecurity-minSecurity+1)
+	server.SecurityBoost = 0
 	
 	// Regenerate services and vulnerabilities
 	server.Services = g.generateServicesForDifficulty(playerLevel, "")
 	server.Roles = g.generateRolesForLevel(playerLevel)
 	server.LocalVulnerabilities = g.generateLocalVulnerabilities(playerLevel)
-	server.FileSystem = g.generateFileSystem(server.Roles)
+	server.FileSystem = g.generateFileSystem(playerLevel, server.Roles)
 	
 	// Reset resources
 	server.Resources.CPU = 1000 + (playerLevel * 100) + g.rng.Intn(500)
@@ -766,6 +800,7 @@ func (g *ServerGenerator) RecycleServer(server *models.Server, playerLevel int)
 	// Reset wallet
 	server.Wallet.Crypto = float64(1000 + (playerLevel * 50) + g.rng.Intn(500))
 	server.Wallet.Data = float64(1000000 + (playerLevel * 100000) + g.rng.Intn(1000000))
+	g.maybeHoneypot(server, playerLevel)
 	
 	return g.db.Save(server).Error
 }
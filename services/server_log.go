@@ -145,6 +145,22 @@ func (s *ServerLogService) LogScan(serverIP, sourceIP string, userID *uuid.UUID)
 	return s.db.Create(log).Error
 }
 
+// LogRoute logs traffic a server forwarded from sourceIP to destIP, e.g. a connection or
+// traceroute probe routed through it.
+func (s *ServerLogService) LogRoute(serverIP, sourceIP, destIP string, userID *uuid.UUID, message string) error {
+	log := &models.ServerLog{
+		ServerIP:  serverIP,
+		LogType:   models.LogTypeRoute,
+		SourceIP:  sourceIP,
+		UserID:    userID,
+		Message:   message,
+		Details:   destIP,
+		Success:   true,
+		CreatedAt: time.Now(),
+	}
+	return s.db.Create(log).Error
+}
+
 // LogSystem logs a system event on a server.
 func (s *ServerLogService) LogSystem(serverIP, message string) error {
 	log := &models.ServerLog{
@@ -200,6 +216,7 @@ func (s *ServerLogService) GetSystemLogs(serverIP string, limit int) ([]models.S
 		models.LogTypeFileWrite,
 		models.LogTypeScan,
 		models.LogTypeSystem,
+		models.LogTypeRoute,
 	})
 	if limit > 0 {
 		query = query.Limit(limit)
@@ -249,6 +266,40 @@ func (s *ServerLogService) GetUserActivityOnServer(serverIP string, userID uuid.
 	return logs, err
 }
 
+// SessionIdleTimeout is how long a connection in auth.log counts as a live session without
+// a disconnection after it. Players who close their terminal never log out.
+const SessionIdleTimeout = 8 * time.Hour
+
+// ActiveSessions returns each player's last connection to a server, according to its
+// auth.log, if they connected in the last SessionIdleTimeout and haven't disconnected since.
+func (s *ServerLogService) ActiveSessions(serverIP string) ([]models.ServerLog, error) {
+	var entries []models.ServerLog
+	err := s.db.Where("server_ip = ? AND user_id IS NOT NULL AND created_at > ? AND log_type IN ?", serverIP,
+		time.Now().Add(-SessionIdleTimeout),
+		[]models.LogType{models.LogTypeConnect, models.LogTypeSSHConnect, models.LogTypeDisconnect, models.LogTypeSSHDisconnect}).
+		Order("created_at ASC").Find(&entries).Error
+	if err != nil {
+		return nil, err
+	}
+
+	last := make(map[uuid.UUID]int)
+	var order []uuid.UUID
+	for i, entry := range entries {
+		if _, seen := last[*entry.UserID]; !seen {
+			order = append(order, *entry.UserID)
+		}
+		last[*entry.UserID] = i
+	}
+	var sessions []models.ServerLog
+	for _, userID := range order {
+		entry := entries[last[userID]]
+		if entry.Success && (entry.LogType == models.LogTypeConnect || entry.LogType == models.LogTypeSSHConnect) {
+			sessions = append(sessions, entry)
+		}
+	}
+	return sessions, nil
+}
+
 // CleanOldLogs removes logs older than the specified duration.
 func (s *ServerLogService) CleanOldLogs(maxAge time.Duration) error {
 	cutoff := time.Now().Add(-maxAge)
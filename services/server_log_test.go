@@ -0,0 +1,33 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"terminal-sh/models"
+)
+
+func TestActiveSessionsFollowConnectsAndDisconnects(t *testing.T) {
+	db := newTestDatabase(t)
+	users := NewUserService(db, "test-secret")
+	alice, _ := users.Register("alice", "correct-horse")
+	bob, _ := users.Register("bob", "battery-staple")
+	carol, _ := users.Register("carol", "tr0ub4dor-3")
+	logs := NewServerLogService(db)
+
+	// Alice is still on, bob logged out, and carol connected too long ago to count
+	logs.LogConnect("10.0.0.1", alice.IP, "alice", &alice.ID, "ssh", true)
+	logs.LogConnect("10.0.0.1", bob.IP, "bob", &bob.ID, "telnet", true)
+	logs.LogDisconnect("10.0.0.1", bob.IP, "bob", &bob.ID, "telnet")
+	logs.LogConnect("10.0.0.1", carol.IP, "carol", &carol.ID, "ssh", true)
+	db.Model(&models.ServerLog{}).Where("user_id = ?", carol.ID).
+		Update("created_at", time.Now().Add(-SessionIdleTimeout-time.Minute))
+
+	sessions, err := logs.ActiveSessions("10.0.0.1")
+	if err != nil {
+		t.Fatalf("failed to get sessions: %v", err)
+	}
+	if len(sessions) != 1 || *sessions[0].UserID != alice.ID || sessions[0].ServiceType != "ssh" {
+		t.Fatalf("expected only alice's ssh session, got %+v", sessions)
+	}
+}
@@ -0,0 +1,46 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"terminal-sh/filesystem"
+	"terminal-sh/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GetServerOverlay returns the user's filesystem overlay for a server.
+// Returns nil if the user hasn't changed anything on it.
+func (s *ServerService) GetServerOverlay(userID, serverID uuid.UUID) (map[string]interface{}, error) {
+	var overlay models.ServerOverlay
+	err := s.db.Where("user_id = ? AND server_id = ?", userID, serverID).First(&overlay).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load filesystem overlay: %w", err)
+	}
+	fs, _ := filesystem.MigrateOverlay(overlay.FileSystem, overlay.Version)
+	return fs, nil
+}
+
+// SaveServerOverlay stores the user's filesystem overlay for a server, replacing any previous one.
+func (s *ServerService) SaveServerOverlay(userID, serverID uuid.UUID, fs map[string]interface{}) error {
+	overlay := &models.ServerOverlay{
+		UserID:     userID,
+		ServerID:   serverID,
+		FileSystem: fs,
+		Version:    filesystem.ChangesVersion,
+	}
+	err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "server_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"file_system", "version", "updated_at"}),
+	}).Create(overlay).Error
+	if err != nil {
+		return fmt.Errorf("failed to save filesystem overlay: %w", err)
+	}
+	return nil
+}
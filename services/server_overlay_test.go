@@ -0,0 +1,41 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+
+	"terminal-sh/models"
+
+	"github.com/google/uuid"
+)
+
+func TestServerOverlayIsPerPlayer(t *testing.T) {
+	db := newTestDatabase(t)
+	serverService := NewServerService(db)
+	server := &models.Server{IP: "target", LocalIP: "10.0.0.9"}
+	if err := db.Create(server).Error; err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+
+	player := uuid.New()
+	first := map[string]interface{}{"tmp": map[string]interface{}{"a.txt": map[string]interface{}{"content": "a"}}}
+	second := map[string]interface{}{"tmp": map[string]interface{}{"b.txt": map[string]interface{}{"content": "b"}}}
+	if err := serverService.SaveServerOverlay(player, server.ID, first); err != nil {
+		t.Fatalf("failed to save overlay: %v", err)
+	}
+
+	// Saving again replaces the player's overlay
+	if err := serverService.SaveServerOverlay(player, server.ID, second); err != nil {
+		t.Fatalf("failed to save overlay: %v", err)
+	}
+	stored, err := serverService.GetServerOverlay(player, server.ID)
+	if err != nil || !reflect.DeepEqual(stored, second) {
+		t.Fatalf("expected the latest overlay, got %v (%v)", stored, err)
+	}
+
+	// Everyone else still gets the untouched base image
+	other, err := serverService.GetServerOverlay(uuid.New(), server.ID)
+	if err != nil || other != nil {
+		t.Fatalf("expected no overlay for another player, got %v (%v)", other, err)
+	}
+}
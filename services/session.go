@@ -92,3 +92,30 @@ func (s *SessionService) BuildServerPath(sessions []*models.Session) string {
 
 	return path
 }
+
+// SaveInterruptedOperation records a progress operation that was cut short by a server shutdown.
+func (s *SessionService) SaveInterruptedOperation(op *models.InterruptedOperation) error {
+	if op.CreatedAt.IsZero() {
+		op.CreatedAt = time.Now()
+	}
+	if err := s.db.Create(op).Error; err != nil {
+		return fmt.Errorf("failed to save interrupted operation: %w", err)
+	}
+	return nil
+}
+
+// TakeInterruptedOperations returns all interrupted operations for a user, oldest first, and deletes them
+// so each operation is resumed at most once.
+func (s *SessionService) TakeInterruptedOperations(userID uuid.UUID) ([]models.InterruptedOperation, error) {
+	var ops []models.InterruptedOperation
+	if err := s.db.Where("user_id = ?", userID).Order("created_at ASC").Find(&ops).Error; err != nil {
+		return nil, fmt.Errorf("failed to load interrupted operations: %w", err)
+	}
+	if len(ops) == 0 {
+		return nil, nil
+	}
+	if err := s.db.Delete(&ops).Error; err != nil {
+		return nil, fmt.Errorf("failed to clear interrupted operations: %w", err)
+	}
+	return ops, nil
+}
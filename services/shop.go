@@ -1,19 +1,36 @@
 package services
 
 import (
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"terminal-sh/database"
 	"terminal-sh/models"
+	"terminal-sh/patch"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrNoUpgradeToken is returned when a user has no unused upgrade token of a type.
+	ErrNoUpgradeToken = errors.New("no upgrade token of that type")
+	// ErrOutOfStock is returned when a shop item has sold out until it restocks.
+	ErrOutOfStock = errors.New("item out of stock")
+	// ErrPurchaseLimit is returned when a user has bought as many of an item as one player may.
+	ErrPurchaseLimit = errors.New("purchase limit reached")
+	// ErrNotOnOffer is returned when a rotating shop isn't offering an item right now.
+	ErrNotOnOffer = errors.New("item is not on offer right now")
 )
 
 // ShopService handles shop-related operations including creation, item management, and purchases.
 type ShopService struct {
 	db            *database.Database
 	serverService *ServerService
+	ledger        *LedgerService
 }
 
 // NewShopService creates a new ShopService with the provided database and server service.
@@ -21,6 +38,7 @@ func NewShopService(db *database.Database, serverService *ServerService) *ShopSe
 	return &ShopService{
 		db:            db,
 		serverService: serverService,
+		ledger:        NewLedgerService(db),
 	}
 }
 
@@ -51,75 +69,139 @@ func (s *ShopService) GetShopItems(shopID uuid.UUID) ([]models.ShopItem, error)
 	return items, nil
 }
 
-// PurchaseItem purchases an item from a shop
-func (s *ShopService) PurchaseItem(userID uuid.UUID, shopID uuid.UUID, itemID uuid.UUID) error {
-	// Get shop item
-	var item models.ShopItem
-	if err := s.db.Where("id = ? AND shop_id = ?", itemID, shopID).First(&item).Error; err != nil {
-		return fmt.Errorf("item not found in shop")
+// PurchaseItem purchases an item from a shop's current rotation at its current price and
+// returns the purchase, with what was paid.
+func (s *ShopService) PurchaseItem(userID uuid.UUID, shopID uuid.UUID, itemID uuid.UUID) (*models.UserPurchase, error) {
+	var shop models.Shop
+	if err := s.db.First(&shop, "id = ?", shopID).Error; err != nil {
+		return nil, fmt.Errorf("shop not found")
+	}
+
+	// Get shop item, restocked if it's due
+	now := time.Now()
+	offered, err := s.offeredItems(&shop, now)
+	if err != nil {
+		return nil, err
+	}
+	var item *models.ShopItem
+	for i := range offered {
+		if offered[i].ID == itemID {
+			item = &offered[i]
+			break
+		}
+	}
+	if item == nil {
+		if err := s.db.Where("id = ? AND shop_id = ?", itemID, shopID).First(&models.ShopItem{}).Error; err == nil {
+			return nil, ErrNotOnOffer
+		}
+		return nil, fmt.Errorf("item not found in shop")
 	}
 
 	// Check stock
-	if item.Stock >= 0 && item.Stock == 0 {
-		return fmt.Errorf("item out of stock")
+	if item.Stock == 0 {
+		return nil, ErrOutOfStock
 	}
 
 	// Get user
 	var user models.User
 	if err := s.db.First(&user, "id = ?", userID).Error; err != nil {
-		return fmt.Errorf("user not found")
+		return nil, fmt.Errorf("user not found")
 	}
 
 	// Check if user has enough currency
-	if item.PriceCrypto > 0 && user.Wallet.Crypto < item.PriceCrypto {
-		return fmt.Errorf("insufficient crypto currency (need %.2f, have %.2f)", item.PriceCrypto, user.Wallet.Crypto)
-	}
-	if item.PriceData > 0 && user.Wallet.Data < item.PriceData {
-		return fmt.Errorf("insufficient data currency (need %.2f, have %.2f)", item.PriceData, user.Wallet.Data)
-	}
-
-	// Deduct currency
-	user.Wallet.Crypto -= item.PriceCrypto
-	user.Wallet.Data -= item.PriceData
-	if err := s.db.Save(&user).Error; err != nil {
-		return fmt.Errorf("failed to update wallet: %w", err)
+	priceCrypto, priceData, _ := s.quote(&shop, item, now)
+	if priceCrypto > 0 && user.Wallet.Crypto < priceCrypto {
+		return nil, fmt.Errorf("insufficient crypto currency (need %.2f, have %.2f)", priceCrypto, user.Wallet.Crypto)
 	}
-
-	// Reduce stock if not unlimited
-	if item.Stock > 0 {
-		item.Stock--
-		if err := s.db.Save(&item).Error; err != nil {
-			return fmt.Errorf("failed to update stock: %w", err)
-		}
+	if priceData > 0 && user.Wallet.Data < priceData {
+		return nil, fmt.Errorf("insufficient data currency (need %.2f, have %.2f)", priceData, user.Wallet.Data)
 	}
 
-	// Record purchase
+	// Deduct currency, reduce stock and record the purchase together
 	purchase := &models.UserPurchase{
 		UserID:      userID,
 		ShopID:      shopID,
 		ItemID:      itemID,
 		ItemName:    item.Name,
 		ItemType:    item.ItemType,
-		PriceCrypto: item.PriceCrypto,
-		PriceData:   item.PriceData,
+		PriceCrypto: priceCrypto,
+		PriceData:   priceData,
 	}
-	if err := s.db.Create(purchase).Error; err != nil {
-		return fmt.Errorf("failed to record purchase: %w", err)
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if item.PurchaseLimit > 0 {
+			// Lock the buyer so their concurrent purchases are counted one after another
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.User{}, "id = ?", userID).Error; err != nil {
+				return fmt.Errorf("user not found")
+			}
+			var bought int64
+			if err := tx.Model(&models.UserPurchase{}).Where("user_id = ? AND item_id = ?", userID, itemID).Count(&bought).Error; err != nil {
+				return err
+			}
+			if bought >= int64(item.PurchaseLimit) {
+				return fmt.Errorf("%w (%d per player)", ErrPurchaseLimit, item.PurchaseLimit)
+			}
+		}
+
+		// Reduce stock if not unlimited. The first sale from a full stock starts the restock timer.
+		if item.Stock > 0 {
+			updates := map[string]interface{}{"stock": gorm.Expr("stock - 1")}
+			if item.Stock >= item.MaxStock {
+				updates["restocked_at"] = now
+			}
+			result := tx.Model(&models.ShopItem{}).Where("id = ? AND stock > 0", item.ID).Updates(updates)
+			if result.Error != nil {
+				return fmt.Errorf("failed to update stock: %w", result.Error)
+			}
+			if result.RowsAffected == 0 {
+				return ErrOutOfStock
+			}
+		}
+
+		if priceCrypto > 0 {
+			if _, err := s.ledger.TransferTx(tx, UserAccount(userID), SystemAccount("shop"), models.CurrencyCrypto,
+				priceCrypto, "purchase", item.ID.String()); err != nil {
+				return fmt.Errorf("failed to update wallet: %w", err)
+			}
+		}
+		if priceData > 0 {
+			if _, err := s.ledger.TransferTx(tx, UserAccount(userID), SystemAccount("shop"), models.CurrencyData,
+				priceData, "purchase", item.ID.String()); err != nil {
+				return fmt.Errorf("failed to update wallet: %w", err)
+			}
+		}
+
+		// Record purchase
+		if err := tx.Create(purchase).Error; err != nil {
+			return fmt.Errorf("failed to record purchase: %w", err)
+		}
+
+		// Upgrade tokens are held until applied with patch or traded
+		if item.ItemType == models.ItemTypeUpgradeToken {
+			upgradeType, _ := patch.ParseUpgradeType(UpgradeTypeForToken(item.Name))
+			token := &models.UpgradeToken{UserID: userID, Name: item.Name, UpgradeType: string(upgradeType)}
+			if err := tx.Create(token).Error; err != nil {
+				return fmt.Errorf("failed to add upgrade token: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	// Handle item based on type
 	switch item.ItemType {
 	case models.ItemTypeTool:
 		// Tool will be added via tool service
-		return nil
+		return purchase, nil
 	case models.ItemTypeUpgradeToken:
 		// Upgrade token - will be applied via upgrade service
-		return nil
+		return purchase, nil
 	case models.ItemTypeResource:
 		// Resource upgrade
-		return s.applyResourceUpgrade(&user, item.Name)
+		return purchase, s.applyResourceUpgrade(&user, item.Name)
 	default:
-		return fmt.Errorf("unknown item type")
+		return purchase, fmt.Errorf("unknown item type")
 	}
 }
 
@@ -142,7 +224,8 @@ func (s *ShopService) applyResourceUpgrade(user *models.User, upgradeName string
 	user.Resources.Bandwidth += upgrade.Bandwidth
 	user.Resources.RAM += upgrade.RAM
 
-	if err := s.db.Save(user).Error; err != nil {
+	// Only write resources: the wallet may have changed since the user was loaded
+	if err := s.db.Model(user).Select("resources").Updates(user).Error; err != nil {
 		return fmt.Errorf("failed to apply resource upgrade: %w", err)
 	}
 
@@ -182,14 +265,23 @@ func (s *ShopService) CreateShopWithRequirements(serverIP string, shopType model
 
 // AddShopItem adds an item to a shop
 func (s *ShopService) AddShopItem(shopID uuid.UUID, itemType models.ItemType, name, description string, priceCrypto, priceData float64, stock int) (*models.ShopItem, error) {
+	return s.AddShopItemWithLimits(shopID, itemType, name, description, priceCrypto, priceData, stock, 0, 0)
+}
+
+// AddShopItemWithLimits adds an item to a shop that restocks up to maxStock and that each
+// player can buy at most purchaseLimit of (0 for either means no restocking or no limit)
+func (s *ShopService) AddShopItemWithLimits(shopID uuid.UUID, itemType models.ItemType, name, description string, priceCrypto, priceData float64, stock, maxStock, purchaseLimit int) (*models.ShopItem, error) {
 	item := &models.ShopItem{
-		ShopID:      shopID,
-		ItemType:    itemType,
-		Name:        name,
-		Description: description,
-		PriceCrypto:  priceCrypto,
-		PriceData:    priceData,
-		Stock:        stock,
+		ShopID:        shopID,
+		ItemType:      itemType,
+		Name:          name,
+		Description:   description,
+		PriceCrypto:   priceCrypto,
+		PriceData:     priceData,
+		Stock:         stock,
+		MaxStock:      maxStock,
+		RestockedAt:   time.Now(),
+		PurchaseLimit: purchaseLimit,
 	}
 
 	if err := s.db.Create(item).Error; err != nil {
@@ -199,3 +291,58 @@ func (s *ShopService) AddShopItem(shopID uuid.UUID, itemType models.ItemType, na
 	return item, nil
 }
 
+// SetShopEconomy sets how a shop restocks, reprices and rotates its items
+func (s *ShopService) SetShopEconomy(shopID uuid.UUID, economy models.ShopEconomy) error {
+	shop := &models.Shop{ID: shopID, Economy: economy}
+	if err := s.db.Model(shop).Select("economy").Updates(shop).Error; err != nil {
+		return fmt.Errorf("failed to update shop economy: %w", err)
+	}
+	return nil
+}
+
+// UpgradeTypeForToken returns the upgrade type an upgrade token grants, from its name.
+func UpgradeTypeForToken(name string) string {
+	nameLower := strings.ToLower(name)
+	switch {
+	case strings.Contains(nameLower, "exploit"):
+		return "exploit"
+	case strings.Contains(nameLower, "cpu"):
+		return "cpu"
+	case strings.Contains(nameLower, "ram"):
+		return "ram"
+	case strings.Contains(nameLower, "bandwidth") || strings.Contains(nameLower, "bw"):
+		return "bw"
+	case strings.Contains(nameLower, "full") || strings.Contains(nameLower, "tune"):
+		return "full"
+	}
+	return "exploit" // default
+}
+
+// GetUpgradeTokens returns the upgrade tokens a user holds, leaving out any in escrow.
+func (s *ShopService) GetUpgradeTokens(userID uuid.UUID) ([]models.UpgradeToken, error) {
+	var tokens []models.UpgradeToken
+	err := s.db.Where("user_id = ? AND escrow_id IS NULL", userID).Order("created_at").Find(&tokens).Error
+	return tokens, err
+}
+
+// UseUpgradeToken takes one of the user's upgrade tokens of a type out of their inventory.
+// Returns ErrNoUpgradeToken if they hold none.
+func (s *ShopService) UseUpgradeToken(userID uuid.UUID, upgradeType patch.UpgradeType) (*models.UpgradeToken, error) {
+	var token models.UpgradeToken
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND upgrade_type = ? AND escrow_id IS NULL", userID, upgradeType).
+			Order("created_at").First(&token).Error; err != nil {
+			return ErrNoUpgradeToken
+		}
+		return tx.Delete(&token).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// ReturnUpgradeToken puts a token taken with UseUpgradeToken back, for when applying it failed.
+func (s *ShopService) ReturnUpgradeToken(token *models.UpgradeToken) error {
+	return s.db.Create(token).Error
+}
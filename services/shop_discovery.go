@@ -11,6 +11,18 @@ import (
 	"github.com/google/uuid"
 )
 
+// discoveredShopEconomy is the economy of shops found on servers whose metadata doesn't set
+// one: a handful of their items on offer at a time, rotating every few hours, with prices that
+// climb as players buy.
+var discoveredShopEconomy = models.ShopEconomy{
+	RestockMinutes:      120,
+	DemandWindowMinutes: 60,
+	PriceStep:           0.05,
+	MaxMarkup:           0.5,
+	RotationSize:        4,
+	RotationMinutes:     360,
+}
+
 // ShopDiscovery handles shop discovery logic, automatically creating shops on servers when discovered.
 type ShopDiscovery struct {
 	db            *database.Database
@@ -92,16 +104,19 @@ func (s *ShopDiscovery) findShopMetadataInFilesystem(fs map[string]interface{})
 // ParseShopMetadata parses shop metadata from JSON content
 func (s *ShopDiscovery) ParseShopMetadata(serverIP, fileContent string) (*models.Shop, error) {
 	var shopData struct {
-		ShopType    string `json:"shop_type"`
-		Name        string `json:"name"`
-		Description string `json:"description"`
+		ShopType    string              `json:"shop_type"`
+		Name        string              `json:"name"`
+		Description string              `json:"description"`
+		Economy     *models.ShopEconomy `json:"economy"`
 		Items       []struct {
-			Type        string  `json:"type"`
-			Name        string  `json:"name"`
-			Description string  `json:"description"`
-			PriceCrypto float64 `json:"price_crypto"`
-			PriceData   float64 `json:"price_data"`
-			Stock       int     `json:"stock"`
+			Type          string  `json:"type"`
+			Name          string  `json:"name"`
+			Description   string  `json:"description"`
+			PriceCrypto   float64 `json:"price_crypto"`
+			PriceData     float64 `json:"price_data"`
+			Stock         int     `json:"stock"`
+			MaxStock      int     `json:"max_stock"`
+			PurchaseLimit int     `json:"purchase_limit"`
 		} `json:"items"`
 	}
 
@@ -120,6 +135,16 @@ func (s *ShopDiscovery) ParseShopMetadata(serverIP, fileContent string) (*models
 		return nil, err
 	}
 
+	// Shops found in the wild rotate their stock unless their metadata says otherwise
+	economy := discoveredShopEconomy
+	if shopData.Economy != nil {
+		economy = *shopData.Economy
+	}
+	if err := s.shopService.SetShopEconomy(shop.ID, economy); err != nil {
+		return nil, err
+	}
+	shop.Economy = economy
+
 	// Add items
 	for _, itemData := range shopData.Items {
 		itemType := models.ItemType(itemData.Type)
@@ -131,8 +156,13 @@ func (s *ShopDiscovery) ParseShopMetadata(serverIP, fileContent string) (*models
 		if stock == 0 {
 			stock = -1 // Unlimited
 		}
+		// Finite stock refills to what the shop started with unless the metadata says otherwise
+		maxStock := itemData.MaxStock
+		if maxStock == 0 && stock > 0 {
+			maxStock = stock
+		}
 
-		_, err := s.shopService.AddShopItem(
+		_, err := s.shopService.AddShopItemWithLimits(
 			shop.ID,
 			itemType,
 			itemData.Name,
@@ -140,6 +170,8 @@ func (s *ShopDiscovery) ParseShopMetadata(serverIP, fileContent string) (*models
 			itemData.PriceCrypto,
 			itemData.PriceData,
 			stock,
+			maxStock,
+			itemData.PurchaseLimit,
 		)
 		if err != nil {
 			// Log error but continue
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sort"
+	"time"
+
+	"terminal-sh/models"
+
+	"github.com/google/uuid"
+)
+
+// ShopOffer is a shop item as it's offered right now, priced for current demand and any sale.
+type ShopOffer struct {
+	Item        models.ShopItem
+	PriceCrypto float64
+	PriceData   float64
+	Demand      int64 // Purchases by all players within the shop's demand window
+	Bought      int64 // Purchases by the player, counted against the item's purchase limit
+}
+
+// GetShopOffers returns the items a shop has on offer, restocked and priced for now, along
+// with how many of each the user has already bought.
+func (s *ShopService) GetShopOffers(shop *models.Shop, userID uuid.UUID) ([]ShopOffer, error) {
+	now := time.Now()
+	items, err := s.offeredItems(shop, now)
+	if err != nil {
+		return nil, err
+	}
+
+	offers := make([]ShopOffer, 0, len(items))
+	for _, item := range items {
+		offer := ShopOffer{Item: item}
+		offer.PriceCrypto, offer.PriceData, offer.Demand = s.quote(shop, &item, now)
+		if item.PurchaseLimit > 0 {
+			offer.Bought = s.countPurchases(userID, item.ID)
+		}
+		offers = append(offers, offer)
+	}
+	return offers, nil
+}
+
+// NextRotation returns when a shop's rotating inventory next changes, or the zero time if the
+// shop offers everything it has.
+func NextRotation(shop *models.Shop, now time.Time) time.Time {
+	if shop.Economy.RotationSize <= 0 || shop.Economy.RotationMinutes <= 0 {
+		return time.Time{}
+	}
+	period := int64(shop.Economy.RotationMinutes) * 60
+	return time.Unix((now.Unix()/period+1)*period, 0)
+}
+
+// StartSale discounts a shop item by a fraction (0.25 for 25% off) for a duration.
+func (s *ShopService) StartSale(itemID uuid.UUID, discount float64, duration time.Duration) (*models.ShopItem, error) {
+	if discount <= 0 || discount >= 1 || duration <= 0 {
+		return nil, fmt.Errorf("invalid sale: discount must be between 0 and 1 and the duration positive")
+	}
+	var item models.ShopItem
+	if err := s.db.First(&item, "id = ?", itemID).Error; err != nil {
+		return nil, fmt.Errorf("item not found")
+	}
+	endsAt := time.Now().Add(duration)
+	item.SaleDiscount = discount
+	item.SaleEndsAt = &endsAt
+	if err := s.db.Model(&item).Select("sale_discount", "sale_ends_at").Updates(&item).Error; err != nil {
+		return nil, fmt.Errorf("failed to start sale: %w", err)
+	}
+	return &item, nil
+}
+
+// offeredItems returns the items in a shop's current rotation, restocking any that are due.
+func (s *ShopService) offeredItems(shop *models.Shop, now time.Time) ([]models.ShopItem, error) {
+	var items []models.ShopItem
+	if err := s.db.Where("shop_id = ?", shop.ID).Order("created_at, id").Find(&items).Error; err != nil {
+		return nil, err
+	}
+	for i := range items {
+		s.restock(shop, &items[i], now)
+	}
+	return rotate(shop, items, now), nil
+}
+
+// restock refills an item to its max stock once the shop's restock interval has passed since
+// its stock first dropped below the max.
+func (s *ShopService) restock(shop *models.Shop, item *models.ShopItem, now time.Time) {
+	interval := time.Duration(shop.Economy.RestockMinutes) * time.Minute
+	if interval <= 0 || item.MaxStock <= 0 || item.Stock < 0 || item.Stock >= item.MaxStock {
+		return
+	}
+	if item.RestockedAt.IsZero() {
+		// Items from before restocking existed start their timer now
+		s.db.Model(&models.ShopItem{}).Where("id = ?", item.ID).Update("restocked_at", now)
+		item.RestockedAt = now
+		return
+	}
+	if now.Sub(item.RestockedAt) < interval {
+		return
+	}
+
+	// Only refill the stock that was read, so a purchase made in between isn't undone
+	result := s.db.Model(&models.ShopItem{}).Where("id = ? AND stock = ?", item.ID, item.Stock).
+		Updates(map[string]interface{}{"stock": item.MaxStock, "restocked_at": now})
+	if result.Error == nil && result.RowsAffected == 1 {
+		item.Stock = item.MaxStock
+		item.RestockedAt = now
+	}
+}
+
+// rotate picks the items a rotating shop offers at a given time. Items are ranked by a hash of
+// their ID and the rotation period, so every player sees the same selection until it rotates.
+func rotate(shop *models.Shop, items []models.ShopItem, now time.Time) []models.ShopItem {
+	size := shop.Economy.RotationSize
+	if size <= 0 || shop.Economy.RotationMinutes <= 0 || size >= len(items) {
+		return items
+	}
+	period := now.Unix() / (int64(shop.Economy.RotationMinutes) * 60)
+
+	rank := make(map[uuid.UUID]uint64, len(items))
+	for _, item := range items {
+		h := fnv.New64a()
+		fmt.Fprintf(h, "%s:%d", item.ID, period)
+		rank[item.ID] = h.Sum64()
+	}
+	ranked := append([]models.ShopItem(nil), items...)
+	sort.Slice(ranked, func(i, j int) bool { return rank[ranked[i].ID] < rank[ranked[j].ID] })
+	chosen := make(map[uuid.UUID]bool, size)
+	for _, item := range ranked[:size] {
+		chosen[item.ID] = true
+	}
+
+	// Keep the shop's own order so item numbers read naturally
+	offered := make([]models.ShopItem, 0, size)
+	for _, item := range items {
+		if chosen[item.ID] {
+			offered = append(offered, item)
+		}
+	}
+	return offered
+}
+
+// quote prices an item for now: its base price marked up by recent purchases across all
+// players, up to the shop's cap, then discounted if it's on sale.
+func (s *ShopService) quote(shop *models.Shop, item *models.ShopItem, now time.Time) (crypto, data float64, demand int64) {
+	factor := 1.0
+	economy := shop.Economy
+	if economy.PriceStep > 0 && economy.DemandWindowMinutes > 0 {
+		since := now.Add(-time.Duration(economy.DemandWindowMinutes) * time.Minute)
+		s.db.Model(&models.UserPurchase{}).Where("item_id = ? AND created_at > ?", item.ID, since).Count(&demand)
+		markup := economy.PriceStep * float64(demand)
+		if economy.MaxMarkup > 0 {
+			markup = math.Min(markup, economy.MaxMarkup)
+		}
+		factor += markup
+	}
+	if item.OnSale(now) {
+		factor *= 1 - item.SaleDiscount
+	}
+	return roundPrice(item.PriceCrypto * factor), roundPrice(item.PriceData * factor), demand
+}
+
+// countPurchases returns how many of a shop item a user has bought.
+func (s *ShopService) countPurchases(userID, itemID uuid.UUID) int64 {
+	var count int64
+	s.db.Model(&models.UserPurchase{}).Where("user_id = ? AND item_id = ?", userID, itemID).Count(&count)
+	return count
+}
+
+// roundPrice rounds a price to whole cents.
+func roundPrice(price float64) float64 {
+	return math.Round(price*100) / 100
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"terminal-sh/models"
+
+	"github.com/google/uuid"
+)
+
+func TestShopPricesFollowDemandAndStockRestocks(t *testing.T) {
+	db := newTestDatabase(t)
+	shops := NewShopService(db, NewServerService(db))
+	shop, err := shops.CreateShop("9.9.9.9", models.ShopTypeResources, "Test Shop", "")
+	if err != nil {
+		t.Fatalf("failed to create shop: %v", err)
+	}
+	economy := models.ShopEconomy{RestockMinutes: 30, DemandWindowMinutes: 60, PriceStep: 0.1, MaxMarkup: 0.2}
+	if err := shops.SetShopEconomy(shop.ID, economy); err != nil {
+		t.Fatalf("failed to set economy: %v", err)
+	}
+	shop.Economy = economy
+	item, err := shops.AddShopItemWithLimits(shop.ID, models.ItemTypeUpgradeToken, "CPU Optimizer Token", "", 100, 0, 3, 3, 2)
+	if err != nil {
+		t.Fatalf("failed to add item: %v", err)
+	}
+
+	users := NewUserService(db, "test-secret")
+	alice, _ := users.Register("alice", "correct-horse")
+	bob, _ := users.Register("bob", "battery-staple")
+	for _, u := range []*models.User{alice, bob} {
+		u.Wallet.Crypto = 1000
+		db.Model(u).Select("wallet").Updates(u)
+	}
+
+	// Each purchase in the demand window raises the price by 10%, capped at 20%
+	var paid []float64
+	for _, buyer := range []*models.User{alice, alice, bob} {
+		purchase, err := shops.PurchaseItem(buyer.ID, shop.ID, item.ID)
+		if err != nil {
+			t.Fatalf("failed to purchase: %v", err)
+		}
+		paid = append(paid, purchase.PriceCrypto)
+	}
+	if paid[0] != 100 || paid[1] != 110 || paid[2] != 120 {
+		t.Fatalf("expected prices 100, 110, 120, got %v", paid)
+	}
+	if _, err := shops.PurchaseItem(bob.ID, shop.ID, item.ID); !errors.Is(err, ErrOutOfStock) {
+		t.Fatalf("expected the item to be sold out, got %v", err)
+	}
+
+	// Once the restock interval passes, the stock refills, but alice is at her limit
+	db.Model(&models.ShopItem{}).Where("id = ?", item.ID).Update("restocked_at", time.Now().Add(-31*time.Minute))
+	offers, err := shops.GetShopOffers(shop, alice.ID)
+	if err != nil || len(offers) != 1 {
+		t.Fatalf("expected one offer, got %v (%v)", offers, err)
+	}
+	if offers[0].Item.Stock != 3 || offers[0].Bought != 2 || offers[0].PriceCrypto != 120 {
+		t.Fatalf("expected a full restock at the capped price, got %+v", offers[0])
+	}
+	if _, err := shops.PurchaseItem(alice.ID, shop.ID, item.ID); !errors.Is(err, ErrPurchaseLimit) {
+		t.Fatalf("expected alice to hit her purchase limit, got %v", err)
+	}
+
+	// A sale discounts the demand price
+	if _, err := shops.StartSale(item.ID, 0.5, time.Hour); err != nil {
+		t.Fatalf("failed to start sale: %v", err)
+	}
+	purchase, err := shops.PurchaseItem(bob.ID, shop.ID, item.ID)
+	if err != nil || purchase.PriceCrypto != 60 {
+		t.Fatalf("expected bob to pay 60 on sale, got %+v (%v)", purchase, err)
+	}
+}
+
+func TestRotatingShopOffersTheSameItemsToEveryone(t *testing.T) {
+	shop := &models.Shop{Economy: models.ShopEconomy{RotationSize: 2, RotationMinutes: 60}}
+	var items []models.ShopItem
+	for i := 0; i < 6; i++ {
+		items = append(items, models.ShopItem{ID: uuid.New()})
+	}
+
+	now := time.Date(2026, 1, 1, 12, 10, 0, 0, time.UTC)
+	offered := rotate(shop, items, now)
+	if len(offered) != 2 {
+		t.Fatalf("expected 2 items on offer, got %d", len(offered))
+	}
+	again := rotate(shop, items, now.Add(40*time.Minute))
+	if again[0].ID != offered[0].ID || again[1].ID != offered[1].ID {
+		t.Fatal("expected the same items until the rotation")
+	}
+	if next := NextRotation(shop, now); !next.Equal(time.Date(2026, 1, 1, 13, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected the next rotation on the hour, got %v", next)
+	}
+}
+
+func TestDiscoveredShopRestocksToItsStartingStock(t *testing.T) {
+	db := newTestDatabase(t)
+	servers := NewServerService(db)
+	shops := NewShopService(db, servers)
+	discovery := NewShopDiscovery(shops, servers, NewToolService(db, servers))
+	shop, err := discovery.ParseShopMetadata("9.9.9.8", `{"name": "Back Alley", "items": [
+		{"type": "upgrade_token", "name": "CPU Optimizer Token", "price_crypto": 100, "stock": 4},
+		{"type": "upgrade_token", "name": "RAM Optimizer Token", "price_crypto": 100}]}`)
+	if err != nil {
+		t.Fatalf("failed to parse shop metadata: %v", err)
+	}
+
+	items, _ := shops.GetShopItems(shop.ID)
+	maxStock := make(map[string]int)
+	for _, item := range items {
+		maxStock[item.Name] = item.MaxStock
+	}
+	if maxStock["CPU Optimizer Token"] != 4 || maxStock["RAM Optimizer Token"] != 0 {
+		t.Fatalf("expected finite stock to refill to 4 and unlimited stock to never restock, got %v", maxStock)
+	}
+}
@@ -0,0 +1,370 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MySQLDataDir is where a server's databases live, one SQL dump per database.
+const MySQLDataDir = "/var/lib/mysql"
+
+// SQLDatabase is a database loaded from a SQL dump, queryable with the mysql client.
+type SQLDatabase struct {
+	Name   string
+	Tables map[string]*SQLTable
+	order  []string // Table names in the order they were created
+}
+
+// SQLTable is a table in an SQLDatabase.
+type SQLTable struct {
+	Name    string
+	Columns []string
+	Rows    [][]string
+}
+
+// SQLResult is the result of a query: a table of values, or just a message for statements
+// that don't return rows.
+type SQLResult struct {
+	Columns []string
+	Rows    [][]string
+	Message string
+}
+
+var (
+	createTablePattern = regexp.MustCompile(`(?is)^CREATE\s+TABLE\s+(?:IF\s+NOT\s+EXISTS\s+)?` + "`?" + `(\w+)` + "`?" + `\s*\((.*)\)$`)
+	insertPattern      = regexp.MustCompile(`(?is)^INSERT\s+INTO\s+` + "`?" + `(\w+)` + "`?" + `\s*(?:\(([^)]*)\))?\s*VALUES\s*(.*)$`)
+	selectPattern      = regexp.MustCompile(`(?is)^SELECT\s+(.+?)\s+FROM\s+` + "`?" + `([\w.]+)` + "`?" + `(?:\s+WHERE\s+(\w+)\s*=\s*(.+?))?(?:\s+LIMIT\s+(\d+))?$`)
+	describePattern    = regexp.MustCompile(`(?is)^(?:DESCRIBE|DESC|SHOW\s+COLUMNS\s+FROM)\s+` + "`?" + `(\w+)` + "`?" + `$`)
+)
+
+// ParseSQLDump loads a database from a SQL dump: CREATE TABLE and INSERT statements.
+// Tables that are inserted into without being created get columns named col1, col2...
+func ParseSQLDump(name, dump string) *SQLDatabase {
+	db := &SQLDatabase{Name: name, Tables: make(map[string]*SQLTable)}
+	for _, stmt := range splitSQLStatements(stripSQLComments(dump)) {
+		if match := createTablePattern.FindStringSubmatch(stmt); match != nil {
+			table := db.table(match[1])
+			table.Columns = nil
+			for _, def := range splitSQLList(match[2]) {
+				fields := strings.Fields(def)
+				if len(fields) == 0 || isSQLConstraint(fields[0]) {
+					continue
+				}
+				table.Columns = append(table.Columns, strings.Trim(fields[0], "`"))
+			}
+			continue
+		}
+		if match := insertPattern.FindStringSubmatch(stmt); match != nil {
+			table := db.table(match[1])
+			if match[2] != "" && len(table.Columns) == 0 {
+				for _, column := range splitSQLList(match[2]) {
+					table.Columns = append(table.Columns, strings.Trim(strings.TrimSpace(column), "`"))
+				}
+			}
+			for _, tuple := range splitSQLTuples(match[3]) {
+				row := splitSQLList(tuple)
+				for i := range row {
+					row[i] = sqlValue(row[i])
+				}
+				for len(table.Columns) < len(row) {
+					table.Columns = append(table.Columns, fmt.Sprintf("col%d", len(table.Columns)+1))
+				}
+				table.Rows = append(table.Rows, row)
+			}
+		}
+	}
+	return db
+}
+
+// table returns the table called name, creating it if needed.
+func (db *SQLDatabase) table(name string) *SQLTable {
+	table, ok := db.Tables[name]
+	if !ok {
+		table = &SQLTable{Name: name}
+		db.Tables[name] = table
+		db.order = append(db.order, name)
+	}
+	return table
+}
+
+// Query runs a single read-only statement against the database: SHOW TABLES, DESCRIBE and
+// SELECT with an optional single WHERE column = value and LIMIT. String values may be quoted
+// or bare.
+func (db *SQLDatabase) Query(stmt string) (*SQLResult, error) {
+	stmt = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(stmt), ";"))
+	upper := strings.ToUpper(stmt)
+
+	switch {
+	case upper == "SHOW TABLES":
+		result := &SQLResult{Columns: []string{"Tables_in_" + db.Name}}
+		for _, name := range db.order {
+			result.Rows = append(result.Rows, []string{name})
+		}
+		return result, nil
+	case describePattern.MatchString(stmt):
+		name := describePattern.FindStringSubmatch(stmt)[1]
+		table, ok := db.Tables[name]
+		if !ok {
+			return nil, fmt.Errorf("ERROR 1146 (42S02): Table '%s.%s' doesn't exist", db.Name, name)
+		}
+		result := &SQLResult{Columns: []string{"Field"}}
+		for _, column := range table.Columns {
+			result.Rows = append(result.Rows, []string{column})
+		}
+		return result, nil
+	case selectPattern.MatchString(stmt):
+		return db.selectRows(selectPattern.FindStringSubmatch(stmt))
+	case strings.HasPrefix(upper, "INSERT") || strings.HasPrefix(upper, "UPDATE") ||
+		strings.HasPrefix(upper, "DELETE") || strings.HasPrefix(upper, "DROP") || strings.HasPrefix(upper, "CREATE"):
+		return nil, fmt.Errorf("ERROR 1290 (HY000): The MySQL server is running with the --read-only option so it cannot execute this statement")
+	}
+	return nil, fmt.Errorf("ERROR 1064 (42000): You have an error in your SQL syntax near '%s'", stmt)
+}
+
+// selectRows runs a parsed SELECT.
+func (db *SQLDatabase) selectRows(match []string) (*SQLResult, error) {
+	name := match[2]
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	table, ok := db.Tables[name]
+	if !ok {
+		return nil, fmt.Errorf("ERROR 1146 (42S02): Table '%s.%s' doesn't exist", db.Name, name)
+	}
+
+	// Columns to return, by index
+	var indexes []int
+	result := &SQLResult{}
+	if strings.TrimSpace(match[1]) == "*" {
+		for i, column := range table.Columns {
+			indexes = append(indexes, i)
+			result.Columns = append(result.Columns, column)
+		}
+	} else if strings.EqualFold(strings.ReplaceAll(match[1], " ", ""), "COUNT(*)") {
+		count := len(table.filter(match[3], match[4]))
+		result.Columns = []string{"COUNT(*)"}
+		result.Rows = [][]string{{strconv.Itoa(count)}}
+		return result, nil
+	} else {
+		for _, column := range splitSQLList(match[1]) {
+			column = strings.Trim(strings.TrimSpace(column), "`")
+			i := table.column(column)
+			if i < 0 {
+				return nil, fmt.Errorf("ERROR 1054 (42S22): Unknown column '%s' in 'field list'", column)
+			}
+			indexes = append(indexes, i)
+			result.Columns = append(result.Columns, column)
+		}
+	}
+	if match[3] != "" && table.column(match[3]) < 0 {
+		return nil, fmt.Errorf("ERROR 1054 (42S22): Unknown column '%s' in 'where clause'", match[3])
+	}
+
+	rows := table.filter(match[3], match[4])
+	if match[5] != "" {
+		if limit, err := strconv.Atoi(match[5]); err == nil && limit < len(rows) {
+			rows = rows[:limit]
+		}
+	}
+	for _, row := range rows {
+		values := make([]string, len(indexes))
+		for i, index := range indexes {
+			if index < len(row) {
+				values[i] = row[index]
+			} else {
+				values[i] = "NULL"
+			}
+		}
+		result.Rows = append(result.Rows, values)
+	}
+	return result, nil
+}
+
+// column returns the index of a column, or -1.
+func (t *SQLTable) column(name string) int {
+	for i, column := range t.Columns {
+		if strings.EqualFold(column, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// filter returns the rows where column equals value, or every row if column is empty.
+func (t *SQLTable) filter(column, value string) [][]string {
+	if column == "" {
+		return t.Rows
+	}
+	index := t.column(column)
+	value = sqlValue(strings.TrimSpace(value))
+	var rows [][]string
+	for _, row := range t.Rows {
+		if index >= 0 && index < len(row) && row[index] == value {
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// FormatSQLResult formats a result the way the mysql client prints it: a boxed table and
+// a row count.
+func FormatSQLResult(result *SQLResult) string {
+	if result.Columns == nil {
+		return result.Message + "\n"
+	}
+	if len(result.Rows) == 0 {
+		return "Empty set\n"
+	}
+
+	widths := make([]int, len(result.Columns))
+	for i, column := range result.Columns {
+		widths[i] = len(column)
+	}
+	for _, row := range result.Rows {
+		for i, value := range row {
+			if i < len(widths) && len(value) > widths[i] {
+				widths[i] = len(value)
+			}
+		}
+	}
+
+	var border strings.Builder
+	border.WriteString("+")
+	for _, width := range widths {
+		border.WriteString(strings.Repeat("-", width+2) + "+")
+	}
+	line := func(values []string) string {
+		var b strings.Builder
+		b.WriteString("|")
+		for i, width := range widths {
+			value := ""
+			if i < len(values) {
+				value = values[i]
+			}
+			fmt.Fprintf(&b, " %-*s |", width, value)
+		}
+		return b.String()
+	}
+
+	var out strings.Builder
+	out.WriteString(border.String() + "\n")
+	out.WriteString(line(result.Columns) + "\n")
+	out.WriteString(border.String() + "\n")
+	for _, row := range result.Rows {
+		out.WriteString(line(row) + "\n")
+	}
+	out.WriteString(border.String() + "\n")
+	noun := "rows"
+	if len(result.Rows) == 1 {
+		noun = "row"
+	}
+	fmt.Fprintf(&out, "%d %s in set\n", len(result.Rows), noun)
+	return out.String()
+}
+
+// SortedDatabaseNames returns the names of a set of databases in order.
+func SortedDatabaseNames(databases map[string]*SQLDatabase) []string {
+	names := make([]string, 0, len(databases))
+	for name := range databases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// stripSQLComments removes -- comment lines from a dump.
+func stripSQLComments(dump string) string {
+	lines := strings.Split(dump, "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		if !strings.HasPrefix(strings.TrimSpace(line), "--") {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+// splitSQLStatements splits on semicolons outside quoted strings.
+func splitSQLStatements(sql string) []string {
+	var stmts []string
+	for _, stmt := range splitOutsideQuotes(sql, ';', false) {
+		if stmt = strings.TrimSpace(stmt); stmt != "" {
+			stmts = append(stmts, stmt)
+		}
+	}
+	return stmts
+}
+
+// splitSQLList splits a comma-separated list, leaving commas in quotes and parentheses alone.
+func splitSQLList(list string) []string {
+	parts := splitOutsideQuotes(list, ',', true)
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	return parts
+}
+
+// splitSQLTuples splits "(1,'a'),(2,'b')" into "1,'a'" and "2,'b'".
+func splitSQLTuples(values string) []string {
+	var tuples []string
+	for _, part := range splitSQLList(values) {
+		part = strings.TrimSpace(part)
+		if strings.HasPrefix(part, "(") && strings.HasSuffix(part, ")") {
+			tuples = append(tuples, part[1:len(part)-1])
+		}
+	}
+	return tuples
+}
+
+// splitOutsideQuotes splits s on sep where it isn't inside a quoted string, or, if nested is
+// set, inside parentheses.
+func splitOutsideQuotes(s string, sep rune, nested bool) []string {
+	var parts []string
+	var current strings.Builder
+	var quote rune
+	depth := 0
+	for _, c := range s {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case nested && c == '(':
+			depth++
+		case nested && c == ')':
+			depth--
+		case c == sep && depth == 0:
+			parts = append(parts, current.String())
+			current.Reset()
+			continue
+		}
+		current.WriteRune(c)
+	}
+	if strings.TrimSpace(current.String()) != "" {
+		parts = append(parts, current.String())
+	}
+	return parts
+}
+
+// sqlValue unquotes a SQL literal.
+func sqlValue(literal string) string {
+	if len(literal) >= 2 && (literal[0] == '\'' || literal[0] == '"') && literal[len(literal)-1] == literal[0] {
+		quote := string(literal[0])
+		return strings.ReplaceAll(literal[1:len(literal)-1], quote+quote, quote)
+	}
+	return literal
+}
+
+// isSQLConstraint reports whether a CREATE TABLE entry starts a constraint instead of a column.
+func isSQLConstraint(word string) bool {
+	switch strings.ToUpper(word) {
+	case "PRIMARY", "KEY", "UNIQUE", "INDEX", "CONSTRAINT", "FOREIGN":
+		return true
+	}
+	return false
+}
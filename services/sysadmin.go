@@ -0,0 +1,318 @@
+package services
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"time"
+
+	"terminal-sh/database"
+	"terminal-sh/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// SysAdminPatrolInterval is how often the background scheduler sends the admins on patrol.
+	SysAdminPatrolInterval = 5 * time.Minute
+	// PatchLifetime is how long a patch holds. After that the admin rolls it back because it
+	// broke something, and the service is vulnerable again.
+	PatchLifetime = 12 * time.Hour
+	// kickAlert is the alert level at which an admin starts killing miners and sessions.
+	kickAlert = 3.0
+	// maxAlert caps how worked up an admin can get.
+	maxAlert = 10.0
+)
+
+// Hourly rates of each admin action on a server at security level 50 and no alert. They
+// scale with the security level, and with the alert once the admin has seen an attack.
+const (
+	patchRate    = 0.1
+	rotateRate   = 0.1
+	backdoorRate = 0.05
+	kickRate     = 0.5
+)
+
+// sysAdminNames are the names admins sign their log entries with.
+var sysAdminNames = []string{"dave", "priya", "marcus", "lin", "olga", "tomas", "aisha", "ken"}
+
+// AdminAction is something an admin did on patrol.
+type AdminAction struct {
+	ServerIP string
+	Admin    string
+	Message  string
+	UserID   *uuid.UUID // The player it was aimed at, if any
+}
+
+// SysAdminService runs the simulated administrators who look after servers: they patch
+// vulnerable services, rotate passwords, read auth.log, kill suspicious sessions and miners,
+// and remove backdoors, writing what they do to the server's system log.
+type SysAdminService struct {
+	db            *database.Database
+	miningService *MiningService
+	serverLogs    *ServerLogService
+	notifications *NotificationService
+	chance        func() float64 // Rolls for the admins' actions; replaced in tests
+}
+
+// NewSysAdminService creates a new SysAdminService.
+func NewSysAdminService(db *database.Database, miningService *MiningService) *SysAdminService {
+	return &SysAdminService{
+		db:            db,
+		miningService: miningService,
+		serverLogs:    NewServerLogService(db),
+		notifications: NewNotificationService(db),
+		chance:        rand.Float64,
+	}
+}
+
+// Patrol sends every server's admin on its rounds for the time since its last patrol.
+// Servers at security level 0 and honeypots have nobody looking after them.
+func (s *SysAdminService) Patrol() ([]AdminAction, error) {
+	var servers []models.Server
+	if err := s.db.Where("security_level > 0").Find(&servers).Error; err != nil {
+		return nil, err
+	}
+
+	var actions []AdminAction
+	for i := range servers {
+		if servers[i].IsHoneypot() {
+			continue
+		}
+		done, err := s.patrolServer(&servers[i], time.Now())
+		if err != nil {
+			return actions, err
+		}
+		actions = append(actions, done...)
+	}
+	return actions, nil
+}
+
+// GetAdmin returns the admin looking after a server, or nil if it hasn't been on patrol yet.
+func (s *SysAdminService) GetAdmin(serverIP string) *models.SysAdmin {
+	var admin models.SysAdmin
+	if err := s.db.Where("server_ip = ?", serverIP).First(&admin).Error; err != nil {
+		return nil
+	}
+	return &admin
+}
+
+// KickedSince returns the kick that ended a player's session on a server after the given
+// time, or nil if they're still welcome.
+func (s *SysAdminService) KickedSince(userID uuid.UUID, serverIP string, since time.Time) *models.SessionKick {
+	var kick models.SessionKick
+	if err := s.db.Where("user_id = ? AND server_ip = ? AND created_at > ?", userID, serverIP, since).
+		Order("created_at DESC").First(&kick).Error; err != nil {
+		return nil
+	}
+	return &kick
+}
+
+// patrolServer does one server admin's rounds for the time since it last patrolled.
+func (s *SysAdminService) patrolServer(server *models.Server, now time.Time) ([]AdminAction, error) {
+	admin := s.GetAdmin(server.IP)
+	if admin == nil {
+		// A new admin starts on their next patrol
+		admin = &models.SysAdmin{ServerIP: server.IP, Name: sysAdminName(server.IP), LastPatrolAt: now}
+		return nil, s.db.Create(admin).Error
+	}
+
+	hours := now.Sub(admin.LastPatrolAt).Hours()
+	if hours <= 0 {
+		return nil, nil
+	}
+
+	// Only one patrol gets this period
+	result := s.db.Model(&models.SysAdmin{}).
+		Where("server_ip = ? AND last_patrol_at = ?", admin.ServerIP, admin.LastPatrolAt).
+		Update("last_patrol_at", now)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return nil, result.Error
+	}
+	since := admin.LastPatrolAt
+	admin.LastPatrolAt = now
+
+	// Read auth.log: every attack since the last patrol puts the admin on alert, which
+	// calms down by one an hour
+	suspects := s.readAuthLog(server.IP, since)
+	admin.Alert = math.Max(0, admin.Alert-hours)
+	for _, count := range suspects {
+		admin.Alert += float64(count)
+	}
+	admin.Alert = math.Min(maxAlert, admin.Alert)
+	if err := s.db.Model(admin).Update("alert", admin.Alert).Error; err != nil {
+		return nil, err
+	}
+
+	aggression := float64(server.SecurityLevel) / 50 * (1 + admin.Alert)
+	var actions []AdminAction
+	act := func(message string, userID *uuid.UUID) {
+		s.serverLogs.LogSystem(server.IP, fmt.Sprintf("%s: %s", admin.Name, message))
+		actions = append(actions, AdminAction{ServerIP: server.IP, Admin: admin.Name, Message: message, UserID: userID})
+		if userID != nil {
+			s.notifications.Notify(*userID, models.NotificationAdmin, fmt.Sprintf("%s on %s %s", admin.Name, server.IP, message))
+		}
+	}
+
+	if err := s.patchServices(server, now, chanceWithin(patchRate*aggression, hours), act); err != nil {
+		return actions, err
+	}
+	if err := s.rotatePasswords(server, chanceWithin(rotateRate*aggression, hours), act); err != nil {
+		return actions, err
+	}
+	if err := s.removeBackdoors(server, chanceWithin(backdoorRate*aggression, hours), act); err != nil {
+		return actions, err
+	}
+	if admin.Alert >= kickAlert {
+		if err := s.killSessions(server, suspects, admin.Name, chanceWithin(kickRate*aggression, hours), act); err != nil {
+			return actions, err
+		}
+		if kills, err := s.miningService.KillServerMiners(server.IP); err == nil {
+			for _, kill := range kills {
+				userID := kill.Miner.UserID
+				act(fmt.Sprintf("killed %s (pid %d) after the break-in", MinerProcess, MinerPID(&kill.Miner)), &userID)
+			}
+		}
+	}
+
+	return actions, nil
+}
+
+// readAuthLog returns how many attacks each player made on a server since the given time:
+// exploit attempts and failed logins.
+func (s *SysAdminService) readAuthLog(serverIP string, since time.Time) map[uuid.UUID]int {
+	var entries []models.ServerLog
+	s.db.Where("server_ip = ? AND created_at > ? AND user_id IS NOT NULL", serverIP, since).
+		Where("(log_type IN ? OR (log_type IN ? AND success = ?))",
+			[]models.LogType{models.LogTypeExploitAttempt, models.LogTypeExploitSuccess, models.LogTypeExploitFail},
+			[]models.LogType{models.LogTypeConnect, models.LogTypeSSHConnect, models.LogTypeAuth}, false).
+		Find(&entries)
+
+	suspects := make(map[uuid.UUID]int)
+	for _, entry := range entries {
+		suspects[*entry.UserID]++
+	}
+	return suspects
+}
+
+// patchServices patches each vulnerable service with the given chance, and rolls back
+// patches older than PatchLifetime.
+func (s *SysAdminService) patchServices(server *models.Server, now time.Time, chance float64, act func(string, *uuid.UUID)) error {
+	changed := false
+	for i := range server.Services {
+		svc := &server.Services[i]
+		switch {
+		case svc.PatchedAt != nil && now.Sub(*svc.PatchedAt) >= PatchLifetime:
+			svc.PatchedAt = nil
+			svc.Vulnerable = true
+			changed = true
+			act(fmt.Sprintf("rolled back the %s update, it broke things", svc.Name), nil)
+		case svc.Vulnerable && len(svc.Vulnerabilities) > 0 && s.chance() < chance:
+			patchedAt := now
+			svc.PatchedAt = &patchedAt
+			svc.Vulnerable = false
+			changed = true
+			act(fmt.Sprintf("patched %s on port %d", svc.Name, svc.Port), nil)
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return s.db.Model(server).Select("services").Updates(server).Error
+}
+
+// rotatePasswords changes each account's password with the given chance. Everyone's
+// cracked or stolen copies of the old password stop working.
+func (s *SysAdminService) rotatePasswords(server *models.Server, chance float64, act func(string, *uuid.UUID)) error {
+	var rotated []string
+	for i := range server.Roles {
+		if s.chance() < chance {
+			server.Roles[i].Rotations++
+			rotated = append(rotated, server.Roles[i].Role)
+		}
+	}
+	if len(rotated) == 0 {
+		return nil
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(server).Select("roles").Updates(server).Error; err != nil {
+			return err
+		}
+		for _, username := range rotated {
+			if err := tx.Where("username = ? AND (server_path = ? OR server_path LIKE ?)", username, server.IP, "%."+server.IP).
+				Delete(&models.DiscoveredCredential{}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, username := range rotated {
+		act(fmt.Sprintf("changed the password for %s", username), nil)
+	}
+	return nil
+}
+
+// removeBackdoors finds and removes each backdoor on the server with the given chance.
+func (s *SysAdminService) removeBackdoors(server *models.Server, chance float64, act func(string, *uuid.UUID)) error {
+	var backdoors []models.BackdoorAccess
+	if err := s.db.Where("server_path = ? OR server_path LIKE ?", server.IP, "%."+server.IP).Find(&backdoors).Error; err != nil {
+		return err
+	}
+	for _, backdoor := range backdoors {
+		if s.chance() >= chance {
+			continue
+		}
+		if err := s.db.Delete(&backdoor).Error; err != nil {
+			return err
+		}
+		userID := backdoor.UserID
+		act(fmt.Sprintf("removed a backdoor in %s", backdoor.ServiceName), &userID)
+	}
+	return nil
+}
+
+// killSessions kills, with the given chance, the session of each attacker who is still
+// connected to the server.
+func (s *SysAdminService) killSessions(server *models.Server, suspects map[uuid.UUID]int, admin string, chance float64, act func(string, *uuid.UUID)) error {
+	sessions, err := s.serverLogs.ActiveSessions(server.IP)
+	if err != nil {
+		return err
+	}
+	for _, session := range sessions {
+		if suspects[*session.UserID] == 0 || s.chance() >= chance {
+			continue
+		}
+		if err := s.KickSession(&session, admin); err != nil {
+			return err
+		}
+		act(fmt.Sprintf("killed the session of %s from %s", session.Username, session.SourceIP), session.UserID)
+	}
+	return nil
+}
+
+// KickSession ends a player's session on a server for whoever killed it: the player is
+// dropped off the server on their next command.
+func (s *SysAdminService) KickSession(session *models.ServerLog, by string) error {
+	if err := s.db.Create(&models.SessionKick{UserID: *session.UserID, ServerIP: session.ServerIP, Admin: by}).Error; err != nil {
+		return err
+	}
+	return s.serverLogs.LogDisconnect(session.ServerIP, session.SourceIP, session.Username, session.UserID, session.ServiceType)
+}
+
+// chanceWithin turns an hourly rate into the chance of it happening at least once in the given hours.
+func chanceWithin(rate, hours float64) float64 {
+	return 1 - math.Exp(-rate*hours)
+}
+
+// sysAdminName picks a server's admin by its IP, so the same admin always looks after it.
+func sysAdminName(serverIP string) string {
+	h := fnv.New32a()
+	h.Write([]byte(serverIP))
+	return sysAdminNames[h.Sum32()%uint32(len(sysAdminNames))]
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"terminal-sh/models"
+)
+
+func TestSysAdminFightsBackAfterAnAttack(t *testing.T) {
+	db := newTestDatabase(t)
+	server := &models.Server{
+		IP:            "10.9.9.9",
+		LocalIP:       "192.168.9.9",
+		SecurityLevel: 50,
+		Services:      []models.Service{{Name: "ssh", Port: 22, Vulnerable: true, Vulnerabilities: []models.Vulnerability{{Type: "weak_password", Level: 5}}}},
+		Roles:         []models.Role{{Role: "admin", Type: models.RoleTypeAdmin}},
+	}
+	if err := db.Create(server).Error; err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	alice, _ := NewUserService(db, "test-secret").Register("alice", "correct-horse")
+
+	serverService := NewServerService(db)
+	admins := NewSysAdminService(db, NewMiningService(db, NewToolService(db, serverService), serverService))
+	admins.chance = func() float64 { return 0 } // The admin does everything they might
+
+	// The first patrol just hires the admin
+	if actions, err := admins.Patrol(); err != nil || len(actions) != 0 {
+		t.Fatalf("expected a quiet first patrol, got %v (%v)", actions, err)
+	}
+	db.Model(&models.SysAdmin{}).Where("server_ip = ?", server.IP).Update("last_patrol_at", time.Now().Add(-time.Hour))
+
+	// Alice cracks the admin password, leaves a backdoor, and is still logged in
+	oldPassword := RolePassword(server, "admin", "admin")
+	credentials := NewCredentialService(db)
+	credentials.SaveCredential(alice.ID, server.IP, "ssh", "admin", oldPassword, "admin", models.CredentialTypeCracked, "password_cracker")
+	credentials.CreateBackdoor(alice.ID, server.IP, "ssh", "rce", "ssh_exploit", "root")
+	logs := NewServerLogService(db)
+	for i := 0; i < 3; i++ {
+		logs.LogExploitAttempt(server.IP, alice.IP, "alice", &alice.ID, "password_cracker", "ssh", true)
+	}
+	logs.LogConnect(server.IP, alice.IP, "admin", &alice.ID, "ssh", true)
+
+	actions, err := admins.Patrol()
+	if err != nil {
+		t.Fatalf("failed to patrol: %v", err)
+	}
+	if len(actions) != 4 {
+		t.Fatalf("expected a patch, a password change, a backdoor removal and a kick, got %+v", actions)
+	}
+
+	var patched models.Server
+	db.First(&patched, "ip = ?", server.IP)
+	if patched.Services[0].Vulnerable || patched.Services[0].PatchedAt == nil {
+		t.Fatal("expected ssh to be patched")
+	}
+	if RolePassword(&patched, "admin", "admin") == oldPassword {
+		t.Fatal("expected the admin password to change")
+	}
+	if credentials.HasCredentialsForService(alice.ID, server.IP, "ssh") || credentials.HasBackdoor(alice.ID, server.IP) {
+		t.Fatal("expected alice to lose her credential and backdoor")
+	}
+	if admins.KickedSince(alice.ID, server.IP, time.Now().Add(-time.Minute)) == nil {
+		t.Fatal("expected alice's session to be killed")
+	}
+	if entries, _ := logs.GetSystemLogs(server.IP, 0); len(entries) != 4 {
+		t.Fatalf("expected every action in the system log, got %d entries", len(entries))
+	}
+
+	// Once the patch is old enough the admin rolls it back
+	expired := time.Now().Add(-PatchLifetime - time.Minute)
+	patched.Services[0].PatchedAt = &expired
+	db.Model(&patched).Select("services").Updates(&patched)
+	db.Model(&models.SysAdmin{}).Where("server_ip = ?", server.IP).Update("last_patrol_at", time.Now().Add(-time.Minute))
+	admins.chance = func() float64 { return 1 }
+	if _, err := admins.Patrol(); err != nil {
+		t.Fatalf("failed to patrol: %v", err)
+	}
+	db.First(&patched, "ip = ?", server.IP)
+	if !patched.Services[0].Vulnerable || patched.Services[0].PatchedAt != nil {
+		t.Fatal("expected the patch to be rolled back")
+	}
+}
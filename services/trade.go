@@ -0,0 +1,373 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"terminal-sh/database"
+	"terminal-sh/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrTradeNotFound is returned when a trade doesn't exist or isn't visible to the user.
+	ErrTradeNotFound = errors.New("trade not found")
+	// ErrTradeClosed is returned when a trade has already settled, been withdrawn or expired.
+	ErrTradeClosed = errors.New("trade is no longer open")
+	// ErrAlreadyOwned is returned when buying a tool the buyer already has.
+	ErrAlreadyOwned = errors.New("you already own this tool")
+	// ErrAmbiguousID is returned when the start of an ID given by a player matches more than one record.
+	ErrAmbiguousID = errors.New("ambiguous id - type more of it")
+)
+
+// minIDPrefix is the fewest characters of an ID a player can refer to a record by.
+const minIDPrefix = 4
+
+// idPrefixPattern returns the pattern for `LIKE ? ESCAPE '\'` matching IDs that start with
+// prefix. LIKE wildcards in the prefix only match themselves.
+func idPrefixPattern(prefix string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(prefix) + "%"
+}
+
+// DefaultTradeExpiry is how long a trade stays open when no expiry is given.
+const DefaultTradeExpiry = 24 * time.Hour
+
+// TradeItem is an item a player puts up for trade: a tool (by name), an upgrade token (by
+// upgrade type) or a loot file (by name, with its content).
+type TradeItem struct {
+	Type    models.ItemType
+	Name    string
+	Content string
+}
+
+// TradeService runs escrowed trades between players, both offers made to one player and
+// listings on the market, as well as direct payments.
+type TradeService struct {
+	db     *database.Database
+	ledger *LedgerService
+}
+
+// NewTradeService creates a new TradeService.
+func NewTradeService(db *database.Database) *TradeService {
+	return &TradeService{db: db, ledger: NewLedgerService(db)}
+}
+
+// Pay sends crypto from one player to another.
+func (s *TradeService) Pay(fromID, toID uuid.UUID, amount float64) error {
+	if fromID == toID {
+		return fmt.Errorf("cannot pay yourself")
+	}
+	if _, err := s.ledger.Transfer(UserAccount(fromID), UserAccount(toID), models.CurrencyCrypto, amount, "payment", ""); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Offer puts an item in escrow and offers it to a player, or lists it on the market when
+// buyerID is nil. The item leaves the seller's inventory until the trade closes.
+func (s *TradeService) Offer(sellerID uuid.UUID, buyerID *uuid.UUID, item TradeItem, price float64, ttl time.Duration) (*models.Trade, error) {
+	if price < 0 {
+		return nil, ErrInvalidAmount
+	}
+	if buyerID != nil && *buyerID == sellerID {
+		return nil, fmt.Errorf("cannot trade with yourself")
+	}
+	if ttl <= 0 {
+		ttl = DefaultTradeExpiry
+	}
+
+	trade := &models.Trade{
+		ID:          uuid.New(),
+		SellerID:    sellerID,
+		BuyerID:     buyerID,
+		Listed:      buyerID == nil,
+		ItemType:    item.Type,
+		ItemName:    item.Name,
+		PriceCrypto: price,
+		Status:      models.TradeOpen,
+		ExpiresAt:   time.Now().Add(ttl),
+	}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		switch item.Type {
+		case models.ItemTypeTool:
+			var tool models.Tool
+			if err := tx.Where("name = ?", item.Name).First(&tool).Error; err != nil {
+				return fmt.Errorf("tool not found: %s", item.Name)
+			}
+			var state models.UserToolState
+			if err := tx.Where("user_id = ? AND tool_id = ?", sellerID, tool.ID).First(&state).Error; err != nil {
+				return fmt.Errorf("you don't own %s", item.Name)
+			}
+			// The tool state, upgrades and all, belongs to nobody while in escrow
+			if err := tx.Model(&state).Update("user_id", uuid.Nil).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec("DELETE FROM user_tools WHERE user_id = ? AND tool_id = ?", sellerID, tool.ID).Error; err != nil {
+				return err
+			}
+			trade.ItemRef = state.ID.String()
+		case models.ItemTypeUpgradeToken:
+			var token models.UpgradeToken
+			if err := tx.Where("user_id = ? AND upgrade_type = ? AND escrow_id IS NULL", sellerID, item.Name).
+				Order("created_at").First(&token).Error; err != nil {
+				return ErrNoUpgradeToken
+			}
+			if err := tx.Model(&token).Update("escrow_id", trade.ID).Error; err != nil {
+				return err
+			}
+			trade.ItemName = token.Name
+			trade.ItemRef = token.ID.String()
+		case models.ItemTypeLoot:
+			trade.Content = item.Content
+		default:
+			return fmt.Errorf("%s items can't be traded", item.Type)
+		}
+		return tx.Create(trade).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return trade, nil
+}
+
+// Accept pays for an open trade and hands its item to the buyer. Tools and upgrade tokens
+// change hands at once; loot files are delivered by TakeDeliveries.
+func (s *TradeService) Accept(buyerID uuid.UUID, tradeID string) (*models.Trade, error) {
+	var trade models.Trade
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.findTrade(tx.Clauses(clause.Locking{Strength: "UPDATE"}), tradeID, &trade); err != nil {
+			return err
+		}
+		if trade.SellerID == buyerID {
+			return fmt.Errorf("cannot buy your own trade")
+		}
+		if !trade.Listed && (trade.BuyerID == nil || *trade.BuyerID != buyerID) {
+			return ErrTradeNotFound
+		}
+		if trade.Status != models.TradeOpen {
+			return ErrTradeClosed
+		}
+		if time.Now().After(trade.ExpiresAt) {
+			return ErrTradeClosed
+		}
+
+		if trade.PriceCrypto > 0 {
+			if _, err := s.ledger.TransferTx(tx, UserAccount(buyerID), UserAccount(trade.SellerID), models.CurrencyCrypto,
+				trade.PriceCrypto, "trade", trade.ID.String()); err != nil {
+				return err
+			}
+		}
+		if err := s.handOver(tx, &trade, buyerID); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		trade.BuyerID = &buyerID
+		trade.Status = models.TradeSettled
+		trade.SettledAt = &now
+		trade.Delivered = trade.ItemType != models.ItemTypeLoot
+		if err := tx.Model(&trade).Select("buyer_id", "status", "settled_at", "delivered").Updates(&trade).Error; err != nil {
+			return err
+		}
+
+		purchase := &models.UserPurchase{
+			UserID:      buyerID,
+			ShopID:      marketShopID(tx),
+			ItemID:      trade.ID,
+			ItemName:    trade.ItemName,
+			ItemType:    trade.ItemType,
+			PriceCrypto: trade.PriceCrypto,
+		}
+		if err := tx.Create(purchase).Error; err != nil {
+			return fmt.Errorf("failed to record purchase: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &trade, nil
+}
+
+// Cancel closes an open trade and returns its item to the seller: the seller withdraws it,
+// or the player it was offered to declines it.
+func (s *TradeService) Cancel(userID uuid.UUID, tradeID string) (*models.Trade, error) {
+	var trade models.Trade
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.findTrade(tx.Clauses(clause.Locking{Strength: "UPDATE"}), tradeID, &trade); err != nil {
+			return err
+		}
+		status := models.TradeCancelled
+		switch {
+		case trade.SellerID == userID:
+		case !trade.Listed && trade.BuyerID != nil && *trade.BuyerID == userID:
+			status = models.TradeDeclined
+		default:
+			return ErrTradeNotFound
+		}
+		if trade.Status != models.TradeOpen {
+			return ErrTradeClosed
+		}
+		return s.close(tx, &trade, status)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &trade, nil
+}
+
+// ExpireTrades closes every open trade past its expiry and returns the items to the sellers.
+// Returns the number of trades expired.
+func (s *TradeService) ExpireTrades() (int, error) {
+	expired := 0
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var trades []models.Trade
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("status = ? AND expires_at < ?", models.TradeOpen, time.Now()).Find(&trades).Error; err != nil {
+			return err
+		}
+		for i := range trades {
+			if err := s.close(tx, &trades[i], models.TradeExpired); err != nil {
+				return err
+			}
+			expired++
+		}
+		return nil
+	})
+	return expired, err
+}
+
+// close ends a trade without a sale and hands its item back to the seller.
+func (s *TradeService) close(tx *gorm.DB, trade *models.Trade, status models.TradeStatus) error {
+	if err := s.handOver(tx, trade, trade.SellerID); err != nil {
+		return err
+	}
+	trade.Status = status
+	trade.Delivered = trade.ItemType != models.ItemTypeLoot
+	return tx.Model(trade).Select("status", "delivered").Updates(trade).Error
+}
+
+// handOver moves a tool or upgrade token out of escrow to a user. Loot files stay in the
+// trade until the receiving player collects them.
+func (s *TradeService) handOver(tx *gorm.DB, trade *models.Trade, userID uuid.UUID) error {
+	switch trade.ItemType {
+	case models.ItemTypeTool:
+		var state models.UserToolState
+		if err := tx.First(&state, "id = ?", trade.ItemRef).Error; err != nil {
+			return fmt.Errorf("escrowed tool missing: %w", err)
+		}
+		var owned int64
+		tx.Model(&models.UserToolState{}).Where("user_id = ? AND tool_id = ?", userID, state.ToolID).Count(&owned)
+		if owned > 0 {
+			if userID != trade.SellerID {
+				return ErrAlreadyOwned
+			}
+			// The seller got another copy meanwhile: the escrowed one has nowhere to go
+			return tx.Delete(&state).Error
+		}
+		if err := tx.Model(&state).Update("user_id", userID).Error; err != nil {
+			return err
+		}
+		return tx.Exec("INSERT INTO user_tools (user_id, tool_id) VALUES (?, ?)", userID, state.ToolID).Error
+	case models.ItemTypeUpgradeToken:
+		return tx.Model(&models.UpgradeToken{}).Where("id = ?", trade.ItemRef).
+			Updates(map[string]interface{}{"user_id": userID, "escrow_id": nil}).Error
+	}
+	return nil
+}
+
+// TakeDeliveries returns the loot files waiting for a user, bought or returned to them, and
+// marks them delivered. The caller writes them to the user's home.
+func (s *TradeService) TakeDeliveries(userID uuid.UUID) ([]models.Trade, error) {
+	var trades []models.Trade
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("item_type = ? AND delivered = ? AND status <> ?", models.ItemTypeLoot, false, models.TradeOpen).
+			Where("(status = ? AND buyer_id = ?) OR (status <> ? AND seller_id = ?)",
+				models.TradeSettled, userID, models.TradeSettled, userID).
+			Find(&trades).Error; err != nil {
+			return err
+		}
+		for i := range trades {
+			trades[i].Delivered = true
+			if err := tx.Model(&trades[i]).Update("delivered", true).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return trades, err
+}
+
+// ReturnDelivery puts a loot file back among the user's deliveries when it couldn't be written
+// to their home, so the next TakeDeliveries hands it over again.
+func (s *TradeService) ReturnDelivery(trade *models.Trade) error {
+	trade.Delivered = false
+	return s.db.Model(trade).Update("delivered", false).Error
+}
+
+// Abort undoes an open offer the seller couldn't go through with: the trade is deleted and its
+// item handed back, without a loot file to deliver.
+func (s *TradeService) Abort(trade *models.Trade) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("status = ?", models.TradeOpen).Delete(trade)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrTradeClosed
+		}
+		return s.handOver(tx, trade, trade.SellerID)
+	})
+}
+
+// GetTrades returns the open trades a user is part of: their own offers and listings, and
+// offers made to them.
+func (s *TradeService) GetTrades(userID uuid.UUID) ([]models.Trade, error) {
+	var trades []models.Trade
+	err := s.db.Where("status = ? AND (seller_id = ? OR buyer_id = ?)", models.TradeOpen, userID, userID).
+		Order("created_at").Find(&trades).Error
+	return trades, err
+}
+
+// GetListings returns the open market listings, oldest first.
+func (s *TradeService) GetListings() ([]models.Trade, error) {
+	var trades []models.Trade
+	err := s.db.Where("status = ? AND listed = ? AND expires_at >= ?", models.TradeOpen, true, time.Now()).
+		Order("created_at").Find(&trades).Error
+	return trades, err
+}
+
+// findTrade loads a trade by its ID or the first minIDPrefix or more characters of it.
+func (s *TradeService) findTrade(tx *gorm.DB, tradeID string, trade *models.Trade) error {
+	if len(tradeID) < minIDPrefix {
+		return ErrTradeNotFound
+	}
+	var trades []models.Trade
+	if err := tx.Where(`id LIKE ? ESCAPE '\'`, idPrefixPattern(tradeID)).Limit(2).Find(&trades).Error; err != nil {
+		return err
+	}
+	switch len(trades) {
+	case 0:
+		return ErrTradeNotFound
+	case 1:
+		*trade = trades[0]
+		return nil
+	default:
+		return ErrAmbiguousID
+	}
+}
+
+// marketShopID returns the ID of the market's shop, under which trades and market fills are
+// recorded as purchases.
+func marketShopID(tx *gorm.DB) uuid.UUID {
+	var shop models.Shop
+	if err := tx.Where("shop_type = ?", models.ShopTypeMarket).First(&shop).Error; err != nil {
+		return uuid.Nil
+	}
+	return shop.ID
+}
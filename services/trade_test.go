@@ -0,0 +1,154 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"terminal-sh/models"
+)
+
+func TestTradeEscrowsToolsUntilSettled(t *testing.T) {
+	db := newTestDatabase(t)
+	serverService := NewServerService(db)
+	toolService := NewToolService(db, serverService)
+	tool := &models.Tool{Name: "nmap", Function: "scanner"}
+	if err := db.Create(tool).Error; err != nil {
+		t.Fatalf("failed to create tool: %v", err)
+	}
+	userService := NewUserService(db, "test-secret")
+	seller, _ := userService.Register("alice", "correct-horse")
+	buyer, _ := userService.Register("bob", "battery-staple")
+	if err := toolService.GrantToolToUser(seller.ID, tool.ID); err != nil {
+		t.Fatalf("failed to grant tool: %v", err)
+	}
+	seller.Wallet.Crypto = 0
+	buyer.Wallet.Crypto = 100
+	db.Model(seller).Select("wallet").Updates(seller)
+	db.Model(buyer).Select("wallet").Updates(buyer)
+
+	trades := NewTradeService(db)
+	trade, err := trades.Offer(seller.ID, &buyer.ID, TradeItem{Type: models.ItemTypeTool, Name: "nmap"}, 60, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to offer: %v", err)
+	}
+	if toolService.UserHasTool(seller.ID, "nmap") {
+		t.Fatal("expected the tool to leave the seller while in escrow")
+	}
+	if _, err := trades.Accept(seller.ID, trade.ID.String()[:8]); err == nil {
+		t.Fatal("expected the seller to be unable to take their own offer")
+	}
+
+	if _, err := trades.Accept(buyer.ID, trade.ID.String()[:8]); err != nil {
+		t.Fatalf("failed to accept: %v", err)
+	}
+	if !toolService.UserHasTool(buyer.ID, "nmap") {
+		t.Fatal("expected the buyer to own the tool")
+	}
+	var paid, received models.User
+	db.First(&paid, "id = ?", buyer.ID)
+	db.First(&received, "id = ?", seller.ID)
+	if paid.Wallet.Crypto != 40 || received.Wallet.Crypto != 60 {
+		t.Fatalf("expected 60 crypto to change hands, got buyer %.2f seller %.2f", paid.Wallet.Crypto, received.Wallet.Crypto)
+	}
+	var purchases int64
+	db.Model(&models.UserPurchase{}).Where("user_id = ? AND item_id = ?", buyer.ID, trade.ID).Count(&purchases)
+	if purchases != 1 {
+		t.Fatalf("expected the trade in the buyer's purchase history, got %d", purchases)
+	}
+	if _, err := trades.Accept(buyer.ID, trade.ID.String()); !errors.Is(err, ErrTradeClosed) {
+		t.Fatalf("expected a settled trade to be closed, got %v", err)
+	}
+}
+
+func TestExpiredLootListingGoesBackToSeller(t *testing.T) {
+	db := newTestDatabase(t)
+	seller, _ := NewUserService(db, "test-secret").Register("alice", "correct-horse")
+
+	trades := NewTradeService(db)
+	listing, err := trades.Offer(seller.ID, nil, TradeItem{Type: models.ItemTypeLoot, Name: "dump.sql", Content: "secrets"}, 10, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to list: %v", err)
+	}
+	db.Model(listing).Update("expires_at", time.Now().Add(-time.Minute))
+
+	if expired, _ := trades.ExpireTrades(); expired != 1 {
+		t.Fatalf("expected 1 expired trade, got %d", expired)
+	}
+	deliveries, _ := trades.TakeDeliveries(seller.ID)
+	if len(deliveries) != 1 || deliveries[0].Content != "secrets" || deliveries[0].Status != models.TradeExpired {
+		t.Fatalf("expected the file back with the seller, got %+v", deliveries)
+	}
+	if again, _ := trades.TakeDeliveries(seller.ID); len(again) != 0 {
+		t.Fatal("expected the file to be delivered only once")
+	}
+
+	// A delivery that couldn't be written is handed over again
+	if err := trades.ReturnDelivery(&deliveries[0]); err != nil {
+		t.Fatalf("failed to return the delivery: %v", err)
+	}
+	if again, _ := trades.TakeDeliveries(seller.ID); len(again) != 1 {
+		t.Fatalf("expected the returned file to be delivered again, got %d", len(again))
+	}
+}
+
+func TestAbortedLootOfferLeavesNothingToDeliver(t *testing.T) {
+	db := newTestDatabase(t)
+	userService := NewUserService(db, "test-secret")
+	seller, _ := userService.Register("alice", "correct-horse")
+	buyer, _ := userService.Register("bob", "battery-staple")
+
+	trades := NewTradeService(db)
+	offer, err := trades.Offer(seller.ID, &buyer.ID, TradeItem{Type: models.ItemTypeLoot, Name: "dump.sql", Content: "secrets"}, 10, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to offer: %v", err)
+	}
+	if err := trades.Abort(offer); err != nil {
+		t.Fatalf("failed to abort: %v", err)
+	}
+	if _, err := trades.Accept(buyer.ID, offer.ID.String()); !errors.Is(err, ErrTradeNotFound) {
+		t.Fatalf("expected the aborted offer to be gone, got %v", err)
+	}
+	if deliveries, _ := trades.TakeDeliveries(seller.ID); len(deliveries) != 0 {
+		t.Fatalf("expected no copy of the file to come back, got %d", len(deliveries))
+	}
+	if err := trades.Abort(offer); !errors.Is(err, ErrTradeClosed) {
+		t.Fatalf("expected a second abort to fail, got %v", err)
+	}
+}
+
+func TestFindTradeByIDPrefix(t *testing.T) {
+	db := newTestDatabase(t)
+	userService := NewUserService(db, "test-secret")
+	seller, _ := userService.Register("alice", "correct-horse")
+	buyer, _ := userService.Register("bob", "battery-staple")
+
+	trades := NewTradeService(db)
+	for _, id := range []string{"abcd1111-0000-4000-8000-000000000000", "abcd2222-0000-4000-8000-000000000000"} {
+		listing, err := trades.Offer(seller.ID, nil, TradeItem{Type: models.ItemTypeLoot, Name: "dump.sql", Content: "secrets"}, 0, time.Hour)
+		if err != nil {
+			t.Fatalf("failed to list: %v", err)
+		}
+		if err := db.Exec("UPDATE trades SET id = ? WHERE id = ?", id, listing.ID).Error; err != nil {
+			t.Fatalf("failed to set trade id: %v", err)
+		}
+	}
+
+	tests := []struct {
+		prefix string
+		want   error
+	}{
+		{"", ErrTradeNotFound},
+		{"abc", ErrTradeNotFound}, // Too short
+		{"%%%%", ErrTradeNotFound},
+		{"____", ErrTradeNotFound},
+		{`abc\`, ErrTradeNotFound},
+		{"abcd", ErrAmbiguousID},
+		{"abcd1", nil},
+	}
+	for _, tt := range tests {
+		if _, err := trades.Accept(buyer.ID, tt.prefix); !errors.Is(err, tt.want) {
+			t.Errorf("Accept(%q) returned %v, want %v", tt.prefix, err, tt.want)
+		}
+	}
+}
@@ -15,6 +15,7 @@ type UpgradeService struct {
 	stateStore  ToolStateStore
 	userService *UserService
 	calculator  *patch.Calculator
+	ledger      *LedgerService
 }
 
 // NewUpgradeService creates a new UpgradeService with the provided dependencies.
@@ -24,6 +25,7 @@ func NewUpgradeService(db *database.Database, stateStore ToolStateStore, userSer
 		stateStore:  stateStore,
 		userService: userService,
 		calculator:  patch.NewCalculator(),
+		ledger:      NewLedgerService(db),
 	}
 }
 
@@ -120,12 +122,8 @@ func (s *UpgradeService) ApplyUpgrade(userID uuid.UUID, toolName string, upgrade
 	}
 
 	// Deduct cost from user wallet
-	user, err := s.userService.GetUserByID(userID)
-	if err != nil {
-		return fmt.Errorf("failed to get user: %w", err)
-	}
-	user.Wallet.Crypto -= cost
-	if err := s.db.Save(user).Error; err != nil {
+	if _, err := s.ledger.Transfer(UserAccount(userID), SystemAccount("upgrades"), models.CurrencyCrypto,
+		cost, "tool_upgrade", toolName+":"+string(upgradeType)); err != nil {
 		return fmt.Errorf("failed to deduct cost: %w", err)
 	}
 
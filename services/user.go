@@ -4,21 +4,49 @@ package services
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"math/big"
+	"strings"
+	"time"
 
 	"terminal-sh/auth"
 	"terminal-sh/database"
+	"terminal-sh/metrics"
 	"terminal-sh/models"
 
 	"github.com/google/uuid"
+	"github.com/pquerna/otp/totp"
 	"gorm.io/gorm"
 )
 
+var (
+	// ErrUserNotFound is returned by Login when no account exists for the username
+	ErrUserNotFound = errors.New("user not found")
+	// ErrInvalidCredentials is returned when the password or TOTP code is wrong
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	// ErrTOTPRequired is returned by Login when the password is correct but a TOTP code is still needed
+	ErrTOTPRequired = errors.New("two-factor code required")
+	// ErrPasswordResetRequired is returned by Login when the user logged in with a reset token
+	// and must choose a new password before continuing
+	ErrPasswordResetRequired = errors.New("password reset required")
+	// ErrNotAdmin is returned when a non-admin user calls an admin-only operation
+	ErrNotAdmin = errors.New("permission denied: admin only")
+)
+
+const (
+	minPasswordLength = 6
+	resetTokenTTL     = 24 * time.Hour
+	totpIssuer        = "terminal.sh"
+)
+
 // UserService handles user-related operations including registration, authentication, and user management.
 type UserService struct {
 	db           *database.Database
 	tokenManager *auth.TokenManager
+	onSessionRevoked func(sessionIDs []uuid.UUID) // Disconnects revoked devices that are still connected
 }
 
 // NewUserService creates a new UserService with the provided database and JWT secret.
@@ -36,6 +64,9 @@ func (s *UserService) Register(username, password string) (*models.User, error)
 	if username == "" || username == "guest" {
 		return nil, fmt.Errorf("invalid username")
 	}
+	if len(password) < minPasswordLength {
+		return nil, fmt.Errorf("password must be at least %d characters", minPasswordLength)
+	}
 
 	// Check if user already exists
 	var existingUser models.User
@@ -77,42 +108,341 @@ func (s *UserService) Register(username, password string) (*models.User, error)
 	if err := s.db.Create(user).Error; err != nil {
 		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
+	metrics.Registrations.Inc()
 
 	return user, nil
 }
 
-// Login authenticates a user with username and password, returning the user, JWT token, and any error.
-// If the user doesn't exist, automatically registers them (auto-registration).
-func (s *UserService) Login(username, password string) (*models.User, string, error) {
+// Login authenticates a user with username and password from sourceIP, returning the user, the tokens of a
+// new device session for device, and any error. Transports that don't keep logins pass an empty device
+// and get no tokens.
+// Failed attempts are throttled per username and per source IP; a locked login returns a *LockoutError.
+// Returns ErrUserNotFound if the account doesn't exist so the caller can offer registration.
+// If the user has TOTP enabled, returns the user with ErrTOTPRequired; finish with VerifyTOTPLogin.
+// If the password is a valid reset token, returns the user with ErrPasswordResetRequired; finish with ResetPassword.
+func (s *UserService) Login(username, password, sourceIP, device string) (*models.User, *SessionTokens, error) {
+	keys := throttleKeys(username, sourceIP)
+	if err := s.checkLockout(keys); err != nil {
+		observeLockout(err)
+		return nil, nil, err
+	}
+
 	var user models.User
 	if err := s.db.Where("username = ?", username).First(&user).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			// Auto-register on first login attempt
-			user, err := s.Register(username, password)
-			if err != nil {
-				return nil, "", fmt.Errorf("registration failed: %w", err)
-			}
-			token, err := s.tokenManager.GenerateToken(user.ID, user.Username)
-			if err != nil {
-				return nil, "", fmt.Errorf("failed to generate token: %w", err)
-			}
-			return user, token, nil
+			// Count against the source IP so usernames can't be enumerated freely
+			metrics.ObserveLoginFailure(metrics.ReasonUnknownUser)
+			s.recordLoginFailure(keys[1:])
+			return nil, nil, ErrUserNotFound
 		}
-		return nil, "", fmt.Errorf("failed to find user: %w", err)
+		return nil, nil, fmt.Errorf("failed to find user: %w", err)
 	}
 
-	// Check password
+	// Check password, falling back to a one-time reset token
 	if !auth.CheckPasswordHash(password, user.PasswordHash) {
-		return nil, "", fmt.Errorf("invalid password")
+		if s.validResetToken(user.ID, password) {
+			s.clearLoginFailures(username)
+			return &user, nil, ErrPasswordResetRequired
+		}
+		metrics.ObserveLoginFailure(metrics.ReasonBadPassword)
+		s.recordLoginFailure(keys)
+		return nil, nil, ErrInvalidCredentials
+	}
+
+	if user.TOTPEnabled {
+		return &user, nil, ErrTOTPRequired
+	}
+
+	return s.completeLogin(&user, device, sourceIP)
+}
+
+// VerifyTOTPLogin finishes a login for a user with TOTP enabled.
+// Wrong codes count toward the same throttle as wrong passwords.
+func (s *UserService) VerifyTOTPLogin(user *models.User, code, sourceIP, device string) (*SessionTokens, error) {
+	keys := throttleKeys(user.Username, sourceIP)
+	if err := s.checkLockout(keys); err != nil {
+		observeLockout(err)
+		return nil, err
+	}
+
+	if !totp.Validate(strings.TrimSpace(code), user.TOTPSecret) {
+		metrics.ObserveLoginFailure(metrics.ReasonBadTOTP)
+		s.recordLoginFailure(keys)
+		return nil, ErrInvalidCredentials
+	}
+
+	_, tokens, err := s.completeLogin(user, device, sourceIP)
+	return tokens, err
+}
+
+// completeLogin records a successful login and, for a device, starts a device session.
+func (s *UserService) completeLogin(user *models.User, device, sourceIP string) (*models.User, *SessionTokens, error) {
+	metrics.Logins.WithLabelValues(metrics.ResultSuccess).Inc()
+	s.clearLoginFailures(user.Username)
+
+	if device == "" {
+		return user, nil, nil
+	}
+	tokens, err := s.IssueDeviceSession(user, device, sourceIP)
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, tokens, nil
+}
+
+// SetAdmin grants or revokes a user's admin rights. The flag belongs to the account, so it
+// follows the user through renames and no one gets it by taking a name. Only operators call
+// this (see cmd/admin); nothing in the game can.
+func (s *UserService) SetAdmin(username string, admin bool) (*models.User, error) {
+	var user models.User
+	if err := s.db.Where("username = ?", username).First(&user).Error; err != nil {
+		return nil, fmt.Errorf("user '%s' not found", username)
+	}
+	user.IsAdmin = admin
+	if err := s.db.Model(&user).Select("is_admin").Updates(&user).Error; err != nil {
+		return nil, fmt.Errorf("failed to update admin rights: %w", err)
+	}
+	return &user, nil
+}
+
+// ListAdmins returns the users with admin rights, by username.
+func (s *UserService) ListAdmins() ([]models.User, error) {
+	var admins []models.User
+	err := s.db.Select("id", "username", "created_at").Where("is_admin = ?", true).Order("username").Find(&admins).Error
+	return admins, err
+}
+
+// IsAdmin reports whether the user's account has admin rights. The flag is read by user ID
+// on every check, so a revoke takes effect straight away.
+func (s *UserService) IsAdmin(user *models.User) bool {
+	if user == nil {
+		return false
+	}
+	var flags []bool
+	if err := s.db.Model(&models.User{}).Where("id = ?", user.ID).Pluck("is_admin", &flags).Error; err != nil || len(flags) == 0 {
+		return false
+	}
+	return flags[0]
+}
+
+// ChangePassword changes a user's password after verifying the current one.
+// Wrong current passwords count toward the username's login throttle.
+// All device sessions except keepSession (uuid.Nil keeps none) are logged out.
+func (s *UserService) ChangePassword(userID uuid.UUID, currentPassword, newPassword string, keepSession uuid.UUID) error {
+	var user models.User
+	if err := s.db.First(&user, "id = ?", userID).Error; err != nil {
+		return fmt.Errorf("failed to find user: %w", err)
+	}
+
+	keys := throttleKeys(user.Username, "")
+	if err := s.checkLockout(keys); err != nil {
+		return err
+	}
+	if !auth.CheckPasswordHash(currentPassword, user.PasswordHash) {
+		s.recordLoginFailure(keys)
+		return fmt.Errorf("current password is incorrect")
+	}
+
+	return s.setPassword(userID, newPassword, keepSession)
+}
+
+// SetPassword replaces a user's password without checking the old one and logs out all of
+// the user's devices. Callers must have authenticated the user another way.
+func (s *UserService) SetPassword(userID uuid.UUID, newPassword string) error {
+	return s.setPassword(userID, newPassword, uuid.Nil)
+}
+
+// ResetPassword finishes a reset token login: the token is redeemed in the same transaction
+// that stores the new password, so it's only used up once the password is saved. All of the
+// user's devices are logged out and a new device session is started for device, as in Login.
+// If the user has TOTP enabled, returns the user with ErrTOTPRequired; finish with VerifyTOTPLogin.
+func (s *UserService) ResetPassword(userID uuid.UUID, token, newPassword, sourceIP, device string) (*models.User, *SessionTokens, error) {
+	passwordHash, err := hashNewPassword(newPassword)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if !redeemResetToken(tx, userID, token) {
+			return ErrInvalidCredentials
+		}
+		return tx.Model(&models.User{}).Where("id = ?", userID).Update("password_hash", passwordHash).Error
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := s.RevokeAllDeviceSessions(userID, uuid.Nil); err != nil {
+		return nil, nil, err
+	}
+
+	var user models.User
+	if err := s.db.First(&user, "id = ?", userID).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to find user: %w", err)
+	}
+	if user.TOTPEnabled {
+		return &user, nil, ErrTOTPRequired
+	}
+	return s.completeLogin(&user, device, sourceIP)
+}
+
+// setPassword stores a new password hash and logs out every device session except keepSession.
+func (s *UserService) setPassword(userID uuid.UUID, newPassword string, keepSession uuid.UUID) error {
+	passwordHash, err := hashNewPassword(newPassword)
+	if err != nil {
+		return err
+	}
+
+	if err := s.db.Model(&models.User{}).Where("id = ?", userID).Update("password_hash", passwordHash).Error; err != nil {
+		return err
+	}
+
+	_, err = s.RevokeAllDeviceSessions(userID, keepSession)
+	return err
+}
+
+// hashNewPassword checks a new password's length and hashes it.
+func hashNewPassword(newPassword string) (string, error) {
+	if len(newPassword) < minPasswordLength {
+		return "", fmt.Errorf("password must be at least %d characters", minPasswordLength)
+	}
+	passwordHash, err := auth.HashPassword(newPassword)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return passwordHash, nil
+}
+
+// IssuePasswordResetToken creates a one-time reset token for username on behalf of an admin.
+// Any earlier unused tokens for the user are revoked. The token is valid for 24 hours and
+// is entered in place of the password at the login screen.
+func (s *UserService) IssuePasswordResetToken(admin *models.User, username string) (string, time.Time, error) {
+	if !s.IsAdmin(admin) {
+		return "", time.Time{}, ErrNotAdmin
+	}
+
+	var user models.User
+	if err := s.db.Where("username = ?", username).First(&user).Error; err != nil {
+		return "", time.Time{}, fmt.Errorf("user '%s' not found", username)
 	}
 
-	// Generate token
-	token, err := s.tokenManager.GenerateToken(user.ID, user.Username)
+	raw := make([]byte, 12)
+	if _, err := rand.Read(raw); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to generate token: %w", err)
+	}
+	token := "reset-" + hex.EncodeToString(raw)
+	expiresAt := time.Now().Add(resetTokenTTL)
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND used_at IS NULL", user.ID).Delete(&models.PasswordResetToken{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.PasswordResetToken{
+			UserID:    user.ID,
+			TokenHash: hashToken(token),
+			IssuedBy:  admin.ID,
+			ExpiresAt: expiresAt,
+		}).Error
+	})
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to generate token: %w", err)
+		return "", time.Time{}, fmt.Errorf("failed to issue reset token: %w", err)
 	}
 
-	return &user, token, nil
+	return token, expiresAt, nil
+}
+
+// validResetToken reports whether token is an unused, unexpired reset token for the user.
+// The token isn't used up until ResetPassword redeems it.
+func (s *UserService) validResetToken(userID uuid.UUID, token string) bool {
+	if !strings.HasPrefix(token, "reset-") {
+		return false
+	}
+	var count int64
+	err := s.db.Model(&models.PasswordResetToken{}).
+		Where("user_id = ? AND token_hash = ? AND used_at IS NULL AND expires_at > ?", userID, hashToken(token), time.Now()).
+		Count(&count).Error
+	return err == nil && count == 1
+}
+
+// redeemResetToken marks a matching unexpired reset token as used within tx.
+// Returns true if the token was valid for the user.
+func redeemResetToken(tx *gorm.DB, userID uuid.UUID, token string) bool {
+	if !strings.HasPrefix(token, "reset-") {
+		return false
+	}
+	now := time.Now()
+	result := tx.Model(&models.PasswordResetToken{}).
+		Where("user_id = ? AND token_hash = ? AND used_at IS NULL AND expires_at > ?", userID, hashToken(token), now).
+		Update("used_at", now)
+	return result.Error == nil && result.RowsAffected == 1
+}
+
+// hashToken returns the stored form of a reset or refresh token.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// BeginTOTPEnrollment generates a new TOTP secret for the user.
+// The secret is stored but not enforced until ConfirmTOTP succeeds.
+// Returns the base32 secret and an otpauth:// URL for authenticator apps.
+func (s *UserService) BeginTOTPEnrollment(user *models.User) (string, string, error) {
+	if user.TOTPEnabled {
+		return "", "", fmt.Errorf("two-factor authentication is already enabled")
+	}
+
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      totpIssuer,
+		AccountName: user.Username,
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate TOTP secret: %w", err)
+	}
+
+	if err := s.db.Model(&models.User{}).Where("id = ?", user.ID).Update("totp_secret", key.Secret()).Error; err != nil {
+		return "", "", fmt.Errorf("failed to save TOTP secret: %w", err)
+	}
+	user.TOTPSecret = key.Secret()
+
+	return key.Secret(), key.URL(), nil
+}
+
+// ConfirmTOTP enables TOTP for the user once they prove their authenticator produces valid codes.
+func (s *UserService) ConfirmTOTP(user *models.User, code string) error {
+	if user.TOTPEnabled {
+		return fmt.Errorf("two-factor authentication is already enabled")
+	}
+	if user.TOTPSecret == "" {
+		return fmt.Errorf("run '2fa enable' first")
+	}
+	if !totp.Validate(strings.TrimSpace(code), user.TOTPSecret) {
+		return fmt.Errorf("invalid code")
+	}
+
+	if err := s.db.Model(&models.User{}).Where("id = ?", user.ID).Update("totp_enabled", true).Error; err != nil {
+		return fmt.Errorf("failed to enable two-factor authentication: %w", err)
+	}
+	user.TOTPEnabled = true
+	return nil
+}
+
+// DisableTOTP turns off TOTP for the user after verifying a current code.
+func (s *UserService) DisableTOTP(user *models.User, code string) error {
+	if !user.TOTPEnabled {
+		return fmt.Errorf("two-factor authentication is not enabled")
+	}
+	if !totp.Validate(strings.TrimSpace(code), user.TOTPSecret) {
+		return fmt.Errorf("invalid code")
+	}
+
+	if err := s.db.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+		"totp_enabled": false,
+		"totp_secret":  "",
+	}).Error; err != nil {
+		return fmt.Errorf("failed to disable two-factor authentication: %w", err)
+	}
+	user.TOTPEnabled = false
+	user.TOTPSecret = ""
+	return nil
 }
 
 // GetUserByID retrieves a user by their UUID, including related tools and achievements.
@@ -205,12 +535,19 @@ func randomInt(min, max int) int {
 }
 
 // ValidateToken validates a JWT token string and returns the associated user.
-// Returns an error if the token is invalid or expired.
+// Returns an error if the token is invalid or expired, or if its device session has been revoked.
 func (s *UserService) ValidateToken(tokenString string) (*models.User, error) {
 	claims, err := s.tokenManager.ValidateToken(tokenString)
 	if err != nil {
 		return nil, err
 	}
+	// Every token is issued for a device session; one without is from before they were revocable
+	if claims.SessionID == uuid.Nil {
+		return nil, auth.ErrInvalidToken
+	}
+	if _, err := s.activeSession(claims.SessionID); err != nil {
+		return nil, err
+	}
 
 	return s.GetUserByID(claims.UserID)
 }
@@ -0,0 +1,17 @@
+package terminal
+
+// AnnouncementMsg is a world-wide announcement (a live event starting, a heist going dark)
+// shown to every connected player above their prompt.
+type AnnouncementMsg struct {
+	Text string
+}
+
+// Announce shows text to every connected session. It's set as the chat service's announcer
+// so services can reach players who aren't in chat.
+func Announce(text string) {
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+	for s := range sessions {
+		go s.send(AnnouncementMsg{Text: text})
+	}
+}
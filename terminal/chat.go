@@ -195,6 +195,12 @@ func (m *ChatModel) listenForMessages() tea.Cmd {
 	}
 }
 
+// handleShutdown leaves chat so the shell can show the notice and flush its filesystems.
+func (m *ChatModel) handleShutdown(msg ShutdownMsg) (tea.Model, tea.Cmd) {
+	m.chatService.UnregisterSession(m.sessionID)
+	return m.parent.handleShutdown(msg)
+}
+
 // Update handles messages
 func (m *ChatModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmds []tea.Cmd
@@ -243,6 +249,26 @@ func (m *ChatModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case chatExitMsg:
 		return m.parent, nil
 
+	case ShutdownMsg:
+		return m.handleShutdown(msg)
+
+	case AnnouncementMsg:
+		// Show it in the shell once the user leaves chat; #public has it already
+		if !Draining() {
+			m.parent.notice = msg.Text
+		}
+		return m, nil
+
+	case notificationTickMsg, notificationsMsg:
+		// Keep the shell's status line up to date for when the user leaves chat
+		_, cmd := m.parent.Update(msg)
+		return m, cmd
+
+	case LogoutMsg:
+		// Device was signed out elsewhere - leave chat and let the shell log out
+		m.chatService.UnregisterSession(m.sessionID)
+		return m.parent.Update(msg)
+
 	case tea.KeyMsg:
 		// Handle special keys
 		switch msg.String() {
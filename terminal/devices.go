@@ -0,0 +1,65 @@
+package terminal
+
+import (
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Connected clients logged in with a device session, so revoking the session signs them out live.
+// A session can have several clients at once (browser tabs, a WebSocket resuming before the old
+// one has closed), so each registration is kept separately.
+// Shared by the SSH and web servers when they run in the same process.
+var (
+	devicesMu sync.Mutex
+	devices   = make(map[uuid.UUID]map[*deviceRegistration]bool)
+)
+
+// deviceRegistration is one connected client for a device session
+type deviceRegistration struct {
+	signOut func()
+}
+
+// RegisterDevice records a connected client for a device session.
+// signOut is called if the session is revoked while the client is connected.
+// Returns a function that removes this registration, leaving any other clients on the session.
+func RegisterDevice(sessionID uuid.UUID, signOut func()) func() {
+	registration := &deviceRegistration{signOut: signOut}
+
+	devicesMu.Lock()
+	if devices[sessionID] == nil {
+		devices[sessionID] = make(map[*deviceRegistration]bool)
+	}
+	devices[sessionID][registration] = true
+	devicesMu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			devicesMu.Lock()
+			delete(devices[sessionID], registration)
+			if len(devices[sessionID]) == 0 {
+				delete(devices, sessionID)
+			}
+			devicesMu.Unlock()
+		})
+	}
+}
+
+// SignOutDevices signs out every connected client for the revoked device sessions.
+// Pass it to UserService.SetSessionRevokedCallback.
+func SignOutDevices(sessionIDs []uuid.UUID) {
+	devicesMu.Lock()
+	var signOuts []func()
+	for _, id := range sessionIDs {
+		for registration := range devices[id] {
+			signOuts = append(signOuts, registration.signOut)
+		}
+		delete(devices, id)
+	}
+	devicesMu.Unlock()
+
+	for _, signOut := range signOuts {
+		go signOut()
+	}
+}
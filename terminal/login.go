@@ -1,6 +1,7 @@
 package terminal
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 	"terminal-sh/models"
@@ -14,6 +15,16 @@ import (
 	"github.com/charmbracelet/lipgloss"
 )
 
+// loginStep is the stage of the login flow the form is collecting input for
+type loginStep int
+
+const (
+	stepCredentials     loginStep = iota // Username and password
+	stepTOTP                             // Two-factor code for accounts with TOTP enabled
+	stepConfirmRegister                  // Confirm creating a new account for an unknown username
+	stepNewPassword                      // Choose a new password after logging in with a reset token
+)
+
 // LoginModel handles the login/registration form
 type LoginModel struct {
 	db            *database.Database
@@ -24,6 +35,14 @@ type LoginModel struct {
 	password      string
 	prefillUser   string // Username from SSH connection
 	prefillPass   string // Password from SSH connection (if provided)
+	sourceIP      string // Client address, used for login throttling
+	sessionHooks  *SessionHooks // Set by transports that persist logins (web client)
+	step          loginStep
+	pendingUser   *models.User // User awaiting a TOTP code or new password
+	totpCode      string
+	confirmCreate bool
+	newPassword   string
+	resetToken    string // Reset token the pending user logged in with, redeemed with the new password
 	authenticated bool
 	user          interface{} // Will store the authenticated user
 	err           error
@@ -49,11 +68,28 @@ func NewLoginModel(db *database.Database, userService *services.UserService, cha
 
 	// Form will be resized based on window size
 	// Use model fields directly so form updates them
-	form := huh.NewForm(
+	model.form = model.newCredentialsForm()
+	return model
+}
+
+// SetSourceIP sets the client address used for per-IP login throttling
+func (m *LoginModel) SetSourceIP(sourceIP string) {
+	m.sourceIP = sourceIP
+}
+
+// SetSessionHooks enables device sessions for this login.
+// After a successful login a device session is issued and hooks.OnLogin receives its tokens.
+func (m *LoginModel) SetSessionHooks(hooks *SessionHooks) {
+	m.sessionHooks = hooks
+}
+
+// newCredentialsForm builds the username/password form
+func (m *LoginModel) newCredentialsForm() *huh.Form {
+	return huh.NewForm(
 		huh.NewGroup(
 			huh.NewInput().
 				Title("Username").
-				Value(&model.username).
+				Value(&m.username).
 				Placeholder("Enter your username").
 				Validate(func(s string) error {
 					if s == "" {
@@ -63,7 +99,7 @@ func NewLoginModel(db *database.Database, userService *services.UserService, cha
 				}),
 			huh.NewInput().
 				Title("Password").
-				Value(&model.password).
+				Value(&m.password).
 				Placeholder("Enter your password").
 				EchoMode(huh.EchoModePassword).
 				Validate(func(s string) error {
@@ -75,10 +111,78 @@ func NewLoginModel(db *database.Database, userService *services.UserService, cha
 		),
 	).
 		WithTheme(huh.ThemeCatppuccin()).
-		WithWidth(50) // Default width, will be updated on window resize
+		WithWidth(m.formWidth)
+}
 
-	model.form = form
-	return model
+// newTOTPForm builds the two-factor code form
+func (m *LoginModel) newTOTPForm() *huh.Form {
+	m.totpCode = ""
+	return huh.NewForm(
+		huh.NewGroup(
+			huh.NewInput().
+				Title("Two-factor code").
+				Value(&m.totpCode).
+				Placeholder("6-digit code from your authenticator app").
+				CharLimit(6).
+				Validate(func(s string) error {
+					if len(strings.TrimSpace(s)) != 6 {
+						return fmt.Errorf("code must be 6 digits")
+					}
+					return nil
+				}),
+		),
+	).
+		WithTheme(huh.ThemeCatppuccin()).
+		WithWidth(m.formWidth)
+}
+
+// newConfirmRegisterForm builds the confirmation form shown before creating an account
+func (m *LoginModel) newConfirmRegisterForm() *huh.Form {
+	m.confirmCreate = false
+	return huh.NewForm(
+		huh.NewGroup(
+			huh.NewConfirm().
+				Title(fmt.Sprintf("No account named '%s' exists.", m.username)).
+				Description("Create a new account with this username and password?").
+				Affirmative("Create account").
+				Negative("Back").
+				Value(&m.confirmCreate),
+		),
+	).
+		WithTheme(huh.ThemeCatppuccin()).
+		WithWidth(m.formWidth)
+}
+
+// newPasswordForm builds the form for choosing a new password after a reset
+func (m *LoginModel) newPasswordForm() *huh.Form {
+	m.newPassword = ""
+	confirm := ""
+	return huh.NewForm(
+		huh.NewGroup(
+			huh.NewInput().
+				Title("New password").
+				Value(&m.newPassword).
+				EchoMode(huh.EchoModePassword).
+				Validate(func(s string) error {
+					if s == "" {
+						return fmt.Errorf("password cannot be empty")
+					}
+					return nil
+				}),
+			huh.NewInput().
+				Title("Confirm new password").
+				Value(&confirm).
+				EchoMode(huh.EchoModePassword).
+				Validate(func(s string) error {
+					if s != m.newPassword {
+						return fmt.Errorf("passwords do not match")
+					}
+					return nil
+				}),
+		),
+	).
+		WithTheme(huh.ThemeCatppuccin()).
+		WithWidth(m.formWidth)
 }
 
 // Init initializes the model
@@ -90,21 +194,17 @@ func (m *LoginModel) Init() tea.Cmd {
 	return m.form.Init()
 }
 
-// attemptAutoLogin tries to login automatically if credentials are provided
+// attemptAutoLogin tries to login automatically if credentials are provided.
+// Unknown usernames go through the same registration confirmation as the form.
 func (m *LoginModel) attemptAutoLogin() tea.Cmd {
-	return tea.Sequence(
-		func() tea.Msg {
-			user, _, err := m.userService.Login(m.prefillUser, m.prefillPass)
-			if err != nil {
-				// User doesn't exist, try to register
-				user, err = m.userService.Register(m.prefillUser, m.prefillPass)
-				if err != nil {
-					return LoginErrorMsg{Error: err}
-				}
-			}
-			return LoginSuccessMsg{User: user}
-		},
-	)
+	return m.submitCredentials(m.prefillUser, m.prefillPass)
+}
+
+// handleShutdown tells the user the server is going away. Nothing is persisted before login.
+func (m *LoginModel) handleShutdown(msg ShutdownMsg) (tea.Model, tea.Cmd) {
+	m.err = fmt.Errorf("server shutting down: %s", msg.Reason)
+	msg.Ack()
+	return m, nil
 }
 
 // Update handles messages
@@ -126,11 +226,24 @@ func (m *LoginModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.form = m.form.WithWidth(formWidth)
 		return m, nil
 
+	case ShutdownMsg:
+		return m.handleShutdown(msg)
+
+	case AnnouncementMsg, notificationTickMsg, notificationsMsg:
+		// Announcements and notifications are for logged-in players, and a shell's
+		// notification ticks stop once it has logged out
+		return m, nil
+
 	case tea.KeyMsg:
 		switch msg.String() {
 		case "ctrl+q":
 			// Allow quitting from login
 			return m, tea.Quit
+		case "esc":
+			// Back out of a secondary step to the credentials form
+			if m.step != stepCredentials {
+				return m, m.showStep(stepCredentials, nil, nil)
+			}
 		}
 
 	case LoginSuccessMsg:
@@ -140,44 +253,20 @@ func (m *LoginModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		// Store user in context for shell to use
 		// Transition to shell model with current window size
 		shellModel := NewShellModelWithSize(m.db, m.userService, msg.User, m.width, m.height, m.chatService)
+		shellModel.sourceIP = m.sourceIP
+		m.startDeviceSession(shellModel, msg)
 		return shellModel, shellModel.Init()
 
+	case loginStepMsg:
+		if msg.ResetToken != "" {
+			m.resetToken = msg.ResetToken
+		}
+		return m, m.showStep(msg.Step, msg.User, msg.Error)
+
 	case LoginErrorMsg:
-		// Show generic error message for security
-		m.err = fmt.Errorf("invalid username or password")
-		// Reset form state to allow retry
-		m.form.State = huh.StateNormal
-		// Clear password field for security
+		// Clear password field for security and go back to the credentials form
 		m.password = ""
-		// Recreate form to reset it - use model fields directly
-		m.form = huh.NewForm(
-			huh.NewGroup(
-				huh.NewInput().
-					Title("Username").
-					Value(&m.username).
-					Placeholder("Enter your username").
-					Validate(func(s string) error {
-						if s == "" {
-							return fmt.Errorf("username cannot be empty")
-						}
-						return nil
-					}),
-				huh.NewInput().
-					Title("Password").
-					Value(&m.password).
-					Placeholder("Enter your password").
-					EchoMode(huh.EchoModePassword).
-					Validate(func(s string) error {
-						if s == "" {
-							return fmt.Errorf("password cannot be empty")
-						}
-						return nil
-					}),
-			),
-		).
-			WithTheme(huh.ThemeCatppuccin()).
-			WithWidth(m.formWidth)
-		return m, m.form.Init()
+		return m, m.showStep(stepCredentials, nil, msg.Error)
 	}
 
 	if !m.authenticated {
@@ -201,34 +290,163 @@ func (m *LoginModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
-// handleSubmit processes the form submission
+// startDeviceSession hands device session tokens to the transport and the shell.
+// Logins and resumes carry their tokens in the message; new accounts get a new device session.
+func (m *LoginModel) startDeviceSession(shell *ShellModel, msg LoginSuccessMsg) {
+	shell.sessionHooks = m.sessionHooks
+	if m.sessionHooks == nil {
+		return
+	}
+
+	tokens := msg.Tokens
+	if tokens == nil {
+		user, ok := msg.User.(*models.User)
+		if !ok {
+			return
+		}
+		var err error
+		tokens, err = m.userService.IssueDeviceSession(user, m.sessionHooks.Device, m.sourceIP)
+		if err != nil {
+			// Not fatal: the user is logged in, they just won't be resumed on reconnect
+			return
+		}
+	}
+
+	shell.setDeviceSession(tokens.SessionID)
+	if m.sessionHooks.OnLogin != nil {
+		m.sessionHooks.OnLogin(tokens)
+	}
+}
+
+// device returns the client description to start device sessions for, or "" if the
+// transport doesn't keep logins.
+func (m *LoginModel) device() string {
+	if m.sessionHooks == nil {
+		return ""
+	}
+	return m.sessionHooks.Device
+}
+
+// showStep switches the form to the given step and shows err (if any) above it
+func (m *LoginModel) showStep(step loginStep, user *models.User, err error) tea.Cmd {
+	m.step = step
+	m.err = err
+	if user != nil || step == stepCredentials {
+		m.pendingUser = user
+	}
+	if step == stepCredentials {
+		m.resetToken = ""
+	}
+
+	switch step {
+	case stepTOTP:
+		m.form = m.newTOTPForm()
+	case stepConfirmRegister:
+		m.form = m.newConfirmRegisterForm()
+	case stepNewPassword:
+		m.form = m.newPasswordForm()
+	default:
+		m.form = m.newCredentialsForm()
+	}
+	return m.form.Init()
+}
+
+// handleSubmit processes the form submission for the current step
 func (m *LoginModel) handleSubmit() tea.Cmd {
-	return tea.Sequence(
-		func() tea.Msg {
-			// Try to login first
-			user, _, err := m.userService.Login(m.username, m.password)
-			if err != nil {
-			// Check if it's a password error (user exists but wrong password)
-			// vs user doesn't exist (auto-register)
-			var existingUser models.User
-			err := m.db.Where("username = ?", m.username).First(&existingUser).Error
-			userExists := err == nil
-				
-				if userExists {
-					// User exists but password is wrong - return generic error
-					return LoginErrorMsg{Error: fmt.Errorf("invalid username or password")}
-				}
-				
-				// User doesn't exist, try to register (auto-create)
-				user, err = m.userService.Register(m.username, m.password)
-				if err != nil {
-					// Registration failed - return generic error for security
-					return LoginErrorMsg{Error: fmt.Errorf("invalid username or password")}
-				}
+	switch m.step {
+	case stepTOTP:
+		return m.submitTOTP()
+	case stepConfirmRegister:
+		return m.submitRegistration()
+	case stepNewPassword:
+		return m.submitNewPassword()
+	default:
+		return m.submitCredentials(m.username, m.password)
+	}
+}
+
+// submitCredentials checks the username and password and decides the next step
+func (m *LoginModel) submitCredentials(username, password string) tea.Cmd {
+	return func() tea.Msg {
+		user, tokens, err := m.userService.Login(username, password, m.sourceIP, m.device())
+		var lockout *services.LockoutError
+		switch {
+		case err == nil:
+			return LoginSuccessMsg{User: user, Tokens: tokens}
+		case errors.Is(err, services.ErrUserNotFound):
+			// Never create an account without asking first
+			return loginStepMsg{Step: stepConfirmRegister}
+		case errors.Is(err, services.ErrTOTPRequired):
+			return loginStepMsg{Step: stepTOTP, User: user}
+		case errors.Is(err, services.ErrPasswordResetRequired):
+			return loginStepMsg{Step: stepNewPassword, User: user, ResetToken: password,
+				Error: fmt.Errorf("reset token accepted - choose a new password")}
+		case errors.As(err, &lockout):
+			return LoginErrorMsg{Error: lockout}
+		default:
+			// Show generic error message for security
+			return LoginErrorMsg{Error: services.ErrInvalidCredentials}
+		}
+	}
+}
+
+// submitTOTP verifies the two-factor code for the pending user
+func (m *LoginModel) submitTOTP() tea.Cmd {
+	user := m.pendingUser
+	code := m.totpCode
+	return func() tea.Msg {
+		if user == nil {
+			return LoginErrorMsg{Error: services.ErrInvalidCredentials}
+		}
+		tokens, err := m.userService.VerifyTOTPLogin(user, code, m.sourceIP, m.device())
+		if err != nil {
+			var lockout *services.LockoutError
+			if errors.As(err, &lockout) {
+				return LoginErrorMsg{Error: lockout}
 			}
-			return LoginSuccessMsg{User: user}
-		},
-	)
+			return loginStepMsg{Step: stepTOTP, User: user, Error: fmt.Errorf("invalid code")}
+		}
+		return LoginSuccessMsg{User: user, Tokens: tokens}
+	}
+}
+
+// submitRegistration creates the account once the user has confirmed
+func (m *LoginModel) submitRegistration() tea.Cmd {
+	if !m.confirmCreate {
+		m.password = ""
+		return m.showStep(stepCredentials, nil, nil)
+	}
+	username, password := m.username, m.password
+	return func() tea.Msg {
+		user, err := m.userService.Register(username, password)
+		if err != nil {
+			return LoginErrorMsg{Error: fmt.Errorf("registration failed: %w", err)}
+		}
+		return LoginSuccessMsg{User: user}
+	}
+}
+
+// submitNewPassword saves the new password chosen after a reset token login.
+// Accounts with TOTP enabled still have to enter a code afterwards.
+func (m *LoginModel) submitNewPassword() tea.Cmd {
+	user := m.pendingUser
+	token, newPassword := m.resetToken, m.newPassword
+	return func() tea.Msg {
+		if user == nil {
+			return LoginErrorMsg{Error: services.ErrInvalidCredentials}
+		}
+		updated, tokens, err := m.userService.ResetPassword(user.ID, token, newPassword, m.sourceIP, m.device())
+		switch {
+		case err == nil:
+			return LoginSuccessMsg{User: updated, Tokens: tokens}
+		case errors.Is(err, services.ErrTOTPRequired):
+			return loginStepMsg{Step: stepTOTP, User: updated, Error: fmt.Errorf("password changed - enter your two-factor code")}
+		case errors.Is(err, services.ErrInvalidCredentials):
+			return LoginErrorMsg{Error: fmt.Errorf("reset token is no longer valid")}
+		default:
+			return loginStepMsg{Step: stepNewPassword, User: user, Error: err}
+		}
+	}
 }
 
 // View renders the UI
@@ -256,7 +474,7 @@ func (m *LoginModel) View() string {
 ╚═══════════════════════════════════════╝`
 	content.WriteString(ui.HeaderStyle.Render(title))
 	content.WriteString("\n\n")
-	content.WriteString(ui.InfoStyle.Render("Enter your credentials to continue"))
+	content.WriteString(ui.InfoStyle.Render(m.stepHint()))
 	content.WriteString("\n\n")
 
 	if m.err != nil {
@@ -274,11 +492,42 @@ func (m *LoginModel) View() string {
 	)
 }
 
+// SessionHooks lets a transport keep users logged in across reconnects.
+// The web bridge uses it to hand tokens to the browser and clear them on logout.
+type SessionHooks struct {
+	Device   string                               // Client description recorded on the device session
+	OnLogin  func(tokens *services.SessionTokens) // Called with the device session tokens after login
+	OnLogout func()                               // Called after the device session is revoked by logout
+}
+
 // Messages
 type LoginSuccessMsg struct {
-	User interface{}
+	User   interface{}
+	Tokens *services.SessionTokens // Set when the login started or resumed a device session
 }
 
 type LoginErrorMsg struct {
 	Error error
 }
+
+// loginStepMsg moves the login flow to another step
+type loginStepMsg struct {
+	Step       loginStep
+	User       *models.User
+	ResetToken string // Set when moving to stepNewPassword
+	Error      error
+}
+
+// stepHint returns the instruction shown above the form for the current step
+func (m *LoginModel) stepHint() string {
+	switch m.step {
+	case stepTOTP:
+		return "Enter the code from your authenticator app (Esc to go back)"
+	case stepConfirmRegister:
+		return "Confirm account creation (Esc to go back)"
+	case stepNewPassword:
+		return "Choose a new password to finish resetting your account"
+	default:
+		return "Enter your credentials to continue"
+	}
+}
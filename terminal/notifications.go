@@ -0,0 +1,57 @@
+package terminal
+
+import (
+	"fmt"
+	"time"
+
+	"terminal-sh/cmd"
+	"terminal-sh/models"
+	"terminal-sh/ui"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// NotificationPollInterval is how often the shell checks for new notifications. They're kept
+// in the database, so the shell picks up ones sent from any server process.
+const NotificationPollInterval = 5 * time.Second
+
+// notificationTickMsg tells a shell it's time to check for notifications.
+type notificationTickMsg struct {
+	shell *ShellModel // Shell that started the ticks, so a logged-out shell's ticks stop
+}
+
+// notificationsMsg carries the user's unread notifications to the status line.
+type notificationsMsg struct {
+	Unread int64
+	Latest *models.Notification
+}
+
+// nextNotificationTick schedules the next notification check.
+func (m *ShellModel) nextNotificationTick() tea.Cmd {
+	return tea.Tick(NotificationPollInterval, func(time.Time) tea.Msg {
+		return notificationTickMsg{shell: m}
+	})
+}
+
+// checkNotifications reads the user's unread notifications for the status line.
+func (m *ShellModel) checkNotifications() tea.Msg {
+	if m.user == nil {
+		return nil
+	}
+	count, latest, err := m.notifications.Unread(m.user.ID)
+	if err != nil {
+		return nil
+	}
+	return notificationsMsg{Unread: count, Latest: latest}
+}
+
+// notificationStatus renders the status line shown above the prompt while the user has unread
+// notifications: how many, and the latest of them.
+func (m *ShellModel) notificationStatus(width int) string {
+	if m.unreadNotifications == 0 || m.latestNotification == nil {
+		return ""
+	}
+	status := fmt.Sprintf("🔔 %d new · %s %s · run 'notifications'", m.unreadNotifications,
+		cmd.NotificationIcon(m.latestNotification.Kind), m.latestNotification.Message)
+	return ui.InfoStyle.MaxWidth(width).Render(status)
+}
@@ -347,15 +347,7 @@ func FormatDirListWithOptions(nodes []*filesystem.Node, longFormat bool) string
 	for _, node := range nodes {
 		if longFormat {
 			// Long format: show file details with colors and emojis
-			// Format: permissions size emoji name
-			perms := "-rw-r--r--"
-			if node.IsDir {
-				perms = "drwxr-xr-x"
-			}
-			size := "0"
-			if !node.IsDir {
-				size = fmt.Sprintf("%d", len(node.Content))
-			}
+			// Format: permissions links owner group size mtime emoji name
 			
 			name := node.Name
 			emoji, color := getFileTypeInfo(name)
@@ -366,7 +358,13 @@ func FormatDirListWithOptions(nodes []*filesystem.Node, longFormat bool) string
 			}
 			
 			nameStyle := lipgloss.NewStyle().Foreground(lipgloss.Color(color))
-			output.WriteString(fmt.Sprintf("%s %6s %s %s\n", perms, size, emoji, nameStyle.Render(name)))
+			rendered := nameStyle.Render(name)
+			if node.IsSymlink() {
+				rendered += " -> " + node.Symlink
+			}
+			output.WriteString(fmt.Sprintf("%s %2d %-8s %-8s %6d %s %s %s\n",
+				node.ModeString(), node.LinkCount(), node.GetOwner(), node.GetGroup(), node.Size(),
+				formatModTime(node.GetModTime()), emoji, rendered))
 		} else {
 			// Short format: just colored name (no emoji)
 			if node.IsDir {
@@ -388,6 +386,14 @@ func FormatDirListWithOptions(nodes []*filesystem.Node, longFormat bool) string
 	return result
 }
 
+// formatModTime formats a modification time like ls -l: time of day for recent files, year otherwise
+func formatModTime(t time.Time) string {
+	if time.Since(t) > 180*24*time.Hour || t.After(time.Now().Add(time.Hour)) {
+		return t.Format("Jan _2  2006")
+	}
+	return t.Format("Jan _2 15:04")
+}
+
 // FormatError formats an error message for display
 // Returns error message with trailing newline
 func FormatError(err error) string {
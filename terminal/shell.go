@@ -35,6 +35,7 @@ type ShellModel struct {
 	vfs         *filesystem.VFS
 	handler     *cmd.CommandHandler
 	chatService *services.ChatService
+	sessionService *services.SessionService
 	history     []struct {
 		command string
 		output  string
@@ -75,6 +76,23 @@ type ShellModel struct {
 
 	// Progress bar state
 	activeProgress    *progressState // Currently active progress operation
+	resumeRemaining   float64        // Seconds left on an interrupted operation being resumed
+
+	notice string // Server notice shown above the prompt (shutdown, interrupted operations)
+
+	// Secret prompt state (passwd): values are read masked and never added to input history
+	secretPrompt *cmd.SecretPromptRequest
+	secretValues []string
+
+	// Notification status line
+	notifications       *services.NotificationService
+	unreadNotifications int64
+	latestNotification  *models.Notification
+
+	sourceIP string // Client address, handed back to the login form on logout
+
+	sessionHooks    *SessionHooks // Transport hooks for device sessions (nil over SSH)
+	deviceSessionID uuid.UUID     // Device session revoked on logout
 }
 
 // progressState tracks an active progress bar operation
@@ -114,11 +132,11 @@ func NewShellModelWithSize(db *database.Database, userService *services.UserServ
 
 	// Create VFS and merge user's saved filesystem changes
 	var vfs *filesystem.VFS
+	var loadErr error
 	if u != nil && u.FileSystem != nil && len(u.FileSystem) > 0 {
-		var err error
-		vfs, err = filesystem.NewVFSFromMap(username, u.FileSystem)
-		if err != nil {
-			// If merge fails, fall back to standard VFS
+		vfs, loadErr = filesystem.NewUserVFSFromMap(username, u.FileSystem)
+		if loadErr != nil {
+			// Work in a fresh VFS for this session, but never save it over the files that failed to load
 			vfs = filesystem.NewVFS(username)
 		}
 	} else {
@@ -126,12 +144,12 @@ func NewShellModelWithSize(db *database.Database, userService *services.UserServ
 	}
 	
 	// Set up save callback for user filesystem
-	if u != nil {
+	if u != nil && loadErr == nil {
 		vfs.SetUserID(u.ID.String())
 		vfs.SetSaveCallback(func(changes map[string]interface{}) error {
 			// Update user's filesystem in database
 			u.FileSystem = changes
-			return db.Model(u).Update("file_system", changes).Error
+			return db.Model(u).Select("file_system").Updates(u).Error
 		})
 	}
 	
@@ -168,6 +186,8 @@ func NewShellModelWithSize(db *database.Database, userService *services.UserServ
 		vfs:         vfs,
 		handler:     handler,
 		chatService: chatService,
+		sessionService: services.NewSessionService(db, services.NewServerService(db)),
+		notifications:  services.NewNotificationService(db),
 		history: make([]struct {
 			command string
 			output  string
@@ -186,6 +206,10 @@ func NewShellModelWithSize(db *database.Database, userService *services.UserServ
 		gradientSeed:      time.Now().UnixNano(),
 	}
 
+	if loadErr != nil {
+		shellModel.notice = fmt.Sprintf("Your saved files couldn't be loaded (%v). They are untouched, but nothing you change this session will be saved.", loadErr)
+	}
+
 	// Precompute initial gradient frames
 	shellModel.refreshGradientFrames()
 
@@ -205,6 +229,7 @@ func NewShellModelWithSize(db *database.Database, userService *services.UserServ
 			return nil
 		},
 	)
+	handler.SetConnectionChain(shellModel.connectionChain)
 
 	return shellModel
 }
@@ -224,9 +249,23 @@ func (m *ShellModel) Init() tea.Cmd {
 		cmds = append(cmds, tick)
 	}
 
+	if m.user != nil {
+		cmds = append(cmds, m.loadInterruptedOperations)
+		// Show what happened while the user was offline, then keep checking
+		cmds = append(cmds, m.checkNotifications, m.nextNotificationTick())
+	}
+
 	return tea.Batch(cmds...)
 }
 
+// handleShutdown warns the user the server is draining and persists their filesystems.
+func (m *ShellModel) handleShutdown(msg ShutdownMsg) (tea.Model, tea.Cmd) {
+	m.notice = fmt.Sprintf("Server shutting down: %s. Your files have been saved; interrupted operations resume on next login.", msg.Reason)
+	m.flushFilesystems()
+	msg.Ack()
+	return m, nil
+}
+
 // Update handles messages
 func (m *ShellModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
@@ -269,6 +308,9 @@ func (m *ShellModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if m.editMode {
 			return m.handleEditModeInput(msg)
 		}
+		if m.secretPrompt != nil {
+			return m.handleSecretPromptInput(msg)
+		}
 
 		switch msg.String() {
 		case "ctrl+q":
@@ -279,12 +321,23 @@ func (m *ShellModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			// In base shell, return to login
 			return m.handleLogout()
 		case "ctrl+c":
-			// Ctrl+C: clear input if no text selected (terminal default behavior)
+			// Ctrl+C: kill the job running in the foreground before it has any effect
+			if m.killForegroundJob() {
+				return m.Update(CommandResultMsg{Result: &cmd.CommandResult{Output: "^C"}})
+			}
+			// Otherwise clear input if no text selected (terminal default behavior)
 			// Text selection is handled by terminal emulator, so if we get here,
 			// it means no selection - clear the input
 			m.textInput.SetValue("")
 			m.inputHistory.Reset()
 			return m, nil
+		case "ctrl+z":
+			// Ctrl+Z: send the job running in the foreground to the background
+			if job := m.handler.Processes().Background(); job != nil {
+				m.activeProgress = nil
+				return m.Update(CommandResultMsg{Result: &cmd.CommandResult{Output: "^Z\n" + cmd.FormatJobLine(*job)}})
+			}
+			return m, nil
 		case "up":
 			// Navigate command history backward
 			if cmd, ok := m.inputHistory.Previous(); ok {
@@ -405,6 +458,7 @@ func (m *ShellModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 			// Scroll to bottom when executing command
 			m.scrollToBottom()
+			m.clearNotice()
 			// Don't clear textInput yet - keep command visible until result comes
 			// Set flag to prevent prompt updates while command executes
 			m.commandPending = true
@@ -451,6 +505,12 @@ func (m *ShellModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		// process it, which should allow the terminal emulator to handle it
 		return m, nil
 	case PasteTextMsg:
+		if m.secretPrompt != nil {
+			// Pasted secrets go into the prompt as they are; Enter still submits them
+			m.textInput.SetValue(m.textInput.Value() + strings.TrimRight(msg.Text, "\r\n"))
+			m.textInput.CursorEnd()
+			return m, nil
+		}
 		// Handle paste: append text to current input value
 		currentValue := m.textInput.Value()
 		// Filter out control characters, keep only printable ASCII and newlines
@@ -471,6 +531,7 @@ func (m *ShellModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				if currentValue != "" {
 					// Scroll to bottom when executing command
 					m.scrollToBottom()
+					m.clearNotice()
 					m.commandPending = true
 					// Add command to history immediately
 					m.history = append(m.history, struct {
@@ -554,6 +615,25 @@ func (m *ShellModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case LogoutMsg:
 		// Return to login screen
 		return m.handleLogout()
+	case ShutdownMsg:
+		return m.handleShutdown(msg)
+	case AnnouncementMsg:
+		// Shutdown notices take priority over announcements
+		if !Draining() {
+			m.notice = msg.Text
+		}
+		return m, nil
+	case notificationTickMsg:
+		if msg.shell != m {
+			return m, nil
+		}
+		return m, tea.Batch(m.checkNotifications, m.nextNotificationTick())
+	case notificationsMsg:
+		m.unreadNotifications = msg.Unread
+		m.latestNotification = msg.Latest
+		return m, nil
+	case resumeOperationsMsg:
+		return m.resumeInterruptedOperations(msg.Operations)
 	case ProgressStartMsg:
 		// Start a progress bar operation
 		m.activeProgress = &progressState{
@@ -584,6 +664,10 @@ func (m *ShellModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 	case ProgressCompleteMsg:
+		// A job that finished in the background is reported before the next prompt
+		if msg.Job != nil && !m.handler.Processes().Finish(msg.Job, msg.Result) {
+			return m, nil
+		}
 		// Progress operation completed
 		if m.activeProgress != nil && m.activeProgress.ID == msg.ID {
 			m.activeProgress = nil
@@ -599,35 +683,54 @@ func (m *ShellModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			req := msg.Result.StartProgress
 			// Clear input but keep command pending (we're still waiting for the operation)
 			m.textInput.SetValue("")
-			m.commandPending = true // Still waiting for the operation
 			m.showWelcome = false
 			
 			// Start the progress bar and run the operation in background
-			duration := time.Duration(req.Duration * float64(time.Second))
-			operationID := req.ID
-			operation := req.Operation
-			
-			return m, tea.Batch(
-				// Start progress bar
-				func() tea.Msg {
-					return ProgressStartMsg{
-						ID:       operationID,
-						Message:  req.Message,
-						Duration: duration,
-					}
-				},
-				// Run the actual operation in background
-				func() tea.Msg {
-					// Wait for the duration to let progress bar animate
-					time.Sleep(duration)
-					// Execute the actual operation
-					result := operation()
-					return ProgressCompleteMsg{
-						ID:     operationID,
-						Result: result,
-					}
-				},
-			)
+			seconds := req.Duration
+			if m.resumeRemaining > 0 && m.resumeRemaining < seconds {
+				// Resumed operations only wait for the time left when they were interrupted
+				seconds = m.resumeRemaining
+			}
+			m.resumeRemaining = 0
+			duration := time.Duration(seconds * float64(time.Second))
+			op := m.trackProgressOperation(req.Message, duration)
+			job := m.handler.Processes().Start(req, m.handler.GetCurrentServerPath(), duration, !req.Background)
+
+			if req.Background {
+				// Background jobs report their number and PID and give the prompt straight back
+				model, started := m.Update(CommandResultMsg{
+					Result: &cmd.CommandResult{Output: fmt.Sprintf("[%d] %d", job.ID, job.PID)},
+				})
+				return model, tea.Batch(started, m.runJob(job, req.Operation, op))
+			}
+			m.commandPending = true // Still waiting for the operation
+			m.activeProgress = jobProgress(job)
+			return m, tea.Batch(m.nextProgressTick(), m.runJob(job, req.Operation, op))
+		}
+
+		// Handle secret prompts: the command finishes once every value has been entered
+		if msg.Result.PromptSecret != nil && len(msg.Result.PromptSecret.Prompts) > 0 {
+			m.secretPrompt = msg.Result.PromptSecret
+			m.secretValues = nil
+			m.textInput.SetValue("")
+			m.textInput.EchoMode = textinput.EchoNone
+			m.showWelcome = false
+			m.commandPending = false
+			m.commandJustDone = true // Move to the next line for the first prompt
+			m.pendingOutput = ""
+			return m, nil
+		}
+
+		// Handle fg: the job's progress bar takes over the prompt until it finishes
+		if msg.Result.Foreground != nil {
+			job := msg.Result.Foreground
+			if !m.handler.Processes().Foreground(job) {
+				return m.Update(CommandResultMsg{Result: &cmd.CommandResult{Error: fmt.Errorf("fg: job has terminated")}})
+			}
+			m.textInput.SetValue("")
+			m.commandPending = true
+			m.activeProgress = jobProgress(job)
+			return m, m.nextProgressTick()
 		}
 
 		// Handle ASCII animation trigger
@@ -724,6 +827,7 @@ func (m *ShellModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					} else {
 						// Set role on the VFS for permission checking
 						serverVFS.SetRole(accessUsername, isRoot, homeDir)
+						serverVFS.SetGroups(m.handler.GetRoleGroups(newServerPath, accessUsername))
 						
 						// Push current context to stack (including current service type)
 						m.shellStack = append(m.shellStack, ShellContext{
@@ -733,29 +837,28 @@ func (m *ShellModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 							handler:     m.handler,
 						})
 						
-						// Switch to server VFS
-						m.vfs = serverVFS
-						m.handler.SetVFS(serverVFS)
-						
-						// Update handler's server path and service type
-						m.handler.SetCurrentServerPath(newServerPath)
-						m.handler.SetCurrentServiceType(serviceType)
-						
 						// Navigate to home directory
 						serverVFS.ChangeDir(homeDir)
 						
+						// Switch the handler to the server's VFS, path and service type
+						m.vfs = serverVFS
+						m.handler.SwitchContext(serverVFS, newServerPath, serviceType)
+						
 						// Add connection message to history
 						pathParts := strings.Split(newServerPath, ".")
 						serverIP := pathParts[len(pathParts)-1]
+						output = m.handler.ConnectBanner(newServerPath, serviceType)
 						roleIndicator := "$"
 						if isRoot {
 							roleIndicator = "#"
 						}
 						if accessMethod == "backdoor" {
-							output = fmt.Sprintf("Connected to %s via %s (backdoor)\nLogged in as %s\n%s@%s:%s%s\n", 
+							output += fmt.Sprintf("Connected to %s via %s (backdoor)\nLogged in as %s\n%s@%s:%s%s\n", 
 								serverIP, serviceType, accessUsername, accessUsername, serverIP, homeDir, roleIndicator)
+						} else if serviceType == "ftp" {
+							output += fmt.Sprintf("230 Login successful as %s.\nRemote system type is UNIX.\nType 'help' for FTP commands.\n", accessUsername)
 						} else {
-							output = fmt.Sprintf("Authenticated to %s via %s as %s\n%s@%s:%s%s\n", 
+							output += fmt.Sprintf("Authenticated to %s via %s as %s\n%s@%s:%s%s\n", 
 								serverIP, serviceType, accessUsername, accessUsername, serverIP, homeDir, roleIndicator)
 						}
 					}
@@ -776,13 +879,9 @@ func (m *ShellModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 							handler:     m.handler,
 						})
 						
-						// Switch to server VFS
+						// Switch the handler to the server's VFS and path
 						m.vfs = serverVFS
-						m.handler.SetVFS(serverVFS)
-						
-						// Update handler's server path
-						m.handler.SetCurrentServerPath(newServerPath)
-						m.handler.SetCurrentServiceType("ssh")
+						m.handler.SwitchContext(serverVFS, newServerPath, "ssh")
 						
 						// Add connection message to history
 						pathParts := strings.Split(newServerPath, ".")
@@ -793,6 +892,11 @@ func (m *ShellModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				} else if msg.Result.Output == "__EXIT_CONNECT__" || msg.Result.Output == "__EXIT_SSH__" {
 					// Handle exit from server session - return immediately
 					return m.handleExitConnection()
+				} else if strings.HasPrefix(msg.Result.Output, "__KICKED__") {
+					// The server's admin killed the session - drop back like exit, with their message
+					model, exitCmd := m.handleExitConnection()
+					m.pendingOutput = strings.TrimPrefix(msg.Result.Output, "__KICKED__") + m.pendingOutput
+					return model, exitCmd
 				} else if msg.Result.Output == "__QUIT__" {
 					// Quit the program
 					return m, tea.Quit
@@ -838,6 +942,8 @@ func (m *ShellModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			if msg.Result.MissionCompleted != nil {
 				output += cmd.FormatMissionCompletion(msg.Result.MissionCompleted)
 			}
+			// Report background jobs that finished since the last prompt
+			output += m.reapJobs()
 			// Set output in history
 			m.history[lastIdx].output = output
 
@@ -855,7 +961,8 @@ func (m *ShellModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.commandPending = false
 			m.textInput.SetValue("")
 		}
-		return m, nil
+		// The command may have read notifications or caused new ones
+		return m, m.checkNotifications
 	}
 	return m, nil
 }
@@ -874,6 +981,10 @@ func (m *ShellModel) executeCommand(command string) tea.Cmd {
 			// In base shell, return to login
 			return LogoutMsg{}
 		}
+		if command == "logout" {
+			// Sign out from anywhere, revoking this device's session
+			return LogoutMsg{}
+		}
 
 		// Execute command
 		result := m.handler.Execute(command)
@@ -979,6 +1090,9 @@ func (m *ShellModel) getPromptLine(username string) string {
 	promptUser, hostname := m.handler.GetPromptInfo()
 	promptChar := m.handler.GetPromptChar()
 	m.textInput.Prompt = RenderPromptWithChar(promptUser, hostname, m.vfs.GetCurrentPath(), promptChar)
+	if m.secretPrompt != nil {
+		m.textInput.Prompt = m.secretPrompt.Prompts[len(m.secretValues)]
+	}
 	return m.textInput.View()
 }
 
@@ -1227,6 +1341,16 @@ func (m *ShellModel) GetViewContent() string {
 		}
 	}
 
+	// Add server notice if any
+	if m.notice != "" {
+		contentLines = append(contentLines, ui.WarningStyle.Render(m.notice))
+	}
+
+	// Add notification status line if anything is unread
+	if status := m.notificationStatus(width); status != "" {
+		contentLines = append(contentLines, status)
+	}
+
 	// Build output (without prompt)
 	var output strings.Builder
 
@@ -1364,6 +1488,9 @@ func (m *ShellModel) View() string {
 	// Build the current prompt line (use handler's prompt info for SSH context)
 	promptUser, hostname := m.handler.GetPromptInfo()
 	m.textInput.Prompt = RenderPrompt(promptUser, hostname, m.vfs.GetCurrentPath())
+	if m.secretPrompt != nil {
+		m.textInput.Prompt = m.secretPrompt.Prompts[len(m.secretValues)]
+	}
 	m.textInput.Width = width
 	promptLine := m.textInput.View()
 
@@ -1415,6 +1542,12 @@ type ProgressTickMsg struct {
 type ProgressCompleteMsg struct {
 	ID     string          // Which operation completed
 	Result *cmd.CommandResult // The result of the operation
+	Job    *cmd.Job           // The job that ran it, if any
+}
+
+// resumeOperationsMsg carries operations interrupted by the last server shutdown
+type resumeOperationsMsg struct {
+	Operations []models.InterruptedOperation
 }
 
 // getCommandMatches returns commands that start with the given prefix
@@ -1424,12 +1557,15 @@ func (m *ShellModel) getCommandMatches(prefix string) []string {
 	// Tool commands (password_cracker, ssh_exploit, etc.) come from GetUserToolNames()
 	builtInCommands := []string{
 		"pwd", "ls", "cd", "cat", "clear", "help", "chat", "tutorial", "mission",
-		"login", "logout", "register", "userinfo", "info", "whoami", "name",
+		"login", "logout", "register", "userinfo", "achievements", "info", "whoami", "name", "passwd", "2fa", "sessions",
 		"ifconfig", "scan", "server",
-		"connect", "ssh", "telnet", "ftp", "exit", "get", "download", "dl",
+		"connect", "ssh", "telnet", "ftp", "exit", "get", "download", "dl", "upload", "scp",
+		"nmap", "traceroute", "route", "tunnel", "curl", "browse", "mysql",
 		"tools", "exploited", "credentials", "creds", "backdoors", "shop", "buy",
-		"patches", "patch", "crypto_miner", "stop_mining", "miners", "wallet",
+		"patches", "patch", "crypto_miner", "stop_mining", "miners", "pool", "ps", "jobs", "fg", "kill", "wallet", "transactions", "pay", "trade", "market", "bounty", "event", "notifications",
 		"ascii", "touch", "mkdir", "rm", "cp", "mv", "edit", "vi", "nano",
+		"chmod", "chown", "stat", "ln", "readlink",
+		"tar", "unzip", "gunzip", "decrypt",
 	}
 	
 	// Get user's owned tools (only include tool commands the user owns)
@@ -1491,10 +1627,28 @@ func (m *ShellModel) getFileMatches(prefix string) []string {
 	return matches
 }
 
+// setDeviceSession records the device session this shell was logged in with
+func (m *ShellModel) setDeviceSession(sessionID uuid.UUID) {
+	m.deviceSessionID = sessionID
+	m.handler.SetDeviceSessionID(sessionID)
+}
+
 // handleLogout returns to the login screen
 func (m *ShellModel) handleLogout() (tea.Model, tea.Cmd) {
+	// Sign this device out so the client can't resume with its stored tokens
+	if m.deviceSessionID != uuid.Nil {
+		if m.sessionHooks != nil && m.sessionHooks.OnLogout != nil {
+			m.sessionHooks.OnLogout()
+		}
+		if m.user != nil {
+			m.userService.RevokeDeviceSession(m.user.ID, m.deviceSessionID)
+		}
+	}
+
 	// Create a new login model with current window size
 	loginModel := NewLoginModel(m.db, m.userService, m.chatService, "", "")
+	loginModel.SetSourceIP(m.sourceIP)
+	loginModel.SetSessionHooks(m.sessionHooks)
 	loginModel.width = m.width
 	loginModel.height = m.height
 	return loginModel, loginModel.Init()
@@ -1516,12 +1670,8 @@ func (m *ShellModel) handleExitConnection() (tea.Model, tea.Cmd) {
 	m.vfs = context.vfs
 	m.handler = context.handler
 	
-	// Update handler's VFS reference
-	m.handler.SetVFS(context.vfs)
-
-	// Update handler's server path and service type
-	m.handler.SetCurrentServerPath(context.serverPath)
-	m.handler.SetCurrentServiceType(context.serviceType)
+	// Update handler's VFS reference, server path and service type
+	m.handler.SwitchContext(context.vfs, context.serverPath, context.serviceType)
 
 	// Add exit message as pending output
 	m.pendingOutput = "Disconnected\n"
@@ -1541,6 +1691,41 @@ func (m *ShellModel) handleExitSSH() (tea.Model, tea.Cmd) {
 	return m.handleExitConnection()
 }
 
+// handleSecretPromptInput reads the values a command asked for with a secret prompt.
+// Ctrl+C cancels the command; Enter moves to the next prompt and, after the last one,
+// hands the values back to the command.
+func (m *ShellModel) handleSecretPromptInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		m.endSecretPrompt()
+		return m.Update(CommandResultMsg{Result: &cmd.CommandResult{Output: "^C"}})
+	case "enter":
+		m.secretValues = append(m.secretValues, m.textInput.Value())
+		m.textInput.SetValue("")
+		if len(m.secretValues) < len(m.secretPrompt.Prompts) {
+			m.commandJustDone = true // Move to the next line for the next prompt
+			return m, nil
+		}
+		submit, values := m.secretPrompt.Submit, m.secretValues
+		m.endSecretPrompt()
+		m.commandPending = true
+		return m, func() tea.Msg {
+			return CommandResultMsg{Result: m.handler.RunOperation(func() *cmd.CommandResult { return submit(values) })}
+		}
+	}
+	var cmd tea.Cmd
+	m.textInput, cmd = m.textInput.Update(msg)
+	return m, cmd
+}
+
+// endSecretPrompt drops the secret prompt and its values and goes back to echoing input
+func (m *ShellModel) endSecretPrompt() {
+	m.secretPrompt = nil
+	m.secretValues = nil
+	m.textInput.SetValue("")
+	m.textInput.EchoMode = textinput.EchoNormal
+}
+
 // handleEditModeInput handles input when in edit mode
 func (m *ShellModel) handleEditModeInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	var cmd tea.Cmd
@@ -1614,6 +1799,83 @@ func (m *ShellModel) nextProgressTick() tea.Cmd {
 	})
 }
 
+// jobProgress returns the progress bar for a job running in the foreground.
+func jobProgress(job *cmd.Job) *progressState {
+	return &progressState{
+		ID:        fmt.Sprintf("job-%d", job.PID),
+		Message:   job.Message,
+		StartTime: job.StartTime,
+		Duration:  job.Duration,
+		Progress:  job.Progress(),
+	}
+}
+
+// runJob runs a job's operation once its duration has passed, unless the job is killed
+// first, in which case the operation never runs and nothing it would have done happens.
+func (m *ShellModel) runJob(job *cmd.Job, operation func() *cmd.CommandResult, op *inflightOp) tea.Cmd {
+	processes := m.handler.Processes()
+	return func() tea.Msg {
+		if !job.Wait() {
+			if op != nil {
+				op.done()
+			}
+			return nil
+		}
+		// Skip the operation if shutdown persisted it for resumption
+		if op != nil {
+			if !op.begin() {
+				return ProgressCompleteMsg{
+					ID:     fmt.Sprintf("job-%d", job.PID),
+					Job:    job,
+					Result: &cmd.CommandResult{Output: "Operation interrupted by server shutdown. It will resume on your next login."},
+				}
+			}
+			defer op.done()
+		}
+		if !processes.Begin(job) {
+			return nil
+		}
+		return ProgressCompleteMsg{
+			ID:     fmt.Sprintf("job-%d", job.PID),
+			Job:    job,
+			Result: m.handler.RunOperation(operation),
+		}
+	}
+}
+
+// reapJobs reports the background jobs that finished since the last prompt, with their output.
+func (m *ShellModel) reapJobs() string {
+	var report strings.Builder
+	for _, job := range m.handler.Processes().Reap() {
+		report.WriteString(cmd.FormatJobLine(job) + "\n")
+		result := job.Result()
+		if result == nil {
+			continue
+		}
+		if result.Error != nil {
+			report.WriteString(FormatError(result.Error))
+		} else if result.Output != "" {
+			report.WriteString(strings.TrimRight(result.Output, "\n") + "\n")
+		}
+		if result.MissionCompleted != nil {
+			report.WriteString(cmd.FormatMissionCompletion(result.MissionCompleted))
+		}
+	}
+	return report.String()
+}
+
+// killForegroundJob kills the job running in the foreground for Ctrl+C. Returns false if
+// there's none, or it's too late to stop it.
+func (m *ShellModel) killForegroundJob() bool {
+	processes := m.handler.Processes()
+	job := processes.ForegroundJob()
+	if job == nil || processes.Kill(job) != nil {
+		return false
+	}
+	m.activeProgress = nil
+	return true
+}
+
 // IsProgressActive returns true if a progress bar is currently active
 func (m *ShellModel) IsProgressActive() bool {
 	return m.activeProgress != nil
@@ -1649,6 +1911,118 @@ func (m *ShellModel) GetProgressBar() string {
 	return fmt.Sprintf("%s [%s] %d%%", m.activeProgress.Message, bar, percentage)
 }
 
+// trackProgressOperation registers a starting progress operation so shutdown can wait for it
+// or persist it for resumption. Returns nil for sessions without a user.
+func (m *ShellModel) trackProgressOperation(message string, duration time.Duration) *inflightOp {
+	if m.user == nil || m.sessionService == nil {
+		return nil
+	}
+
+	record := models.InterruptedOperation{
+		UserID:     m.user.ID,
+		ServerPath: m.handler.GetCurrentServerPath(),
+		Message:    message,
+	}
+	if len(m.history) > 0 {
+		record.Command = m.history[len(m.history)-1].command
+	}
+	if record.Command == "" {
+		return nil
+	}
+
+	startTime := time.Now()
+	sessionService := m.sessionService
+	return trackOperation(func() error {
+		record.Remaining = math.Max(0, (duration - time.Since(startTime)).Seconds())
+		return sessionService.SaveInterruptedOperation(&record)
+	})
+}
+
+// loadInterruptedOperations fetches operations interrupted by the last shutdown
+func (m *ShellModel) loadInterruptedOperations() tea.Msg {
+	ops, err := m.sessionService.TakeInterruptedOperations(m.user.ID)
+	if err != nil || len(ops) == 0 {
+		return nil
+	}
+	return resumeOperationsMsg{Operations: ops}
+}
+
+// resumeInterruptedOperations re-runs the first operation that was interrupted on the local shell.
+// Operations interrupted while connected to a server can't be resumed automatically
+// because the connection is gone, so the user is told to run them again.
+func (m *ShellModel) resumeInterruptedOperations(ops []models.InterruptedOperation) (tea.Model, tea.Cmd) {
+	var resume *models.InterruptedOperation
+	var skipped []string
+	for i := range ops {
+		op := &ops[i]
+		if resume == nil && op.ServerPath == "" {
+			resume = op
+			continue
+		}
+		target := "local shell"
+		if op.ServerPath != "" {
+			pathParts := strings.Split(op.ServerPath, ".")
+			target = pathParts[len(pathParts)-1]
+		}
+		skipped = append(skipped, fmt.Sprintf("'%s' (%s)", op.Command, target))
+	}
+
+	if len(skipped) > 0 {
+		m.notice = fmt.Sprintf("Interrupted by server restart: %s. Run again to retry.", strings.Join(skipped, ", "))
+	}
+
+	if resume == nil || m.commandPending {
+		return m, nil
+	}
+
+	m.scrollToBottom()
+	m.commandPending = true
+	m.showWelcome = false
+	m.resumeRemaining = resume.Remaining
+	m.history = append(m.history, struct {
+		command string
+		output  string
+	}{
+		command: resume.Command,
+		output:  "",
+	})
+	return m, m.executeCommand(resume.Command)
+}
+
+// connectionChain returns every machine in the session's chain of connections, from the
+// player's home computer to the server they're on now.
+func (m *ShellModel) connectionChain() []cmd.ConnectedHost {
+	hosts := make([]cmd.ConnectedHost, 0, len(m.shellStack)+1)
+	for _, ctx := range m.shellStack {
+		hosts = append(hosts, cmd.ConnectedHost{ServerPath: ctx.serverPath, VFS: ctx.vfs})
+	}
+	return append(hosts, cmd.ConnectedHost{ServerPath: m.handler.GetCurrentServerPath(), VFS: m.vfs})
+}
+
+// flushFilesystems persists the home filesystem and every connected server filesystem
+func (m *ShellModel) flushFilesystems() {
+	seen := make(map[*filesystem.VFS]bool)
+	flush := func(vfs *filesystem.VFS) {
+		if vfs == nil || seen[vfs] {
+			return
+		}
+		seen[vfs] = true
+		_ = vfs.Flush()
+	}
+	for _, ctx := range m.shellStack {
+		flush(ctx.vfs)
+	}
+	flush(m.vfs)
+}
+
+// clearNotice removes a stale server notice once the user runs another command.
+// Shutdown notices stay until the session is closed.
+func (m *ShellModel) clearNotice() {
+	if !Draining() {
+		m.notice = ""
+	}
+}
+
 // refreshGradientFrames rebuilds the gradient frames for the current viewport.
 func (m *ShellModel) refreshGradientFrames() {
 	if !m.gradientAnimating {
@@ -0,0 +1,225 @@
+package terminal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ShutdownMsg notifies a session that the server is shutting down.
+// The receiving model must call Ack once it has flushed any state it owns.
+type ShutdownMsg struct {
+	Reason string
+	ack    func()
+}
+
+// Ack reports that the receiving model has finished its shutdown work.
+func (m ShutdownMsg) Ack() {
+	if m.ack != nil {
+		m.ack()
+	}
+}
+
+// shutdownHandler is a model with shutdown work of its own. It must Ack the message once done.
+type shutdownHandler interface {
+	handleShutdown(msg ShutdownMsg) (tea.Model, tea.Cmd)
+}
+
+// FilterShutdown makes sure a ShutdownMsg is answered whichever model a session is running:
+// models with shutdown work of their own get it to handle, any other model has it acked
+// straight away so Shutdown doesn't wait on a session with nothing to flush.
+// SSH programs use it as their message filter; the web bridge calls it before every Update.
+func FilterShutdown(model tea.Model, msg tea.Msg) tea.Msg {
+	if shutdown, ok := msg.(ShutdownMsg); ok {
+		if _, handles := model.(shutdownHandler); !handles {
+			shutdown.Ack()
+		}
+	}
+	return msg
+}
+
+// liveSession is a connected client registered for shutdown broadcasts.
+type liveSession struct {
+	send  func(tea.Msg)
+	close func(reason string)
+}
+
+var (
+	sessionsMu     sync.Mutex
+	sessions       = make(map[*liveSession]struct{})
+	draining       bool
+	shutdownReason string
+)
+
+// RegisterSession adds a connected client to the shutdown broadcast list.
+// send delivers a message to the session's Bubble Tea model; close disconnects the client
+// with the shutdown reason. The returned function removes the session and must be called
+// when the client disconnects.
+func RegisterSession(send func(tea.Msg), close func(reason string)) func() {
+	s := &liveSession{send: send, close: close}
+
+	sessionsMu.Lock()
+	sessions[s] = struct{}{}
+	isDraining, reason := draining, shutdownReason
+	sessionsMu.Unlock()
+
+	// Clients that connect while draining are told straight away
+	if isDraining {
+		go send(ShutdownMsg{Reason: reason})
+	}
+
+	return func() {
+		sessionsMu.Lock()
+		delete(sessions, s)
+		sessionsMu.Unlock()
+	}
+}
+
+// Draining reports whether a shutdown is in progress.
+func Draining() bool {
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+	return draining
+}
+
+// opState is the lifecycle of a tracked progress operation.
+type opState int
+
+const (
+	opWaiting   opState = iota // Progress bar is animating, operation not yet executed
+	opRunning                  // Operation is executing
+	opPersisted                // Operation was saved for resumption and must not execute
+)
+
+// inflightOp is a progress operation that shutdown must either wait for or persist.
+type inflightOp struct {
+	state   opState
+	persist func() error
+}
+
+var (
+	opsMu sync.Mutex
+	ops   = make(map[*inflightOp]struct{})
+)
+
+// trackOperation registers a progress operation that has started.
+// persist is called if the server shuts down before the operation begins executing.
+func trackOperation(persist func() error) *inflightOp {
+	op := &inflightOp{persist: persist}
+	opsMu.Lock()
+	ops[op] = struct{}{}
+	opsMu.Unlock()
+	return op
+}
+
+// begin marks the operation as executing.
+// Returns false if the operation was already persisted by shutdown and must not run.
+func (op *inflightOp) begin() bool {
+	opsMu.Lock()
+	defer opsMu.Unlock()
+	if op.state == opPersisted {
+		return false
+	}
+	op.state = opRunning
+	return true
+}
+
+// done removes the operation from tracking.
+func (op *inflightOp) done() {
+	opsMu.Lock()
+	delete(ops, op)
+	opsMu.Unlock()
+}
+
+// pendingOperations returns the number of tracked operations that have not finished.
+func pendingOperations() int {
+	opsMu.Lock()
+	defer opsMu.Unlock()
+	return len(ops)
+}
+
+// persistWaitingOperations saves every operation that has not started executing yet.
+// Returns the number of operations persisted.
+func persistWaitingOperations() (int, error) {
+	opsMu.Lock()
+	defer opsMu.Unlock()
+
+	var errs []error
+	persisted := 0
+	for op := range ops {
+		if op.state != opWaiting {
+			continue
+		}
+		op.state = opPersisted
+		delete(ops, op)
+		if err := op.persist(); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		persisted++
+	}
+	return persisted, errors.Join(errs...)
+}
+
+// Shutdown drains every registered session.
+// It broadcasts reason to all sessions, waits for them to flush their filesystems,
+// waits for in-flight progress operations to finish until ctx expires, persists the rest
+// for resumption on next login, and finally disconnects all clients.
+// Returns the number of operations persisted.
+func Shutdown(ctx context.Context, reason string) (int, error) {
+	sessionsMu.Lock()
+	draining = true
+	shutdownReason = reason
+	snapshot := make([]*liveSession, 0, len(sessions))
+	for s := range sessions {
+		snapshot = append(snapshot, s)
+	}
+	sessionsMu.Unlock()
+
+	var errs []error
+
+	// Broadcast the notice and wait for every session to flush
+	var wg sync.WaitGroup
+	for _, s := range snapshot {
+		wg.Add(1)
+		var once sync.Once
+		go s.send(ShutdownMsg{Reason: reason, ack: func() { once.Do(wg.Done) }})
+	}
+	flushed := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(flushed)
+	}()
+	select {
+	case <-flushed:
+	case <-ctx.Done():
+		errs = append(errs, fmt.Errorf("timed out waiting for sessions to flush: %w", ctx.Err()))
+	}
+
+	// Let in-flight operations finish while there is time left
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+wait:
+	for pendingOperations() > 0 {
+		select {
+		case <-ctx.Done():
+			break wait
+		case <-ticker.C:
+		}
+	}
+
+	persisted, err := persistWaitingOperations()
+	if err != nil {
+		errs = append(errs, fmt.Errorf("failed to persist interrupted operations: %w", err))
+	}
+
+	for _, s := range snapshot {
+		s.close(reason)
+	}
+
+	return persisted, errors.Join(errs...)
+}
@@ -5,8 +5,10 @@ package ssh
 import (
 	"context"
 	"fmt"
+	"net"
 	"terminal-sh/config"
 	"terminal-sh/database"
+	"terminal-sh/metrics"
 	"terminal-sh/services"
 	"terminal-sh/terminal"
 	"sync"
@@ -17,6 +19,7 @@ import (
 	"github.com/charmbracelet/wish"
 	wishbubbletea "github.com/charmbracelet/wish/bubbletea"
 	"github.com/charmbracelet/wish/logging"
+	"github.com/muesli/termenv"
 )
 
 var (
@@ -37,6 +40,9 @@ var (
 // Returns an error if the server fails to start.
 func StartServer(cfg *config.Config, db *database.Database, chatService *services.ChatService) error {
 	userService := services.NewUserService(db, cfg.JWTSecret)
+	userService.SetPreviousJWTSecrets(cfg.JWTPreviousSecrets)
+	userService.SetSessionRevokedCallback(terminal.SignOutDevices)
+	chatService.SetAnnouncer(terminal.Announce)
 
 	// Use default host key path if not provided
 	hostKeyPath := cfg.HostKeyPath
@@ -53,8 +59,11 @@ func StartServer(cfg *config.Config, db *database.Database, chatService *service
 		wish.WithMiddleware(
 			// Logging middleware
 			logging.Middleware(),
+			// Shutdown notice middleware - runs after the Bubble Tea program exits
+			shutdownNoticeMiddleware(),
 			// Bubble Tea middleware - shows login form to everyone
-			wishbubbletea.Middleware(func(sess ssh.Session) (tea.Model, []tea.ProgramOption) {
+			// Uses a program handler so the session can receive shutdown broadcasts
+			wishbubbletea.MiddlewareWithProgramHandler(func(sess ssh.Session) *tea.Program {
 				// Extract username from SSH session (if provided)
 				// SSH protocol requires a username, but we ignore it for auth
 				// We'll use it as a hint/prefill in the login form
@@ -68,15 +77,31 @@ func StartServer(cfg *config.Config, db *database.Database, chatService *service
 				// Create login model with prefilled username (no password from SSH)
 				// Everyone sees the login form - no SSH auth required
 				model := terminal.NewLoginModel(db, userService, chatService, username, "")
+				if host, _, err := net.SplitHostPort(sess.RemoteAddr().String()); err == nil {
+					model.SetSourceIP(host)
+				}
 				
 				// After login, transition to shell
 				// Note: We don't use tea.WithAltScreen() because we want scrollback history in shell
 				// Enable mouse support for scroll wheel
-				return model, []tea.ProgramOption{
+				opts := append(wishbubbletea.MakeOptions(sess),
 					tea.WithAltScreen(),
 					tea.WithMouseAllMotion(),
-				}
-			}),
+					tea.WithFilter(terminal.FilterShutdown),
+				)
+				program := tea.NewProgram(model, opts...)
+
+				// Register for shutdown broadcasts until the connection closes
+				unregister := terminal.RegisterSession(program.Send, func(string) { program.Quit() })
+				go func() {
+					<-sess.Context().Done()
+					unregister()
+				}()
+
+				return program
+			}, termenv.Ascii),
+			// Session metrics middleware - outermost so it spans the whole session
+			sessionMetricsMiddleware(),
 		),
 		// No authentication callbacks = no SSH auth required
 		// All connections are allowed and go directly to login form
@@ -100,6 +125,31 @@ func StartServer(cfg *config.Config, db *database.Database, chatService *service
 	return s.ListenAndServe()
 }
 
+// sessionMetricsMiddleware tracks the number of connected SSH sessions.
+func sessionMetricsMiddleware() wish.Middleware {
+	return func(next ssh.Handler) ssh.Handler {
+		return func(sess ssh.Session) {
+			gauge := metrics.ActiveSessions.WithLabelValues(metrics.TransportSSH)
+			gauge.Inc()
+			defer gauge.Dec()
+			next(sess)
+		}
+	}
+}
+
+// shutdownNoticeMiddleware prints the shutdown notice once the Bubble Tea program has exited,
+// since the alternate screen is cleared when the program quits.
+func shutdownNoticeMiddleware() wish.Middleware {
+	return func(next ssh.Handler) ssh.Handler {
+		return func(sess ssh.Session) {
+			if terminal.Draining() {
+				wish.Println(sess, "Server is shutting down. Your progress has been saved - please reconnect shortly.")
+			}
+			next(sess)
+		}
+	}
+}
+
 // ShutdownServer gracefully shuts down the SSH server
 func ShutdownServer(ctx context.Context) error {
 	serverMu.Lock()
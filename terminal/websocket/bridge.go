@@ -2,6 +2,7 @@ package websocket
 
 import (
 	"fmt"
+	"net"
 	"strings"
 	"sync"
 	"terminal-sh/database"
@@ -10,6 +11,7 @@ import (
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 )
 
@@ -43,12 +45,23 @@ const (
 // BubbleTeaBridge wraps a Bubble Tea model for WebSocket communication
 type BubbleTeaBridge struct {
 	model       tea.Model
-	conn        *websocket.Conn
+	conn        *websocket.Conn // Current browser connection, nil while detached; guarded by writeMu
+	writeMu     sync.Mutex // Serializes writes; shutdown writes from outside processMessages
+	resumeToken string     // Lets a reconnecting browser reattach to this bridge
+	seq         uint64          // Sequence number of the last output message, guarded by writeMu
+	replay      []OutputMessage // Output not yet acknowledged by the browser, guarded by writeMu
+	replayBytes int
+	graceTimer  *time.Timer // Closes the bridge if the browser doesn't come back, guarded by resumableMu
 	db          *database.Database
 	userService *services.UserService
 	done        chan struct{}
 	msgChan     chan tea.Msg
 	closeOnce   sync.Once
+	unregister  func()
+	sourceIP    string
+	deviceMu         sync.Mutex
+	unregisterDevice func()    // Removes the live device registration, nil when not logged in
+	deviceSession    uuid.UUID // Device session logged in on this bridge, uuid.Nil when not logged in
 	width       int
 	height      int
 	renderMode  RenderMode
@@ -62,7 +75,7 @@ type BubbleTeaBridge struct {
 }
 
 // NewBubbleTeaBridge creates a new bridge between Bubble Tea and WebSocket
-func NewBubbleTeaBridge(conn *websocket.Conn, db *database.Database, userService *services.UserService, chatService *services.ChatService, width, height int) (*BubbleTeaBridge, error) {
+func NewBubbleTeaBridge(conn *websocket.Conn, db *database.Database, userService *services.UserService, chatService *services.ChatService, device string, width, height int) (*BubbleTeaBridge, error) {
 	// Ensure reasonable defaults
 	if width < 20 {
 		width = 80
@@ -86,6 +99,13 @@ func NewBubbleTeaBridge(conn *websocket.Conn, db *database.Database, userService
 		renderMode:  RenderModeFullScreen,
 		lastView:    "",
 	}
+
+	// Throttle logins by client address and keep browsers logged in across reconnects
+	if host, _, err := net.SplitHostPort(conn.RemoteAddr().String()); err == nil {
+		bridge.sourceIP = host
+		loginModel.SetSourceIP(host)
+	}
+	loginModel.SetSessionHooks(bridge.sessionHooks(device))
 	
 	// Initialize model
 	initCmd := bridge.model.Init()
@@ -103,6 +123,14 @@ func NewBubbleTeaBridge(conn *websocket.Conn, db *database.Database, userService
 		Height: height,
 	}
 
+	// Register for shutdown broadcasts
+	bridge.unregister = terminal.RegisterSession(bridge.send, bridge.shutdown)
+
+	// Allow the browser to reattach if its connection drops
+	if err := bridge.registerResumable(); err != nil {
+		return nil, err
+	}
+
 	// Start goroutine to process messages and send output
 	go bridge.processMessages()
 
@@ -298,25 +326,16 @@ func prepareShellOutputWithClear(view string, height int) string {
 func (b *BubbleTeaBridge) processMessages() {
 	defer b.closeDone()
 
+	// Tell the browser how to resume this session before any output
+	b.writeJSON(SessionMessage{Type: MessageTypeSession, Token: b.resumeToken})
+
 	// Start in alternate screen for login (no scrollback needed)
-	initMsg := OutputMessage{
-		Type: MessageTypeOutput,
-		Data: enterAltScreen + hideCursor,
-	}
-	if err := b.conn.WriteJSON(initMsg); err != nil {
-		return
-	}
+	b.emit(enterAltScreen + hideCursor)
 
 	// Send initial view
 	currentView := b.model.View()
 	output := prepareFullScreenOutput(currentView)
-	msg := OutputMessage{
-		Type: MessageTypeOutput,
-		Data: output,
-	}
-	if err := b.conn.WriteJSON(msg); err != nil {
-		return
-	}
+	b.emit(output)
 	b.lastView = currentView
 	wasLogin := true
 	wasChat := false
@@ -325,11 +344,7 @@ func (b *BubbleTeaBridge) processMessages() {
 		select {
 		case <-b.done:
 			// Clean up on close
-			exitMsg := OutputMessage{
-				Type: MessageTypeOutput,
-				Data: exitAltScreen + showCursor,
-			}
-			b.conn.WriteJSON(exitMsg)
+			b.emit(exitAltScreen + showCursor)
 			return
 			
 		case teaMsg := <-b.msgChan:
@@ -339,9 +354,20 @@ func (b *BubbleTeaBridge) processMessages() {
 				b.height = sizeMsg.Height
 			}
 			
+			// Client resumed after missing output - resend the whole screen
+			if _, ok := teaMsg.(redrawMsg); ok {
+				if b.isLoginModel() {
+					b.emit(enterAltScreen + hideCursor)
+				} else {
+					b.emit(exitAltScreen + hideCursor)
+				}
+				b.lastView = ""
+				b.lastContent = ""
+			}
+
 			// Update model
 			var cmd tea.Cmd
-			b.model, cmd = b.model.Update(teaMsg)
+			b.model, cmd = b.model.Update(terminal.FilterShutdown(b.model, teaMsg))
 			
 			// Execute any returned command
 			if cmd != nil {
@@ -354,13 +380,7 @@ func (b *BubbleTeaBridge) processMessages() {
 
 			if wasLogin && !isLogin && !isChat {
 				// Transition: login -> shell: exit alternate screen to enable scrollback
-				transitionMsg := OutputMessage{
-					Type: MessageTypeOutput,
-					Data: exitAltScreen + clearScreen + cursorHome,
-				}
-				if err := b.conn.WriteJSON(transitionMsg); err != nil {
-					return
-				}
+				b.emit(exitAltScreen + clearScreen + cursorHome)
 				b.renderMode = RenderModeIncremental
 				b.lastView = ""    // Force redraw
 				b.lastContent = "" // Reset content tracking
@@ -372,13 +392,7 @@ func (b *BubbleTeaBridge) processMessages() {
 				// Stop gradient ticker if running
 				b.gradientTickerRunning = false
 				
-				transitionMsg := OutputMessage{
-					Type: MessageTypeOutput,
-					Data: enterAltScreen + clearScreen + cursorHome,
-				}
-				if err := b.conn.WriteJSON(transitionMsg); err != nil {
-					return
-				}
+				b.emit(enterAltScreen + clearScreen + cursorHome)
 				b.renderMode = RenderModeFullScreen
 				b.lastView = "" // Force redraw
 			}
@@ -399,13 +413,7 @@ func (b *BubbleTeaBridge) processMessages() {
 
 			// Transition: chat -> shell: reset to incremental mode
 			if wasChat && !isChat && !isLogin {
-				transitionMsg := OutputMessage{
-					Type: MessageTypeOutput,
-					Data: clearScreen + cursorHome,
-				}
-				if err := b.conn.WriteJSON(transitionMsg); err != nil {
-					return
-				}
+				b.emit(clearScreen + cursorHome)
 				b.renderMode = RenderModeIncremental
 				b.lastView = ""    // Force redraw
 				b.lastContent = "" // Reset content tracking
@@ -449,13 +457,7 @@ func (b *BubbleTeaBridge) processMessages() {
 				} else {
 					output = prepareFullScreenOutput(currentView)
 				}
-				msg := OutputMessage{
-					Type: MessageTypeOutput,
-					Data: output,
-				}
-				if err := b.conn.WriteJSON(msg); err != nil {
-					return
-				}
+				b.emit(output)
 				b.lastView = currentView
 		} else {
 			// Shell mode - always use clearAll to prevent scrollback accumulation
@@ -493,13 +495,7 @@ func (b *BubbleTeaBridge) processMessages() {
 			
 			b.lastView = currentView
 			
-			msg := OutputMessage{
-				Type: MessageTypeOutput,
-				Data: data.String(),
-			}
-			if err := b.conn.WriteJSON(msg); err != nil {
-				return
-			}
+			b.emit(data.String())
 		}
 		}
 	}
@@ -584,9 +580,55 @@ func (b *BubbleTeaBridge) closeDone() {
 
 // Close closes the bridge
 func (b *BubbleTeaBridge) Close() {
+	if b.unregister != nil {
+		b.unregister()
+	}
+	b.forgetDevice()
+	b.forgetResumable()
 	b.closeDone()
 }
 
+// writeJSON writes a message to the client, serialized with other writers.
+// Messages are dropped while no connection is attached.
+func (b *BubbleTeaBridge) writeJSON(v interface{}) error {
+	b.writeMu.Lock()
+	defer b.writeMu.Unlock()
+	if b.conn == nil {
+		return errDetached
+	}
+	return b.conn.WriteJSON(v)
+}
+
+// send delivers a message to the model, giving up if the bridge closes
+func (b *BubbleTeaBridge) send(msg tea.Msg) {
+	select {
+	case b.msgChan <- msg:
+	case <-b.done:
+	}
+}
+
+// shutdown tells the client the server is going away and closes the connection.
+// The browser client uses the shutdown message to reconnect once the server is back.
+func (b *BubbleTeaBridge) shutdown(reason string) {
+	b.writeJSON(ShutdownMessage{
+		Type:   MessageTypeShutdown,
+		Reason: reason,
+	})
+	b.writeMu.Lock()
+	conn := b.conn
+	if conn != nil {
+		conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"),
+			time.Now().Add(time.Second))
+	}
+	b.writeMu.Unlock()
+	if conn != nil {
+		conn.Close()
+	}
+	// Nothing will resume a bridge after shutdown, including one already waiting for its browser
+	b.Close()
+}
+
 // executeCmd executes a tea.Cmd and handles BatchMsg properly.
 // This is essential for commands that use tea.Batch to run multiple operations.
 func (b *BubbleTeaBridge) executeCmd(c tea.Cmd) {
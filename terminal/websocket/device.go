@@ -0,0 +1,106 @@
+package websocket
+
+import (
+	"log"
+	"strings"
+
+	"terminal-sh/services"
+	"terminal-sh/terminal"
+
+	"github.com/google/uuid"
+)
+
+// sessionHooks returns the device session hooks for this bridge's login model.
+func (b *BubbleTeaBridge) sessionHooks(device string) *terminal.SessionHooks {
+	return &terminal.SessionHooks{
+		Device:  device,
+		OnLogin: b.deviceLoggedIn,
+		OnLogout: func() {
+			b.forgetDevice()
+			b.writeJSON(LogoutMessage{Type: MessageTypeLogout})
+		},
+	}
+}
+
+// deviceLoggedIn hands tokens to the browser and registers the bridge for live sign-out.
+// A revoked session returns the browser to the login screen, which clears its tokens.
+func (b *BubbleTeaBridge) deviceLoggedIn(tokens *services.SessionTokens) {
+	b.forgetDevice()
+	unregister := terminal.RegisterDevice(tokens.SessionID, func() {
+		b.send(terminal.LogoutMsg{})
+	})
+	b.deviceMu.Lock()
+	b.unregisterDevice = unregister
+	b.deviceSession = tokens.SessionID
+	b.deviceMu.Unlock()
+
+	b.writeJSON(AuthMessage{
+		Type:         MessageTypeAuth,
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresAt:    tokens.ExpiresAt.Unix(),
+	})
+}
+
+// forgetDevice removes the bridge from the live device registry.
+func (b *BubbleTeaBridge) forgetDevice() {
+	b.deviceMu.Lock()
+	unregister := b.unregisterDevice
+	b.unregisterDevice = nil
+	b.deviceSession = uuid.Nil
+	b.deviceMu.Unlock()
+	if unregister != nil {
+		unregister()
+	}
+}
+
+// HandleAuth resumes a session from tokens stored by the browser.
+// Ignored once the user is past the login screen. Invalid tokens tell the browser to forget them.
+func (b *BubbleTeaBridge) HandleAuth(msg AuthMessage) error {
+	if !b.isLoginModel() {
+		return nil
+	}
+
+	user, tokens, err := b.userService.ResumeSession(msg.RefreshToken, b.sourceIP)
+	if err != nil {
+		log.Printf("Session resume failed: %v", err)
+		return b.writeJSON(LogoutMessage{Type: MessageTypeLogout})
+	}
+
+	b.send(terminal.LoginSuccessMsg{User: user, Tokens: tokens})
+	return nil
+}
+
+// describeUserAgent turns a User-Agent header into a short device label like "Firefox on Linux".
+func describeUserAgent(userAgent string) string {
+	browser := "Browser"
+	switch {
+	case strings.Contains(userAgent, "Edg/"):
+		browser = "Edge"
+	case strings.Contains(userAgent, "Firefox/"):
+		browser = "Firefox"
+	case strings.Contains(userAgent, "Chrome/"):
+		browser = "Chrome"
+	case strings.Contains(userAgent, "Safari/"):
+		browser = "Safari"
+	}
+
+	platform := ""
+	switch {
+	case strings.Contains(userAgent, "Android"):
+		platform = "Android"
+	case strings.Contains(userAgent, "iPhone"), strings.Contains(userAgent, "iPad"):
+		platform = "iOS"
+	case strings.Contains(userAgent, "Windows"):
+		platform = "Windows"
+	case strings.Contains(userAgent, "Mac OS X"):
+		platform = "macOS"
+	case strings.Contains(userAgent, "Linux"):
+		platform = "Linux"
+	}
+
+	if platform == "" {
+		return browser
+	}
+	return browser + " on " + platform
+}
@@ -0,0 +1,73 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"terminal-sh/database"
+	"terminal-sh/terminal"
+	"time"
+)
+
+// healthCheckTimeout bounds how long a health probe waits on the database.
+const healthCheckTimeout = 2 * time.Second
+
+// healthResponse is the JSON body returned by the health and readiness endpoints.
+type healthResponse struct {
+	Status   string `json:"status"`
+	Database string `json:"database,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// handleHealthz reports whether the process is alive and can reach the database.
+func handleHealthz(db *database.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeHealth(w, checkDatabase(r.Context(), db))
+	}
+}
+
+// handleReadyz reports whether the server can accept new sessions.
+// It checks that the database answers queries, not just that the connection is open,
+// and fails while the server is draining sessions for shutdown.
+func handleReadyz(db *database.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if terminal.Draining() {
+			writeHealthResponse(w, http.StatusServiceUnavailable, healthResponse{Status: "draining"})
+			return
+		}
+		err := checkDatabase(r.Context(), db)
+		if err == nil {
+			ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+			defer cancel()
+			var one int
+			err = db.WithContext(ctx).Raw("SELECT 1").Scan(&one).Error
+		}
+		writeHealth(w, err)
+	}
+}
+
+// checkDatabase pings the database with a bounded timeout.
+func checkDatabase(ctx context.Context, db *database.Database) error {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+	return db.Ping(ctx)
+}
+
+// writeHealth writes a health response with 200 on success or 503 on failure.
+func writeHealth(w http.ResponseWriter, err error) {
+	resp := healthResponse{Status: "ok", Database: "ok"}
+	status := http.StatusOK
+	if err != nil {
+		resp = healthResponse{Status: "unavailable", Database: "error", Error: err.Error()}
+		status = http.StatusServiceUnavailable
+	}
+	writeHealthResponse(w, status, resp)
+}
+
+// writeHealthResponse writes resp as JSON with the given status code.
+func writeHealthResponse(w http.ResponseWriter, status int, resp healthResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
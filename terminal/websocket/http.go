@@ -2,14 +2,19 @@
 package websocket
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sync"
 	"terminal-sh/config"
 	"terminal-sh/database"
+	"terminal-sh/metrics"
 	"terminal-sh/services"
+	"terminal-sh/terminal"
 
 	"github.com/charmbracelet/lipgloss"
 )
@@ -22,11 +27,19 @@ var (
 			Foreground(lipgloss.Color("39"))
 )
 
+var (
+	httpServer *http.Server
+	serverMu   sync.Mutex
+)
+
 // StartHTTPServer starts the HTTP server for serving static files and WebSocket connections.
 // Serves the web interface from the web/ directory and handles WebSocket upgrades at /ws.
-// Returns an error if the server fails to start.
+// Returns an error if the server fails to start; returns nil after ShutdownHTTPServer.
 func StartHTTPServer(cfg *config.Config, db *database.Database, chatService *services.ChatService) error {
 	userService := services.NewUserService(db, cfg.JWTSecret)
+	userService.SetPreviousJWTSecrets(cfg.JWTPreviousSecrets)
+	userService.SetSessionRevokedCallback(terminal.SignOutDevices)
+	chatService.SetAnnouncer(terminal.Announce)
 
 	// Determine web directory path (relative to working directory)
 	// Try multiple possible locations
@@ -42,22 +55,68 @@ func StartHTTPServer(cfg *config.Config, db *database.Database, chatService *ser
 		}
 	}
 
+	mux := http.NewServeMux()
+
 	// Serve static files
 	fileServer := http.FileServer(http.Dir(webDir))
-	http.Handle("/", fileServer)
+	mux.Handle("/", fileServer)
 
 	// WebSocket endpoint
-	http.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
-		if err := HandleWebSocket(w, r, db, userService, chatService); err != nil {
+	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+		if err := HandleWebSocket(w, r, db, userService, chatService); err != nil && !terminal.Draining() {
 			log.Printf("WebSocket error: %v", err)
 		}
 	})
 
+	// Observability endpoints for load balancers and Prometheus
+	serverService := services.NewServerService(db)
+	toolService := services.NewToolService(db, serverService)
+	miningService := services.NewMiningService(db, toolService, serverService)
+	serverGenerator := services.NewServerGenerator(db, serverService)
+	metrics.RegisterGameGauges(
+		func() float64 { return float64(miningService.CountActiveMiners()) },
+		func() float64 { return float64(serverGenerator.GetProceduralServerCount()) },
+	)
+	mux.Handle("/metrics", metrics.Handler())
+	mux.HandleFunc("/healthz", handleHealthz(db))
+	mux.HandleFunc("/readyz", handleReadyz(db))
+
 	addr := cfg.WebHost + ":" + fmt.Sprintf("%d", cfg.WebPort)
+	srv := &http.Server{
+		Addr:    addr,
+		Handler: mux,
+	}
+
+	// Store server reference for graceful shutdown
+	serverMu.Lock()
+	httpServer = srv
+	serverMu.Unlock()
+
 	fmt.Println(infoLogStyle.Render(fmt.Sprintf("HTTP/WebSocket server listening on %s", addr)))
 	fmt.Println(successLogStyle.Render("✓") + " " + infoLogStyle.Render(fmt.Sprintf("WebSocket endpoint: ws://%s/ws", addr)))
 	fmt.Println(successLogStyle.Render("✓") + " Static files served from /")
-	
-	return http.ListenAndServe(addr, nil)
+	fmt.Println(successLogStyle.Render("✓") + " Metrics at /metrics, health checks at /healthz and /readyz")
+
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// ShutdownHTTPServer gracefully shuts down the HTTP server.
+// It stops accepting connections and waits for in-flight HTTP requests until ctx expires.
+// WebSocket connections are hijacked and not tracked by http.Server; drain them with
+// terminal.Shutdown first.
+func ShutdownHTTPServer(ctx context.Context) error {
+	serverMu.Lock()
+	srv := httpServer
+	serverMu.Unlock()
+
+	if srv == nil {
+		return nil // Server not started
+	}
+
+	fmt.Println(infoLogStyle.Render("Shutting down HTTP server..."))
+	return srv.Shutdown(ctx)
 }
 
@@ -2,12 +2,18 @@ package websocket
 
 // Message types for WebSocket communication between browser and server.
 const (
-	MessageTypeInput   = "input"
-	MessageTypeResize  = "resize"
-	MessageTypeOutput  = "output"
-	MessageTypeClose   = "close"
-	MessageTypeMouse   = "mouse"
-	MessageTypePaste   = "paste"
+	MessageTypeInput    = "input"
+	MessageTypeResize   = "resize"
+	MessageTypeOutput   = "output"
+	MessageTypeClose    = "close"
+	MessageTypeMouse    = "mouse"
+	MessageTypePaste    = "paste"
+	MessageTypeShutdown = "shutdown"
+	MessageTypeAuth     = "auth"
+	MessageTypeLogout   = "logout"
+	MessageTypeSession  = "session"
+	MessageTypeResume   = "resume"
+	MessageTypeAck      = "ack"
 )
 
 // InputMessage represents keyboard input from the browser client.
@@ -26,9 +32,11 @@ type ResizeMessage struct {
 }
 
 // OutputMessage represents ANSI-encoded terminal output to send to the browser client.
+// Seq increases by one per message so a reconnecting client can ask for what it missed.
 type OutputMessage struct {
 	Type string `json:"type"`
 	Data string `json:"data"` // ANSI-encoded string from Bubble Tea View()
+	Seq  uint64 `json:"seq"`
 }
 
 // CloseMessage represents a connection close request from the browser client.
@@ -49,3 +57,49 @@ type PasteMessage struct {
 	Type string `json:"type"`
 	Text string `json:"text"` // The text to paste
 }
+
+// ShutdownMessage tells the browser client that the server is shutting down.
+type ShutdownMessage struct {
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+}
+
+// AuthMessage carries session tokens.
+// The browser sends its stored tokens to resume a session; the server replies with fresh tokens
+// after every login or resume. RefreshToken is omitted when the client's current one is still valid.
+type AuthMessage struct {
+	Type         string `json:"type"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresAt    int64  `json:"expires_at,omitempty"` // Access token expiry (Unix seconds)
+}
+
+// LogoutMessage tells the browser to forget its stored tokens.
+type LogoutMessage struct {
+	Type string `json:"type"`
+}
+
+// SessionMessage is the first message on every connection. Token identifies the server-side
+// session so the client can resume it after a dropped connection; Resumed is true when this
+// connection reattached to an existing session.
+type SessionMessage struct {
+	Type    string `json:"type"`
+	Token   string `json:"token"`
+	Resumed bool   `json:"resumed"`
+}
+
+// ResumeMessage is sent by a reconnecting client as its first message to reattach to a session.
+// The server replays output after LastSeq. A logged-in session is only handed to a client that
+// also proves it's the session's device with its refresh token.
+type ResumeMessage struct {
+	Type         string `json:"type"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	LastSeq      uint64 `json:"last_seq"`
+}
+
+// AckMessage acknowledges output up to Seq so the server can drop it from the replay buffer.
+type AckMessage struct {
+	Type string `json:"type"`
+	Seq  uint64 `json:"seq"`
+}
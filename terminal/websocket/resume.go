@@ -0,0 +1,209 @@
+package websocket
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// resumeGracePeriod is how long a bridge waits for its browser to reconnect after the
+	// connection drops before the session is closed.
+	resumeGracePeriod = 2 * time.Minute
+	// maxReplayBytes bounds the unacknowledged output kept per bridge. If a client falls
+	// further behind, it gets a full redraw instead of a replay.
+	maxReplayBytes = 1 << 20
+)
+
+// errDetached is returned when writing to a bridge that has no browser connection.
+var errDetached = errors.New("no client attached")
+
+// redrawMsg asks processMessages to resend the whole screen.
+type redrawMsg struct{}
+
+// Bridges that a reconnecting browser can reattach to, keyed by resume token
+var (
+	resumableMu sync.Mutex
+	resumable   = make(map[string]*BubbleTeaBridge)
+)
+
+// registerResumable gives the bridge a resume token and makes it resumable.
+func (b *BubbleTeaBridge) registerResumable() error {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return err
+	}
+	b.resumeToken = hex.EncodeToString(raw)
+
+	resumableMu.Lock()
+	resumable[b.resumeToken] = b
+	resumableMu.Unlock()
+	return nil
+}
+
+// forgetResumable removes the bridge from the resume registry.
+func (b *BubbleTeaBridge) forgetResumable() {
+	resumableMu.Lock()
+	defer resumableMu.Unlock()
+	if b.graceTimer != nil {
+		b.graceTimer.Stop()
+		b.graceTimer = nil
+	}
+	if resumable[b.resumeToken] == b {
+		delete(resumable, b.resumeToken)
+	}
+}
+
+// resumeBridge reattaches conn to the bridge for msg.Token and replays output after msg.LastSeq.
+// Returns nil if there is no such session or the client may not take it over.
+func resumeBridge(msg ResumeMessage, conn *websocket.Conn) *BubbleTeaBridge {
+	resumableMu.Lock()
+	b, ok := resumable[msg.Token]
+	resumableMu.Unlock()
+	if !ok {
+		return nil
+	}
+	owner := b.authorizeResume(msg.RefreshToken)
+	if !b.attach(conn, msg.LastSeq, owner) {
+		return nil
+	}
+
+	resumableMu.Lock()
+	if b.graceTimer != nil {
+		b.graceTimer.Stop()
+		b.graceTimer = nil
+	}
+	resumableMu.Unlock()
+
+	// The grace period may have run out while we were attaching
+	select {
+	case <-b.done:
+		return nil
+	default:
+		return b
+	}
+}
+
+// authorizeResume reports whether the client presenting refreshToken is the device session
+// logged in on the bridge, which may take it over even from a connection that is still attached.
+// Returns false for a bridge nobody is logged in on.
+func (b *BubbleTeaBridge) authorizeResume(refreshToken string) bool {
+	b.deviceMu.Lock()
+	owner := b.deviceSession
+	b.deviceMu.Unlock()
+	if owner == uuid.Nil || b.userService == nil {
+		return false
+	}
+	session, err := b.userService.AuthenticateRefreshToken(refreshToken)
+	return err == nil && session.ID == owner
+}
+
+// attach switches the bridge to a new connection, replays missed output and redraws the screen.
+// A bridge somebody is logged in on only goes to its owner; only the owner can take over a
+// connection that is still attached (the old one hasn't noticed it died), which is closed.
+// Returns false if conn wasn't attached.
+func (b *BubbleTeaBridge) attach(conn *websocket.Conn, lastSeq uint64, owner bool) bool {
+	select {
+	case <-b.done:
+		return false
+	default:
+	}
+
+	b.deviceMu.Lock()
+	loggedIn := b.deviceSession != uuid.Nil
+	b.deviceMu.Unlock()
+
+	b.writeMu.Lock()
+	old := b.conn
+	if !owner && (loggedIn || old != nil) {
+		b.writeMu.Unlock()
+		return false
+	}
+	b.conn = conn
+	conn.WriteJSON(SessionMessage{Type: MessageTypeSession, Token: b.resumeToken, Resumed: true})
+
+	// Replay what the client missed unless some of it was already dropped; the redraw covers that
+	complete := lastSeq >= b.seq || (len(b.replay) > 0 && b.replay[0].Seq <= lastSeq+1)
+	if complete {
+		for _, msg := range b.replay {
+			if msg.Seq > lastSeq {
+				conn.WriteJSON(msg)
+			}
+		}
+	}
+	b.writeMu.Unlock()
+
+	if old != nil && old != conn {
+		old.Close()
+	}
+	// Redraw even after a full replay to clear anything the client printed while disconnected
+	go b.send(redrawMsg{})
+	return true
+}
+
+// detach drops conn from the bridge and closes the bridge unless the browser reconnects
+// within the grace period. Does nothing if another connection has already taken over.
+func (b *BubbleTeaBridge) detach(conn *websocket.Conn) {
+	b.writeMu.Lock()
+	if b.conn != conn {
+		b.writeMu.Unlock()
+		return
+	}
+	b.conn = nil
+	b.writeMu.Unlock()
+
+	resumableMu.Lock()
+	defer resumableMu.Unlock()
+	if b.graceTimer != nil {
+		b.graceTimer.Stop()
+	}
+	b.graceTimer = time.AfterFunc(resumeGracePeriod, func() {
+		// Give up unless a connection was attached in the meantime
+		b.writeMu.Lock()
+		attached := b.conn != nil
+		b.writeMu.Unlock()
+		if !attached {
+			b.Close()
+		}
+	})
+}
+
+// emit sends terminal output to the browser, numbering it and keeping it for replay
+// until acknowledged. While no connection is attached the output is only buffered.
+func (b *BubbleTeaBridge) emit(data string) {
+	b.writeMu.Lock()
+	defer b.writeMu.Unlock()
+
+	b.seq++
+	msg := OutputMessage{Type: MessageTypeOutput, Data: data, Seq: b.seq}
+	b.replay = append(b.replay, msg)
+	b.replayBytes += len(data)
+	for b.replayBytes > maxReplayBytes && len(b.replay) > 1 {
+		b.replayBytes -= len(b.replay[0].Data)
+		b.replay = b.replay[1:]
+	}
+
+	if b.conn != nil {
+		// A failed write means the connection is dead; the read loop will detach it
+		b.conn.WriteJSON(msg)
+	}
+}
+
+// HandleAck drops output the browser has confirmed receiving from the replay buffer.
+func (b *BubbleTeaBridge) HandleAck(msg AckMessage) error {
+	b.writeMu.Lock()
+	defer b.writeMu.Unlock()
+
+	drop := 0
+	for drop < len(b.replay) && b.replay[drop].Seq <= msg.Seq {
+		b.replayBytes -= len(b.replay[drop].Data)
+		drop++
+	}
+	b.replay = b.replay[drop:]
+	return nil
+}
@@ -2,10 +2,14 @@ package websocket
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"terminal-sh/database"
+	"terminal-sh/metrics"
 	"terminal-sh/services"
+	"terminal-sh/terminal"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
@@ -29,8 +33,17 @@ type WebSocketSession struct {
 	height    int
 }
 
+// firstMessageTimeout is how long a new connection may take to send its first message.
+// Browsers send a resume or resize message as soon as the socket opens.
+const firstMessageTimeout = 10 * time.Second
+
+// errClientClosed is returned by handleMessages when the client asked to close the session.
+var errClientClosed = errors.New("client closed session")
+
 // HandleWebSocket handles WebSocket upgrade and manages the session lifecycle.
-// Creates a Bubble Tea bridge and processes incoming messages until the connection closes.
+// A client whose first message is a valid resume request reattaches to its existing bridge;
+// otherwise a new Bubble Tea bridge is created. Messages are processed until the connection
+// closes, after which the bridge waits for the client to resume unless it closed deliberately.
 func HandleWebSocket(w http.ResponseWriter, r *http.Request, db *database.Database, userService *services.UserService, chatService *services.ChatService) error {
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
@@ -38,16 +51,35 @@ func HandleWebSocket(w http.ResponseWriter, r *http.Request, db *database.Databa
 	}
 	defer conn.Close()
 
+	gauge := metrics.ActiveSessions.WithLabelValues(metrics.TransportWebSocket)
+	gauge.Inc()
+	defer gauge.Dec()
+
 	// Default terminal size (will be updated on first resize message)
 	width := 80
 	height := 24
 
-	// Create Bubble Tea bridge
-	bridge, err := NewBubbleTeaBridge(conn, db, userService, chatService, width, height)
+	// The first message decides between resuming and starting fresh
+	conn.SetReadDeadline(time.Now().Add(firstMessageTimeout))
+	messageType, first, err := conn.ReadMessage()
 	if err != nil {
 		return err
 	}
-	defer bridge.Close()
+	conn.SetReadDeadline(time.Time{})
+
+	var bridge *BubbleTeaBridge
+	var resume ResumeMessage
+	if messageType == websocket.TextMessage && json.Unmarshal(first, &resume) == nil && resume.Type == MessageTypeResume {
+		bridge = resumeBridge(resume, conn)
+		first = nil // Handled
+	}
+	if bridge == nil {
+		// Create Bubble Tea bridge
+		bridge, err = NewBubbleTeaBridge(conn, db, userService, chatService, describeUserAgent(r.UserAgent()), width, height)
+		if err != nil {
+			return err
+		}
+	}
 
 	session := &WebSocketSession{
 		conn:        conn,
@@ -59,23 +91,36 @@ func HandleWebSocket(w http.ResponseWriter, r *http.Request, db *database.Databa
 	}
 
 	// Handle messages from client
-	return session.handleMessages()
+	err = session.handleMessages(first)
+	if errors.Is(err, errClientClosed) || terminal.Draining() {
+		bridge.Close()
+		return nil
+	}
+	// Connection dropped - keep the session for the client to resume
+	bridge.detach(conn)
+	return err
 }
 
-// handleMessages processes incoming WebSocket messages
-func (s *WebSocketSession) handleMessages() error {
+// handleMessages processes incoming WebSocket messages, starting with pending if not nil
+func (s *WebSocketSession) handleMessages(pending []byte) error {
 	for {
-		// Read message from client
-		messageType, message, err := s.conn.ReadMessage()
-		if err != nil {
-			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("WebSocket error: %v", err)
+		var message []byte
+		if pending != nil {
+			message, pending = pending, nil
+		} else {
+			// Read message from client
+			messageType, data, err := s.conn.ReadMessage()
+			if err != nil {
+				if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+					log.Printf("WebSocket error: %v", err)
+				}
+				return err
 			}
-			return err
-		}
 
-		if messageType != websocket.TextMessage {
-			continue
+			if messageType != websocket.TextMessage {
+				continue
+			}
+			message = data
 		}
 
 		// Parse message
@@ -122,8 +167,20 @@ func (s *WebSocketSession) handleMessages() error {
 					log.Printf("Error handling paste: %v", err)
 				}
 			}
+		case MessageTypeAuth:
+			var authMsg AuthMessage
+			if err := json.Unmarshal(message, &authMsg); err == nil {
+				if err := s.bridge.HandleAuth(authMsg); err != nil {
+					log.Printf("Error handling auth: %v", err)
+				}
+			}
+		case MessageTypeAck:
+			var ackMsg AckMessage
+			if err := json.Unmarshal(message, &ackMsg); err == nil {
+				s.bridge.HandleAck(ackMsg)
+			}
 		case MessageTypeClose:
-			return nil // Client requested close
+			return errClientClosed // Client requested close
 		}
 	}
 }
@@ -51,6 +51,62 @@ fitAddon.fit();
 // WebSocket connection
 let ws = null;
 let pendingResize = null;
+let serverShuttingDown = false;
+
+// Resumable session state: the server numbers output so a dropped connection can
+// reattach to the same session and replay what was missed
+let resumeToken = null;
+let lastSeq = 0;
+let ackedSeq = 0;
+let reconnectDelay = 1000;
+const MAX_RECONNECT_DELAY = 30000;
+
+// Acknowledge received output so the server can drop it from its replay buffer
+setInterval(() => {
+  if (ws && ws.readyState === WebSocket.OPEN && lastSeq > ackedSeq) {
+    ws.send(JSON.stringify({ type: "ack", seq: lastSeq }));
+    ackedSeq = lastSeq;
+  }
+}, 2000);
+
+// Session tokens are kept in localStorage so a reload or reconnect resumes straight into the shell
+const TOKEN_STORAGE_KEY = "terminal.sh.session";
+
+function loadSessionTokens() {
+  try {
+    return JSON.parse(localStorage.getItem(TOKEN_STORAGE_KEY));
+  } catch (e) {
+    return null;
+  }
+}
+
+function saveSessionTokens(message) {
+  const previous = loadSessionTokens() || {};
+  localStorage.setItem(
+    TOKEN_STORAGE_KEY,
+    JSON.stringify({
+      access_token: message.access_token,
+      refresh_token: message.refresh_token || previous.refresh_token,
+    })
+  );
+}
+
+function clearSessionTokens() {
+  localStorage.removeItem(TOKEN_STORAGE_KEY);
+}
+
+// Send stored tokens so the server can resume the session
+function sendStoredTokens() {
+  const tokens = loadSessionTokens();
+  if (!tokens || !tokens.refresh_token) return;
+  ws.send(
+    JSON.stringify({
+      type: "auth",
+      access_token: tokens.access_token || "",
+      refresh_token: tokens.refresh_token,
+    })
+  );
+}
 
 // Send resize to server
 function sendResize() {
@@ -92,10 +148,24 @@ function connectWebSocket() {
   ws = new WebSocket(wsUrl);
 
   ws.onopen = () => {
-    // Clear terminal
-    term.reset();
+    reconnectDelay = 1000;
+    // Reattach to the previous session first; the server replays output after lastSeq.
+    // A logged-in session is only handed back to the device holding its refresh token.
+    if (resumeToken) {
+      const tokens = loadSessionTokens() || {};
+      ws.send(
+        JSON.stringify({
+          type: "resume",
+          token: resumeToken,
+          refresh_token: tokens.refresh_token || "",
+          last_seq: lastSeq,
+        })
+      );
+    }
     // Send initial resize
     sendResize();
+    // Log back in with stored tokens if the session couldn't be resumed
+    sendStoredTokens();
   };
 
   ws.onmessage = (event) => {
@@ -103,8 +173,31 @@ function connectWebSocket() {
       const message = JSON.parse(event.data);
 
       if (message.type === "output") {
+        // Skip output already shown before a reconnect
+        if (message.seq && message.seq <= lastSeq) return;
+        lastSeq = message.seq || lastSeq;
         // Write the data directly - server handles all ANSI sequences
         term.write(message.data);
+      } else if (message.type === "session") {
+        if (!message.resumed) {
+          // New session - start from a clean terminal
+          term.reset();
+          lastSeq = 0;
+          ackedSeq = 0;
+        }
+        resumeToken = message.token;
+      } else if (message.type === "auth") {
+        // Logged in or resumed - remember tokens for the next connection
+        saveSessionTokens(message);
+      } else if (message.type === "logout") {
+        // Logged out or session revoked - forget stored tokens
+        clearSessionTokens();
+      } else if (message.type === "shutdown") {
+        // Server is restarting - the close that follows is expected
+        serverShuttingDown = true;
+        term.write(
+          `\r\n\x1b[33mServer shutting down: ${message.reason}. Your progress has been saved.\x1b[0m\r\n`
+        );
       }
     } catch (e) {
       // If not JSON, write raw data
@@ -117,11 +210,26 @@ function connectWebSocket() {
   };
 
   ws.onclose = () => {
+    const seconds = Math.round(reconnectDelay / 1000);
     // Show reconnect message
-    term.write(
-      "\r\n\x1b[31mConnection closed. Reconnecting in 3 seconds...\x1b[0m\r\n"
+    if (serverShuttingDown) {
+      serverShuttingDown = false;
+      // The old session is gone; log in again with stored tokens
+      resumeToken = null;
+      term.write(
+        `\r\n\x1b[33mWaiting for server to restart. Reconnecting in ${seconds} seconds...\x1b[0m\r\n`
+      );
+    } else {
+      term.write(
+        `\r\n\x1b[31mConnection lost. Reconnecting in ${seconds} seconds...\x1b[0m\r\n`
+      );
+    }
+    setTimeout(connectWebSocket, reconnectDelay);
+    // Back off exponentially with jitter so clients don't reconnect in lockstep
+    reconnectDelay = Math.min(
+      MAX_RECONNECT_DELAY,
+      reconnectDelay * 2 + Math.random() * 1000
     );
-    setTimeout(connectWebSocket, 3000);
   };
 }
 